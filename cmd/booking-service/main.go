@@ -3,20 +3,30 @@ package main
 import (
 	"context"
 	"log"
+	"net"
 	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
+	"cred_flights_booking/internal/config"
 	"cred_flights_booking/internal/database"
+	"cred_flights_booking/internal/diagnostics"
+	"cred_flights_booking/internal/events"
 	"cred_flights_booking/internal/handlers"
+	"cred_flights_booking/internal/jobs"
+	"cred_flights_booking/internal/lifecycle"
+	"cred_flights_booking/internal/middleware"
+	"cred_flights_booking/internal/notifications"
 	"cred_flights_booking/internal/services"
+	"cred_flights_booking/internal/status"
 )
 
 func main() {
 	log.Println("Starting Booking Service...")
 
+	// Shutdown waits out bookings in flight, up to BOOKING_TIMEOUT
+	lc := lifecycle.New(lifecycle.LoadConfig("booking-service", 90*time.Second))
+
 	// Initialize database connection
 	db, err := database.NewPostgresDB()
 	if err != nil {
@@ -42,58 +52,213 @@ func main() {
 		paymentServiceURL = "http://localhost:8082"
 	}
 
-	bookingService := services.NewBookingServiceV2(db, cache, flightServiceURL, paymentServiceURL)
+	// Booking status changes are published for downstream consumers
+	bus := events.NewBus(cache, "booking-service", int64(config.GetInt("EVENT_STREAM_MAX_LEN", 100000)))
+
+	// Booking reads are served from a read model projected from booking events
+	readModel := services.NewBookingReadModel(db, cache)
+
+	policyService := services.NewCancellationPolicyService(db)
+	notifier := notifications.NewNotifier(notifications.LogSender{})
+	agencyService := services.NewAgencyService(db, cache, readModel)
+	funnelService := services.NewFunnelService(db, cache)
+	taxReportService := services.NewTaxReportService(db)
+	bookingService := services.NewBookingServiceV2(db, cache, bus, readModel, policyService, agencyService, notifier, flightServiceURL, paymentServiceURL)
+
+	// Start background jobs
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	// Each run bills agencies up to the start of the current billing period (UTC days by default)
+	invoiceInterval := config.GetDuration("AGENCY_INVOICE_INTERVAL", 24*time.Hour)
+	jobs.Start(jobCtx, jobs.Job{
+		Name:     "agency-invoicing",
+		Interval: invoiceInterval,
+		Timeout:  5 * time.Minute,
+		Run: func(ctx context.Context) error {
+			_, err := agencyService.InvoiceAgencies(ctx, time.Now().UTC().Truncate(invoiceInterval))
+			return err
+		},
+	})
+
+	jobs.Start(jobCtx, jobs.Job{
+		Name:     "funnel-report",
+		Interval: config.GetDuration("FUNNEL_REPORT_INTERVAL", time.Hour),
+		Timeout:  5 * time.Minute,
+		Run:      funnelService.AggregateRecent,
+	})
+
+	consumer := lc.Instance()
+	readModel.Start(jobCtx, bus, consumer)
+
+	// Receipts of confirmed bookings are posted to accounting (ACCOUNTING_WEBHOOK_URL)
+	receiptExporter := services.NewReceiptExporter(bookingService, services.LoadReceiptConfig())
+	receiptExporter.Start(jobCtx, bus, consumer)
+
+	// Bookings whose payment was voided uncaptured are cancelled
+	paymentVoids := services.NewPaymentVoidReconciler(bookingService)
+	paymentVoids.Start(jobCtx, bus, consumer)
+
+	// Booking status transitions are pushed to WebSocket clients
+	liveService := services.NewBookingLiveService(bookingService)
+	liveService.Start(jobCtx, bus)
+
+	jobs.Start(jobCtx, jobs.Job{
+		Name:     "booking-completion",
+		Interval: config.GetDuration("BOOKING_COMPLETION_INTERVAL", 15*time.Minute),
+		Timeout:  10 * time.Minute,
+		Run: func(ctx context.Context) error {
+			_, err := bookingService.CompleteFlownBookings(ctx)
+			return err
+		},
+	})
 
 	// Initialize handlers
-	bookingHandlers := handlers.NewBookingHandlers(bookingService)
+	bookingHandlers := handlers.NewBookingHandlers(bookingService, agencyService)
+	policyHandlers := handlers.NewCancellationPolicyHandlers(policyService)
+	agencyHandlers := handlers.NewAgencyHandlers(agencyService)
+	funnelHandlers := handlers.NewFunnelHandlers(funnelService)
+	taxHandlers := handlers.NewTaxHandlers(taxReportService)
+	adminViewHandlers := handlers.NewAdminViewHandlers(bookingService)
+	liveHandlers := handlers.NewBookingLiveHandlers(liveService)
+	deadLetterHandlers := handlers.NewDeadLetterHandlers(bus)
 
 	// Create HTTP server with Go 1.22 ServeMux
 	mux := http.NewServeMux()
 
+	// Route groups with per-group request deadlines
+	writes := middleware.NewGroup(mux, middleware.Timeout(config.GetDuration("BOOKING_TIMEOUT", 60*time.Second)))
+	api := middleware.NewGroup(mux, middleware.Timeout(config.GetDuration("API_TIMEOUT", 10*time.Second)))
+	streams := middleware.NewGroup(mux) // Long-lived connections, no deadline
+
 	// Register routes
-	mux.HandleFunc("POST /api/bookings", bookingHandlers.CreateBooking)
-	mux.HandleFunc("GET /api/bookings/{id}", bookingHandlers.GetBooking)
-	mux.HandleFunc("PUT /api/bookings/{id}/cancel", bookingHandlers.CancelBooking)
-
-	// Health check endpoint
-	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"healthy","service":"booking-service"}`))
-	})
+	writes.HandleFunc("POST /api/bookings", bookingHandlers.CreateBooking)
+	writes.HandleFunc("POST /api/bookings/batch", bookingHandlers.CreateBookings)
+	api.HandleFunc("POST /api/bookings/holds/{id}/extend", bookingHandlers.ExtendHold)
+	api.HandleFunc("GET /api/bookings/{id}", bookingHandlers.GetBooking)
+	writes.HandleFunc("PUT /api/bookings/{id}/cancel", bookingHandlers.CancelBooking)
+	api.HandleFunc("POST /api/bookings/{id}/resend-confirmation", bookingHandlers.ResendConfirmation)
+	api.HandleFunc("GET /api/bookings/{id}/export", bookingHandlers.ExportBooking)
+	api.HandleFunc("GET /api/bookings/{id}/offers", bookingHandlers.ListUpgradeOffers)
+	writes.HandleFunc("POST /api/bookings/{id}/offers/{offer_id}/accept", bookingHandlers.AcceptUpgradeOffer)
+	api.HandleFunc("POST /api/bookings/{id}/links", bookingHandlers.LinkBooking)
+	api.HandleFunc("DELETE /api/bookings/{id}/links", bookingHandlers.UnlinkBooking)
+	api.HandleFunc("GET /api/bookings/{id}/itinerary", bookingHandlers.GetItinerary)
+	api.HandleFunc("GET /api/bookings/seats", bookingHandlers.GetConfirmedSeats)
+	api.HandleFunc("GET /api/users/{id}/bookings", bookingHandlers.ListUserBookings)
+	api.HandleFunc("GET /api/users/{id}/loyalty", bookingHandlers.GetLoyaltyBalance)
+	streams.HandleFunc("GET /api/ws", liveHandlers.Connect)
+
+	// Agency routes (agency API key)
+	api.HandleFunc("GET /api/agency/account", agencyHandlers.GetAccount)
+	api.HandleFunc("GET /api/agency/bookings", agencyHandlers.ListBookings)
+	api.HandleFunc("GET /api/agency/invoices", agencyHandlers.ListInvoices)
+
+	// Personal data routes (admin)
+	api.HandleFunc("GET /api/users/{id}/export", bookingHandlers.ExportUserData)
+	writes.HandleFunc("DELETE /api/users/{id}/data", bookingHandlers.EraseUserData)
+
+	// Admin routes
+	writes.HandleFunc("POST /api/admin/bookings", bookingHandlers.CreateManualBooking)
+	api.HandleFunc("GET /api/admin/cancellation-policies", policyHandlers.ListPolicies)
+	api.HandleFunc("GET /api/admin/cancellation-policies/{fare_code}", policyHandlers.GetPolicy)
+	api.HandleFunc("PUT /api/admin/cancellation-policies/{fare_code}", policyHandlers.PutPolicy)
+	api.HandleFunc("DELETE /api/admin/cancellation-policies/{fare_code}", policyHandlers.DeletePolicy)
+	api.HandleFunc("POST /api/admin/agencies", agencyHandlers.CreateAgency)
+	api.HandleFunc("GET /api/admin/agencies", agencyHandlers.ListAgencies)
+	api.HandleFunc("GET /api/admin/agencies/{id}", agencyHandlers.GetAgencyAccount)
+	api.HandleFunc("POST /api/admin/agencies/{id}/invoices/{invoice_id}/pay", agencyHandlers.MarkInvoicePaid)
+	api.HandleFunc("GET /api/admin/funnel", funnelHandlers.GetReport)
+	api.HandleFunc("GET /api/admin/tax/report", taxHandlers.GetReport)
+
+	// Admin dashboard views (role-scoped)
+	api.HandleFunc("GET /api/admin/views/session", adminViewHandlers.GetSession)
+	api.HandleFunc("GET /api/admin/views/flights/{id}", adminViewHandlers.GetFlightView)
+
+	// Events consumers gave up on (shared by all services)
+	api.HandleFunc("GET /api/admin/dlq", deadLetterHandlers.ListDeadLetters)
+	api.HandleFunc("POST /api/admin/dlq/{id}/replay", deadLetterHandlers.ReplayDeadLetter)
+
+	// Liveness, startup, and readiness probes, plus preStop draining and /quitquitquit
+	lc.Mount(mux, []status.Check{
+		status.PostgresCheck("postgres", db),
+		status.RedisCheck("redis", cache),
+	}, handlers.AdminOnly)
+
+	// Diagnostics (pprof, expvar, runtime stats) on the internal DEBUG_ADDR only
+	diagnostics.Serve(diagnostics.NewHandler("booking-service", map[string]diagnostics.PoolStats{
+		"postgres": func() interface{} { return db.Stats() },
+		"redis":    func() interface{} { return cache.PoolStats() },
+	}), "127.0.0.1:6061")
+
+	// Dependency dashboard for incidents, behind admin auth
+	statusTimeout := config.GetDuration("STATUS_CHECK_TIMEOUT", 2*time.Second)
+	statusClient := &http.Client{Timeout: statusTimeout}
+	mux.Handle("GET /internal/status", handlers.AdminOnly(status.NewHandler(status.Config{
+		Service: "booking-service",
+		Checks: []status.Check{
+			status.PostgresCheck("postgres", db),
+			status.RedisCheck("redis", cache),
+			status.ServiceCheck("flight-service", flightServiceURL, statusClient),
+			status.ServiceCheck("payment-service", paymentServiceURL, statusClient),
+		},
+		Queues: []status.Queue{
+			{Name: "booking_read_model", Depth: func(ctx context.Context) (int64, error) { return readModel.Backlog(ctx, bus) }},
+			{Name: "booking_receipts", Depth: func(ctx context.Context) (int64, error) { return receiptExporter.Backlog(ctx, bus) }},
+			{Name: "payment_voids", Depth: func(ctx context.Context) (int64, error) { return paymentVoids.Backlog(ctx, bus) }},
+		},
+		Caches: []status.Cache{
+			status.RedisKeyspaceCache("redis_keyspace", cache),
+		},
+		Breakers: []string{"load_shedding", "http_hedging"},
+		Timeout:  statusTimeout,
+	})))
+	// Apply shared middleware
+	handler := middleware.Chain(mux,
+		middleware.Trace(),
+		middleware.LoadShed(middleware.LoadShedConfig(10*time.Second)),
+		middleware.SlowRequests(config.GetDuration("SLOW_REQUEST_THRESHOLD", time.Second)),
+		middleware.CORS(middleware.LoadCORSConfig()),
+		middleware.Chaos(middleware.LoadChaosConfig()),
+		middleware.Compress(config.GetInt("COMPRESSION_MIN_BYTES", 1024)),
+	)
 
 	// Create HTTP server
 	server := &http.Server{
 		Addr:         ":8081",
-		Handler:      mux,
+		Handler:      handler,
 		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
+		WriteTimeout: 90 * time.Second, // Must exceed BOOKING_TIMEOUT so the 504 reaches the client
 		IdleTimeout:  60 * time.Second,
 	}
 
-	// Start server in a goroutine
+	// Draining responses close their connections so clients reconnect to another replica
+	lc.OnDrain(func() { server.SetKeepAlivesEnabled(false) })
+
+	// Listen before marking the service started, so the startup probe only passes once
+	// connections are accepted
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
 	go func() {
 		log.Printf("Booking Service listening on port 8081")
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Failed to start server: %v", err)
 		}
 	}()
+	lc.MarkStarted()
 
-	// Wait for interrupt signal to gracefully shutdown the server
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	// Wait for SIGTERM, SIGINT, or /quitquitquit, then drain before shutting down
+	lc.Wait()
 
 	log.Println("Shutting down Booking Service...")
 
-	// Create a deadline for server shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	// Attempt graceful shutdown
-	if err := server.Shutdown(ctx); err != nil {
+	// Stop routing new requests here, then let in-flight requests finish
+	if err := lc.Shutdown(server); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
+	stopJobs()
 
 	log.Println("Booking Service exited")
 }
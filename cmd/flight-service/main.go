@@ -3,20 +3,45 @@ package main
 import (
 	"context"
 	"log"
+	"net"
 	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
+	"cred_flights_booking/internal/config"
 	"cred_flights_booking/internal/database"
+	"cred_flights_booking/internal/diagnostics"
+	"cred_flights_booking/internal/events"
+	"cred_flights_booking/internal/experiments"
+	"cred_flights_booking/internal/farequote"
+	"cred_flights_booking/internal/feeds"
 	"cred_flights_booking/internal/handlers"
+	"cred_flights_booking/internal/jobs"
+	"cred_flights_booking/internal/lifecycle"
+	"cred_flights_booking/internal/middleware"
+	"cred_flights_booking/internal/notifications"
+	"cred_flights_booking/internal/seatnonce"
 	"cred_flights_booking/internal/services"
+	"cred_flights_booking/internal/status"
+	"cred_flights_booking/internal/webhooks"
+	"cred_flights_booking/pkg/models"
 )
 
 func main() {
 	log.Println("Starting Flight Service...")
 
+	lc := lifecycle.New(lifecycle.LoadConfig("flight-service", 30*time.Second))
+
+	// Seat updates must carry nonces, so the service doesn't start without their secrets
+	if err := seatnonce.LoadConfig().Validate(); err != nil {
+		log.Fatalf("Invalid seat nonce config: %v", err)
+	}
+
+	// Payments are checked against the fare quotes signed here
+	if err := farequote.LoadConfig().Validate(); err != nil {
+		log.Fatalf("Invalid fare quote config: %v", err)
+	}
+
 	// Initialize database connection
 	db, err := database.NewPostgresDB()
 	if err != nil {
@@ -31,61 +56,287 @@ func main() {
 	}
 	defer cache.Close()
 
+	// Optionally move legacy cache keys under the configured namespace
+	if os.Getenv("CACHE_MIGRATE_KEYS") == "true" {
+		if _, err := cache.MigrateKeysToNamespace(context.Background()); err != nil {
+			log.Printf("Failed to migrate cache keys: %v", err)
+		}
+	}
+
+	// Get service URLs from environment
+	bookingServiceURL := os.Getenv("BOOKING_SERVICE_URL")
+	if bookingServiceURL == "" {
+		bookingServiceURL = "http://localhost:8081"
+	}
+
+	// Initialize event bus
+	bus := events.NewBus(cache, "flight-service", int64(config.GetInt("EVENT_STREAM_MAX_LEN", 100000)))
+
+	// Load A/B experiments (EXPERIMENTS)
+	registry, err := experiments.LoadRegistry(cache)
+	if err != nil {
+		log.Fatalf("Failed to load experiments: %v", err)
+	}
+
 	// Initialize services
-	flightService := services.NewFlightService(db, cache)
+	flightService := services.NewFlightService(db, cache, bus, registry, bookingServiceURL)
+	scheduleService := services.NewScheduleService(db, bus, config.GetInt("SCHEDULE_HORIZON_DAYS", 60))
+	partnerService := services.NewPartnerService(db, cache)
+	priceAlertService := services.NewPriceAlertService(db, flightService, notifications.NewNotifier(notifications.LogSender{}))
+	searchJobService := services.NewSearchJobService(flightService, cache)
+	searchAnalyticsService := services.NewSearchAnalyticsService(cache)
+
+	// External schedule/availability feed (FLIGHT_FEED_FILE)
+	feedConfig := feeds.LoadConfig()
+	var feedFetcher feeds.Fetcher
+	if feedConfig.File != "" {
+		feedFetcher = feeds.NewFileFetcher(feedConfig.File)
+	}
+	feedService := services.NewFeedSyncService(db, flightService, feedFetcher, feedConfig)
+
+	// Start background jobs
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	jobs.Start(jobCtx, jobs.Job{
+		Name:     "schedule-materializer",
+		Interval: config.GetDuration("SCHEDULE_MATERIALIZE_INTERVAL", time.Hour),
+		Timeout:  5 * time.Minute,
+		Run: func(ctx context.Context) error {
+			result, err := scheduleService.Materialize(ctx)
+			if err != nil {
+				return err
+			}
+			// Every flight in the horizon gets its seat counter before it can be booked
+			if _, err := flightService.OpenSeatCounters(ctx, result.From, result.To); err != nil {
+				return err
+			}
+			if result.Created == 0 {
+				return nil
+			}
+			// New flights may open routes the search would otherwise prune
+			return flightService.RefreshRouteGraph(ctx)
+		},
+	})
+
+	jobs.Start(jobCtx, jobs.Job{
+		Name:     "seat-counter-archive",
+		Interval: config.GetDuration("SEAT_COUNTER_ARCHIVE_INTERVAL", time.Hour),
+		Timeout:  5 * time.Minute,
+		Run: func(ctx context.Context) error {
+			_, err := flightService.ArchiveSeatCounters(ctx)
+			return err
+		},
+	})
+
+	jobs.Start(jobCtx, jobs.Job{
+		Name:     "route-graph",
+		Interval: config.GetDuration("ROUTE_GRAPH_INTERVAL", 15*time.Minute),
+		Timeout:  5 * time.Minute,
+		Run:      flightService.RefreshRouteGraph,
+	})
+
+	jobs.Start(jobCtx, jobs.Job{
+		Name:     "price-alerts",
+		Interval: config.GetDuration("PRICE_ALERT_INTERVAL", 15*time.Minute),
+		Timeout:  5 * time.Minute,
+		Run: func(ctx context.Context) error {
+			_, err := priceAlertService.CheckAlerts(ctx)
+			return err
+		},
+	})
+
+	jobs.Start(jobCtx, jobs.Job{
+		Name:     "popular-routes",
+		Interval: config.GetDuration("POPULAR_ROUTES_INTERVAL", 15*time.Minute),
+		Timeout:  5 * time.Minute,
+		Run: func(ctx context.Context) error {
+			_, err := flightService.BuildPopularRoutes(ctx)
+			return err
+		},
+	})
+
+	if feedFetcher != nil {
+		jobs.Start(jobCtx, jobs.Job{
+			Name:     "feed-sync",
+			Interval: feedConfig.Interval,
+			Timeout:  5 * time.Minute,
+			Run: func(ctx context.Context) error {
+				result, err := feedService.Sync(ctx, "feed-sync")
+				if err != nil || result.Created+result.Cancelled == 0 {
+					return err
+				}
+				// Added or withdrawn flights change which routes the search can serve
+				return flightService.RefreshRouteGraph(ctx)
+			},
+		})
+	}
+
+	// Purge CDN copies of searches whose inventory changed (CDN_PURGE_URL)
+	flightService.StartCDNPurges(jobCtx)
+
+	// Exhaustive searches run on background workers
+	searchJobService.Start(jobCtx)
 
 	// Initialize handlers
 	flightHandlers := handlers.NewFlightHandlers(flightService)
+	scheduleHandlers := handlers.NewScheduleHandlers(scheduleService)
+	partnerHandlers := handlers.NewPartnerHandlers(partnerService)
+	priceAlertHandlers := handlers.NewPriceAlertHandlers(priceAlertService)
+	searchJobHandlers := handlers.NewSearchJobHandlers(searchJobService)
+	searchAnalyticsHandlers := handlers.NewSearchAnalyticsHandlers(searchAnalyticsService)
+	feedHandlers := handlers.NewFeedHandlers(feedService)
+	deadLetterHandlers := handlers.NewDeadLetterHandlers(bus)
 
 	// Create HTTP server with Go 1.22 ServeMux
 	mux := http.NewServeMux()
 
+	// Route groups with per-group request deadlines
+	search := middleware.NewGroup(mux, middleware.Timeout(config.GetDuration("SEARCH_TIMEOUT", 30*time.Second)))
+	api := middleware.NewGroup(mux, middleware.Timeout(config.GetDuration("API_TIMEOUT", 10*time.Second)))
+	admin := middleware.NewGroup(mux, middleware.Timeout(config.GetDuration("ADMIN_TIMEOUT", 60*time.Second)))
+	webhookVerifier := webhooks.NewVerifier(webhooks.LoadConfig(), cache)
+	// Calls only other services may make, signed like webhooks and refused without WEBHOOK_SECRETS
+	service := middleware.NewGroup(mux,
+		middleware.Timeout(config.GetDuration("API_TIMEOUT", 10*time.Second)),
+		webhookVerifier.Require(),
+	)
+
 	// Register routes
-	mux.HandleFunc("GET /api/flights/search", flightHandlers.SearchFlights)
-	mux.HandleFunc("GET /api/flights/{id}", flightHandlers.GetFlight)
-	mux.HandleFunc("POST /api/flights/validate", flightHandlers.ValidateFlight)
-	mux.HandleFunc("POST /api/flights/seats/decrement", flightHandlers.DecrementSeats)
-	mux.HandleFunc("POST /api/flights/seats/increment", flightHandlers.IncrementSeats)
-
-	// Health check endpoint
-	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"healthy","service":"flight-service"}`))
-	})
+	search.HandleFunc("GET /api/flights/search", searchAnalyticsHandlers.Track(flightHandlers.SearchFlights))
+	search.HandleFunc("POST /api/flights/search/batch", flightHandlers.SearchFlightsBatch)
+	search.HandleFunc("POST /api/flights/availability/batch", flightHandlers.GetBatchAvailability)
+	api.HandleFunc("POST /api/flights/search/jobs", searchJobHandlers.CreateJob)
+	api.HandleFunc("GET /api/flights/search/jobs/{id}", searchJobHandlers.GetJob)
+	api.HandleFunc("GET /api/flights/lookup", flightHandlers.LookupFlights)
+	api.HandleFunc("GET /api/flights/popular", flightHandlers.GetPopularRoutes)
+	api.HandleFunc("GET /api/flights/{id}", flightHandlers.GetFlight)
+	api.HandleFunc("POST /api/flights/validate", flightHandlers.ValidateFlight)
+	api.HandleFunc("POST /api/flights/seats/decrement", flightHandlers.DecrementSeats)
+	api.HandleFunc("POST /api/flights/seats/increment", flightHandlers.IncrementSeats)
+	service.HandleFunc("POST /api/flights/seats/release-nonce", flightHandlers.IssueReleaseNonce)
+	api.HandleFunc("POST /api/flights/seats/reserve-batch", flightHandlers.ReserveSeatsBatch)
+	service.HandleFunc("POST /api/flights/occupancy/events", flightHandlers.RecordOccupancyEvent)
+	api.HandleFunc("GET /api/flights/{id}/load-factor", flightHandlers.GetLoadFactor)
+	api.HandleFunc("GET /api/flights/{id}/availability", flightHandlers.GetSeatAvailability)
+	api.HandleFunc("GET /api/flights/{id}/cabins", flightHandlers.ListCabins)
+	api.HandleFunc("POST /api/flights/cabins/quote", flightHandlers.QuoteUpgrades)
+	service.HandleFunc("POST /api/flights/cabins/move", flightHandlers.MoveCabinSeats)
+	api.HandleFunc("POST /api/price-alerts", priceAlertHandlers.CreateAlert)
+	api.HandleFunc("GET /api/price-alerts", priceAlertHandlers.ListAlerts)
+	api.HandleFunc("DELETE /api/price-alerts/{id}", priceAlertHandlers.CancelAlert)
+
+	// Partner routes (read-only, API key scoped and metered)
+	search.HandleFunc("GET /api/partner/v1/flights/search",
+		partnerHandlers.Require(models.PartnerScopeSearch, flightHandlers.SearchFlights))
+	api.HandleFunc("GET /api/partner/v1/flights/{id}/availability",
+		partnerHandlers.Require(models.PartnerScopeAvailability, flightHandlers.GetSeatAvailability))
+	search.HandleFunc("POST /api/partner/v1/flights/availability/batch",
+		partnerHandlers.Require(models.PartnerScopeAvailability, flightHandlers.GetBatchAvailability))
+	api.HandleFunc("GET /api/partner/v1/usage", partnerHandlers.GetUsage)
+
+	// Admin routes
+	admin.HandleFunc("PATCH /api/admin/flights/{id}", flightHandlers.UpdateFlight)
+	admin.HandleFunc("POST /api/admin/flights/{id}/cancel", flightHandlers.CancelFlight)
+	admin.HandleFunc("POST /api/admin/flights/{id}/seats/recalculate", flightHandlers.RecalculateSeats)
+	admin.HandleFunc("PUT /api/admin/flights/{id}/cabins/{cabin}", flightHandlers.SetCabin)
+	admin.HandleFunc("GET /api/admin/flights/{id}/seats/events", flightHandlers.ListSeatEvents)
+	admin.HandleFunc("POST /api/admin/seats/rebuild", flightHandlers.RebuildSeats)
+	admin.HandleFunc("GET /api/admin/flights/{id}/forecast", flightHandlers.GetForecast)
+	admin.HandleFunc("POST /api/admin/schedules", scheduleHandlers.CreateSchedule)
+	admin.HandleFunc("GET /api/admin/schedules", scheduleHandlers.ListSchedules)
+	admin.HandleFunc("POST /api/admin/schedules/materialize", scheduleHandlers.MaterializeSchedules)
+	admin.HandleFunc("POST /api/admin/feed/sync", feedHandlers.SyncFeed)
+	admin.HandleFunc("GET /api/admin/experiments", flightHandlers.GetExperimentStats)
+	admin.HandleFunc("POST /api/admin/partners", partnerHandlers.CreatePartner)
+	admin.HandleFunc("GET /api/admin/partners", partnerHandlers.ListPartners)
+	admin.HandleFunc("GET /api/admin/partners/{id}/usage", partnerHandlers.GetPartnerUsage)
+	admin.HandleFunc("GET /api/admin/search/anomalies", searchAnalyticsHandlers.GetAnomalies)
+	admin.HandleFunc("DELETE /api/admin/search/anomalies/{subject}", searchAnalyticsHandlers.ClearAnomaly)
+	admin.HandleFunc("GET /api/admin/search/activity", searchAnalyticsHandlers.GetActivity)
+	admin.HandleFunc("GET /api/admin/dlq", deadLetterHandlers.ListDeadLetters)
+	admin.HandleFunc("POST /api/admin/dlq/{id}/replay", deadLetterHandlers.ReplayDeadLetter)
+
+	// Liveness, startup, and readiness probes, plus preStop draining and /quitquitquit
+	lc.Mount(mux, []status.Check{
+		status.PostgresCheck("postgres", db),
+		status.RedisCheck("redis", cache),
+	}, handlers.AdminOnly)
+
+	// Diagnostics (pprof, expvar, runtime stats) on the internal DEBUG_ADDR only
+	diagnostics.Serve(diagnostics.NewHandler("flight-service", map[string]diagnostics.PoolStats{
+		"postgres": func() interface{} { return db.Stats() },
+		"redis":    func() interface{} { return cache.PoolStats() },
+	}), "127.0.0.1:6060")
+
+	// Dependency dashboard for incidents, behind admin auth
+	statusTimeout := config.GetDuration("STATUS_CHECK_TIMEOUT", 2*time.Second)
+	statusClient := &http.Client{Timeout: statusTimeout}
+	mux.Handle("GET /internal/status", handlers.AdminOnly(status.NewHandler(status.Config{
+		Service: "flight-service",
+		Checks: []status.Check{
+			status.PostgresCheck("postgres", db),
+			status.RedisCheck("redis", cache),
+			status.ServiceCheck("booking-service", bookingServiceURL, statusClient),
+		},
+		Queues: []status.Queue{
+			{Name: "search_jobs", Depth: searchJobService.QueueDepth},
+			{Name: "cdn_purges", Depth: flightService.PendingCDNPurges},
+		},
+		Caches: []status.Cache{
+			status.ExpvarCache("flight_search", "flight_search_cache"),
+			status.ExpvarCache("search_projection", "flight_search_projection_cache"),
+			status.RedisKeyspaceCache("redis_keyspace", cache),
+		},
+		Breakers: []string{"load_shedding", "http_hedging", "flight_search_coalescing"},
+		Timeout:  statusTimeout,
+	})))
+	// Apply shared middleware
+	handler := middleware.Chain(mux,
+		middleware.Trace(),
+		middleware.LoadShed(middleware.LoadShedConfig(time.Second)),
+		middleware.SlowRequests(config.GetDuration("SLOW_REQUEST_THRESHOLD", time.Second)),
+		middleware.CORS(middleware.LoadCORSConfig()),
+		middleware.Chaos(middleware.LoadChaosConfig()),
+		middleware.Compress(config.GetInt("COMPRESSION_MIN_BYTES", 1024)),
+	)
 
 	// Create HTTP server
 	server := &http.Server{
 		Addr:         ":8080",
-		Handler:      mux,
+		Handler:      handler,
 		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
+		WriteTimeout: 90 * time.Second, // Must exceed ADMIN_TIMEOUT, the longest group deadline, so the 504 reaches the client
 		IdleTimeout:  60 * time.Second,
 	}
 
-	// Start server in a goroutine
+	// Draining responses close their connections so clients reconnect to another replica
+	lc.OnDrain(func() { server.SetKeepAlivesEnabled(false) })
+
+	// Listen before marking the service started, so the startup probe only passes once
+	// connections are accepted
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
 	go func() {
 		log.Printf("Flight Service listening on port 8080")
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Failed to start server: %v", err)
 		}
 	}()
+	lc.MarkStarted()
 
-	// Wait for interrupt signal to gracefully shutdown the server
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	// Wait for SIGTERM, SIGINT, or /quitquitquit, then drain before shutting down
+	lc.Wait()
 
 	log.Println("Shutting down Flight Service...")
 
-	// Create a deadline for server shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	// Attempt graceful shutdown
-	if err := server.Shutdown(ctx); err != nil {
+	// Stop routing new requests here, then let in-flight requests finish
+	if err := lc.Shutdown(server); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
+	stopJobs()
 
 	log.Println("Flight Service exited")
 }
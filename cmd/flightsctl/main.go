@@ -0,0 +1,427 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/pkg/client"
+	"cred_flights_booking/pkg/models"
+	"github.com/google/uuid"
+)
+
+const usage = `flightsctl - operate the flight booking services from the command line
+
+Usage:
+  flightsctl <command> [flags]
+
+Commands:
+  search            Search flights (-from, -to, -date, -seats, -sort, -limit)
+  book              Create a booking (-user, -flight, -seats, -date, -fare, -email, -phone, -key)
+  cancel            Cancel a booking, or some of its seats (-booking, -seats)
+  seats recalc      Rebuild a flight date's seat counter from the database (-flight, -date; admin)
+  seats rebuild     Replay seat events into seat counters, all when unfiltered (-flight, -date; admin)
+  seats events      List a flight date's seat events (-flight, -date; admin)
+  cache inspect     List cache keys with type, TTL, and optionally values (-pattern, -limit, -values)
+  cache flush       Delete cache keys matching a pattern (-pattern, -yes)
+  payment simulate  Force a payment outcome (-outcome success|failure|timeout, -amount, -user, -booking)
+
+Services are reached at FLIGHT_SERVICE_URL, BOOKING_SERVICE_URL, and PAYMENT_SERVICE_URL
+(default localhost:8080-8082); admin commands send ADMIN_TOKEN (and ADMIN_USER, if set). Cache
+commands connect to Redis directly (REDIS_HOST, REDIS_PORT, CACHE_KEY_PREFIX).
+
+Exit status is 1 on errors and 3 when a service rejected the request.
+`
+
+// cli holds the service clients shared by every command
+type cli struct {
+	flights  *client.FlightClient
+	bookings *client.BookingClient
+	payments *client.PaymentClient
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "help" || os.Args[1] == "-h" || os.Args[1] == "--help" {
+		fmt.Print(usage)
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := newCLI().run(ctx, os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "flightsctl: %v\n", err)
+		var apiErr *client.APIError
+		if errors.As(err, &apiErr) {
+			os.Exit(3)
+		}
+		os.Exit(1)
+	}
+}
+
+// newCLI creates the service clients from the environment
+func newCLI() *cli {
+	newConfig := func(urlEnv, fallback string) client.Config {
+		return client.Config{
+			BaseURL:    config.GetEnv(urlEnv, fallback),
+			Timeout:    config.GetDuration("FLIGHTSCTL_TIMEOUT", 30*time.Second),
+			AdminUser:  config.GetEnv("ADMIN_USER", ""),
+			AdminToken: config.GetEnv("ADMIN_TOKEN", ""),
+		}
+	}
+	return &cli{
+		flights:  client.NewFlightClient(newConfig("FLIGHT_SERVICE_URL", "http://localhost:8080")),
+		bookings: client.NewBookingClient(newConfig("BOOKING_SERVICE_URL", "http://localhost:8081")),
+		payments: client.NewPaymentClient(newConfig("PAYMENT_SERVICE_URL", "http://localhost:8082")),
+	}
+}
+
+// run dispatches a command line to its command
+func (c *cli) run(ctx context.Context, args []string) error {
+	command, args := args[0], args[1:]
+	switch command {
+	case "search":
+		return c.search(ctx, args)
+	case "book":
+		return c.book(ctx, args)
+	case "cancel":
+		return c.cancel(ctx, args)
+	case "seats", "cache", "payment":
+		if len(args) == 0 {
+			return fmt.Errorf("%s needs a subcommand, see flightsctl help", command)
+		}
+		command, args = command+" "+args[0], args[1:]
+	}
+
+	switch command {
+	case "seats recalc":
+		return c.recalcSeats(ctx, args)
+	case "seats rebuild":
+		return c.rebuildSeats(ctx, args)
+	case "seats events":
+		return c.seatEvents(ctx, args)
+	case "cache inspect":
+		return inspectCache(ctx, args)
+	case "cache flush":
+		return flushCache(ctx, args)
+	case "payment simulate":
+		return c.simulatePayment(ctx, args)
+	}
+	return fmt.Errorf("unknown command %q, see flightsctl help", command)
+}
+
+// search prints the flight paths for a route and date as a table
+func (c *cli) search(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	from := fs.String("from", "", "source airport code (required)")
+	to := fs.String("to", "", "destination airport code (required)")
+	date := fs.String("date", "", "travel date, YYYY-MM-DD (required)")
+	seats := fs.Int("seats", 1, "seats needed")
+	sortBy := fs.String("sort", "cheapest", "cheapest, fastest, or recommended")
+	limit := fs.Int("limit", 10, "most paths printed (0 prints all)")
+	asJSON := fs.Bool("json", false, "print the raw response")
+	fs.Parse(args)
+
+	if *from == "" || *to == "" || *date == "" {
+		return errors.New("search needs -from, -to, and -date")
+	}
+
+	response, err := c.flights.Search(ctx, &models.SearchRequest{
+		Source:      strings.ToUpper(*from),
+		Destination: strings.ToUpper(*to),
+		Date:        *date,
+		Seats:       *seats,
+		SortBy:      *sortBy,
+	})
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(response)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tFLIGHTS\tROUTE\tDEPARTS\tARRIVES\tSTOPS\tDURATION\tPRICE")
+	for i, path := range response.Paths {
+		if *limit > 0 && i >= *limit {
+			break
+		}
+		first, last := path.Flights[0], path.Flights[len(path.Flights)-1]
+		var numbers, route []string
+		for _, flight := range path.Flights {
+			numbers = append(numbers, fmt.Sprintf("%s(%d)", flight.FlightNumber, flight.ID))
+			route = append(route, flight.Source)
+		}
+		route = append(route, last.Destination)
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d\t%dm\t%s\n", i+1,
+			strings.Join(numbers, ","), strings.Join(route, "-"),
+			first.DepartureTime.Format("2006-01-02 15:04"), last.ArrivalTime.Format("2006-01-02 15:04"),
+			path.Stops, path.TotalTime, path.TotalPrice)
+	}
+	w.Flush()
+	fmt.Printf("%d paths found\n", response.Count)
+	return nil
+}
+
+// book creates a booking. An idempotency key is generated when none is given, so the
+// client's retries can never book twice.
+func (c *cli) book(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("book", flag.ExitOnError)
+	req := models.BookingRequest{}
+	fs.IntVar(&req.UserID, "user", 0, "user ID (required)")
+	fs.IntVar(&req.FlightID, "flight", 0, "flight ID (required)")
+	fs.IntVar(&req.Seats, "seats", 1, "seats to book")
+	fs.StringVar(&req.Date, "date", "", "travel date, YYYY-MM-DD (required)")
+	fs.StringVar(&req.FareCode, "fare", "", "fare code (default standard)")
+	fs.StringVar(&req.Email, "email", "", "confirmation email")
+	fs.StringVar(&req.Phone, "phone", "", "confirmation phone, E.164")
+	fs.StringVar(&req.IdempotencyKey, "key", "", "idempotency key (default random)")
+	fs.Parse(args)
+
+	if req.IdempotencyKey == "" {
+		req.IdempotencyKey = uuid.New().String()
+	}
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	response, err := c.bookings.CreateBooking(ctx, &req)
+	if err != nil {
+		return err
+	}
+	if err := printJSON(response); err != nil {
+		return err
+	}
+	if response.Status == models.BookingStatusFailed {
+		return fmt.Errorf("booking failed: %s", response.Message)
+	}
+	return nil
+}
+
+// cancel cancels a booking, or only some of its seats
+func (c *cli) cancel(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("cancel", flag.ExitOnError)
+	bookingID := fs.Int("booking", 0, "booking ID (required)")
+	seats := fs.Int("seats", 0, "seats to cancel (0 cancels the whole booking)")
+	fs.Parse(args)
+
+	if *bookingID <= 0 {
+		return errors.New("cancel needs -booking")
+	}
+
+	response, err := c.bookings.CancelBooking(ctx, *bookingID, *seats)
+	if err != nil {
+		return err
+	}
+	return printJSON(response)
+}
+
+// recalcSeats rebuilds a flight date's seat counter from the database
+func (c *cli) recalcSeats(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("seats recalc", flag.ExitOnError)
+	flightID := fs.Int("flight", 0, "flight ID (required)")
+	date := fs.String("date", "", "flight date, YYYY-MM-DD (required)")
+	fs.Parse(args)
+
+	if *flightID <= 0 || *date == "" {
+		return errors.New("seats recalc needs -flight and -date")
+	}
+
+	response, err := c.flights.RecalculateSeats(ctx, *flightID, *date)
+	if err != nil {
+		return err
+	}
+	return printJSON(response)
+}
+
+// rebuildSeats replays seat events into the seat counters after a cache loss
+func (c *cli) rebuildSeats(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("seats rebuild", flag.ExitOnError)
+	flightID := fs.Int("flight", 0, "flight ID (default every flight)")
+	date := fs.String("date", "", "flight date, YYYY-MM-DD (default every date)")
+	fs.Parse(args)
+
+	response, err := c.flights.RebuildSeats(ctx, *flightID, *date)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FLIGHT\tDATE\tEVENTS\tPREVIOUS\tAVAILABLE\tDELTA")
+	for _, counter := range response.Counters {
+		previous := "-"
+		if counter.PreviousAvailable != nil {
+			previous = fmt.Sprint(*counter.PreviousAvailable)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%d\t%+d\n", counter.FlightID, counter.Date, counter.Events,
+			previous, counter.Available, counter.Delta)
+	}
+	w.Flush()
+	fmt.Printf("%d counters rebuilt\n", len(response.Counters))
+	return nil
+}
+
+// seatEvents prints a flight date's seat events with the counter each projects
+func (c *cli) seatEvents(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("seats events", flag.ExitOnError)
+	flightID := fs.Int("flight", 0, "flight ID (required)")
+	date := fs.String("date", "", "flight date, YYYY-MM-DD (required)")
+	fs.Parse(args)
+
+	if *flightID <= 0 || *date == "" {
+		return errors.New("seats events needs -flight and -date")
+	}
+
+	response, err := c.flights.SeatEvents(ctx, *flightID, *date)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tAT\tKIND\tSEATS\tAVAILABLE\tREASON\tACTOR")
+	for _, event := range response.Events {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%+d\t%d\t%s\t%s\n", event.ID, event.CreatedAt.Format(time.RFC3339),
+			event.Kind, event.Seats, event.Available, event.Reason, event.Actor)
+	}
+	w.Flush()
+	fmt.Printf("base %d seats, %d available after %d events\n", response.BaseSeats, response.Available, len(response.Events))
+	return nil
+}
+
+// simulatePayment forces a payment outcome without charging anything
+func (c *cli) simulatePayment(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("payment simulate", flag.ExitOnError)
+	outcome := fs.String("outcome", client.SimulateSuccess, "success, failure, or timeout")
+	req := models.PaymentRequest{}
+	amount := fs.Float64("amount", 1000, "payment amount")
+	fs.IntVar(&req.UserID, "user", 1, "user ID")
+	fs.IntVar(&req.BookingID, "booking", 1, "booking ID")
+	fs.StringVar(&req.PaymentType, "type", models.PaymentTypeCreditCard, "payment type")
+	fs.Parse(args)
+	parsedAmount, err := models.ParseMoneyFloat(*amount)
+	if err != nil {
+		return err
+	}
+	req.Amount = parsedAmount
+
+	response, err := c.payments.Simulate(ctx, *outcome, &req)
+	if err != nil {
+		return err
+	}
+	return printJSON(response)
+}
+
+// inspectCache lists the cache keys matching a pattern within CACHE_KEY_PREFIX
+func inspectCache(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("cache inspect", flag.ExitOnError)
+	pattern := fs.String("pattern", "*", "key pattern, without CACHE_KEY_PREFIX (e.g. flight_seats:*)")
+	limit := fs.Int("limit", 50, "most keys listed")
+	values := fs.Bool("values", false, "print string values (decompressed)")
+	fs.Parse(args)
+
+	cache, err := database.NewRedisClient()
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+
+	keys, err := scanKeys(ctx, cache, *pattern, *limit)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tTYPE\tTTL")
+	for _, key := range keys {
+		keyType, _ := cache.Type(ctx, key).Result()
+		ttl, _ := cache.TTL(ctx, key).Result()
+		ttlText := "none"
+		if ttl >= 0 {
+			ttlText = ttl.Round(time.Second).String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", key, keyType, ttlText)
+		if *values && keyType == "string" {
+			if payload, err := cache.GetPayload(ctx, key); err == nil {
+				fmt.Fprintf(w, "  %s\t\t\n", payload)
+			}
+		}
+	}
+	w.Flush()
+	fmt.Printf("%d keys listed (limit %d)\n", len(keys), *limit)
+	return nil
+}
+
+// flushCache deletes the cache keys matching a pattern within CACHE_KEY_PREFIX. Without
+// -yes it only lists what would be deleted.
+func flushCache(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("cache flush", flag.ExitOnError)
+	pattern := fs.String("pattern", "", "key pattern, without CACHE_KEY_PREFIX (required, e.g. flight_search:*)")
+	confirm := fs.Bool("yes", false, "delete the keys instead of listing them")
+	fs.Parse(args)
+
+	if *pattern == "" {
+		return errors.New("cache flush needs -pattern")
+	}
+
+	cache, err := database.NewRedisClient()
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+
+	keys, err := scanKeys(ctx, cache, *pattern, 0)
+	if err != nil {
+		return err
+	}
+	if !*confirm {
+		for _, key := range keys {
+			fmt.Println(key)
+		}
+		fmt.Printf("%d keys match; run again with -yes to delete them\n", len(keys))
+		return nil
+	}
+
+	deleted := 0
+	for start := 0; start < len(keys); start += 500 {
+		batch := keys[start:min(start+500, len(keys))]
+		n, err := cache.Del(ctx, batch...).Result()
+		if err != nil {
+			return fmt.Errorf("failed to delete keys: %w", err)
+		}
+		deleted += int(n)
+	}
+	fmt.Printf("Deleted %d keys matching %s%s\n", deleted, database.KeyPrefix(), *pattern)
+	return nil
+}
+
+// scanKeys returns up to limit keys (0 for all) matching a pattern within CACHE_KEY_PREFIX
+func scanKeys(ctx context.Context, cache *database.RedisClient, pattern string, limit int) ([]string, error) {
+	var keys []string
+	iter := cache.Scan(ctx, 0, database.KeyPrefix()+pattern, 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if limit > 0 && len(keys) >= limit {
+			break
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan keys: %w", err)
+	}
+	return keys, nil
+}
+
+// printJSON prints a response as indented JSON
+func printJSON(value interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
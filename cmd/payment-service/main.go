@@ -3,21 +3,71 @@ package main
 import (
 	"context"
 	"log"
+	"net"
 	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
+	"cred_flights_booking/internal/auditlog"
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/internal/diagnostics"
+	"cred_flights_booking/internal/events"
+	"cred_flights_booking/internal/farequote"
 	"cred_flights_booking/internal/handlers"
+	"cred_flights_booking/internal/jobs"
+	"cred_flights_booking/internal/lifecycle"
+	"cred_flights_booking/internal/logredact"
+	"cred_flights_booking/internal/middleware"
 	"cred_flights_booking/internal/services"
+	"cred_flights_booking/internal/status"
 )
 
 func main() {
+	// Amounts, booking IDs, and user IDs are scrubbed from the application logs
+	logredact.Install(logredact.LoadConfig())
+
 	log.Println("Starting Payment Service...")
 
+	lc := lifecycle.New(lifecycle.LoadConfig("payment-service", 30*time.Second))
+
+	// Amounts are only charged against signed fare quotes, so the service doesn't start
+	// without their secrets
+	if err := farequote.LoadConfig().Validate(); err != nil {
+		log.Fatalf("Invalid fare quote config: %v", err)
+	}
+
+	// Payments are recorded in a separate hash-chained log
+	auditLog, err := auditlog.Open(auditlog.LoadConfig())
+	if err != nil {
+		log.Fatalf("Failed to open payment audit log: %v", err)
+	}
+
+	// Initialize Redis connection
+	cache, err := database.NewRedisClient()
+	if err != nil {
+		log.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	defer cache.Close()
+
+	// Voids of uncaptured authorizations are published for booking-service to reconcile
+	bus := events.NewBus(cache, "payment-service", int64(config.GetInt("EVENT_STREAM_MAX_LEN", 100000)))
+
 	// Initialize services
-	paymentService := services.NewPaymentService()
+	paymentService := services.NewPaymentService(auditLog, cache, bus)
+
+	// Start background jobs
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	jobs.Start(jobCtx, jobs.Job{
+		Name:     "payment-auth-void",
+		Interval: config.GetDuration("PAYMENT_VOID_INTERVAL", time.Minute),
+		Timeout:  5 * time.Minute,
+		Run: func(ctx context.Context) error {
+			_, err := paymentService.VoidExpiredAuthorizations(ctx)
+			return err
+		},
+	})
 
 	// Initialize handlers
 	paymentHandlers := handlers.NewPaymentHandlers(paymentService)
@@ -25,51 +75,86 @@ func main() {
 	// Create HTTP server with Go 1.22 ServeMux
 	mux := http.NewServeMux()
 
+	// Route group with a shared request deadline
+	payments := middleware.NewGroup(mux, middleware.Timeout(config.GetDuration("PAYMENT_TIMEOUT", 30*time.Second)))
+
 	// Register routes
-	mux.HandleFunc("POST /api/payments/process", paymentHandlers.ProcessPayment)
-	mux.HandleFunc("POST /api/payments/simulate/failure", paymentHandlers.SimulatePaymentFailure)
-	mux.HandleFunc("POST /api/payments/simulate/timeout", paymentHandlers.SimulatePaymentTimeout)
-	mux.HandleFunc("POST /api/payments/simulate/success", paymentHandlers.SimulatePaymentSuccess)
-
-	// Health check endpoint
-	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"healthy","service":"payment-service"}`))
-	})
+	payments.HandleFunc("POST /api/payments/process", paymentHandlers.ProcessPayment)
+	payments.HandleFunc("POST /api/payments/{id}/capture", paymentHandlers.CapturePayment)
+	payments.HandleFunc("POST /api/payments/{id}/reverse", paymentHandlers.ReversePayment)
+	payments.HandleFunc("GET /api/payments/personas", paymentHandlers.ListPersonas)
+	payments.HandleFunc("POST /api/payments/simulate/failure", paymentHandlers.SimulatePaymentFailure)
+	payments.HandleFunc("POST /api/payments/simulate/timeout", paymentHandlers.SimulatePaymentTimeout)
+	payments.HandleFunc("POST /api/payments/simulate/success", paymentHandlers.SimulatePaymentSuccess)
+	payments.HandleFunc("GET /api/admin/payments/rates", paymentHandlers.GetRates)
+	payments.HandleFunc("PUT /api/admin/payments/rates", paymentHandlers.PutRates)
+	payments.HandleFunc("GET /api/admin/payments/audit", paymentHandlers.ListAuditLog)
+	payments.HandleFunc("GET /api/admin/payments/audit/verify", paymentHandlers.VerifyAuditLog)
+
+	// Liveness, startup, and readiness probes, plus preStop draining and /quitquitquit
+	lc.Mount(mux, []status.Check{
+		status.RedisCheck("redis", cache),
+	}, handlers.AdminOnly)
+
+	// Diagnostics (pprof, expvar, runtime stats) on the internal DEBUG_ADDR only
+	diagnostics.Serve(diagnostics.NewHandler("payment-service", nil), "127.0.0.1:6062")
+
+	// Dependency dashboard for incidents, behind admin auth
+	statusTimeout := config.GetDuration("STATUS_CHECK_TIMEOUT", 2*time.Second)
+	mux.Handle("GET /internal/status", handlers.AdminOnly(status.NewHandler(status.Config{
+		Service: "payment-service",
+		Checks: []status.Check{
+			status.RedisCheck("redis", cache),
+		},
+		Breakers: []string{"load_shedding"},
+		Timeout:  statusTimeout,
+	})))
+	// Apply shared middleware
+	handler := middleware.Chain(mux,
+		middleware.Trace(),
+		middleware.LoadShed(middleware.LoadShedConfig(6*time.Second)),
+		middleware.SlowRequests(config.GetDuration("SLOW_REQUEST_THRESHOLD", time.Second)),
+		middleware.CORS(middleware.LoadCORSConfig()),
+		middleware.Chaos(middleware.LoadChaosConfig()),
+		middleware.Compress(config.GetInt("COMPRESSION_MIN_BYTES", 1024)),
+	)
 
 	// Create HTTP server
 	server := &http.Server{
 		Addr:         ":8082",
-		Handler:      mux,
+		Handler:      handler,
 		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
+		WriteTimeout: 60 * time.Second, // Must exceed PAYMENT_TIMEOUT so the 504 reaches the client
 		IdleTimeout:  60 * time.Second,
 	}
 
-	// Start server in a goroutine
+	// Draining responses close their connections so clients reconnect to another replica
+	lc.OnDrain(func() { server.SetKeepAlivesEnabled(false) })
+
+	// Listen before marking the service started, so the startup probe only passes once
+	// connections are accepted
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
 	go func() {
 		log.Printf("Payment Service listening on port 8082")
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Failed to start server: %v", err)
 		}
 	}()
+	lc.MarkStarted()
 
-	// Wait for interrupt signal to gracefully shutdown the server
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	// Wait for SIGTERM, SIGINT, or /quitquitquit, then drain before shutting down
+	lc.Wait()
 
 	log.Println("Shutting down Payment Service...")
 
-	// Create a deadline for server shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	// Attempt graceful shutdown
-	if err := server.Shutdown(ctx); err != nil {
+	// Stop routing new requests here, then let in-flight requests finish
+	if err := lc.Shutdown(server); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
+	stopJobs()
 
 	log.Println("Payment Service exited")
 }
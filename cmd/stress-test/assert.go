@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// JSON types a Schema can require
+const (
+	jsonObject  = "object"
+	jsonArray   = "array"
+	jsonString  = "string"
+	jsonNumber  = "number"
+	jsonInteger = "integer"
+	jsonBoolean = "boolean"
+	jsonNull    = "null"
+)
+
+// formatDateTime requires a string to be an RFC 3339 timestamp
+const formatDateTime = "date-time"
+
+// maxReportedMismatches bounds the mismatches listed in one failure
+const maxReportedMismatches = 10
+
+// Schema describes the expected shape of a JSON value, checked against the raw response
+// body so missing fields and wrong types are caught before the client decodes them away
+type Schema struct {
+	Type       string             // One of the json* types; empty accepts any type
+	Nullable   bool               // null is accepted as well as Type
+	Required   []string           // Object fields that must be present
+	Properties map[string]*Schema // Object fields, checked when present
+	Items      *Schema            // Array elements
+	MinItems   int                // Fewest array elements
+	Enum       []string           // Accepted string values
+	Format     string             // formatDateTime, for strings
+	Minimum    *float64           // Smallest accepted number
+	// Check asserts relations between an object's fields (e.g. count matches the paths),
+	// returning a mismatch description or ""
+	Check func(object map[string]interface{}) (expected, actual string)
+}
+
+// atLeast returns a pointer to a Minimum bound
+func atLeast(min float64) *float64 {
+	return &min
+}
+
+// Mismatch is one place where a response differs from its schema
+type Mismatch struct {
+	Path     string // JSONPath-like location, e.g. $.paths[0].flights[1].price
+	Expected string
+	Actual   string
+}
+
+// SchemaError lists every mismatch of a response, rendered as a diff
+type SchemaError struct {
+	Endpoint   string
+	Mismatches []Mismatch
+}
+
+// Error renders the mismatches as expected (-) and actual (+) lines per path
+func (e *SchemaError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s response does not match schema (%d mismatches):", e.Endpoint, len(e.Mismatches))
+	for i, m := range e.Mismatches {
+		if i == maxReportedMismatches {
+			fmt.Fprintf(&b, "\n  ... %d more", len(e.Mismatches)-i)
+			break
+		}
+		fmt.Fprintf(&b, "\n  %s\n    - expected: %s\n    + actual:   %s", m.Path, m.Expected, m.Actual)
+	}
+	return b.String()
+}
+
+// assertSchema validates a raw JSON response body against an endpoint's schema
+func assertSchema(endpoint string, schema *Schema, body []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return &SchemaError{Endpoint: endpoint, Mismatches: []Mismatch{{Path: "$", Expected: "JSON document", Actual: describe(string(body))}}}
+	}
+
+	var mismatches []Mismatch
+	schema.validate("$", value, &mismatches)
+	if len(mismatches) > 0 {
+		return &SchemaError{Endpoint: endpoint, Mismatches: mismatches}
+	}
+	return nil
+}
+
+// validate appends the mismatches of value, found at path, to mismatches
+func (s *Schema) validate(path string, value interface{}, mismatches *[]Mismatch) {
+	fail := func(expected string, actual interface{}) {
+		*mismatches = append(*mismatches, Mismatch{Path: path, Expected: expected, Actual: describe(actual)})
+	}
+
+	if value == nil {
+		if !s.Nullable && s.Type != "" && s.Type != jsonNull {
+			fail(s.Type, nil)
+		}
+		return
+	}
+	if s.Type != "" && !hasType(value, s.Type) {
+		fail(s.Type, value)
+		return
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, field := range s.Required {
+			if _, ok := v[field]; !ok {
+				*mismatches = append(*mismatches, Mismatch{Path: path + "." + field, Expected: "required field", Actual: "missing"})
+			}
+		}
+		fields := make([]string, 0, len(s.Properties))
+		for field := range s.Properties {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			if fieldValue, ok := v[field]; ok {
+				s.Properties[field].validate(path+"."+field, fieldValue, mismatches)
+			}
+		}
+		if s.Check != nil {
+			if expected, actual := s.Check(v); expected != "" {
+				*mismatches = append(*mismatches, Mismatch{Path: path, Expected: expected, Actual: actual})
+			}
+		}
+
+	case []interface{}:
+		if len(v) < s.MinItems {
+			fail(fmt.Sprintf("at least %d items", s.MinItems), fmt.Sprintf("%d items", len(v)))
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, mismatches)
+			}
+		}
+
+	case string:
+		if len(s.Enum) > 0 && !contains(s.Enum, v) {
+			fail(fmt.Sprintf("one of %q", s.Enum), v)
+		}
+		if s.Format == formatDateTime {
+			if _, err := time.Parse(time.RFC3339Nano, v); err != nil {
+				fail("RFC 3339 date-time", v)
+			}
+		}
+
+	case json.Number:
+		if s.Minimum != nil {
+			if n, err := v.Float64(); err == nil && n < *s.Minimum {
+				fail(fmt.Sprintf("%s >= %g", s.Type, *s.Minimum), v)
+			}
+		}
+	}
+}
+
+// hasType reports whether a decoded JSON value is of a schema type
+func hasType(value interface{}, typ string) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return typ == jsonObject
+	case []interface{}:
+		return typ == jsonArray
+	case string:
+		return typ == jsonString
+	case bool:
+		return typ == jsonBoolean
+	case json.Number:
+		if typ == jsonNumber {
+			return true
+		}
+		_, err := v.Int64()
+		return typ == jsonInteger && err == nil
+	}
+	return false
+}
+
+// describe renders an actual value for a mismatch: its JSON type and a short excerpt
+func describe(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return fmt.Sprintf("object with %d fields", len(v))
+	case []interface{}:
+		return fmt.Sprintf("array of %d items", len(v))
+	case string:
+		if len(v) > 60 {
+			v = v[:60] + "..."
+		}
+		return fmt.Sprintf("string %q", v)
+	case json.Number:
+		return "number " + v.String()
+	case bool:
+		return fmt.Sprintf("boolean %t", v)
+	}
+	return fmt.Sprint(value)
+}
+
+// contains reports whether values includes value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// bodyCaptureKey is the context key of a request's capturedBody
+type bodyCaptureKey struct{}
+
+// capturedBody receives the raw body of the response to a request
+type capturedBody struct {
+	data []byte
+}
+
+// withBodyCapture returns a context whose requests record their raw response body
+func withBodyCapture(ctx context.Context) (context.Context, *capturedBody) {
+	capture := &capturedBody{}
+	return context.WithValue(ctx, bodyCaptureKey{}, capture), capture
+}
+
+// captureTransport copies response bodies into the capturedBody of requests that carry one,
+// leaving the body readable for the typed client
+type captureTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t captureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	capture, ok := req.Context().Value(bodyCaptureKey{}).(*capturedBody)
+	if err != nil || !ok {
+		return resp, err
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	capture.data = data
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return resp, nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"cred_flights_booking/pkg/models"
+)
+
+// approvedPaymentUser is the payment mock's approved_user persona, whose payments always
+// succeed, so every booking of the cancellation test is confirmed and can be cancelled
+const approvedPaymentUser = 997
+
+// cancellationFlights are the flights the cancellation test books, from the seeded database
+var cancellationFlights = []int{3, 12, 14}
+
+// seatCounter is a flight date's seat counter and the database value it is reconciled with
+type seatCounter struct {
+	available         int
+	databaseAvailable int
+}
+
+// runCancellationTest books and cancels bookingsPerUser bookings per virtual user, some in
+// two partial steps, then checks each flight's seat counter and database seats are back to
+// their values before the run. Increment/decrement asymmetries under concurrency show up
+// as a difference. Nothing else should book these flights while it runs.
+func (st *StressTest) runCancellationTest(concurrentUsers, bookingsPerUser int) ValidationResult {
+	log.Printf("Starting cancellation seat-return test with %d users x %d bookings", concurrentUsers, bookingsPerUser)
+
+	date := getRandomDate()
+	before, err := st.readSeatCounters(date)
+	if err != nil {
+		result := TestResult{TestName: "Cancellation Seat Counters", Error: fmt.Sprintf("Failed to read seat counters before the run: %v", err)}
+		return ValidationResult{TotalTests: 1, FailedTests: 1, Failures: []TestResult{result}}
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		held      = make(map[int]int) // Seats of bookings left pending, per flight
+		collector = newResultCollector(concurrentUsers)
+	)
+	for i := 0; i < concurrentUsers; i++ {
+		wg.Add(1)
+		go func(userID int) {
+			defer wg.Done()
+			for n := 0; n < bookingsPerUser; n++ {
+				req := &models.BookingRequest{
+					UserID:   approvedPaymentUser,
+					FlightID: cancellationFlights[rand.Intn(len(cancellationFlights))],
+					Seats:    rand.Intn(3) + 1,
+					Date:     date,
+				}
+				testName := fmt.Sprintf("Cancellation User %d", userID)
+				result, heldSeats := st.bookAndCancel(testName, req)
+				if heldSeats > 0 {
+					mu.Lock()
+					held[req.FlightID] += heldSeats
+					mu.Unlock()
+				}
+				collector.record(userID, result)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	result := collector.result()
+	after, err := st.readSeatCounters(date)
+	if err != nil {
+		result.TotalTests++
+		result.FailedTests++
+		result.Failures = append(result.Failures, TestResult{TestName: "Cancellation Seat Counters", Error: fmt.Sprintf("Failed to read seat counters after the run: %v", err)})
+		return result
+	}
+
+	// Seats of pending bookings are still legitimately held
+	for _, flightID := range cancellationFlights {
+		expected := before[flightID]
+		expected.available -= held[flightID]
+		expected.databaseAvailable -= held[flightID]
+		actual := after[flightID]
+
+		check := TestResult{TestName: fmt.Sprintf("Seat Return Flight %d", flightID), Success: true}
+		if actual != expected {
+			check.Success = false
+			check.Error = fmt.Sprintf("Seats of flight %d on %s not returned exactly:\n  available_seats\n    - expected: %d\n    + actual:   %d\n  db_available_seats\n    - expected: %d\n    + actual:   %d",
+				flightID, date, expected.available, actual.available, expected.databaseAvailable, actual.databaseAvailable)
+		}
+
+		result.TotalTests++
+		if check.Success {
+			result.PassedTests++
+		} else {
+			result.FailedTests++
+			result.Failures = append(result.Failures, check)
+		}
+		log.Printf("  Flight %d: %d seats before, %d after (%d held by pending bookings)", flightID, before[flightID].available, actual.available, held[flightID])
+	}
+
+	log.Printf("Cancellation seat-return test completed:")
+	log.Printf("  Total checks: %d", result.TotalTests)
+	log.Printf("  Passed: %d", result.PassedTests)
+	log.Printf("  Failed: %d", result.FailedTests)
+
+	return result
+}
+
+// bookAndCancel books req and cancels the booking, in two partial steps half of the time.
+// Returns the result and the seats left held when the booking stays pending.
+func (st *StressTest) bookAndCancel(testName string, req *models.BookingRequest) (TestResult, int) {
+	testStart := time.Now()
+	ctx := context.Background()
+
+	booking, err := st.bookings.CreateBooking(ctx, req)
+	if err != nil {
+		return failedResult(testName, err, time.Since(testStart)), 0
+	}
+	switch booking.Status {
+	case models.BookingStatusConfirmed:
+	case models.BookingStatusFailed:
+		// Nothing was kept; the booking service returned the seats itself
+		return TestResult{TestName: testName, Success: true, Duration: time.Since(testStart), Response: booking}, 0
+	default:
+		return TestResult{
+			TestName: testName,
+			Error:    fmt.Sprintf("Booking %d left %s instead of confirmed; its %d seats stay held", booking.BookingID, booking.Status, req.Seats),
+			Duration: time.Since(testStart),
+		}, req.Seats
+	}
+
+	remaining := req.Seats
+	if remaining > 1 && rand.Intn(2) == 0 {
+		partial := rand.Intn(remaining-1) + 1
+		response, err := st.bookings.CancelBooking(ctx, booking.BookingID, partial)
+		if err != nil {
+			return failedResult(testName, fmt.Errorf("partial cancellation of booking %d: %w", booking.BookingID, err), time.Since(testStart)), 0
+		}
+		if response.SeatsCancelled != partial || response.RemainingSeats != remaining-partial {
+			return TestResult{
+				TestName: testName,
+				Error: fmt.Sprintf("Partial cancellation of booking %d: expected %d cancelled and %d remaining, got %d and %d",
+					booking.BookingID, partial, remaining-partial, response.SeatsCancelled, response.RemainingSeats),
+				Duration: time.Since(testStart),
+			}, 0
+		}
+		remaining -= partial
+	}
+
+	response, err := st.bookings.CancelBooking(ctx, booking.BookingID, 0)
+	if err != nil {
+		return failedResult(testName, fmt.Errorf("cancellation of booking %d: %w", booking.BookingID, err), time.Since(testStart)), 0
+	}
+	if response.Status != models.BookingStatusCancelled || response.SeatsCancelled != remaining {
+		return TestResult{
+			TestName: testName,
+			Error: fmt.Sprintf("Cancellation of booking %d: expected %s with %d seats cancelled, got %s with %d",
+				booking.BookingID, models.BookingStatusCancelled, remaining, response.Status, response.SeatsCancelled),
+			Duration: time.Since(testStart),
+		}, 0
+	}
+
+	return TestResult{TestName: testName, Success: true, Duration: time.Since(testStart), Response: response}, 0
+}
+
+// readSeatCounters reads the seat counters of the cancellation test's flights on date
+func (st *StressTest) readSeatCounters(date string) (map[int]seatCounter, error) {
+	counters := make(map[int]seatCounter, len(cancellationFlights))
+	for _, flightID := range cancellationFlights {
+		availability, err := st.flights.GetAvailability(context.Background(), flightID, date)
+		if err != nil {
+			return nil, fmt.Errorf("flight %d: %w", flightID, err)
+		}
+		counters[flightID] = seatCounter{available: availability.Available, databaseAvailable: availability.DatabaseAvailable}
+	}
+	return counters, nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"log"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// failureSampleSize is how many failed requests each test keeps for the detailed report
+const failureSampleSize = 50
+
+// Latency histogram buckets grow by 5% from 1µs, so percentiles are within 5% of the
+// exact value; the last bucket (~300s) holds anything slower
+const (
+	histogramGrowth  = 1.05
+	histogramBuckets = 400
+)
+
+// resultCollector aggregates a test's results in constant memory, however long it runs.
+// Counters and latencies are sharded per virtual user and updated atomically, so users
+// never wait on each other; failures keep a bounded reservoir sample for the report.
+type resultCollector struct {
+	shards   []collectorShard
+	warmUp   atomic.Int64
+	failures *failureReservoir
+}
+
+// collectorShard holds the counters of the virtual users mapped to it
+type collectorShard struct {
+	total   atomic.Int64
+	passed  atomic.Int64
+	failed  atomic.Int64
+	max     atomic.Int64
+	latency [histogramBuckets]atomic.Int64
+}
+
+// newResultCollector creates a collector with one shard per virtual user
+func newResultCollector(users int) *resultCollector {
+	if users < 1 {
+		users = 1
+	}
+	return &resultCollector{
+		shards:   make([]collectorShard, users),
+		failures: newFailureReservoir(failureSampleSize),
+	}
+}
+
+// recordWarmUp counts a request made during the warm-up, left out of the statistics
+func (c *resultCollector) recordWarmUp() {
+	c.warmUp.Add(1)
+}
+
+// record adds a virtual user's result
+func (c *resultCollector) record(user int, result TestResult) {
+	c.add(user, result, result.Success)
+}
+
+// recordTolerated adds a failed request the test still counts as passed, such as a payment
+// the gateway timed out, keeping it in the failure sample for the report
+func (c *resultCollector) recordTolerated(user int, result TestResult) {
+	c.failures.add(result)
+	c.add(user, result, true)
+}
+
+// add counts a result as passed or failed and records its latency
+func (c *resultCollector) add(user int, result TestResult, passed bool) {
+	shard := &c.shards[user%len(c.shards)]
+	shard.total.Add(1)
+	if passed {
+		shard.passed.Add(1)
+	} else {
+		shard.failed.Add(1)
+		c.failures.add(result)
+	}
+
+	shard.latency[histogramBucket(result.Duration)].Add(1)
+	for {
+		max := shard.max.Load()
+		if int64(result.Duration) <= max || shard.max.CompareAndSwap(max, int64(result.Duration)) {
+			break
+		}
+	}
+}
+
+// result merges the shards into the test's ValidationResult
+func (c *resultCollector) result() ValidationResult {
+	var (
+		result ValidationResult
+		counts [histogramBuckets]int64
+		max    time.Duration
+	)
+	for i := range c.shards {
+		shard := &c.shards[i]
+		result.TotalTests += int(shard.total.Load())
+		result.PassedTests += int(shard.passed.Load())
+		result.FailedTests += int(shard.failed.Load())
+		for b := range counts {
+			counts[b] += shard.latency[b].Load()
+		}
+		if d := time.Duration(shard.max.Load()); d > max {
+			max = d
+		}
+	}
+
+	result.WarmUpRequests = int(c.warmUp.Load())
+	result.Failures = c.failures.sample()
+	result.Latency = latencySummary{
+		Count: result.TotalTests,
+		P50:   histogramPercentile(&counts, 50),
+		P95:   histogramPercentile(&counts, 95),
+		P99:   histogramPercentile(&counts, 99),
+		Max:   max,
+	}
+	return result
+}
+
+// histogramBucket returns the latency bucket of d
+func histogramBucket(d time.Duration) int {
+	micros := float64(d) / float64(time.Microsecond)
+	if micros <= 1 {
+		return 0
+	}
+	bucket := int(math.Ceil(math.Log(micros) / math.Log(histogramGrowth)))
+	if bucket >= histogramBuckets {
+		return histogramBuckets - 1
+	}
+	return bucket
+}
+
+// histogramPercentile returns the upper bound of the bucket holding the nearest-rank p-th
+// percentile
+func histogramPercentile(counts *[histogramBuckets]int64, p float64) time.Duration {
+	var total int64
+	for _, n := range counts {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+
+	rank := int64(math.Ceil(p / 100 * float64(total)))
+	var seen int64
+	for bucket, n := range counts {
+		seen += n
+		if seen >= rank {
+			return time.Duration(math.Pow(histogramGrowth, float64(bucket)) * float64(time.Microsecond))
+		}
+	}
+	return 0
+}
+
+// latencySummary is the latency distribution of a test's steady-state requests
+type latencySummary struct {
+	Count int
+	P50   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// log prints the summary under a test's results
+func (s latencySummary) log() {
+	if s.Count == 0 {
+		log.Printf("  Latency: no steady-state requests")
+		return
+	}
+	log.Printf("  Latency (steady state): p50 %v, p95 %v, p99 %v, max %v",
+		s.P50.Round(time.Millisecond), s.P95.Round(time.Millisecond), s.P99.Round(time.Millisecond), s.Max.Round(time.Millisecond))
+}
+
+// failureReservoir keeps a uniform random sample of failed requests (Algorithm R). Only
+// failures take its lock, and it never holds more than its size.
+type failureReservoir struct {
+	mu      sync.Mutex
+	size    int
+	seen    int64
+	results []TestResult
+	rng     *rand.Rand
+}
+
+// newFailureReservoir creates a reservoir keeping up to size failures
+func newFailureReservoir(size int) *failureReservoir {
+	return &failureReservoir{
+		size:    size,
+		results: make([]TestResult, 0, size),
+		rng:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// add offers a failure to the sample
+func (r *failureReservoir) add(result TestResult) {
+	// The decoded response isn't reported and can be large
+	result.Response = nil
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seen++
+	if len(r.results) < r.size {
+		r.results = append(r.results, result)
+		return
+	}
+	if i := r.rng.Int63n(r.seen); i < int64(r.size) {
+		r.results[i] = result
+	}
+}
+
+// sample returns a copy of the sampled failures
+func (r *failureReservoir) sample() []TestResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]TestResult(nil), r.results...)
+}
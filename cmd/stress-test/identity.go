@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"cred_flights_booking/pkg/client"
+	"cred_flights_booking/pkg/models"
+)
+
+// virtualUser is one simulated user: its user ID and the keys its requests carry, so rate
+// limits, quotas, and agency credit apply per user as they would in production
+type virtualUser struct {
+	userID int
+	st     *StressTest
+
+	mu                 sync.Mutex
+	flights            *client.FlightClient  // Partner-key client; nil sends plain searches
+	bookings           *client.BookingClient // Agency-key client; nil books as the user
+	partnerProvisioned bool
+	agencyProvisioned  bool
+	session            session
+}
+
+// virtualUser returns the identity of the index-th virtual user, acquiring its keys on
+// first use. Every test's index-th user is the same identity.
+func (st *StressTest) virtualUser(ctx context.Context, index int) (*virtualUser, error) {
+	st.identitiesMu.Lock()
+	defer st.identitiesMu.Unlock()
+	if user, ok := st.identities[index]; ok {
+		return user, nil
+	}
+
+	user := &virtualUser{userID: index + 1, st: st}
+	creds := st.credentials
+	switch {
+	case len(creds.PartnerKeys) > 0:
+		user.flights = st.partnerClient(creds.PartnerKeys[index%len(creds.PartnerKeys)])
+	case creds.ProvisionPartners:
+		flights, err := st.provisionPartner(ctx, user.userID)
+		if err != nil {
+			return nil, err
+		}
+		user.flights, user.partnerProvisioned = flights, true
+	}
+	switch {
+	case len(creds.AgencyKeys) > 0:
+		user.bookings = st.agencyClient(creds.AgencyKeys[index%len(creds.AgencyKeys)])
+	case creds.ProvisionAgencies:
+		bookings, err := st.provisionAgency(ctx, user.userID)
+		if err != nil {
+			return nil, err
+		}
+		user.bookings, user.agencyProvisioned = bookings, true
+	}
+
+	st.identities[index] = user
+	return user, nil
+}
+
+// search searches as the user: through the partner API with its key, when it has one
+func (u *virtualUser) search(ctx context.Context, req *models.SearchRequest) (*models.SearchResponse, error) {
+	req.UserID = u.userID
+	u.mu.Lock()
+	flights := u.flights
+	u.mu.Unlock()
+	if flights == nil {
+		return u.st.flights.Search(ctx, req)
+	}
+
+	response, err := flights.PartnerSearch(ctx, req)
+	if !errors.Is(err, client.ErrUnauthorized) || !u.partnerProvisioned {
+		return response, err
+	}
+	// The key was revoked or expired: acquire a new one and try once more
+	if flights, err = u.refreshPartner(ctx, flights); err != nil {
+		return nil, err
+	}
+	return flights.PartnerSearch(ctx, req)
+}
+
+// book books as the user: on agency credit with its key, when it has one
+func (u *virtualUser) book(ctx context.Context, req *models.BookingRequest) (*models.BookingResponse, error) {
+	req.UserID = u.userID
+	u.mu.Lock()
+	bookings := u.bookings
+	u.mu.Unlock()
+	if bookings == nil {
+		return u.st.bookings.CreateBooking(ctx, req)
+	}
+
+	response, err := bookings.CreateBooking(ctx, req)
+	if !errors.Is(err, client.ErrUnauthorized) || !u.agencyProvisioned {
+		return response, err
+	}
+	if bookings, err = u.refreshAgency(ctx, bookings); err != nil {
+		return nil, err
+	}
+	return bookings.CreateBooking(ctx, req)
+}
+
+// refreshPartner replaces a rejected partner key, unless another request already did
+func (u *virtualUser) refreshPartner(ctx context.Context, rejected *client.FlightClient) (*client.FlightClient, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.flights != rejected {
+		return u.flights, nil
+	}
+	flights, err := u.st.provisionPartner(ctx, u.userID)
+	if err != nil {
+		return nil, err
+	}
+	u.flights = flights
+	return flights, nil
+}
+
+// refreshAgency replaces a rejected agency key, unless another request already did
+func (u *virtualUser) refreshAgency(ctx context.Context, rejected *client.BookingClient) (*client.BookingClient, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.bookings != rejected {
+		return u.bookings, nil
+	}
+	bookings, err := u.st.provisionAgency(ctx, u.userID)
+	if err != nil {
+		return nil, err
+	}
+	u.bookings = bookings
+	return bookings, nil
+}
+
+// provisionPartner creates a partner for a virtual user and returns a client with its key
+func (st *StressTest) provisionPartner(ctx context.Context, userID int) (*client.FlightClient, error) {
+	created, err := st.adminFlights.CreatePartner(ctx, &models.PartnerRequest{
+		Name:              fmt.Sprintf("stress-test-user-%d-%d", userID, time.Now().UnixNano()),
+		RequestsPerMinute: st.credentials.PartnerRequestsPerMinute,
+		DailyQuota:        st.credentials.PartnerDailyQuota,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to provision partner for user %d: %w", userID, err)
+	}
+	return st.partnerClient(created.APIKey), nil
+}
+
+// provisionAgency creates an agency for a virtual user and returns a client with its key
+func (st *StressTest) provisionAgency(ctx context.Context, userID int) (*client.BookingClient, error) {
+	created, err := st.adminBookings.CreateAgency(ctx, &models.AgencyRequest{
+		Name:        fmt.Sprintf("stress-test-user-%d-%d", userID, time.Now().UnixNano()),
+		CreditLimit: st.credentials.AgencyCreditLimit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to provision agency for user %d: %w", userID, err)
+	}
+	return st.agencyClient(created.APIKey), nil
+}
+
+// partnerClient returns a Flight Service client sending a partner key
+func (st *StressTest) partnerClient(apiKey string) *client.FlightClient {
+	cfg := st.clientConfig(flightServiceURL)
+	cfg.APIKey = apiKey
+	return client.NewFlightClient(cfg)
+}
+
+// agencyClient returns a Booking Service client sending an agency key
+func (st *StressTest) agencyClient(agencyKey string) *client.BookingClient {
+	cfg := st.clientConfig(bookingServiceURL)
+	cfg.AgencyKey = agencyKey
+	return client.NewBookingClient(cfg)
+}
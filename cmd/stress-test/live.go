@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cred_flights_booking/internal/websocket"
+	"cred_flights_booking/pkg/models"
+)
+
+// liveStatusURL is the Booking Service's booking status WebSocket
+var liveStatusURL = "ws" + strings.TrimPrefix(bookingServiceURL, "http") + "/api/ws"
+
+// maxLiveSubscriptions bounds the bookings a virtual user subscribes to per connection
+const maxLiveSubscriptions = 5
+
+// liveSnapshotTimeout bounds the connect and the wait for every subscribed snapshot
+const liveSnapshotTimeout = 10 * time.Second
+
+// runLiveStatusTest has each virtual user repeatedly connect to the booking status
+// WebSocket, subscribe to its recent bookings, and watch them for a think time. A cycle's
+// duration is the connect plus the wait for every snapshot; pushed messages are checked
+// against the live update schema.
+func (st *StressTest) runLiveStatusTest(concurrentUsers int, phases loadPhases) ValidationResult {
+	log.Printf("Starting live booking status test with %d concurrent users for %v", concurrentUsers, phases)
+
+	var wg sync.WaitGroup
+	startTime := time.Now()
+	steadyStart, endTime := phases.schedule(startTime)
+
+	var pushes atomic.Int64
+	collector := newResultCollector(concurrentUsers)
+
+	for i := 0; i < concurrentUsers; i++ {
+		wg.Add(1)
+		go func(userID int) {
+			defer wg.Done()
+			testName := fmt.Sprintf("Live Status User %d", userID)
+			user, err := st.virtualUser(context.Background(), userID)
+			if err != nil {
+				collector.record(userID, failedResult(testName, err, 0))
+				return
+			}
+
+			for time.Now().Before(endTime) {
+				// Users watch bookings they made; one without any books first
+				bookingIDs := user.recentBookings(maxLiveSubscriptions)
+				if len(bookingIDs) == 0 {
+					req := user.nextBooking(context.Background())
+					if response, err := user.book(context.Background(), req); err == nil {
+						user.rememberBooking(req, response)
+					}
+					bookingIDs = user.recentBookings(maxLiveSubscriptions)
+				}
+
+				testStart := time.Now()
+				result, received := st.watchBookings(testName, bookingIDs, st.thinkTime(actionLive))
+				pushes.Add(int64(received))
+
+				if testStart.Before(steadyStart) {
+					collector.recordWarmUp()
+				} else {
+					collector.record(userID, result)
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	result := collector.result()
+
+	log.Printf("Live booking status test completed:")
+	log.Printf("  Warm-up connections (excluded): %d", result.WarmUpRequests)
+	log.Printf("  Total connections: %d", result.TotalTests)
+	log.Printf("  Successful: %d", result.PassedTests)
+	log.Printf("  Failed: %d", result.FailedTests)
+	log.Printf("  Pushed updates received: %d", pushes.Load())
+	result.Latency.log()
+
+	return result
+}
+
+// watchBookings opens one connection, subscribes to bookingIDs, waits for their snapshots,
+// then reads pushed updates for watch. Returns the result, timed up to the last snapshot,
+// and how many updates were pushed after the snapshots.
+func (st *StressTest) watchBookings(testName string, bookingIDs []int, watch time.Duration) (TestResult, int) {
+	testStart := time.Now()
+	fail := func(err error) (TestResult, int) {
+		result := failedResult(testName, err, time.Since(testStart))
+		var handshakeErr *websocket.HandshakeError
+		if errors.As(err, &handshakeErr) {
+			result.StatusCode = handshakeErr.StatusCode
+		}
+		return result, 0
+	}
+	if len(bookingIDs) == 0 {
+		return fail(errors.New("no confirmed booking to subscribe to"))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), liveSnapshotTimeout)
+	defer cancel()
+	conn, err := websocket.Dial(ctx, liveStatusURL, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer conn.Close(websocket.CloseNormal, "")
+
+	if err := conn.WriteJSON(&models.LiveRequest{Action: models.LiveActionSubscribe, BookingIDs: bookingIDs}); err != nil {
+		return fail(err)
+	}
+
+	// Every subscribed booking answers with a snapshot, or an error
+	pending := make(map[int]bool, len(bookingIDs))
+	for _, id := range bookingIDs {
+		pending[id] = true
+	}
+	conn.SetReadDeadline(time.Now().Add(liveSnapshotTimeout))
+	for len(pending) > 0 {
+		update, err := readLiveUpdate(conn)
+		if err != nil {
+			return fail(fmt.Errorf("waiting for snapshots of %d bookings: %w", len(pending), err))
+		}
+		if !pending[update.BookingID] {
+			continue
+		}
+		if update.Type == models.LiveUpdateError {
+			return fail(fmt.Errorf("subscribing to booking %d: %s", update.BookingID, update.Error))
+		}
+		if update.Type == models.LiveUpdateSnapshot {
+			delete(pending, update.BookingID)
+		}
+	}
+	duration := time.Since(testStart)
+
+	// Watch for pushed transitions until the user moves on
+	received := 0
+	conn.SetReadDeadline(time.Now().Add(watch))
+	for {
+		_, err := readLiveUpdate(conn)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			break
+		}
+		if err != nil {
+			return failedResult(testName, fmt.Errorf("watching bookings: %w", err), duration), received
+		}
+		received++
+	}
+
+	return TestResult{TestName: testName, Success: true, Duration: duration}, received
+}
+
+// readLiveUpdate reads one pushed message and checks it against the live update schema
+func readLiveUpdate(conn *websocket.Conn) (*models.BookingLiveUpdate, error) {
+	data, err := conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	if err := assertSchema("live update", liveUpdateSchema, data); err != nil {
+		return nil, err
+	}
+
+	var update models.BookingLiveUpdate
+	if err := json.Unmarshal(data, &update); err != nil {
+		return nil, fmt.Errorf("failed to decode live update: %w", err)
+	}
+	return &update, nil
+}
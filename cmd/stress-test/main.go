@@ -1,18 +1,19 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"io"
 	"log"
 	"math/rand"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"cred_flights_booking/internal/models"
+	"cred_flights_booking/pkg/client"
+	"cred_flights_booking/pkg/models"
 )
 
 const (
@@ -22,7 +23,18 @@ const (
 )
 
 type StressTest struct {
-	client *http.Client
+	httpClient *http.Client
+	flights    *client.FlightClient
+	bookings   *client.BookingClient
+	payments   *client.PaymentClient
+
+	// Identities and pacing of the virtual users, from the scenario
+	credentials   Credentials
+	thinkTimes    map[string]ThinkTime
+	adminFlights  *client.FlightClient
+	adminBookings *client.BookingClient
+	identitiesMu  sync.Mutex
+	identities    map[int]*virtualUser
 }
 
 type TestResult struct {
@@ -35,310 +47,211 @@ type TestResult struct {
 }
 
 type ValidationResult struct {
-	TotalTests  int
-	PassedTests int
-	FailedTests int
-	Results     []TestResult
+	TotalTests     int
+	PassedTests    int
+	FailedTests    int
+	WarmUpRequests int            // Made during the warm-up and left out of the counts
+	Latency        latencySummary // Steady-state latency percentiles
+	Failures       []TestResult   // Random sample of failed requests, at most failureSampleSize
 }
 
-func NewStressTest() *StressTest {
-	return &StressTest{
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+func NewStressTest(scenario *Scenario) *StressTest {
+	// Response bodies are captured so they can be checked against the endpoint schemas
+	st := &StressTest{
+		httpClient:  &http.Client{Timeout: 30 * time.Second, Transport: captureTransport{base: http.DefaultTransport}},
+		credentials: scenario.Credentials,
+		thinkTimes:  scenario.ThinkTimes,
+		identities:  make(map[int]*virtualUser),
 	}
+	st.flights = client.NewFlightClient(st.clientConfig(flightServiceURL))
+	st.bookings = client.NewBookingClient(st.clientConfig(bookingServiceURL))
+	st.payments = client.NewPaymentClient(st.clientConfig(paymentServiceURL))
+
+	adminConfig := func(baseURL string) client.Config {
+		cfg := st.clientConfig(baseURL)
+		cfg.AdminUser, cfg.AdminToken = st.credentials.AdminUser, st.credentials.AdminToken
+		return cfg
+	}
+	st.adminFlights = client.NewFlightClient(adminConfig(flightServiceURL))
+	st.adminBookings = client.NewBookingClient(adminConfig(bookingServiceURL))
+	return st
+}
+
+// clientConfig returns the config of an unauthenticated service client. Retries would
+// hide the failures the test is looking for.
+func (st *StressTest) clientConfig(baseURL string) client.Config {
+	return client.Config{BaseURL: baseURL, Timeout: 30 * time.Second, MaxAttempts: 1, HTTPClient: st.httpClient}
 }
 
-// validateResponse validates response against expected values
-func (st *StressTest) validateResponse(testName string, resp *http.Response, expectedStatus int, expectedFields map[string]interface{}) TestResult {
+// failedResult records a request that returned an error, with the status code when the
+// service answered
+func failedResult(testName string, err error, duration time.Duration) TestResult {
 	result := TestResult{
 		TestName: testName,
 		Success:  false,
+		Error:    fmt.Sprintf("Request failed: %v", err),
+		Duration: duration,
 	}
-
-	// Check HTTP status code
-	if resp.StatusCode != expectedStatus {
-		result.Error = fmt.Sprintf("Expected status %d, got %d", expectedStatus, resp.StatusCode)
-		result.StatusCode = resp.StatusCode
-		return result
-	}
-
-	// Parse response body
-	var responseData map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&responseData); err != nil {
-		result.Error = fmt.Sprintf("Failed to decode response: %v", err)
-		return result
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) {
+		result.StatusCode = apiErr.StatusCode
 	}
+	return result
+}
 
-	// Validate expected fields
-	for field, expectedValue := range expectedFields {
-		if actualValue, exists := responseData[field]; !exists {
-			result.Error = fmt.Sprintf("Missing field: %s", field)
-			return result
-		} else {
-			// Special handling for count field - check if > 0 instead of exact match
-			if field == "count" && expectedValue == float64(0) {
-				if count, ok := actualValue.(float64); !ok || count <= 0 {
-					result.Error = fmt.Sprintf("Field %s: expected > 0, got %v", field, actualValue)
-					return result
-				}
-			} else if actualValue != expectedValue {
-				result.Error = fmt.Sprintf("Field %s: expected %v, got %v", field, expectedValue, actualValue)
-				return result
-			}
-		}
+// schemaResult records a response, failing it with a diff of every mismatch when its raw
+// body doesn't match the endpoint's schema
+func schemaResult(testName, endpoint string, schema *Schema, body *capturedBody, response interface{}, duration time.Duration) TestResult {
+	if err := assertSchema(endpoint, schema, body.data); err != nil {
+		return TestResult{TestName: testName, Error: err.Error(), Duration: duration, Response: response}
 	}
-
-	result.Success = true
-	result.StatusCode = resp.StatusCode
-	result.Response = responseData
-	return result
+	return TestResult{TestName: testName, Success: true, Duration: duration, Response: response}
 }
 
-func (st *StressTest) runFlightSearchTest(concurrentUsers int, duration time.Duration) ValidationResult {
-	log.Printf("Starting flight search stress test with %d concurrent users for %v", concurrentUsers, duration)
+func (st *StressTest) runFlightSearchTest(concurrentUsers int, phases loadPhases) ValidationResult {
+	log.Printf("Starting flight search stress test with %d concurrent users for %v", concurrentUsers, phases)
 
 	var wg sync.WaitGroup
 	startTime := time.Now()
-	endTime := startTime.Add(duration)
+	steadyStart, endTime := phases.schedule(startTime)
 
-	// Track results
-	var (
-		totalRequests int64
-		successCount  int64
-		errorCount    int64
-		results       []TestResult
-		mu            sync.Mutex
-	)
+	// Track results; requests started during the warm-up are only counted
+	collector := newResultCollector(concurrentUsers)
 
 	// Start concurrent users
 	for i := 0; i < concurrentUsers; i++ {
 		wg.Add(1)
 		go func(userID int) {
 			defer wg.Done()
-			for time.Now().Before(endTime) {
-				// Generate random search parameters using valid routes
-				source, destination := getRandomRoute()
-				date := getRandomDate()
-				seats := rand.Intn(4) + 1
-				sortBy := []string{"cheapest", "fastest"}[rand.Intn(2)]
+			testName := fmt.Sprintf("Flight Search User %d", userID)
+			user, err := st.virtualUser(context.Background(), userID)
+			if err != nil {
+				collector.record(userID, failedResult(testName, err, 0))
+				return
+			}
 
+			for time.Now().Before(endTime) {
+				// Search as the virtual user: a new valid route, or its last one refined
+				req := user.nextSearch()
 				testStart := time.Now()
 
-				// Make search request
-				url := fmt.Sprintf("%s/api/flights/search?source=%s&destination=%s&date=%s&seats=%d&sort_by=%s",
-					flightServiceURL, source, destination, date, seats, sortBy)
+				ctx, body := withBodyCapture(context.Background())
+				response, err := user.search(ctx, req)
 
-				resp, err := st.client.Get(url)
+				// Validate response - should have at least one well-formed path
+				var result TestResult
 				if err != nil {
-					mu.Lock()
-					errorCount++
-					results = append(results, TestResult{
-						TestName: fmt.Sprintf("Flight Search User %d", userID),
-						Success:  false,
-						Error:    fmt.Sprintf("Request failed: %v", err),
-						Duration: time.Since(testStart),
-					})
-					mu.Unlock()
-					continue
-				}
-
-				// Validate response
-				expectedFields := map[string]interface{}{
-					"count": float64(0), // Should have at least one path (we'll check > 0)
+					result = failedResult(testName, err, time.Since(testStart))
+				} else {
+					user.rememberSearch(req, response)
+					result = schemaResult(testName, "search", searchResponseSchema, body, response, time.Since(testStart))
 				}
-				result := st.validateResponse(fmt.Sprintf("Flight Search User %d", userID), resp, http.StatusOK, expectedFields)
-				result.Duration = time.Since(testStart)
 
-				mu.Lock()
-				totalRequests++
-				if result.Success {
-					successCount++
+				if testStart.Before(steadyStart) {
+					collector.recordWarmUp()
 				} else {
-					errorCount++
+					collector.record(userID, result)
 				}
-				results = append(results, result)
-				mu.Unlock()
-
-				resp.Body.Close()
 
-				// Small delay between requests
-				time.Sleep(time.Duration(rand.Intn(1000)) * time.Millisecond)
+				// Pause while the user reads the results
+				st.think(actionSearch)
 			}
 		}(i)
 	}
 
 	wg.Wait()
+	result := collector.result()
 
 	log.Printf("Flight search test completed:")
-	log.Printf("  Total requests: %d", totalRequests)
-	log.Printf("  Successful: %d", successCount)
-	log.Printf("  Failed: %d", errorCount)
-	log.Printf("  Success rate: %.2f%%", float64(successCount)/float64(totalRequests)*100)
-
-	return ValidationResult{
-		TotalTests:  int(totalRequests),
-		PassedTests: int(successCount),
-		FailedTests: int(errorCount),
-		Results:     results,
-	}
+	log.Printf("  Warm-up requests (excluded): %d", result.WarmUpRequests)
+	log.Printf("  Total requests: %d", result.TotalTests)
+	log.Printf("  Successful: %d", result.PassedTests)
+	log.Printf("  Failed: %d", result.FailedTests)
+	log.Printf("  Success rate: %.2f%%", float64(result.PassedTests)/float64(result.TotalTests)*100)
+	result.Latency.log()
+
+	return result
 }
 
-func (st *StressTest) runBookingTest(concurrentUsers int, duration time.Duration) ValidationResult {
-	log.Printf("Starting booking stress test with %d concurrent users for %v", concurrentUsers, duration)
+func (st *StressTest) runBookingTest(concurrentUsers int, phases loadPhases) ValidationResult {
+	log.Printf("Starting booking stress test with %d concurrent users for %v", concurrentUsers, phases)
 
 	var wg sync.WaitGroup
 	startTime := time.Now()
-	endTime := startTime.Add(duration)
+	steadyStart, endTime := phases.schedule(startTime)
 
-	// Track results
-	var (
-		totalBookings int64
-		successCount  int64
-		errorCount    int64
-		results       []TestResult
-		mu            sync.Mutex
-	)
+	// Track results; bookings started during the warm-up are only counted
+	collector := newResultCollector(concurrentUsers)
 
 	// Start concurrent users
 	for i := 0; i < concurrentUsers; i++ {
 		wg.Add(1)
 		go func(userID int) {
 			defer wg.Done()
-			for time.Now().Before(endTime) {
-				// Create booking request
-				bookingReq := models.BookingRequest{
-					UserID:   userID + 1,
-					FlightID: []int{3, 12, 14}[rand.Intn(3)], // Use actual flight IDs from database
-					Seats:    rand.Intn(3) + 1,               // 1-3 seats
-					Date:     getRandomDate(),
-				}
+			testName := fmt.Sprintf("Booking User %d", userID)
+			user, err := st.virtualUser(context.Background(), userID)
+			if err != nil {
+				collector.record(userID, failedResult(testName, err, 0))
+				return
+			}
 
+			for time.Now().Before(endTime) {
+				// Pick a flight from the user's last search results, searching first when
+				// it has none left to book
+				bookingReq := user.nextBooking(context.Background())
 				testStart := time.Now()
 
-				jsonData, err := json.Marshal(bookingReq)
-				if err != nil {
-					mu.Lock()
-					errorCount++
-					results = append(results, TestResult{
-						TestName: fmt.Sprintf("Booking User %d", userID),
-						Success:  false,
-						Error:    fmt.Sprintf("Failed to marshal request: %v", err),
-						Duration: time.Since(testStart),
-					})
-					mu.Unlock()
-					continue
-				}
-
-				// Make booking request
-				url := fmt.Sprintf("%s/api/bookings", bookingServiceURL)
-				resp, err := st.client.Post(url, "application/json", bytes.NewBuffer(jsonData))
+				// Make booking request - both confirmed and business logic failures
+				// (like insufficient seats) are valid outcomes
+				var result TestResult
+				ctx, body := withBodyCapture(context.Background())
+				response, err := user.book(ctx, bookingReq)
 				if err != nil {
-					mu.Lock()
-					errorCount++
-					results = append(results, TestResult{
-						TestName: fmt.Sprintf("Booking User %d", userID),
-						Success:  false,
-						Error:    fmt.Sprintf("Request failed: %v", err),
-						Duration: time.Since(testStart),
-					})
-					mu.Unlock()
-					continue
-				}
-
-				// Custom validation for booking - accept both success (200) and business logic failures (400)
-				result := TestResult{
-					TestName: fmt.Sprintf("Booking User %d", userID),
-					Success:  false,
-					Duration: time.Since(testStart),
-				}
-
-				// Accept both HTTP 200 (success) and HTTP 400 (business logic failure like insufficient seats)
-				if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusBadRequest {
-					result.Success = true
-					result.StatusCode = resp.StatusCode
+					result = failedResult(testName, err, time.Since(testStart))
 				} else {
-					result.Error = fmt.Sprintf("Expected status 200 or 400, got %d", resp.StatusCode)
-					result.StatusCode = resp.StatusCode
+					user.rememberBooking(bookingReq, response)
+					result = schemaResult(testName, "booking", bookingResponseSchema, body, response, time.Since(testStart))
 				}
-				result.Duration = time.Since(testStart)
 
-				mu.Lock()
-				totalBookings++
-				if result.Success {
-					successCount++
+				if testStart.Before(steadyStart) {
+					collector.recordWarmUp()
 				} else {
-					errorCount++
+					collector.record(userID, result)
 				}
-				results = append(results, result)
-				mu.Unlock()
-
-				resp.Body.Close()
 
-				// Small delay between requests
-				time.Sleep(time.Duration(rand.Intn(2000)) * time.Millisecond)
+				// Pause before the user's next booking
+				st.think(actionBooking)
 			}
 		}(i)
 	}
 
 	wg.Wait()
+	result := collector.result()
 
 	log.Printf("Booking test completed:")
-	log.Printf("  Total bookings: %d", totalBookings)
-	log.Printf("  Successful: %d", successCount)
-	log.Printf("  Failed: %d", errorCount)
-	log.Printf("  Success rate: %.2f%%", float64(successCount)/float64(totalBookings)*100)
-
-	return ValidationResult{
-		TotalTests:  int(totalBookings),
-		PassedTests: int(successCount),
-		FailedTests: int(errorCount),
-		Results:     results,
-	}
+	log.Printf("  Warm-up bookings (excluded): %d", result.WarmUpRequests)
+	log.Printf("  Total bookings: %d", result.TotalTests)
+	log.Printf("  Successful: %d", result.PassedTests)
+	log.Printf("  Failed: %d", result.FailedTests)
+	log.Printf("  Success rate: %.2f%%", float64(result.PassedTests)/float64(result.TotalTests)*100)
+	result.Latency.log()
+
+	return result
 }
 
 func (st *StressTest) runPaymentFailureTest() TestResult {
 	log.Printf("Starting payment failure simulation test")
 
-	testStart := time.Now()
-
 	// Test payment failure scenarios
 	paymentReq := models.PaymentRequest{
 		BookingID:   1,
-		Amount:      1000.0,
+		Amount:      models.NewMoney(100000),
 		UserID:      1,
 		PaymentType: "credit_card",
 	}
 
-	jsonData, err := json.Marshal(paymentReq)
-	if err != nil {
-		return TestResult{
-			TestName: "Payment Failure Test",
-			Success:  false,
-			Error:    fmt.Sprintf("Failed to marshal request: %v", err),
-			Duration: time.Since(testStart),
-		}
-	}
-
-	// Test failure simulation
-	url := fmt.Sprintf("%s/api/payments/simulate/failure", paymentServiceURL)
-	resp, err := st.client.Post(url, "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return TestResult{
-			TestName: "Payment Failure Test",
-			Success:  false,
-			Error:    fmt.Sprintf("Request failed: %v", err),
-			Duration: time.Since(testStart),
-		}
-	}
-	defer resp.Body.Close()
-
 	// Validate response - should return failed status
-	expectedFields := map[string]interface{}{
-		"status": "failed",
-	}
-	result := st.validateResponse("Payment Failure Test", resp, http.StatusOK, expectedFields)
-	result.Duration = time.Since(testStart)
+	result := st.runSimulatedPayment("Payment Failure Test", client.SimulateFailure, &paymentReq, models.PaymentStatusFailed)
 
 	log.Printf("Payment failure test completed:")
 	log.Printf("  Success: %v", result.Success)
@@ -352,45 +265,16 @@ func (st *StressTest) runPaymentFailureTest() TestResult {
 func (st *StressTest) runPaymentTimeoutTest() TestResult {
 	log.Printf("Starting payment timeout simulation test")
 
-	testStart := time.Now()
-
 	// Test payment timeout scenarios
 	paymentReq := models.PaymentRequest{
 		BookingID:   2,
-		Amount:      1500.0,
+		Amount:      models.NewMoney(150000),
 		UserID:      2,
 		PaymentType: "debit_card",
 	}
 
-	jsonData, err := json.Marshal(paymentReq)
-	if err != nil {
-		return TestResult{
-			TestName: "Payment Timeout Test",
-			Success:  false,
-			Error:    fmt.Sprintf("Failed to marshal request: %v", err),
-			Duration: time.Since(testStart),
-		}
-	}
-
-	// Test timeout simulation
-	url := fmt.Sprintf("%s/api/payments/simulate/timeout", paymentServiceURL)
-	resp, err := st.client.Post(url, "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return TestResult{
-			TestName: "Payment Timeout Test",
-			Success:  false,
-			Error:    fmt.Sprintf("Request failed: %v", err),
-			Duration: time.Since(testStart),
-		}
-	}
-	defer resp.Body.Close()
-
 	// Validate response - should return timeout status
-	expectedFields := map[string]interface{}{
-		"status": "timeout",
-	}
-	result := st.validateResponse("Payment Timeout Test", resp, http.StatusRequestTimeout, expectedFields)
-	result.Duration = time.Since(testStart)
+	result := st.runSimulatedPayment("Payment Timeout Test", client.SimulateTimeout, &paymentReq, models.PaymentStatusTimeout)
 
 	log.Printf("Payment timeout test completed:")
 	log.Printf("  Success: %v", result.Success)
@@ -401,16 +285,28 @@ func (st *StressTest) runPaymentTimeoutTest() TestResult {
 	return result
 }
 
+// runSimulatedPayment forces a payment outcome and checks the response has the expected status
+func (st *StressTest) runSimulatedPayment(testName, outcome string, req *models.PaymentRequest, expectedStatus string) TestResult {
+	testStart := time.Now()
+
+	ctx, body := withBodyCapture(context.Background())
+	response, err := st.payments.Simulate(ctx, outcome, req)
+	if err != nil {
+		return failedResult(testName, err, time.Since(testStart))
+	}
+	return schemaResult(testName, "payment simulation", paymentResponseSchema(expectedStatus), body, response, time.Since(testStart))
+}
+
 func (st *StressTest) runConcurrentPaymentTest(concurrentUsers int) ValidationResult {
 	log.Printf("Starting concurrent payment test with %d users", concurrentUsers)
 
 	var wg sync.WaitGroup
 	var (
-		successCount int64
-		failureCount int64
-		timeoutCount int64
-		results      []TestResult
-		mu           sync.Mutex
+		successCount atomic.Int64
+		failureCount atomic.Int64
+		timeoutCount atomic.Int64
+		invalidCount atomic.Int64
+		collector    = newResultCollector(concurrentUsers)
 	)
 
 	for i := 0; i < concurrentUsers; i++ {
@@ -419,121 +315,42 @@ func (st *StressTest) runConcurrentPaymentTest(concurrentUsers int) ValidationRe
 			defer wg.Done()
 
 			testStart := time.Now()
+			testName := fmt.Sprintf("Concurrent Payment User %d", userID)
 
 			paymentReq := models.PaymentRequest{
 				BookingID:   userID + 1,
-				Amount:      float64(rand.Intn(5000) + 1000),
+				Amount:      models.NewMoney(int64(rand.Intn(5000)+1000) * 100),
 				UserID:      userID + 1,
 				PaymentType: "credit_card",
 			}
 
-			jsonData, err := json.Marshal(paymentReq)
-			if err != nil {
-				mu.Lock()
-				failureCount++
-				results = append(results, TestResult{
-					TestName: fmt.Sprintf("Concurrent Payment User %d", userID),
-					Success:  false,
-					Error:    fmt.Sprintf("Failed to marshal request: %v", err),
-					Duration: time.Since(testStart),
-				})
-				mu.Unlock()
-				return
-			}
-
 			// Create context with timeout
 			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 			defer cancel()
+			ctx, body := withBodyCapture(ctx)
 
-			// Make payment request
-			url := fmt.Sprintf("%s/api/payments/process", paymentServiceURL)
-			req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
-			if err != nil {
-				mu.Lock()
-				failureCount++
-				results = append(results, TestResult{
-					TestName: fmt.Sprintf("Concurrent Payment User %d", userID),
-					Success:  false,
-					Error:    fmt.Sprintf("Failed to create request: %v", err),
-					Duration: time.Since(testStart),
-				})
-				mu.Unlock()
-				return
-			}
-			req.Header.Set("Content-Type", "application/json")
-
-			resp, err := st.client.Do(req)
-			if err != nil {
-				mu.Lock()
-				timeoutCount++
-				results = append(results, TestResult{
-					TestName: fmt.Sprintf("Concurrent Payment User %d", userID),
-					Success:  false,
-					Error:    fmt.Sprintf("Request failed: %v", err),
-					Duration: time.Since(testStart),
-				})
-				mu.Unlock()
-				return
-			}
-			defer resp.Body.Close()
-
-			// Read response body once and decode
-			body, err := io.ReadAll(resp.Body)
+			// Make payment request - success, failure, and timeout are all valid outcomes
+			paymentResp, err := st.payments.ProcessPayment(ctx, &paymentReq)
 			if err != nil {
-				mu.Lock()
-				failureCount++
-				results = append(results, TestResult{
-					TestName: fmt.Sprintf("Concurrent Payment User %d", userID),
-					Success:  false,
-					Error:    fmt.Sprintf("Failed to read response body: %v", err),
-					Duration: time.Since(testStart),
-				})
-				mu.Unlock()
-				return
-			}
-
-			var paymentResp models.PaymentResponse
-			if err := json.Unmarshal(body, &paymentResp); err != nil {
-				mu.Lock()
-				failureCount++
-				results = append(results, TestResult{
-					TestName: fmt.Sprintf("Concurrent Payment User %d", userID),
-					Success:  false,
-					Error:    fmt.Sprintf("Failed to decode response: %v", err),
-					Duration: time.Since(testStart),
-				})
-				mu.Unlock()
+				timeoutCount.Add(1)
+				collector.recordTolerated(userID, failedResult(testName, err, time.Since(testStart)))
 				return
 			}
 
-			// Create a simple validation result - accept success (200), failure (400), and timeout (408)
-			result := TestResult{
-				TestName: fmt.Sprintf("Concurrent Payment User %d", userID),
-				Success:  false,
-				Duration: time.Since(testStart),
-			}
-
-			// Accept HTTP 200 (success), HTTP 400 (failure), and HTTP 408 (timeout) as valid responses
-			if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusRequestTimeout {
-				result.Success = true
-				result.StatusCode = resp.StatusCode
-			} else {
-				result.Error = fmt.Sprintf("Expected status 200, 400, or 408, got %d", resp.StatusCode)
-				result.StatusCode = resp.StatusCode
+			schema := paymentResponseSchema(models.PaymentStatusSuccess, models.PaymentStatusFailed, models.PaymentStatusTimeout)
+			result := schemaResult(testName, "payment", schema, body, paymentResp, time.Since(testStart))
+			if !result.Success {
+				invalidCount.Add(1)
 			}
-			result.Duration = time.Since(testStart)
-
-			mu.Lock()
 			switch paymentResp.Status {
 			case models.PaymentStatusSuccess:
-				successCount++
+				successCount.Add(1)
 			case models.PaymentStatusFailed:
-				failureCount++
+				failureCount.Add(1)
 			case models.PaymentStatusTimeout:
-				timeoutCount++
+				timeoutCount.Add(1)
 			}
-			results = append(results, result)
-			mu.Unlock()
+			collector.record(userID, result)
 
 			log.Printf("User %d: Payment %s - %s", userID, paymentResp.Status, paymentResp.Message)
 		}(i)
@@ -542,17 +359,15 @@ func (st *StressTest) runConcurrentPaymentTest(concurrentUsers int) ValidationRe
 	wg.Wait()
 
 	log.Printf("Concurrent payment test completed:")
-	log.Printf("  Successful: %d", successCount)
-	log.Printf("  Failed: %d", failureCount)
-	log.Printf("  Timeout: %d", timeoutCount)
-	log.Printf("  Total: %d", successCount+failureCount+timeoutCount)
-
-	return ValidationResult{
-		TotalTests:  concurrentUsers,
-		PassedTests: concurrentUsers, // All tests are successful since they all got valid responses
-		FailedTests: 0,               // No test failures - all API calls worked correctly
-		Results:     results,
-	}
+	log.Printf("  Successful: %d", successCount.Load())
+	log.Printf("  Failed: %d", failureCount.Load())
+	log.Printf("  Timeout: %d", timeoutCount.Load())
+	log.Printf("  Invalid responses: %d", invalidCount.Load())
+	log.Printf("  Total: %d", successCount.Load()+failureCount.Load()+timeoutCount.Load())
+
+	// Success, failure, and timeout are all valid outcomes; only responses that don't
+	// match the payment schema fail the test
+	return collector.result()
 }
 
 // Helper functions
@@ -579,72 +394,98 @@ func getRandomDate() string {
 }
 
 func main() {
+	warmUp := flag.Duration("warmup", 10*time.Second, "Warm-up per timed test; its requests prime caches and are left out of the statistics")
+	steadyState := flag.Duration("duration", 30*time.Second, "Steady-state window per timed test, measured after the warm-up")
+	scenarioPath := flag.String("scenario", "", "Scenario file with the credentials virtual users act as (see scripts/stress-scenario.example.json)")
+	flag.Parse()
+	phases := loadPhases{WarmUp: *warmUp, SteadyState: *steadyState}
+
+	scenario := &Scenario{}
+	if *scenarioPath != "" {
+		var err error
+		if scenario, err = loadScenario(*scenarioPath); err != nil {
+			log.Fatalf("Failed to load scenario: %v", err)
+		}
+	}
+
 	log.Println("Starting Flight Booking System Stress Tests with Validation...")
 
 	// Initialize random seed
 	rand.Seed(time.Now().UnixNano())
 
 	// Create stress test instance
-	st := NewStressTest()
+	st := NewStressTest(scenario)
 
 	// Wait for services to be ready
 	log.Println("Waiting for services to be ready...")
 	time.Sleep(5 * time.Second)
 
-	// Track overall results
-	var allResults []TestResult
+	// Track overall results; failures are the samples the tests kept
+	var failures []TestResult
 	totalTests := 0
 	totalPassed := 0
 	totalFailed := 0
 
 	// Run different stress tests
 	log.Println("=== Flight Search Stress Test ===")
-	searchResult := st.runFlightSearchTest(10, 30*time.Second)
-	allResults = append(allResults, searchResult.Results...)
+	searchResult := st.runFlightSearchTest(10, phases)
+	failures = append(failures, searchResult.Failures...)
 	totalTests += searchResult.TotalTests
 	totalPassed += searchResult.PassedTests
 	totalFailed += searchResult.FailedTests
 
 	log.Println("\n=== Booking Stress Test ===")
-	bookingResult := st.runBookingTest(5, 30*time.Second)
-	allResults = append(allResults, bookingResult.Results...)
+	bookingResult := st.runBookingTest(5, phases)
+	failures = append(failures, bookingResult.Failures...)
 	totalTests += bookingResult.TotalTests
 	totalPassed += bookingResult.PassedTests
 	totalFailed += bookingResult.FailedTests
 
+	log.Println("\n=== Live Booking Status Test ===")
+	liveResult := st.runLiveStatusTest(5, phases)
+	failures = append(failures, liveResult.Failures...)
+	totalTests += liveResult.TotalTests
+	totalPassed += liveResult.PassedTests
+	totalFailed += liveResult.FailedTests
+
 	log.Println("\n=== Payment Failure Test ===")
 	failureResult := st.runPaymentFailureTest()
-	allResults = append(allResults, failureResult)
 	totalTests++
 	if failureResult.Success {
 		totalPassed++
 	} else {
+		failures = append(failures, failureResult)
 		totalFailed++
 	}
 
 	log.Println("\n=== Payment Timeout Test ===")
 	timeoutResult := st.runPaymentTimeoutTest()
-	allResults = append(allResults, timeoutResult)
 	totalTests++
 	if timeoutResult.Success {
 		totalPassed++
 	} else {
+		failures = append(failures, timeoutResult)
 		totalFailed++
 	}
 
 	log.Println("\n=== Concurrent Payment Test ===")
 	paymentResult := st.runConcurrentPaymentTest(10)
-	allResults = append(allResults, paymentResult.Results...)
+	failures = append(failures, paymentResult.Failures...)
 	totalTests += paymentResult.TotalTests
 	totalPassed += paymentResult.PassedTests
 	totalFailed += paymentResult.FailedTests
 
+	log.Println("\n=== Cancellation Seat-Return Test ===")
+	cancellationResult := st.runCancellationTest(5, 4)
+	failures = append(failures, cancellationResult.Failures...)
+	totalTests += cancellationResult.TotalTests
+	totalPassed += cancellationResult.PassedTests
+	totalFailed += cancellationResult.FailedTests
+
 	// Print detailed results
-	log.Println("\n=== Detailed Test Results ===")
-	for _, result := range allResults {
-		if !result.Success {
-			log.Printf("❌ %s: %s (Duration: %v, Status: %d)", result.TestName, result.Error, result.Duration, result.StatusCode)
-		}
+	log.Println("\n=== Detailed Test Results (sampled failures) ===")
+	for _, result := range failures {
+		log.Printf("❌ %s: %s (Duration: %v, Status: %d)", result.TestName, result.Error, result.Duration, result.StatusCode)
 	}
 
 	// Print summary
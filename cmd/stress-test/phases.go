@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// loadPhases splits a timed test into a warm-up, whose requests fill caches and connection
+// pools and are left out of the statistics, followed by the steady-state window it reports on
+type loadPhases struct {
+	WarmUp      time.Duration
+	SteadyState time.Duration
+}
+
+// String describes the phases for logs
+func (p loadPhases) String() string {
+	return fmt.Sprintf("%v warm-up + %v steady state", p.WarmUp, p.SteadyState)
+}
+
+// schedule returns when the steady state starts and when the test ends, for a test
+// starting at start
+func (p loadPhases) schedule(start time.Time) (steadyStart, end time.Time) {
+	steadyStart = start.Add(p.WarmUp)
+	return steadyStart, steadyStart.Add(p.SteadyState)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Scenario is the stress tester's optional scenario file (-scenario)
+type Scenario struct {
+	Credentials Credentials `json:"credentials"`
+	// Pause after each action ("search", "booking") and how long live status connections
+	// are watched ("live"), defaulting to uniform 0-1s, 0-2s, and 1-5s
+	ThinkTimes map[string]ThinkTime `json:"think_times"`
+}
+
+// Credentials are the identities virtual users act as. Values may reference environment
+// variables as ${NAME}, so secrets stay out of the file.
+type Credentials struct {
+	// Admin credentials, used to provision keys
+	AdminUser  string `json:"admin_user"`
+	AdminToken string `json:"admin_token"`
+
+	// Keys assigned to virtual users round-robin: partner keys send searches through the
+	// partner API, agency keys book on agency credit
+	PartnerKeys []string `json:"partner_keys"`
+	AgencyKeys  []string `json:"agency_keys"`
+
+	// Create a partner or agency per virtual user when no keys are listed, and again when
+	// the service rejects a created key
+	ProvisionPartners bool `json:"provision_partners"`
+	ProvisionAgencies bool `json:"provision_agencies"`
+
+	// Limits of provisioned partners and agencies
+	PartnerRequestsPerMinute int     `json:"partner_requests_per_minute"`
+	PartnerDailyQuota        int     `json:"partner_daily_quota"`
+	AgencyCreditLimit        float64 `json:"agency_credit_limit"`
+}
+
+// loadScenario reads a scenario file, expanding environment variables and applying defaults
+func loadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(os.ExpandEnv(string(data)))))
+	decoder.DisallowUnknownFields()
+	var scenario Scenario
+	if err := decoder.Decode(&scenario); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario %s: %w", path, err)
+	}
+
+	for action, thinkTime := range scenario.ThinkTimes {
+		if _, ok := defaultThinkTimes[action]; !ok {
+			return nil, fmt.Errorf("scenario %s: unknown think time action %q", path, action)
+		}
+		if err := thinkTime.validate(); err != nil {
+			return nil, fmt.Errorf("scenario %s: think_times.%s: %w", path, action, err)
+		}
+	}
+
+	creds := &scenario.Credentials
+	if (creds.ProvisionPartners || creds.ProvisionAgencies) && creds.AdminToken == "" {
+		return nil, fmt.Errorf("scenario %s: provisioning keys requires credentials.admin_token", path)
+	}
+	if creds.PartnerRequestsPerMinute <= 0 {
+		creds.PartnerRequestsPerMinute = 600
+	}
+	if creds.PartnerDailyQuota <= 0 {
+		creds.PartnerDailyQuota = 1000000
+	}
+	if creds.AgencyCreditLimit <= 0 {
+		creds.AgencyCreditLimit = 10000000
+	}
+	return &scenario, nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+
+	"cred_flights_booking/pkg/models"
+)
+
+// flightSchema is one flight of a search path
+var flightSchema = &Schema{
+	Type:     jsonObject,
+	Required: []string{"id", "flight_number", "source", "destination", "departure_time", "arrival_time", "total_seats", "booked_seats", "price"},
+	Properties: map[string]*Schema{
+		"id":             {Type: jsonInteger, Minimum: atLeast(1)},
+		"flight_number":  {Type: jsonString},
+		"source":         {Type: jsonString},
+		"destination":    {Type: jsonString},
+		"departure_time": {Type: jsonString, Format: formatDateTime},
+		"arrival_time":   {Type: jsonString, Format: formatDateTime},
+		"total_seats":    {Type: jsonInteger, Minimum: atLeast(0)},
+		"booked_seats":   {Type: jsonInteger, Minimum: atLeast(0)},
+		"price":          {Type: jsonNumber, Minimum: atLeast(0)},
+		"status":         {Type: jsonString},
+	},
+}
+
+// flightPathSchema is one path of a search response: its flights connect, and its stops
+// match them
+var flightPathSchema = &Schema{
+	Type:     jsonObject,
+	Required: []string{"flights", "total_price", "total_time_minutes", "stops"},
+	Properties: map[string]*Schema{
+		"flights":            {Type: jsonArray, MinItems: 1, Items: flightSchema},
+		"total_price":        {Type: jsonNumber, Minimum: atLeast(0)},
+		"total_time_minutes": {Type: jsonInteger, Minimum: atLeast(0)},
+		"stops":              {Type: jsonInteger, Minimum: atLeast(0)},
+		"score":              {Type: jsonNumber},
+	},
+	Check: func(path map[string]interface{}) (string, string) {
+		flights, _ := path["flights"].([]interface{})
+		if stops, ok := jsonInt(path["stops"]); ok && len(flights) > 0 && stops != len(flights)-1 {
+			return fmt.Sprintf("stops = %d (flights - 1)", len(flights)-1), fmt.Sprintf("stops = %d", stops)
+		}
+		for i := 1; i < len(flights); i++ {
+			prev, _ := flights[i-1].(map[string]interface{})
+			next, _ := flights[i].(map[string]interface{})
+			if prev["destination"] != next["source"] {
+				return fmt.Sprintf("flights[%d].source = %v (previous destination)", i, prev["destination"]),
+					fmt.Sprintf("flights[%d].source = %v", i, next["source"])
+			}
+		}
+		return "", ""
+	},
+}
+
+// searchResponseSchema is a search that found at least one path, with a count matching them
+var searchResponseSchema = &Schema{
+	Type:     jsonObject,
+	Required: []string{"paths", "count"},
+	Properties: map[string]*Schema{
+		"paths":       {Type: jsonArray, MinItems: 1, Items: flightPathSchema},
+		"count":       {Type: jsonInteger, Minimum: atLeast(1)},
+		"experiments": {Type: jsonObject},
+	},
+	Check: func(response map[string]interface{}) (string, string) {
+		paths, _ := response["paths"].([]interface{})
+		if count, ok := jsonInt(response["count"]); ok && count != len(paths) {
+			return fmt.Sprintf("count = %d (paths returned)", len(paths)), fmt.Sprintf("count = %d", count)
+		}
+		return "", ""
+	},
+}
+
+// bookingResponseSchema is a booking outcome: confirmed bookings carry their ID and payment
+var bookingResponseSchema = &Schema{
+	Type:     jsonObject,
+	Required: []string{"booking_id", "status", "total_amount"},
+	Properties: map[string]*Schema{
+		"booking_id":   {Type: jsonInteger, Minimum: atLeast(0)},
+		"status":       {Type: jsonString, Enum: []string{models.BookingStatusConfirmed, models.BookingStatusFailed, models.BookingStatusPending, models.BookingStatusQueued}},
+		"total_amount": {Type: jsonNumber, Minimum: atLeast(0)},
+		"payment_id":   {Type: jsonString},
+		"message":      {Type: jsonString},
+		"hold_id":      {Type: jsonString},
+		"warnings":     {Type: jsonArray, Items: &Schema{Type: jsonString}},
+	},
+	Check: func(response map[string]interface{}) (string, string) {
+		if response["status"] != models.BookingStatusConfirmed {
+			return "", ""
+		}
+		if id, _ := jsonInt(response["booking_id"]); id <= 0 {
+			return "confirmed booking with booking_id > 0", fmt.Sprintf("booking_id = %v", response["booking_id"])
+		}
+		if paymentID, _ := response["payment_id"].(string); paymentID == "" {
+			return "confirmed booking with a payment_id", "no payment_id"
+		}
+		return "", ""
+	},
+}
+
+// paymentResponseSchema is a payment whose status is one of statuses; successful payments
+// carry their ID
+func paymentResponseSchema(statuses ...string) *Schema {
+	return &Schema{
+		Type:     jsonObject,
+		Required: []string{"payment_id", "status", "booking_id", "amount", "processed_at"},
+		Properties: map[string]*Schema{
+			"payment_id":   {Type: jsonString},
+			"status":       {Type: jsonString, Enum: statuses},
+			"message":      {Type: jsonString},
+			"booking_id":   {Type: jsonInteger},
+			"amount":       {Type: jsonNumber, Minimum: atLeast(0)},
+			"processed_at": {Type: jsonString, Format: formatDateTime},
+			"capture_by":   {Type: jsonString, Format: formatDateTime},
+		},
+		Check: func(response map[string]interface{}) (string, string) {
+			if paymentID, _ := response["payment_id"].(string); response["status"] == models.PaymentStatusSuccess && paymentID == "" {
+				return "successful payment with a payment_id", "empty payment_id"
+			}
+			return "", ""
+		},
+	}
+}
+
+// liveUpdateSchema is a message pushed on the booking status WebSocket
+var liveUpdateSchema = &Schema{
+	Type:     jsonObject,
+	Required: []string{"type"},
+	Properties: map[string]*Schema{
+		"type":        {Type: jsonString, Enum: []string{models.LiveUpdateSnapshot, models.LiveUpdateStatus, models.LiveUpdateUpdated, models.LiveUpdateError}},
+		"booking_id":  {Type: jsonInteger},
+		"status":      {Type: jsonString},
+		"from":        {Type: jsonString},
+		"seats":       {Type: jsonInteger, Minimum: atLeast(0)},
+		"reason":      {Type: jsonString},
+		"occurred_at": {Type: jsonString, Format: formatDateTime},
+		"error":       {Type: jsonString},
+	},
+	Check: func(update map[string]interface{}) (string, string) {
+		if update["type"] == models.LiveUpdateSnapshot {
+			if status, _ := update["status"].(string); status == "" {
+				return "snapshot with a status", "no status"
+			}
+		}
+		return "", ""
+	},
+}
+
+// jsonInt returns a decoded JSON integer
+func jsonInt(value interface{}) (int, bool) {
+	n, ok := value.(interface{ Int64() (int64, error) })
+	if !ok {
+		return 0, false
+	}
+	i, err := n.Int64()
+	return int(i), err == nil
+}
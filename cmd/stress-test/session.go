@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"cred_flights_booking/pkg/models"
+)
+
+// Think-time distributions
+const (
+	thinkUniform     = "uniform"
+	thinkConstant    = "constant"
+	thinkExponential = "exponential"
+	thinkLogNormal   = "lognormal"
+)
+
+// Virtual user actions with their own think times
+const (
+	actionSearch  = "search"
+	actionBooking = "booking"
+	actionLive    = "live" // How long a live status connection is watched
+)
+
+// defaultThinkTimes are the pauses after each action when the scenario sets none
+var defaultThinkTimes = map[string]ThinkTime{
+	actionSearch:  {Distribution: thinkUniform, Max: jsonDuration(time.Second)},
+	actionBooking: {Distribution: thinkUniform, Max: jsonDuration(2 * time.Second)},
+	actionLive:    {Distribution: thinkUniform, Min: jsonDuration(time.Second), Max: jsonDuration(5 * time.Second)},
+}
+
+// refineSearchChance is how often a user who searched before searches its last route again,
+// e.g. with another sort, as users comparing results do
+const refineSearchChance = 0.3
+
+// maxRememberedBookings bounds the bookings a session keeps
+const maxRememberedBookings = 20
+
+// jsonDuration is a time.Duration written as a string in JSON, e.g. "1.5s"
+type jsonDuration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *jsonDuration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"1.5s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = jsonDuration(parsed)
+	return nil
+}
+
+// ThinkTime is the distribution of a virtual user's pause after an action. Min and Max
+// bound every distribution (Max only when set).
+type ThinkTime struct {
+	Distribution string       `json:"distribution"` // uniform (default), constant, exponential, or lognormal
+	Mean         jsonDuration `json:"mean"`         // constant, exponential, and lognormal
+	Min          jsonDuration `json:"min"`
+	Max          jsonDuration `json:"max"`   // Upper bound of uniform
+	Sigma        float64      `json:"sigma"` // Spread of lognormal (default 0.5)
+}
+
+// validate checks the distribution and its parameters
+func (t ThinkTime) validate() error {
+	switch t.Distribution {
+	case "", thinkUniform:
+		if t.Max < t.Min {
+			return fmt.Errorf("uniform think time needs max >= min")
+		}
+	case thinkConstant, thinkExponential, thinkLogNormal:
+		if t.Mean <= 0 {
+			return fmt.Errorf("%s think time needs a positive mean", t.Distribution)
+		}
+	default:
+		return fmt.Errorf("unknown think time distribution %q", t.Distribution)
+	}
+	if t.Min < 0 || (t.Max > 0 && t.Max < t.Min) {
+		return fmt.Errorf("think time bounds must satisfy 0 <= min <= max")
+	}
+	return nil
+}
+
+// sample draws one pause
+func (t ThinkTime) sample() time.Duration {
+	var d float64
+	switch t.Distribution {
+	case thinkConstant:
+		d = float64(t.Mean)
+	case thinkExponential:
+		d = rand.ExpFloat64() * float64(t.Mean)
+	case thinkLogNormal:
+		sigma := t.Sigma
+		if sigma <= 0 {
+			sigma = 0.5
+		}
+		// Keeps the mean at Mean
+		mu := math.Log(float64(t.Mean)) - sigma*sigma/2
+		d = math.Exp(mu + sigma*rand.NormFloat64())
+	default:
+		d = float64(t.Min) + rand.Float64()*float64(t.Max-t.Min)
+	}
+
+	pause := time.Duration(d)
+	if pause < time.Duration(t.Min) {
+		pause = time.Duration(t.Min)
+	}
+	if t.Max > 0 && pause > time.Duration(t.Max) {
+		pause = time.Duration(t.Max)
+	}
+	return pause
+}
+
+// think pauses a virtual user after an action
+func (st *StressTest) think(action string) {
+	time.Sleep(st.thinkTime(action))
+}
+
+// thinkTime draws a virtual user's pause after an action
+func (st *StressTest) thinkTime(action string) time.Duration {
+	thinkTime, ok := st.thinkTimes[action]
+	if !ok {
+		thinkTime = defaultThinkTimes[action]
+	}
+	return thinkTime.sample()
+}
+
+// session is what a virtual user remembers between requests
+type session struct {
+	lastSearch    *models.SearchRequest
+	lastResults   *models.SearchResponse
+	bookedFlights map[int]bool
+	bookings      []int // Most recent confirmed booking IDs
+}
+
+// nextSearch returns the user's next search: its last route again with another sort now
+// and then, otherwise a new route
+func (u *virtualUser) nextSearch() *models.SearchRequest {
+	u.mu.Lock()
+	last := u.session.lastSearch
+	u.mu.Unlock()
+
+	sortBy := []string{"cheapest", "fastest"}[rand.Intn(2)]
+	if last != nil && rand.Float64() < refineSearchChance {
+		refined := *last
+		refined.SortBy = sortBy
+		return &refined
+	}
+
+	source, destination := getRandomRoute()
+	return &models.SearchRequest{
+		Source:      source,
+		Destination: destination,
+		Date:        getRandomDate(),
+		Seats:       rand.Intn(4) + 1,
+		SortBy:      sortBy,
+	}
+}
+
+// rememberSearch keeps a search and its results in the session
+func (u *virtualUser) rememberSearch(req *models.SearchRequest, response *models.SearchResponse) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.session.lastSearch = req
+	u.session.lastResults = response
+}
+
+// rememberBooking keeps a confirmed booking in the session
+func (u *virtualUser) rememberBooking(req *models.BookingRequest, response *models.BookingResponse) {
+	if response.Status != models.BookingStatusConfirmed {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.session.bookedFlights == nil {
+		u.session.bookedFlights = make(map[int]bool)
+	}
+	u.session.bookedFlights[req.FlightID] = true
+	u.session.bookings = append(u.session.bookings, response.BookingID)
+	if len(u.session.bookings) > maxRememberedBookings {
+		u.session.bookings = u.session.bookings[1:]
+	}
+}
+
+// recentBookings returns up to n of the user's most recent confirmed bookings
+func (u *virtualUser) recentBookings(n int) []int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	bookings := u.session.bookings
+	if len(bookings) > n {
+		bookings = bookings[len(bookings)-n:]
+	}
+	return append([]int(nil), bookings...)
+}
+
+// nextBooking returns the user's next booking: a flight from its last search results it
+// hasn't booked yet, preferring direct ones, searching first when it has none. Falls back
+// to a seeded flight when searches find nothing.
+func (u *virtualUser) nextBooking(ctx context.Context) *models.BookingRequest {
+	if req := u.pickFromResults(); req != nil {
+		return req
+	}
+
+	search := u.nextSearch()
+	if response, err := u.search(ctx, search); err == nil {
+		u.rememberSearch(search, response)
+		if req := u.pickFromResults(); req != nil {
+			return req
+		}
+	}
+
+	return &models.BookingRequest{
+		FlightID: []int{3, 12, 14}[rand.Intn(3)], // Use actual flight IDs from database
+		Seats:    rand.Intn(3) + 1,               // 1-3 seats
+		Date:     getRandomDate(),
+	}
+}
+
+// pickFromResults books the first flight of a path from the last search results, or
+// returns nil when no path has an unbooked flight
+func (u *virtualUser) pickFromResults() *models.BookingRequest {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.session.lastResults == nil {
+		return nil
+	}
+
+	var direct, connecting []int
+	for _, path := range u.session.lastResults.Paths {
+		if len(path.Flights) == 0 || u.session.bookedFlights[path.Flights[0].ID] {
+			continue
+		}
+		if path.Stops == 0 {
+			direct = append(direct, path.Flights[0].ID)
+		} else {
+			connecting = append(connecting, path.Flights[0].ID)
+		}
+	}
+	candidates := direct
+	if len(candidates) == 0 {
+		candidates = connecting
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	return &models.BookingRequest{
+		FlightID: candidates[rand.Intn(len(candidates))],
+		Seats:    u.session.lastSearch.Seats,
+		Date:     u.session.lastSearch.Date,
+	}
+}
@@ -3,8 +3,10 @@ module cred_flights_booking
 go 1.22
 
 require (
+	github.com/andybalholm/brotli v1.1.0
 	github.com/go-redis/redis/v8 v8.11.5
 	github.com/google/uuid v1.6.0
+	github.com/klauspost/compress v1.17.9
 	github.com/lib/pq v1.10.9
 	golang.org/x/sync v0.6.0
 )
@@ -0,0 +1,254 @@
+package auditlog
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/pkg/models"
+)
+
+// genesisHash is the previous hash of the first entry
+var genesisHash = strings.Repeat("0", sha256.Size*2)
+
+// maxEntryBytes bounds one line of the log when it is read back
+const maxEntryBytes = 1 << 20
+
+// Config controls the audit log
+type Config struct {
+	Path string // Append-only file of hash-chained entries; empty disables the log
+	Key  string // Optional hex AES-256 key; entry data is encrypted with it when set
+}
+
+// LoadConfig loads the payment audit log settings from the environment
+func LoadConfig() Config {
+	return Config{
+		Path: config.GetEnv("PAYMENT_AUDIT_LOG", "payment_audit.log"),
+		Key:  config.GetEnv("PAYMENT_AUDIT_KEY", ""),
+	}
+}
+
+// computeHash returns the hex SHA-256 of an entry's fields chained to the previous hash
+func computeHash(entry *models.AuditLogEntry) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%d|%s|%s|", entry.PrevHash, entry.Seq, entry.Time.UTC().Format(time.RFC3339Nano), entry.Event)
+	h.Write(entry.Data)
+	h.Write([]byte("|"))
+	h.Write([]byte(entry.Sealed))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Log appends hash-chained entries to a file kept apart from the application logs
+type Log struct {
+	aead cipher.AEAD
+
+	mu   sync.Mutex
+	file *os.File
+	seq  int64
+	head string
+}
+
+// Open opens or creates the log and resumes its chain. It returns a nil log, whose methods
+// do nothing, when no path is configured.
+func Open(cfg Config) (*Log, error) {
+	if cfg.Path == "" {
+		return nil, nil
+	}
+
+	l := &Log{head: genesisHash}
+	if cfg.Key != "" {
+		key, err := hex.DecodeString(cfg.Key)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("audit log key must be 64 hex characters")
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create audit log cipher: %w", err)
+		}
+		if l.aead, err = cipher.NewGCM(block); err != nil {
+			return nil, fmt.Errorf("failed to create audit log cipher: %w", err)
+		}
+	}
+
+	if dir := filepath.Dir(cfg.Path); dir != "." {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+		}
+	}
+	file, err := os.OpenFile(cfg.Path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	l.file = file
+
+	// New entries chain from the last one even if earlier ones were tampered with;
+	// verification keeps reporting the break
+	report, err := l.scan(nil)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	l.seq, l.head = report.Entries, report.Head
+	return l, nil
+}
+
+// Append records an event with its details, syncing it to disk before returning
+func (l *Log) Append(event string, data interface{}) error {
+	if l == nil {
+		return nil
+	}
+
+	details, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := &models.AuditLogEntry{
+		Seq:      l.seq + 1,
+		Time:     time.Now().UTC(),
+		Event:    event,
+		PrevHash: l.head,
+	}
+	if l.aead != nil {
+		nonce := make([]byte, l.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return fmt.Errorf("failed to generate audit entry nonce: %w", err)
+		}
+		entry.Sealed = base64.StdEncoding.EncodeToString(l.aead.Seal(nonce, nonce, details, []byte(strconv.FormatInt(entry.Seq, 10))))
+	} else {
+		entry.Data = details
+	}
+	entry.Hash = computeHash(entry)
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync audit log: %w", err)
+	}
+
+	l.seq, l.head = entry.Seq, entry.Hash
+	return nil
+}
+
+// Verify re-reads the whole log and checks every entry's hash and link to the previous one
+func (l *Log) Verify() (*models.AuditLogReport, error) {
+	if l == nil {
+		return &models.AuditLogReport{Head: genesisHash, Valid: true}, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.scan(nil)
+}
+
+// Entries returns up to limit entries from seq on, with sealed details decrypted
+func (l *Log) Entries(from int64, limit int) ([]models.AuditLogEntry, error) {
+	if l == nil {
+		return []models.AuditLogEntry{}, nil
+	}
+
+	entries := []models.AuditLogEntry{}
+	l.mu.Lock()
+	_, err := l.scan(func(entry *models.AuditLogEntry) bool {
+		if entry.Seq >= from {
+			entries = append(entries, *entry)
+		}
+		return len(entries) < limit
+	})
+	l.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range entries {
+		if entries[i].Sealed == "" || l.aead == nil {
+			continue
+		}
+		details, err := l.open(&entries[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt audit entry %d: %w", entries[i].Seq, err)
+		}
+		entries[i].Data = details
+	}
+	return entries, nil
+}
+
+// open decrypts an entry's sealed details
+func (l *Log) open(entry *models.AuditLogEntry) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(entry.Sealed)
+	if err != nil || len(sealed) < l.aead.NonceSize() {
+		return nil, fmt.Errorf("malformed sealed data")
+	}
+	nonce, ciphertext := sealed[:l.aead.NonceSize()], sealed[l.aead.NonceSize():]
+	return l.aead.Open(nil, nonce, ciphertext, []byte(strconv.FormatInt(entry.Seq, 10)))
+}
+
+// scan reads the log from the start, verifying the chain and passing each entry to visit
+// until it returns false. The caller holds mu, except while opening.
+func (l *Log) scan(visit func(entry *models.AuditLogEntry) bool) (*models.AuditLogReport, error) {
+	if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	report := &models.AuditLogReport{Head: genesisHash, Valid: true}
+	scanner := bufio.NewScanner(l.file)
+	scanner.Buffer(make([]byte, 64*1024), maxEntryBytes)
+	for scanner.Scan() {
+		var entry models.AuditLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			fail(report, report.Entries+1, "unreadable entry")
+			report.Entries++
+			continue
+		}
+
+		switch {
+		case entry.Seq != report.Entries+1:
+			fail(report, report.Entries+1, fmt.Sprintf("expected sequence %d, found %d", report.Entries+1, entry.Seq))
+		case entry.PrevHash != report.Head:
+			fail(report, entry.Seq, "previous hash does not match the preceding entry")
+		case computeHash(&entry) != entry.Hash:
+			fail(report, entry.Seq, "hash does not match the entry's contents")
+		}
+		report.Entries, report.Head = entry.Seq, entry.Hash
+
+		if visit != nil && !visit(&entry) {
+			return report, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return report, nil
+}
+
+// fail records the first break in the chain
+func fail(report *models.AuditLogReport, seq int64, reason string) {
+	if !report.Valid {
+		return
+	}
+	report.Valid = false
+	report.BrokenAt = seq
+	report.Error = reason
+}
@@ -0,0 +1,175 @@
+package cdn
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cred_flights_booking/internal/config"
+)
+
+// KeyAllSearches tags every cacheable search response, for purging them all at once
+const KeyAllSearches = "search"
+
+// maxKeysPerPurge is the most surrogate keys sent in one purge request
+const maxKeysPerPurge = 256
+
+// Config controls the caching headers on search responses and how a CDN or reverse proxy
+// is told to purge them
+type Config struct {
+	MaxAge               time.Duration // Shared-cache lifetime (s-maxage) of a search response; 0 disables caching
+	StaleWhileRevalidate time.Duration // How long a shared cache may serve a stale response while refetching it
+	PurgeURL             string        // Endpoint purge requests are POSTed to; empty disables purging
+	PurgeHeader          string        // Header carrying the space-separated keys to purge
+	PurgeToken           string        // Optional bearer token sent with purge requests
+	PurgeInterval        time.Duration // How long keys are collected before they are purged together
+}
+
+// LoadConfig loads the CDN settings from the environment
+func LoadConfig() Config {
+	return Config{
+		MaxAge:               config.GetDuration("SEARCH_CDN_MAX_AGE", 30*time.Second),
+		StaleWhileRevalidate: config.GetDuration("SEARCH_CDN_STALE_WHILE_REVALIDATE", 30*time.Second),
+		PurgeURL:             config.GetEnv("CDN_PURGE_URL", ""),
+		PurgeHeader:          config.GetEnv("CDN_PURGE_HEADER", "Surrogate-Key"),
+		PurgeToken:           config.GetEnv("CDN_PURGE_TOKEN", ""),
+		PurgeInterval:        config.GetDuration("CDN_PURGE_INTERVAL", time.Second),
+	}
+}
+
+// RouteKey returns the surrogate key of searches for a route and date
+func RouteKey(source, destination, date string) string {
+	return fmt.Sprintf("route-%s-%s-%s", source, destination, date)
+}
+
+// FlightKey returns the surrogate key of searches that include a flight on a date
+func FlightKey(flightID int, date string) string {
+	return fmt.Sprintf("flight-%d-%s", flightID, date)
+}
+
+// SetCacheHeaders marks a response as cacheable by shared caches only, tagged with
+// surrogate keys; browsers always revalidate. Personalized responses are marked private.
+func SetCacheHeaders(header http.Header, cfg Config, personalized bool, keys []string) {
+	if cfg.MaxAge <= 0 || personalized {
+		header.Set("Cache-Control", "private, no-store")
+		return
+	}
+
+	header.Set("Cache-Control", fmt.Sprintf("public, max-age=0, s-maxage=%d, stale-while-revalidate=%d",
+		int(cfg.MaxAge.Seconds()), int(cfg.StaleWhileRevalidate.Seconds())))
+	header.Add("Vary", "Accept")
+	header.Set("Surrogate-Key", strings.Join(append([]string{KeyAllSearches}, keys...), " "))
+}
+
+// Purger collects surrogate keys whose responses are out of date and purges them from
+// the CDN in batches, so a burst of inventory changes costs one request per interval
+type Purger struct {
+	cfg    Config
+	client *http.Client
+
+	mu      sync.Mutex
+	pending map[string]struct{}
+}
+
+// NewPurger creates a purger; without a purge URL, purges are dropped
+func NewPurger(cfg Config, client *http.Client) *Purger {
+	return &Purger{
+		cfg:     cfg,
+		client:  client,
+		pending: make(map[string]struct{}),
+	}
+}
+
+// Enabled reports whether purges are sent
+func (p *Purger) Enabled() bool {
+	return p.cfg.PurgeURL != "" && p.cfg.MaxAge > 0
+}
+
+// Purge queues keys for the next purge request
+func (p *Purger) Purge(keys ...string) {
+	if !p.Enabled() {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, key := range keys {
+		p.pending[key] = struct{}{}
+	}
+}
+
+// Pending returns how many keys are waiting to be purged
+func (p *Purger) Pending() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.pending)
+}
+
+// Start sends queued purges every PurgeInterval until ctx is cancelled
+func (p *Purger) Start(ctx context.Context) {
+	if !p.Enabled() || p.cfg.PurgeInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(p.cfg.PurgeInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.flush(ctx)
+			}
+		}
+	}()
+}
+
+// flush sends the queued keys, putting back any whose request failed for the next run
+func (p *Purger) flush(ctx context.Context) {
+	p.mu.Lock()
+	keys := make([]string, 0, len(p.pending))
+	for key := range p.pending {
+		keys = append(keys, key)
+	}
+	p.pending = make(map[string]struct{})
+	p.mu.Unlock()
+
+	sort.Strings(keys)
+	for start := 0; start < len(keys); start += maxKeysPerPurge {
+		batch := keys[start:min(start+maxKeysPerPurge, len(keys))]
+		if err := p.send(ctx, batch); err != nil {
+			log.Printf("Failed to purge %d CDN keys: %v", len(batch), err)
+			p.Purge(batch...)
+		}
+	}
+}
+
+// send issues one purge request
+func (p *Purger) send(ctx context.Context, keys []string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.PurgeURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create purge request: %w", err)
+	}
+	req.Header.Set(p.cfg.PurgeHeader, strings.Join(keys, " "))
+	if p.cfg.PurgeToken != "" {
+		req.Header.Set("Authorization", "Bearer "+p.cfg.PurgeToken)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send purge request: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("purge request returned status %d", resp.StatusCode)
+	}
+	return nil
+}
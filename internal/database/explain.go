@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cred_flights_booking/internal/config"
+)
+
+// ExplainConfig controls automatic EXPLAIN ANALYZE capture of slow queries
+type ExplainConfig struct {
+	Threshold  time.Duration // Queries at least this slow are candidates
+	SampleRate float64       // Share of slow queries explained, 0-1; 0 disables capture
+	Interval   time.Duration // Least time between captures of the same query
+	Timeout    time.Duration // Longest an EXPLAIN ANALYZE may run
+	Keep       int           // Newest plans kept per query
+}
+
+// LoadExplainConfig loads the EXPLAIN sampling settings from the environment. The threshold
+// defaults to the slow query logging threshold.
+func LoadExplainConfig(slowQueryThreshold time.Duration) ExplainConfig {
+	return ExplainConfig{
+		Threshold:  config.GetDuration("QUERY_EXPLAIN_THRESHOLD", slowQueryThreshold),
+		SampleRate: config.GetFloat("QUERY_EXPLAIN_SAMPLE_RATE", 0.1),
+		Interval:   config.GetDuration("QUERY_EXPLAIN_INTERVAL", 10*time.Minute),
+		Timeout:    config.GetDuration("QUERY_EXPLAIN_TIMEOUT", 30*time.Second),
+		Keep:       max(config.GetInt("QUERY_EXPLAIN_KEEP", 20), 1),
+	}
+}
+
+// explainSampler re-runs a sample of slow read queries under EXPLAIN ANALYZE and stores their
+// plans in query_explain_samples, one capture at a time
+type explainSampler struct {
+	cfg ExplainConfig
+	db  *sql.DB // Set once the pool is open
+
+	running atomic.Bool
+
+	mu   sync.Mutex
+	last map[string]time.Time // Last capture per query label
+}
+
+// newExplainSampler creates a sampler; captures start once its database is set
+func newExplainSampler(cfg ExplainConfig) *explainSampler {
+	return &explainSampler{
+		cfg:  cfg,
+		last: make(map[string]time.Time),
+	}
+}
+
+// explainable reports whether a statement can be safely re-run: a read starting with SELECT
+// or WITH. The capture also runs in a read-only transaction, so a WITH that writes fails.
+func explainable(label, query string) bool {
+	if label == "explain" {
+		return false
+	}
+	statement := strings.ToLower(strings.TrimSpace(query[len(leadingComments.FindString(query)):]))
+	return strings.HasPrefix(statement, "select") || strings.HasPrefix(statement, "with")
+}
+
+// offer captures the plan of a slow query in the background when it is sampled, no capture
+// is running, and the query was not captured within the interval
+func (s *explainSampler) offer(label, query string, args []driver.NamedValue, elapsed time.Duration) {
+	if s == nil || s.db == nil || s.cfg.SampleRate <= 0 || s.cfg.Threshold <= 0 || elapsed < s.cfg.Threshold {
+		return
+	}
+	if !explainable(label, query) || rand.Float64() >= s.cfg.SampleRate {
+		return
+	}
+	if !s.running.CompareAndSwap(false, true) {
+		return
+	}
+
+	s.mu.Lock()
+	if last, ok := s.last[label]; ok && time.Since(last) < s.cfg.Interval {
+		s.mu.Unlock()
+		s.running.Store(false)
+		return
+	}
+	s.last[label] = time.Now()
+	s.mu.Unlock()
+
+	// The driver may reuse the argument slice once the statement returns
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+
+	go func() {
+		defer s.running.Store(false)
+		if err := s.capture(label, query, values, elapsed); err != nil {
+			log.Printf("Failed to capture EXPLAIN of %s query: %v", label, err)
+		}
+	}()
+}
+
+// capture runs EXPLAIN ANALYZE of a query in a read-only transaction that is rolled back,
+// then stores the plan and prunes the query's older plans
+func (s *explainSampler) capture(label, query string, args []interface{}, elapsed time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	var plan []byte
+	err = tx.QueryRowContext(ctx, "EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) "+query, args...).Scan(&plan)
+	tx.Rollback()
+	if err != nil {
+		return err
+	}
+
+	statement := strings.Join(strings.Fields(query), " ")
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO query_explain_samples (query_name, query, duration_ms, plan)
+		VALUES ($1, $2, $3, $4)
+	`, label, statement, elapsed.Milliseconds(), plan)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		DELETE FROM query_explain_samples
+		WHERE query_name = $1 AND id NOT IN (
+			SELECT id FROM query_explain_samples WHERE query_name = $1 ORDER BY id DESC LIMIT $2
+		)
+	`, label, s.cfg.Keep)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Captured EXPLAIN ANALYZE of %s query (%dms)", label, elapsed.Milliseconds())
+	return nil
+}
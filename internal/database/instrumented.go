@@ -0,0 +1,185 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"sync"
+	"time"
+)
+
+// maxCachedLabels bounds the statement-to-label cache; statements built with varying text
+// beyond it are labelled on every run
+const maxCachedLabels = 1000
+
+// labelCache remembers the metrics label of each statement text
+var labelCache = struct {
+	sync.Mutex
+	labels map[string]string
+}{labels: make(map[string]string)}
+
+// cachedQueryLabel returns QueryLabel(query), computing it once per statement text
+func cachedQueryLabel(query string) string {
+	labelCache.Lock()
+	label, ok := labelCache.labels[query]
+	labelCache.Unlock()
+	if ok {
+		return label
+	}
+
+	label = QueryLabel(query)
+	labelCache.Lock()
+	if len(labelCache.labels) < maxCachedLabels {
+		labelCache.labels[query] = label
+	}
+	labelCache.Unlock()
+	return label
+}
+
+// instrumentedConnector wraps the PostgreSQL connector so every statement, including those
+// run in transactions, is recorded in the query metrics and may be sampled for EXPLAIN
+type instrumentedConnector struct {
+	driver.Connector
+	explain *explainSampler
+}
+
+// Connect opens an instrumented connection
+func (c *instrumentedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedConn{Conn: conn, explain: c.explain}, nil
+}
+
+// instrumentedConn times the statements run on a driver connection. The optional driver
+// interfaces are passed through to the wrapped connection.
+type instrumentedConn struct {
+	driver.Conn
+	explain *explainSampler
+}
+
+// finish records a completed statement and offers it for EXPLAIN sampling
+func (c *instrumentedConn) finish(query string, args []driver.NamedValue, start time.Time, rows int64, err error) {
+	elapsed := time.Since(start)
+	label := cachedQueryLabel(query)
+	recordQuery(label, elapsed, rows, err)
+	if err == nil {
+		c.explain.offer(label, query, args, elapsed)
+	}
+}
+
+// QueryContext runs a query; it is recorded once its rows are closed
+func (c *instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		if err != driver.ErrSkip {
+			c.finish(query, args, start, -1, err)
+		}
+		return nil, err
+	}
+	return &instrumentedRows{Rows: rows, conn: c, query: query, args: args, start: start}, nil
+}
+
+// ExecContext runs a statement and records the rows it affected
+func (c *instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	start := time.Now()
+	result, err := execer.ExecContext(ctx, query, args)
+	if err == driver.ErrSkip {
+		return nil, err
+	}
+	rows := int64(-1)
+	if err == nil {
+		if affected, affectedErr := result.RowsAffected(); affectedErr == nil {
+			rows = affected
+		}
+	}
+	c.finish(query, args, start, rows, err)
+	return result, err
+}
+
+// PrepareContext prepares a statement; prepared statements are not instrumented
+func (c *instrumentedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+// BeginTx starts a transaction on the wrapped connection
+func (c *instrumentedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+// Ping checks the wrapped connection
+func (c *instrumentedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// ResetSession resets the wrapped connection before it is reused
+func (c *instrumentedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+// IsValid reports whether the wrapped connection may be reused
+func (c *instrumentedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// instrumentedRows counts the rows read from a query and records it when closed
+type instrumentedRows struct {
+	driver.Rows
+	conn  *instrumentedConn
+	query string
+	args  []driver.NamedValue
+	start time.Time
+
+	count  int64
+	err    error
+	closed bool
+}
+
+// Next reads the next row, counting it
+func (r *instrumentedRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	switch {
+	case err == nil:
+		r.count++
+	case err != io.EOF:
+		r.err = err
+	}
+	return err
+}
+
+// Close closes the rows and records the query
+func (r *instrumentedRows) Close() error {
+	err := r.Rows.Close()
+	if !r.closed {
+		r.closed = true
+		r.conn.finish(r.query, r.args, r.start, r.count, r.err)
+	}
+	return err
+}
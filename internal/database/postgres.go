@@ -1,18 +1,23 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"time"
 
-	_ "github.com/lib/pq"
+	"cred_flights_booking/internal/config"
+	"github.com/lib/pq"
 )
 
 // DB represents the database connection
 type DB struct {
 	*sql.DB
+	slowQueryThreshold time.Duration // Queries at least this slow are logged; 0 disables
+	txMaxAttempts      int           // Attempts of a retried transaction before giving up
 }
 
 // NewPostgresDB creates a new PostgreSQL database connection
@@ -26,23 +31,45 @@ func NewPostgresDB() (*DB, error) {
 	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		host, port, user, password, dbname)
 
-	db, err := sql.Open("postgres", dsn)
+	// Benchmarks point the pool at a schema of synthetic tables, falling back to public
+	if searchPath := getEnv("DB_SEARCH_PATH", ""); searchPath != "" {
+		dsn += " search_path=" + searchPath
+	}
+
+	connector, err := pq.NewConnector(dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
+	// Every statement is measured for /debug/metrics, and slow reads are sampled for EXPLAIN
+	slowQueryThreshold := config.GetDuration("SLOW_QUERY_THRESHOLD", 200*time.Millisecond)
+	explain := newExplainSampler(LoadExplainConfig(slowQueryThreshold))
+	db := sql.OpenDB(&instrumentedConnector{Connector: connector, explain: explain})
+	explain.db = db
+
 	// Configure connection pool
 	db.SetMaxOpenConns(25)
 	db.SetMaxIdleConns(25)
 	db.SetConnMaxLifetime(5 * time.Minute)
 
-	// Test the connection
-	if err := db.Ping(); err != nil {
+	// Wait for the database to accept connections
+	log.Printf("Connecting to PostgreSQL at %s:%s/%s", host, port, dbname)
+	err = WaitFor("PostgreSQL", LoadRetryConfig(), func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return db.PingContext(ctx)
+	})
+	if err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
 	log.Println("Successfully connected to PostgreSQL database")
-	return &DB{db}, nil
+	return &DB{
+		DB:                 db,
+		slowQueryThreshold: slowQueryThreshold,
+		txMaxAttempts:      max(config.GetInt("DB_TX_MAX_ATTEMPTS", 3), 1),
+	}, nil
 }
 
 // Close closes the database connection
@@ -78,3 +105,55 @@ func (db *DB) Transaction(fn func(*sql.Tx) error) error {
 
 	return nil
 }
+
+// RetryTransaction runs fn in a transaction with the given options, rolling back when fn
+// fails. Transactions PostgreSQL aborts with a serialization failure or deadlock are retried
+// from the start, up to DB_TX_MAX_ATTEMPTS times, so fn must only write through tx and must
+// not keep state from an earlier attempt.
+func (db *DB) RetryTransaction(ctx context.Context, opts *sql.TxOptions, fn func(*sql.Tx) error) error {
+	for attempt := 1; ; attempt++ {
+		err := db.transactionContext(ctx, opts, fn)
+		if err == nil || !isRetryableTxError(err) || attempt >= db.txMaxAttempts {
+			return err
+		}
+
+		backoff := time.Duration(attempt) * 20 * time.Millisecond
+		log.Printf("Transaction aborted (attempt %d/%d), retrying in %v: %v", attempt, db.txMaxAttempts, backoff, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// transactionContext runs fn in one transaction bound to ctx
+func (db *DB) transactionContext(ctx context.Context, opts *sql.TxOptions, fn func(*sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			log.Printf("Failed to rollback transaction: %v", rbErr)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// isRetryableTxError reports whether PostgreSQL aborted a transaction because of a
+// serialization failure (40001) or deadlock (40P01)
+func isRetryableTxError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == "40001" || pqErr.Code == "40P01"
+}
@@ -0,0 +1,187 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// maxQueryLabels bounds the distinct query labels tracked; later ones are counted as "other"
+const maxQueryLabels = 500
+
+// Histogram bucket upper bounds of query durations (seconds) and result sizes (rows)
+var (
+	durationBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	rowBuckets      = []float64{0, 1, 10, 100, 1000, 10000}
+)
+
+// queryNamePattern matches a /* query: name */ comment naming a statement for metrics
+var queryNamePattern = regexp.MustCompile(`/\*\s*query:\s*([\w.\-]+)\s*\*/`)
+
+// leadingComments matches SQL comments and whitespace before a statement's first keyword
+var leadingComments = regexp.MustCompile(`(?s)^(\s+|--[^\n]*\n?|/\*.*?\*/)*`)
+
+// histogram is a cumulative Prometheus-style histogram
+type histogram struct {
+	counts []uint64 // Per bucket, plus +Inf last
+	sum    float64
+	count  uint64
+}
+
+// observe adds a value to the histogram
+func (h *histogram) observe(buckets []float64, value float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(buckets)+1)
+	}
+	i := sort.SearchFloat64s(buckets, value)
+	h.counts[i]++
+	h.sum += value
+	h.count++
+}
+
+// queryStats are the metrics of one query label
+type queryStats struct {
+	duration histogram
+	rows     histogram
+	errors   map[string]uint64 // By error class
+}
+
+// queryMetrics collects per-query metrics for every statement run through the database
+var queryMetrics = struct {
+	mu      sync.Mutex
+	queries map[string]*queryStats
+}{queries: make(map[string]*queryStats)}
+
+// QueryLabel names a statement for metrics: the name in a /* query: name */ comment,
+// otherwise its first keyword and the table it reads or writes (e.g. "select flights")
+func QueryLabel(query string) string {
+	statement := strings.TrimSpace(query[len(leadingComments.FindString(query)):])
+	words := strings.Fields(strings.ToLower(statement))
+	if len(words) == 0 {
+		return "empty"
+	}
+	verb := words[0]
+	if verb == "explain" {
+		return verb
+	}
+	if match := queryNamePattern.FindStringSubmatch(query); match != nil {
+		return match[1]
+	}
+
+	// The relation follows FROM, INTO, or UPDATE; a WITH statement is named by its first CTE
+	after := map[string]string{"select": "from", "delete": "from", "insert": "into", "update": "update", "with": "with"}[verb]
+	if after == "" {
+		return verb
+	}
+	for i, word := range words[:len(words)-1] {
+		if word == after {
+			next := words[i+1]
+			if next == "recursive" && i+2 < len(words) {
+				next = words[i+2]
+			}
+			return verb + " " + strings.Trim(next, `"(),;`)
+		}
+	}
+	return verb
+}
+
+// errorClass classifies a query error for metrics: the PostgreSQL condition name when the
+// server reported one, else timeout, canceled, or other
+func errorClass(err error) string {
+	var pqErr *pq.Error
+	switch {
+	case errors.As(err, &pqErr):
+		return pqErr.Code.Name()
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	}
+	return "other"
+}
+
+// recordQuery adds one statement's duration, rows read or affected, and error to the metrics
+func recordQuery(label string, elapsed time.Duration, rows int64, err error) {
+	queryMetrics.mu.Lock()
+	defer queryMetrics.mu.Unlock()
+
+	stats, ok := queryMetrics.queries[label]
+	if !ok {
+		if len(queryMetrics.queries) >= maxQueryLabels {
+			label = "other"
+		}
+		if stats, ok = queryMetrics.queries[label]; !ok {
+			stats = &queryStats{errors: make(map[string]uint64)}
+			queryMetrics.queries[label] = stats
+		}
+	}
+
+	stats.duration.observe(durationBuckets, elapsed.Seconds())
+	if rows >= 0 {
+		stats.rows.observe(rowBuckets, float64(rows))
+	}
+	if err != nil {
+		stats.errors[errorClass(err)]++
+	}
+}
+
+// WriteQueryMetrics writes the query metrics in the Prometheus text exposition format
+func WriteQueryMetrics(w io.Writer) {
+	queryMetrics.mu.Lock()
+	defer queryMetrics.mu.Unlock()
+
+	labels := make([]string, 0, len(queryMetrics.queries))
+	for label := range queryMetrics.queries {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	fmt.Fprintln(w, "# HELP db_query_duration_seconds Time from sending a statement until its last row was read.")
+	fmt.Fprintln(w, "# TYPE db_query_duration_seconds histogram")
+	for _, label := range labels {
+		writeHistogram(w, "db_query_duration_seconds", label, durationBuckets, &queryMetrics.queries[label].duration)
+	}
+
+	fmt.Fprintln(w, "# HELP db_query_rows Rows returned by a query or affected by a statement.")
+	fmt.Fprintln(w, "# TYPE db_query_rows histogram")
+	for _, label := range labels {
+		writeHistogram(w, "db_query_rows", label, rowBuckets, &queryMetrics.queries[label].rows)
+	}
+
+	fmt.Fprintln(w, "# HELP db_query_errors_total Failed statements by error class.")
+	fmt.Fprintln(w, "# TYPE db_query_errors_total counter")
+	for _, label := range labels {
+		stats := queryMetrics.queries[label]
+		classes := make([]string, 0, len(stats.errors))
+		for class := range stats.errors {
+			classes = append(classes, class)
+		}
+		sort.Strings(classes)
+		for _, class := range classes {
+			fmt.Fprintf(w, "db_query_errors_total{query=%q,error=%q} %d\n", label, class, stats.errors[class])
+		}
+	}
+}
+
+// writeHistogram writes one histogram's cumulative buckets, sum, and count
+func writeHistogram(w io.Writer, name, label string, buckets []float64, h *histogram) {
+	if h.count == 0 {
+		return
+	}
+	var cumulative uint64
+	for i, bound := range buckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{query=%q,le=\"%g\"} %d\n", name, label, bound, cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{query=%q,le=\"+Inf\"} %d\n", name, label, h.count)
+	fmt.Fprintf(w, "%s_sum{query=%q} %g\n", name, label, h.sum)
+	fmt.Fprintf(w, "%s_count{query=%q} %d\n", name, label, h.count)
+}
@@ -1,15 +1,59 @@
 package database
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"expvar"
 	"fmt"
+	"io"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/klauspost/compress/zstd"
 )
 
+// keyPrefix namespaces every cache key so environments sharing a Redis instance don't collide
+var keyPrefix = loadKeyPrefix()
+
+// compressionMarker prefixes compressed payloads. Plain JSON never starts with this byte,
+// so values written before compression existed are still readable. Payloads are written
+// with zstd; gzip payloads written before zstd are told apart by their magic bytes.
+const compressionMarker byte = 0x01
+
+// gzipMagic starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// zstdEncoder and zstdDecoder are shared by all payloads; both are safe for concurrent use
+// through EncodeAll and DecodeAll
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest))
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+)
+
+// compressionThreshold is the payload size in bytes above which values are compressed
+var compressionThreshold = loadCompressionThreshold()
+
+// compressionStats exposes cache compression counters (compressed_writes, bytes_saved)
+var compressionStats = expvar.NewMap("cache_compression")
+
+// legacyKeyPatterns lists the un-namespaced key patterns written before CACHE_KEY_PREFIX existed
+var legacyKeyPatterns = []string{
+	"flight_search:*",
+	"flight_seats:*",
+	"flight_seats_reconciled:*",
+	"flight_load:*",
+	"flight:*",
+	"occupancy_event:*",
+	"booking:*",
+	"temp_booking:*",
+	"events:*",
+}
+
 // RedisClient represents the Redis client
 type RedisClient struct {
 	*redis.Client
@@ -28,11 +72,15 @@ func NewRedisClient() (*RedisClient, error) {
 		MinIdleConns: 5,
 	})
 
-	// Test the connection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if err := client.Ping(ctx).Err(); err != nil {
+	// Wait for Redis to accept connections
+	log.Printf("Connecting to Redis at %s:%s", host, port)
+	err := WaitFor("Redis", LoadRetryConfig(), func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return client.Ping(ctx).Err()
+	})
+	if err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to ping Redis: %w", err)
 	}
 
@@ -45,19 +93,41 @@ func (rc *RedisClient) Close() error {
 	return rc.Client.Close()
 }
 
-// SetJSON sets a JSON value in Redis with expiration
+// SetJSON sets a JSON value in Redis with expiration.
+// Payloads above the compression threshold are zstd-compressed behind a marker byte.
 func (rc *RedisClient) SetJSON(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
 	jsonData, err := json.Marshal(value)
 	if err != nil {
 		return fmt.Errorf("failed to marshal JSON: %w", err)
 	}
 
-	return rc.Set(ctx, key, jsonData, expiration).Err()
+	payload, err := encodePayload(jsonData)
+	if err != nil {
+		return err
+	}
+
+	return rc.Set(ctx, key, payload, expiration).Err()
 }
 
-// GetJSON gets a JSON value from Redis
+// UpdateJSON replaces an existing JSON value and its expiration. It reports false, writing
+// nothing, when the key does not exist (e.g. it expired or was deleted meanwhile).
+func (rc *RedisClient) UpdateJSON(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	jsonData, err := json.Marshal(value)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	payload, err := encodePayload(jsonData)
+	if err != nil {
+		return false, err
+	}
+
+	return rc.SetXX(ctx, key, payload, expiration).Result()
+}
+
+// GetJSON gets a JSON value from Redis, transparently decompressing marked payloads
 func (rc *RedisClient) GetJSON(ctx context.Context, key string, dest interface{}) error {
-	data, err := rc.Get(ctx, key).Result()
+	data, err := rc.Get(ctx, key).Bytes()
 	if err != nil {
 		if err == redis.Nil {
 			return fmt.Errorf("key not found: %s", key)
@@ -65,7 +135,21 @@ func (rc *RedisClient) GetJSON(ctx context.Context, key string, dest interface{}
 		return fmt.Errorf("failed to get from Redis: %w", err)
 	}
 
-	return json.Unmarshal([]byte(data), dest)
+	jsonData, err := decodePayload(data)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(jsonData, dest)
+}
+
+// GetPayload returns a stored value's JSON bytes, decompressing it if needed
+func (rc *RedisClient) GetPayload(ctx context.Context, key string) ([]byte, error) {
+	data, err := rc.Get(ctx, key).Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get from Redis: %w", err)
+	}
+	return decodePayload(data)
 }
 
 // Delete removes a key from Redis
@@ -82,22 +166,337 @@ func (rc *RedisClient) KeyExists(ctx context.Context, key string) (bool, error)
 	return result > 0, nil
 }
 
-// GenerateSearchCacheKey generates a cache key for flight search results (src, dest, date only)
-func GenerateSearchCacheKey(source, destination, date string) string {
-	return fmt.Sprintf("flight_search:%s:%s:%s", source, destination, date)
+// GenerateSearchCacheKey generates a cache key for flight search results (src, dest, date, and search depth only)
+func GenerateSearchCacheKey(source, destination, date string, maxStops int) string {
+	return namespacedKey("flight_search:%s:%s:%s:%d", source, destination, date, maxStops)
 }
 
 // GenerateSeatCacheKey generates a cache key for flight seat count
 func GenerateSeatCacheKey(flightID int, date string) string {
-	return fmt.Sprintf("flight_seats:%d:%s", flightID, date)
+	return namespacedKey("flight_seats:%d:%s", flightID, date)
+}
+
+// GenerateSeatCounterIndexKey generates the key of the sorted set of live seat counters,
+// scored by the end of their departure day
+func GenerateSeatCounterIndexKey() string {
+	return namespacedKey("flight_seats_index")
+}
+
+// GenerateFlightCacheKey generates a cache key for flight details fetched from the flight service
+func GenerateFlightCacheKey(flightID int) string {
+	return namespacedKey("flight:%d", flightID)
 }
 
 // GenerateBookingCacheKey generates a cache key for booking
 func GenerateBookingCacheKey(bookingID int) string {
-	return fmt.Sprintf("booking:%d", bookingID)
+	return namespacedKey("booking:%d", bookingID)
+}
+
+// GenerateResendCooldownKey generates a key that throttles confirmation resends for a booking
+func GenerateResendCooldownKey(bookingID int) string {
+	return namespacedKey("confirmation_resend:%d", bookingID)
 }
 
 // GenerateTempBookingCacheKey generates a cache key for temporary booking
 func GenerateTempBookingCacheKey(userID, flightID int) string {
-	return fmt.Sprintf("temp_booking:%d:%d", userID, flightID)
+	return namespacedKey("temp_booking:%d:%d", userID, flightID)
+}
+
+// GenerateBookingHoldCacheKey generates a key mapping a hold ID to its temporary booking key
+func GenerateBookingHoldCacheKey(holdID string) string {
+	return namespacedKey("booking_hold:%s", holdID)
+}
+
+// GenerateUpgradeOfferCacheKey generates a cache key for a booking's cabin upgrade offer
+func GenerateUpgradeOfferCacheKey(offerID string) string {
+	return namespacedKey("upgrade_offer:%s", offerID)
+}
+
+// GenerateSeatReconciledCacheKey generates a cache key holding when a seat counter was last loaded from the database
+func GenerateSeatReconciledCacheKey(flightID int, date string) string {
+	return namespacedKey("flight_seats_reconciled:%d:%s", flightID, date)
+}
+
+// GenerateLoadFactorCacheKey generates a cache key for the booked seat counter of a flight
+func GenerateLoadFactorCacheKey(flightID int, date string) string {
+	return namespacedKey("flight_load:%d:%s", flightID, date)
+}
+
+// GenerateOccupancyEventCacheKey generates a cache key used to deduplicate occupancy events
+func GenerateOccupancyEventCacheKey(bookingID int, reason string) string {
+	return namespacedKey("occupancy_event:%d:%s", bookingID, reason)
+}
+
+// GeneratePartnerKeyCacheKey generates a cache key for a partner looked up by API key hash
+func GeneratePartnerKeyCacheKey(keyHash string) string {
+	return namespacedKey("partner_key:%s", keyHash)
+}
+
+// GeneratePartnerRateKey generates the per-minute request counter key for a partner
+func GeneratePartnerRateKey(partnerID int, minute int64) string {
+	return namespacedKey("partner_rate:%d:%d", partnerID, minute)
+}
+
+// GeneratePartnerUsageKey generates the daily usage hash key for a partner
+func GeneratePartnerUsageKey(partnerID int, date string) string {
+	return namespacedKey("partner_usage:%d:%s", partnerID, date)
+}
+
+// GenerateAgencyKeyCacheKey generates a cache key for an agency looked up by API key hash
+func GenerateAgencyKeyCacheKey(keyHash string) string {
+	return namespacedKey("agency_key:%s", keyHash)
+}
+
+// GenerateBatchAvailabilityCacheKey generates a cache key for a batch availability response
+func GenerateBatchAvailabilityCacheKey(requestHash string) string {
+	return namespacedKey("availability_batch:%s", requestHash)
+}
+
+// GeneratePopularRoutesCacheKey generates the key of the precomputed popular routes, with
+// an encoding suffix ("json" or "br")
+func GeneratePopularRoutesCacheKey(encoding string) string {
+	return namespacedKey("popular_routes:%s", encoding)
+}
+
+// GenerateSeatNonceKey generates the key recording a used seat nonce
+func GenerateSeatNonceKey(nonceID string) string {
+	return namespacedKey("seat_nonce:%s", nonceID)
+}
+
+// GenerateWebhookReplayKey generates the key recording a received webhook delivery
+func GenerateWebhookReplayKey(deliveryID, timestamp string) string {
+	return namespacedKey("webhook_replay:%s:%s", deliveryID, timestamp)
+}
+
+// GenerateExperimentExposureKey generates the hash key counting exposures per variant of an experiment
+func GenerateExperimentExposureKey(experiment string) string {
+	return namespacedKey("experiment_exposures:%s", experiment)
+}
+
+// GenerateFunnelStageKey generates the HyperLogLog key of sessions reaching a funnel stage
+func GenerateFunnelStageKey(day, route, stage string) string {
+	return namespacedKey("funnel:%s:%s:%s", day, route, stage)
+}
+
+// GenerateFunnelRoutesKey generates the set key of routes with funnel events on a day
+func GenerateFunnelRoutesKey(day string) string {
+	return namespacedKey("funnel_routes:%s", day)
+}
+
+// GenerateEventStreamKey generates the Redis stream key for an event stream
+func GenerateEventStreamKey(stream string) string {
+	return namespacedKey("events:%s", stream)
+}
+
+// GenerateDeadLetterKey generates the Redis stream key of events consumers gave up on
+func GenerateDeadLetterKey() string {
+	return namespacedKey("events_dlq")
+}
+
+// GenerateEventAttemptsKey generates the key counting a consumer group's failed deliveries per entry
+func GenerateEventAttemptsKey(stream, group string) string {
+	return namespacedKey("event_attempts:%s:%s", stream, group)
+}
+
+// GenerateBookingProjectionKey generates the hash key tracking how far the booking read model is projected
+func GenerateBookingProjectionKey() string {
+	return namespacedKey("booking_projection")
+}
+
+// GenerateSearchProjectionKey generates the cache key of a route's sorted search paths for a search depth, seat bucket, and order
+func GenerateSearchProjectionKey(source, destination, date string, maxStops, seatBucket int, sortBy, variant string) string {
+	return namespacedKey("search_projection:%s:%s:%s:%d:%d:%s:%s", source, destination, date, maxStops, seatBucket, sortBy, variant)
+}
+
+// GenerateSearchLoadLockKey generates the key of the lock held by the replica loading a route's search results
+func GenerateSearchLoadLockKey(source, destination, date string, maxStops int) string {
+	return namespacedKey("search_load_lock:%s:%s:%s:%d", source, destination, date, maxStops)
+}
+
+// GenerateSearchLoadedChannel generates the pub/sub channel announcing a route's search results were loaded
+func GenerateSearchLoadedChannel(source, destination, date string, maxStops int) string {
+	return namespacedKey("search_loaded:%s:%s:%s:%d", source, destination, date, maxStops)
+}
+
+// GenerateSearchJobKey generates the cache key of an async search job
+func GenerateSearchJobKey(jobID string) string {
+	return namespacedKey("search_job:%s", jobID)
+}
+
+// GenerateSearchJobResultKey generates the cache key of an exhaustive search's results, by request hash
+func GenerateSearchJobResultKey(requestHash string) string {
+	return namespacedKey("search_job_result:%s", requestHash)
+}
+
+// GenerateSearchActivityKey generates the sorted set key of a subject's recent searches (e.g. "ip:203.0.113.7")
+func GenerateSearchActivityKey(subject string) string {
+	return namespacedKey("search_activity:%s", subject)
+}
+
+// GenerateSearchVolumeKey generates the per-minute counter key of all searches
+func GenerateSearchVolumeKey(minute int64) string {
+	return namespacedKey("search_volume:%d", minute)
+}
+
+// GenerateSearchAnomaliesKey generates the sorted set key of subjects flagged for search abuse, by detection time
+func GenerateSearchAnomaliesKey() string {
+	return namespacedKey("search_anomalies")
+}
+
+// GenerateSearchAnomalyKey generates the key of a flagged subject's anomaly details
+func GenerateSearchAnomalyKey(subject string) string {
+	return namespacedKey("search_anomaly:%s", subject)
+}
+
+// GenerateSearchThrottleKey generates the key marking a subject as throttled
+func GenerateSearchThrottleKey(subject string) string {
+	return namespacedKey("search_throttle:%s", subject)
+}
+
+// GenerateSearchThrottleRateKey generates the per-minute search counter key of a throttled subject
+func GenerateSearchThrottleRateKey(subject string, minute int64) string {
+	return namespacedKey("search_throttle_rate:%s:%d", subject, minute)
+}
+
+// GenerateBookingQueueKey generates the key of a flight date's waiting booking tickets, by arrival
+func GenerateBookingQueueKey(flightID int, date string) string {
+	return namespacedKey("booking_queue:%d:%s", flightID, date)
+}
+
+// GenerateBookingQueueSeenKey generates the key of a flight date's waiting tickets, by last poll
+func GenerateBookingQueueSeenKey(flightID int, date string) string {
+	return namespacedKey("booking_queue_seen:%d:%s", flightID, date)
+}
+
+// GenerateBookingQueueActiveKey generates the key of a flight date's admission leases, by expiry
+func GenerateBookingQueueActiveKey(flightID int, date string) string {
+	return namespacedKey("booking_queue_active:%d:%s", flightID, date)
+}
+
+// GenerateBookingQueueSeqKey generates the arrival counter key of a flight date's booking queue
+func GenerateBookingQueueSeqKey(flightID int, date string) string {
+	return namespacedKey("booking_queue_seq:%d:%s", flightID, date)
+}
+
+// GenerateBookingQueueSpentKey generates the key of a flight date's admitted tickets, by lease expiry
+func GenerateBookingQueueSpentKey(flightID int, date string) string {
+	return namespacedKey("booking_queue_spent:%d:%s", flightID, date)
+}
+
+// GeneratePaymentAuthorizationKey generates cache key for a payment's authorization
+func GeneratePaymentAuthorizationKey(paymentID string) string {
+	return namespacedKey("payment_auth:%s", paymentID)
+}
+
+// GeneratePaymentAuthorizationDeadlinesKey generates cache key for the uncaptured
+// authorizations, scored by capture deadline
+func GeneratePaymentAuthorizationDeadlinesKey() string {
+	return namespacedKey("payment_auth_deadlines")
+}
+
+// GeneratePaymentRefundKey generates the key claimed by the one refund of a captured payment
+func GeneratePaymentRefundKey(paymentID string) string {
+	return namespacedKey("payment_refund:%s", paymentID)
+}
+
+// KeyPrefix returns the namespace prefix applied to all cache keys
+func KeyPrefix() string {
+	return keyPrefix
+}
+
+// loadKeyPrefix reads the cache key namespace from the environment (e.g. "staging")
+func loadKeyPrefix() string {
+	prefix := getEnv("CACHE_KEY_PREFIX", "")
+	if prefix != "" && !strings.HasSuffix(prefix, ":") {
+		prefix += ":"
+	}
+	return prefix
+}
+
+// namespacedKey formats a cache key and applies the configured namespace prefix
+func namespacedKey(format string, args ...interface{}) string {
+	return keyPrefix + fmt.Sprintf(format, args...)
+}
+
+// MigrateKeysToNamespace renames un-namespaced legacy keys to the configured prefix.
+// Keys that already exist under the prefix are left untouched.
+func (rc *RedisClient) MigrateKeysToNamespace(ctx context.Context) (int, error) {
+	if keyPrefix == "" {
+		return 0, nil
+	}
+
+	migrated := 0
+	for _, pattern := range legacyKeyPatterns {
+		iter := rc.Scan(ctx, 0, pattern, 100).Iterator()
+		for iter.Next(ctx) {
+			key := iter.Val()
+			renamed, err := rc.RenameNX(ctx, key, keyPrefix+key).Result()
+			if err != nil {
+				return migrated, fmt.Errorf("failed to rename key %s: %w", key, err)
+			}
+			if renamed {
+				migrated++
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return migrated, fmt.Errorf("failed to scan keys for %s: %w", pattern, err)
+		}
+	}
+
+	log.Printf("Migrated %d cache keys to namespace %q", migrated, keyPrefix)
+	return migrated, nil
+}
+
+// loadCompressionThreshold reads the compression threshold from the environment (0 disables compression)
+func loadCompressionThreshold() int {
+	threshold, err := strconv.Atoi(getEnv("CACHE_COMPRESSION_THRESHOLD", "4096"))
+	if err != nil {
+		log.Printf("Invalid CACHE_COMPRESSION_THRESHOLD, using default: %v", err)
+		return 4096
+	}
+	return threshold
+}
+
+// encodePayload compresses JSON payloads larger than the compression threshold
+func encodePayload(jsonData []byte) ([]byte, error) {
+	if compressionThreshold <= 0 || len(jsonData) < compressionThreshold {
+		return jsonData, nil
+	}
+
+	payload := zstdEncoder.EncodeAll(jsonData, []byte{compressionMarker})
+
+	compressionStats.Add("compressed_writes", 1)
+	compressionStats.Add("bytes_saved", int64(len(jsonData)-len(payload)))
+	return payload, nil
+}
+
+// decodePayload returns the JSON bytes of a stored payload, decompressing if marked
+func decodePayload(data []byte) ([]byte, error) {
+	if len(data) == 0 || data[0] != compressionMarker {
+		return data, nil
+	}
+	if bytes.HasPrefix(data[1:], gzipMagic) {
+		return decodeGzipPayload(data[1:])
+	}
+
+	jsonData, err := zstdDecoder.DecodeAll(data[1:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress payload: %w", err)
+	}
+	return jsonData, nil
+}
+
+// decodeGzipPayload decompresses a payload written before zstd compression
+func decodeGzipPayload(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress payload: %w", err)
+	}
+	defer zr.Close()
+
+	jsonData, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress payload: %w", err)
+	}
+	return jsonData, nil
 }
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"cred_flights_booking/internal/config"
+)
+
+// RetryConfig controls how long a service waits for a dependency at startup
+type RetryConfig struct {
+	MaxWait        time.Duration // Give up once this much time has passed
+	InitialBackoff time.Duration // Delay after the first failed attempt
+	MaxBackoff     time.Duration // Upper bound for the doubling delay
+}
+
+// LoadRetryConfig loads startup retry settings from the environment
+func LoadRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxWait:        config.GetDuration("STARTUP_MAX_WAIT", 60*time.Second),
+		InitialBackoff: config.GetDuration("STARTUP_INITIAL_BACKOFF", 500*time.Millisecond),
+		MaxBackoff:     config.GetDuration("STARTUP_MAX_BACKOFF", 5*time.Second),
+	}
+}
+
+// WaitFor calls connect until it succeeds, doubling the delay between attempts, and
+// returns the last error once the maximum wait is exceeded
+func WaitFor(name string, cfg RetryConfig, connect func() error) error {
+	start := time.Now()
+	backoff := cfg.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := connect()
+		if err == nil {
+			if attempt > 1 {
+				log.Printf("%s is available after %d attempts (%v)", name, attempt, time.Since(start).Round(time.Millisecond))
+			}
+			return nil
+		}
+
+		remaining := cfg.MaxWait - time.Since(start)
+		if remaining <= 0 {
+			return fmt.Errorf("gave up waiting for %s after %d attempts: %w", name, attempt, err)
+		}
+
+		delay := min(backoff, remaining)
+		log.Printf("Waiting for %s (attempt %d failed: %v), retrying in %v", name, attempt, err, delay.Round(time.Millisecond))
+		time.Sleep(delay)
+		backoff = min(backoff*2, cfg.MaxBackoff)
+	}
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+
+	"cred_flights_booking/internal/tracing"
+)
+
+// maxLoggedQueryLength truncates long queries (such as the multi-stop CTE) in slow query logs
+const maxLoggedQueryLength = 300
+
+// QueryContext runs a query, logging it if it exceeds the slow query threshold
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := db.DB.QueryContext(ctx, query, args...)
+	db.logSlowQuery(ctx, start, query, args, err)
+	return rows, err
+}
+
+// QueryRowContext runs a single-row query, logging it if it exceeds the slow query threshold
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := db.DB.QueryRowContext(ctx, query, args...)
+	db.logSlowQuery(ctx, start, query, args, row.Err())
+	return row
+}
+
+// ExecContext runs a statement, logging it if it exceeds the slow query threshold
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := db.DB.ExecContext(ctx, query, args...)
+	db.logSlowQuery(ctx, start, query, args, err)
+	return result, err
+}
+
+// logSlowQuery logs a query that took at least the threshold. Argument values are omitted
+// since they may hold personal data; only their count is logged.
+func (db *DB) logSlowQuery(ctx context.Context, start time.Time, query string, args []interface{}, err error) {
+	elapsed := time.Since(start)
+	if db.slowQueryThreshold <= 0 || elapsed < db.slowQueryThreshold {
+		return
+	}
+
+	statement := strings.Join(strings.Fields(query), " ")
+	if len(statement) > maxLoggedQueryLength {
+		statement = statement[:maxLoggedQueryLength] + "..."
+	}
+
+	route := ""
+	if info := tracing.FromContext(ctx); info != nil {
+		route = info.Route
+	}
+
+	log.Printf("SLOW_QUERY duration_ms=%d threshold_ms=%d args=%d error=%t route=%q trace_id=%s query=%q",
+		elapsed.Milliseconds(), db.slowQueryThreshold.Milliseconds(), len(args), err != nil,
+		route, tracing.TraceID(ctx), statement)
+}
@@ -0,0 +1,144 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"expvar"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/database"
+)
+
+// startedAt is when the process started, for uptime reporting
+var startedAt = time.Now()
+
+// PoolStats returns the current statistics of a connection pool
+type PoolStats func() interface{}
+
+// RuntimeStats is the response of GET /debug/runtime
+type RuntimeStats struct {
+	Service       string                 `json:"service"`
+	GoVersion     string                 `json:"go_version"`
+	UptimeSeconds int64                  `json:"uptime_seconds"`
+	NumCPU        int                    `json:"num_cpu"`
+	GOMAXPROCS    int                    `json:"gomaxprocs"`
+	Goroutines    int                    `json:"goroutines"`
+	Heap          HeapStats              `json:"heap"`
+	GC            GCStats                `json:"gc"`
+	Pools         map[string]interface{} `json:"pools,omitempty"`
+}
+
+// HeapStats summarizes heap memory usage in bytes
+type HeapStats struct {
+	AllocBytes      uint64 `json:"alloc_bytes"`
+	InuseBytes      uint64 `json:"inuse_bytes"`
+	IdleBytes       uint64 `json:"idle_bytes"`
+	SysBytes        uint64 `json:"sys_bytes"`
+	Objects         uint64 `json:"objects"`
+	TotalAllocBytes uint64 `json:"total_alloc_bytes"`
+}
+
+// GCStats summarizes garbage collector activity
+type GCStats struct {
+	NumGC        uint32     `json:"num_gc"`
+	LastGC       *time.Time `json:"last_gc,omitempty"`
+	LastPauseMs  float64    `json:"last_pause_ms"`
+	TotalPauseMs float64    `json:"total_pause_ms"`
+	CPUFraction  float64    `json:"cpu_fraction"`
+	NextGCBytes  uint64     `json:"next_gc_bytes"`
+}
+
+// NewHandler serves pprof under /debug/pprof/, expvar at /debug/vars, runtime statistics
+// (including the given connection pools) at /debug/runtime, and SQL query metrics in the
+// Prometheus text format at /debug/metrics
+func NewHandler(service string, pools map[string]PoolStats) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("GET /debug/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		database.WriteQueryMetrics(w)
+	})
+	mux.HandleFunc("GET /debug/runtime", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(collect(service, pools)); err != nil {
+			log.Printf("Failed to encode response: %v", err)
+		}
+	})
+	return mux
+}
+
+// collect gathers the current runtime statistics
+func collect(service string, pools map[string]PoolStats) *RuntimeStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	stats := &RuntimeStats{
+		Service:       service,
+		GoVersion:     runtime.Version(),
+		UptimeSeconds: int64(time.Since(startedAt).Seconds()),
+		NumCPU:        runtime.NumCPU(),
+		GOMAXPROCS:    runtime.GOMAXPROCS(0),
+		Goroutines:    runtime.NumGoroutine(),
+		Heap: HeapStats{
+			AllocBytes:      mem.HeapAlloc,
+			InuseBytes:      mem.HeapInuse,
+			IdleBytes:       mem.HeapIdle,
+			SysBytes:        mem.HeapSys,
+			Objects:         mem.HeapObjects,
+			TotalAllocBytes: mem.TotalAlloc,
+		},
+		GC: GCStats{
+			NumGC:        mem.NumGC,
+			TotalPauseMs: float64(mem.PauseTotalNs) / 1e6,
+			CPUFraction:  mem.GCCPUFraction,
+			NextGCBytes:  mem.NextGC,
+		},
+	}
+	if mem.NumGC > 0 {
+		lastGC := time.Unix(0, int64(mem.LastGC))
+		stats.GC.LastGC = &lastGC
+		stats.GC.LastPauseMs = float64(mem.PauseNs[(mem.NumGC+255)%256]) / 1e6
+	}
+
+	if len(pools) > 0 {
+		stats.Pools = make(map[string]interface{}, len(pools))
+		for name, poolStats := range pools {
+			stats.Pools[name] = poolStats()
+		}
+	}
+	return stats
+}
+
+// ListenAndServe serves the diagnostics handler on an internal address in the background
+func ListenAndServe(addr string, handler http.Handler) {
+	go func() {
+		log.Printf("Diagnostics listening on %s", addr)
+		server := &http.Server{
+			Addr:        addr,
+			Handler:     handler,
+			ReadTimeout: 30 * time.Second,
+			IdleTimeout: 60 * time.Second,
+		}
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("Diagnostics server failed: %v", err)
+		}
+	}()
+}
+
+// Serve serves diagnostics on the internal DEBUG_ADDR, or defaultAddr when it is unset.
+// Diagnostics are never mounted on the public port: heap dumps and profiles expose
+// request data, so the address should stay on localhost or a private network.
+func Serve(handler http.Handler, defaultAddr string) {
+	ListenAndServe(config.GetEnv("DEBUG_ADDR", defaultAddr), handler)
+}
@@ -0,0 +1,299 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/internal/experiments"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// Stream names
+const (
+	StreamFlights  = "flights"
+	StreamBookings = "bookings"
+	StreamPayments = "payments"
+)
+
+// Event is a domain event published on the bus
+type Event struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Source     string          `json:"source"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Payload    json.RawMessage `json:"payload"`
+	// Experiment variants of the user whose request caused the event, by experiment key
+	Experiments map[string]string `json:"experiments,omitempty"`
+}
+
+// Decode unmarshals the event payload into dest
+func (e *Event) Decode(dest interface{}) error {
+	if err := json.Unmarshal(e.Payload, dest); err != nil {
+		return fmt.Errorf("failed to decode %s payload: %w", e.Type, err)
+	}
+	return nil
+}
+
+// Handler processes a single event. Returning an error leaves the event pending for
+// redelivery, until it has failed EVENT_MAX_ATTEMPTS times and is dead-lettered.
+type Handler func(ctx context.Context, event *Event) error
+
+// Bus publishes and consumes domain events over Redis streams
+type Bus struct {
+	cache       *database.RedisClient
+	source      string
+	maxLen      int64
+	maxAttempts int
+}
+
+// NewBus creates an event bus. source identifies the publishing service and
+// maxLen caps each stream's length (approximately).
+func NewBus(cache *database.RedisClient, source string, maxLen int64) *Bus {
+	return &Bus{
+		cache:       cache,
+		source:      source,
+		maxLen:      maxLen,
+		maxAttempts: max(config.GetInt("EVENT_MAX_ATTEMPTS", 5), 1),
+	}
+}
+
+// Publish appends an event to a stream
+func (b *Bus) Publish(ctx context.Context, stream, eventType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s payload: %w", eventType, err)
+	}
+
+	event := Event{
+		ID:          uuid.NewString(),
+		Type:        eventType,
+		Source:      b.source,
+		OccurredAt:  time.Now().UTC(),
+		Payload:     data,
+		Experiments: experiments.FromContext(ctx).Tags(),
+	}
+
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	err = b.cache.XAdd(ctx, &redis.XAddArgs{
+		Stream: database.GenerateEventStreamKey(stream),
+		MaxLen: b.maxLen,
+		Approx: true,
+		Values: map[string]interface{}{"event": encoded},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to publish %s event: %w", eventType, err)
+	}
+	return nil
+}
+
+// rangePageSize is how many stream entries Range reads per round trip
+const rangePageSize = 500
+
+// Range calls fn for every event on a stream published at or after since, oldest first.
+// It reads the stream directly, without a consumer group, so it suits replays for analysis.
+// Only events still retained by the stream's max length are visited.
+func (b *Bus) Range(ctx context.Context, stream string, since time.Time, fn func(event *Event) error) error {
+	streamKey := database.GenerateEventStreamKey(stream)
+	start := strconv.FormatInt(since.UnixMilli(), 10)
+
+	for {
+		messages, err := b.cache.XRangeN(ctx, streamKey, start, "+", rangePageSize).Result()
+		if err != nil {
+			return fmt.Errorf("failed to read %s events: %w", stream, err)
+		}
+
+		for _, message := range messages {
+			raw, _ := message.Values["event"].(string)
+			var event Event
+			if err := json.Unmarshal([]byte(raw), &event); err != nil {
+				log.Printf("Skipping malformed event %s on %s: %v", message.ID, streamKey, err)
+				continue
+			}
+			if err := fn(&event); err != nil {
+				return err
+			}
+		}
+
+		if len(messages) < rangePageSize {
+			return nil
+		}
+		start = "(" + messages[len(messages)-1].ID
+	}
+}
+
+// Subscribe consumes a stream as part of a consumer group until ctx is cancelled.
+// Each group sees every event once; consumers within a group share the load.
+func (b *Bus) Subscribe(ctx context.Context, stream, group, consumer string, handler Handler) error {
+	streamKey := database.GenerateEventStreamKey(stream)
+
+	err := b.cache.XGroupCreateMkStream(ctx, streamKey, group, "$").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group %s: %w", group, err)
+	}
+
+	// Retry this consumer's unacknowledged events on startup and whenever the stream is idle
+	pending := true
+	for ctx.Err() == nil {
+		id := ">"
+		if pending {
+			id = "0"
+		}
+
+		streams, err := b.cache.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    group,
+			Consumer: consumer,
+			Streams:  []string{streamKey, id},
+			Count:    50,
+			Block:    5 * time.Second,
+		}).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) || ctx.Err() != nil {
+				pending = true
+				continue
+			}
+			log.Printf("Failed to read %s events for %s: %v", stream, group, err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		pending = false
+		for _, s := range streams {
+			for _, message := range s.Messages {
+				b.dispatch(ctx, stream, group, message, handler)
+			}
+		}
+	}
+	return nil
+}
+
+// Tail calls fn for every event published on a stream from now on until ctx is cancelled.
+// Unlike Subscribe it uses no consumer group, so every caller (e.g. every instance
+// pushing live updates) sees every event; events published while it is not running are
+// never delivered.
+func (b *Bus) Tail(ctx context.Context, stream string, fn func(event *Event)) error {
+	streamKey := database.GenerateEventStreamKey(stream)
+
+	lastID := "$"
+	for ctx.Err() == nil {
+		streams, err := b.cache.XRead(ctx, &redis.XReadArgs{
+			Streams: []string{streamKey, lastID},
+			Count:   100,
+			Block:   5 * time.Second,
+		}).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) || ctx.Err() != nil {
+				continue
+			}
+			log.Printf("Failed to tail %s events: %v", stream, err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		for _, s := range streams {
+			for _, message := range s.Messages {
+				lastID = message.ID
+				raw, _ := message.Values["event"].(string)
+				var event Event
+				if err := json.Unmarshal([]byte(raw), &event); err != nil {
+					log.Printf("Skipping malformed event %s on %s: %v", message.ID, streamKey, err)
+					continue
+				}
+				fn(&event)
+			}
+		}
+	}
+	return nil
+}
+
+// backlogScanLimit caps how many undelivered entries Backlog counts
+const backlogScanLimit = 10000
+
+// Backlog returns how many events a consumer group has yet to process: those delivered but
+// not acknowledged, plus those not yet delivered (counted up to backlogScanLimit)
+func (b *Bus) Backlog(ctx context.Context, stream, group string) (int64, error) {
+	streamKey := database.GenerateEventStreamKey(stream)
+
+	groups, err := b.cache.XInfoGroups(ctx, streamKey).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s consumer groups: %w", stream, err)
+	}
+	for _, info := range groups {
+		if info.Name != group {
+			continue
+		}
+		undelivered, err := b.cache.XRangeN(ctx, streamKey, "("+info.LastDeliveredID, "+", backlogScanLimit).Result()
+		if err != nil {
+			return 0, fmt.Errorf("failed to read undelivered %s events: %w", stream, err)
+		}
+		return info.Pending + int64(len(undelivered)), nil
+	}
+	return 0, fmt.Errorf("consumer group %s not found on %s", group, stream)
+}
+
+// dispatch runs the handler for one stream message and acknowledges it on success. An
+// event that keeps failing, or can't be decoded, is moved to the dead-letter stream.
+func (b *Bus) dispatch(ctx context.Context, stream, group string, message redis.XMessage, handler Handler) {
+	// Replays target one group; the others acknowledge them unseen
+	if target, _ := message.Values["replay_group"].(string); target != "" && target != group {
+		b.ack(ctx, stream, group, message.ID)
+		return
+	}
+
+	raw, _ := message.Values["event"].(string)
+
+	var event Event
+	if err := json.Unmarshal([]byte(raw), &event); err != nil {
+		// Malformed entries can never succeed, so dead-letter them right away
+		log.Printf("Dead-lettering malformed event %s on %s: %v", message.ID, stream, err)
+		b.deadLetter(ctx, stream, group, message.ID, raw, nil, err, 1)
+		return
+	}
+
+	if err := handler(ctx, &event); err != nil {
+		// Failures caused by shutting down don't count against the event
+		if ctx.Err() != nil {
+			return
+		}
+
+		attempts, countErr := b.countAttempt(ctx, stream, group, message.ID)
+		if countErr != nil {
+			log.Printf("Failed to handle %s event %s: %v (%v)", event.Type, event.ID, err, countErr)
+			return
+		}
+		if attempts < b.maxAttempts {
+			log.Printf("Failed to handle %s event %s (attempt %d of %d): %v", event.Type, event.ID, attempts, b.maxAttempts, err)
+			return
+		}
+
+		log.Printf("Dead-lettering %s event %s after %d attempts: %v", event.Type, event.ID, attempts, err)
+		b.deadLetter(ctx, stream, group, message.ID, raw, &event, err, attempts)
+		return
+	}
+
+	b.ack(ctx, stream, group, message.ID)
+}
+
+// ack acknowledges a message and forgets its failed attempts
+func (b *Bus) ack(ctx context.Context, stream, group, messageID string) {
+	_, err := b.cache.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAck(ctx, database.GenerateEventStreamKey(stream), group, messageID)
+		pipe.HDel(ctx, database.GenerateEventAttemptsKey(stream, group), messageID)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to acknowledge event %s on %s: %v", messageID, stream, err)
+	}
+}
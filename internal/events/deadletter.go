@@ -0,0 +1,188 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/pkg/models"
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrDeadLetterNotFound is returned when no dead letter has the requested ID
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// Dead letter metrics, exposed via expvar (dead_lettered, replayed)
+var deadLetterMetrics = expvar.NewMap("event_dlq")
+
+// attemptsTTL bounds how long failed delivery counts are kept for entries that are never
+// acknowledged (e.g. trimmed from the stream while pending)
+const attemptsTTL = 7 * 24 * time.Hour
+
+// countAttempt records a failed delivery of a stream entry and returns the failures so far
+func (b *Bus) countAttempt(ctx context.Context, stream, group, messageID string) (int, error) {
+	key := database.GenerateEventAttemptsKey(stream, group)
+
+	var attempts *redis.IntCmd
+	_, err := b.cache.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		attempts = pipe.HIncrBy(ctx, key, messageID, 1)
+		pipe.Expire(ctx, key, attemptsTTL)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count delivery attempt: %w", err)
+	}
+	return int(attempts.Val()), nil
+}
+
+// deadLetter stores a failed entry with its error and acknowledges it, so it stops blocking
+// the group's pending list. If it can't be stored it stays pending and is retried.
+func (b *Bus) deadLetter(ctx context.Context, stream, group, messageID, raw string, event *Event, cause error, attempts int) {
+	letter := models.DeadLetter{
+		Stream:    stream,
+		Group:     group,
+		MessageID: messageID,
+		Event:     raw,
+		Error:     cause.Error(),
+		Attempts:  attempts,
+		FailedAt:  time.Now().UTC(),
+	}
+	if event != nil {
+		letter.EventID, letter.EventType = event.ID, event.Type
+	}
+
+	encoded, err := json.Marshal(letter)
+	if err != nil {
+		log.Printf("Failed to marshal dead letter for event %s on %s: %v", messageID, stream, err)
+		return
+	}
+	err = b.cache.XAdd(ctx, &redis.XAddArgs{
+		Stream: database.GenerateDeadLetterKey(),
+		Values: map[string]interface{}{"dead_letter": encoded},
+	}).Err()
+	if err != nil {
+		log.Printf("Failed to dead-letter event %s on %s: %v", messageID, stream, err)
+		return
+	}
+
+	deadLetterMetrics.Add("dead_lettered", 1)
+	b.ack(ctx, stream, group, messageID)
+}
+
+// decodeDeadLetter reads a dead letter from its stream entry
+func decodeDeadLetter(message redis.XMessage) (models.DeadLetter, error) {
+	raw, _ := message.Values["dead_letter"].(string)
+
+	var letter models.DeadLetter
+	if err := json.Unmarshal([]byte(raw), &letter); err != nil {
+		return letter, fmt.Errorf("failed to decode dead letter %s: %w", message.ID, err)
+	}
+	letter.ID = message.ID
+	return letter, nil
+}
+
+// DeadLetters returns up to limit dead letters, newest first, optionally only those of one stream
+func (b *Bus) DeadLetters(ctx context.Context, stream string, limit int) (*models.DeadLettersResponse, error) {
+	key := database.GenerateDeadLetterKey()
+
+	total, err := b.cache.XLen(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count dead letters: %w", err)
+	}
+
+	letters := []models.DeadLetter{}
+	end := "+"
+	for len(letters) < limit {
+		messages, err := b.cache.XRevRangeN(ctx, key, end, "-", rangePageSize).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dead letters: %w", err)
+		}
+
+		for _, message := range messages {
+			letter, err := decodeDeadLetter(message)
+			if err != nil {
+				log.Printf("Skipping %v", err)
+				continue
+			}
+			if stream != "" && letter.Stream != stream {
+				continue
+			}
+			letters = append(letters, letter)
+			if len(letters) == limit {
+				break
+			}
+		}
+
+		if len(messages) < rangePageSize {
+			break
+		}
+		end = "(" + messages[len(messages)-1].ID
+	}
+
+	return &models.DeadLettersResponse{DeadLetters: letters, Count: len(letters), Total: total}, nil
+}
+
+// validStreamID reports whether id has the "milliseconds-sequence" form of a stream entry ID
+func validStreamID(id string) bool {
+	millis, seq, ok := strings.Cut(id, "-")
+	if !ok {
+		return false
+	}
+	_, millisErr := strconv.ParseUint(millis, 10, 64)
+	_, seqErr := strconv.ParseUint(seq, 10, 64)
+	return millisErr == nil && seqErr == nil
+}
+
+// ReplayDeadLetter re-publishes a dead letter to its stream, delivered only to the consumer
+// group that gave up on it, and removes it from the dead-letter stream. The event keeps its
+// ID, so consumers that deduplicate by ID still see it once.
+func (b *Bus) ReplayDeadLetter(ctx context.Context, id string) (*models.DeadLetterReplayResponse, error) {
+	if !validStreamID(id) {
+		return nil, ErrDeadLetterNotFound
+	}
+
+	key := database.GenerateDeadLetterKey()
+	messages, err := b.cache.XRange(ctx, key, id, id).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead letter: %w", err)
+	}
+	if len(messages) == 0 {
+		return nil, ErrDeadLetterNotFound
+	}
+	letter, err := decodeDeadLetter(messages[0])
+	if err != nil {
+		return nil, err
+	}
+
+	messageID, err := b.cache.XAdd(ctx, &redis.XAddArgs{
+		Stream: database.GenerateEventStreamKey(letter.Stream),
+		MaxLen: b.maxLen,
+		Approx: true,
+		Values: map[string]interface{}{"event": letter.Event, "replay_group": letter.Group},
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay dead letter: %w", err)
+	}
+
+	// A failed delete leaves the dead letter listed; replaying it again is harmless for
+	// consumers that deduplicate
+	if err := b.cache.XDel(ctx, key, id).Err(); err != nil {
+		log.Printf("Failed to remove replayed dead letter %s: %v", id, err)
+	}
+
+	deadLetterMetrics.Add("replayed", 1)
+	log.Printf("Replayed dead letter %s to %s for %s as %s", id, letter.Stream, letter.Group, messageID)
+	return &models.DeadLetterReplayResponse{
+		ID:        id,
+		Stream:    letter.Stream,
+		Group:     letter.Group,
+		MessageID: messageID,
+	}, nil
+}
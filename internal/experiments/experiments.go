@@ -0,0 +1,240 @@
+package experiments
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sort"
+	"strconv"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/database"
+)
+
+// Exposure counts since start, keyed "{experiment}:{variant}" (Redis keeps the totals across instances)
+var exposureMetrics = expvar.NewMap("experiment_exposures")
+
+// Experiments with built-in strategies. Other keys are bucketed and tagged but have no effect.
+const (
+	// Ranking overrides the "recommended" sort weights with the weight_price,
+	// weight_duration, and weight_stops params
+	Ranking = "search_ranking"
+	// Pricing scales fares in search and validation by the price_multiplier param
+	Pricing = "search_pricing"
+)
+
+// Variant is one arm of an experiment
+type Variant struct {
+	Name   string             `json:"name"`
+	Weight int                `json:"weight"` // Relative share of users
+	Params map[string]float64 `json:"params,omitempty"`
+}
+
+// Experiment splits users deterministically across weighted variants
+type Experiment struct {
+	Key      string    `json:"key"`
+	Variants []Variant `json:"variants"`
+}
+
+// validate checks that the experiment has a key and positively weighted, uniquely named variants
+func (e *Experiment) validate() error {
+	if e.Key == "" {
+		return fmt.Errorf("experiment key is required")
+	}
+	if len(e.Variants) == 0 {
+		return fmt.Errorf("experiment %s has no variants", e.Key)
+	}
+	names := make(map[string]bool)
+	for _, variant := range e.Variants {
+		if variant.Name == "" || variant.Weight <= 0 {
+			return fmt.Errorf("experiment %s has a variant without a name or positive weight", e.Key)
+		}
+		if names[variant.Name] {
+			return fmt.Errorf("experiment %s has duplicate variant %s", e.Key, variant.Name)
+		}
+		names[variant.Name] = true
+	}
+	return nil
+}
+
+// Assignment is the variant a user is bucketed into for one experiment
+type Assignment struct {
+	Experiment string
+	Variant    string
+	Params     map[string]float64
+}
+
+// Assignments holds a user's variants by experiment key
+type Assignments map[string]Assignment
+
+// Param returns a variant param for an experiment, and whether the user has it
+func (a Assignments) Param(experiment, name string) (float64, bool) {
+	assignment, ok := a[experiment]
+	if !ok {
+		return 0, false
+	}
+	value, ok := assignment.Params[name]
+	return value, ok
+}
+
+// Tags returns the variant names by experiment key, for tagging responses and events
+func (a Assignments) Tags() map[string]string {
+	if len(a) == 0 {
+		return nil
+	}
+	tags := make(map[string]string, len(a))
+	for key, assignment := range a {
+		tags[key] = assignment.Variant
+	}
+	return tags
+}
+
+// Registry holds the configured experiments and records exposures
+type Registry struct {
+	experiments []Experiment
+	cache       *database.RedisClient
+}
+
+// LoadRegistry loads experiments from the EXPERIMENTS environment variable, a JSON array of
+// {"key", "variants": [{"name", "weight", "params"}]}. With none configured every user is untagged.
+func LoadRegistry(cache *database.RedisClient) (*Registry, error) {
+	var experiments []Experiment
+	if raw := config.GetEnv("EXPERIMENTS", ""); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &experiments); err != nil {
+			return nil, fmt.Errorf("failed to parse EXPERIMENTS: %w", err)
+		}
+	}
+
+	keys := make(map[string]bool)
+	for i := range experiments {
+		if err := experiments[i].validate(); err != nil {
+			return nil, err
+		}
+		if keys[experiments[i].Key] {
+			return nil, fmt.Errorf("duplicate experiment %s", experiments[i].Key)
+		}
+		keys[experiments[i].Key] = true
+	}
+
+	return &Registry{
+		experiments: experiments,
+		cache:       cache,
+	}, nil
+}
+
+// Assign buckets a user into every experiment. The bucket is a hash of the experiment key
+// and user ID, so a user keeps their variant across requests, instances, and services, and
+// buckets are independent between experiments. Anonymous users (ID 0) are not assigned.
+func (r *Registry) Assign(userID int) Assignments {
+	if userID <= 0 || len(r.experiments) == 0 {
+		return nil
+	}
+
+	assignments := make(Assignments, len(r.experiments))
+	for _, experiment := range r.experiments {
+		variant := pick(experiment, userID)
+		assignments[experiment.Key] = Assignment{
+			Experiment: experiment.Key,
+			Variant:    variant.Name,
+			Params:     variant.Params,
+		}
+	}
+	return assignments
+}
+
+// pick chooses the user's variant by walking cumulative weights
+func pick(experiment Experiment, userID int) Variant {
+	total := 0
+	for _, variant := range experiment.Variants {
+		total += variant.Weight
+	}
+
+	point := int(bucket(experiment.Key, userID) % uint32(total))
+	for _, variant := range experiment.Variants {
+		if point < variant.Weight {
+			return variant
+		}
+		point -= variant.Weight
+	}
+	return experiment.Variants[len(experiment.Variants)-1]
+}
+
+// bucket hashes an experiment key and user ID
+func bucket(key string, userID int) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key + ":" + strconv.Itoa(userID)))
+	return h.Sum32()
+}
+
+// RecordExposures counts that a user was shown each of their variants. Failures are
+// logged rather than returned so analysis never affects serving.
+func (r *Registry) RecordExposures(ctx context.Context, assignments Assignments) {
+	if len(assignments) == 0 {
+		return
+	}
+
+	pipe := r.cache.Pipeline()
+	for key, assignment := range assignments {
+		exposureMetrics.Add(key+":"+assignment.Variant, 1)
+		pipe.HIncrBy(ctx, database.GenerateExperimentExposureKey(key), assignment.Variant, 1)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to record experiment exposures: %v", err)
+	}
+}
+
+// VariantStats is a variant's configuration and exposure count
+type VariantStats struct {
+	Variant
+	Exposures int64 `json:"exposures"`
+}
+
+// ExperimentStats reports exposures per variant for one experiment
+type ExperimentStats struct {
+	Key      string         `json:"key"`
+	Variants []VariantStats `json:"variants"`
+	Total    int64          `json:"total_exposures"`
+}
+
+// Stats returns the configured experiments with their exposure counts across all instances
+func (r *Registry) Stats(ctx context.Context) ([]ExperimentStats, error) {
+	stats := make([]ExperimentStats, 0, len(r.experiments))
+	for _, experiment := range r.experiments {
+		counts, err := r.cache.HGetAll(ctx, database.GenerateExperimentExposureKey(experiment.Key)).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read exposures for %s: %w", experiment.Key, err)
+		}
+
+		entry := ExperimentStats{Key: experiment.Key}
+		for _, variant := range experiment.Variants {
+			exposures, _ := strconv.ParseInt(counts[variant.Name], 10, 64)
+			entry.Variants = append(entry.Variants, VariantStats{Variant: variant, Exposures: exposures})
+			entry.Total += exposures
+		}
+		stats = append(stats, entry)
+	}
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Key < stats[j].Key })
+	return stats, nil
+}
+
+// assignmentsKey carries a request's assignments in its context
+type assignmentsKey struct{}
+
+// WithAssignments returns a context carrying the user's assignments, so strategies and
+// published events downstream can read them
+func WithAssignments(ctx context.Context, assignments Assignments) context.Context {
+	if len(assignments) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, assignmentsKey{}, assignments)
+}
+
+// FromContext returns the assignments carried by ctx, if any
+func FromContext(ctx context.Context) Assignments {
+	assignments, _ := ctx.Value(assignmentsKey{}).(Assignments)
+	return assignments
+}
@@ -0,0 +1,226 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"time"
+
+	"cred_flights_booking/pkg/models"
+)
+
+// FormatNDC is the export format name for the NDC OrderViewRS-like structure
+const FormatNDC = "ndc"
+
+// ndcVersion is the NDC schema version the export loosely follows
+const ndcVersion = "21.3"
+
+// OrderViewRS is a simplified NDC OrderViewRS describing one booking as an order
+type OrderViewRS struct {
+	XMLName   xml.Name    `xml:"OrderViewRS" json:"-"`
+	Version   string      `xml:"Version,attr" json:"version"`
+	Timestamp time.Time   `xml:"Timestamp,attr" json:"timestamp"`
+	Response  NDCResponse `xml:"Response" json:"response"`
+}
+
+// NDCResponse holds the order and the data lists it references
+type NDCResponse struct {
+	DataLists NDCDataLists `xml:"DataLists" json:"data_lists"`
+	Order     NDCOrder     `xml:"Order" json:"order"`
+}
+
+// NDCDataLists holds the passengers, segments, and contacts referenced by order items
+type NDCDataLists struct {
+	ContactInfoList []NDCContactInfo `xml:"ContactInfoList>ContactInfo,omitempty" json:"contact_info_list,omitempty"`
+	PaxList         []NDCPax         `xml:"PaxList>Pax" json:"pax_list"`
+	PaxSegmentList  []NDCPaxSegment  `xml:"PaxSegmentList>PaxSegment" json:"pax_segment_list"`
+}
+
+// NDCContactInfo is a passenger contact
+type NDCContactInfo struct {
+	ContactInfoID string           `xml:"ContactInfoID" json:"contact_info_id"`
+	EmailAddress  *NDCEmailAddress `xml:"EmailAddress,omitempty" json:"email_address,omitempty"`
+	Phone         *NDCPhone        `xml:"Phone,omitempty" json:"phone,omitempty"`
+}
+
+// NDCEmailAddress is a contact email
+type NDCEmailAddress struct {
+	EmailAddressText string `xml:"EmailAddressText" json:"email_address_text"`
+}
+
+// NDCPhone is a contact phone number
+type NDCPhone struct {
+	PhoneNumber string `xml:"PhoneNumber" json:"phone_number"`
+}
+
+// NDCPax is a passenger; bookings do not carry passenger names, so passengers are anonymous adults
+type NDCPax struct {
+	PaxID            string `xml:"PaxID" json:"pax_id"`
+	PTC              string `xml:"PTC" json:"ptc"`
+	ContactInfoRefID string `xml:"ContactInfoRefID,omitempty" json:"contact_info_ref_id,omitempty"`
+}
+
+// NDCPaxSegment is a flown segment
+type NDCPaxSegment struct {
+	PaxSegmentID         string            `xml:"PaxSegmentID" json:"pax_segment_id"`
+	Dep                  NDCTransportPoint `xml:"Dep" json:"dep"`
+	Arrival              NDCTransportPoint `xml:"Arrival" json:"arrival"`
+	MarketingCarrierInfo NDCCarrierInfo    `xml:"MarketingCarrierInfo" json:"marketing_carrier_info"`
+	Duration             string            `xml:"Duration" json:"duration"` // ISO 8601 duration
+}
+
+// NDCTransportPoint is a segment endpoint
+type NDCTransportPoint struct {
+	IATALocationCode          string `xml:"IATALocationCode" json:"iata_location_code"`
+	AircraftScheduledDateTime string `xml:"AircraftScheduledDateTime" json:"aircraft_scheduled_date_time"`
+}
+
+// NDCCarrierInfo identifies the marketing carrier and flight number
+type NDCCarrierInfo struct {
+	CarrierDesigCode                 string `xml:"CarrierDesigCode" json:"carrier_desig_code"`
+	MarketingCarrierFlightNumberText string `xml:"MarketingCarrierFlightNumberText" json:"marketing_carrier_flight_number_text"`
+}
+
+// NDCOrder is the booking as an order
+type NDCOrder struct {
+	OrderID          string         `xml:"OrderID" json:"order_id"`
+	OwnerCode        string         `xml:"OwnerCode" json:"owner_code"`
+	StatusCode       string         `xml:"StatusCode" json:"status_code"`
+	CreationDateTime time.Time      `xml:"CreationDateTime" json:"creation_date_time"`
+	TotalPrice       NDCPrice       `xml:"TotalPrice" json:"total_price"`
+	OrderItems       []NDCOrderItem `xml:"OrderItem" json:"order_items"`
+	PaymentRef       string         `xml:"PaymentInfo>PaymentRefID,omitempty" json:"payment_ref_id,omitempty"`
+}
+
+// NDCPrice is an amount in a currency
+type NDCPrice struct {
+	TotalAmount NDCAmount `xml:"TotalAmount" json:"total_amount"`
+}
+
+// NDCAmount is a decimal amount with its ISO 4217 currency code
+type NDCAmount struct {
+	CurCode string `xml:"CurCode,attr" json:"cur_code"`
+	Value   string `xml:",chardata" json:"value"`
+}
+
+// NDCOrderItem is one priced item of the order: a fare on a segment for every passenger
+type NDCOrderItem struct {
+	OrderItemID string       `xml:"OrderItemID" json:"order_item_id"`
+	FareCode    string       `xml:"FareDetail>FareComponent>FareBasisCode" json:"fare_basis_code"`
+	Price       NDCPrice     `xml:"Price" json:"price"`
+	Services    []NDCService `xml:"Service" json:"services"`
+}
+
+// NDCService links a passenger to a segment within an order item
+type NDCService struct {
+	ServiceID       string `xml:"ServiceID" json:"service_id"`
+	PaxRefID        string `xml:"PaxRefID" json:"pax_ref_id"`
+	PaxSegmentRefID string `xml:"ServiceAssociations>PaxSegmentRefID" json:"pax_segment_ref_id"`
+}
+
+// NewOrderView renders a booking and its segments as an OrderViewRS, with amounts in currency
+func NewOrderView(booking *models.Booking, segments []models.BookingSegment, currency string, now time.Time) *OrderViewRS {
+	orderID := fmt.Sprintf("ORD%d", booking.ID)
+	view := &OrderViewRS{
+		Version:   ndcVersion,
+		Timestamp: now.UTC(),
+	}
+
+	var contactRef string
+	if booking.Email != "" || booking.Phone != "" {
+		contactRef = "CI1"
+		contact := NDCContactInfo{ContactInfoID: contactRef}
+		if booking.Email != "" {
+			contact.EmailAddress = &NDCEmailAddress{EmailAddressText: booking.Email}
+		}
+		if booking.Phone != "" {
+			contact.Phone = &NDCPhone{PhoneNumber: booking.Phone}
+		}
+		view.Response.DataLists.ContactInfoList = []NDCContactInfo{contact}
+	}
+
+	for i := 1; i <= booking.Seats; i++ {
+		view.Response.DataLists.PaxList = append(view.Response.DataLists.PaxList, NDCPax{
+			PaxID:            fmt.Sprintf("PAX%d", i),
+			PTC:              "ADT",
+			ContactInfoRefID: contactRef,
+		})
+	}
+
+	order := NDCOrder{
+		OrderID:          orderID,
+		StatusCode:       ndcStatus(booking.Status),
+		CreationDateTime: booking.CreatedAt.UTC(),
+		TotalPrice:       NDCPrice{TotalAmount: ndcAmount(booking.TotalAmount, currency)},
+		PaymentRef:       booking.PaymentID,
+	}
+
+	for i, segment := range segments {
+		segmentID := fmt.Sprintf("SEG%d", i+1)
+		carrier := (&models.Flight{FlightNumber: segment.FlightNumber}).AirlineCode()
+		if order.OwnerCode == "" {
+			order.OwnerCode = carrier
+		}
+
+		view.Response.DataLists.PaxSegmentList = append(view.Response.DataLists.PaxSegmentList, NDCPaxSegment{
+			PaxSegmentID: segmentID,
+			Dep: NDCTransportPoint{
+				IATALocationCode:          segment.Source,
+				AircraftScheduledDateTime: segment.DepartureTime.Format("2006-01-02T15:04:05"),
+			},
+			Arrival: NDCTransportPoint{
+				IATALocationCode:          segment.Destination,
+				AircraftScheduledDateTime: segment.ArrivalTime.Format("2006-01-02T15:04:05"),
+			},
+			MarketingCarrierInfo: NDCCarrierInfo{
+				CarrierDesigCode:                 carrier,
+				MarketingCarrierFlightNumberText: segment.FlightNumber[len(carrier):],
+			},
+			Duration: isoDuration(segment.ArrivalTime.Sub(segment.DepartureTime)),
+		})
+
+		item := NDCOrderItem{
+			OrderItemID: fmt.Sprintf("%s-ITEM%d", orderID, i+1),
+			FareCode:    booking.FareCode,
+			Price:       NDCPrice{TotalAmount: ndcAmount(segment.Price.Mul(booking.Seats), currency)},
+		}
+		for _, pax := range view.Response.DataLists.PaxList {
+			item.Services = append(item.Services, NDCService{
+				ServiceID:       fmt.Sprintf("SRV-%s-%s", segmentID, pax.PaxID),
+				PaxRefID:        pax.PaxID,
+				PaxSegmentRefID: segmentID,
+			})
+		}
+		order.OrderItems = append(order.OrderItems, item)
+	}
+
+	view.Response.Order = order
+	return view
+}
+
+// ndcStatus maps a booking status to an NDC order status code
+func ndcStatus(status string) string {
+	switch status {
+	case models.BookingStatusConfirmed:
+		return "OPENED"
+	case models.BookingStatusCancelled:
+		return "CANCELLED"
+	case models.BookingStatusCompleted:
+		return "CLOSED"
+	default:
+		return "PENDING"
+	}
+}
+
+// ndcAmount formats an amount with two decimals
+func ndcAmount(amount models.Money, currency string) NDCAmount {
+	return NDCAmount{CurCode: currency, Value: amount.String()}
+}
+
+// isoDuration formats a duration as ISO 8601 (e.g. PT2H30M)
+func isoDuration(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	minutes := int(d.Minutes())
+	return fmt.Sprintf("PT%dH%dM", minutes/60, minutes%60)
+}
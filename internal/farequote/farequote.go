@@ -0,0 +1,144 @@
+package farequote
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/pkg/models"
+)
+
+var (
+	// ErrMissingQuote is returned when a payment carries no fare quote
+	ErrMissingQuote = errors.New("missing fare quote")
+	// ErrInvalidQuote is returned when a quote's signature matches no known secret
+	ErrInvalidQuote = errors.New("invalid fare quote signature")
+	// ErrQuoteExpired is returned when a quote is past its expiry
+	ErrQuoteExpired = errors.New("fare quote expired")
+	// ErrNoSecrets is returned when FARE_QUOTE_SECRETS is unset outside insecure dev mode
+	ErrNoSecrets = errors.New("FARE_QUOTE_SECRETS is not set")
+)
+
+// Config holds the secrets fare quotes are signed with and how long quotes are honoured
+type Config struct {
+	Secrets []string      // The first signs new quotes; all are accepted so secrets can rotate
+	TTL     time.Duration // How long a quote can be paid against
+	// InsecureDev lets services run without secrets, accepting any payment amount. Local
+	// development only.
+	InsecureDev bool
+}
+
+// LoadConfig loads fare quote settings from the environment
+func LoadConfig() Config {
+	return Config{
+		Secrets:     config.GetList("FARE_QUOTE_SECRETS", nil),
+		TTL:         config.GetDuration("FARE_QUOTE_TTL", 20*time.Minute),
+		InsecureDev: config.GetBool("FARE_QUOTE_INSECURE_DEV", false),
+	}
+}
+
+// Validate checks that quotes can be signed and verified; without secrets, only
+// FARE_QUOTE_INSECURE_DEV lets a service start
+func (c Config) Validate() error {
+	if len(c.Secrets) > 0 {
+		return nil
+	}
+	if !c.InsecureDev {
+		return ErrNoSecrets
+	}
+	log.Printf("WARNING: FARE_QUOTE_SECRETS is not set and FARE_QUOTE_INSECURE_DEV is on; payment amounts are not verified")
+	return nil
+}
+
+// Signer signs fare quotes in flight-service and verifies them in payment-service
+type Signer struct {
+	secrets     []string
+	ttl         time.Duration
+	insecureDev bool
+}
+
+// NewSigner creates a signer; without secrets, quotes are neither signed nor accepted
+// unless the config is in insecure dev mode
+func NewSigner(cfg Config) *Signer {
+	return &Signer{
+		secrets:     cfg.Secrets,
+		ttl:         cfg.TTL,
+		insecureDev: cfg.InsecureDev,
+	}
+}
+
+// Enabled reports whether quotes are signed and required
+func (s *Signer) Enabled() bool {
+	return len(s.secrets) > 0
+}
+
+// Optional reports whether payments may go through without a quote: only without
+// secrets in insecure dev mode
+func (s *Signer) Optional() bool {
+	return !s.Enabled() && s.insecureDev
+}
+
+// quoteCurrency returns the currency a quote's amount is in; an empty one is the default
+func quoteCurrency(amount models.Money) string {
+	if amount.Currency == "" {
+		return models.DefaultCurrency
+	}
+	return amount.Currency
+}
+
+// computeSignature returns the hex HMAC-SHA256 of a quote's fields
+func computeSignature(secret string, quote *models.FareQuote) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d|%s|%d|%d|%d|%s|%d", quote.FlightID, quote.Date, quote.Seats, quote.UserID,
+		quote.Amount.Minor, quoteCurrency(quote.Amount), quote.ExpiresAt.Unix())
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign returns a quote for a booking's total, or nil when signing is disabled
+func (s *Signer) Sign(flightID int, date string, seats, userID int, amount models.Money) *models.FareQuote {
+	if !s.Enabled() {
+		return nil
+	}
+
+	quote := &models.FareQuote{
+		FlightID:  flightID,
+		Date:      date,
+		Seats:     seats,
+		UserID:    userID,
+		Amount:    amount,
+		ExpiresAt: time.Now().Add(s.ttl).UTC().Truncate(time.Second),
+	}
+	quote.Signature = computeSignature(s.secrets[0], quote)
+	return quote
+}
+
+// Verify checks a quote's signature against every known secret and its expiry
+func (s *Signer) Verify(quote *models.FareQuote) error {
+	if !s.Enabled() {
+		return ErrNoSecrets
+	}
+	if quote == nil || quote.Signature == "" {
+		return ErrMissingQuote
+	}
+
+	valid := false
+	for _, secret := range s.secrets {
+		if hmac.Equal([]byte(computeSignature(secret, quote)), []byte(quote.Signature)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return ErrInvalidQuote
+	}
+
+	if time.Now().After(quote.ExpiresAt) {
+		return ErrQuoteExpired
+	}
+	return nil
+}
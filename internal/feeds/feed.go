@@ -0,0 +1,61 @@
+package feeds
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/pkg/models"
+)
+
+// Fetcher retrieves the current snapshot of an external schedule/availability feed
+type Fetcher interface {
+	Fetch(ctx context.Context) ([]models.FeedFlight, error)
+}
+
+// Config holds the external flight feed settings
+type Config struct {
+	Source        string        // Name the feed's flights are recorded under, e.g. "amadeus"
+	File          string        // Path of a JSON feed file read by FileFetcher; empty disables the feed
+	Interval      time.Duration // How often the feed is synced
+	CancelMissing bool          // Cancel feed flights in the snapshot's date range that it no longer lists
+}
+
+// LoadConfig loads the flight feed settings from the environment
+func LoadConfig() Config {
+	return Config{
+		Source:        config.GetEnv("FLIGHT_FEED_SOURCE", "gds"),
+		File:          config.GetEnv("FLIGHT_FEED_FILE", ""),
+		Interval:      config.GetDuration("FLIGHT_FEED_INTERVAL", 15*time.Minute),
+		CancelMissing: config.GetBool("FLIGHT_FEED_CANCEL_MISSING", true),
+	}
+}
+
+// FileFetcher reads a feed snapshot from a JSON file holding an array of flight records,
+// e.g. one dropped by an SFTP transfer or a vendor export
+type FileFetcher struct {
+	path string
+}
+
+// NewFileFetcher creates a fetcher for a feed file
+func NewFileFetcher(path string) *FileFetcher {
+	return &FileFetcher{path: path}
+}
+
+// Fetch reads and decodes the feed file
+func (f *FileFetcher) Fetch(ctx context.Context) ([]models.FeedFlight, error) {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open feed file: %w", err)
+	}
+	defer file.Close()
+
+	var records []models.FeedFlight
+	if err := json.NewDecoder(file).Decode(&records); err != nil {
+		return nil, fmt.Errorf("failed to decode feed file %s: %w", f.path, err)
+	}
+	return records, nil
+}
@@ -0,0 +1,102 @@
+package funnel
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/internal/tracing"
+	"github.com/go-redis/redis/v8"
+)
+
+// Funnel stages, in order
+const (
+	StageSearch         = "search"          // Flight search (flight-service)
+	StageSelection      = "selection"       // Flight details viewed (flight-service)
+	StageBookingAttempt = "booking_attempt" // Booking submitted for a valid flight (booking-service)
+	StagePayment        = "payment"         // Payment succeeded (booking-service)
+	StageConfirmation   = "confirmation"    // Booking confirmed (booking-service)
+)
+
+// Stages lists the funnel stages in order
+var Stages = []string{StageSearch, StageSelection, StageBookingAttempt, StagePayment, StageConfirmation}
+
+// Route formats a route as used in funnel keys and reports, e.g. "DEL-BOM". It returns ""
+// unless both are 3-letter airport codes, so malformed searches are not tracked.
+func Route(source, destination string) string {
+	source, destination = strings.ToUpper(source), strings.ToUpper(destination)
+	if len(source) != 3 || len(destination) != 3 {
+		return ""
+	}
+	return source + "-" + destination
+}
+
+// Tracker records funnel events. Each stage counts distinct sessions per route and UTC day
+// in Redis HyperLogLogs, so retries and repeated searches within a session count once.
+type Tracker struct {
+	cache *database.RedisClient
+	ttl   time.Duration
+}
+
+// NewTracker creates a funnel tracker
+func NewTracker(cache *database.RedisClient) *Tracker {
+	return &Tracker{
+		cache: cache,
+		ttl:   config.GetDuration("FUNNEL_KEY_TTL", 8*24*time.Hour),
+	}
+}
+
+// Track records that the request's session reached a stage on a route. The event is logged
+// as a FUNNEL line; recording failures are logged and never fail the request.
+func (t *Tracker) Track(ctx context.Context, stage, route string) {
+	sessionID := tracing.SessionID(ctx)
+	if sessionID == "" || route == "" {
+		return
+	}
+
+	now := time.Now().UTC()
+	log.Printf("FUNNEL stage=%s route=%s session_id=%s trace_id=%s", stage, route, sessionID, tracing.TraceID(ctx))
+
+	day := now.Format("2006-01-02")
+	stageKey := database.GenerateFunnelStageKey(day, route, stage)
+	routesKey := database.GenerateFunnelRoutesKey(day)
+
+	pipe := t.cache.Pipeline()
+	pipe.PFAdd(ctx, stageKey, sessionID)
+	pipe.Expire(ctx, stageKey, t.ttl)
+	pipe.SAdd(ctx, routesKey, route)
+	pipe.Expire(ctx, routesKey, t.ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to record funnel %s for %s: %v", stage, route, err)
+	}
+}
+
+// Counts returns the distinct sessions reaching each stage, by route, for a UTC day
+func (t *Tracker) Counts(ctx context.Context, day string) (map[string]map[string]int64, error) {
+	routes, err := t.cache.SMembers(ctx, database.GenerateFunnelRoutesKey(day)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list funnel routes: %w", err)
+	}
+
+	counts := make(map[string]map[string]int64, len(routes))
+	for _, route := range routes {
+		pipe := t.cache.Pipeline()
+		results := make(map[string]*redis.IntCmd, len(Stages))
+		for _, stage := range Stages {
+			results[stage] = pipe.PFCount(ctx, database.GenerateFunnelStageKey(day, route, stage))
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, fmt.Errorf("failed to count funnel for %s: %w", route, err)
+		}
+
+		counts[route] = make(map[string]int64, len(Stages))
+		for stage, result := range results {
+			counts[route][stage] = result.Val()
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/pkg/models"
+)
+
+// Admin roles, granted per operator with ADMIN_ROLES
+const (
+	RoleViewer  = "viewer"  // Flights and availability
+	RoleOps     = "ops"     // Adds bookings, with contact details masked
+	RoleFinance = "finance" // Adds payment and revenue figures
+	RoleAdmin   = "admin"   // Everything, unmasked
+
+	// RolePrivacy may export and erase users' personal data; no other role, admin included, can
+	RolePrivacy = "privacy"
+)
+
+// warnAdminDisabled logs once that admin requests are refused for lack of tokens
+var warnAdminDisabled sync.Once
+
+// adminIdentity returns the operator an admin request authenticates as. ADMIN_TOKENS lists
+// each operator's own token as "user:token" entries separated by commas; the operator is
+// the one whose token matches X-Admin-Token. An X-Admin-User header naming anyone else is
+// refused. Without ADMIN_TOKENS every admin request is refused.
+func adminIdentity(r *http.Request) (string, bool) {
+	provided := r.Header.Get("X-Admin-Token")
+	if provided == "" {
+		return "", false
+	}
+
+	entries := config.GetList("ADMIN_TOKENS", nil)
+	if len(entries) == 0 {
+		warnAdminDisabled.Do(func() {
+			log.Printf("WARNING: ADMIN_TOKENS is not set; refusing all admin requests")
+		})
+		return "", false
+	}
+
+	// Every entry is compared, so the time taken doesn't reveal which one matched
+	user := ""
+	for _, entry := range entries {
+		name, token, ok := strings.Cut(entry, ":")
+		name, token = strings.TrimSpace(name), strings.TrimSpace(token)
+		if !ok || name == "" || token == "" {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1 {
+			user = name
+		}
+	}
+	if user == "" {
+		return "", false
+	}
+
+	if claimed := r.Header.Get("X-Admin-User"); claimed != "" && !strings.EqualFold(claimed, user) {
+		return "", false
+	}
+	return user, true
+}
+
+// AdminOnly restricts a handler to requests carrying admin credentials. X-Admin-User is
+// set to the authenticated operator, so handlers and audit logs behind it can trust it.
+func AdminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := adminIdentity(r)
+		if !ok {
+			http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+			return
+		}
+		r.Header.Set("X-Admin-User", user)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// adminRoles returns the roles granted to an operator. ADMIN_ROLES lists them as
+// "user:role|role" entries separated by commas; operators not listed, and every operator
+// without ADMIN_ROLES, get ADMIN_DEFAULT_ROLE.
+func adminRoles(user string) map[string]bool {
+	roles := make(map[string]bool)
+	for _, entry := range config.GetList("ADMIN_ROLES", nil) {
+		name, granted, ok := strings.Cut(entry, ":")
+		if !ok || !strings.EqualFold(strings.TrimSpace(name), user) {
+			continue
+		}
+		for _, role := range strings.Split(granted, "|") {
+			if role = strings.TrimSpace(role); role != "" {
+				roles[role] = true
+			}
+		}
+	}
+	if len(roles) == 0 {
+		roles[config.GetEnv("ADMIN_DEFAULT_ROLE", RoleViewer)] = true
+	}
+	return roles
+}
+
+// writeErrorResponse writes the JSON error envelope for errors clients branch on by code
+func writeErrorResponse(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	response := models.ErrorResponse{Error: message, Code: code, Status: status}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode error response: %v", err)
+	}
+}
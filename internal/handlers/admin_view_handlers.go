@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"cred_flights_booking/internal/services"
+	"cred_flights_booking/pkg/models"
+)
+
+// defaultViewBookings and maxViewBookings bound the recent bookings in a flight view
+const (
+	defaultViewBookings = 20
+	maxViewBookings     = 100
+)
+
+// AdminViewHandlers serves consolidated views for the ops dashboard, so it can render a
+// page in one call instead of one per service
+type AdminViewHandlers struct {
+	bookingService *services.BookingServiceV2
+}
+
+// NewAdminViewHandlers creates new admin view handlers
+func NewAdminViewHandlers(bookingService *services.BookingServiceV2) *AdminViewHandlers {
+	return &AdminViewHandlers{
+		bookingService: bookingService,
+	}
+}
+
+// viewSections returns the view sections the roles may see, and whether booking contacts
+// must be masked
+func viewSections(roles map[string]bool) (map[string]bool, bool) {
+	sections := map[string]bool{
+		services.ViewSectionFlight:       true,
+		services.ViewSectionAvailability: true,
+	}
+	if roles[RoleOps] || roles[RoleAdmin] {
+		sections[services.ViewSectionBookings] = true
+	}
+	if roles[RoleFinance] || roles[RoleAdmin] {
+		sections[services.ViewSectionPayments] = true
+	}
+	return sections, !roles[RoleAdmin]
+}
+
+// sortedKeys returns the keys of a set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// GetSession handles requests for the signed-in operator's roles and visible sections
+func (avh *AdminViewHandlers) GetSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := adminIdentity(r)
+	if !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	roles := adminRoles(admin)
+	sections, _ := viewSections(roles)
+	session := models.AdminSession{
+		User:     admin,
+		Roles:    sortedKeys(roles),
+		Sections: sortedKeys(sections),
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(session); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetFlightView handles requests for a flight's consolidated view: flight details, live
+// availability, recent bookings, and payment stats, as far as the operator's roles allow
+func (avh *AdminViewHandlers) GetFlightView(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := adminIdentity(r)
+	if !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	flightID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || flightID <= 0 {
+		http.Error(w, "Invalid flight ID", http.StatusBadRequest)
+		return
+	}
+	date := r.URL.Query().Get("date")
+	if date != "" {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			http.Error(w, "Invalid date parameter, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+	limit := defaultViewBookings
+	if value := r.URL.Query().Get("bookings_limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxViewBookings {
+			http.Error(w, "bookings_limit must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+	}
+
+	roles := adminRoles(admin)
+	sections, mask := viewSections(roles)
+
+	ctx := r.Context()
+
+	view, err := avh.bookingService.FlightView(ctx, flightID, date, services.FlightViewOptions{
+		Sections:     sections,
+		BookingLimit: limit,
+		MaskContacts: mask,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrFlightNotFound) {
+			http.Error(w, "Flight not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Admin flight view error: %v", err)
+		http.Error(w, "Failed to load flight view", http.StatusBadGateway)
+		return
+	}
+	view.Roles = sortedKeys(roles)
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(view); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"cred_flights_booking/internal/services"
+	"cred_flights_booking/pkg/models"
+)
+
+// HeaderAgencyKey carries an agency's API key
+const HeaderAgencyKey = "X-Agency-Key"
+
+// AgencyHandlers handles agency accounts, their bookings, and invoices
+type AgencyHandlers struct {
+	agencyService *services.AgencyService
+}
+
+// NewAgencyHandlers creates new agency handlers
+func NewAgencyHandlers(agencyService *services.AgencyService) *AgencyHandlers {
+	return &AgencyHandlers{
+		agencyService: agencyService,
+	}
+}
+
+// authenticateAgency resolves the agency API key header, writing the error response on failure
+func authenticateAgency(w http.ResponseWriter, r *http.Request, agencyService *services.AgencyService) (*models.Agency, bool) {
+	agency, err := agencyService.Authenticate(r.Context(), r.Header.Get(HeaderAgencyKey))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAPIKey) {
+			http.Error(w, "Invalid or missing agency key", http.StatusUnauthorized)
+			return nil, false
+		}
+		log.Printf("Agency authentication error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return agency, true
+}
+
+// GetAccount handles an agency's request for its credit position
+func (ah *AgencyHandlers) GetAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	agency, ok := authenticateAgency(w, r, ah.agencyService)
+	if !ok {
+		return
+	}
+
+	ah.writeAccount(w, r, agency.ID)
+}
+
+// ListBookings handles an agency's request for the bookings it made
+func (ah *AgencyHandlers) ListBookings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	agency, ok := authenticateAgency(w, r, ah.agencyService)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	limit := 50
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > 200 {
+			http.Error(w, "Invalid limit, expected 1-200", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+	offset := 0
+	if value := query.Get("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+		offset = parsed
+	}
+
+	ctx := r.Context()
+
+	response, err := ah.agencyService.ListBookings(ctx, agency.ID, query.Get("status"), limit, offset)
+	if err != nil {
+		log.Printf("Agency bookings error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to list bookings: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ListInvoices handles an agency's request for its invoices
+func (ah *AgencyHandlers) ListInvoices(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	agency, ok := authenticateAgency(w, r, ah.agencyService)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	invoices, err := ah.agencyService.ListInvoices(ctx, agency.ID)
+	if err != nil {
+		log.Printf("Agency invoices error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to list invoices: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(invoices); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// CreateAgency handles admin requests to register an agency and issue its API key
+func (ah *AgencyHandlers) CreateAgency(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := adminIdentity(r)
+	if !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse request body
+	var req models.AgencyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid agency: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	created, err := ah.agencyService.CreateAgency(ctx, &req)
+	if err != nil {
+		log.Printf("Agency creation error: %v", err)
+		http.Error(w, fmt.Sprintf("Agency creation failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	if err := json.NewEncoder(w).Encode(created); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("AUDIT: agency %d (%s) created by %s with credit limit %.2f", created.ID, created.Name, admin, created.CreditLimit)
+}
+
+// ListAgencies handles admin requests to list agencies
+func (ah *AgencyHandlers) ListAgencies(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := adminIdentity(r); !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := r.Context()
+
+	agencies, err := ah.agencyService.ListAgencies(ctx)
+	if err != nil {
+		log.Printf("Agency listing error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to list agencies: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(agencies); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetAgencyAccount handles admin requests for an agency's credit position
+func (ah *AgencyHandlers) GetAgencyAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := adminIdentity(r); !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	agencyID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || agencyID <= 0 {
+		http.Error(w, "Invalid agency ID", http.StatusBadRequest)
+		return
+	}
+
+	ah.writeAccount(w, r, agencyID)
+}
+
+// MarkInvoicePaid handles admin requests to record an agency's invoice payment
+func (ah *AgencyHandlers) MarkInvoicePaid(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := adminIdentity(r)
+	if !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	agencyID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || agencyID <= 0 {
+		http.Error(w, "Invalid agency ID", http.StatusBadRequest)
+		return
+	}
+	invoiceID, err := strconv.Atoi(r.PathValue("invoice_id"))
+	if err != nil || invoiceID <= 0 {
+		http.Error(w, "Invalid invoice ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	invoice, err := ah.agencyService.MarkInvoicePaid(ctx, agencyID, invoiceID)
+	if err != nil {
+		if errors.Is(err, services.ErrInvoiceNotFound) {
+			http.Error(w, "Open invoice not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Invoice payment error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to mark invoice paid: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(invoice); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("AUDIT: invoice %d of agency %d (%.2f) marked paid by %s", invoiceID, agencyID, invoice.Amount, admin)
+}
+
+// writeAccount writes an agency's credit position
+func (ah *AgencyHandlers) writeAccount(w http.ResponseWriter, r *http.Request, agencyID int) {
+	ctx := r.Context()
+
+	account, err := ah.agencyService.GetAccount(ctx, agencyID)
+	if err != nil {
+		if errors.Is(err, services.ErrAgencyNotFound) {
+			http.Error(w, "Agency not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Agency account error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to get account: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(account); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -3,28 +3,51 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"net/http"
 	"strconv"
-	"time"
+	"strings"
 
-	"cred_flights_booking/internal/models"
+	"cred_flights_booking/internal/export"
 	"cred_flights_booking/internal/services"
+	"cred_flights_booking/pkg/models"
 )
 
+// HeaderQueueTicket carries a queued booking's place in its flight's admission queue
+const HeaderQueueTicket = "X-Queue-Ticket"
+
 // BookingHandlers handles booking-related HTTP requests
 type BookingHandlers struct {
 	bookingService *services.BookingServiceV2
+	agencyService  *services.AgencyService
 }
 
 // NewBookingHandlers creates new booking handlers
-func NewBookingHandlers(bookingService *services.BookingServiceV2) *BookingHandlers {
+func NewBookingHandlers(bookingService *services.BookingServiceV2, agencyService *services.AgencyService) *BookingHandlers {
 	return &BookingHandlers{
 		bookingService: bookingService,
+		agencyService:  agencyService,
 	}
 }
 
+// bookingAgency returns the ID of the agency booking on credit, or 0 when no agency key
+// was sent. It writes the error response when the key is invalid.
+func (bh *BookingHandlers) bookingAgency(w http.ResponseWriter, r *http.Request) (int, bool) {
+	if r.Header.Get(HeaderAgencyKey) == "" {
+		return 0, true
+	}
+	agency, ok := authenticateAgency(w, r, bh.agencyService)
+	if !ok {
+		return 0, false
+	}
+	return agency.ID, true
+}
+
 // CreateBooking handles booking creation requests
 func (bh *BookingHandlers) CreateBooking(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -44,19 +67,74 @@ func (bh *BookingHandlers) CreateBooking(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Invalid user ID, flight ID, seats, or date", http.StatusBadRequest)
 		return
 	}
+	if req.IdempotencyKey == "" {
+		req.IdempotencyKey = r.Header.Get("Idempotency-Key")
+	}
+	if err := req.ValidateIdempotencyKey(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := req.ValidateContact(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := req.ValidatePassengers(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second) // Longer timeout for booking
-	defer cancel()
+	// Agencies book on credit instead of card payment
+	agencyID, ok := bh.bookingAgency(w, r)
+	if !ok {
+		return
+	}
+	req.AgencyID = agencyID
+
+	ctx := r.Context()
+
+	// Bookings for a busy flight date wait their turn instead of all reaching the seat
+	// reservation and payment at once
+	if req.QueueTicket == "" {
+		req.QueueTicket = r.Header.Get(HeaderQueueTicket)
+	}
+	admission, err := bh.bookingService.AdmitBooking(ctx, &req)
+	if err != nil {
+		if errors.Is(err, services.ErrBookingQueueFull) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(admission.RetryAfter.Seconds()))))
+			writeErrorResponse(w, http.StatusServiceUnavailable, models.ErrorCodeOverloaded, "Too many bookings for this flight, try again later")
+			return
+		}
+		if errors.Is(err, services.ErrQueueTicketUsed) {
+			http.Error(w, "Queue ticket was already admitted", http.StatusConflict)
+			return
+		}
+		log.Printf("Booking admission error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !admission.Admitted {
+		writeQueuedResponse(w, admission)
+		return
+	}
+	defer bh.bookingService.ReleaseBooking(context.WithoutCancel(ctx), &req, admission)
 
 	// Create booking
 	response, err := bh.bookingService.CreateBooking(ctx, &req)
 	if err != nil {
+		if errors.Is(err, models.ErrDuplicatePassenger) {
+			writeErrorResponse(w, http.StatusConflict, models.ErrorCodeDuplicatePassenger, err.Error())
+			return
+		}
 		log.Printf("Booking creation error: %v", err)
 		http.Error(w, fmt.Sprintf("Booking failed: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	// Step timings are only for internal callers debugging latency
+	if !wantsDebugTimings(r) {
+		response.DebugTimings = nil
+	}
+
 	// Return response
 	w.Header().Set("Content-Type", "application/json")
 
@@ -77,6 +155,150 @@ func (bh *BookingHandlers) CreateBooking(w http.ResponseWriter, r *http.Request)
 	log.Printf("Booking creation completed: ID=%d, Status=%s", response.BookingID, response.Status)
 }
 
+// CreateManualBooking handles an operator's booking of a sale paid outside the payment
+// service, such as a phone or counter sale paid in cash or by bank transfer
+func (bh *BookingHandlers) CreateManualBooking(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := adminIdentity(r)
+	if !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse request body
+	var req models.ManualBookingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	response, err := bh.bookingService.CreateManualBooking(ctx, &req, admin)
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicatePassenger) {
+			writeErrorResponse(w, http.StatusConflict, models.ErrorCodeDuplicatePassenger, err.Error())
+			return
+		}
+		log.Printf("Manual booking creation error: %v", err)
+		http.Error(w, fmt.Sprintf("Booking failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+
+	statusCode := http.StatusOK
+	if response.Status == models.BookingStatusFailed {
+		statusCode = http.StatusBadRequest
+	}
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// writeQueuedResponse tells a client its booking is waiting in the flight's admission queue
+func writeQueuedResponse(w http.ResponseWriter, admission *services.BookingAdmission) {
+	retryAfter := int(math.Ceil(admission.RetryAfter.Seconds()))
+	response := models.BookingResponse{
+		Status:            models.BookingStatusQueued,
+		Message:           fmt.Sprintf("Booking queued at position %d, resubmit with the queue ticket", admission.Position),
+		QueueTicket:       admission.Ticket,
+		QueuePosition:     admission.Position,
+		RetryAfterSeconds: retryAfter,
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.Header().Set(HeaderQueueTicket, admission.Ticket)
+	w.WriteHeader(http.StatusAccepted)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// CreateBookings handles batch booking requests from agents and corporate accounts
+func (bh *BookingHandlers) CreateBookings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse request body
+	var req models.BatchBookingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate request; individual items are validated by the service and reported per item
+	maxBookings := services.MaxBatchBookings()
+	if len(req.Bookings) == 0 || len(req.Bookings) > maxBookings {
+		http.Error(w, fmt.Sprintf("Between 1 and %d bookings are required", maxBookings), http.StatusBadRequest)
+		return
+	}
+
+	agencyID, ok := bh.bookingAgency(w, r)
+	if !ok {
+		return
+	}
+	for i := range req.Bookings {
+		req.Bookings[i].AgencyID = agencyID
+	}
+
+	ctx := r.Context()
+
+	response, err := bh.bookingService.CreateBookings(ctx, &req)
+	if err != nil {
+		log.Printf("Batch booking error: %v", err)
+		http.Error(w, fmt.Sprintf("Batch booking failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+
+	// 200 when every booking is confirmed, 409 when an atomic batch was undone,
+	// and 207 when a non-atomic batch has mixed results
+	statusCode := http.StatusOK
+	switch {
+	case response.Failed == 0 && response.Pending == 0:
+	case req.Atomic:
+		statusCode = http.StatusConflict
+	default:
+		statusCode = http.StatusMultiStatus
+	}
+
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// wantsDebugTimings reports whether an internal caller asked for the booking step breakdown
+func wantsDebugTimings(r *http.Request) bool {
+	if r.Header.Get("X-Debug-Timings") != "true" {
+		return false
+	}
+	_, ok := adminIdentity(r)
+	return ok
+}
+
 // GetBooking handles getting booking details
 func (bh *BookingHandlers) GetBooking(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -97,9 +319,7 @@ func (bh *BookingHandlers) GetBooking(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
-	defer cancel()
+	ctx := r.Context()
 
 	// Get booking
 	booking, err := bh.bookingService.GetBooking(ctx, bookingID)
@@ -109,6 +329,13 @@ func (bh *BookingHandlers) GetBooking(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Optionally embed the flight details
+	for _, expand := range strings.Split(r.URL.Query().Get("expand"), ",") {
+		if strings.TrimSpace(expand) == "flight" {
+			bh.bookingService.AttachFlight(ctx, booking)
+		}
+	}
+
 	// Return response
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -142,15 +369,31 @@ func (bh *BookingHandlers) CancelBooking(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
-	defer cancel()
+	// Parse optional request body for partial cancellation
+	var req models.CancelBookingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Seats < 0 {
+		http.Error(w, "Invalid seats", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
 
 	// Cancel booking
-	err = bh.bookingService.CancelBooking(ctx, bookingID)
+	response, err := bh.bookingService.CancelBooking(ctx, bookingID, req.Seats)
 	if err != nil {
 		log.Printf("Cancel booking error: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to cancel booking: %v", err), http.StatusBadRequest)
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, services.ErrBookingNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, models.ErrInvalidTransition):
+			status = http.StatusConflict
+		}
+		http.Error(w, fmt.Sprintf("Failed to cancel booking: %v", err), status)
 		return
 	}
 
@@ -158,17 +401,495 @@ func (bh *BookingHandlers) CancelBooking(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
-	response := map[string]interface{}{
-		"message":      "Booking cancelled successfully",
-		"booking_id":   bookingID,
-		"cancelled_at": time.Now(),
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Booking cancelled: ID=%d, Seats=%d, Refund=%s", bookingID, response.SeatsCancelled, response.RefundAmount)
+}
+
+// ResendConfirmation handles requests to resend a booking's confirmation to its contacts
+func (bh *BookingHandlers) ResendConfirmation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	bookingID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || bookingID <= 0 {
+		http.Error(w, "Invalid booking ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	response, err := bh.bookingService.ResendConfirmation(ctx, bookingID)
+	if err != nil {
+		log.Printf("Resend confirmation error: %v", err)
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrBookingNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrBookingNotConfirmed):
+			status = http.StatusConflict
+		case errors.Is(err, services.ErrNoContactDetails):
+			status = http.StatusUnprocessableEntity
+		case errors.Is(err, services.ErrResendTooSoon):
+			status = http.StatusTooManyRequests
+		}
+		http.Error(w, fmt.Sprintf("Failed to resend confirmation: %v", err), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ExtendHold handles requests for more time to pay for a held booking
+func (bh *BookingHandlers) ExtendHold(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	holdID := r.PathValue("id")
+	if holdID == "" {
+		http.Error(w, "Invalid hold ID", http.StatusBadRequest)
+		return
+	}
+
+	// Parse request body
+	var req models.HoldExtensionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate request
+	if req.UserID <= 0 {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	response, err := bh.bookingService.ExtendHold(ctx, holdID, req.UserID)
+	if err != nil {
+		log.Printf("Hold extension error: %v", err)
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrHoldNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrHoldExtensionLimit), errors.Is(err, services.ErrHoldExtensionConflict):
+			status = http.StatusConflict
+		}
+		http.Error(w, fmt.Sprintf("Failed to extend hold: %v", err), status)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// upgradeErrorStatus maps a cabin upgrade error to its HTTP status
+func upgradeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrBookingNotFound), errors.Is(err, services.ErrOfferNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrUpgradeNotAllowed), errors.Is(err, services.ErrOfferStale),
+		errors.Is(err, services.ErrUpgradeUnavailable):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrUpgradePaymentFailed), errors.Is(err, models.ErrAmountMismatch):
+		return http.StatusPaymentRequired
+	}
+	return http.StatusInternalServerError
+}
+
+// ListUpgradeOffers handles requests for a confirmed booking's cabin upgrade offers
+func (bh *BookingHandlers) ListUpgradeOffers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	bookingID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || bookingID <= 0 {
+		http.Error(w, "Invalid booking ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	response, err := bh.bookingService.ListUpgradeOffers(ctx, bookingID)
+	if err != nil {
+		log.Printf("Upgrade offers error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to list upgrade offers: %v", err), upgradeErrorStatus(err))
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// AcceptUpgradeOffer handles requests to buy a booking's cabin upgrade offer
+func (bh *BookingHandlers) AcceptUpgradeOffer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	bookingID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || bookingID <= 0 {
+		http.Error(w, "Invalid booking ID", http.StatusBadRequest)
+		return
+	}
+
+	offerID := r.PathValue("offer_id")
+	if offerID == "" {
+		http.Error(w, "Invalid offer ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	response, err := bh.bookingService.AcceptUpgradeOffer(ctx, bookingID, offerID)
+	if err != nil {
+		log.Printf("Upgrade acceptance error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to upgrade booking: %v", err), upgradeErrorStatus(err))
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// linkErrorStatus maps a booking link error to its HTTP status
+func linkErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrBookingNotFound), errors.Is(err, services.ErrNotLinked):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrLinkNotAllowed), errors.Is(err, services.ErrAlreadyLinked):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// LinkBooking handles requests to link a booking to a companion's booking on the same flight
+func (bh *BookingHandlers) LinkBooking(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	bookingID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || bookingID <= 0 {
+		http.Error(w, "Invalid booking ID", http.StatusBadRequest)
+		return
+	}
+
+	// Parse request body
+	var req models.BookingLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.BookingID <= 0 {
+		http.Error(w, "Invalid linked booking ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	response, err := bh.bookingService.LinkBookings(ctx, bookingID, req.BookingID)
+	if err != nil {
+		log.Printf("Booking link error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to link bookings: %v", err), linkErrorStatus(err))
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// UnlinkBooking handles requests to remove a booking from its linked group
+func (bh *BookingHandlers) UnlinkBooking(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	bookingID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || bookingID <= 0 {
+		http.Error(w, "Invalid booking ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := bh.bookingService.UnlinkBooking(ctx, bookingID); err != nil {
+		log.Printf("Booking unlink error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to unlink booking: %v", err), linkErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetItinerary handles requests for the shared itinerary of a booking and its linked bookings
+func (bh *BookingHandlers) GetItinerary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	bookingID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || bookingID <= 0 {
+		http.Error(w, "Invalid booking ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	response, err := bh.bookingService.GetItinerary(ctx, bookingID)
+	if err != nil {
+		log.Printf("Itinerary error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to get itinerary: %v", err), linkErrorStatus(err))
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ListUserBookings handles requests for a user's bookings, optionally filtered by status
+func (bh *BookingHandlers) ListUserBookings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || userID <= 0 {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
 	}
 
+	query := r.URL.Query()
+	limit := 50
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > 200 {
+			http.Error(w, "Invalid limit, expected 1-200", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+	offset := 0
+	if value := query.Get("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+		offset = parsed
+	}
+
+	ctx := r.Context()
+
+	response, err := bh.bookingService.ListUserBookings(ctx, userID, query.Get("status"), limit, offset)
+	if err != nil {
+		log.Printf("User bookings error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to list bookings: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		log.Printf("Failed to encode response: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+}
 
-	log.Printf("Booking cancelled: ID=%d", bookingID)
+// GetLoyaltyBalance handles requests for a user's loyalty points earned on flown bookings
+func (bh *BookingHandlers) GetLoyaltyBalance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || userID <= 0 {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	balance, err := bh.bookingService.GetLoyaltyBalance(ctx, userID)
+	if err != nil {
+		log.Printf("Get loyalty balance error: %v", err)
+		http.Error(w, "Failed to get loyalty balance", http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(balance); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ExportBooking handles requests to export a confirmed booking in an industry format.
+// The NDC export is XML unless the client accepts application/json.
+func (bh *BookingHandlers) ExportBooking(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	bookingID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || bookingID <= 0 {
+		http.Error(w, "Invalid booking ID", http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = export.FormatNDC
+	}
+	if format != export.FormatNDC {
+		http.Error(w, "Invalid format parameter. Must be 'ndc'", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	view, err := bh.bookingService.ExportOrderView(ctx, bookingID)
+	if err != nil {
+		log.Printf("Export booking error: %v", err)
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrBookingNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrBookingNotConfirmed):
+			status = http.StatusConflict
+		}
+		http.Error(w, fmt.Sprintf("Failed to export booking: %v", err), status)
+		return
+	}
+
+	// Return response
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(view); err != nil {
+			log.Printf("Failed to encode response: %v", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(view); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// GetConfirmedSeats handles requests for the confirmed seat total of a flight date
+func (bh *BookingHandlers) GetConfirmedSeats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flightID, err := strconv.Atoi(r.URL.Query().Get("flight_id"))
+	if err != nil || flightID <= 0 {
+		http.Error(w, "Invalid flight ID", http.StatusBadRequest)
+		return
+	}
+
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		http.Error(w, "Missing required parameter: date", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	seats, err := bh.bookingService.GetConfirmedSeats(ctx, flightID, date)
+	if err != nil {
+		log.Printf("Confirmed seats error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to get confirmed seats: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	response := models.ConfirmedSeatsResponse{
+		FlightID:       flightID,
+		Date:           date,
+		ConfirmedSeats: seats,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
 }
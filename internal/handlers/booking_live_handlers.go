@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/services"
+	"cred_flights_booking/internal/websocket"
+	"cred_flights_booking/pkg/models"
+)
+
+// BookingLiveHandlers serves the booking status WebSocket
+type BookingLiveHandlers struct {
+	liveService  *services.BookingLiveService
+	pingInterval time.Duration
+}
+
+// NewBookingLiveHandlers creates new live booking handlers
+func NewBookingLiveHandlers(liveService *services.BookingLiveService) *BookingLiveHandlers {
+	return &BookingLiveHandlers{
+		liveService:  liveService,
+		pingInterval: config.GetDuration("WS_PING_INTERVAL", 30*time.Second),
+	}
+}
+
+// Connect upgrades to a WebSocket on which the client subscribes to booking IDs and
+// receives their status transitions as they happen
+func (lh *BookingLiveHandlers) Connect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	subscriber, err := lh.liveService.Connect()
+	if err != nil {
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer lh.liveService.Disconnect(subscriber)
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		log.Printf("Failed to upgrade live booking connection: %v", err)
+		return
+	}
+
+	// A client that stops answering pings is disconnected
+	pongWait := 2 * lh.pingInterval
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.OnPong = func() { conn.SetReadDeadline(time.Now().Add(pongWait)) }
+
+	// The request context ends with the hijacked connection's handler, so reads own theirs
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		defer cancel()
+		lh.readRequests(ctx, conn, subscriber, pongWait)
+	}()
+
+	ticker := time.NewTicker(lh.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			conn.Close(websocket.CloseNormal, "")
+			return
+		case update, ok := <-subscriber.Updates:
+			if !ok {
+				conn.Close(websocket.CloseTryAgainLater, "too slow, reconnect")
+				return
+			}
+			if err := conn.WriteJSON(update); err != nil {
+				conn.Close(websocket.CloseGoingAway, "")
+				return
+			}
+		case <-ticker.C:
+			if err := conn.Ping(); err != nil {
+				conn.Close(websocket.CloseGoingAway, "")
+				return
+			}
+		}
+	}
+}
+
+// readRequests applies the client's subscribe and unsubscribe messages until the
+// connection closes
+func (lh *BookingLiveHandlers) readRequests(ctx context.Context, conn *websocket.Conn, subscriber *services.LiveSubscriber, pongWait time.Duration) {
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) && ctx.Err() == nil {
+				log.Printf("Live booking connection ended: %v", err)
+			}
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+
+		var req models.LiveRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			conn.WriteJSON(&models.BookingLiveUpdate{Type: models.LiveUpdateError, Error: "Invalid message"})
+			continue
+		}
+
+		switch req.Action {
+		case models.LiveActionSubscribe:
+			for _, bookingID := range req.BookingIDs {
+				conn.WriteJSON(lh.subscribe(ctx, subscriber, bookingID))
+			}
+		case models.LiveActionUnsubscribe:
+			for _, bookingID := range req.BookingIDs {
+				lh.liveService.Unsubscribe(subscriber, bookingID)
+			}
+		default:
+			conn.WriteJSON(&models.BookingLiveUpdate{Type: models.LiveUpdateError, Error: "Action must be subscribe or unsubscribe"})
+		}
+	}
+}
+
+// subscribe subscribes to one booking and returns its snapshot, or the error to send instead
+func (lh *BookingLiveHandlers) subscribe(ctx context.Context, subscriber *services.LiveSubscriber, bookingID int) *models.BookingLiveUpdate {
+	if bookingID <= 0 {
+		return &models.BookingLiveUpdate{Type: models.LiveUpdateError, BookingID: bookingID, Error: "Invalid booking ID"}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	snapshot, err := lh.liveService.Subscribe(ctx, subscriber, bookingID)
+	if err != nil {
+		message := "Failed to subscribe to booking"
+		switch {
+		case errors.Is(err, services.ErrBookingNotFound):
+			message = "Booking not found"
+		case errors.Is(err, services.ErrTooManySubscriptions):
+			message = err.Error()
+		default:
+			log.Printf("Failed to subscribe to booking %d: %v", bookingID, err)
+		}
+		return &models.BookingLiveUpdate{Type: models.LiveUpdateError, BookingID: bookingID, Error: message}
+	}
+	return snapshot
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"cred_flights_booking/internal/services"
+	"cred_flights_booking/pkg/models"
+)
+
+// writeCabinError maps a cabin inventory error to its HTTP status
+func writeCabinError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, services.ErrUnknownCabin), errors.Is(err, services.ErrInvalidCabinMove):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrFlightNotFound):
+		http.Error(w, "Flight not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrCabinNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrCabinSoldOut):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("Cabin %s error: %v", action, err)
+		http.Error(w, "Failed to "+action, http.StatusInternalServerError)
+	}
+}
+
+// ListCabins handles requests for a flight date's cabins and their availability
+func (fh *FlightHandlers) ListCabins(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flightID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || flightID <= 0 {
+		http.Error(w, "Invalid flight ID", http.StatusBadRequest)
+		return
+	}
+
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		http.Error(w, "Missing required parameter: date", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	response, err := fh.flightService.ListCabins(ctx, flightID, date)
+	if err != nil {
+		writeCabinError(w, err, "list cabins")
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// SetCabin handles admin requests to set a premium cabin's capacity and fare on a flight date
+func (fh *FlightHandlers) SetCabin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := adminIdentity(r)
+	if !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	flightID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || flightID <= 0 {
+		http.Error(w, "Invalid flight ID", http.StatusBadRequest)
+		return
+	}
+
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		http.Error(w, "Missing required parameter: date", http.StatusBadRequest)
+		return
+	}
+
+	var req models.CabinInventoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.TotalSeats < 0 || !req.Price.IsPositive() {
+		http.Error(w, "Total seats must not be negative and price must be positive", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	response, err := fh.flightService.SetCabin(ctx, flightID, date, r.PathValue("cabin"), &req, admin)
+	if err != nil {
+		writeCabinError(w, err, "set cabin")
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// QuoteUpgrades handles requests to price a booking's upgrade to each higher cabin
+func (fh *FlightHandlers) QuoteUpgrades(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.UpgradeQuoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.FlightID <= 0 || req.Seats <= 0 || req.Date == "" {
+		http.Error(w, "Invalid flight ID, seats, or date", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	response, err := fh.flightService.QuoteUpgrades(ctx, &req)
+	if err != nil {
+		writeCabinError(w, err, "quote upgrades")
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// MoveCabinSeats handles requests to move booked seats to a higher cabin
+func (fh *FlightHandlers) MoveCabinSeats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.CabinMoveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.FlightID <= 0 || req.Seats <= 0 || req.Date == "" || req.ToCabin == "" {
+		http.Error(w, "Invalid flight ID, seats, date, or cabin", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := fh.flightService.MoveCabinSeats(ctx, &req); err != nil {
+		writeCabinError(w, err, "move seats")
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(req); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
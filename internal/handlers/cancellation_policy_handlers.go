@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"cred_flights_booking/internal/services"
+	"cred_flights_booking/pkg/models"
+)
+
+// CancellationPolicyHandlers handles admin requests for fare cancellation rules
+type CancellationPolicyHandlers struct {
+	policyService *services.CancellationPolicyService
+}
+
+// NewCancellationPolicyHandlers creates new cancellation policy handlers
+func NewCancellationPolicyHandlers(policyService *services.CancellationPolicyService) *CancellationPolicyHandlers {
+	return &CancellationPolicyHandlers{
+		policyService: policyService,
+	}
+}
+
+// ListPolicies handles listing all cancellation policies
+func (ph *CancellationPolicyHandlers) ListPolicies(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := adminIdentity(r); !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := r.Context()
+
+	policies, err := ph.policyService.ListPolicies(ctx)
+	if err != nil {
+		log.Printf("List cancellation policies error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to list cancellation policies: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(policies); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetPolicy handles getting the cancellation policy for a fare code
+func (ph *CancellationPolicyHandlers) GetPolicy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := adminIdentity(r); !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := r.Context()
+
+	policy, err := ph.policyService.GetPolicy(ctx, r.PathValue("fare_code"))
+	if err != nil {
+		if errors.Is(err, services.ErrPolicyNotFound) {
+			http.Error(w, "Cancellation policy not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Get cancellation policy error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to get cancellation policy: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(policy); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// PutPolicy handles creating or replacing the cancellation policy for a fare code
+func (ph *CancellationPolicyHandlers) PutPolicy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := adminIdentity(r)
+	if !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse request body
+	var policy models.CancellationPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	policy.FareCode = r.PathValue("fare_code")
+
+	if err := policy.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	saved, err := ph.policyService.UpsertPolicy(ctx, &policy)
+	if err != nil {
+		log.Printf("Save cancellation policy error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to save cancellation policy: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("AUDIT: cancellation policy %s saved by %s (refundable=%t, %d tiers)", saved.FareCode, admin, saved.Refundable, len(saved.Tiers))
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(saved); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// DeletePolicy handles removing an unused cancellation policy
+func (ph *CancellationPolicyHandlers) DeletePolicy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := adminIdentity(r)
+	if !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	fareCode := r.PathValue("fare_code")
+	ctx := r.Context()
+
+	if err := ph.policyService.DeletePolicy(ctx, fareCode); err != nil {
+		switch {
+		case errors.Is(err, services.ErrPolicyNotFound):
+			http.Error(w, "Cancellation policy not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrPolicyInUse):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Printf("Delete cancellation policy error: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to delete cancellation policy: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Printf("AUDIT: cancellation policy %s deleted by %s", fareCode, admin)
+	w.WriteHeader(http.StatusNoContent)
+}
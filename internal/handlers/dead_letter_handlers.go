@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"cred_flights_booking/internal/events"
+)
+
+// Page sizes of GET /api/admin/dlq
+const (
+	defaultDeadLetters = 50
+	maxDeadLetters     = 500
+)
+
+// DeadLetterHandlers handles inspection and replay of events consumers gave up on
+type DeadLetterHandlers struct {
+	bus *events.Bus
+}
+
+// NewDeadLetterHandlers creates new dead letter handlers
+func NewDeadLetterHandlers(bus *events.Bus) *DeadLetterHandlers {
+	return &DeadLetterHandlers{
+		bus: bus,
+	}
+}
+
+// ListDeadLetters handles admin requests for dead-lettered events, newest first, optionally
+// only those of one stream
+func (dh *DeadLetterHandlers) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := adminIdentity(r); !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	limit := defaultDeadLetters
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxDeadLetters {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxDeadLetters), http.StatusBadRequest)
+			return
+		}
+	}
+	stream := r.URL.Query().Get("stream")
+
+	ctx := r.Context()
+
+	response, err := dh.bus.DeadLetters(ctx, stream, limit)
+	if err != nil {
+		log.Printf("Dead letter listing error: %v", err)
+		http.Error(w, "Failed to list dead letters", http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ReplayDeadLetter handles admin requests to re-publish a dead letter once its cause is fixed
+func (dh *DeadLetterHandlers) ReplayDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := adminIdentity(r)
+	if !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	id := r.PathValue("id")
+
+	ctx := r.Context()
+
+	response, err := dh.bus.ReplayDeadLetter(ctx, id)
+	if err != nil {
+		if errors.Is(err, events.ErrDeadLetterNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		log.Printf("Dead letter replay error: %v", err)
+		http.Error(w, "Failed to replay dead letter", http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("AUDIT: dead letter %s replayed to %s by %s", id, response.Stream, admin)
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"cred_flights_booking/internal/services"
+)
+
+// FeedHandlers handles external flight feed HTTP requests
+type FeedHandlers struct {
+	feedService *services.FeedSyncService
+}
+
+// NewFeedHandlers creates new feed handlers
+func NewFeedHandlers(feedService *services.FeedSyncService) *FeedHandlers {
+	return &FeedHandlers{
+		feedService: feedService,
+	}
+}
+
+// SyncFeed handles requests to sync the external flight feed immediately
+func (fh *FeedHandlers) SyncFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := adminIdentity(r)
+	if !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := r.Context()
+
+	response, err := fh.feedService.Sync(ctx, admin)
+	if err != nil {
+		if errors.Is(err, services.ErrFeedNotConfigured) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		log.Printf("Feed sync error: %v", err)
+		http.Error(w, "Feed sync failed", http.StatusBadGateway)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("AUDIT: flight feed %s synced by %s", response.Source, admin)
+}
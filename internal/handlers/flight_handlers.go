@@ -1,27 +1,35 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
-	"cred_flights_booking/internal/models"
+	"cred_flights_booking/internal/cdn"
+	"cred_flights_booking/internal/seatnonce"
 	"cred_flights_booking/internal/services"
+	"cred_flights_booking/pkg/models"
 )
 
+// contentTypeNDJSON is the media type of streamed search results
+const contentTypeNDJSON = "application/x-ndjson"
+
 // FlightHandlers handles flight-related HTTP requests
 type FlightHandlers struct {
 	flightService *services.FlightService
+	cdn           cdn.Config
 }
 
 // NewFlightHandlers creates new flight handlers
 func NewFlightHandlers(flightService *services.FlightService) *FlightHandlers {
 	return &FlightHandlers{
 		flightService: flightService,
+		cdn:           cdn.LoadConfig(),
 	}
 }
 
@@ -53,8 +61,8 @@ func (fh *FlightHandlers) SearchFlights(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Validate sort order
-	if sortBy != "" && sortBy != "cheapest" && sortBy != "fastest" {
-		http.Error(w, "Invalid sort_by parameter. Must be 'cheapest' or 'fastest'", http.StatusBadRequest)
+	if sortBy != "" && sortBy != "cheapest" && sortBy != "fastest" && sortBy != "recommended" {
+		http.Error(w, "Invalid sort_by parameter. Must be 'cheapest', 'fastest', or 'recommended'", http.StatusBadRequest)
 		return
 	}
 
@@ -63,18 +71,94 @@ func (fh *FlightHandlers) SearchFlights(w http.ResponseWriter, r *http.Request)
 		sortBy = "cheapest"
 	}
 
+	// Parse optional diversity caps
+	var diversity models.DiversityOptions
+	diversityParams := map[string]*int{
+		"max_per_first_leg":      &diversity.MaxPerFirstLeg,
+		"max_per_airline":        &diversity.MaxPerAirline,
+		"max_per_departure_hour": &diversity.MaxPerDepartureHour,
+	}
+	for param, target := range diversityParams {
+		value := r.URL.Query().Get(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			http.Error(w, fmt.Sprintf("Invalid %s parameter", param), http.StatusBadRequest)
+			return
+		}
+		*target = parsed
+	}
+
+	// Parse airline filter (comma-separated airline codes)
+	var airlines []string
+	if airlineStr := r.URL.Query().Get("airline"); airlineStr != "" {
+		for _, code := range strings.Split(airlineStr, ",") {
+			if code = strings.ToUpper(strings.TrimSpace(code)); code != "" {
+				airlines = append(airlines, code)
+			}
+		}
+	}
+
+	// Parse nearby-airport expansion
+	includeNearby := r.URL.Query().Get("include_nearby") == "true"
+	var nearbyRadius float64
+	if radiusStr := r.URL.Query().Get("nearby_radius_km"); radiusStr != "" {
+		nearbyRadius, err = strconv.ParseFloat(radiusStr, 64)
+		if err != nil || nearbyRadius <= 0 {
+			http.Error(w, "Invalid nearby_radius_km parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Parse the optional user ID used for experiment bucketing
+	var userID int
+	if userStr := r.URL.Query().Get("user_id"); userStr != "" {
+		userID, err = strconv.Atoi(userStr)
+		if err != nil || userID <= 0 {
+			http.Error(w, "Invalid user_id parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Parse the optional search depth
+	var maxStops *int
+	if stopsStr := r.URL.Query().Get("max_stops"); stopsStr != "" {
+		stops, err := strconv.Atoi(stopsStr)
+		if limit := fh.flightService.MaxSearchStops(); err != nil || stops < 0 || stops > limit {
+			http.Error(w, fmt.Sprintf("max_stops must be between 0 and %d", limit), http.StatusBadRequest)
+			return
+		}
+		maxStops = &stops
+	}
+
 	// Create search request
 	req := &models.SearchRequest{
-		Source:      source,
-		Destination: destination,
-		Date:        date,
-		Seats:       seats,
-		SortBy:      sortBy,
+		Source:         source,
+		Destination:    destination,
+		Date:           date,
+		Seats:          seats,
+		SortBy:         sortBy,
+		Diversity:      diversity,
+		Airlines:       airlines,
+		IncludeNearby:  includeNearby,
+		NearbyRadiusKm: nearbyRadius,
+		UserID:         userID,
+		MaxStops:       maxStops,
 	}
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
-	defer cancel()
+	ctx := r.Context()
+
+	// Stream paths as they are found when the client asks for NDJSON
+	if strings.Contains(r.Header.Get("Accept"), contentTypeNDJSON) {
+		if req.IncludeNearby {
+			http.Error(w, "include_nearby is not supported when streaming", http.StatusBadRequest)
+			return
+		}
+		fh.streamSearch(w, r, req)
+		return
+	}
 
 	// Search flights
 	response, err := fh.flightService.SearchFlights(ctx, req)
@@ -84,11 +168,15 @@ func (fh *FlightHandlers) SearchFlights(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Return response
+	// Return response; searches without experiments may be cached by a CDN, tagged so
+	// inventory changes can purge them
+	personalized := req.UserID > 0 || len(response.Experiments) > 0
+	cdn.SetCacheHeaders(w.Header(), fh.cdn, personalized, searchSurrogateKeys(req, response))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
-	if err := json.NewEncoder(w).Encode(response); err != nil {
+	// Large path lists are encoded without reflection into a pooled buffer
+	if err := response.WriteJSON(w); err != nil {
 		log.Printf("Failed to encode response: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -97,36 +185,103 @@ func (fh *FlightHandlers) SearchFlights(w http.ResponseWriter, r *http.Request)
 	log.Printf("Flight search completed: %d paths found", response.Count)
 }
 
-// GetFlight handles getting flight details
-func (fh *FlightHandlers) GetFlight(w http.ResponseWriter, r *http.Request) {
+// searchSurrogateKeys returns the surrogate keys of a search response: its routes, and
+// every flight date whose seats or fare it shows
+func searchSurrogateKeys(req *models.SearchRequest, response *models.SearchResponse) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	add := func(key string) {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	add(cdn.RouteKey(req.Source, req.Destination, req.Date))
+	for _, pair := range response.AirportPairs {
+		if source, destination, ok := strings.Cut(pair, "-"); ok {
+			add(cdn.RouteKey(source, destination, req.Date))
+		}
+	}
+	for _, path := range response.Paths {
+		for _, flight := range path.Flights {
+			add(cdn.FlightKey(flight.ID, flight.DepartureTime.Format("2006-01-02")))
+		}
+	}
+	return keys
+}
+
+// streamSearch writes search results as NDJSON, flushing each path as soon as it is found
+func (fh *FlightHandlers) streamSearch(w http.ResponseWriter, r *http.Request, req *models.SearchRequest) {
+	ctx := r.Context()
+	flusher := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", contentTypeNDJSON)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(w)
+	writeLine := func(line *models.SearchStreamLine) error {
+		if err := encoder.Encode(line); err != nil {
+			return err
+		}
+		return flusher.Flush()
+	}
+
+	count, err := fh.flightService.StreamSearch(ctx, req, func(path *models.FlightPath) error {
+		return writeLine(&models.SearchStreamLine{Type: models.SearchStreamPath, Path: path})
+	})
+	if err != nil {
+		log.Printf("Streamed flight search error after %d paths: %v", count, err)
+		if ctx.Err() == nil {
+			writeLine(&models.SearchStreamLine{Type: models.SearchStreamError, Error: "Search failed"})
+		}
+		return
+	}
+
+	if err := writeLine(&models.SearchStreamLine{Type: models.SearchStreamDone, Count: count}); err != nil {
+		log.Printf("Failed to write search stream: %v", err)
+		return
+	}
+	log.Printf("Streamed flight search completed: %d paths sent", count)
+}
+
+// LookupFlights handles flight lookup by flight number and date
+func (fh *FlightHandlers) LookupFlights(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Extract flight ID from URL path
-	// Assuming URL pattern: /api/flights/{id}
-	// You might need to adjust this based on your routing setup
-	flightIDStr := r.URL.Query().Get("id")
-	if flightIDStr == "" {
-		http.Error(w, "Missing flight ID", http.StatusBadRequest)
+	flightNumber := strings.ToUpper(r.URL.Query().Get("flight_number"))
+	date := r.URL.Query().Get("date")
+	if flightNumber == "" || date == "" {
+		http.Error(w, "Missing required parameters: flight_number, date", http.StatusBadRequest)
 		return
 	}
 
-	flightID, err := strconv.Atoi(flightIDStr)
-	if err != nil || flightID <= 0 {
-		http.Error(w, "Invalid flight ID", http.StatusBadRequest)
+	ctx := r.Context()
+
+	flights, err := fh.flightService.LookupFlights(ctx, flightNumber, date)
+	if err != nil {
+		log.Printf("Flight lookup error: %v", err)
+		http.Error(w, fmt.Sprintf("Lookup failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if len(flights) == 0 {
+		http.Error(w, "Flight not found", http.StatusNotFound)
 		return
 	}
 
-	// Get flight details (you'll need to implement this in FlightService)
-	// For now, we'll return a placeholder
+	// Return response
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
 	response := map[string]interface{}{
-		"message":   "Flight details endpoint not implemented yet",
-		"flight_id": flightID,
+		"flights": flights,
+		"count":   len(flights),
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
@@ -136,6 +291,44 @@ func (fh *FlightHandlers) GetFlight(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// GetFlight handles getting flight details
+func (fh *FlightHandlers) GetFlight(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flightID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || flightID <= 0 {
+		http.Error(w, "Invalid flight ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	flight, err := fh.flightService.GetFlight(ctx, flightID)
+	if err != nil {
+		if errors.Is(err, services.ErrFlightNotFound) {
+			http.Error(w, "Flight not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Get flight error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to get flight: %v", err), http.StatusInternalServerError)
+		return
+	}
+	fh.flightService.TrackSelection(ctx, flight)
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(flight); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
 // ValidateFlight handles flight validation requests
 func (fh *FlightHandlers) ValidateFlight(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -156,12 +349,10 @@ func (fh *FlightHandlers) ValidateFlight(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
-	defer cancel()
+	ctx := fh.flightService.AssignExperiments(r.Context(), req.UserID)
 
 	// Validate flight
-	response, err := fh.flightService.ValidateFlight(ctx, req.FlightID, req.Seats, req.Date)
+	response, err := fh.flightService.ValidateFlight(ctx, req.FlightID, req.Seats, req.Date, req.UserID)
 	if err != nil {
 		log.Printf("Flight validation error: %v", err)
 		http.Error(w, fmt.Sprintf("Validation failed: %v", err), http.StatusInternalServerError)
@@ -200,13 +391,21 @@ func (fh *FlightHandlers) DecrementSeats(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Invalid flight ID, seats, or date", http.StatusBadRequest)
 		return
 	}
+	if len(req.Reason) > services.MaxSeatReasonLength {
+		http.Error(w, fmt.Sprintf("Reason must be at most %d characters", services.MaxSeatReasonLength), http.StatusBadRequest)
+		return
+	}
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
-	defer cancel()
+	ctx := fh.flightService.AssignExperiments(r.Context(), req.UserID)
+
+	// Spend the nonce issued at validation, so a captured request can't be replayed
+	if err := fh.flightService.SpendSeatNonce(ctx, models.SeatEventReserve, &req); err != nil {
+		writeSeatNonceError(w, err)
+		return
+	}
 
 	// Decrement seats
-	err := fh.flightService.DecrementSeats(ctx, req.FlightID, req.Seats, req.Date)
+	err := fh.flightService.DecrementSeats(ctx, req.FlightID, req.Seats, req.Date, req.Reason)
 	if err != nil {
 		log.Printf("Seat decrement error: %v", err)
 		http.Error(w, fmt.Sprintf("Seat decrement failed: %v", err), http.StatusBadRequest)
@@ -217,12 +416,13 @@ func (fh *FlightHandlers) DecrementSeats(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
-	response := map[string]interface{}{
-		"message":    "Seats decremented successfully",
-		"flight_id":  req.FlightID,
-		"seats":      req.Seats,
-		"date":       req.Date,
-		"updated_at": time.Now(),
+	response := models.SeatUpdateResponse{
+		Message:      "Seats decremented successfully",
+		FlightID:     req.FlightID,
+		Seats:        req.Seats,
+		Date:         req.Date,
+		UpdatedAt:    time.Now(),
+		ReleaseNonce: fh.flightService.IssueReleaseNonce(req.FlightID, req.Date, models.CabinEconomy, req.Seats),
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
@@ -234,6 +434,185 @@ func (fh *FlightHandlers) DecrementSeats(w http.ResponseWriter, r *http.Request)
 	log.Printf("Seats decremented for flight %d: %d seats", req.FlightID, req.Seats)
 }
 
+// ReserveSeatsBatch handles all-or-nothing seat reservations across multiple flights
+func (fh *FlightHandlers) ReserveSeatsBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse request body
+	var req models.BatchSeatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate request
+	if len(req.Reservations) == 0 {
+		http.Error(w, "At least one reservation is required", http.StatusBadRequest)
+		return
+	}
+	for _, reservation := range req.Reservations {
+		if reservation.FlightID <= 0 || reservation.Seats <= 0 || reservation.Date == "" {
+			http.Error(w, "Invalid flight ID, seats, or date", http.StatusBadRequest)
+			return
+		}
+		if len(reservation.Reason) > services.MaxSeatReasonLength {
+			http.Error(w, fmt.Sprintf("Reason must be at most %d characters", services.MaxSeatReasonLength), http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	// Every reservation spends its own nonce issued at validation, so a batch can't be replayed
+	for i := range req.Reservations {
+		if err := fh.flightService.SpendSeatNonce(ctx, models.SeatEventReserve, &req.Reservations[i]); err != nil {
+			writeSeatNonceError(w, fmt.Errorf("reservation %d: %w", i, err))
+			return
+		}
+	}
+
+	// Reserve seats on every flight or none
+	results, err := fh.flightService.ReserveSeatsBatch(ctx, req.Reservations)
+	if err != nil {
+		log.Printf("Batch seat reservation error: %v", err)
+		http.Error(w, fmt.Sprintf("Seat reservation failed: %v", err), http.StatusConflict)
+		return
+	}
+	for i := range results {
+		results[i].ReleaseNonce = fh.flightService.IssueReleaseNonce(results[i].FlightID, results[i].Date, models.CabinEconomy, results[i].Seats)
+	}
+
+	// Return success response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	response := models.BatchSeatResponse{
+		Reservations: results,
+		ReservedAt:   time.Now(),
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetBatchAvailability handles availability and lowest-fare requests for several routes and dates
+func (fh *FlightHandlers) GetBatchAvailability(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse request body
+	var req models.BatchAvailabilityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate request
+	maxRoutes := services.MaxBatchAvailabilityRoutes()
+	if len(req.Routes) == 0 || len(req.Routes) > maxRoutes {
+		http.Error(w, fmt.Sprintf("Between 1 and %d routes are required", maxRoutes), http.StatusBadRequest)
+		return
+	}
+	if req.Seats < 0 {
+		http.Error(w, "Invalid seats", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	response, err := fh.flightService.GetBatchAvailability(ctx, &req)
+	if err != nil {
+		log.Printf("Batch availability error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to get availability: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// SearchFlightsBatch handles searches of one route on several dates, or of several routes
+func (fh *FlightHandlers) SearchFlightsBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse request body
+	var req models.BatchSearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate request
+	if (len(req.Dates) > 0) == (len(req.Routes) > 0) {
+		http.Error(w, "Either dates (with source and destination) or routes is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Dates) > 0 && (req.Source == "" || req.Destination == "") {
+		http.Error(w, "source and destination are required with dates", http.StatusBadRequest)
+		return
+	}
+	if maxSearches := services.MaxBatchSearches(); len(req.Dates)+len(req.Routes) > maxSearches {
+		http.Error(w, fmt.Sprintf("At most %d searches are allowed per batch", maxSearches), http.StatusBadRequest)
+		return
+	}
+	if req.Seats <= 0 {
+		http.Error(w, "Invalid seats", http.StatusBadRequest)
+		return
+	}
+	if req.SortBy == "" {
+		req.SortBy = "cheapest"
+	}
+	if req.SortBy != "cheapest" && req.SortBy != "fastest" && req.SortBy != "recommended" {
+		http.Error(w, "Invalid sort_by. Must be 'cheapest', 'fastest', or 'recommended'", http.StatusBadRequest)
+		return
+	}
+	if req.MaxStops != nil {
+		if limit := fh.flightService.MaxSearchStops(); *req.MaxStops < 0 || *req.MaxStops > limit {
+			http.Error(w, fmt.Sprintf("max_stops must be between 0 and %d", limit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	response, err := fh.flightService.SearchFlightsBatch(ctx, &req)
+	if err != nil {
+		log.Printf("Batch search error: %v", err)
+		http.Error(w, fmt.Sprintf("Search failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Batch search completed: %d searches", len(response.Results))
+}
+
 // IncrementSeats handles seat increment requests
 func (fh *FlightHandlers) IncrementSeats(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -253,13 +632,26 @@ func (fh *FlightHandlers) IncrementSeats(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Invalid flight ID, seats, or date", http.StatusBadRequest)
 		return
 	}
+	if len(req.Reason) > services.MaxSeatReasonLength {
+		http.Error(w, fmt.Sprintf("Reason must be at most %d characters", services.MaxSeatReasonLength), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
-	defer cancel()
+	// Spend the nonce issued at reservation or by IssueReleaseNonce
+	if err := fh.flightService.SpendSeatNonce(ctx, models.SeatEventRelease, &req); err != nil {
+		writeSeatNonceError(w, err)
+		return
+	}
 
-	// Increment seats
-	err := fh.flightService.IncrementSeats(ctx, req.FlightID, req.Seats, req.Date)
+	// Increment seats; seats of an upgraded booking go back to their premium cabin
+	var err error
+	if req.Cabin != "" && req.Cabin != models.CabinEconomy {
+		err = fh.flightService.ReleaseCabinSeats(ctx, req.FlightID, req.Seats, req.Date, req.Cabin)
+	} else {
+		err = fh.flightService.IncrementSeats(ctx, req.FlightID, req.Seats, req.Date, req.Reason)
+	}
 	if err != nil {
 		log.Printf("Seat increment error: %v", err)
 		http.Error(w, fmt.Sprintf("Seat increment failed: %v", err), http.StatusInternalServerError)
@@ -270,12 +662,12 @@ func (fh *FlightHandlers) IncrementSeats(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
-	response := map[string]interface{}{
-		"message":    "Seats incremented successfully",
-		"flight_id":  req.FlightID,
-		"seats":      req.Seats,
-		"date":       req.Date,
-		"updated_at": time.Now(),
+	response := models.SeatUpdateResponse{
+		Message:   "Seats incremented successfully",
+		FlightID:  req.FlightID,
+		Seats:     req.Seats,
+		Date:      req.Date,
+		UpdatedAt: time.Now(),
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
@@ -286,3 +678,515 @@ func (fh *FlightHandlers) IncrementSeats(w http.ResponseWriter, r *http.Request)
 
 	log.Printf("Seats incremented for flight %d: %d seats", req.FlightID, req.Seats)
 }
+
+// IssueReleaseNonce handles requests for a nonce to give seats back to a flight date's cabin, for
+// releases long after the reservation (cancellations)
+func (fh *FlightHandlers) IssueReleaseNonce(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse request body
+	var req models.SeatUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate request
+	if req.FlightID <= 0 || req.Seats <= 0 || req.Date == "" {
+		http.Error(w, "Invalid flight ID, seats, or date", http.StatusBadRequest)
+		return
+	}
+
+	response := models.SeatNonceResponse{
+		Nonce: fh.flightService.IssueReleaseNonce(req.FlightID, req.Date, req.Cabin, req.Seats),
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// writeSeatNonceError maps a refused seat nonce to its status
+func writeSeatNonceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, seatnonce.ErrNonceUsed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, seatnonce.ErrMissingNonce), errors.Is(err, seatnonce.ErrInvalidNonce),
+		errors.Is(err, seatnonce.ErrNonceExpired):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	default:
+		log.Printf("Seat nonce error: %v", err)
+		http.Error(w, "Failed to check seat nonce", http.StatusInternalServerError)
+	}
+}
+
+// RecordOccupancyEvent handles seat occupancy events published by the booking service
+func (fh *FlightHandlers) RecordOccupancyEvent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse request body
+	var event models.SeatOccupancyEvent
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate request
+	if event.FlightID <= 0 || event.BookingID <= 0 || event.Delta == 0 || event.Date == "" || event.Reason == "" {
+		http.Error(w, "Invalid flight ID, booking ID, delta, date, or reason", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	// Apply event
+	response, err := fh.flightService.ApplyOccupancyEvent(ctx, &event)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidOccupancyDelta) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, services.ErrFlightNotFound) {
+			http.Error(w, "Flight not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Occupancy event error: %v", err)
+		http.Error(w, fmt.Sprintf("Occupancy event failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetLoadFactor handles load factor requests for a flight on a date
+func (fh *FlightHandlers) GetLoadFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flightID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || flightID <= 0 {
+		http.Error(w, "Invalid flight ID", http.StatusBadRequest)
+		return
+	}
+
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		http.Error(w, "Missing required parameter: date", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	response, err := fh.flightService.GetLoadFactor(ctx, flightID, date)
+	if err != nil {
+		log.Printf("Load factor error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to get load factor: %v", err), http.StatusNotFound)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetSeatAvailability handles requests for a flight's live seat counter and its provenance
+func (fh *FlightHandlers) GetSeatAvailability(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flightID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || flightID <= 0 {
+		http.Error(w, "Invalid flight ID", http.StatusBadRequest)
+		return
+	}
+
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		http.Error(w, "Missing required parameter: date", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	response, err := fh.flightService.GetSeatAvailability(ctx, flightID, date)
+	if err != nil {
+		if errors.Is(err, services.ErrFlightNotFound) {
+			http.Error(w, "Flight not found on this date", http.StatusNotFound)
+			return
+		}
+		log.Printf("Seat availability error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to get seat availability: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// RecalculateSeats handles admin requests to recompute a flight's seat counter
+func (fh *FlightHandlers) RecalculateSeats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := adminIdentity(r)
+	if !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	flightID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || flightID <= 0 {
+		http.Error(w, "Invalid flight ID", http.StatusBadRequest)
+		return
+	}
+
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		http.Error(w, "Missing required parameter: date", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	response, err := fh.flightService.RecalculateSeats(ctx, flightID, date, admin)
+	if err != nil {
+		log.Printf("Seat recalculation error: %v", err)
+		http.Error(w, fmt.Sprintf("Seat recalculation failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ListSeatEvents handles admin requests for a flight date's seat event stream
+func (fh *FlightHandlers) ListSeatEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := adminIdentity(r); !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	flightID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || flightID <= 0 {
+		http.Error(w, "Invalid flight ID", http.StatusBadRequest)
+		return
+	}
+
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		http.Error(w, "Missing required parameter: date", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	response, err := fh.flightService.ListSeatEvents(ctx, flightID, date)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrSeatEventsDisabled):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, services.ErrFlightNotFound):
+			http.Error(w, "Flight not found", http.StatusNotFound)
+		default:
+			log.Printf("Seat events error: %v", err)
+			http.Error(w, "Failed to list seat events", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// RebuildSeats handles admin requests to replay seat events into the seat counters
+func (fh *FlightHandlers) RebuildSeats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := adminIdentity(r)
+	if !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	// Both filters are optional; without them every counter with events is rebuilt
+	var flightID int
+	if value := r.URL.Query().Get("flight_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			http.Error(w, "Invalid flight ID", http.StatusBadRequest)
+			return
+		}
+		flightID = id
+	}
+	date := r.URL.Query().Get("date")
+	if date != "" {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			http.Error(w, "Invalid date parameter, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	response, err := fh.flightService.RebuildSeats(ctx, flightID, date, admin)
+	if err != nil {
+		if errors.Is(err, services.ErrSeatEventsDisabled) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		log.Printf("Seat rebuild error: %v", err)
+		http.Error(w, fmt.Sprintf("Seat rebuild failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// UpdateFlight handles admin changes to a flight's times, capacity, or price
+func (fh *FlightHandlers) UpdateFlight(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := adminIdentity(r)
+	if !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	flightID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || flightID <= 0 {
+		http.Error(w, "Invalid flight ID", http.StatusBadRequest)
+		return
+	}
+
+	// Parse request body
+	var req models.FlightUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	flight, err := fh.flightService.UpdateFlight(ctx, flightID, &req, admin)
+	if err != nil {
+		if errors.Is(err, services.ErrFlightNotFound) {
+			http.Error(w, "Flight not found or cancelled", http.StatusNotFound)
+			return
+		}
+		log.Printf("Flight update error: %v", err)
+		http.Error(w, fmt.Sprintf("Flight update failed: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(flight); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// CancelFlight handles admin flight cancellations
+func (fh *FlightHandlers) CancelFlight(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := adminIdentity(r)
+	if !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	flightID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || flightID <= 0 {
+		http.Error(w, "Invalid flight ID", http.StatusBadRequest)
+		return
+	}
+
+	// Parse request body
+	var req models.FlightCancelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Reason == "" {
+		http.Error(w, "A cancellation reason is required", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	flight, err := fh.flightService.CancelFlight(ctx, flightID, req.Reason, admin)
+	if err != nil {
+		if errors.Is(err, services.ErrFlightNotFound) {
+			http.Error(w, "Flight not found or already cancelled", http.StatusNotFound)
+			return
+		}
+		log.Printf("Flight cancellation error: %v", err)
+		http.Error(w, fmt.Sprintf("Flight cancellation failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(flight); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetExperimentStats handles requests for experiment exposure counts (admin)
+func (fh *FlightHandlers) GetExperimentStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := adminIdentity(r); !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := r.Context()
+
+	stats, err := fh.flightService.ExperimentStats(ctx)
+	if err != nil {
+		log.Printf("Experiment stats error: %v", err)
+		http.Error(w, "Failed to get experiment stats", http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"experiments": stats}); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetForecast handles requests for a flight's booking velocity and sell-out forecast (admin)
+func (fh *FlightHandlers) GetForecast(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := adminIdentity(r); !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	flightID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || flightID <= 0 {
+		http.Error(w, "Invalid flight ID", http.StatusBadRequest)
+		return
+	}
+	date := r.URL.Query().Get("date")
+	if date != "" {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			http.Error(w, "Invalid date parameter, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	forecast, err := fh.flightService.ForecastFlight(ctx, flightID, date)
+	if err != nil {
+		if errors.Is(err, services.ErrFlightNotFound) {
+			http.Error(w, "Flight not found for date", http.StatusNotFound)
+			return
+		}
+		log.Printf("Flight forecast error: %v", err)
+		http.Error(w, "Failed to forecast flight", http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(forecast); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"cred_flights_booking/internal/services"
+)
+
+// maxFunnelReportDays bounds the date range of a funnel report
+const maxFunnelReportDays = 92
+
+// FunnelHandlers handles booking funnel report HTTP requests
+type FunnelHandlers struct {
+	funnelService *services.FunnelService
+}
+
+// NewFunnelHandlers creates new funnel handlers
+func NewFunnelHandlers(funnelService *services.FunnelService) *FunnelHandlers {
+	return &FunnelHandlers{
+		funnelService: funnelService,
+	}
+}
+
+// GetReport handles admin requests for conversion reports over the from/to query range
+// (default: last 7 days), optionally for one route (e.g. route=DEL-BOM)
+func (fh *FunnelHandlers) GetReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := adminIdentity(r); !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	today := time.Now().UTC()
+	from := r.URL.Query().Get("from")
+	if from == "" {
+		from = today.AddDate(0, 0, -6).Format("2006-01-02")
+	}
+	to := r.URL.Query().Get("to")
+	if to == "" {
+		to = today.Format("2006-01-02")
+	}
+	start, startErr := time.Parse("2006-01-02", from)
+	end, endErr := time.Parse("2006-01-02", to)
+	if startErr != nil || endErr != nil {
+		http.Error(w, "Invalid from or to date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	if end.Before(start) || end.Sub(start) >= maxFunnelReportDays*24*time.Hour {
+		http.Error(w, "Date range must be between 1 and 92 days", http.StatusBadRequest)
+		return
+	}
+	route := strings.ToUpper(r.URL.Query().Get("route"))
+
+	ctx := r.Context()
+
+	report, err := fh.funnelService.Report(ctx, from, to, route)
+	if err != nil {
+		log.Printf("Funnel report error: %v", err)
+		http.Error(w, "Failed to get funnel report", http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cred_flights_booking/internal/services"
+	"cred_flights_booking/pkg/models"
+)
+
+// PartnerHandlers handles partner API authentication, metering, and administration
+type PartnerHandlers struct {
+	partnerService *services.PartnerService
+}
+
+// NewPartnerHandlers creates new partner handlers
+func NewPartnerHandlers(partnerService *services.PartnerService) *PartnerHandlers {
+	return &PartnerHandlers{
+		partnerService: partnerService,
+	}
+}
+
+// authenticate resolves the X-API-Key header to a partner, writing the error response on failure
+func (ph *PartnerHandlers) authenticate(w http.ResponseWriter, r *http.Request) (*models.Partner, bool) {
+	partner, err := ph.partnerService.Authenticate(r.Context(), r.Header.Get("X-API-Key"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAPIKey) {
+			http.Error(w, "Invalid or missing API key", http.StatusUnauthorized)
+			return nil, false
+		}
+		log.Printf("Partner authentication error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return partner, true
+}
+
+// Require wraps a read-only handler so it is only served to partners granted the scope,
+// metering each request against the partner's per-minute limit and daily quota
+func (ph *PartnerHandlers) Require(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		partner, ok := ph.authenticate(w, r)
+		if !ok {
+			return
+		}
+		if !partner.HasScope(scope) {
+			http.Error(w, fmt.Sprintf("API key is not authorized for %s", scope), http.StatusForbidden)
+			return
+		}
+
+		admission, err := ph.partnerService.Admit(r.Context(), partner, scope)
+		if admission != nil {
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(partner.RequestsPerMinute))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(admission.MinuteRemaining))
+			w.Header().Set("X-Quota-Limit", strconv.Itoa(partner.DailyQuota))
+			w.Header().Set("X-Quota-Remaining", strconv.Itoa(admission.DailyRemaining))
+		}
+		if err != nil {
+			if errors.Is(err, services.ErrRateLimited) || errors.Is(err, services.ErrQuotaExceeded) {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(admission.RetryAfter.Seconds()))))
+				http.Error(w, err.Error(), http.StatusTooManyRequests)
+				return
+			}
+			log.Printf("Partner metering error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// GetUsage handles a partner's request for its own usage report
+func (ph *PartnerHandlers) GetUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	partner, ok := ph.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	ph.writeUsage(w, r, partner.ID)
+}
+
+// CreatePartner handles admin requests to register a partner and issue its API key
+func (ph *PartnerHandlers) CreatePartner(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := adminIdentity(r)
+	if !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse request body
+	var req models.PartnerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid partner: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	created, err := ph.partnerService.CreatePartner(ctx, &req)
+	if err != nil {
+		log.Printf("Partner creation error: %v", err)
+		http.Error(w, fmt.Sprintf("Partner creation failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	if err := json.NewEncoder(w).Encode(created); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("AUDIT: partner %d (%s) created by %s", created.ID, created.Name, admin)
+}
+
+// ListPartners handles admin requests to list partners
+func (ph *PartnerHandlers) ListPartners(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := adminIdentity(r); !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := r.Context()
+
+	partners, err := ph.partnerService.ListPartners(ctx)
+	if err != nil {
+		log.Printf("Partner listing error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to list partners: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(partners); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetPartnerUsage handles admin requests for a partner's usage report
+func (ph *PartnerHandlers) GetPartnerUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := adminIdentity(r); !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	partnerID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || partnerID <= 0 {
+		http.Error(w, "Invalid partner ID", http.StatusBadRequest)
+		return
+	}
+
+	ph.writeUsage(w, r, partnerID)
+}
+
+// writeUsage writes a partner's usage report for the from/to query range (default: last 7 days)
+func (ph *PartnerHandlers) writeUsage(w http.ResponseWriter, r *http.Request, partnerID int) {
+	today := time.Now().UTC()
+	from := r.URL.Query().Get("from")
+	if from == "" {
+		from = today.AddDate(0, 0, -6).Format("2006-01-02")
+	}
+	to := r.URL.Query().Get("to")
+	if to == "" {
+		to = today.Format("2006-01-02")
+	}
+
+	ctx := r.Context()
+
+	report, err := ph.partnerService.GetUsage(ctx, partnerID, from, to)
+	if err != nil {
+		if errors.Is(err, services.ErrPartnerNotFound) {
+			http.Error(w, "Partner not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Partner usage error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to get usage: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -1,14 +1,23 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
-	"cred_flights_booking/internal/models"
+	"cred_flights_booking/internal/farequote"
 	"cred_flights_booking/internal/services"
+	"cred_flights_booking/pkg/models"
+)
+
+// Page sizes of GET /api/admin/payments/audit
+const (
+	defaultAuditEntries = 100
+	maxAuditEntries     = 1000
 )
 
 // PaymentHandlers handles payment-related HTTP requests
@@ -38,18 +47,21 @@ func (ph *PaymentHandlers) ProcessPayment(w http.ResponseWriter, r *http.Request
 	}
 
 	// Validate request
-	if req.BookingID <= 0 || req.Amount <= 0 || req.UserID <= 0 {
+	if req.BookingID <= 0 || !req.Amount.IsPositive() || req.Amount.Currency != models.DefaultCurrency || req.UserID <= 0 {
 		http.Error(w, "Invalid booking ID, amount, or user ID", http.StatusBadRequest)
 		return
 	}
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
-	defer cancel()
+	ctx := r.Context()
 
 	// Process payment
 	response, err := ph.paymentService.ProcessPayment(ctx, &req)
 	if err != nil {
+		if errors.Is(err, models.ErrAmountMismatch) || errors.Is(err, farequote.ErrMissingQuote) ||
+			errors.Is(err, farequote.ErrInvalidQuote) || errors.Is(err, farequote.ErrQuoteExpired) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
 		log.Printf("Payment processing error: %v", err)
 		http.Error(w, "Payment processing failed", http.StatusInternalServerError)
 		return
@@ -77,6 +89,101 @@ func (ph *PaymentHandlers) ProcessPayment(w http.ResponseWriter, r *http.Request
 	log.Printf("Payment processed: BookingID=%d, Status=%s", req.BookingID, response.Status)
 }
 
+// CapturePayment handles capturing a successful payment's authorization once its booking is stored
+func (ph *PaymentHandlers) CapturePayment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	paymentID := r.PathValue("id")
+	if paymentID == "" {
+		http.Error(w, "Missing payment ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	auth, err := ph.paymentService.CapturePayment(ctx, paymentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrAuthorizationNotFound):
+			http.Error(w, "Payment authorization not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrAuthorizationVoided):
+			http.Error(w, "Payment authorization was voided or refunded", http.StatusConflict)
+		default:
+			log.Printf("Payment capture error: %v", err)
+			http.Error(w, "Payment capture failed", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(auth); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// ReversePayment handles returning a payment in full for a booking that was rolled back,
+// voiding it when still authorized and refunding it when captured
+func (ph *PaymentHandlers) ReversePayment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	paymentID := r.PathValue("id")
+	if paymentID == "" {
+		http.Error(w, "Missing payment ID", http.StatusBadRequest)
+		return
+	}
+
+	reason := r.URL.Query().Get("reason")
+	if reason == "" {
+		reason = "booking_rollback"
+	}
+
+	ctx := r.Context()
+
+	auth, err := ph.paymentService.ReversePayment(ctx, paymentID, reason)
+	if err != nil {
+		if errors.Is(err, services.ErrAuthorizationNotFound) {
+			http.Error(w, "Payment authorization not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Payment reversal error: %v", err)
+		http.Error(w, "Payment reversal failed", http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(auth); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// ListPersonas handles requests for the mock gateway's deterministic test personas
+func (ph *PaymentHandlers) ListPersonas(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(ph.paymentService.Personas()); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
 // SimulatePaymentFailure handles payment failure simulation requests
 func (ph *PaymentHandlers) SimulatePaymentFailure(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -92,14 +199,12 @@ func (ph *PaymentHandlers) SimulatePaymentFailure(w http.ResponseWriter, r *http
 	}
 
 	// Validate request
-	if req.BookingID <= 0 || req.Amount <= 0 || req.UserID <= 0 {
+	if req.BookingID <= 0 || !req.Amount.IsPositive() || req.Amount.Currency != models.DefaultCurrency || req.UserID <= 0 {
 		http.Error(w, "Invalid booking ID, amount, or user ID", http.StatusBadRequest)
 		return
 	}
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
-	defer cancel()
+	ctx := r.Context()
 
 	// Simulate payment failure
 	response, err := ph.paymentService.SimulatePaymentFailure(ctx, &req)
@@ -137,14 +242,12 @@ func (ph *PaymentHandlers) SimulatePaymentTimeout(w http.ResponseWriter, r *http
 	}
 
 	// Validate request
-	if req.BookingID <= 0 || req.Amount <= 0 || req.UserID <= 0 {
+	if req.BookingID <= 0 || !req.Amount.IsPositive() || req.Amount.Currency != models.DefaultCurrency || req.UserID <= 0 {
 		http.Error(w, "Invalid booking ID, amount, or user ID", http.StatusBadRequest)
 		return
 	}
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
-	defer cancel()
+	ctx := r.Context()
 
 	// Simulate payment timeout
 	response, err := ph.paymentService.SimulatePaymentTimeout(ctx, &req)
@@ -182,14 +285,12 @@ func (ph *PaymentHandlers) SimulatePaymentSuccess(w http.ResponseWriter, r *http
 	}
 
 	// Validate request
-	if req.BookingID <= 0 || req.Amount <= 0 || req.UserID <= 0 {
+	if req.BookingID <= 0 || !req.Amount.IsPositive() || req.Amount.Currency != models.DefaultCurrency || req.UserID <= 0 {
 		http.Error(w, "Invalid booking ID, amount, or user ID", http.StatusBadRequest)
 		return
 	}
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
-	defer cancel()
+	ctx := r.Context()
 
 	// Simulate payment success
 	response, err := ph.paymentService.SimulatePaymentSuccess(ctx, &req)
@@ -211,3 +312,144 @@ func (ph *PaymentHandlers) SimulatePaymentSuccess(w http.ResponseWriter, r *http
 
 	log.Printf("Payment success simulated: BookingID=%d", req.BookingID)
 }
+
+// GetRates handles requests for the mock gateway's current rates and rate schedule
+func (ph *PaymentHandlers) GetRates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := adminIdentity(r); !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(ph.paymentService.Rates(time.Now())); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// PutRates handles replacing the mock gateway's rate schedule
+func (ph *PaymentHandlers) PutRates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := adminIdentity(r)
+	if !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	var schedule models.PaymentRateSchedule
+	if err := json.NewDecoder(r.Body).Decode(&schedule); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := ph.paymentService.SetRateSchedule(schedule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("AUDIT: payment rate schedule set to %d windows by %s", len(schedule.Windows), admin)
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(ph.paymentService.Rates(time.Now())); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// ListAuditLog handles requests for payment audit log entries, decrypted when the key is set
+func (ph *PaymentHandlers) ListAuditLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := adminIdentity(r)
+	if !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	from := int64(1)
+	if value := r.URL.Query().Get("from"); value != "" {
+		var err error
+		from, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || from < 1 {
+			http.Error(w, "from must be a positive sequence number", http.StatusBadRequest)
+			return
+		}
+	}
+	limit := defaultAuditEntries
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxAuditEntries {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxAuditEntries), http.StatusBadRequest)
+			return
+		}
+	}
+
+	entries, err := ph.paymentService.AuditLogEntries(from, limit)
+	if err != nil {
+		log.Printf("Payment audit log read error: %v", err)
+		http.Error(w, "Failed to read payment audit log", http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(models.AuditLogEntriesResponse{Entries: entries}); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		return
+	}
+
+	log.Printf("AUDIT: payment audit log entries from %d read by %s", from, admin)
+}
+
+// VerifyAuditLog handles requests to check the payment audit log's hash chain
+func (ph *PaymentHandlers) VerifyAuditLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := adminIdentity(r); !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	report, err := ph.paymentService.VerifyAuditLog()
+	if err != nil {
+		log.Printf("Payment audit log verification error: %v", err)
+		http.Error(w, "Failed to verify payment audit log", http.StatusInternalServerError)
+		return
+	}
+	if !report.Valid {
+		log.Printf("Payment audit log chain broken at entry %d: %s", report.BrokenAt, report.Error)
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
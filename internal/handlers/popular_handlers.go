@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"cred_flights_booking/internal/middleware"
+	"cred_flights_booking/internal/services"
+)
+
+// GetPopularRoutes serves the precomputed popular routes with their cheapest fares. Clients
+// accepting Brotli get the stored compressed body as is; the response is never built per request.
+func (fh *FlightHandlers) GetPopularRoutes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	encoding := services.PopularRoutesJSON
+	if middleware.AcceptsEncoding(r.Header.Get("Accept-Encoding"), services.PopularRoutesBrotli) {
+		encoding = services.PopularRoutesBrotli
+	}
+
+	ctx := r.Context()
+
+	body, err := fh.flightService.PopularRoutes(ctx, encoding)
+	if err != nil {
+		if errors.Is(err, services.ErrPopularRoutesUnavailable) {
+			w.Header().Set("Retry-After", "60")
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		log.Printf("Popular routes error: %v", err)
+		http.Error(w, "Failed to load popular routes", http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(fh.flightService.PopularRoutesMaxAge().Seconds())))
+	if encoding == services.PopularRoutesBrotli {
+		w.Header().Set("Content-Encoding", services.PopularRoutesBrotli)
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write(body); err != nil {
+		log.Printf("Failed to write popular routes: %v", err)
+	}
+}
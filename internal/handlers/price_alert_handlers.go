@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cred_flights_booking/internal/services"
+	"cred_flights_booking/pkg/models"
+)
+
+// PriceAlertHandlers handles price alert HTTP requests
+type PriceAlertHandlers struct {
+	priceAlertService *services.PriceAlertService
+}
+
+// NewPriceAlertHandlers creates new price alert handlers
+func NewPriceAlertHandlers(priceAlertService *services.PriceAlertService) *PriceAlertHandlers {
+	return &PriceAlertHandlers{
+		priceAlertService: priceAlertService,
+	}
+}
+
+// CreateAlert handles price alert subscription requests
+func (ph *PriceAlertHandlers) CreateAlert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse request body
+	var req models.PriceAlertRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(time.Now().UTC()); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid price alert: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	alert, err := ph.priceAlertService.CreateAlert(ctx, &req)
+	if err != nil {
+		if errors.Is(err, services.ErrTooManyAlerts) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		log.Printf("Price alert creation error: %v", err)
+		http.Error(w, fmt.Sprintf("Price alert creation failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	if err := json.NewEncoder(w).Encode(alert); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ListAlerts handles requests for a user's price alerts
+func (ph *PriceAlertHandlers) ListAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+	if err != nil || userID <= 0 {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	alerts, err := ph.priceAlertService.ListAlerts(ctx, userID)
+	if err != nil {
+		log.Printf("Price alert listing error: %v", err)
+		http.Error(w, "Failed to list price alerts", http.StatusInternalServerError)
+		return
+	}
+
+	response := models.PriceAlertsResponse{
+		UserID: userID,
+		Alerts: alerts,
+		Count:  len(alerts),
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// CancelAlert handles requests to stop an active price alert
+func (ph *PriceAlertHandlers) CancelAlert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	alertID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || alertID <= 0 {
+		http.Error(w, "Invalid price alert ID", http.StatusBadRequest)
+		return
+	}
+	userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+	if err != nil || userID <= 0 {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	alert, err := ph.priceAlertService.CancelAlert(ctx, alertID, userID)
+	if err != nil {
+		if errors.Is(err, services.ErrPriceAlertNotFound) {
+			http.Error(w, "Active price alert not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Price alert cancellation error: %v", err)
+		http.Error(w, "Failed to cancel price alert", http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(alert); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// ExportUserData handles requests from operators with the privacy role for a user's data
+// export bundle
+func (bh *BookingHandlers) ExportUserData(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := adminIdentity(r)
+	if !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+	if !adminRoles(admin)[RolePrivacy] {
+		http.Error(w, "Privacy role required", http.StatusForbidden)
+		return
+	}
+
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || userID <= 0 {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	export, err := bh.bookingService.ExportUserData(ctx, userID)
+	if err != nil {
+		log.Printf("User data export error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to export user data: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="user-%d-export.json"`, userID))
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(export); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("AUDIT: data for user %d exported by %s", userID, admin)
+}
+
+// EraseUserData handles requests from operators with the privacy role to anonymize a user's
+// personal data
+func (bh *BookingHandlers) EraseUserData(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := adminIdentity(r)
+	if !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+	if !adminRoles(admin)[RolePrivacy] {
+		http.Error(w, "Privacy role required", http.StatusForbidden)
+		return
+	}
+
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || userID <= 0 {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	record, err := bh.bookingService.EraseUserData(ctx, userID, admin)
+	if err != nil {
+		log.Printf("User data erasure error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to erase user data: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(record); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("AUDIT: personal data for user %d erased by %s (%d bookings anonymized, erasure %d)",
+		userID, admin, record.BookingsAnonymized, record.ID)
+}
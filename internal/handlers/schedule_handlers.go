@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"cred_flights_booking/internal/services"
+	"cred_flights_booking/pkg/models"
+)
+
+// ScheduleHandlers handles flight schedule HTTP requests
+type ScheduleHandlers struct {
+	scheduleService *services.ScheduleService
+}
+
+// NewScheduleHandlers creates new schedule handlers
+func NewScheduleHandlers(scheduleService *services.ScheduleService) *ScheduleHandlers {
+	return &ScheduleHandlers{
+		scheduleService: scheduleService,
+	}
+}
+
+// CreateSchedule handles schedule creation requests
+func (sh *ScheduleHandlers) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := adminIdentity(r)
+	if !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse request body
+	var schedule models.FlightSchedule
+	if err := json.NewDecoder(r.Body).Decode(&schedule); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := schedule.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid schedule: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	created, err := sh.scheduleService.CreateSchedule(ctx, &schedule)
+	if err != nil {
+		log.Printf("Schedule creation error: %v", err)
+		http.Error(w, fmt.Sprintf("Schedule creation failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	if err := json.NewEncoder(w).Encode(created); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("AUDIT: schedule %d (%s) created by %s", created.ID, created.FlightNumber, admin)
+}
+
+// ListSchedules handles schedule listing requests
+func (sh *ScheduleHandlers) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := adminIdentity(r); !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := r.Context()
+
+	schedules, err := sh.scheduleService.ListSchedules(ctx)
+	if err != nil {
+		log.Printf("Schedule listing error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to list schedules: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	response := map[string]interface{}{
+		"schedules": schedules,
+		"count":     len(schedules),
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// MaterializeSchedules handles requests to generate flights from schedules immediately
+func (sh *ScheduleHandlers) MaterializeSchedules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := adminIdentity(r)
+	if !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := r.Context()
+
+	response, err := sh.scheduleService.Materialize(ctx)
+	if err != nil {
+		log.Printf("Schedule materialization error: %v", err)
+		http.Error(w, fmt.Sprintf("Materialization failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("AUDIT: schedules materialized by %s (%d flights created)", admin, response.Created)
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/services"
+)
+
+// maxSearchAnomalies bounds the anomalies listed in one report
+const maxSearchAnomalies = 500
+
+// SearchAnalyticsHandlers handles search activity tracking and abuse reports
+type SearchAnalyticsHandlers struct {
+	analyticsService *services.SearchAnalyticsService
+	trustForwarded   bool // Identify clients by X-Forwarded-For, when behind a trusted proxy
+}
+
+// NewSearchAnalyticsHandlers creates new search analytics handlers
+func NewSearchAnalyticsHandlers(analyticsService *services.SearchAnalyticsService) *SearchAnalyticsHandlers {
+	return &SearchAnalyticsHandlers{
+		analyticsService: analyticsService,
+		trustForwarded:   config.GetBool("SEARCH_ABUSE_TRUST_FORWARDED", false),
+	}
+}
+
+// clientIP returns the address a request came from
+func (sh *SearchAnalyticsHandlers) clientIP(r *http.Request) string {
+	if sh.trustForwarded {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			if ip := strings.TrimSpace(first); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Track wraps a search handler so each search counts against the client's IP and, when
+// given, its user_id. Throttled clients over their rate get 429; tracking errors never
+// fail the search.
+func (sh *SearchAnalyticsHandlers) Track(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		source := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("source")))
+		destination := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("destination")))
+		date := r.URL.Query().Get("date")
+		if source == "" || destination == "" || date == "" {
+			next(w, r)
+			return
+		}
+
+		subjects := []string{"ip:" + sh.clientIP(r)}
+		if userID, err := strconv.Atoi(r.URL.Query().Get("user_id")); err == nil && userID > 0 {
+			subjects = append(subjects, "user:"+strconv.Itoa(userID))
+		}
+
+		retryAfter, err := sh.analyticsService.RecordSearch(r.Context(), subjects, source+"-"+destination, date)
+		if err != nil {
+			if errors.Is(err, services.ErrSearchThrottled) {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				http.Error(w, "Too many searches, slow down", http.StatusTooManyRequests)
+				return
+			}
+			log.Printf("Search tracking error: %v", err)
+		}
+
+		next(w, r)
+	}
+}
+
+// GetAnomalies handles admin requests for recently flagged search clients and global
+// search volume
+func (sh *SearchAnalyticsHandlers) GetAnomalies(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := adminIdentity(r); !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	limit := 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 || parsed > maxSearchAnomalies {
+			http.Error(w, "Invalid limit parameter (1-500)", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	ctx := r.Context()
+
+	report, err := sh.analyticsService.Anomalies(ctx, limit)
+	if err != nil {
+		log.Printf("Search anomaly report error: %v", err)
+		http.Error(w, "Failed to get search anomalies", http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetActivity handles admin requests for one client's searches in the current window
+// (subject=ip:203.0.113.7 or subject=user:42)
+func (sh *SearchAnalyticsHandlers) GetActivity(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := adminIdentity(r); !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	subject := r.URL.Query().Get("subject")
+	if !strings.HasPrefix(subject, "ip:") && !strings.HasPrefix(subject, "user:") {
+		http.Error(w, "Invalid subject parameter, expected ip:<address> or user:<id>", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	activity, err := sh.analyticsService.Activity(ctx, subject)
+	if err != nil {
+		log.Printf("Search activity error: %v", err)
+		http.Error(w, "Failed to get search activity", http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(activity); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ClearAnomaly handles admin requests to forgive a flagged client, lifting its throttle
+func (sh *SearchAnalyticsHandlers) ClearAnomaly(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := adminIdentity(r)
+	if !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	subject := r.PathValue("subject")
+	if subject == "" {
+		http.Error(w, "Missing subject", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := sh.analyticsService.ClearSubject(ctx, subject); err != nil {
+		if errors.Is(err, services.ErrSearchAnomalyNotFound) {
+			http.Error(w, "Search anomaly not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Search anomaly clear error: %v", err)
+		http.Error(w, "Failed to clear search anomaly", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("AUDIT: search anomaly for %s cleared by %s", subject, admin)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"cred_flights_booking/internal/services"
+	"cred_flights_booking/pkg/models"
+)
+
+// SearchJobHandlers handles async search job HTTP requests
+type SearchJobHandlers struct {
+	searchJobService *services.SearchJobService
+}
+
+// NewSearchJobHandlers creates new search job handlers
+func NewSearchJobHandlers(searchJobService *services.SearchJobService) *SearchJobHandlers {
+	return &SearchJobHandlers{
+		searchJobService: searchJobService,
+	}
+}
+
+// CreateJob handles requests to start an exhaustive search in the background
+func (sh *SearchJobHandlers) CreateJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse request body
+	var req models.SearchJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate request
+	req.Source = strings.ToUpper(strings.TrimSpace(req.Source))
+	req.Destination = strings.ToUpper(strings.TrimSpace(req.Destination))
+	if req.Source == "" || req.Destination == "" || req.Date == "" || req.Seats <= 0 {
+		http.Error(w, "Missing or invalid source, destination, date, or seats", http.StatusBadRequest)
+		return
+	}
+	if req.SortBy == "" {
+		req.SortBy = "cheapest"
+	}
+	if req.SortBy != "cheapest" && req.SortBy != "fastest" && req.SortBy != "recommended" {
+		http.Error(w, "Invalid sort_by. Must be 'cheapest', 'fastest', or 'recommended'", http.StatusBadRequest)
+		return
+	}
+	if req.MaxStops == 0 {
+		req.MaxStops = 3
+	}
+	if maxStops := sh.searchJobService.MaxStops(); req.MaxStops < 0 || req.MaxStops > maxStops {
+		http.Error(w, fmt.Sprintf("max_stops must be between 0 and %d", maxStops), http.StatusBadRequest)
+		return
+	}
+	for i, code := range req.Airlines {
+		req.Airlines[i] = strings.ToUpper(strings.TrimSpace(code))
+	}
+
+	ctx := r.Context()
+
+	job, err := sh.searchJobService.CreateJob(ctx, &req)
+	if err != nil {
+		if errors.Is(err, services.ErrSearchQueueFull) {
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		log.Printf("Search job creation error: %v", err)
+		http.Error(w, fmt.Sprintf("Search job creation failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	status := http.StatusAccepted
+	if job.Status == models.SearchJobCompleted {
+		status = http.StatusOK
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/flights/search/jobs/"+job.ID)
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetJob handles requests for a search job's progress and results
+func (sh *SearchJobHandlers) GetJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jobID := r.PathValue("id")
+	if jobID == "" {
+		http.Error(w, "Missing search job ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	job, err := sh.searchJobService.GetJob(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, services.ErrSearchJobNotFound) {
+			http.Error(w, "Search job not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Search job lookup error: %v", err)
+		http.Error(w, "Failed to get search job", http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
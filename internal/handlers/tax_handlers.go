@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"cred_flights_booking/internal/services"
+)
+
+// maxTaxReportDays bounds the date range of a tax report
+const maxTaxReportDays = 366
+
+// TaxHandlers handles tax report HTTP requests
+type TaxHandlers struct {
+	taxReportService *services.TaxReportService
+}
+
+// NewTaxHandlers creates new tax handlers
+func NewTaxHandlers(taxReportService *services.TaxReportService) *TaxHandlers {
+	return &TaxHandlers{
+		taxReportService: taxReportService,
+	}
+}
+
+// GetReport handles admin requests for taxes charged on bookings created over the from/to
+// query range (default: the current month to date)
+func (th *TaxHandlers) GetReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := adminIdentity(r); !ok {
+		http.Error(w, "Admin credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	today := time.Now().UTC()
+	from := r.URL.Query().Get("from")
+	if from == "" {
+		from = today.AddDate(0, 0, 1-today.Day()).Format("2006-01-02")
+	}
+	to := r.URL.Query().Get("to")
+	if to == "" {
+		to = today.Format("2006-01-02")
+	}
+	start, startErr := time.Parse("2006-01-02", from)
+	end, endErr := time.Parse("2006-01-02", to)
+	if startErr != nil || endErr != nil {
+		http.Error(w, "Invalid from or to date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	if end.Before(start) || end.Sub(start) >= maxTaxReportDays*24*time.Hour {
+		http.Error(w, "Date range must be between 1 and 366 days", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	report, err := th.taxReportService.Report(ctx, from, to)
+	if err != nil {
+		log.Printf("Tax report error: %v", err)
+		http.Error(w, "Failed to get tax report", http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
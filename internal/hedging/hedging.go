@@ -0,0 +1,270 @@
+package hedging
+
+import (
+	"context"
+	"expvar"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cred_flights_booking/internal/config"
+)
+
+// Hedging metrics, exposed via expvar (requests, hedged, hedge_wins, budget_exhausted)
+var metrics = expvar.NewMap("http_hedging")
+
+// Config controls request hedging
+type Config struct {
+	Enabled    bool          // Kill switch; when false every request is sent exactly once
+	Percentile float64       // Latency percentile after which a second attempt is sent
+	MinSamples int           // Observed requests needed before hedging starts
+	MinDelay   time.Duration // Lower bound on the hedge delay
+	MaxDelay   time.Duration // Upper bound on the hedge delay
+	Budget     float64       // Max fraction of requests that may be hedged
+}
+
+// LoadConfig loads hedging settings from the environment
+func LoadConfig() Config {
+	return Config{
+		Enabled:    config.GetBool("HTTP_HEDGING_ENABLED", false),
+		Percentile: config.GetFloat("HTTP_HEDGING_PERCENTILE", 0.95),
+		MinSamples: config.GetInt("HTTP_HEDGING_MIN_SAMPLES", 20),
+		MinDelay:   config.GetDuration("HTTP_HEDGING_MIN_DELAY", 10*time.Millisecond),
+		MaxDelay:   config.GetDuration("HTTP_HEDGING_MAX_DELAY", 2*time.Second),
+		Budget:     config.GetFloat("HTTP_HEDGING_BUDGET", 0.1),
+	}
+}
+
+// idempotentKey marks a request context as safe to send twice
+type idempotentKey struct{}
+
+// Idempotent marks requests made with the returned context as hedgeable even if
+// their method is not GET or HEAD (e.g. POST reads like flight validation)
+func Idempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+// hedgeable reports whether a request may be sent more than once
+func hedgeable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return true
+	}
+	marked, _ := req.Context().Value(idempotentKey{}).(bool)
+	return marked
+}
+
+// Transport sends a second attempt of a hedgeable request once the first has been
+// outstanding longer than the configured latency percentile, and returns whichever
+// answers first. The losing attempt is cancelled.
+type Transport struct {
+	Base http.RoundTripper // Defaults to http.DefaultTransport
+
+	cfg       Config
+	mu        sync.Mutex
+	latencies map[string]*window // Keyed by method and host
+	requests  atomic.Int64
+	hedged    atomic.Int64
+}
+
+// NewTransport creates a hedging transport
+func NewTransport(base http.RoundTripper, cfg Config) *Transport {
+	return &Transport{
+		Base:      base,
+		cfg:       cfg,
+		latencies: make(map[string]*window),
+	}
+}
+
+// attempt is the outcome of one copy of a request
+type attempt struct {
+	resp   *http.Response
+	err    error
+	cancel context.CancelFunc
+	start  time.Time
+	hedge  bool
+}
+
+// RoundTrip sends the request, hedging it when enabled and safe
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if !t.cfg.Enabled || !hedgeable(req) {
+		return base.RoundTrip(req)
+	}
+
+	metrics.Add("requests", 1)
+	t.requests.Add(1)
+	samples := t.window(req)
+
+	delay, ok := samples.percentile(t.cfg.Percentile, t.cfg.MinSamples)
+	if !ok {
+		start := time.Now()
+		resp, err := base.RoundTrip(req)
+		if err == nil {
+			samples.add(time.Since(start))
+		}
+		return resp, err
+	}
+	delay = min(max(delay, t.cfg.MinDelay), t.cfg.MaxDelay)
+
+	results := make(chan attempt, 2)
+	send := func(r *http.Request, hedge bool) {
+		ctx, cancel := context.WithCancel(req.Context())
+		start := time.Now()
+		resp, err := base.RoundTrip(r.WithContext(ctx))
+		results <- attempt{resp: resp, err: err, cancel: cancel, start: start, hedge: hedge}
+	}
+	go send(req, false)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	pending := 1
+	for {
+		select {
+		case <-timer.C:
+			hedgeReq, ok := t.hedgeRequest(req)
+			if !ok {
+				continue
+			}
+			pending++
+			metrics.Add("hedged", 1)
+			go send(hedgeReq, true)
+
+		case res := <-results:
+			pending--
+			if res.err == nil && res.resp.StatusCode < http.StatusInternalServerError {
+				samples.add(time.Since(res.start))
+				if res.hedge {
+					metrics.Add("hedge_wins", 1)
+				}
+				discard(results, pending)
+				res.resp.Body = &cancelOnClose{ReadCloser: res.resp.Body, cancel: res.cancel}
+				return res.resp, nil
+			}
+			// Wait for the other attempt if one is still in flight
+			if pending > 0 {
+				release(res)
+				continue
+			}
+			if res.err != nil {
+				res.cancel()
+				return nil, res.err
+			}
+			res.resp.Body = &cancelOnClose{ReadCloser: res.resp.Body, cancel: res.cancel}
+			return res.resp, nil
+		}
+	}
+}
+
+// hedgeRequest prepares the second attempt, if the hedging budget allows one
+func (t *Transport) hedgeRequest(req *http.Request) (*http.Request, bool) {
+	if float64(t.hedged.Load()+1) > t.cfg.Budget*float64(t.requests.Load()) {
+		metrics.Add("budget_exhausted", 1)
+		return nil, false
+	}
+
+	hedgeReq := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, false
+		}
+		hedgeReq.Body = body
+	}
+	t.hedged.Add(1)
+	return hedgeReq, true
+}
+
+// window returns the latency samples for the request's method and host
+func (t *Transport) window(req *http.Request) *window {
+	key := req.Method + " " + req.URL.Host
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w, ok := t.latencies[key]
+	if !ok {
+		w = &window{}
+		t.latencies[key] = w
+	}
+	return w
+}
+
+// discard releases attempts that finish after a winner was returned
+func discard(results chan attempt, pending int) {
+	if pending == 0 {
+		return
+	}
+	go func() {
+		for i := 0; i < pending; i++ {
+			release(<-results)
+		}
+	}()
+}
+
+// release cancels a losing attempt and closes its response body
+func release(a attempt) {
+	a.cancel()
+	if a.resp != nil {
+		io.Copy(io.Discard, a.resp.Body)
+		a.resp.Body.Close()
+	}
+}
+
+// cancelOnClose cancels the winning attempt's context once its body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the body and releases the attempt's context
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// windowSize is the number of recent latencies kept per destination
+const windowSize = 256
+
+// window is a ring buffer of recent request latencies
+type window struct {
+	mu      sync.Mutex
+	samples [windowSize]time.Duration
+	count   int
+	next    int
+}
+
+// add records a latency sample
+func (w *window) add(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % windowSize
+	if w.count < windowSize {
+		w.count++
+	}
+}
+
+// percentile returns the p-th latency percentile, or false with too few samples
+func (w *window) percentile(p float64, minSamples int) (time.Duration, bool) {
+	w.mu.Lock()
+	sorted := make([]time.Duration, w.count)
+	copy(sorted, w.samples[:w.count])
+	w.mu.Unlock()
+
+	if len(sorted) == 0 || len(sorted) < minSamples {
+		return 0, false
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	idx := int(p * float64(len(sorted)-1))
+	return sorted[idx], true
+}
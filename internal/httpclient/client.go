@@ -0,0 +1,118 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"expvar"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/hedging"
+	"cred_flights_booking/internal/tracing"
+	"cred_flights_booking/pkg/client"
+)
+
+// Connection metrics, exposed via expvar (new, reused, reused_idle, dial_errors)
+var connMetrics = expvar.NewMap("http_client_connections")
+
+// TransportConfig controls connection pooling for calls between services
+type TransportConfig struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int // 0 means unlimited
+	IdleConnTimeout     time.Duration
+	DialTimeout         time.Duration
+	KeepAlive           time.Duration
+	TLSHandshakeTimeout time.Duration
+}
+
+// LoadTransportConfig loads transport settings from the environment
+func LoadTransportConfig() TransportConfig {
+	return TransportConfig{
+		MaxIdleConns:        config.GetInt("HTTP_MAX_IDLE_CONNS", 200),
+		MaxIdleConnsPerHost: config.GetInt("HTTP_MAX_IDLE_CONNS_PER_HOST", 64),
+		MaxConnsPerHost:     config.GetInt("HTTP_MAX_CONNS_PER_HOST", 0),
+		IdleConnTimeout:     config.GetDuration("HTTP_IDLE_CONN_TIMEOUT", 90*time.Second),
+		DialTimeout:         config.GetDuration("HTTP_DIAL_TIMEOUT", 5*time.Second),
+		KeepAlive:           config.GetDuration("HTTP_KEEP_ALIVE", 30*time.Second),
+		TLSHandshakeTimeout: config.GetDuration("HTTP_TLS_HANDSHAKE_TIMEOUT", 5*time.Second),
+	}
+}
+
+// NewTransport creates a pooled transport. The default transport keeps only two idle
+// connections per host, so bursts of calls to one service keep opening new ones.
+func NewTransport(cfg TransportConfig) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: cfg.KeepAlive,
+	}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ExpectContinueTimeout: time.Second,
+		TLSClientConfig:       &tls.Config{MinVersion: tls.VersionTLS12},
+	}
+}
+
+// shared is the pooled transport used by every inter-service client in the process
+var shared = NewTransport(LoadTransportConfig())
+
+// NewClient creates a client for calls between services: it propagates the trace ID,
+// hedges idempotent reads when enabled, and reuses pooled connections
+func NewClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &tracing.Transport{
+			Base: hedging.NewTransport(&metricsTransport{base: shared}, hedging.LoadConfig()),
+		},
+	}
+}
+
+// ServiceConfig returns SDK client settings for calls to another service: the shared
+// client transport, and retries of idempotent calls per SERVICE_CLIENT_MAX_ATTEMPTS
+func ServiceConfig(baseURL string, timeout time.Duration) client.Config {
+	return client.Config{
+		BaseURL:      baseURL,
+		HTTPClient:   NewClient(timeout),
+		MaxAttempts:  config.GetInt("SERVICE_CLIENT_MAX_ATTEMPTS", 3),
+		RetryBackoff: config.GetDuration("SERVICE_CLIENT_RETRY_BACKOFF", 100*time.Millisecond),
+	}
+}
+
+// metricsTransport counts whether each request got a new or reused connection
+type metricsTransport struct {
+	base http.RoundTripper
+}
+
+// connTrace records connection reuse for one request
+var connTrace = &httptrace.ClientTrace{
+	GotConn: func(info httptrace.GotConnInfo) {
+		switch {
+		case !info.Reused:
+			connMetrics.Add("new", 1)
+		case info.WasIdle:
+			connMetrics.Add("reused_idle", 1)
+		default:
+			connMetrics.Add("reused", 1)
+		}
+	},
+	ConnectDone: func(network, addr string, err error) {
+		if err != nil {
+			connMetrics.Add("dial_errors", 1)
+		}
+	},
+}
+
+// RoundTrip attaches the connection trace and sends the request
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := httptrace.WithClientTrace(req.Context(), connTrace)
+	return t.base.RoundTrip(req.WithContext(ctx))
+}
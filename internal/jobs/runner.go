@@ -0,0 +1,63 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Job is a unit of background work run on a fixed interval
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Timeout  time.Duration // Per-run timeout; defaults to the interval
+	Run      func(ctx context.Context) error
+}
+
+// Start runs each job once immediately and then on its interval until ctx is cancelled
+func Start(ctx context.Context, jobs ...Job) {
+	for _, job := range jobs {
+		if job.Interval <= 0 {
+			log.Printf("Skipping job %s: interval must be positive", job.Name)
+			continue
+		}
+		go run(ctx, job)
+	}
+}
+
+// run executes a job loop
+func run(ctx context.Context, job Job) {
+	log.Printf("Starting job %s (every %v)", job.Name, job.Interval)
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		runOnce(ctx, job)
+
+		select {
+		case <-ctx.Done():
+			log.Printf("Stopping job %s", job.Name)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runOnce executes a single run of a job with its timeout
+func runOnce(ctx context.Context, job Job) {
+	timeout := job.Timeout
+	if timeout <= 0 {
+		timeout = job.Interval
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := job.Run(runCtx); err != nil {
+		log.Printf("Job %s failed after %v: %v", job.Name, time.Since(start), err)
+		return
+	}
+	log.Printf("Job %s completed in %v", job.Name, time.Since(start))
+}
@@ -0,0 +1,239 @@
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/status"
+)
+
+// Probe states
+const (
+	StateStarting = "starting"
+	StateReady    = "ready"
+	StateDraining = "draining"
+	StateNotReady = "not_ready"
+)
+
+// Config controls how a service starts, drains, and shuts down. The pod fields come from
+// the Kubernetes downward API and are empty outside a cluster.
+type Config struct {
+	Service          string
+	DrainDelay       time.Duration // How long a draining service keeps serving before it stops accepting connections
+	ShutdownTimeout  time.Duration // Deadline for in-flight requests once the drain delay has passed
+	ReadinessTimeout time.Duration // Deadline for each readiness check
+	PodName          string
+	PodNamespace     string
+	PodIP            string
+	NodeName         string
+}
+
+// LoadConfig reads LIFECYCLE_* settings and the downward API environment. shutdownTimeout
+// is the service's default, which must cover its longest request.
+func LoadConfig(service string, shutdownTimeout time.Duration) Config {
+	return Config{
+		Service:          service,
+		DrainDelay:       config.GetDuration("LIFECYCLE_DRAIN_DELAY", 5*time.Second),
+		ShutdownTimeout:  config.GetDuration("LIFECYCLE_SHUTDOWN_TIMEOUT", shutdownTimeout),
+		ReadinessTimeout: config.GetDuration("LIFECYCLE_READINESS_TIMEOUT", 2*time.Second),
+		PodName:          os.Getenv("POD_NAME"),
+		PodNamespace:     os.Getenv("POD_NAMESPACE"),
+		PodIP:            os.Getenv("POD_IP"),
+		NodeName:         os.Getenv("NODE_NAME"),
+	}
+}
+
+// Instance names this replica: the pod name, falling back to the hostname
+func (c Config) Instance() string {
+	if c.PodName != "" {
+		return c.PodName
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		return hostname
+	}
+	return c.Service
+}
+
+// ProbeResponse is the body of the health, startup, and readiness probes
+type ProbeResponse struct {
+	Status       string                    `json:"status"`
+	Service      string                    `json:"service"`
+	Instance     string                    `json:"instance"`
+	State        string                    `json:"state,omitempty"`
+	Dependencies []status.DependencyStatus `json:"dependencies,omitempty"`
+}
+
+// Lifecycle tracks whether a service has started and whether it is draining, and turns
+// SIGTERM, the preStop hook, and /quitquitquit into one ordered shutdown
+type Lifecycle struct {
+	cfg      Config
+	instance string
+
+	started  atomic.Bool
+	draining atomic.Bool
+
+	drainOnce  sync.Once
+	drainStart time.Time
+	onDrain    []func()
+
+	quitOnce sync.Once
+	quit     chan struct{}
+}
+
+// New creates a service's lifecycle
+func New(cfg Config) *Lifecycle {
+	lc := &Lifecycle{
+		cfg:      cfg,
+		instance: cfg.Instance(),
+		quit:     make(chan struct{}),
+	}
+	if cfg.PodName != "" {
+		log.Printf("Running as pod %s/%s (ip %s) on node %s", cfg.PodNamespace, cfg.PodName, cfg.PodIP, cfg.NodeName)
+	}
+	return lc
+}
+
+// Config returns the lifecycle's configuration
+func (lc *Lifecycle) Config() Config {
+	return lc.cfg
+}
+
+// Instance returns the name of this replica
+func (lc *Lifecycle) Instance() string {
+	return lc.instance
+}
+
+// MarkStarted passes the startup probe; call it once the service is listening
+func (lc *Lifecycle) MarkStarted() {
+	lc.started.Store(true)
+}
+
+// OnDrain registers fn to run when draining begins, e.g. to stop HTTP keep-alives
+func (lc *Lifecycle) OnDrain(fn func()) {
+	lc.onDrain = append(lc.onDrain, fn)
+}
+
+// Draining reports whether the service is draining
+func (lc *Lifecycle) Draining() bool {
+	return lc.draining.Load()
+}
+
+// Drain fails the readiness probe and returns once the drain delay has passed since
+// draining began, so load balancers stop routing here before the server stops accepting
+// connections. Safe to call from the preStop hook and again on SIGTERM; later calls only
+// wait out what is left of the delay.
+func (lc *Lifecycle) Drain() {
+	lc.drainOnce.Do(func() {
+		lc.drainStart = time.Now()
+		lc.draining.Store(true)
+		log.Printf("Draining %s for %v", lc.instance, lc.cfg.DrainDelay)
+		for _, fn := range lc.onDrain {
+			fn()
+		}
+	})
+	if remaining := lc.cfg.DrainDelay - time.Since(lc.drainStart); remaining > 0 {
+		time.Sleep(remaining)
+	}
+}
+
+// Quit asks the service to shut down, as SIGTERM does
+func (lc *Lifecycle) Quit() {
+	lc.quitOnce.Do(func() { close(lc.quit) })
+}
+
+// Wait blocks until SIGINT, SIGTERM, or Quit
+func (lc *Lifecycle) Wait() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	select {
+	case sig := <-signals:
+		log.Printf("Received %v", sig)
+	case <-lc.quit:
+		log.Printf("Shutdown requested")
+	}
+}
+
+// Shutdown drains the service, then gives in-flight requests ShutdownTimeout to finish
+func (lc *Lifecycle) Shutdown(server *http.Server) error {
+	lc.Drain()
+
+	ctx, cancel := context.WithTimeout(context.Background(), lc.cfg.ShutdownTimeout)
+	defer cancel()
+	return server.Shutdown(ctx)
+}
+
+// Mount registers the probes and lifecycle endpoints. readiness lists the dependencies
+// the service cannot serve without; guard protects the endpoints that change state.
+//
+//	GET  /health          liveness: the process is up
+//	GET  /health/startup  startup: 503 until MarkStarted
+//	GET  /health/ready    readiness: 503 while starting, draining, or a dependency is down
+//	GET  /prestop         preStop hook: drains and returns once the drain delay has passed
+//	POST /quitquitquit    drains and shuts down
+func (lc *Lifecycle) Mount(mux *http.ServeMux, readiness []status.Check, guard func(http.Handler) http.Handler) {
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		lc.writeProbe(w, http.StatusOK, &ProbeResponse{Status: "healthy"})
+	})
+
+	mux.HandleFunc("GET /health/startup", func(w http.ResponseWriter, r *http.Request) {
+		if !lc.started.Load() {
+			lc.writeProbe(w, http.StatusServiceUnavailable, &ProbeResponse{Status: "unhealthy", State: StateStarting})
+			return
+		}
+		lc.writeProbe(w, http.StatusOK, &ProbeResponse{Status: "healthy", State: StateReady})
+	})
+
+	mux.HandleFunc("GET /health/ready", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case !lc.started.Load():
+			lc.writeProbe(w, http.StatusServiceUnavailable, &ProbeResponse{Status: "unhealthy", State: StateStarting})
+			return
+		case lc.draining.Load():
+			lc.writeProbe(w, http.StatusServiceUnavailable, &ProbeResponse{Status: "unhealthy", State: StateDraining})
+			return
+		}
+
+		report := status.Collect(r.Context(), status.Config{Service: lc.cfg.Service, Checks: readiness, Timeout: lc.cfg.ReadinessTimeout})
+		if report.Status != status.StatusOK {
+			lc.writeProbe(w, http.StatusServiceUnavailable, &ProbeResponse{Status: "unhealthy", State: StateNotReady, Dependencies: report.Dependencies})
+			return
+		}
+		lc.writeProbe(w, http.StatusOK, &ProbeResponse{Status: "healthy", State: StateReady, Dependencies: report.Dependencies})
+	})
+
+	mux.Handle("GET /prestop", guard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lc.Drain()
+		lc.writeProbe(w, http.StatusOK, &ProbeResponse{Status: "healthy", State: StateDraining})
+	})))
+
+	mux.Handle("POST /quitquitquit", guard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("AUDIT: shutdown of %s requested by %s", lc.instance, r.Header.Get("X-Admin-User"))
+		lc.writeProbe(w, http.StatusAccepted, &ProbeResponse{Status: "healthy", State: StateDraining})
+		lc.Quit()
+	})))
+}
+
+// writeProbe writes a probe response for this replica
+func (lc *Lifecycle) writeProbe(w http.ResponseWriter, statusCode int, response *ProbeResponse) {
+	response.Service = lc.cfg.Service
+	response.Instance = lc.instance
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
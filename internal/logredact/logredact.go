@@ -0,0 +1,75 @@
+package logredact
+
+import (
+	"io"
+	"log"
+	"regexp"
+	"strings"
+
+	"cred_flights_booking/internal/config"
+)
+
+// redacted replaces the value of a sensitive field
+const redacted = "[REDACTED]"
+
+// defaultFields are the fields whose values payment logs must not carry
+var defaultFields = []string{
+	"amount", "booking", "booking_id", "bookingid", "user", "user_id", "userid",
+	"payment_id", "paymentid", "card_number", "cvv",
+}
+
+// Config controls which log fields are redacted
+type Config struct {
+	Enabled bool
+	Fields  []string // Case-insensitive field names
+}
+
+// LoadConfig loads log redaction settings from the environment
+func LoadConfig() Config {
+	return Config{
+		Enabled: config.GetBool("LOG_REDACT_ENABLED", true),
+		Fields:  config.GetList("LOG_REDACT_FIELDS", defaultFields),
+	}
+}
+
+// Writer redacts the values of sensitive fields from log lines before writing them. A field
+// followed by "=" or ":" has its value redacted, and one followed by a space has a
+// following number redacted, so both "amount: 170.00" and "booking 42" are caught.
+type Writer struct {
+	out       io.Writer
+	separated *regexp.Regexp
+	spaced    *regexp.Regexp
+}
+
+// NewWriter wraps out, redacting the given fields
+func NewWriter(out io.Writer, fields []string) *Writer {
+	quoted := make([]string, len(fields))
+	for i, field := range fields {
+		quoted[i] = regexp.QuoteMeta(strings.TrimSpace(field))
+	}
+	names := `(?i)\b(` + strings.Join(quoted, "|") + `)`
+
+	return &Writer{
+		out:       out,
+		separated: regexp.MustCompile(names + `(\s*[=:]\s*)("[^"]*"|[^\s,;)]+)`),
+		spaced:    regexp.MustCompile(names + `(\s+#?)(\d[\w.\-]*)`),
+	}
+}
+
+// Write redacts one log line and writes it
+func (w *Writer) Write(p []byte) (int, error) {
+	line := w.separated.ReplaceAll(p, []byte("${1}${2}"+redacted))
+	line = w.spaced.ReplaceAll(line, []byte("${1}${2}"+redacted))
+	if _, err := w.out.Write(line); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Install redacts everything written through the standard logger
+func Install(cfg Config) {
+	if !cfg.Enabled || len(cfg.Fields) == 0 {
+		return
+	}
+	log.SetOutput(NewWriter(log.Writer(), cfg.Fields))
+}
@@ -0,0 +1,188 @@
+package middleware
+
+import (
+	"expvar"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/pkg/models"
+)
+
+// Chaos metrics, exposed via expvar (latency_injected, errors_injected, connections_dropped)
+var chaosMetrics = expvar.NewMap("chaos")
+
+// Fault kinds a chaos rule can inject
+const (
+	FaultLatency = "latency"
+	FaultError   = "error"
+	FaultDrop    = "drop"
+)
+
+// ChaosRule injects one kind of fault into a percentage of the requests matching a route
+type ChaosRule struct {
+	Method  string        // Empty matches every method
+	Prefix  string        // Path prefix; "/" matches every path
+	Fault   string        // FaultLatency, FaultError, or FaultDrop
+	Percent float64       // Share of matching requests affected, 0-100
+	Latency time.Duration // Delay added by FaultLatency
+	Status  int           // Status returned by FaultError
+}
+
+// ChaosConfig controls fault injection, for exercising retries, hedging, and load shedding
+// outside production
+type ChaosConfig struct {
+	Enabled        bool
+	Rules          []ChaosRule
+	ExemptPrefixes []string // Paths never faulted (health checks, diagnostics)
+}
+
+// LoadChaosConfig loads fault injection settings from the environment. CHAOS_RULES lists
+// "route:fault:percent[:param]" entries separated by commas, where route is "METHOD /prefix",
+// "/prefix", or "*", and param is the delay of a latency fault or the status of an error
+// fault. Invalid entries are logged and skipped.
+func LoadChaosConfig() ChaosConfig {
+	cfg := ChaosConfig{
+		Enabled:        config.GetBool("CHAOS_ENABLED", false),
+		ExemptPrefixes: []string{"/health", "/debug/", "/internal/status", "/prestop", "/quitquitquit"},
+	}
+	if !cfg.Enabled {
+		return cfg
+	}
+
+	for _, entry := range config.GetList("CHAOS_RULES", nil) {
+		rule, err := parseChaosRule(entry)
+		if err != nil {
+			log.Printf("Ignoring chaos rule %q: %v", entry, err)
+			continue
+		}
+		cfg.Rules = append(cfg.Rules, rule)
+	}
+	return cfg
+}
+
+// parseChaosRule parses one CHAOS_RULES entry
+func parseChaosRule(entry string) (ChaosRule, error) {
+	parts := strings.Split(entry, ":")
+	if len(parts) < 3 || len(parts) > 4 {
+		return ChaosRule{}, fmt.Errorf("expected route:fault:percent[:param]")
+	}
+
+	var rule ChaosRule
+	route := strings.TrimSpace(parts[0])
+	if method, path, ok := strings.Cut(route, " "); ok {
+		rule.Method, route = strings.ToUpper(method), strings.TrimSpace(path)
+	}
+	switch {
+	case route == "*":
+		rule.Prefix = "/"
+	case strings.HasPrefix(route, "/"):
+		rule.Prefix = route
+	default:
+		return ChaosRule{}, fmt.Errorf("route must be *, /prefix, or METHOD /prefix")
+	}
+
+	percent, err := strconv.ParseFloat(strings.TrimSpace(parts[2]), 64)
+	if err != nil || percent <= 0 || percent > 100 {
+		return ChaosRule{}, fmt.Errorf("percent must be between 0 and 100")
+	}
+	rule.Percent = percent
+
+	param := ""
+	if len(parts) == 4 {
+		param = strings.TrimSpace(parts[3])
+	}
+
+	rule.Fault = strings.ToLower(strings.TrimSpace(parts[1]))
+	switch rule.Fault {
+	case FaultLatency:
+		rule.Latency, err = time.ParseDuration(param)
+		if err != nil || rule.Latency <= 0 {
+			return ChaosRule{}, fmt.Errorf("latency fault needs a positive delay")
+		}
+	case FaultError:
+		rule.Status = http.StatusServiceUnavailable
+		if param != "" {
+			rule.Status, err = strconv.Atoi(param)
+			if err != nil || rule.Status < 400 || rule.Status > 599 {
+				return ChaosRule{}, fmt.Errorf("error fault status must be 4xx or 5xx")
+			}
+		}
+	case FaultDrop:
+	default:
+		return ChaosRule{}, fmt.Errorf("fault must be %s, %s, or %s", FaultLatency, FaultError, FaultDrop)
+	}
+	return rule, nil
+}
+
+// matches reports whether a rule applies to a request
+func (rule ChaosRule) matches(r *http.Request) bool {
+	return (rule.Method == "" || rule.Method == r.Method) && strings.HasPrefix(r.URL.Path, rule.Prefix)
+}
+
+// Chaos injects latency, error responses, or dropped connections into a random share of
+// the requests matching each rule. Every matching rule rolls separately: latency is added
+// before the request continues, while an error or drop ends it. Disabled unless
+// CHAOS_ENABLED is set.
+func Chaos(cfg ChaosConfig) Middleware {
+	return func(next http.Handler) http.Handler {
+		if !cfg.Enabled || len(cfg.Rules) == 0 {
+			return next
+		}
+
+		log.Printf("WARNING: chaos fault injection is enabled with %d rules", len(cfg.Rules))
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, prefix := range cfg.ExemptPrefixes {
+				if strings.HasPrefix(r.URL.Path, prefix) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			for _, rule := range cfg.Rules {
+				if !rule.matches(r) || rand.Float64()*100 >= rule.Percent {
+					continue
+				}
+
+				switch rule.Fault {
+				case FaultLatency:
+					chaosMetrics.Add("latency_injected", 1)
+					timer := time.NewTimer(rule.Latency)
+					select {
+					case <-timer.C:
+					case <-r.Context().Done():
+						timer.Stop()
+						return
+					}
+				case FaultError:
+					chaosMetrics.Add("errors_injected", 1)
+					w.Header().Set("X-Chaos-Fault", FaultError)
+					writeError(w, rule.Status, models.ErrorCodeInjectedFault, "Fault injected by chaos testing")
+					return
+				case FaultDrop:
+					chaosMetrics.Add("connections_dropped", 1)
+					dropConnection(w)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// dropConnection closes the client connection without a response. Connections that can't
+// be hijacked (HTTP/2) are aborted by the server instead.
+func dropConnection(w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	conn.Close()
+}
@@ -0,0 +1,280 @@
+package middleware
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"expvar"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Response compression metrics, exposed via expvar
+var compressionMetrics = expvar.NewMap("http_compression")
+
+// Supported content encodings, in order of preference
+var supportedEncodings = []string{"gzip", "deflate"}
+
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(io.Discard)
+	},
+}
+
+var flateWriterPool = sync.Pool{
+	New: func() interface{} {
+		w, _ := flate.NewWriter(io.Discard, flate.DefaultCompression)
+		return w
+	},
+}
+
+// Compress negotiates gzip/deflate response compression for bodies of at least minSize bytes
+func Compress(minSize int) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			// Upgraded connections and HEAD requests are passed through untouched
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: w,
+				encoding:       encoding,
+				minSize:        minSize,
+				status:         http.StatusOK,
+			}
+			defer cw.Close()
+
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding picks the preferred supported encoding from an Accept-Encoding header
+func negotiateEncoding(header string) string {
+	if header == "" {
+		return ""
+	}
+
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = parsed
+			}
+		}
+		accepted[name] = quality
+	}
+
+	best, bestQuality := "", 0.0
+	for _, encoding := range supportedEncodings {
+		quality, ok := accepted[encoding]
+		if !ok {
+			quality, ok = accepted["*"]
+		}
+		if ok && quality > bestQuality {
+			best, bestQuality = encoding, quality
+		}
+	}
+	return best
+}
+
+// AcceptsEncoding reports whether an Accept-Encoding header allows an encoding, e.g. for
+// handlers serving precompressed bodies
+func AcceptsEncoding(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil && parsed <= 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// compressible reports whether a content type benefits from compression
+func compressible(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(strings.ToLower(mediaType))
+	if mediaType == "text/event-stream" {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "json") ||
+		strings.HasSuffix(mediaType, "xml") ||
+		mediaType == "application/javascript"
+}
+
+// countingWriter counts the bytes written to the underlying writer
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// compressWriter buffers the response until it knows whether compression is worthwhile
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+	status   int
+	buf      []byte
+	decided  bool
+	encoder  io.WriteCloser
+	flusher  interface{ Flush() error }
+	output   *countingWriter
+	bytesIn  int64
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.decided {
+		return
+	}
+	cw.status = status
+
+	// Bodiless responses never get compressed
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		cw.passthrough()
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.minSize {
+			return len(p), nil
+		}
+		if err := cw.decide(); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	if cw.encoder != nil {
+		cw.bytesIn += int64(len(p))
+		return cw.encoder.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// decide starts compressing if the response is eligible, then flushes the buffer
+func (cw *compressWriter) decide() error {
+	header := cw.Header()
+	if header.Get("Content-Encoding") != "" || !compressible(header.Get("Content-Type")) {
+		return cw.passthrough()
+	}
+
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+	header.Set("Content-Encoding", cw.encoding)
+	header.Del("Content-Length")
+	cw.ResponseWriter.WriteHeader(cw.status)
+	cw.decided = true
+
+	cw.output = &countingWriter{w: cw.ResponseWriter}
+	switch cw.encoding {
+	case "gzip":
+		gz := gzipWriterPool.Get().(*gzip.Writer)
+		gz.Reset(cw.output)
+		cw.encoder, cw.flusher = gz, gz
+	case "deflate":
+		fl := flateWriterPool.Get().(*flate.Writer)
+		fl.Reset(cw.output)
+		cw.encoder, cw.flusher = fl, fl
+	}
+
+	buffered := cw.buf
+	cw.buf = nil
+	cw.bytesIn += int64(len(buffered))
+	_, err := cw.encoder.Write(buffered)
+	return err
+}
+
+// passthrough writes the response uncompressed
+func (cw *compressWriter) passthrough() error {
+	cw.decided = true
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buffered := cw.buf
+	cw.buf = nil
+	if len(buffered) == 0 {
+		return nil
+	}
+	_, err := cw.ResponseWriter.Write(buffered)
+	return err
+}
+
+// Flush sends any buffered data to the client
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if len(cw.buf) == 0 {
+			return
+		}
+		if err := cw.decide(); err != nil {
+			return
+		}
+	}
+	if cw.flusher != nil {
+		cw.flusher.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Close finishes the response and records compression metrics
+func (cw *compressWriter) Close() error {
+	if !cw.decided {
+		// Small responses are sent as-is
+		if len(cw.buf) == 0 && cw.status == http.StatusOK {
+			return nil
+		}
+		return cw.passthrough()
+	}
+	if cw.encoder == nil {
+		return nil
+	}
+
+	err := cw.encoder.Close()
+	switch enc := cw.encoder.(type) {
+	case *gzip.Writer:
+		gzipWriterPool.Put(enc)
+	case *flate.Writer:
+		flateWriterPool.Put(enc)
+	}
+	cw.encoder = nil
+
+	compressionMetrics.Add("responses_compressed", 1)
+	compressionMetrics.Add("bytes_in", cw.bytesIn)
+	compressionMetrics.Add("bytes_out", cw.output.n)
+	compressionMetrics.Add("bytes_saved", cw.bytesIn-cw.output.n)
+	return err
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cred_flights_booking/internal/config"
+)
+
+// CORSConfig controls which browser origins may call the APIs
+type CORSConfig struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// LoadCORSConfig reads the CORS configuration from environment variables
+func LoadCORSConfig() CORSConfig {
+	return CORSConfig{
+		AllowedOrigins:   config.GetList("CORS_ALLOWED_ORIGINS", nil),
+		AllowedMethods:   config.GetList("CORS_ALLOWED_METHODS", []string{"GET", "POST", "PUT", "DELETE"}),
+		AllowedHeaders:   config.GetList("CORS_ALLOWED_HEADERS", []string{"Content-Type", "Authorization", "X-Admin-User", "X-Admin-Token", "X-Session-ID"}),
+		ExposedHeaders:   config.GetList("CORS_EXPOSED_HEADERS", nil),
+		AllowCredentials: config.GetBool("CORS_ALLOW_CREDENTIALS", false),
+		MaxAge:           config.GetDuration("CORS_MAX_AGE", 10*time.Minute),
+	}
+}
+
+// allowsOrigin reports whether the origin is in the allow list ("*" allows any origin)
+func (c CORSConfig) allowsOrigin(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// CORS answers preflight requests and adds CORS headers for allowed origins.
+// With no allowed origins configured, requests pass through unchanged.
+func CORS(cfg CORSConfig) Middleware {
+	allowedMethods := strings.Join(cfg.AllowedMethods, ", ")
+	allowedHeaders := strings.Join(cfg.AllowedHeaders, ", ")
+	exposedHeaders := strings.Join(cfg.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		if len(cfg.AllowedOrigins) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			header := w.Header()
+			header.Add("Vary", "Origin")
+			if !cfg.allowsOrigin(origin) {
+				// Browsers block the response without the CORS headers
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			header.Set("Access-Control-Allow-Origin", origin)
+			if cfg.AllowCredentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			// Preflight requests are answered here and never reach the mux
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				header.Add("Vary", "Access-Control-Request-Method")
+				header.Add("Vary", "Access-Control-Request-Headers")
+				header.Set("Access-Control-Allow-Methods", allowedMethods)
+				header.Set("Access-Control-Allow-Headers", allowedHeaders)
+				header.Set("Access-Control-Max-Age", maxAge)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			if exposedHeaders != "" {
+				header.Set("Access-Control-Expose-Headers", exposedHeaders)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"cred_flights_booking/pkg/models"
+)
+
+// writeError writes the standard JSON error envelope
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	header := w.Header()
+	header.Del("Content-Length")
+	header.Set("Content-Type", "application/json")
+	header.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+
+	response := models.ErrorResponse{
+		Error:  message,
+		Code:   code,
+		Status: status,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode error response: %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+
+	"cred_flights_booking/internal/tracing"
+)
+
+// Middleware wraps an http.Handler with additional behaviour
+type Middleware func(http.Handler) http.Handler
+
+// Chain applies middlewares to a handler, with the first middleware being the outermost
+func Chain(handler http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// Group registers routes on a mux with a shared set of middlewares
+type Group struct {
+	mux         *http.ServeMux
+	middlewares []Middleware
+}
+
+// NewGroup creates a route group on the given mux
+func NewGroup(mux *http.ServeMux, middlewares ...Middleware) *Group {
+	return &Group{
+		mux:         mux,
+		middlewares: middlewares,
+	}
+}
+
+// HandleFunc registers a handler function for the pattern with the group's middlewares,
+// recording the pattern as the request's route for logs
+func (g *Group) HandleFunc(pattern string, handler http.HandlerFunc) {
+	routed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tracing.SetRoute(r.Context(), pattern)
+		handler(w, r)
+	})
+	g.mux.Handle(pattern, Chain(routed, g.middlewares...))
+}
@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"expvar"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/pkg/models"
+)
+
+// Load shedding metrics, exposed via expvar (requests_rejected, limit, inflight)
+var sheddingMetrics = expvar.NewMap("load_shedding")
+
+// ShedConfig controls the adaptive concurrency limit
+type ShedConfig struct {
+	Enabled        bool
+	InitialLimit   int
+	MinLimit       int
+	MaxLimit       int
+	TargetLatency  time.Duration // The limit shrinks while smoothed latency is above this
+	RetryAfter     time.Duration // Suggested client back-off for rejected requests
+	ExemptPrefixes []string      // Paths never shed (health checks, diagnostics)
+}
+
+// LoadShedConfig loads load shedding settings from the environment, with a per-service latency target
+func LoadShedConfig(targetLatency time.Duration) ShedConfig {
+	return ShedConfig{
+		Enabled:        config.GetBool("LOAD_SHED_ENABLED", true),
+		InitialLimit:   config.GetInt("LOAD_SHED_INITIAL_LIMIT", 100),
+		MinLimit:       config.GetInt("LOAD_SHED_MIN_LIMIT", 10),
+		MaxLimit:       config.GetInt("LOAD_SHED_MAX_LIMIT", 1000),
+		TargetLatency:  config.GetDuration("LOAD_SHED_TARGET_LATENCY", targetLatency),
+		RetryAfter:     config.GetDuration("LOAD_SHED_RETRY_AFTER", time.Second),
+		ExemptPrefixes: []string{"/health", "/debug/", "/internal/status", "/prestop", "/quitquitquit"},
+	}
+}
+
+// concurrencyLimiter adapts its limit with additive increase and multiplicative decrease:
+// it grows while latency stays under target and the limit is in use, and shrinks by 10%
+// at most once per latency window when smoothed latency exceeds the target
+type concurrencyLimiter struct {
+	mu           sync.Mutex
+	cfg          ShedConfig
+	limit        float64
+	inflight     int
+	latency      float64 // Exponentially weighted moving average, in nanoseconds
+	lastDecrease time.Time
+}
+
+// acquire admits a request if the service is under its current limit
+func (cl *concurrencyLimiter) acquire() bool {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if cl.inflight >= int(cl.limit) {
+		return false
+	}
+	cl.inflight++
+	sheddingMetrics.Set("inflight", intVar(cl.inflight))
+	return true
+}
+
+// release records a finished request's latency and adjusts the limit
+func (cl *concurrencyLimiter) release(elapsed time.Duration) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	utilized := float64(cl.inflight) >= cl.limit*0.8
+	cl.inflight--
+
+	if cl.latency == 0 {
+		cl.latency = float64(elapsed)
+	} else {
+		cl.latency = 0.9*cl.latency + 0.1*float64(elapsed)
+	}
+
+	now := time.Now()
+	target := float64(cl.cfg.TargetLatency)
+	switch {
+	case cl.latency > target && now.Sub(cl.lastDecrease) >= cl.cfg.TargetLatency:
+		cl.limit = math.Max(float64(cl.cfg.MinLimit), cl.limit*0.9)
+		cl.lastDecrease = now
+	case cl.latency <= target && utilized:
+		cl.limit = math.Min(float64(cl.cfg.MaxLimit), cl.limit+1/cl.limit)
+	}
+
+	sheddingMetrics.Set("inflight", intVar(cl.inflight))
+	sheddingMetrics.Set("limit", intVar(int(cl.limit)))
+}
+
+// intVar wraps an int for an expvar map
+func intVar(n int) *expvar.Int {
+	v := new(expvar.Int)
+	v.Set(int64(n))
+	return v
+}
+
+// LoadShed rejects requests beyond an adaptive concurrency limit with 503 and Retry-After,
+// so overload fails fast instead of queueing until downstream timeouts cascade
+func LoadShed(cfg ShedConfig) Middleware {
+	return func(next http.Handler) http.Handler {
+		if !cfg.Enabled {
+			return next
+		}
+
+		limiter := &concurrencyLimiter{cfg: cfg, limit: float64(cfg.InitialLimit)}
+		retryAfter := strconv.Itoa(int(math.Ceil(cfg.RetryAfter.Seconds())))
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Upgraded connections are long-lived and capped by their own handlers
+			if r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			for _, prefix := range cfg.ExemptPrefixes {
+				if strings.HasPrefix(r.URL.Path, prefix) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			if !limiter.acquire() {
+				sheddingMetrics.Add("requests_rejected", 1)
+				w.Header().Set("Retry-After", retryAfter)
+				writeError(w, http.StatusServiceUnavailable, models.ErrorCodeOverloaded, "Server is overloaded, retry later")
+				return
+			}
+
+			start := time.Now()
+			defer func() { limiter.release(time.Since(start)) }()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
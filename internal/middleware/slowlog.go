@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"cred_flights_booking/internal/tracing"
+)
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	if sr.status == 0 {
+		sr.status = status
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(p []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	return sr.ResponseWriter.Write(p)
+}
+
+// Flush sends any buffered data to the client
+func (sr *statusRecorder) Flush() {
+	if f, ok := sr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// SlowRequests logs requests that take longer than threshold (0 disables). Query parameter
+// names are logged without their values, which may hold personal data.
+func SlowRequests(threshold time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		if threshold <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Upgraded connections last as long as the client stays
+			if r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorder, r)
+
+			elapsed := time.Since(start)
+			if elapsed < threshold {
+				return
+			}
+
+			route := r.URL.Path
+			if info := tracing.FromContext(r.Context()); info != nil && info.Route != "" {
+				route = info.Route
+			}
+			params := make([]string, 0, len(r.URL.Query()))
+			for name := range r.URL.Query() {
+				params = append(params, name)
+			}
+			sort.Strings(params)
+
+			log.Printf("SLOW_REQUEST method=%s route=%q path=%q params=%q body_bytes=%d status=%d duration_ms=%d threshold_ms=%d trace_id=%s",
+				r.Method, route, r.URL.Path, strings.Join(params, ","), r.ContentLength, recorder.status,
+				elapsed.Milliseconds(), threshold.Milliseconds(), tracing.TraceID(r.Context()))
+		})
+	}
+}
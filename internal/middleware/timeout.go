@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"cred_flights_booking/pkg/models"
+)
+
+// Timeout bounds the request context with a deadline. A handler that returns without a
+// response, or answers with a server error, once the deadline has fired gets a 504
+// instead; a response the handler completed, even late, is sent as written, so work that
+// did finish (e.g. a confirmed booking) is never reported as failed. Handlers must honour
+// ctx.Done() for the deadline to cut work short.
+func Timeout(d time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			tw := &timeoutWriter{ResponseWriter: w, ctx: ctx}
+			next.ServeHTTP(tw, r.WithContext(ctx))
+
+			if !tw.wroteHeader && ctx.Err() == context.DeadlineExceeded {
+				tw.WriteHeader(http.StatusGatewayTimeout)
+			}
+		})
+	}
+}
+
+// timeoutWriter swaps the handler's server errors for a timeout error once the deadline has
+// passed, since they are most likely the deadline's doing
+type timeoutWriter struct {
+	http.ResponseWriter
+	ctx         context.Context
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	if tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+
+	if status >= http.StatusInternalServerError && tw.ctx.Err() == context.DeadlineExceeded {
+		tw.timedOut = true
+		log.Printf("Request deadline exceeded, returning %d instead of %d", http.StatusGatewayTimeout, status)
+		writeError(tw.ResponseWriter, http.StatusGatewayTimeout, models.ErrorCodeTimeout, "Request timed out")
+		return
+	}
+	tw.ResponseWriter.WriteHeader(status)
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	if !tw.wroteHeader {
+		tw.WriteHeader(http.StatusOK)
+	}
+	if tw.timedOut {
+		// Discard the handler's own error body
+		return len(p), nil
+	}
+	return tw.ResponseWriter.Write(p)
+}
+
+// Flush sends any buffered data to the client
+func (tw *timeoutWriter) Flush() {
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+
+	"cred_flights_booking/internal/tracing"
+	"github.com/google/uuid"
+)
+
+// maxTraceIDLength bounds caller-supplied trace and session IDs
+const maxTraceIDLength = 128
+
+// Trace assigns each request a trace ID, reusing the caller's X-Request-ID when present,
+// and echoes it in the response. A caller-supplied X-Session-ID is carried alongside it.
+func Trace() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			traceID := r.Header.Get(tracing.HeaderRequestID)
+			if traceID == "" || len(traceID) > maxTraceIDLength {
+				traceID = uuid.New().String()
+			}
+
+			sessionID := r.Header.Get(tracing.HeaderSessionID)
+			if len(sessionID) > maxTraceIDLength {
+				sessionID = ""
+			}
+
+			w.Header().Set(tracing.HeaderRequestID, traceID)
+			ctx := tracing.NewContext(r.Context(), &tracing.RequestInfo{TraceID: traceID, SessionID: sessionID})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"cred_flights_booking/pkg/models"
+)
+
+// Notification channels
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+)
+
+// Notification is a message to a single recipient on one channel
+type Notification struct {
+	Channel   string
+	Recipient string
+	Subject   string
+	Body      string
+	BookingID int
+	AlertID   int // Set for price alert notifications
+}
+
+// Sender delivers notifications, e.g. through an email or SMS provider
+type Sender interface {
+	Send(ctx context.Context, notification *Notification) error
+}
+
+// LogSender writes notifications to the log instead of delivering them
+type LogSender struct{}
+
+// Send logs the notification
+func (LogSender) Send(ctx context.Context, notification *Notification) error {
+	reference := fmt.Sprintf("booking %d", notification.BookingID)
+	if notification.AlertID > 0 {
+		reference = fmt.Sprintf("price alert %d", notification.AlertID)
+	}
+	log.Printf("NOTIFY %s to %s (%s): %s", notification.Channel, notification.Recipient, reference, notification.Subject)
+	return nil
+}
+
+// Notifier builds booking and price alert notifications and sends them to every contact
+type Notifier struct {
+	sender Sender
+}
+
+// NewNotifier creates a notifier using the given sender
+func NewNotifier(sender Sender) *Notifier {
+	return &Notifier{
+		sender: sender,
+	}
+}
+
+// SendBookingConfirmation sends the confirmation to the booking's email and phone,
+// returning the channels that were delivered
+func (n *Notifier) SendBookingConfirmation(ctx context.Context, booking *models.Booking) ([]string, error) {
+	subject := fmt.Sprintf("Booking %d confirmed", booking.ID)
+	body := confirmationBody(booking)
+
+	var notifications []*Notification
+	if booking.Email != "" {
+		notifications = append(notifications, &Notification{
+			Channel:   ChannelEmail,
+			Recipient: booking.Email,
+			Subject:   subject,
+			Body:      body,
+			BookingID: booking.ID,
+		})
+	}
+	if booking.Phone != "" {
+		notifications = append(notifications, &Notification{
+			Channel:   ChannelSMS,
+			Recipient: booking.Phone,
+			Subject:   subject,
+			Body:      subject + ". " + strings.SplitN(body, "\n", 2)[0],
+			BookingID: booking.ID,
+		})
+	}
+
+	return n.sendAll(ctx, notifications, fmt.Sprintf("confirmation for booking %d", booking.ID))
+}
+
+// SendReviewRequest asks a flown booking's traveller to review the trip,
+// returning the channels that were delivered
+func (n *Notifier) SendReviewRequest(ctx context.Context, booking *models.Booking) ([]string, error) {
+	subject := fmt.Sprintf("How was your trip? (booking %d)", booking.ID)
+	body := "Thanks for flying with us. Tell us how it went:\n" + strings.SplitN(confirmationBody(booking), "\n", 2)[0] + "\n"
+
+	var notifications []*Notification
+	if booking.Email != "" {
+		notifications = append(notifications, &Notification{
+			Channel:   ChannelEmail,
+			Recipient: booking.Email,
+			Subject:   subject,
+			Body:      body,
+			BookingID: booking.ID,
+		})
+	}
+	if booking.Phone != "" {
+		notifications = append(notifications, &Notification{
+			Channel:   ChannelSMS,
+			Recipient: booking.Phone,
+			Subject:   subject,
+			Body:      subject,
+			BookingID: booking.ID,
+		})
+	}
+
+	return n.sendAll(ctx, notifications, fmt.Sprintf("review request for booking %d", booking.ID))
+}
+
+// SendPriceAlert tells the subscriber that the route's lowest fare reached their target,
+// returning the channels that were delivered
+func (n *Notifier) SendPriceAlert(ctx context.Context, alert *models.PriceAlert, fare float64) ([]string, error) {
+	subject := fmt.Sprintf("Fares from %s to %s on %s dropped to %.2f", alert.Source, alert.Destination, alert.Date, fare)
+	body := fmt.Sprintf("%s\nYour target: %.2f per seat for %d seat(s)\nSearch now to book before the fare changes.\n",
+		subject, alert.TargetPrice, alert.Seats)
+
+	var notifications []*Notification
+	if alert.Email != "" {
+		notifications = append(notifications, &Notification{
+			Channel:   ChannelEmail,
+			Recipient: alert.Email,
+			Subject:   subject,
+			Body:      body,
+			AlertID:   alert.ID,
+		})
+	}
+	if alert.Phone != "" {
+		notifications = append(notifications, &Notification{
+			Channel:   ChannelSMS,
+			Recipient: alert.Phone,
+			Subject:   subject,
+			Body:      subject,
+			AlertID:   alert.ID,
+		})
+	}
+
+	return n.sendAll(ctx, notifications, fmt.Sprintf("price alert %d", alert.ID))
+}
+
+// sendAll sends each notification, succeeding if at least one channel was delivered
+func (n *Notifier) sendAll(ctx context.Context, notifications []*Notification, what string) ([]string, error) {
+	var sent []string
+	var errs []string
+	for _, notification := range notifications {
+		if err := n.sender.Send(ctx, notification); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", notification.Channel, err))
+			continue
+		}
+		sent = append(sent, notification.Channel)
+	}
+
+	if len(errs) > 0 && len(sent) == 0 {
+		return nil, fmt.Errorf("failed to send %s: %s", what, strings.Join(errs, "; "))
+	}
+	if len(errs) > 0 {
+		log.Printf("Partially sent %s: %s", what, strings.Join(errs, "; "))
+	}
+	return sent, nil
+}
+
+// confirmationBody renders the confirmation text, starting with a one-line itinerary
+func confirmationBody(booking *models.Booking) string {
+	var b strings.Builder
+	if len(booking.Segments) > 0 {
+		segment := booking.Segments[0]
+		fmt.Fprintf(&b, "%s %s-%s departing %s, %d seat(s)\n", segment.FlightNumber, segment.Source,
+			segment.Destination, segment.DepartureTime.Format("2006-01-02 15:04"), booking.Seats)
+	} else {
+		fmt.Fprintf(&b, "Flight %d on %s, %d seat(s)\n", booking.FlightID, booking.Date, booking.Seats)
+	}
+	fmt.Fprintf(&b, "Booking ID: %d\n", booking.ID)
+	fmt.Fprintf(&b, "Fare: %s\n", booking.FareCode)
+	for _, line := range booking.Taxes {
+		fmt.Fprintf(&b, "%s (%g%%): %s\n", line.Code, line.Rate, line.Amount)
+	}
+	fmt.Fprintf(&b, "Total paid: %s\n", booking.TotalAmount)
+	if booking.PaymentID != "" {
+		fmt.Fprintf(&b, "Payment reference: %s\n", booking.PaymentID)
+	}
+	return b.String()
+}
@@ -0,0 +1,152 @@
+package seatnonce
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/pkg/models"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrMissingNonce is returned when a seat update carries no nonce
+	ErrMissingNonce = errors.New("missing seat nonce")
+	// ErrInvalidNonce is returned when a nonce's signature matches no known secret or it
+	// was issued for another operation, flight, date, or fewer seats
+	ErrInvalidNonce = errors.New("invalid seat nonce")
+	// ErrNonceExpired is returned when a nonce is past its expiry
+	ErrNonceExpired = errors.New("seat nonce expired")
+	// ErrNonceUsed is returned when a nonce was already spent
+	ErrNonceUsed = errors.New("seat nonce already used")
+	// ErrNoSecrets is returned when nonces are spent without SEAT_NONCE_SECRETS configured
+	ErrNoSecrets = errors.New("SEAT_NONCE_SECRETS is not set")
+)
+
+// Config holds the secrets seat nonces are signed with and how long they are honoured
+type Config struct {
+	Secrets []string      // The first signs new nonces; all are accepted so secrets can rotate
+	TTL     time.Duration // How long a nonce can be spent
+}
+
+// LoadConfig loads seat nonce settings from the environment
+func LoadConfig() Config {
+	return Config{
+		Secrets: config.GetList("SEAT_NONCE_SECRETS", nil),
+		TTL:     config.GetDuration("SEAT_NONCE_TTL", 5*time.Minute),
+	}
+}
+
+// Validate checks that nonces can be issued and verified; seat updates are refused without secrets
+func (c Config) Validate() error {
+	if len(c.Secrets) == 0 {
+		return ErrNoSecrets
+	}
+	return nil
+}
+
+// Signer issues seat nonces and spends them, recording spent nonces in Redis
+type Signer struct {
+	secrets []string
+	ttl     time.Duration
+	cache   *database.RedisClient
+}
+
+// NewSigner creates a signer; without secrets no nonces are issued and every spend is refused
+func NewSigner(cfg Config, cache *database.RedisClient) *Signer {
+	return &Signer{
+		secrets: cfg.Secrets,
+		ttl:     cfg.TTL,
+		cache:   cache,
+	}
+}
+
+// Enabled reports whether nonces are issued
+func (s *Signer) Enabled() bool {
+	return len(s.secrets) > 0
+}
+
+// computeSignature returns the hex HMAC-SHA256 of a nonce's fields
+func computeSignature(secret string, nonce *models.SeatNonce) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s|%s|%d|%s|%s|%d|%d", nonce.ID, nonce.Operation, nonce.FlightID, nonce.Date,
+		nonce.Cabin, nonce.Seats, nonce.ExpiresAt.Unix())
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// nonceCabin returns the cabin a seat update applies to; an empty cabin is economy
+func nonceCabin(cabin string) string {
+	if cabin == "" {
+		return models.CabinEconomy
+	}
+	return cabin
+}
+
+// Issue returns a nonce for one operation on up to seats seats of a cabin (empty for
+// economy), or nil when nonces are disabled
+func (s *Signer) Issue(operation string, flightID int, date, cabin string, seats int) *models.SeatNonce {
+	if !s.Enabled() {
+		return nil
+	}
+
+	nonce := &models.SeatNonce{
+		ID:        uuid.New().String(),
+		Operation: operation,
+		FlightID:  flightID,
+		Date:      date,
+		Cabin:     nonceCabin(cabin),
+		Seats:     seats,
+		ExpiresAt: time.Now().Add(s.ttl).UTC().Truncate(time.Second),
+	}
+	nonce.Signature = computeSignature(s.secrets[0], nonce)
+	return nonce
+}
+
+// Spend checks that a nonce authorizes the operation on the request's flight, date, cabin,
+// and seats, then records it so it is refused if sent again. Without secrets every request is refused.
+func (s *Signer) Spend(ctx context.Context, operation string, req *models.SeatUpdateRequest) error {
+	if !s.Enabled() {
+		return ErrNoSecrets
+	}
+
+	nonce := req.Nonce
+	if nonce == nil || nonce.Signature == "" {
+		return ErrMissingNonce
+	}
+	if nonce.Operation != operation || nonce.FlightID != req.FlightID || nonce.Date != req.Date ||
+		nonce.Cabin != nonceCabin(req.Cabin) || nonce.Seats < req.Seats {
+		return ErrInvalidNonce
+	}
+
+	valid := false
+	for _, secret := range s.secrets {
+		if hmac.Equal([]byte(computeSignature(secret, nonce)), []byte(nonce.Signature)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return ErrInvalidNonce
+	}
+
+	remaining := time.Until(nonce.ExpiresAt)
+	if remaining <= 0 {
+		return ErrNonceExpired
+	}
+
+	// Expired nonces are refused anyway, so spent ones are only remembered until then
+	fresh, err := s.cache.SetNX(ctx, database.GenerateSeatNonceKey(nonce.ID), 1, remaining+time.Minute).Result()
+	if err != nil {
+		return fmt.Errorf("failed to record seat nonce: %w", err)
+	}
+	if !fresh {
+		return ErrNonceUsed
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"cred_flights_booking/pkg/models"
+)
+
+// Admin view sections
+const (
+	ViewSectionFlight       = "flight"
+	ViewSectionAvailability = "availability"
+	ViewSectionBookings     = "recent_bookings"
+	ViewSectionPayments     = "payment_stats"
+)
+
+// FlightViewOptions selects the sections of a flight view and how much detail they carry
+type FlightViewOptions struct {
+	Sections     map[string]bool
+	BookingLimit int
+	MaskContacts bool // Hide booking emails and phone numbers
+}
+
+// FlightView loads the selected sections of an admin flight view concurrently. A section
+// that fails is reported in the view's Errors instead of failing the whole view. An empty
+// date means the flight's departure date.
+func (bs *BookingServiceV2) FlightView(ctx context.Context, flightID int, date string, opts FlightViewOptions) (*models.AdminFlightView, error) {
+	view := &models.AdminFlightView{
+		FlightID:    flightID,
+		Date:        date,
+		GeneratedAt: time.Now().UTC(),
+	}
+
+	// Every other section is scoped to a date, so resolve the flight first
+	flight, err := bs.getFlightViaHTTP(ctx, flightID)
+	if err != nil {
+		return nil, err
+	}
+	if view.Date == "" {
+		view.Date = flight.DepartureTime.Format("2006-01-02")
+	}
+	if opts.Sections[ViewSectionFlight] {
+		view.Flight = flight
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs = make(map[string]string)
+	)
+	load := func(section string, fn func() error) {
+		if !opts.Sections[section] {
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(); err != nil {
+				mu.Lock()
+				errs[section] = err.Error()
+				mu.Unlock()
+			}
+		}()
+	}
+
+	load(ViewSectionAvailability, func() (err error) {
+		view.Availability, err = bs.getAvailabilityViaHTTP(ctx, flightID, view.Date)
+		return err
+	})
+	load(ViewSectionBookings, func() (err error) {
+		view.RecentBookings, err = bs.recentFlightBookings(ctx, flightID, view.Date, opts.BookingLimit, opts.MaskContacts)
+		return err
+	})
+	load(ViewSectionPayments, func() (err error) {
+		view.PaymentStats, err = bs.flightPaymentStats(ctx, flightID, view.Date)
+		return err
+	})
+	wg.Wait()
+
+	if len(errs) > 0 {
+		view.Errors = errs
+	}
+	return view, nil
+}
+
+// getAvailabilityViaHTTP gets the live seat counter for a flight date from the Flight Service
+func (bs *BookingServiceV2) getAvailabilityViaHTTP(ctx context.Context, flightID int, date string) (*models.SeatAvailabilityResponse, error) {
+	availability, err := bs.flights.GetAvailability(ctx, flightID, date)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get availability: %w", err)
+	}
+	return availability, nil
+}
+
+// recentFlightBookings returns a flight date's newest bookings
+func (bs *BookingServiceV2) recentFlightBookings(ctx context.Context, flightID int, date string, limit int, maskContacts bool) ([]models.Booking, error) {
+	bookings, err := bs.readModel.List(ctx, BookingFilter{FlightID: flightID, Date: date, Limit: limit})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list flight bookings: %w", err)
+	}
+
+	if maskContacts {
+		for i := range bookings {
+			bookings[i].Email = maskEmail(bookings[i].Email)
+			bookings[i].Phone = maskPhone(bookings[i].Phone)
+		}
+	}
+	return bookings, nil
+}
+
+// flightPaymentStats totals payments and refunds on a flight date's bookings
+func (bs *BookingServiceV2) flightPaymentStats(ctx context.Context, flightID int, date string) (*models.FlightPaymentStats, error) {
+	query := `
+		SELECT COUNT(*) FILTER (WHERE status IN ($3, $5)),
+		       COUNT(*) FILTER (WHERE status = $4),
+		       COALESCE(SUM(seats) FILTER (WHERE status IN ($3, $5)), 0),
+		       COALESCE(SUM(total_amount), 0),
+		       COALESCE(SUM(refund_amount), 0),
+		       COALESCE(SUM(cancellation_fee), 0),
+		       COALESCE(SUM(total_amount - COALESCE(refund_amount, 0)) FILTER (WHERE agency_id IS NOT NULL), 0)
+		FROM bookings
+		WHERE flight_id = $1 AND date = $2 AND status IN ($3, $4, $5)
+	`
+
+	var stats models.FlightPaymentStats
+	err := bs.db.QueryRowContext(ctx, query, flightID, date, models.BookingStatusConfirmed, models.BookingStatusCancelled,
+		models.BookingStatusCompleted).Scan(
+		&stats.ConfirmedBookings, &stats.CancelledBookings, &stats.SeatsSold, &stats.GrossRevenue,
+		&stats.Refunded, &stats.CancellationFees, &stats.AgencyRevenue,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payment stats: %w", err)
+	}
+	stats.NetRevenue = stats.GrossRevenue - stats.Refunded
+
+	return &stats, nil
+}
+
+// maskEmail keeps the first character of the local part and the domain
+func maskEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || local == "" {
+		return email
+	}
+	return local[:1] + "***@" + domain
+}
+
+// maskPhone keeps the last four digits
+func maskPhone(phone string) string {
+	if len(phone) <= 4 {
+		return phone
+	}
+	return strings.Repeat("*", len(phone)-4) + phone[len(phone)-4:]
+}
@@ -0,0 +1,415 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/pkg/models"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrAgencyNotFound is returned when an agency does not exist
+	ErrAgencyNotFound = errors.New("agency not found")
+	// ErrCreditLimitExceeded is returned when a charge would take an agency past its credit limit
+	ErrCreditLimitExceeded = errors.New("credit limit exceeded")
+	// ErrInvoiceNotFound is returned when an invoice does not exist for the agency
+	ErrInvoiceNotFound = errors.New("invoice not found")
+)
+
+// AgencyService manages agency accounts, their credit ledger, and invoicing
+type AgencyService struct {
+	db        *database.DB
+	cache     *database.RedisClient
+	readModel *BookingReadModel
+}
+
+// NewAgencyService creates a new agency service
+func NewAgencyService(db *database.DB, cache *database.RedisClient, readModel *BookingReadModel) *AgencyService {
+	return &AgencyService{
+		db:        db,
+		cache:     cache,
+		readModel: readModel,
+	}
+}
+
+// CreateAgency registers an agency and returns its newly generated API key
+func (as *AgencyService) CreateAgency(ctx context.Context, req *models.AgencyRequest) (*models.AgencyCreatedResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	apiKey := "ak_" + hex.EncodeToString(secret)
+
+	agency := models.Agency{
+		Name:        req.Name,
+		KeyPrefix:   apiKey[:10],
+		CreditLimit: req.CreditLimit,
+	}
+
+	query := `
+		INSERT INTO agencies (name, key_hash, key_prefix, credit_limit)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, active, created_at
+	`
+	err := as.db.QueryRowContext(ctx, query, agency.Name, hashAPIKey(apiKey), agency.KeyPrefix, agency.CreditLimit).
+		Scan(&agency.ID, &agency.Active, &agency.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create agency: %w", err)
+	}
+
+	return &models.AgencyCreatedResponse{Agency: agency, APIKey: apiKey}, nil
+}
+
+// agencyColumns lists the agency columns scanned by scanAgency
+const agencyColumns = `id, name, key_prefix, credit_limit, active, created_at`
+
+// scanAgency scans a row selected with agencyColumns
+func scanAgency(row rowScanner) (*models.Agency, error) {
+	var agency models.Agency
+	err := row.Scan(&agency.ID, &agency.Name, &agency.KeyPrefix, &agency.CreditLimit, &agency.Active, &agency.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &agency, nil
+}
+
+// ListAgencies returns all registered agencies
+func (as *AgencyService) ListAgencies(ctx context.Context) ([]models.Agency, error) {
+	rows, err := as.db.QueryContext(ctx, `SELECT `+agencyColumns+` FROM agencies ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query agencies: %w", err)
+	}
+	defer rows.Close()
+
+	agencies := []models.Agency{}
+	for rows.Next() {
+		agency, err := scanAgency(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan agency: %w", err)
+		}
+		agencies = append(agencies, *agency)
+	}
+	return agencies, rows.Err()
+}
+
+// GetAgency returns an agency by ID
+func (as *AgencyService) GetAgency(ctx context.Context, agencyID int) (*models.Agency, error) {
+	agency, err := scanAgency(as.db.QueryRowContext(ctx, `SELECT `+agencyColumns+` FROM agencies WHERE id = $1`, agencyID))
+	if err == sql.ErrNoRows {
+		return nil, ErrAgencyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query agency: %w", err)
+	}
+	return agency, nil
+}
+
+// Authenticate resolves an agency API key to its active agency
+func (as *AgencyService) Authenticate(ctx context.Context, apiKey string) (*models.Agency, error) {
+	if apiKey == "" {
+		return nil, ErrInvalidAPIKey
+	}
+
+	keyHash := hashAPIKey(apiKey)
+	cacheKey := database.GenerateAgencyKeyCacheKey(keyHash)
+
+	var agency *models.Agency
+	var cached models.Agency
+	if err := as.cache.GetJSON(ctx, cacheKey, &cached); err == nil {
+		agency = &cached
+	} else {
+		found, err := scanAgency(as.db.QueryRowContext(ctx,
+			`SELECT `+agencyColumns+` FROM agencies WHERE key_hash = $1`, keyHash))
+		if err == sql.ErrNoRows {
+			return nil, ErrInvalidAPIKey
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to query agency: %w", err)
+		}
+		agency = found
+
+		if err := as.cache.SetJSON(ctx, cacheKey, agency, 5*time.Minute); err != nil {
+			log.Printf("Failed to cache agency %d: %v", agency.ID, err)
+		}
+	}
+
+	if !agency.Active {
+		return nil, ErrInvalidAPIKey
+	}
+	return agency, nil
+}
+
+// outstandingQuery sums what an agency owes: uninvoiced entries plus entries on open invoices
+const outstandingQuery = `
+	SELECT COALESCE(SUM(l.amount) FILTER (WHERE l.invoice_id IS NULL), 0),
+	       COALESCE(SUM(l.amount) FILTER (WHERE i.status = 'open'), 0)
+	FROM agency_ledger l
+	LEFT JOIN agency_invoices i ON i.id = l.invoice_id
+	WHERE l.agency_id = $1
+`
+
+// GetAccount returns an agency's credit position
+func (as *AgencyService) GetAccount(ctx context.Context, agencyID int) (*models.AgencyAccount, error) {
+	agency, err := as.GetAgency(ctx, agencyID)
+	if err != nil {
+		return nil, err
+	}
+
+	account := &models.AgencyAccount{Agency: *agency}
+	if err := as.db.QueryRowContext(ctx, outstandingQuery, agencyID).Scan(&account.Unbilled, &account.Invoiced); err != nil {
+		return nil, fmt.Errorf("failed to query agency balance: %w", err)
+	}
+	account.Outstanding = account.Unbilled + account.Invoiced
+	account.Available = agency.CreditLimit - account.Outstanding
+	return account, nil
+}
+
+// Charge debits a booking amount from an agency's credit and returns the payment ID
+// recorded on the booking. The agency row is locked so concurrent charges can't
+// overspend the limit.
+func (as *AgencyService) Charge(ctx context.Context, agencyID int, amount models.Money) (string, error) {
+	paymentID := "agy_" + uuid.NewString()
+
+	err := as.db.Transaction(func(tx *sql.Tx) error {
+		var limit models.Money
+		var active bool
+		err := tx.QueryRowContext(ctx, `SELECT credit_limit, active FROM agencies WHERE id = $1 FOR UPDATE`, agencyID).
+			Scan(&limit, &active)
+		if err == sql.ErrNoRows || (err == nil && !active) {
+			return ErrAgencyNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lock agency: %w", err)
+		}
+
+		var unbilled, invoiced models.Money
+		if err := tx.QueryRowContext(ctx, outstandingQuery, agencyID).Scan(&unbilled, &invoiced); err != nil {
+			return fmt.Errorf("failed to query agency balance: %w", err)
+		}
+		if available := limit.Sub(unbilled).Sub(invoiced); amount.Minor > available.Minor {
+			return fmt.Errorf("%w: %s available, %s required", ErrCreditLimitExceeded, available, amount)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO agency_ledger (agency_id, payment_id, entry_type, amount, note)
+			VALUES ($1, $2, $3, $4, 'booking')
+		`, agencyID, paymentID, models.LedgerEntryCharge, amount)
+		if err != nil {
+			return fmt.Errorf("failed to record agency charge: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("Agency %d charged %s (%s)", agencyID, amount, paymentID)
+	return paymentID, nil
+}
+
+// Credit returns an amount to an agency's credit, e.g. a refund or a reversed charge
+func (as *AgencyService) Credit(ctx context.Context, agencyID int, paymentID string, amount models.Money, note string) error {
+	if !amount.IsPositive() {
+		return nil
+	}
+
+	_, err := as.db.ExecContext(ctx, `
+		INSERT INTO agency_ledger (agency_id, payment_id, entry_type, amount, note)
+		VALUES ($1, $2, $3, $4, $5)
+	`, agencyID, paymentID, models.LedgerEntryCredit, models.NewMoney(0).Sub(amount), note)
+	if err != nil {
+		return fmt.Errorf("failed to record agency credit: %w", err)
+	}
+
+	log.Printf("Agency %d credited %s (%s, %s)", agencyID, amount, paymentID, note)
+	return nil
+}
+
+// ListBookings returns an agency's bookings, newest first, optionally filtered by status
+func (as *AgencyService) ListBookings(ctx context.Context, agencyID int, status string, limit, offset int) (*models.AgencyBookingsResponse, error) {
+	bookings, err := as.readModel.List(ctx, BookingFilter{AgencyID: agencyID, Status: status, Limit: limit, Offset: offset})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agency bookings: %w", err)
+	}
+
+	return &models.AgencyBookingsResponse{
+		AgencyID: agencyID,
+		Bookings: bookings,
+		Count:    len(bookings),
+		Limit:    limit,
+		Offset:   offset,
+	}, nil
+}
+
+// invoiceColumns lists the invoice columns scanned by scanInvoice
+const invoiceColumns = `id, agency_id, period_start, period_end, amount, entries, status, created_at, paid_at`
+
+// scanInvoice scans a row selected with invoiceColumns
+func scanInvoice(row rowScanner) (*models.Invoice, error) {
+	var invoice models.Invoice
+	err := row.Scan(&invoice.ID, &invoice.AgencyID, &invoice.PeriodStart, &invoice.PeriodEnd,
+		&invoice.Amount, &invoice.Entries, &invoice.Status, &invoice.CreatedAt, &invoice.PaidAt)
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// ListInvoices returns an agency's invoices, newest first
+func (as *AgencyService) ListInvoices(ctx context.Context, agencyID int) ([]models.Invoice, error) {
+	rows, err := as.db.QueryContext(ctx,
+		`SELECT `+invoiceColumns+` FROM agency_invoices WHERE agency_id = $1 ORDER BY id DESC`, agencyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invoices: %w", err)
+	}
+	defer rows.Close()
+
+	invoices := []models.Invoice{}
+	for rows.Next() {
+		invoice, err := scanInvoice(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invoice: %w", err)
+		}
+		invoices = append(invoices, *invoice)
+	}
+	return invoices, rows.Err()
+}
+
+// MarkInvoicePaid records payment of an open invoice, releasing its amount back to credit
+func (as *AgencyService) MarkInvoicePaid(ctx context.Context, agencyID, invoiceID int) (*models.Invoice, error) {
+	invoice, err := scanInvoice(as.db.QueryRowContext(ctx, `
+		UPDATE agency_invoices
+		SET status = $1, paid_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND agency_id = $3 AND status = $4
+		RETURNING `+invoiceColumns,
+		models.InvoiceStatusPaid, invoiceID, agencyID, models.InvoiceStatusOpen))
+	if err == sql.ErrNoRows {
+		return nil, ErrInvoiceNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark invoice paid: %w", err)
+	}
+	return invoice, nil
+}
+
+// InvoiceAgencies bills every agency for its uninvoiced ledger entries created before
+// periodEnd. Agencies without such entries are skipped, so repeated runs for the same
+// period issue nothing new; it returns the number of invoices issued.
+func (as *AgencyService) InvoiceAgencies(ctx context.Context, periodEnd time.Time) (int, error) {
+	rows, err := as.db.QueryContext(ctx,
+		`SELECT DISTINCT agency_id FROM agency_ledger WHERE invoice_id IS NULL AND created_at < $1`, periodEnd)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query unbilled agencies: %w", err)
+	}
+	var agencyIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan agency ID: %w", err)
+		}
+		agencyIDs = append(agencyIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read unbilled agencies: %w", err)
+	}
+
+	issued := 0
+	for _, agencyID := range agencyIDs {
+		invoice, err := as.invoiceAgency(ctx, agencyID, periodEnd)
+		if err != nil {
+			return issued, err
+		}
+		if invoice != nil {
+			issued++
+			log.Printf("Invoice %d issued to agency %d: %.2f for %d entries", invoice.ID, agencyID, invoice.Amount, invoice.Entries)
+		}
+	}
+	return issued, nil
+}
+
+// invoiceAgency bills one agency's uninvoiced entries created before periodEnd.
+// The agency row is locked so concurrent runs can't bill the same entries twice.
+func (as *AgencyService) invoiceAgency(ctx context.Context, agencyID int, periodEnd time.Time) (*models.Invoice, error) {
+	var invoice *models.Invoice
+	err := as.db.Transaction(func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `SELECT id FROM agencies WHERE id = $1 FOR UPDATE`, agencyID); err != nil {
+			return fmt.Errorf("failed to lock agency: %w", err)
+		}
+
+		var entries int
+		var amount float64
+		var periodStart sql.NullTime
+		err := tx.QueryRowContext(ctx, `
+			SELECT COUNT(*), COALESCE(SUM(amount), 0), MIN(created_at)
+			FROM agency_ledger
+			WHERE agency_id = $1 AND invoice_id IS NULL AND created_at < $2
+		`, agencyID, periodEnd).Scan(&entries, &amount, &periodStart)
+		if err != nil {
+			return fmt.Errorf("failed to total unbilled entries: %w", err)
+		}
+		if entries == 0 {
+			return nil
+		}
+
+		created, err := scanInvoice(tx.QueryRowContext(ctx, `
+			INSERT INTO agency_invoices (agency_id, period_start, period_end, amount, entries, status)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING `+invoiceColumns,
+			agencyID, periodStart.Time, periodEnd, amount, entries, models.InvoiceStatusOpen))
+		if err != nil {
+			return fmt.Errorf("failed to create invoice: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE agency_ledger
+			SET invoice_id = $1
+			WHERE agency_id = $2 AND invoice_id IS NULL AND created_at < $3
+		`, created.ID, agencyID, periodEnd)
+		if err != nil {
+			return fmt.Errorf("failed to assign entries to invoice: %w", err)
+		}
+
+		invoice = created
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// chargeAgency settles a booking against the agency's credit instead of a card payment.
+// A limit breach is reported as a failed payment so the booking flow releases the seats.
+func (bs *BookingServiceV2) chargeAgency(ctx context.Context, agencyID int, amount models.Money) (*models.PaymentResponse, error) {
+	paymentID, err := bs.agencies.Charge(ctx, agencyID, amount)
+	if errors.Is(err, ErrCreditLimitExceeded) || errors.Is(err, ErrAgencyNotFound) {
+		return &models.PaymentResponse{
+			Status:  models.PaymentStatusFailed,
+			Amount:  amount,
+			Message: err.Error(),
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &models.PaymentResponse{
+		PaymentID: paymentID,
+		Status:    models.PaymentStatusSuccess,
+		Amount:    amount,
+		Message:   "Charged to agency credit",
+	}, nil
+}
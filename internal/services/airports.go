@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+	_ "time/tzdata" // Embed zone data so slim container images can resolve airport zones
+
+	"cred_flights_booking/pkg/models"
+)
+
+// earthRadiusKm is the mean Earth radius used for great-circle distances
+const earthRadiusKm = 6371.0
+
+// referenceDataRefreshInterval controls how often airport zones and airlines are reloaded
+const referenceDataRefreshInterval = 10 * time.Minute
+
+// referenceData caches airport time zones and countries, and airlines in memory
+type referenceData struct {
+	mu        sync.RWMutex
+	zones     map[string]*time.Location
+	countries map[string]string
+	airlines  map[string]models.Airline
+	loadedAt  time.Time
+}
+
+// nearbyAirports returns the airport itself plus every airport in the same metro group or
+// within radiusKm of it. Unknown airports expand to just themselves.
+func (fs *FlightService) nearbyAirports(ctx context.Context, code string, radiusKm float64) ([]string, error) {
+	airports, err := fs.listAirports(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	origin, ok := airports[code]
+	if !ok {
+		return []string{code}, nil
+	}
+
+	nearby := map[string]bool{code: true}
+
+	// Airports sharing a metro-area group
+	query := `
+		SELECT g2.airport_code
+		FROM airport_groups g1
+		JOIN airport_groups g2 ON g1.group_code = g2.group_code
+		WHERE g1.airport_code = $1
+	`
+	rows, err := fs.db.QueryContext(ctx, query, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query airport groups: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var member string
+		if err := rows.Scan(&member); err != nil {
+			return nil, fmt.Errorf("failed to scan airport group: %w", err)
+		}
+		nearby[member] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read airport groups: %w", err)
+	}
+
+	// Airports within the radius
+	for _, airport := range airports {
+		if distanceKm(origin, airport) <= radiusKm {
+			nearby[airport.Code] = true
+		}
+	}
+
+	codes := make([]string, 0, len(nearby))
+	codes = append(codes, code)
+	for member := range nearby {
+		if member != code {
+			codes = append(codes, member)
+		}
+	}
+	return codes, nil
+}
+
+// listAirports loads all airports keyed by code
+func (fs *FlightService) listAirports(ctx context.Context) (map[string]models.Airport, error) {
+	rows, err := fs.db.QueryContext(ctx, `SELECT code, name, city, latitude, longitude, timezone, country FROM airports`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query airports: %w", err)
+	}
+	defer rows.Close()
+
+	airports := make(map[string]models.Airport)
+	for rows.Next() {
+		var airport models.Airport
+		if err := rows.Scan(&airport.Code, &airport.Name, &airport.City, &airport.Latitude, &airport.Longitude, &airport.TimeZone, &airport.Country); err != nil {
+			return nil, fmt.Errorf("failed to scan airport: %w", err)
+		}
+		airports[airport.Code] = airport
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read airports: %w", err)
+	}
+
+	return airports, nil
+}
+
+// distanceKm returns the great-circle distance between two airports
+func distanceKm(a, b models.Airport) float64 {
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+// enrichFlights anchors flight times to their airports' time zones and attaches the
+// operating airline. Airports without a known zone are treated as UTC.
+func (fs *FlightService) enrichFlights(ctx context.Context, flights []models.Flight) {
+	zones, airlines := fs.loadReferenceData(ctx)
+	for i := range flights {
+		flights[i].Localize(zoneFor(zones, flights[i].Source), zoneFor(zones, flights[i].Destination))
+		if airline, ok := airlines[flights[i].AirlineCode()]; ok {
+			flights[i].Airline = &airline
+		}
+	}
+}
+
+// loadReferenceData returns the cached airport zones and airlines, reloading them when stale
+func (fs *FlightService) loadReferenceData(ctx context.Context) (map[string]*time.Location, map[string]models.Airline) {
+	fs.reference.mu.RLock()
+	zones, airlines, loadedAt := fs.reference.zones, fs.reference.airlines, fs.reference.loadedAt
+	fs.reference.mu.RUnlock()
+
+	if zones != nil && time.Since(loadedAt) < referenceDataRefreshInterval {
+		return zones, airlines
+	}
+
+	airports, err := fs.listAirports(ctx)
+	if err != nil {
+		log.Printf("Failed to load airport time zones: %v", err)
+		return zones, airlines
+	}
+
+	loadedZones := make(map[string]*time.Location, len(airports))
+	loadedCountries := make(map[string]string, len(airports))
+	for code, airport := range airports {
+		loadedCountries[code] = airport.Country
+		loc, err := time.LoadLocation(airport.TimeZone)
+		if err != nil {
+			log.Printf("Unknown time zone %q for airport %s: %v", airport.TimeZone, code, err)
+			continue
+		}
+		loadedZones[code] = loc
+	}
+
+	loadedAirlines, err := fs.listAirlines(ctx)
+	if err != nil {
+		log.Printf("Failed to load airlines: %v", err)
+		loadedAirlines = airlines
+	}
+
+	fs.reference.mu.Lock()
+	fs.reference.zones, fs.reference.airlines, fs.reference.loadedAt = loadedZones, loadedAirlines, time.Now()
+	fs.reference.countries = loadedCountries
+	fs.reference.mu.Unlock()
+
+	return loadedZones, loadedAirlines
+}
+
+// routeJurisdiction returns the tax jurisdiction of a route from its airports' countries
+func (fs *FlightService) routeJurisdiction(ctx context.Context, source, destination string) string {
+	fs.loadReferenceData(ctx)
+
+	fs.reference.mu.RLock()
+	defer fs.reference.mu.RUnlock()
+	return fs.taxes.Jurisdiction(fs.reference.countries[source], fs.reference.countries[destination])
+}
+
+// listAirlines loads all airlines keyed by code
+func (fs *FlightService) listAirlines(ctx context.Context) (map[string]models.Airline, error) {
+	rows, err := fs.db.QueryContext(ctx, `SELECT code, name FROM airlines`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query airlines: %w", err)
+	}
+	defer rows.Close()
+
+	airlines := make(map[string]models.Airline)
+	for rows.Next() {
+		var airline models.Airline
+		if err := rows.Scan(&airline.Code, &airline.Name); err != nil {
+			return nil, fmt.Errorf("failed to scan airline: %w", err)
+		}
+		airlines[airline.Code] = airline
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read airlines: %w", err)
+	}
+
+	return airlines, nil
+}
+
+// zoneFor returns the time zone of an airport, defaulting to UTC
+func zoneFor(zones map[string]*time.Location, code string) *time.Location {
+	if loc, ok := zones[code]; ok {
+		return loc
+	}
+	return time.UTC
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/pkg/models"
+	"golang.org/x/sync/errgroup"
+)
+
+// batchAvailabilityConcurrency bounds the routes searched in parallel for one batch
+const batchAvailabilityConcurrency = 8
+
+// MaxBatchAvailabilityRoutes returns the most routes accepted in one batch availability request
+func MaxBatchAvailabilityRoutes() int {
+	return config.GetInt("AVAILABILITY_BATCH_MAX_ROUTES", 50)
+}
+
+// batchAvailabilityHash identifies a normalized batch request for the response cache
+func batchAvailabilityHash(routes []models.RouteDate, seats int) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d", seats)
+	for _, route := range routes {
+		fmt.Fprintf(h, "|%s:%s:%s", route.Source, route.Destination, route.Date)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// GetBatchAvailability returns availability and the lowest fare for each route and date.
+// Identical requests are served from a short-lived response cache.
+func (fs *FlightService) GetBatchAvailability(ctx context.Context, req *models.BatchAvailabilityRequest) (*models.BatchAvailabilityResponse, error) {
+	seats := req.Seats
+	if seats <= 0 {
+		seats = 1
+	}
+
+	routes := make([]models.RouteDate, len(req.Routes))
+	for i, route := range req.Routes {
+		routes[i] = models.RouteDate{
+			Source:      strings.ToUpper(strings.TrimSpace(route.Source)),
+			Destination: strings.ToUpper(strings.TrimSpace(route.Destination)),
+			Date:        strings.TrimSpace(route.Date),
+		}
+	}
+
+	// Serve repeated partner refreshes from the request-level cache
+	cacheKey := database.GenerateBatchAvailabilityCacheKey(batchAvailabilityHash(routes, seats))
+	var cached models.BatchAvailabilityResponse
+	if err := fs.cache.GetJSON(ctx, cacheKey, &cached); err == nil {
+		cached.Cached = true
+		return &cached, nil
+	}
+
+	// Search each distinct route once
+	unique := make(map[models.RouteDate]*models.RouteAvailability)
+	for _, route := range routes {
+		if _, ok := unique[route]; !ok {
+			unique[route] = &models.RouteAvailability{RouteDate: route}
+		}
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(batchAvailabilityConcurrency)
+	for route, result := range unique {
+		route, result := route, result
+		g.Go(func() error {
+			fs.routeAvailability(gctx, route, seats, result)
+			return nil
+		})
+	}
+	g.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	response := &models.BatchAvailabilityResponse{
+		Routes:      make([]models.RouteAvailability, len(routes)),
+		GeneratedAt: time.Now(),
+	}
+	for i, route := range routes {
+		response.Routes[i] = *unique[route]
+	}
+
+	if err := fs.cache.SetJSON(ctx, cacheKey, response, config.GetDuration("AVAILABILITY_BATCH_CACHE_TTL", time.Minute)); err != nil {
+		log.Printf("Failed to cache batch availability: %v", err)
+	}
+
+	return response, nil
+}
+
+// routeAvailability fills in availability for one route and date. Errors are reported on the result.
+func (fs *FlightService) routeAvailability(ctx context.Context, route models.RouteDate, seats int, result *models.RouteAvailability) {
+	if len(route.Source) != 3 || len(route.Destination) != 3 || route.Source == route.Destination {
+		result.Error = "invalid source or destination"
+		return
+	}
+	if _, err := time.Parse("2006-01-02", route.Date); err != nil {
+		result.Error = "invalid date, expected YYYY-MM-DD"
+		return
+	}
+
+	paths, err := fs.searchRoute(ctx, &models.SearchRequest{
+		Source:      route.Source,
+		Destination: route.Destination,
+		Date:        route.Date,
+		Seats:       seats,
+		SortBy:      "cheapest",
+	})
+	if err != nil {
+		log.Printf("Batch availability search failed for %s-%s on %s: %v", route.Source, route.Destination, route.Date, err)
+		result.Error = "search failed"
+		return
+	}
+
+	result.FlightCount = len(paths)
+	result.Available = len(paths) > 0
+	if !result.Available {
+		return
+	}
+
+	// Paths are sorted cheapest first
+	cheapest := paths[0]
+	fare := cheapest.TotalPrice
+	result.LowestFare = &fare
+	for _, flight := range cheapest.Flights {
+		result.LowestFareTrip = append(result.LowestFareTrip, flight.ID)
+	}
+
+	var flights []models.Flight
+	for _, path := range paths {
+		flights = append(flights, path.Flights...)
+	}
+	seatCounts, err := fs.getAvailableSeatsBatch(ctx, flights)
+	if err != nil {
+		log.Printf("Failed to get available seats for %s-%s on %s: %v", route.Source, route.Destination, route.Date, err)
+		return
+	}
+	for _, path := range paths {
+		pathSeats := -1
+		for _, flight := range path.Flights {
+			if count := seatCounts[flight.ID]; pathSeats < 0 || count < pathSeats {
+				pathSeats = count
+			}
+		}
+		result.MaxSeats = max(result.MaxSeats, pathSeats)
+	}
+}
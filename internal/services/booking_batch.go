@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/pkg/models"
+	"golang.org/x/sync/errgroup"
+)
+
+// MaxBatchBookings returns the most bookings accepted in one batch request
+func MaxBatchBookings() int {
+	return config.GetInt("BOOKING_BATCH_MAX_ITEMS", 25)
+}
+
+// CreateBookings creates several bookings with bounded parallelism and reports each outcome.
+// In atomic mode no new bookings start after the first failure, and every booking already
+// made (confirmed or awaiting payment) is rolled back with a full refund.
+func (bs *BookingServiceV2) CreateBookings(ctx context.Context, req *models.BatchBookingRequest) (*models.BatchBookingResponse, error) {
+	results := make([]models.BatchBookingResult, len(req.Bookings))
+	var valid []int
+
+	// Temporary holds are keyed by user and flight, so one batch can't book the same pair twice
+	seen := make(map[string]int)
+	for i := range req.Bookings {
+		item := &req.Bookings[i]
+		results[i] = models.BatchBookingResult{Index: i}
+		if err := item.Validate(); err != nil {
+			results[i].Status = models.BookingStatusFailed
+			results[i].ErrorCode = models.BatchErrorInvalidRequest
+			results[i].Message = err.Error()
+			continue
+		}
+		key := fmt.Sprintf("%d:%d", item.UserID, item.FlightID)
+		if first, ok := seen[key]; ok {
+			results[i].Status = models.BookingStatusFailed
+			results[i].ErrorCode = models.BatchErrorDuplicate
+			results[i].Message = fmt.Sprintf("Same user and flight as booking %d", first)
+			continue
+		}
+		seen[key] = i
+		valid = append(valid, i)
+	}
+
+	// An atomic batch with an invalid item can't succeed, so book nothing
+	var aborted atomic.Bool
+	if req.Atomic && len(valid) < len(req.Bookings) {
+		aborted.Store(true)
+	}
+
+	var g errgroup.Group
+	g.SetLimit(bs.batchConcurrency)
+	for _, i := range valid {
+		g.Go(func() error {
+			if aborted.Load() {
+				results[i].Status = models.BookingStatusSkipped
+				results[i].ErrorCode = models.BatchErrorAborted
+				results[i].Message = "Not attempted because another booking in the atomic batch failed"
+				return nil
+			}
+			bs.createBatchItem(ctx, &req.Bookings[i], &results[i])
+			if req.Atomic && results[i].Status != models.BookingStatusConfirmed {
+				aborted.Store(true)
+			}
+			return nil
+		})
+	}
+	g.Wait()
+
+	response := &models.BatchBookingResponse{Atomic: req.Atomic}
+	if req.Atomic && aborted.Load() {
+		response.RolledBack = bs.rollbackBatch(ctx, req.Bookings, results)
+	}
+
+	for _, result := range results {
+		switch result.Status {
+		case models.BookingStatusConfirmed:
+			response.Succeeded++
+		case models.BookingStatusPending:
+			response.Pending++
+		default:
+			response.Failed++
+		}
+	}
+	response.Results = results
+
+	log.Printf("Batch booking: %d items, atomic=%t, succeeded=%d, pending=%d, failed=%d, rolled_back=%t",
+		len(results), req.Atomic, response.Succeeded, response.Pending, response.Failed, response.RolledBack)
+	return response, nil
+}
+
+// createBatchItem books one item of a batch and records its outcome
+func (bs *BookingServiceV2) createBatchItem(ctx context.Context, item *models.BookingRequest, result *models.BatchBookingResult) {
+	resp, err := bs.CreateBooking(ctx, item)
+	if err != nil {
+		log.Printf("Batch booking item %d error: %v", result.Index, err)
+		result.Status = models.BookingStatusFailed
+		result.ErrorCode = models.BatchErrorInternal
+		if errors.Is(err, models.ErrDuplicatePassenger) {
+			result.ErrorCode = models.BatchErrorDuplicatePassenger
+		}
+		result.Message = err.Error()
+		return
+	}
+
+	result.Status = resp.Status
+	result.BookingID = resp.BookingID
+	if resp.TotalAmount.IsPositive() {
+		result.TotalAmount = &resp.TotalAmount
+	}
+	result.PaymentID = resp.PaymentID
+	result.Message = resp.Message
+	switch resp.Status {
+	case models.BookingStatusFailed:
+		result.ErrorCode = models.BatchErrorBookingFailed
+	case models.BookingStatusPending:
+		result.ErrorCode = models.BatchErrorPending
+	}
+}
+
+// rollbackBatch undoes every confirmed or pending booking of a failed atomic batch.
+// It runs on a fresh deadline so a request timeout can't leave the batch half undone,
+// and reports whether every booking was rolled back.
+func (bs *BookingServiceV2) rollbackBatch(ctx context.Context, items []models.BookingRequest, results []models.BatchBookingResult) bool {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+
+	complete := true
+	for i := range results {
+		result := &results[i]
+		item := &items[i]
+
+		switch result.Status {
+		case models.BookingStatusConfirmed:
+			if err := bs.voidBooking(ctx, result.BookingID); err != nil {
+				log.Printf("Failed to roll back batch booking %d: %v", result.BookingID, err)
+				result.ErrorCode = models.BatchErrorInternal
+				result.Message = fmt.Sprintf("Rollback failed: %v", err)
+				complete = false
+				continue
+			}
+		case models.BookingStatusPending:
+			tempBookingKey := database.GenerateTempBookingCacheKey(item.UserID, item.FlightID)
+			bs.revertBookingOnFailure(ctx, item.FlightID, item.Seats, item.Date, tempBookingKey, nil)
+		default:
+			continue
+		}
+
+		result.Status = models.BookingStatusRolledBack
+		result.ErrorCode = ""
+		result.Message = "Rolled back because another booking in the atomic batch failed"
+	}
+	return complete
+}
+
+// voidBooking cancels a just-confirmed booking with a full refund and no cancellation fee,
+// releasing its seats. A card payment is reversed first (voided while authorized, refunded
+// once captured), so the booking is only marked refunded once the money is returned.
+func (bs *BookingServiceV2) voidBooking(ctx context.Context, bookingID int) error {
+	booking, err := bs.GetBooking(ctx, bookingID)
+	if err != nil {
+		return fmt.Errorf("failed to get booking: %w", err)
+	}
+
+	if booking.Status != models.BookingStatusConfirmed {
+		return fmt.Errorf("booking is no longer confirmed")
+	}
+	if booking.AgencyID == 0 && booking.PaymentID != "" {
+		if _, err := bs.payments.Reverse(ctx, booking.PaymentID, "batch_rollback"); err != nil {
+			return fmt.Errorf("failed to reverse payment %s: %w", booking.PaymentID, err)
+		}
+	}
+	err = bs.transitionBooking(ctx, booking, models.BookingStatusCancelled, "batch_rollback",
+		", refund_amount = total_amount, cancellation_fee = 0")
+	if err != nil {
+		return fmt.Errorf("failed to void booking: %w", err)
+	}
+
+	if booking.AgencyID > 0 {
+		if err := bs.agencies.Credit(ctx, booking.AgencyID, booking.PaymentID, booking.TotalAmount, "batch_rollback"); err != nil {
+			log.Printf("Failed to credit agency for rolled back booking %d: %v", bookingID, err)
+		}
+	}
+	if err := bs.releaseBookingSeats(ctx, booking, booking.Seats, models.SeatReasonBatchRollback); err != nil {
+		log.Printf("Failed to increment seats on rollback: %v", err)
+	}
+	bs.publishOccupancyEvent(ctx, bookingID, booking.FlightID, -booking.Seats, booking.Date, models.OccupancyReasonBookingCancelled, "")
+	bs.cache.Delete(ctx, database.GenerateBookingCacheKey(bookingID))
+
+	log.Printf("Booking %d voided by batch rollback: refund=%s", bookingID, booking.TotalAmount)
+	return nil
+}
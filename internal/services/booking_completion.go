@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"cred_flights_booking/pkg/models"
+)
+
+// Stored flight times are airport-local wall clocks, which can be up to 14 hours ahead of
+// and 12 hours behind UTC
+const (
+	maxZoneLead = 14 * time.Hour
+	maxZoneLag  = 12 * time.Hour
+)
+
+// CompleteFlownBookings marks confirmed bookings whose flight has arrived as completed and
+// runs their post-travel hooks (loyalty accrual and a review request). It returns the number
+// of bookings completed.
+func (bs *BookingServiceV2) CompleteFlownBookings(ctx context.Context) (int, error) {
+	// Candidates are bookings whose snapshotted arrival may have passed in any time zone,
+	// most overdue first; arrivalTime decides each one
+	query := `
+		SELECT b.id
+		FROM bookings b
+		LEFT JOIN LATERAL (
+			SELECT MAX(arrival_time) AS arrival_time FROM booking_segments WHERE booking_id = b.id
+		) s ON TRUE
+		WHERE b.status = $1
+		  AND COALESCE(s.arrival_time, b.date::date + INTERVAL '1 day') < (NOW() AT TIME ZONE 'UTC') + $2::int * INTERVAL '1 hour'
+		ORDER BY COALESCE(s.arrival_time, b.date::date + INTERVAL '1 day'), b.id
+		LIMIT $3
+	`
+
+	rows, err := bs.db.QueryContext(ctx, query, models.BookingStatusConfirmed, int(maxZoneLead.Hours()), bs.completionBatch)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query flown bookings: %w", err)
+	}
+	var bookingIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan booking ID: %w", err)
+		}
+		bookingIDs = append(bookingIDs, id)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read flown bookings: %w", err)
+	}
+
+	completed := 0
+	now := time.Now()
+	for _, bookingID := range bookingIDs {
+		if err := ctx.Err(); err != nil {
+			return completed, err
+		}
+
+		booking, err := bs.GetBooking(ctx, bookingID)
+		if err != nil {
+			log.Printf("Failed to load booking %d for completion: %v", bookingID, err)
+			continue
+		}
+		arrival, err := bs.arrivalTime(ctx, booking)
+		if err != nil {
+			log.Printf("Failed to resolve arrival of booking %d: %v", bookingID, err)
+			continue
+		}
+		if now.Before(arrival) {
+			continue
+		}
+
+		if err := bs.transitionBooking(ctx, booking, models.BookingStatusCompleted, "flown", ""); err != nil {
+			log.Printf("Failed to complete booking %d: %v", bookingID, err)
+			continue
+		}
+		completed++
+		bs.runPostTravelHooks(ctx, booking)
+	}
+
+	log.Printf("Completed %d of %d flown booking candidates", completed, len(bookingIDs))
+	return completed, nil
+}
+
+// arrivalTime returns when a booking's flight arrives, preferring the Flight Service's
+// zone-aware time. The snapshot fallback assumes the latest zone, so a booking is never
+// completed before its flight lands.
+func (bs *BookingServiceV2) arrivalTime(ctx context.Context, booking *models.Booking) (time.Time, error) {
+	if flight, err := bs.getFlightViaHTTP(ctx, booking.FlightID); err == nil {
+		return flight.ArrivalTime, nil
+	}
+	if len(booking.Segments) > 0 {
+		return booking.Segments[len(booking.Segments)-1].ArrivalTime.Add(maxZoneLag), nil
+	}
+	date, err := time.Parse("2006-01-02", booking.Date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid booking date %q: %w", booking.Date, err)
+	}
+	return date.AddDate(0, 0, 1).Add(maxZoneLag), nil
+}
+
+// runPostTravelHooks accrues loyalty points and asks for a review once a booking is flown.
+// Failures are logged, not returned; the booking stays completed.
+func (bs *BookingServiceV2) runPostTravelHooks(ctx context.Context, booking *models.Booking) {
+	if err := bs.accrueLoyalty(ctx, booking); err != nil {
+		log.Printf("Failed to accrue loyalty points for booking %d: %v", booking.ID, err)
+	}
+
+	if booking.Email == "" && booking.Phone == "" {
+		return
+	}
+	if _, err := bs.notifier.SendReviewRequest(ctx, booking); err != nil {
+		log.Printf("Failed to send review request for booking %d: %v", booking.ID, err)
+	}
+}
+
+// accrueLoyalty credits points for a flown booking's amount. Each booking accrues once.
+func (bs *BookingServiceV2) accrueLoyalty(ctx context.Context, booking *models.Booking) error {
+	points := int(math.Floor(booking.TotalAmount.Float64() * bs.loyaltyRate))
+	if points <= 0 {
+		return nil
+	}
+
+	_, err := bs.db.ExecContext(ctx, `
+		INSERT INTO loyalty_ledger (booking_id, user_id, points)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (booking_id) DO NOTHING`,
+		booking.ID, booking.UserID, points)
+	if err != nil {
+		return fmt.Errorf("failed to record loyalty points: %w", err)
+	}
+	return nil
+}
+
+// GetLoyaltyBalance returns a user's loyalty points total and their 20 most recent accruals
+func (bs *BookingServiceV2) GetLoyaltyBalance(ctx context.Context, userID int) (*models.LoyaltyBalance, error) {
+	balance := &models.LoyaltyBalance{UserID: userID, Accruals: []models.LoyaltyAccrual{}}
+
+	err := bs.db.QueryRowContext(ctx,
+		"SELECT COALESCE(SUM(points), 0) FROM loyalty_ledger WHERE user_id = $1", userID,
+	).Scan(&balance.Points)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query loyalty balance: %w", err)
+	}
+
+	rows, err := bs.db.QueryContext(ctx, `
+		SELECT booking_id, points, created_at
+		FROM loyalty_ledger
+		WHERE user_id = $1
+		ORDER BY created_at DESC, booking_id DESC
+		LIMIT 20`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query loyalty accruals: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var accrual models.LoyaltyAccrual
+		if err := rows.Scan(&accrual.BookingID, &accrual.Points, &accrual.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan loyalty accrual: %w", err)
+		}
+		balance.Accruals = append(balance.Accruals, accrual)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read loyalty accruals: %w", err)
+	}
+
+	return balance, nil
+}
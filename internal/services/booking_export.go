@@ -0,0 +1,33 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/export"
+	"cred_flights_booking/pkg/models"
+)
+
+// ExportOrderView renders a confirmed or flown booking as a simplified NDC OrderViewRS
+func (bs *BookingServiceV2) ExportOrderView(ctx context.Context, bookingID int) (*export.OrderViewRS, error) {
+	booking, err := bs.GetBooking(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if booking.Status != models.BookingStatusConfirmed && booking.Status != models.BookingStatusCompleted {
+		return nil, ErrBookingNotConfirmed
+	}
+
+	// Prefer the terms snapshotted at confirmation; older bookings fall back to the live flight
+	segments := booking.Segments
+	if len(segments) == 0 {
+		bs.AttachFlight(ctx, booking)
+		if booking.Flight != nil {
+			segments = []models.BookingSegment{models.NewBookingSegment(0, booking.Flight)}
+		}
+	}
+
+	currency := config.GetEnv("BOOKING_CURRENCY", "INR")
+	return export.NewOrderView(booking, segments, currency, time.Now()), nil
+}
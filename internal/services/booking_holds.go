@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/pkg/models"
+	"github.com/go-redis/redis/v8"
+)
+
+// Seat hold errors
+var (
+	// ErrHoldNotFound is returned when a hold does not exist, has expired or completed, or
+	// belongs to another user
+	ErrHoldNotFound = errors.New("hold not found")
+	// ErrHoldExtensionLimit is returned when a hold has used every extension or reached the
+	// longest allowed hold
+	ErrHoldExtensionLimit = errors.New("hold cannot be extended further")
+	// ErrHoldExtensionConflict is returned when another request extended the hold concurrently
+	ErrHoldExtensionConflict = errors.New("hold was extended concurrently, please retry")
+)
+
+// HoldConfig controls how long seats are held for a booking whose payment is in progress
+type HoldConfig struct {
+	TTL           time.Duration // Initial hold
+	Extension     time.Duration // Time added by each extension
+	MaxExtensions int
+	MaxTotal      time.Duration // Longest a hold may last from its creation, extensions included
+}
+
+// LoadHoldConfig loads the seat hold settings from the environment
+func LoadHoldConfig() HoldConfig {
+	cfg := HoldConfig{
+		TTL:           config.GetDuration("BOOKING_HOLD_TTL", 15*time.Minute),
+		Extension:     config.GetDuration("BOOKING_HOLD_EXTENSION", 5*time.Minute),
+		MaxExtensions: max(config.GetInt("BOOKING_HOLD_MAX_EXTENSIONS", 2), 0),
+		MaxTotal:      config.GetDuration("BOOKING_HOLD_MAX_TOTAL", 30*time.Minute),
+	}
+	if cfg.MaxTotal < cfg.TTL {
+		cfg.MaxTotal = cfg.TTL
+	}
+	return cfg
+}
+
+// storeHold caches a temporary booking until it expires, indexed by its hold ID
+func (bs *BookingServiceV2) storeHold(ctx context.Context, tempBookingKey string, hold *models.TempBooking) error {
+	ttl := time.Until(hold.ExpiresAt)
+	if err := bs.cache.SetJSON(ctx, tempBookingKey, hold, ttl); err != nil {
+		return err
+	}
+	if err := bs.cache.Set(ctx, database.GenerateBookingHoldCacheKey(hold.HoldID), tempBookingKey, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to index hold: %w", err)
+	}
+	return nil
+}
+
+// getHold loads a user's hold by ID along with its temporary booking key
+func (bs *BookingServiceV2) getHold(ctx context.Context, holdID string, userID int) (*models.TempBooking, string, error) {
+	tempBookingKey, err := bs.cache.Get(ctx, database.GenerateBookingHoldCacheKey(holdID)).Result()
+	if err == redis.Nil {
+		return nil, "", ErrHoldNotFound
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to look up hold: %w", err)
+	}
+
+	var hold models.TempBooking
+	if err := bs.cache.GetJSON(ctx, tempBookingKey, &hold); err != nil {
+		if exists, existsErr := bs.cache.KeyExists(ctx, tempBookingKey); existsErr == nil && !exists {
+			return nil, "", ErrHoldNotFound
+		}
+		return nil, "", fmt.Errorf("failed to load hold: %w", err)
+	}
+
+	// The user may have started a new hold on the same flight since
+	if hold.HoldID != holdID || hold.UserID != userID {
+		return nil, "", ErrHoldNotFound
+	}
+	return &hold, tempBookingKey, nil
+}
+
+// ExtendHold pushes a hold's expiry back by one extension, up to HoldConfig.MaxExtensions
+// times and never past HoldConfig.MaxTotal after the hold was created. Each extension is
+// recorded in booking_hold_extensions.
+func (bs *BookingServiceV2) ExtendHold(ctx context.Context, holdID string, userID int) (*models.HoldExtensionResponse, error) {
+	hold, tempBookingKey, err := bs.getHold(ctx, holdID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	maxExpiresAt := hold.CreatedAt.Add(bs.holds.MaxTotal)
+	expiresAt := hold.ExpiresAt.Add(bs.holds.Extension)
+	if expiresAt.After(maxExpiresAt) {
+		expiresAt = maxExpiresAt
+	}
+	if hold.Extensions >= bs.holds.MaxExtensions || !expiresAt.After(hold.ExpiresAt) {
+		return nil, ErrHoldExtensionLimit
+	}
+
+	previousExpiresAt := hold.ExpiresAt
+	hold.ExpiresAt = expiresAt
+	hold.Extensions++
+
+	query := `
+		INSERT INTO booking_hold_extensions (hold_id, extension, user_id, flight_id, date, previous_expires_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (hold_id, extension) DO NOTHING
+	`
+
+	// The extension's primary key serializes concurrent extensions; the cached hold is only
+	// updated if it still exists, and the record is rolled back otherwise
+	err = bs.db.Transaction(func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, query, holdID, hold.Extensions, hold.UserID, hold.FlightID, hold.Date,
+			previousExpiresAt, expiresAt)
+		if err != nil {
+			return fmt.Errorf("failed to record hold extension: %w", err)
+		}
+		if inserted, _ := result.RowsAffected(); inserted == 0 {
+			return ErrHoldExtensionConflict
+		}
+
+		ttl := time.Until(expiresAt)
+		updated, err := bs.cache.UpdateJSON(ctx, tempBookingKey, hold, ttl)
+		if err != nil {
+			return fmt.Errorf("failed to extend hold: %w", err)
+		}
+		if !updated {
+			return ErrHoldNotFound
+		}
+		if err := bs.cache.Expire(ctx, database.GenerateBookingHoldCacheKey(holdID), ttl).Err(); err != nil {
+			log.Printf("Failed to extend hold index %s: %v", holdID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Extended hold %s for user %d on flight %d to %s (extension %d of %d)",
+		holdID, userID, hold.FlightID, expiresAt.Format(time.RFC3339), hold.Extensions, bs.holds.MaxExtensions)
+
+	return &models.HoldExtensionResponse{
+		HoldID:              holdID,
+		PreviousExpiresAt:   previousExpiresAt,
+		ExpiresAt:           expiresAt,
+		Extensions:          hold.Extensions,
+		ExtensionsRemaining: bs.holds.MaxExtensions - hold.Extensions,
+		MaxExpiresAt:        maxExpiresAt,
+	}, nil
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"cred_flights_booking/pkg/models"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Booking link errors
+var (
+	// ErrLinkNotAllowed is returned when two bookings cannot travel together: one is closed,
+	// they are the same booking, or they are on different flight dates
+	ErrLinkNotAllowed = errors.New("only two active bookings on the same flight date can be linked")
+	// ErrAlreadyLinked is returned when both bookings already belong to different groups
+	ErrAlreadyLinked = errors.New("bookings are already linked to different groups")
+	// ErrNotLinked is returned when unlinking a booking that has no links
+	ErrNotLinked = errors.New("booking is not linked")
+)
+
+// linkable reports whether a booking can still join a group
+func linkable(booking *models.Booking) bool {
+	return booking.Status == models.BookingStatusPending || booking.Status == models.BookingStatusConfirmed
+}
+
+// LinkBookings puts two bookings of the same flight date in one group, joining the group
+// either already belongs to, and returns the group's itinerary
+func (bs *BookingServiceV2) LinkBookings(ctx context.Context, bookingID, otherID int) (*models.ItineraryResponse, error) {
+	if bookingID == otherID {
+		return nil, ErrLinkNotAllowed
+	}
+	booking, err := bs.GetBooking(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	other, err := bs.GetBooking(ctx, otherID)
+	if err != nil {
+		return nil, err
+	}
+	if !linkable(booking) || !linkable(other) || booking.FlightID != other.FlightID || booking.Date != other.Date {
+		return nil, ErrLinkNotAllowed
+	}
+
+	insert := `
+		INSERT INTO booking_links (booking_id, group_id)
+		VALUES ($1, $2)
+		ON CONFLICT (booking_id) DO NOTHING
+	`
+
+	var groupID string
+	err = bs.db.RetryTransaction(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable}, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `SELECT DISTINCT group_id FROM booking_links WHERE booking_id = ANY($1)`,
+			pq.Array([]int{bookingID, otherID}))
+		if err != nil {
+			return fmt.Errorf("failed to query booking links: %w", err)
+		}
+		var groups []string
+		for rows.Next() {
+			var group string
+			if err := rows.Scan(&group); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan booking link: %w", err)
+			}
+			groups = append(groups, group)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to read booking links: %w", err)
+		}
+
+		switch len(groups) {
+		case 0:
+			groupID = uuid.New().String()
+		case 1:
+			groupID = groups[0]
+		default:
+			return ErrAlreadyLinked
+		}
+
+		for _, id := range []int{bookingID, otherID} {
+			if _, err := tx.ExecContext(ctx, insert, id, groupID); err != nil {
+				return fmt.Errorf("failed to link booking: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Linked bookings %d and %d in group %s", bookingID, otherID, groupID)
+	return bs.GetItinerary(ctx, bookingID)
+}
+
+// UnlinkBooking removes a booking from its group. A group left with one booking is removed.
+func (bs *BookingServiceV2) UnlinkBooking(ctx context.Context, bookingID int) error {
+	err := bs.db.Transaction(func(tx *sql.Tx) error {
+		var groupID string
+		err := tx.QueryRowContext(ctx, `DELETE FROM booking_links WHERE booking_id = $1 RETURNING group_id`, bookingID).Scan(&groupID)
+		if err == sql.ErrNoRows {
+			return ErrNotLinked
+		}
+		if err != nil {
+			return fmt.Errorf("failed to unlink booking: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM booking_links
+			WHERE group_id = $1 AND (SELECT COUNT(*) FROM booking_links WHERE group_id = $1) = 1
+		`, groupID)
+		if err != nil {
+			return fmt.Errorf("failed to remove booking group: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Unlinked booking %d", bookingID)
+	return nil
+}
+
+// GetItinerary returns the shared view of a booking's group: the flight and every linked
+// booking with its seats and status. An unlinked booking is returned on its own.
+func (bs *BookingServiceV2) GetItinerary(ctx context.Context, bookingID int) (*models.ItineraryResponse, error) {
+	booking, err := bs.GetBooking(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+
+	itinerary := &models.ItineraryResponse{
+		FlightID: booking.FlightID,
+		Date:     booking.Date,
+		Segments: booking.Segments,
+	}
+
+	err = bs.db.QueryRowContext(ctx, `SELECT group_id FROM booking_links WHERE booking_id = $1`, bookingID).Scan(&itinerary.GroupID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to query booking link: %w", err)
+	}
+
+	if itinerary.GroupID == "" {
+		itinerary.Bookings = []models.LinkedBooking{{
+			BookingID: booking.ID,
+			UserID:    booking.UserID,
+			Seats:     booking.Seats,
+			Status:    booking.Status,
+			Cabin:     booking.Cabin,
+		}}
+	} else {
+		itinerary.Bookings, err = bs.groupBookings(ctx, itinerary.GroupID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, linked := range itinerary.Bookings {
+		if linked.Status == models.BookingStatusPending || linked.Status == models.BookingStatusConfirmed {
+			itinerary.ActiveSeats += linked.Seats
+		}
+	}
+	return itinerary, nil
+}
+
+// groupBookings returns the bookings of a group in booking order
+func (bs *BookingServiceV2) groupBookings(ctx context.Context, groupID string) ([]models.LinkedBooking, error) {
+	query := `
+		SELECT b.id, b.user_id, b.seats, b.status, b.cabin, l.linked_at
+		FROM booking_links l
+		JOIN bookings b ON b.id = l.booking_id
+		WHERE l.group_id = $1
+		ORDER BY b.id
+	`
+
+	rows, err := bs.db.QueryContext(ctx, query, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query linked bookings: %w", err)
+	}
+	defer rows.Close()
+
+	bookings := []models.LinkedBooking{}
+	for rows.Next() {
+		var linked models.LinkedBooking
+		if err := rows.Scan(&linked.BookingID, &linked.UserID, &linked.Seats, &linked.Status, &linked.Cabin, &linked.LinkedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan linked booking: %w", err)
+		}
+		bookings = append(bookings, linked)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read linked bookings: %w", err)
+	}
+	return bookings, nil
+}
+
+// activeLinkedBookings returns the IDs of pending and confirmed bookings linked to a booking
+func (bs *BookingServiceV2) activeLinkedBookings(ctx context.Context, bookingID int) ([]int, error) {
+	query := `
+		SELECT b.id
+		FROM booking_links mine
+		JOIN booking_links l ON l.group_id = mine.group_id AND l.booking_id <> mine.booking_id
+		JOIN bookings b ON b.id = l.booking_id
+		WHERE mine.booking_id = $1 AND b.status IN ($2, $3)
+		ORDER BY b.id
+	`
+
+	rows, err := bs.db.QueryContext(ctx, query, bookingID, models.BookingStatusPending, models.BookingStatusConfirmed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query linked bookings: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan linked booking: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read linked bookings: %w", err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/events"
+	"cred_flights_booking/pkg/models"
+)
+
+var (
+	// ErrTooManyConnections is returned when the instance already holds its maximum of live connections
+	ErrTooManyConnections = errors.New("too many live connections, please try again later")
+	// ErrTooManySubscriptions is returned when a connection subscribes to more bookings than allowed
+	ErrTooManySubscriptions = errors.New("too many booking subscriptions on this connection")
+	// ErrLiveDisconnected is returned when subscribing on a connection that was already dropped
+	ErrLiveDisconnected = errors.New("live connection was closed")
+)
+
+// liveBufferSize is how many updates may queue for a connection before it is dropped as too slow
+const liveBufferSize = 64
+
+// LiveSubscriber is one connection's subscription to booking updates. Updates are closed
+// when the subscriber is disconnected, including when it fell too far behind.
+type LiveSubscriber struct {
+	Updates chan *models.BookingLiveUpdate
+
+	bookings  map[int]struct{}
+	closeOnce sync.Once
+}
+
+// close ends the subscriber's updates
+func (s *LiveSubscriber) close() {
+	s.closeOnce.Do(func() { close(s.Updates) })
+}
+
+// BookingLiveService pushes booking status transitions to connected clients. Every instance
+// tails the booking event stream, so a client sees its bookings' updates whichever
+// instance it is connected to.
+type BookingLiveService struct {
+	bookings         *BookingServiceV2
+	maxConnections   int
+	maxSubscriptions int
+
+	mu          sync.Mutex
+	subscribers map[*LiveSubscriber]struct{}
+	byBooking   map[int]map[*LiveSubscriber]struct{}
+}
+
+// NewBookingLiveService creates the live booking update service
+func NewBookingLiveService(bookings *BookingServiceV2) *BookingLiveService {
+	return &BookingLiveService{
+		bookings:         bookings,
+		maxConnections:   config.GetInt("WS_MAX_CONNECTIONS", 1000),
+		maxSubscriptions: config.GetInt("WS_MAX_SUBSCRIPTIONS", 50),
+		subscribers:      make(map[*LiveSubscriber]struct{}),
+		byBooking:        make(map[int]map[*LiveSubscriber]struct{}),
+	}
+}
+
+// Start tails booking events in the background until ctx is cancelled
+func (ls *BookingLiveService) Start(ctx context.Context, bus *events.Bus) {
+	go func() {
+		if err := bus.Tail(ctx, events.StreamBookings, ls.dispatch); err != nil {
+			log.Printf("Live booking updates stopped: %v", err)
+		}
+	}()
+}
+
+// Connect registers a new connection
+func (ls *BookingLiveService) Connect() (*LiveSubscriber, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if len(ls.subscribers) >= ls.maxConnections {
+		return nil, ErrTooManyConnections
+	}
+
+	subscriber := &LiveSubscriber{
+		Updates:  make(chan *models.BookingLiveUpdate, liveBufferSize),
+		bookings: make(map[int]struct{}),
+	}
+	ls.subscribers[subscriber] = struct{}{}
+	return subscriber, nil
+}
+
+// Disconnect removes a connection and all its subscriptions
+func (ls *BookingLiveService) Disconnect(subscriber *LiveSubscriber) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	ls.remove(subscriber)
+}
+
+// remove drops a subscriber; ls.mu must be held
+func (ls *BookingLiveService) remove(subscriber *LiveSubscriber) {
+	for bookingID := range subscriber.bookings {
+		ls.unindex(subscriber, bookingID)
+	}
+	delete(ls.subscribers, subscriber)
+	subscriber.close()
+}
+
+// unindex removes one booking subscription; ls.mu must be held
+func (ls *BookingLiveService) unindex(subscriber *LiveSubscriber, bookingID int) {
+	delete(subscriber.bookings, bookingID)
+	if subscribers := ls.byBooking[bookingID]; subscribers != nil {
+		delete(subscribers, subscriber)
+		if len(subscribers) == 0 {
+			delete(ls.byBooking, bookingID)
+		}
+	}
+}
+
+// Subscribe starts pushing a booking's updates to a connection, beginning with a snapshot
+// of its current status. The subscription is registered before the snapshot is read, so no
+// transition is missed; one may arrive just before the snapshot.
+func (ls *BookingLiveService) Subscribe(ctx context.Context, subscriber *LiveSubscriber, bookingID int) (*models.BookingLiveUpdate, error) {
+	ls.mu.Lock()
+	if _, ok := ls.subscribers[subscriber]; !ok {
+		ls.mu.Unlock()
+		return nil, ErrLiveDisconnected
+	}
+	if _, ok := subscriber.bookings[bookingID]; !ok {
+		if len(subscriber.bookings) >= ls.maxSubscriptions {
+			ls.mu.Unlock()
+			return nil, ErrTooManySubscriptions
+		}
+		subscriber.bookings[bookingID] = struct{}{}
+		if ls.byBooking[bookingID] == nil {
+			ls.byBooking[bookingID] = make(map[*LiveSubscriber]struct{})
+		}
+		ls.byBooking[bookingID][subscriber] = struct{}{}
+	}
+	ls.mu.Unlock()
+
+	booking, err := ls.bookings.GetBooking(ctx, bookingID)
+	if err != nil {
+		ls.Unsubscribe(subscriber, bookingID)
+		return nil, err
+	}
+
+	return &models.BookingLiveUpdate{
+		Type:       models.LiveUpdateSnapshot,
+		BookingID:  booking.ID,
+		Status:     booking.Status,
+		Seats:      booking.Seats,
+		OccurredAt: booking.CreatedAt,
+	}, nil
+}
+
+// Unsubscribe stops pushing a booking's updates to a connection
+func (ls *BookingLiveService) Unsubscribe(subscriber *LiveSubscriber, bookingID int) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	ls.unindex(subscriber, bookingID)
+}
+
+// dispatch pushes a booking event to the connections subscribed to its booking. A
+// connection whose buffer is full is dropped rather than blocking the others.
+func (ls *BookingLiveService) dispatch(event *events.Event) {
+	updateType := models.LiveUpdateStatus
+	switch event.Type {
+	case models.EventBookingStatusChanged:
+	case models.EventBookingUpdated:
+		updateType = models.LiveUpdateUpdated
+	default:
+		return
+	}
+
+	var payload models.BookingStatusEvent
+	if err := event.Decode(&payload); err != nil {
+		log.Printf("Skipping undecodable %s event %s: %v", event.Type, event.ID, err)
+		return
+	}
+
+	update := &models.BookingLiveUpdate{
+		Type:       updateType,
+		BookingID:  payload.BookingID,
+		Status:     payload.To,
+		From:       payload.From,
+		Seats:      payload.Seats,
+		Reason:     payload.Reason,
+		OccurredAt: event.OccurredAt,
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	for subscriber := range ls.byBooking[payload.BookingID] {
+		select {
+		case subscriber.Updates <- update:
+		default:
+			log.Printf("Dropping slow live connection subscribed to booking %d", payload.BookingID)
+			ls.remove(subscriber)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"cred_flights_booking/pkg/models"
+)
+
+// CreateManualBooking books seats sold by an operator and paid outside the payment service
+// (cash, bank transfer, or cheque). Seats are reserved like any booking, but no charge is
+// made: the booking is stored confirmed with the offline payment reference as its payment ID.
+// Repeating a request with the same payment reference returns the original booking.
+func (bs *BookingServiceV2) CreateManualBooking(ctx context.Context, req *models.ManualBookingRequest, agent string) (*models.BookingResponse, error) {
+	booking := &req.BookingRequest
+	paymentID := req.PaymentID()
+	log.Printf("Creating manual booking for user %d, flight %d, seats %d, payment %s", booking.UserID, booking.FlightID, booking.Seats, paymentID)
+
+	// A repeated submission of the same offline payment returns the booking it already made
+	bookingID, err := bs.findIdempotentBooking(ctx, booking, bookingIdempotencyKey(booking, paymentID))
+	if err == nil {
+		return bs.duplicateBookingResponse(ctx, bookingID)
+	}
+	if !errors.Is(err, ErrBookingNotFound) {
+		return nil, err
+	}
+
+	var warnings []string
+	if err := bs.checkDuplicatePassengers(ctx, bs.db, booking); err != nil {
+		var dupErr *models.DuplicatePassengerError
+		if !errors.As(err, &dupErr) || bs.duplicatePassengers == DuplicatePassengerReject {
+			return nil, err
+		}
+		warnings = append(warnings, dupErr.Error())
+	}
+
+	if booking.FareCode == "" {
+		booking.FareCode = models.DefaultFareCode
+	}
+	if _, err := bs.policies.GetPolicy(ctx, booking.FareCode); err != nil {
+		if errors.Is(err, ErrPolicyNotFound) {
+			return &models.BookingResponse{
+				Status:  models.BookingStatusFailed,
+				Message: fmt.Sprintf("Unknown fare code: %s", booking.FareCode),
+			}, nil
+		}
+		return nil, err
+	}
+
+	validation, err := bs.validateFlightViaHTTP(ctx, booking.UserID, booking.FlightID, booking.Seats, booking.Date)
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate flight: %w", err)
+	}
+	if !validation.Valid {
+		return &models.BookingResponse{
+			Status:  models.BookingStatusFailed,
+			Message: validation.Message,
+		}, nil
+	}
+
+	// The booking is stored straight away, so no temporary hold is needed
+	releaseNonce, err := bs.decrementSeatsViaHTTP(ctx, booking.UserID, booking.FlightID, booking.Seats, booking.Date, validation.SeatNonce)
+	if err != nil {
+		return &models.BookingResponse{
+			Status:  models.BookingStatusFailed,
+			Message: fmt.Sprintf("Failed to reserve seats: %v", err),
+		}, nil
+	}
+
+	bookingID, duplicate, err := bs.createPermanentBooking(ctx, booking, validation.Price, paymentID, validation.Flight, validation.Taxes)
+	if err != nil || duplicate {
+		if err := bs.incrementSeatsViaHTTP(ctx, booking.FlightID, booking.Seats, booking.Date, models.SeatReasonHoldReverted, releaseNonce); err != nil {
+			log.Printf("Failed to revert seat count for flight %d: %v", booking.FlightID, err)
+		}
+	}
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicatePassenger) {
+			return nil, err
+		}
+		return &models.BookingResponse{
+			Status:  models.BookingStatusFailed,
+			Message: fmt.Sprintf("Failed to create booking: %v", err),
+		}, nil
+	}
+	if duplicate {
+		return bs.duplicateBookingResponse(ctx, bookingID)
+	}
+
+	bs.publishOccupancyEvent(ctx, bookingID, booking.FlightID, booking.Seats, booking.Date, models.OccupancyReasonBookingConfirmed, "")
+	go bs.sendConfirmation(bookingID)
+
+	log.Printf("AUDIT: manual booking %d (user %d, flight %d on %s, %d seats, %s) created by %s with %s payment %s",
+		bookingID, booking.UserID, booking.FlightID, booking.Date, booking.Seats, validation.Price, agent, req.PaymentMethod, req.PaymentReference)
+
+	return &models.BookingResponse{
+		BookingID:   bookingID,
+		Status:      models.BookingStatusConfirmed,
+		TotalAmount: validation.Price,
+		PaymentID:   paymentID,
+		Message:     "Booking created successfully",
+		Warnings:    warnings,
+	}, nil
+}
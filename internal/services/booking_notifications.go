@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/pkg/models"
+)
+
+var (
+	// ErrBookingNotConfirmed is returned when a confirmation is requested for an unconfirmed booking
+	ErrBookingNotConfirmed = errors.New("booking is not confirmed")
+	// ErrNoContactDetails is returned when a booking has neither an email nor a phone number
+	ErrNoContactDetails = errors.New("booking has no email or phone on file")
+	// ErrResendTooSoon is returned when a confirmation was resent within the cooldown
+	ErrResendTooSoon = errors.New("confirmation was resent recently, please try again later")
+)
+
+// sendConfirmation sends a new booking's confirmation in the background. Failures are logged, not returned.
+func (bs *BookingServiceV2) sendConfirmation(bookingID int) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	booking, err := bs.GetBooking(ctx, bookingID)
+	if err != nil {
+		log.Printf("Failed to load booking %d for confirmation: %v", bookingID, err)
+		return
+	}
+	if booking.Email == "" && booking.Phone == "" {
+		return
+	}
+
+	if _, err := bs.notifier.SendBookingConfirmation(ctx, booking); err != nil {
+		log.Printf("Failed to send confirmation for booking %d: %v", bookingID, err)
+	}
+}
+
+// ResendConfirmation re-sends a confirmed booking's confirmation to its email and phone
+func (bs *BookingServiceV2) ResendConfirmation(ctx context.Context, bookingID int) (*models.ResendConfirmationResponse, error) {
+	booking, err := bs.GetBooking(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if booking.Status != models.BookingStatusConfirmed {
+		return nil, ErrBookingNotConfirmed
+	}
+	if booking.Email == "" && booking.Phone == "" {
+		return nil, ErrNoContactDetails
+	}
+
+	// Throttle resends per booking
+	cooldownKey := database.GenerateResendCooldownKey(bookingID)
+	acquired, err := bs.cache.SetNX(ctx, cooldownKey, time.Now().Unix(), bs.resendCooldown).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check resend cooldown: %w", err)
+	}
+	if !acquired {
+		return nil, ErrResendTooSoon
+	}
+
+	channels, err := bs.notifier.SendBookingConfirmation(ctx, booking)
+	if err != nil {
+		// Allow an immediate retry when nothing was delivered
+		bs.cache.Delete(ctx, cooldownKey)
+		return nil, err
+	}
+
+	log.Printf("Confirmation for booking %d resent via %v", bookingID, channels)
+	return &models.ResendConfirmationResponse{
+		BookingID: bookingID,
+		Channels:  channels,
+		SentAt:    time.Now(),
+	}, nil
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/pkg/models"
+	"github.com/lib/pq"
+)
+
+// Duplicate passenger check modes
+const (
+	DuplicatePassengerOff    = "off"    // Passengers are stored but not checked
+	DuplicatePassengerWarn   = "warn"   // Duplicates are booked with a warning on the response
+	DuplicatePassengerReject = "reject" // Duplicates fail the booking with a 409
+)
+
+// loadDuplicatePassengerMode loads DUPLICATE_PASSENGER_CHECK, falling back to off
+func loadDuplicatePassengerMode() string {
+	mode := strings.ToLower(config.GetEnv("DUPLICATE_PASSENGER_CHECK", DuplicatePassengerOff))
+	switch mode {
+	case DuplicatePassengerOff, DuplicatePassengerWarn, DuplicatePassengerReject:
+		return mode
+	}
+	log.Printf("Ignoring DUPLICATE_PASSENGER_CHECK %q, expected off, warn or reject", mode)
+	return DuplicatePassengerOff
+}
+
+// queryer is satisfied by *database.DB and *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// findDuplicatePassengers returns the document numbers among docs that already travel on an
+// active booking of the flight date
+func findDuplicatePassengers(ctx context.Context, q queryer, flightID int, date string, docs []string) ([]string, error) {
+	query := `
+		SELECT DISTINCT p.document_number
+		FROM passengers p
+		JOIN bookings b ON b.id = p.booking_id
+		WHERE p.flight_id = $1 AND p.date = $2 AND p.document_number = ANY($3)
+		  AND b.status NOT IN ($4, $5)
+		ORDER BY p.document_number
+	`
+
+	rows, err := q.QueryContext(ctx, query, flightID, date, pq.Array(docs),
+		models.BookingStatusCancelled, models.BookingStatusFailed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query passengers: %w", err)
+	}
+	defer rows.Close()
+
+	var duplicates []string
+	for rows.Next() {
+		var doc string
+		if err := rows.Scan(&doc); err != nil {
+			return nil, fmt.Errorf("failed to scan passenger: %w", err)
+		}
+		duplicates = append(duplicates, doc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read passengers: %w", err)
+	}
+	return duplicates, nil
+}
+
+// checkDuplicatePassengers returns a *models.DuplicatePassengerError when a requested
+// passenger is already booked on the flight date, or nil when the check is off or finds none
+func (bs *BookingServiceV2) checkDuplicatePassengers(ctx context.Context, q queryer, req *models.BookingRequest) error {
+	if bs.duplicatePassengers == DuplicatePassengerOff || len(req.Passengers) == 0 {
+		return nil
+	}
+
+	docs := make([]string, len(req.Passengers))
+	for i, p := range req.Passengers {
+		docs[i] = p.DocumentNumber
+	}
+	duplicates, err := findDuplicatePassengers(ctx, q, req.FlightID, req.Date, docs)
+	if err != nil {
+		return err
+	}
+	if len(duplicates) == 0 {
+		return nil
+	}
+
+	dupErr := &models.DuplicatePassengerError{FlightID: req.FlightID, Date: req.Date}
+	for _, doc := range duplicates {
+		dupErr.DocumentNumbers = append(dupErr.DocumentNumbers, models.MaskDocumentNumber(doc))
+	}
+	return dupErr
+}
+
+// insertPassengers stores a booking's passengers as part of a transaction
+func insertPassengers(ctx context.Context, tx *sql.Tx, bookingID int, req *models.BookingRequest) error {
+	query := `
+		INSERT INTO passengers (booking_id, flight_id, date, name, document_number)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	for _, p := range req.Passengers {
+		if _, err := tx.ExecContext(ctx, query, bookingID, req.FlightID, req.Date, p.Name, p.DocumentNumber); err != nil {
+			return fmt.Errorf("failed to store passenger: %w", err)
+		}
+	}
+	return nil
+}
+
+// getPassengers loads a booking's passengers in the order they were booked
+func (bs *BookingServiceV2) getPassengers(ctx context.Context, bookingID int) ([]models.Passenger, error) {
+	query := `
+		SELECT COALESCE(name, ''), COALESCE(document_number, '')
+		FROM passengers
+		WHERE booking_id = $1
+		ORDER BY id
+	`
+
+	rows, err := bs.db.QueryContext(ctx, query, bookingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query passengers: %w", err)
+	}
+	defer rows.Close()
+
+	var passengers []models.Passenger
+	for rows.Next() {
+		var p models.Passenger
+		if err := rows.Scan(&p.Name, &p.DocumentNumber); err != nil {
+			return nil, fmt.Errorf("failed to scan passenger: %w", err)
+		}
+		passengers = append(passengers, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read passengers: %w", err)
+	}
+	return passengers, nil
+}
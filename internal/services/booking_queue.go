@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/pkg/models"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrBookingQueueFull is returned when a flight date's admission queue has no room left
+	ErrBookingQueueFull = errors.New("booking queue is full")
+	// ErrQueueTicketUsed is returned when a ticket that was already admitted is resubmitted
+	ErrQueueTicketUsed = errors.New("queue ticket already admitted")
+)
+
+// Booking queue metrics, exposed via expvar (admitted, queued, rejected_full, rejected_used,
+// errors)
+var bookingQueueMetrics = expvar.NewMap("booking_queue")
+
+// BookingQueueConfig controls the per-flight admission queue that bookings wait in when
+// too many target one flight date at once
+type BookingQueueConfig struct {
+	Enabled     bool
+	Concurrency int           // Bookings of one flight date processed at a time
+	Lease       time.Duration // Longest an admitted booking holds its slot if it is never released
+	TicketTTL   time.Duration // How long a waiting ticket is kept without being polled
+	RetryAfter  time.Duration // How long queued clients are told to wait before polling again
+	MaxLength   int           // Waiting tickets per flight date before new bookings are turned away
+}
+
+// LoadBookingQueueConfig loads the booking admission queue settings from the environment
+func LoadBookingQueueConfig() BookingQueueConfig {
+	return BookingQueueConfig{
+		Enabled:     config.GetBool("BOOKING_QUEUE_ENABLED", true),
+		Concurrency: max(config.GetInt("BOOKING_QUEUE_CONCURRENCY", 20), 1),
+		Lease:       config.GetDuration("BOOKING_QUEUE_LEASE", 60*time.Second),
+		TicketTTL:   config.GetDuration("BOOKING_QUEUE_TICKET_TTL", 30*time.Second),
+		RetryAfter:  config.GetDuration("BOOKING_QUEUE_RETRY_AFTER", 2*time.Second),
+		MaxLength:   max(config.GetInt("BOOKING_QUEUE_MAX_LENGTH", 10000), 1),
+	}
+}
+
+// admitBookingScript admits a booking to a flight date when a slot is free and it is at
+// the front of the queue, or keeps its place in line. An admitted ticket is spent: its slot
+// is held by a lease token for that one request, and the ticket is refused until the lease
+// expires.
+// KEYS: waiting tickets by arrival, waiting tickets by last poll, admission leases by
+// expiry, arrival counter, spent tickets by lease expiry. ARGV: ticket, now ms,
+// concurrency, lease ms, ticket TTL ms, max length, new ticket, key TTL ms, lease token.
+// Returns {status, ticket, position, lease}: 1 admitted, 0 queued, -1 queue full,
+// -2 ticket already admitted.
+const admitBookingScript = `
+local now = tonumber(ARGV[2])
+redis.call('ZREMRANGEBYSCORE', KEYS[3], '-inf', now)
+redis.call('ZREMRANGEBYSCORE', KEYS[5], '-inf', now)
+local stale = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', now - tonumber(ARGV[5]))
+for _, t in ipairs(stale) do
+	redis.call('ZREM', KEYS[1], t)
+	redis.call('ZREM', KEYS[2], t)
+end
+
+local ticket = ARGV[1]
+if ticket ~= '' and redis.call('ZSCORE', KEYS[5], ticket) then
+	return {-2, ticket, 0, ''}
+end
+if ticket == '' or not redis.call('ZSCORE', KEYS[1], ticket) then
+	if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[6]) then
+		return {-1, '', 0, ''}
+	end
+	ticket = ARGV[7]
+	redis.call('ZADD', KEYS[1], redis.call('INCR', KEYS[4]), ticket)
+end
+redis.call('ZADD', KEYS[2], now, ticket)
+
+local status = 0
+local lease = ''
+local rank = redis.call('ZRANK', KEYS[1], ticket)
+if rank < tonumber(ARGV[3]) - redis.call('ZCARD', KEYS[3]) then
+	local expiry = now + tonumber(ARGV[4])
+	lease = ARGV[9]
+	redis.call('ZREM', KEYS[1], ticket)
+	redis.call('ZREM', KEYS[2], ticket)
+	redis.call('ZADD', KEYS[3], expiry, lease)
+	redis.call('ZADD', KEYS[5], expiry, ticket)
+	status = 1
+end
+
+for i = 1, 5 do
+	redis.call('PEXPIRE', KEYS[i], ARGV[8])
+end
+return {status, ticket, rank + 1, lease}
+`
+
+// BookingAdmission reports whether a booking may proceed or must keep waiting
+type BookingAdmission struct {
+	Admitted   bool
+	Ticket     string        // Sent back by queued clients to keep their place; spent once admitted
+	Lease      string        // Holds an admitted booking's slot until released
+	Position   int           // Place in line while queued, 1 being next
+	RetryAfter time.Duration // How long a queued client should wait before polling again
+}
+
+// bookingQueueKeys returns the keys of a flight date's admission queue
+func bookingQueueKeys(flightID int, date string) []string {
+	return []string{
+		database.GenerateBookingQueueKey(flightID, date),
+		database.GenerateBookingQueueSeenKey(flightID, date),
+		database.GenerateBookingQueueActiveKey(flightID, date),
+		database.GenerateBookingQueueSeqKey(flightID, date),
+		database.GenerateBookingQueueSpentKey(flightID, date),
+	}
+}
+
+// AdmitBooking lets a booking through when one of its flight date's slots is free and no
+// earlier ticket is waiting, otherwise it queues the booking. Clients poll again with the
+// returned ticket; a ticket not polled within the ticket TTL loses its place, and an
+// admitted ticket admits only the request it was admitted with. Redis errors admit the
+// booking, so the queue never blocks bookings on its own.
+func (bs *BookingServiceV2) AdmitBooking(ctx context.Context, req *models.BookingRequest) (*BookingAdmission, error) {
+	cfg := bs.queue
+	if !cfg.Enabled {
+		return &BookingAdmission{Admitted: true}, nil
+	}
+
+	keyTTL := max(cfg.Lease, cfg.TicketTTL) + time.Minute
+	result, err := bs.cache.Eval(ctx, admitBookingScript, bookingQueueKeys(req.FlightID, req.Date),
+		req.QueueTicket, time.Now().UnixMilli(), cfg.Concurrency, cfg.Lease.Milliseconds(),
+		cfg.TicketTTL.Milliseconds(), cfg.MaxLength, uuid.New().String(), keyTTL.Milliseconds(),
+		uuid.New().String()).Result()
+	if err != nil {
+		bookingQueueMetrics.Add("errors", 1)
+		log.Printf("Booking queue unavailable for flight %d on %s, admitting: %v", req.FlightID, req.Date, err)
+		return &BookingAdmission{Admitted: true}, nil
+	}
+
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 4 {
+		return nil, fmt.Errorf("unexpected booking queue result: %v", result)
+	}
+	status, _ := values[0].(int64)
+	ticket, _ := values[1].(string)
+	position, _ := values[2].(int64)
+	lease, _ := values[3].(string)
+
+	admission := &BookingAdmission{Ticket: ticket, Lease: lease, Position: int(position), RetryAfter: cfg.RetryAfter}
+	switch status {
+	case 1:
+		bookingQueueMetrics.Add("admitted", 1)
+		admission.Admitted = true
+		admission.Position = 0
+	case -1:
+		bookingQueueMetrics.Add("rejected_full", 1)
+		return admission, ErrBookingQueueFull
+	case -2:
+		bookingQueueMetrics.Add("rejected_used", 1)
+		return admission, ErrQueueTicketUsed
+	default:
+		bookingQueueMetrics.Add("queued", 1)
+	}
+	return admission, nil
+}
+
+// ReleaseBooking frees an admitted booking's slot for the next ticket in line. The ticket
+// stays spent until its lease would have expired.
+func (bs *BookingServiceV2) ReleaseBooking(ctx context.Context, req *models.BookingRequest, admission *BookingAdmission) {
+	if admission == nil || admission.Lease == "" {
+		return
+	}
+
+	key := database.GenerateBookingQueueActiveKey(req.FlightID, req.Date)
+	if err := bs.cache.ZRem(ctx, key, admission.Lease).Err(); err != nil {
+		// The slot frees itself when its lease expires
+		log.Printf("Failed to release booking queue slot for flight %d on %s: %v", req.FlightID, req.Date, err)
+	}
+}
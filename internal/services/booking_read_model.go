@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/internal/events"
+	"cred_flights_booking/pkg/models"
+)
+
+// readModelGroup is the consumer group projecting booking events into the read model
+const readModelGroup = "booking-read-model"
+
+// Fields of the projection progress hash
+const (
+	projectionPublished = "published" // Newest booking event published, in Unix milliseconds
+	projectionApplied   = "applied"   // Newest booking event projected, in Unix milliseconds
+)
+
+// advanceProjectionScript raises a progress field to ARGV[2] unless it is already later
+const advanceProjectionScript = `
+local current = tonumber(redis.call('HGET', KEYS[1], ARGV[1]) or '0')
+if tonumber(ARGV[2]) > current then
+	redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+end
+return 1
+`
+
+// BookingReadModel serves booking reads from booking_read_model, a denormalized copy of each
+// booking and its segments that a projector refreshes from booking events. While the
+// projection lags the newest event by more than the staleness bound, reads fall back to
+// the bookings table.
+type BookingReadModel struct {
+	db           *database.DB
+	cache        *database.RedisClient
+	enabled      bool
+	maxStaleness time.Duration
+}
+
+// NewBookingReadModel creates the booking read model
+func NewBookingReadModel(db *database.DB, cache *database.RedisClient) *BookingReadModel {
+	return &BookingReadModel{
+		db:           db,
+		cache:        cache,
+		enabled:      config.GetBool("BOOKING_READ_MODEL", true),
+		maxStaleness: config.GetDuration("BOOKING_READ_MODEL_MAX_STALENESS", 2*time.Second),
+	}
+}
+
+// BookingFilter selects bookings for a listing; zero fields do not filter
+type BookingFilter struct {
+	UserID   int
+	AgencyID int
+	FlightID int
+	Date     string
+	Status   string
+	Limit    int
+	Offset   int
+}
+
+// projectBookingsQuery copies bookings matching a condition on b into the read model
+const projectBookingsQuery = `
+	INSERT INTO booking_read_model (id, user_id, flight_id, seats, total_amount, status, payment_id, date, fare_code, cabin,
+	                                email, phone, agency_id, refund_amount, cancellation_fee, segments, taxes, created_at, projected_at)
+	SELECT b.id, b.user_id, b.flight_id, b.seats, b.total_amount, b.status, b.payment_id, b.date, b.fare_code, b.cabin,
+	       b.email, b.phone, b.agency_id, b.refund_amount, b.cancellation_fee,
+	       COALESCE((
+	           SELECT json_agg(json_build_object(
+	               'booking_id', s.booking_id, 'segment_index', s.segment_index, 'flight_id', s.flight_id,
+	               'flight_number', s.flight_number, 'source', s.source, 'destination', s.destination,
+	               'departure_time', to_char(s.departure_time, 'YYYY-MM-DD"T"HH24:MI:SS"Z"'),
+	               'arrival_time', to_char(s.arrival_time, 'YYYY-MM-DD"T"HH24:MI:SS"Z"'),
+	               'price', s.price) ORDER BY s.segment_index)
+	           FROM booking_segments s WHERE s.booking_id = b.id
+	       ), '[]'),
+	       COALESCE((
+	           SELECT json_agg(json_build_object(
+	               'jurisdiction', t.jurisdiction, 'code', t.code, 'rate', t.rate,
+	               'taxable_amount', t.taxable_amount, 'amount', t.amount) ORDER BY t.line_index)
+	           FROM booking_tax_lines t WHERE t.booking_id = b.id
+	       ), '[]'),
+	       b.created_at, NOW()
+	FROM bookings b
+	WHERE %s
+	ON CONFLICT (id) DO UPDATE SET
+	    seats = EXCLUDED.seats, total_amount = EXCLUDED.total_amount, status = EXCLUDED.status,
+	    payment_id = EXCLUDED.payment_id, cabin = EXCLUDED.cabin, email = EXCLUDED.email, phone = EXCLUDED.phone,
+	    refund_amount = EXCLUDED.refund_amount, cancellation_fee = EXCLUDED.cancellation_fee,
+	    segments = EXCLUDED.segments, taxes = EXCLUDED.taxes, projected_at = EXCLUDED.projected_at
+`
+
+// Start backfills bookings missing from the read model, then projects booking events in
+// the background until ctx is cancelled. If the backfill fails the read model is disabled,
+// since listings served from it would miss bookings.
+func (rm *BookingReadModel) Start(ctx context.Context, bus *events.Bus, consumer string) {
+	if !rm.enabled {
+		log.Printf("Booking read model disabled; reads use the bookings table")
+		return
+	}
+
+	result, err := rm.db.ExecContext(ctx, fmt.Sprintf(projectBookingsQuery,
+		"NOT EXISTS (SELECT 1 FROM booking_read_model r WHERE r.id = b.id)"))
+	if err != nil {
+		log.Printf("Failed to backfill booking read model, reads use the bookings table: %v", err)
+		rm.enabled = false
+		return
+	}
+	if backfilled, _ := result.RowsAffected(); backfilled > 0 {
+		log.Printf("Backfilled %d bookings into the read model", backfilled)
+	}
+
+	go func() {
+		if err := bus.Subscribe(ctx, events.StreamBookings, readModelGroup, consumer, rm.Project); err != nil {
+			log.Printf("Booking read model projector stopped: %v", err)
+		}
+	}()
+}
+
+// Backlog returns how many booking events the projector has yet to apply
+func (rm *BookingReadModel) Backlog(ctx context.Context, bus *events.Bus) (int64, error) {
+	if !rm.enabled {
+		return 0, nil
+	}
+	return bus.Backlog(ctx, events.StreamBookings, readModelGroup)
+}
+
+// Project refreshes the read model row of the booking an event is about. The row is
+// copied from the bookings table, so events applied out of order still converge.
+func (rm *BookingReadModel) Project(ctx context.Context, event *events.Event) error {
+	var payload models.BookingStatusEvent
+	if err := event.Decode(&payload); err != nil {
+		// Dead-lettered once its attempts run out, so the booking isn't silently left stale
+		return err
+	}
+
+	if err := rm.Refresh(ctx, payload.BookingID); err != nil {
+		return err
+	}
+
+	rm.advance(ctx, projectionApplied, event.OccurredAt)
+	return nil
+}
+
+// Refresh copies one booking from the bookings table into the read model
+func (rm *BookingReadModel) Refresh(ctx context.Context, bookingID int) error {
+	if !rm.enabled {
+		return nil
+	}
+	if _, err := rm.db.ExecContext(ctx, fmt.Sprintf(projectBookingsQuery, "b.id = $1"), bookingID); err != nil {
+		return fmt.Errorf("failed to project booking %d: %w", bookingID, err)
+	}
+	return nil
+}
+
+// MarkPublished records that a booking event was published, so reads can tell whether the
+// projection has caught up
+func (rm *BookingReadModel) MarkPublished(ctx context.Context, at time.Time) {
+	if rm.enabled {
+		rm.advance(ctx, projectionPublished, at)
+	}
+}
+
+// advance raises a projection progress field to at
+func (rm *BookingReadModel) advance(ctx context.Context, field string, at time.Time) {
+	key := database.GenerateBookingProjectionKey()
+	if err := rm.cache.Eval(ctx, advanceProjectionScript, []string{key}, field, at.UnixMilli()).Err(); err != nil {
+		log.Printf("Failed to record booking projection %s progress: %v", field, err)
+	}
+}
+
+// fresh reports whether reads may use the read model: every published event is projected,
+// or the last projected one is within the staleness bound (so no pending event is older)
+func (rm *BookingReadModel) fresh(ctx context.Context) bool {
+	if !rm.enabled {
+		return false
+	}
+
+	values, err := rm.cache.HMGet(ctx, database.GenerateBookingProjectionKey(), projectionPublished, projectionApplied).Result()
+	if err != nil {
+		log.Printf("Failed to read booking projection progress: %v", err)
+		return false
+	}
+	published, applied := progressMillis(values[0]), progressMillis(values[1])
+	if applied >= published {
+		return true
+	}
+	return time.Since(time.UnixMilli(applied)) <= rm.maxStaleness
+}
+
+// progressMillis parses a projection progress value, treating a missing one as zero
+func progressMillis(value interface{}) int64 {
+	s, _ := value.(string)
+	millis, _ := strconv.ParseInt(s, 10, 64)
+	return millis
+}
+
+// Get returns a booking from the read model. It returns ErrBookingNotFound when the read
+// model is stale or has not projected the booking yet, so callers read the bookings table.
+func (rm *BookingReadModel) Get(ctx context.Context, bookingID int) (*models.Booking, error) {
+	if !rm.fresh(ctx) {
+		return nil, ErrBookingNotFound
+	}
+
+	query := `
+		SELECT id, user_id, flight_id, seats, total_amount, status, COALESCE(payment_id, ''), date, fare_code, cabin,
+		       COALESCE(email, ''), COALESCE(phone, ''), COALESCE(agency_id, 0), created_at, segments, taxes
+		FROM booking_read_model
+		WHERE id = $1
+	`
+
+	var booking models.Booking
+	var segments, taxes []byte
+	err := rm.db.QueryRowContext(ctx, query, bookingID).Scan(
+		&booking.ID, &booking.UserID, &booking.FlightID, &booking.Seats, &booking.TotalAmount,
+		&booking.Status, &booking.PaymentID, &booking.Date, &booking.FareCode, &booking.Cabin,
+		&booking.Email, &booking.Phone, &booking.AgencyID, &booking.CreatedAt, &segments, &taxes,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrBookingNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query booking read model: %w", err)
+	}
+	if err := json.Unmarshal(segments, &booking.Segments); err != nil {
+		return nil, fmt.Errorf("failed to decode booking segments: %w", err)
+	}
+	if len(booking.Segments) == 0 {
+		booking.Segments = nil
+	}
+	if err := json.Unmarshal(taxes, &booking.Taxes); err != nil {
+		return nil, fmt.Errorf("failed to decode booking taxes: %w", err)
+	}
+	if len(booking.Taxes) == 0 {
+		booking.Taxes = nil
+	}
+
+	return &booking, nil
+}
+
+// List returns bookings matching a filter, newest first, from the read model when it is
+// fresh and from the bookings table otherwise
+func (rm *BookingReadModel) List(ctx context.Context, filter BookingFilter) ([]models.Booking, error) {
+	table := "bookings"
+	if rm.fresh(ctx) {
+		table = "booking_read_model"
+	}
+
+	query := `
+		SELECT id, user_id, flight_id, seats, total_amount, status, COALESCE(payment_id, ''), date, fare_code, cabin,
+		       COALESCE(email, ''), COALESCE(phone, ''), COALESCE(agency_id, 0), created_at
+		FROM ` + table + `
+		WHERE ($1 = 0 OR user_id = $1) AND ($2 = 0 OR agency_id = $2) AND ($3 = 0 OR flight_id = $3)
+		  AND ($4 = '' OR date = $4) AND ($5 = '' OR status = $5)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $6 OFFSET $7
+	`
+
+	rows, err := rm.db.QueryContext(ctx, query, filter.UserID, filter.AgencyID, filter.FlightID, filter.Date,
+		filter.Status, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bookings: %w", err)
+	}
+	defer rows.Close()
+
+	bookings := []models.Booking{}
+	for rows.Next() {
+		var booking models.Booking
+		if err := rows.Scan(&booking.ID, &booking.UserID, &booking.FlightID, &booking.Seats, &booking.TotalAmount,
+			&booking.Status, &booking.PaymentID, &booking.Date, &booking.FareCode, &booking.Cabin,
+			&booking.Email, &booking.Phone, &booking.AgencyID, &booking.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan booking: %w", err)
+		}
+		bookings = append(bookings, booking)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read bookings: %w", err)
+	}
+
+	return bookings, nil
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/events"
+	"cred_flights_booking/internal/webhooks"
+	"cred_flights_booking/pkg/models"
+)
+
+// receiptsGroup is the consumer group delivering booking receipts to accounting
+const receiptsGroup = "accounting-receipts"
+
+// receiptStats exposes booking receipt delivery counters (delivered, failed)
+var receiptStats = expvar.NewMap("booking_receipts")
+
+// ReceiptConfig controls where confirmed-booking receipts are delivered
+type ReceiptConfig struct {
+	URL           string        // Accounting endpoint receipts are POSTed to; empty disables delivery
+	Secrets       []string      // Webhook secrets deliveries are signed with
+	Timeout       time.Duration // Per-delivery timeout
+	InvoicePrefix string        // Prefix of invoice numbers
+}
+
+// LoadReceiptConfig loads accounting receipt settings from the environment
+func LoadReceiptConfig() ReceiptConfig {
+	return ReceiptConfig{
+		URL:           config.GetEnv("ACCOUNTING_WEBHOOK_URL", ""),
+		Secrets:       config.GetList("ACCOUNTING_WEBHOOK_SECRETS", nil),
+		Timeout:       config.GetDuration("ACCOUNTING_WEBHOOK_TIMEOUT", 10*time.Second),
+		InvoicePrefix: config.GetEnv("ACCOUNTING_INVOICE_PREFIX", "INV"),
+	}
+}
+
+// ReceiptExporter posts the financial summary of every confirmed booking to an accounting
+// endpoint. Deliveries are driven by booking events, so a failed delivery is retried and,
+// once its attempts run out, dead-lettered for replay like any other event.
+type ReceiptExporter struct {
+	bookings *BookingServiceV2
+	cfg      ReceiptConfig
+	client   *http.Client
+	signer   *webhooks.Signer
+}
+
+// NewReceiptExporter creates a receipt exporter
+func NewReceiptExporter(bookings *BookingServiceV2, cfg ReceiptConfig) *ReceiptExporter {
+	return &ReceiptExporter{
+		bookings: bookings,
+		cfg:      cfg,
+		client:   &http.Client{Timeout: cfg.Timeout},
+		signer:   webhooks.NewSigner(webhooks.Config{Secrets: cfg.Secrets}),
+	}
+}
+
+// Start delivers receipts of confirmed bookings in the background until ctx is cancelled
+func (re *ReceiptExporter) Start(ctx context.Context, bus *events.Bus, consumer string) {
+	if re.cfg.URL == "" {
+		return
+	}
+
+	go func() {
+		if err := bus.Subscribe(ctx, events.StreamBookings, receiptsGroup, consumer, re.Deliver); err != nil {
+			log.Printf("Booking receipt exporter stopped: %v", err)
+		}
+	}()
+}
+
+// Backlog returns how many booking events the exporter has yet to handle
+func (re *ReceiptExporter) Backlog(ctx context.Context, bus *events.Bus) (int64, error) {
+	if re.cfg.URL == "" {
+		return 0, nil
+	}
+	return bus.Backlog(ctx, events.StreamBookings, receiptsGroup)
+}
+
+// Deliver posts the receipt of a booking that was just confirmed. Other events are ignored.
+func (re *ReceiptExporter) Deliver(ctx context.Context, event *events.Event) error {
+	if event.Type != models.EventBookingStatusChanged {
+		return nil
+	}
+	var payload models.BookingStatusEvent
+	if err := event.Decode(&payload); err != nil {
+		return err
+	}
+	if payload.To != models.BookingStatusConfirmed {
+		return nil
+	}
+
+	receipt, err := re.buildReceipt(ctx, payload.BookingID, event.OccurredAt)
+	if err != nil {
+		return err
+	}
+	if err := re.send(ctx, receipt); err != nil {
+		receiptStats.Add("failed", 1)
+		return err
+	}
+
+	receiptStats.Add("delivered", 1)
+	log.Printf("Delivered receipt %s for booking %d to accounting", receipt.InvoiceNumber, receipt.BookingID)
+	return nil
+}
+
+// InvoiceNumber returns a booking's invoice number
+func (re *ReceiptExporter) InvoiceNumber(bookingID int) string {
+	return fmt.Sprintf("%s-%08d", re.cfg.InvoicePrefix, bookingID)
+}
+
+// buildReceipt loads a booking's amounts and tax lines from the bookings tables. The read
+// model and cache are skipped since they may not have caught up with the event yet.
+func (re *ReceiptExporter) buildReceipt(ctx context.Context, bookingID int, confirmedAt time.Time) (*models.BookingReceipt, error) {
+	query := `
+		SELECT user_id, COALESCE(agency_id, 0), flight_id, date, seats, fare_code, total_amount,
+		       COALESCE(payment_id, ''), created_at
+		FROM bookings
+		WHERE id = $1
+	`
+
+	receipt := &models.BookingReceipt{
+		InvoiceNumber: re.InvoiceNumber(bookingID),
+		BookingID:     bookingID,
+		ConfirmedAt:   confirmedAt,
+	}
+	err := re.bookings.db.QueryRowContext(ctx, query, bookingID).Scan(
+		&receipt.UserID, &receipt.AgencyID, &receipt.FlightID, &receipt.Date, &receipt.Seats,
+		&receipt.FareCode, &receipt.TotalAmount, &receipt.PaymentID, &receipt.BookedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrBookingNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query booking for receipt: %w", err)
+	}
+
+	taxes, err := re.bookings.getTaxLines(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	receipt.Taxes = taxes
+	if receipt.Taxes == nil {
+		receipt.Taxes = []models.TaxLine{}
+	}
+
+	receipt.TaxTotal = models.Money{Currency: receipt.TotalAmount.Currency}
+	for _, line := range taxes {
+		receipt.TaxTotal = receipt.TaxTotal.Add(line.Amount)
+	}
+	receipt.BaseFare = receipt.TotalAmount.Sub(receipt.TaxTotal)
+	receipt.Currency = receipt.TotalAmount.Currency
+	if receipt.Currency == "" {
+		receipt.Currency = models.DefaultCurrency
+	}
+	return receipt, nil
+}
+
+// send POSTs a signed receipt to the accounting endpoint
+func (re *ReceiptExporter) send(ctx context.Context, receipt *models.BookingReceipt) error {
+	body, err := json.Marshal(receipt)
+	if err != nil {
+		return fmt.Errorf("failed to marshal receipt: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, re.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create receipt request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", receipt.InvoiceNumber)
+	re.signer.Sign(req, body)
+
+	resp, err := re.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send receipt %s: %w", receipt.InvoiceNumber, err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("accounting endpoint returned status %d for receipt %s", resp.StatusCode, receipt.InvoiceNumber)
+	}
+	return nil
+}
@@ -1,49 +1,159 @@
 package services
 
 import (
-	"bytes"
 	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"net/http"
 	"time"
 
+	"cred_flights_booking/internal/config"
 	"cred_flights_booking/internal/database"
-	"cred_flights_booking/internal/models"
+	"cred_flights_booking/internal/events"
+	"cred_flights_booking/internal/funnel"
+	"cred_flights_booking/internal/hedging"
+	"cred_flights_booking/internal/httpclient"
+	"cred_flights_booking/internal/notifications"
+	"cred_flights_booking/internal/webhooks"
+	"cred_flights_booking/pkg/client"
+	"cred_flights_booking/pkg/models"
+	"github.com/google/uuid"
+	"golang.org/x/sync/errgroup"
 )
 
+// ErrBookingNotFound is returned when a booking does not exist
+var ErrBookingNotFound = errors.New("booking not found")
+
 // BookingServiceV2 handles booking-related operations with improved architecture
 type BookingServiceV2 struct {
-	db                *database.DB
-	cache             *database.RedisClient
-	flightServiceURL  string
-	paymentServiceURL string
-	httpClient        *http.Client
+	db               *database.DB
+	cache            *database.RedisClient
+	events           *events.Bus
+	readModel        *BookingReadModel
+	policies         *CancellationPolicyService
+	agencies         *AgencyService
+	notifier         *notifications.Notifier
+	funnel           *funnel.Tracker
+	flights          *client.FlightClient
+	payments         *client.PaymentClient
+	resendCooldown   time.Duration
+	batchConcurrency int
+	completionBatch  int
+	loyaltyRate      float64
+	holds            HoldConfig
+	queue            BookingQueueConfig
+	// DUPLICATE_PASSENGER_CHECK: off, warn, or reject
+	duplicatePassengers string
+	upgradeOfferTTL     time.Duration
 }
 
 // NewBookingServiceV2 creates a new booking service
-func NewBookingServiceV2(db *database.DB, cache *database.RedisClient, flightServiceURL, paymentServiceURL string) *BookingServiceV2 {
+func NewBookingServiceV2(db *database.DB, cache *database.RedisClient, bus *events.Bus, readModel *BookingReadModel, policies *CancellationPolicyService, agencies *AgencyService, notifier *notifications.Notifier, flightServiceURL, paymentServiceURL string) *BookingServiceV2 {
 	return &BookingServiceV2{
-		db:                db,
-		cache:             cache,
-		flightServiceURL:  flightServiceURL,
-		paymentServiceURL: paymentServiceURL,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		db:                  db,
+		cache:               cache,
+		events:              bus,
+		readModel:           readModel,
+		policies:            policies,
+		agencies:            agencies,
+		notifier:            notifier,
+		funnel:              funnel.NewTracker(cache),
+		flights:             client.NewFlightClient(flightClientConfig(flightServiceURL)),
+		payments:            client.NewPaymentClient(httpclient.ServiceConfig(paymentServiceURL, 30*time.Second)),
+		resendCooldown:      config.GetDuration("CONFIRMATION_RESEND_COOLDOWN", time.Minute),
+		batchConcurrency:    max(config.GetInt("BOOKING_BATCH_CONCURRENCY", 4), 1),
+		completionBatch:     max(config.GetInt("BOOKING_COMPLETION_BATCH_SIZE", 500), 1),
+		loyaltyRate:         config.GetFloat("LOYALTY_POINTS_PER_UNIT", 0.1),
+		holds:               LoadHoldConfig(),
+		queue:               LoadBookingQueueConfig(),
+		duplicatePassengers: loadDuplicatePassengerMode(),
+		upgradeOfferTTL:     config.GetDuration("UPGRADE_OFFER_TTL", 10*time.Minute),
 	}
 }
 
-// CreateBooking creates a new booking with improved flow
-func (bs *BookingServiceV2) CreateBooking(ctx context.Context, req *models.BookingRequest) (*models.BookingResponse, error) {
+// flightClientConfig returns the Flight Service client settings. Occupancy events and
+// release nonce requests are signed, since the Flight Service verifies them as webhooks.
+func flightClientConfig(baseURL string) client.Config {
+	cfg := httpclient.ServiceConfig(baseURL, 30*time.Second)
+	cfg.Signer = webhooks.NewSigner(webhooks.LoadConfig())
+	return cfg
+}
+
+// CreateBooking creates a new booking with improved flow.
+// Each step is timed; the breakdown is logged and attached to the response.
+func (bs *BookingServiceV2) CreateBooking(ctx context.Context, req *models.BookingRequest) (response *models.BookingResponse, err error) {
 	log.Printf("Creating booking for user %d, flight %d, seats %d", req.UserID, req.FlightID, req.Seats)
 
-	// Step 1: Validate flight availability via Flight Service
-	validation, err := bs.validateFlightViaHTTP(ctx, req.FlightID, req.Seats, req.Date)
-	if err != nil {
-		return nil, fmt.Errorf("failed to validate flight: %w", err)
+	timer := newStepTimer()
+	defer func() { timer.finish(ctx, response) }()
+
+	// A retry with a known idempotency key returns the original booking without charging again
+	if req.IdempotencyKey != "" {
+		bookingID, err := bs.findIdempotentBooking(ctx, req, req.IdempotencyKey)
+		if err == nil {
+			return bs.duplicateBookingResponse(ctx, bookingID)
+		}
+		if !errors.Is(err, ErrBookingNotFound) {
+			return nil, err
+		}
+	}
+
+	// A passenger already booked on the flight date is rejected before seats are held, or
+	// flagged on the response in warn mode
+	var warnings []string
+	if err := bs.checkDuplicatePassengers(ctx, bs.db, req); err != nil {
+		var dupErr *models.DuplicatePassengerError
+		if !errors.As(err, &dupErr) {
+			return nil, err
+		}
+		if bs.duplicatePassengers == DuplicatePassengerReject {
+			return nil, err
+		}
+		log.Printf("Booking for user %d: %v", req.UserID, err)
+		warnings = append(warnings, dupErr.Error())
+	}
+
+	// Step 1: Resolve the fare's cancellation rules and validate flight availability via
+	// Flight Service. Both are reads, so they run concurrently.
+	if req.FareCode == "" {
+		req.FareCode = models.DefaultFareCode
+	}
+
+	var (
+		validation             *models.FlightValidationResponse
+		policyErr, validateErr error
+		reads                  errgroup.Group
+	)
+	reads.Go(func() error {
+		defer timer.step(stepPolicy)()
+		_, policyErr = bs.policies.GetPolicy(ctx, req.FareCode)
+		return policyErr
+	})
+	reads.Go(func() error {
+		defer timer.step(stepValidate)()
+		validation, validateErr = bs.validateFlightViaHTTP(ctx, req.UserID, req.FlightID, req.Seats, req.Date)
+		return validateErr
+	})
+	reads.Wait()
+
+	if policyErr != nil {
+		if errors.Is(policyErr, ErrPolicyNotFound) {
+			return &models.BookingResponse{
+				Status:  models.BookingStatusFailed,
+				Message: fmt.Sprintf("Unknown fare code: %s", req.FareCode),
+			}, nil
+		}
+		return nil, policyErr
+	}
+	if validateErr != nil {
+		return nil, fmt.Errorf("failed to validate flight: %w", validateErr)
+	}
+
+	var route string
+	if validation.Flight != nil {
+		route = funnel.Route(validation.Flight.Source, validation.Flight.Destination)
+		bs.funnel.Track(ctx, funnel.StageBookingAttempt, route)
 	}
 
 	if !validation.Valid {
@@ -53,31 +163,53 @@ func (bs *BookingServiceV2) CreateBooking(ctx context.Context, req *models.Booki
 		}, nil
 	}
 
-	// Step 2: Create temporary booking in Redis
+	// Steps 2 and 3: Create temporary booking in Redis and decrement seats in Flight Service.
+	// Neither depends on the other, so they run concurrently; each is undone if the other fails.
+	now := time.Now()
 	tempBooking := &models.TempBooking{
+		HoldID:      uuid.New().String(),
 		UserID:      req.UserID,
 		FlightID:    req.FlightID,
 		Seats:       req.Seats,
 		TotalAmount: validation.Price,
 		Date:        req.Date,
-		CreatedAt:   time.Now(),
-		ExpiresAt:   time.Now().Add(15 * time.Minute), // 15 minutes expiry
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(bs.holds.TTL),
 	}
-
 	tempBookingKey := database.GenerateTempBookingCacheKey(req.UserID, req.FlightID)
-	if err := bs.cache.SetJSON(ctx, tempBookingKey, tempBooking, 15*time.Minute); err != nil {
-		return nil, fmt.Errorf("failed to create temporary booking: %w", err)
-	}
 
-	// Step 3: Decrement seats in Flight Service
-	if err := bs.decrementSeatsViaHTTP(ctx, req.FlightID, req.Seats, req.Date); err != nil {
+	var (
+		holdErr, decrementErr error
+		releaseNonce          *models.SeatNonce
+		reserve               errgroup.Group
+	)
+	reserve.Go(func() error {
+		defer timer.step(stepHold)()
+		holdErr = bs.storeHold(ctx, tempBookingKey, tempBooking)
+		return holdErr
+	})
+	reserve.Go(func() error {
+		defer timer.step(stepDecrement)()
+		releaseNonce, decrementErr = bs.decrementSeatsViaHTTP(ctx, req.UserID, req.FlightID, req.Seats, req.Date, validation.SeatNonce)
+		return decrementErr
+	})
+	reserve.Wait()
+
+	if decrementErr != nil {
 		// Clean up temporary booking
 		bs.cache.Delete(ctx, tempBookingKey)
 		return &models.BookingResponse{
 			Status:  models.BookingStatusFailed,
-			Message: fmt.Sprintf("Failed to reserve seats: %v", err),
+			Message: fmt.Sprintf("Failed to reserve seats: %v", decrementErr),
 		}, nil
 	}
+	if holdErr != nil {
+		// Seats were taken without a hold, so give them back
+		done := timer.step(stepRevert)
+		bs.revertBookingOnFailure(ctx, req.FlightID, req.Seats, req.Date, tempBookingKey, releaseNonce)
+		done()
+		return nil, fmt.Errorf("failed to create temporary booking: %w", holdErr)
+	}
 
 	// Step 4: Process payment
 	paymentReq := &models.PaymentRequest{
@@ -85,12 +217,22 @@ func (bs *BookingServiceV2) CreateBooking(ctx context.Context, req *models.Booki
 		Amount:      validation.Price,
 		UserID:      req.UserID,
 		PaymentType: "credit_card", // Default payment type
+		Quote:       validation.Quote,
 	}
 
-	paymentResp, err := bs.processPayment(ctx, paymentReq)
+	done := timer.step(stepPayment)
+	var paymentResp *models.PaymentResponse
+	if req.AgencyID > 0 {
+		paymentResp, err = bs.chargeAgency(ctx, req.AgencyID, validation.Price)
+	} else {
+		paymentResp, err = bs.processPayment(ctx, paymentReq)
+	}
+	done()
 	if err != nil {
 		// Payment failed - revert seat count and clean up
-		bs.revertBookingOnFailure(ctx, req.FlightID, req.Seats, req.Date, tempBookingKey)
+		done = timer.step(stepRevert)
+		bs.revertBookingOnFailure(ctx, req.FlightID, req.Seats, req.Date, tempBookingKey, releaseNonce)
+		done()
 		return &models.BookingResponse{
 			Status:  models.BookingStatusFailed,
 			Message: fmt.Sprintf("Payment failed: %v", err),
@@ -102,31 +244,106 @@ func (bs *BookingServiceV2) CreateBooking(ctx context.Context, req *models.Booki
 	switch paymentResp.Status {
 	case models.PaymentStatusSuccess:
 		bookingStatus = models.BookingStatusConfirmed
+		if route != "" {
+			bs.funnel.Track(ctx, funnel.StagePayment, route)
+		}
+		// Never store a booking whose payment was for another amount than its total
+		if paymentResp.Amount.Minor != validation.Price.Minor {
+			mismatch := &models.AmountMismatchError{Expected: validation.Price, Actual: paymentResp.Amount}
+			done = timer.step(stepRevert)
+			bs.revertBookingOnFailure(ctx, req.FlightID, req.Seats, req.Date, tempBookingKey, releaseNonce)
+			if req.AgencyID > 0 {
+				if err := bs.agencies.Credit(ctx, req.AgencyID, paymentResp.PaymentID, paymentResp.Amount, "amount_mismatch"); err != nil {
+					log.Printf("Failed to reverse agency charge %s: %v", paymentResp.PaymentID, err)
+				}
+			} else {
+				log.Printf("Payment %s rejected (%v) and left uncaptured to be voided", paymentResp.PaymentID, mismatch)
+			}
+			done()
+			return &models.BookingResponse{
+				Status:  models.BookingStatusFailed,
+				Message: mismatch.Error(),
+			}, nil
+		}
+
 		// Create permanent booking in database
-		bookingID, err := bs.createPermanentBooking(ctx, req, validation.Price, paymentResp.PaymentID)
+		done = timer.step(stepPersist)
+		bookingID, duplicate, err := bs.createPermanentBooking(ctx, req, validation.Price, paymentResp.PaymentID, validation.Flight, validation.Taxes)
+		done()
 		if err != nil {
 			// Revert everything on database failure
-			bs.revertBookingOnFailure(ctx, req.FlightID, req.Seats, req.Date, tempBookingKey)
+			done = timer.step(stepRevert)
+			bs.revertBookingOnFailure(ctx, req.FlightID, req.Seats, req.Date, tempBookingKey, releaseNonce)
+			reason := "booking_failed"
+			if errors.Is(err, models.ErrDuplicatePassenger) {
+				reason = "duplicate_passenger"
+			}
+			if req.AgencyID > 0 {
+				if err := bs.agencies.Credit(ctx, req.AgencyID, paymentResp.PaymentID, validation.Price, reason); err != nil {
+					log.Printf("Failed to reverse agency charge %s: %v", paymentResp.PaymentID, err)
+				}
+			} else if reason == "duplicate_passenger" {
+				log.Printf("Payment %s rejected (%v) and left uncaptured to be voided", paymentResp.PaymentID, err)
+			}
+			done()
+			// A passenger booked concurrently on the flight is reported like the early check
+			if reason == "duplicate_passenger" {
+				return nil, err
+			}
 			return &models.BookingResponse{
 				Status:  models.BookingStatusFailed,
 				Message: fmt.Sprintf("Failed to create booking: %v", err),
 			}, nil
 		}
+		if duplicate {
+			// A concurrent request stored this booking first; undo this attempt's seats and charge
+			done = timer.step(stepRevert)
+			bs.revertBookingOnFailure(ctx, req.FlightID, req.Seats, req.Date, tempBookingKey, releaseNonce)
+			if req.AgencyID > 0 {
+				if err := bs.agencies.Credit(ctx, req.AgencyID, paymentResp.PaymentID, validation.Price, "duplicate_booking"); err != nil {
+					log.Printf("Failed to reverse agency charge %s: %v", paymentResp.PaymentID, err)
+				}
+			} else {
+				log.Printf("Payment %s duplicates booking %d and is left uncaptured to be voided", paymentResp.PaymentID, bookingID)
+			}
+			done()
+			return bs.duplicateBookingResponse(ctx, bookingID)
+		}
 		// Remove temporary booking
 		bs.cache.Delete(ctx, tempBookingKey)
 
+		// Card payments are only held until captured; agency charges are final
+		if req.AgencyID == 0 {
+			bs.capturePayment(ctx, paymentResp.PaymentID, bookingID)
+		}
+
+		// Publish occupancy change for load-factor tracking
+		done = timer.step(stepPublish)
+		bs.publishOccupancyEvent(ctx, bookingID, req.FlightID, req.Seats, req.Date, models.OccupancyReasonBookingConfirmed, "")
+		done()
+
+		if route != "" {
+			bs.funnel.Track(ctx, funnel.StageConfirmation, route)
+		}
+
+		// Send the confirmation without holding up the response
+		go bs.sendConfirmation(bookingID)
+
 		return &models.BookingResponse{
 			BookingID:   bookingID,
 			Status:      bookingStatus,
 			TotalAmount: validation.Price,
 			PaymentID:   paymentResp.PaymentID,
 			Message:     "Booking created successfully",
+			Warnings:    warnings,
 		}, nil
 
 	case models.PaymentStatusFailed, models.PaymentStatusTimeout:
 		bookingStatus = models.BookingStatusFailed
 		// Revert seat count and clean up
-		bs.revertBookingOnFailure(ctx, req.FlightID, req.Seats, req.Date, tempBookingKey)
+		done = timer.step(stepRevert)
+		bs.revertBookingOnFailure(ctx, req.FlightID, req.Seats, req.Date, tempBookingKey, releaseNonce)
+		done()
 		return &models.BookingResponse{
 			Status:      bookingStatus,
 			TotalAmount: validation.Price,
@@ -135,126 +352,115 @@ func (bs *BookingServiceV2) CreateBooking(ctx context.Context, req *models.Booki
 
 	default:
 		bookingStatus = models.BookingStatusPending
-		// Keep temporary booking for retry
+		// Keep temporary booking for retry; the hold can be extended if payment takes longer
 		return &models.BookingResponse{
-			Status:      bookingStatus,
-			TotalAmount: validation.Price,
-			Message:     "Payment pending, please retry",
+			Status:        bookingStatus,
+			TotalAmount:   validation.Price,
+			Message:       "Payment pending, please retry",
+			HoldID:        tempBooking.HoldID,
+			HoldExpiresAt: &tempBooking.ExpiresAt,
+			Warnings:      warnings,
 		}, nil
 	}
 }
 
-// validateFlightViaHTTP validates flight via HTTP call to Flight Service
-func (bs *BookingServiceV2) validateFlightViaHTTP(ctx context.Context, flightID, seats int, date string) (*models.FlightValidationResponse, error) {
-	reqBody := models.FlightValidationRequest{
+// validateFlightViaHTTP validates flight via HTTP call to Flight Service, priced for the
+// user's experiment variant
+func (bs *BookingServiceV2) validateFlightViaHTTP(ctx context.Context, userID, flightID, seats int, date string) (*models.FlightValidationResponse, error) {
+	// Validation is a read, so it may be hedged like a GET
+	validation, err := bs.flights.Validate(hedging.Idempotent(ctx), &models.FlightValidationRequest{
 		FlightID: flightID,
 		Seats:    seats,
 		Date:     date,
-	}
-
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal validation request: %w", err)
-	}
-
-	url := fmt.Sprintf("%s/api/flights/validate", bs.flightServiceURL)
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
-	}
-
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	resp, err := bs.httpClient.Do(httpReq)
+		UserID:   userID,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to make validation request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("validation request failed with status: %d", resp.StatusCode)
-	}
-
-	var validation models.FlightValidationResponse
-	if err := json.NewDecoder(resp.Body).Decode(&validation); err != nil {
-		return nil, fmt.Errorf("failed to decode validation response: %w", err)
+		return nil, fmt.Errorf("failed to validate flight: %w", err)
 	}
 
-	return &validation, nil
+	return validation, nil
 }
 
-// decrementSeatsViaHTTP decrements seats via HTTP call to Flight Service
-func (bs *BookingServiceV2) decrementSeatsViaHTTP(ctx context.Context, flightID, seats int, date string) error {
-	reqBody := models.SeatUpdateRequest{
+// decrementSeatsViaHTTP decrements seats via HTTP call to Flight Service, spending the nonce
+// issued at validation. It returns the nonce for giving the seats back.
+func (bs *BookingServiceV2) decrementSeatsViaHTTP(ctx context.Context, userID, flightID, seats int, date string, nonce *models.SeatNonce) (*models.SeatNonce, error) {
+	response, err := bs.flights.DecrementSeats(ctx, &models.SeatUpdateRequest{
 		FlightID: flightID,
 		Seats:    seats,
 		Date:     date,
-	}
-
-	jsonData, err := json.Marshal(reqBody)
+		UserID:   userID,
+		Reason:   models.SeatReasonBookingHold,
+		Nonce:    nonce,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to marshal seat update request: %w", err)
+		return nil, fmt.Errorf("failed to decrement seats: %w", err)
 	}
 
-	url := fmt.Sprintf("%s/api/flights/seats/decrement", bs.flightServiceURL)
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Errorf("failed to create HTTP request: %w", err)
-	}
-
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	resp, err := bs.httpClient.Do(httpReq)
-	if err != nil {
-		return fmt.Errorf("failed to make seat decrement request: %w", err)
-	}
-	defer resp.Body.Close()
+	return response.ReleaseNonce, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("seat decrement request failed with status: %d", resp.StatusCode)
+// incrementSeatsViaHTTP increments seats via HTTP call to Flight Service, spending the nonce
+// issued with the reservation or a new one when it is nil
+func (bs *BookingServiceV2) incrementSeatsViaHTTP(ctx context.Context, flightID, seats int, date, reason string, nonce *models.SeatNonce) error {
+	if nonce == nil {
+		var err error
+		if nonce, err = bs.releaseNonce(ctx, flightID, seats, date, models.CabinEconomy); err != nil {
+			return err
+		}
 	}
 
-	return nil
-}
-
-// incrementSeatsViaHTTP increments seats via HTTP call to Flight Service
-func (bs *BookingServiceV2) incrementSeatsViaHTTP(ctx context.Context, flightID, seats int, date string) error {
-	reqBody := models.SeatUpdateRequest{
+	err := bs.flights.IncrementSeats(ctx, &models.SeatUpdateRequest{
 		FlightID: flightID,
 		Seats:    seats,
 		Date:     date,
-	}
-
-	jsonData, err := json.Marshal(reqBody)
+		Reason:   reason,
+		Nonce:    nonce,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to marshal seat update request: %w", err)
+		return fmt.Errorf("failed to increment seats: %w", err)
 	}
 
-	url := fmt.Sprintf("%s/api/flights/seats/increment", bs.flightServiceURL)
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	return nil
+}
+
+// releaseNonce asks the Flight Service for a nonce to give seats back to a cabin (empty for
+// economy), for releases without the one issued with the reservation
+func (bs *BookingServiceV2) releaseNonce(ctx context.Context, flightID, seats int, date, cabin string) (*models.SeatNonce, error) {
+	nonce, err := bs.flights.ReleaseNonce(hedging.Idempotent(ctx), flightID, date, cabin, seats)
 	if err != nil {
-		return fmt.Errorf("failed to create HTTP request: %w", err)
+		return nil, fmt.Errorf("failed to get release nonce: %w", err)
 	}
+	return nonce, nil
+}
 
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	resp, err := bs.httpClient.Do(httpReq)
+// releaseBookingSeats gives a booking's seats back to the Flight Service, to its premium
+// cabin when it was upgraded
+func (bs *BookingServiceV2) releaseBookingSeats(ctx context.Context, booking *models.Booking, seats int, reason string) error {
+	nonce, err := bs.releaseNonce(ctx, booking.FlightID, seats, booking.Date, booking.Cabin)
 	if err != nil {
-		return fmt.Errorf("failed to make seat increment request: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("seat increment request failed with status: %d", resp.StatusCode)
+	err = bs.flights.IncrementSeats(ctx, &models.SeatUpdateRequest{
+		FlightID: booking.FlightID,
+		Seats:    seats,
+		Date:     booking.Date,
+		Reason:   reason,
+		Cabin:    booking.Cabin,
+		Nonce:    nonce,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to increment seats: %w", err)
 	}
 
 	return nil
 }
 
-// revertBookingOnFailure reverts seat count and cleans up temporary booking
-func (bs *BookingServiceV2) revertBookingOnFailure(ctx context.Context, flightID, seats int, date, tempBookingKey string) {
+// revertBookingOnFailure reverts seat count and cleans up temporary booking. releaseNonce is
+// the one returned by the decrement, or nil to request a new one.
+func (bs *BookingServiceV2) revertBookingOnFailure(ctx context.Context, flightID, seats int, date, tempBookingKey string, releaseNonce *models.SeatNonce) {
 	// Increment seats back
-	if err := bs.incrementSeatsViaHTTP(ctx, flightID, seats, date); err != nil {
+	if err := bs.incrementSeatsViaHTTP(ctx, flightID, seats, date, models.SeatReasonHoldReverted, releaseNonce); err != nil {
 		log.Printf("Failed to revert seat count for flight %d: %v", flightID, err)
 	}
 
@@ -267,17 +473,65 @@ func (bs *BookingServiceV2) revertBookingOnFailure(ctx context.Context, flightID
 }
 
 // createPermanentBooking creates a permanent booking in the database
-func (bs *BookingServiceV2) createPermanentBooking(ctx context.Context, req *models.BookingRequest, totalAmount float64, paymentID string) (int, error) {
+// The booking row and the flight's terms at confirmation (snapshotted into booking_segments)
+// are written in one serializable transaction, retried when PostgreSQL aborts it.
+// The insert is an upsert on the booking's idempotency key: when the same booking was
+// already stored, its ID is returned with duplicate set and nothing is written. The tax
+// lines included in the total are stored with it for reporting, and so are its passengers,
+// which in reject mode are checked again for duplicates inside the transaction.
+func (bs *BookingServiceV2) createPermanentBooking(ctx context.Context, req *models.BookingRequest, totalAmount models.Money, paymentID string, flight *models.Flight, taxes []models.TaxLine) (bookingID int, duplicate bool, err error) {
+	idempotencyKey := bookingIdempotencyKey(req, paymentID)
 	query := `
-		INSERT INTO bookings (user_id, flight_id, seats, total_amount, status, payment_id, date)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO bookings (user_id, flight_id, seats, total_amount, status, payment_id, date, fare_code, email, phone, agency_id,
+		                      idempotency_key)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), NULLIF($10, ''), NULLIF($11, 0), $12)
+		ON CONFLICT (user_id, flight_id, date, idempotency_key) DO NOTHING
 		RETURNING id
 	`
 
-	var bookingID int
-	err := bs.db.QueryRowContext(ctx, query, req.UserID, req.FlightID, req.Seats, totalAmount, models.BookingStatusConfirmed, paymentID, req.Date).Scan(&bookingID)
+	var segments []models.BookingSegment
+	err = bs.db.RetryTransaction(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable}, func(tx *sql.Tx) error {
+		bookingID, duplicate, segments = 0, false, nil
+
+		err := tx.QueryRowContext(ctx, query, req.UserID, req.FlightID, req.Seats, totalAmount, models.BookingStatusConfirmed,
+			paymentID, req.Date, req.FareCode, req.Email, req.Phone, req.AgencyID, idempotencyKey).Scan(&bookingID)
+		if err == sql.ErrNoRows {
+			duplicate = true
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create booking: %w", err)
+		}
+
+		if flight != nil {
+			segment := models.NewBookingSegment(0, flight)
+			segment.BookingID = bookingID
+			if err := insertSegment(ctx, tx, &segment); err != nil {
+				return err
+			}
+			segments = append(segments, segment)
+		}
+		if err := insertTaxLines(ctx, tx, bookingID, paymentID, taxes); err != nil {
+			return err
+		}
+		if bs.duplicatePassengers == DuplicatePassengerReject {
+			if err := bs.checkDuplicatePassengers(ctx, tx, req); err != nil {
+				return err
+			}
+		}
+		return insertPassengers(ctx, tx, bookingID, req)
+	})
 	if err != nil {
-		return 0, fmt.Errorf("failed to create booking: %w", err)
+		return 0, false, err
+	}
+
+	if duplicate {
+		bookingID, err = bs.findIdempotentBooking(ctx, req, idempotencyKey)
+		if err != nil {
+			return 0, false, fmt.Errorf("failed to load conflicting booking: %w", err)
+		}
+		log.Printf("Booking for user %d, flight %d on %s already exists as %d", req.UserID, req.FlightID, req.Date, bookingID)
+		return bookingID, true, nil
 	}
 
 	// Cache the booking
@@ -290,7 +544,14 @@ func (bs *BookingServiceV2) createPermanentBooking(ctx context.Context, req *mod
 		Status:      models.BookingStatusConfirmed,
 		PaymentID:   paymentID,
 		Date:        req.Date,
+		FareCode:    req.FareCode,
+		Cabin:       models.CabinEconomy,
+		Email:       req.Email,
+		Phone:       req.Phone,
+		AgencyID:    req.AgencyID,
 		CreatedAt:   time.Now(),
+		Segments:    segments,
+		Taxes:       taxes,
 	}
 
 	cacheKey := database.GenerateBookingCacheKey(bookingID)
@@ -298,36 +559,126 @@ func (bs *BookingServiceV2) createPermanentBooking(ctx context.Context, req *mod
 		log.Printf("Failed to cache booking: %v", err)
 	}
 
+	bs.publishStatusChange(ctx, booking, "", "payment_succeeded")
+
+	return bookingID, false, nil
+}
+
+// bookingIdempotencyKey returns the key a booking is stored under: the client's key, or
+// else its payment ID, so a replayed insert of the same payment cannot store a second booking
+func bookingIdempotencyKey(req *models.BookingRequest, paymentID string) string {
+	if req.IdempotencyKey != "" {
+		return req.IdempotencyKey
+	}
+	return "payment:" + paymentID
+}
+
+// findIdempotentBooking returns the ID of the user's booking of a flight date stored under
+// an idempotency key
+func (bs *BookingServiceV2) findIdempotentBooking(ctx context.Context, req *models.BookingRequest, idempotencyKey string) (int, error) {
+	query := `
+		SELECT id FROM bookings
+		WHERE user_id = $1 AND flight_id = $2 AND date = $3 AND idempotency_key = $4
+	`
+
+	var bookingID int
+	err := bs.db.QueryRowContext(ctx, query, req.UserID, req.FlightID, req.Date, idempotencyKey).Scan(&bookingID)
+	if err == sql.ErrNoRows {
+		return 0, ErrBookingNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to query booking by idempotency key: %w", err)
+	}
 	return bookingID, nil
 }
 
-// processPayment processes payment through the payment service
-func (bs *BookingServiceV2) processPayment(ctx context.Context, req *models.PaymentRequest) (*models.PaymentResponse, error) {
-	jsonData, err := json.Marshal(req)
+// duplicateBookingResponse describes an already stored booking as the result of a repeated request
+func (bs *BookingServiceV2) duplicateBookingResponse(ctx context.Context, bookingID int) (*models.BookingResponse, error) {
+	booking, err := bs.GetBooking(ctx, bookingID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal payment request: %w", err)
+		return nil, fmt.Errorf("failed to get existing booking: %w", err)
 	}
 
-	url := fmt.Sprintf("%s/api/payments/process", bs.paymentServiceURL)
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	return &models.BookingResponse{
+		BookingID:   booking.ID,
+		Status:      booking.Status,
+		TotalAmount: booking.TotalAmount,
+		PaymentID:   booking.PaymentID,
+		Message:     "Booking already exists for this request",
+		Duplicate:   true,
+	}, nil
+}
+
+// insertSegment stores a booking segment snapshot as part of a transaction
+func insertSegment(ctx context.Context, tx *sql.Tx, segment *models.BookingSegment) error {
+	query := `
+		INSERT INTO booking_segments (booking_id, segment_index, flight_id, flight_number, source, destination,
+		                              departure_time, arrival_time, price)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	_, err := tx.ExecContext(ctx, query,
+		segment.BookingID, segment.SegmentIndex, segment.FlightID, segment.FlightNumber, segment.Source,
+		segment.Destination, segment.DepartureTime, segment.ArrivalTime, segment.Price,
+	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+		return fmt.Errorf("failed to store booking segment: %w", err)
 	}
+	return nil
+}
 
-	httpReq.Header.Set("Content-Type", "application/json")
+// getSegments loads the flight snapshots of a booking in itinerary order
+func (bs *BookingServiceV2) getSegments(ctx context.Context, bookingID int) ([]models.BookingSegment, error) {
+	query := `
+		SELECT booking_id, segment_index, flight_id, flight_number, source, destination,
+		       departure_time, arrival_time, price
+		FROM booking_segments
+		WHERE booking_id = $1
+		ORDER BY segment_index
+	`
 
-	resp, err := bs.httpClient.Do(httpReq)
+	rows, err := bs.db.QueryContext(ctx, query, bookingID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to make payment request: %w", err)
+		return nil, fmt.Errorf("failed to query booking segments: %w", err)
 	}
-	defer resp.Body.Close()
+	defer rows.Close()
 
-	var paymentResp models.PaymentResponse
-	if err := json.NewDecoder(resp.Body).Decode(&paymentResp); err != nil {
-		return nil, fmt.Errorf("failed to decode payment response: %w", err)
+	var segments []models.BookingSegment
+	for rows.Next() {
+		var segment models.BookingSegment
+		err := rows.Scan(
+			&segment.BookingID, &segment.SegmentIndex, &segment.FlightID, &segment.FlightNumber,
+			&segment.Source, &segment.Destination, &segment.DepartureTime, &segment.ArrivalTime, &segment.Price,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan booking segment: %w", err)
+		}
+		segments = append(segments, segment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read booking segments: %w", err)
 	}
 
-	return &paymentResp, nil
+	return segments, nil
+}
+
+// processPayment processes payment through the payment service
+func (bs *BookingServiceV2) processPayment(ctx context.Context, req *models.PaymentRequest) (*models.PaymentResponse, error) {
+	paymentResp, err := bs.payments.ProcessPayment(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process payment: %w", err)
+	}
+
+	return paymentResp, nil
+}
+
+// capturePayment captures a card payment's authorization once its booking is stored.
+// Failures are logged: the authorization is voided at its deadline and the booking
+// cancelled by the payment void reconciler.
+func (bs *BookingServiceV2) capturePayment(ctx context.Context, paymentID string, bookingID int) {
+	if _, err := bs.payments.Capture(ctx, paymentID); err != nil {
+		log.Printf("Failed to capture payment %s for booking %d: %v", paymentID, bookingID, err)
+	}
 }
 
 // GetBooking retrieves a booking by ID
@@ -339,61 +690,295 @@ func (bs *BookingServiceV2) GetBooking(ctx context.Context, bookingID int) (*mod
 		return &booking, nil
 	}
 
+	// Then the read model, unless it lags the write path
+	view, err := bs.readModel.Get(ctx, bookingID)
+	if err == nil {
+		return view, nil
+	}
+	if !errors.Is(err, ErrBookingNotFound) {
+		log.Printf("Failed to read booking %d from read model: %v", bookingID, err)
+	}
+
 	// Query from database
 	query := `
-		SELECT id, user_id, flight_id, seats, total_amount, status, payment_id, date, created_at
+		SELECT id, user_id, flight_id, seats, total_amount, status, payment_id, date, fare_code, cabin,
+		       COALESCE(email, ''), COALESCE(phone, ''), COALESCE(agency_id, 0), created_at
 		FROM bookings
 		WHERE id = $1
 	`
 
-	err := bs.db.QueryRowContext(ctx, query, bookingID).Scan(
+	err = bs.db.QueryRowContext(ctx, query, bookingID).Scan(
 		&booking.ID, &booking.UserID, &booking.FlightID, &booking.Seats, &booking.TotalAmount,
-		&booking.Status, &booking.PaymentID, &booking.Date, &booking.CreatedAt,
+		&booking.Status, &booking.PaymentID, &booking.Date, &booking.FareCode, &booking.Cabin,
+		&booking.Email, &booking.Phone, &booking.AgencyID, &booking.CreatedAt,
 	)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("booking not found")
+			return nil, ErrBookingNotFound
 		}
 		return nil, fmt.Errorf("failed to query booking: %w", err)
 	}
 
-	// Cache the result
-	if err := bs.cache.SetJSON(ctx, cacheKey, booking, 30*time.Minute); err != nil {
-		log.Printf("Failed to cache booking: %v", err)
+	segments, err := bs.getSegments(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	booking.Segments = segments
+
+	taxes, err := bs.getTaxLines(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	booking.Taxes = taxes
+
+	// Cache only active bookings; flown and closed ones are rarely read again
+	if booking.Status == models.BookingStatusPending || booking.Status == models.BookingStatusConfirmed {
+		if err := bs.cache.SetJSON(ctx, cacheKey, booking, 30*time.Minute); err != nil {
+			log.Printf("Failed to cache booking: %v", err)
+		}
 	}
 
 	return &booking, nil
 }
 
-// CancelBooking cancels a booking
-func (bs *BookingServiceV2) CancelBooking(ctx context.Context, bookingID int) error {
+// ListUserBookings returns a user's bookings, newest first, optionally filtered by status
+func (bs *BookingServiceV2) ListUserBookings(ctx context.Context, userID int, status string, limit, offset int) (*models.UserBookingsResponse, error) {
+	bookings, err := bs.readModel.List(ctx, BookingFilter{UserID: userID, Status: status, Limit: limit, Offset: offset})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user bookings: %w", err)
+	}
+
+	return &models.UserBookingsResponse{
+		UserID:   userID,
+		Bookings: bookings,
+		Count:    len(bookings),
+		Limit:    limit,
+		Offset:   offset,
+	}, nil
+}
+
+// AttachFlight populates booking.Flight with the current flight details from the Flight Service,
+// falling back to the booking's snapshot when the flight cannot be fetched
+func (bs *BookingServiceV2) AttachFlight(ctx context.Context, booking *models.Booking) {
+	flight, err := bs.getFlightViaHTTP(ctx, booking.FlightID)
+	if err == nil {
+		booking.Flight = flight
+		return
+	}
+	log.Printf("Failed to fetch flight %d for booking %d, using snapshot: %v", booking.FlightID, booking.ID, err)
+
+	if len(booking.Segments) > 0 {
+		segment := booking.Segments[0]
+		booking.Flight = &models.Flight{
+			ID:            segment.FlightID,
+			FlightNumber:  segment.FlightNumber,
+			Source:        segment.Source,
+			Destination:   segment.Destination,
+			DepartureTime: segment.DepartureTime,
+			ArrivalTime:   segment.ArrivalTime,
+			Price:         segment.Price,
+		}
+	}
+}
+
+// getFlightViaHTTP gets flight details via HTTP call to Flight Service, cached for 5 minutes
+func (bs *BookingServiceV2) getFlightViaHTTP(ctx context.Context, flightID int) (*models.Flight, error) {
+	cacheKey := database.GenerateFlightCacheKey(flightID)
+	var flight models.Flight
+	if err := bs.cache.GetJSON(ctx, cacheKey, &flight); err == nil {
+		return &flight, nil
+	}
+
+	fetched, err := bs.flights.GetFlight(ctx, flightID)
+	if errors.Is(err, client.ErrNotFound) {
+		return nil, ErrFlightNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get flight: %w", err)
+	}
+
+	if err := bs.cache.SetJSON(ctx, cacheKey, fetched, 5*time.Minute); err != nil {
+		log.Printf("Failed to cache flight: %v", err)
+	}
+
+	return fetched, nil
+}
+
+// departureTime returns when a booking's flight departs, preferring the Flight Service's
+// zone-aware time over the snapshot and the booking date
+func (bs *BookingServiceV2) departureTime(ctx context.Context, booking *models.Booking) (time.Time, error) {
+	if flight, err := bs.getFlightViaHTTP(ctx, booking.FlightID); err == nil {
+		return flight.DepartureTime, nil
+	}
+	if len(booking.Segments) > 0 {
+		return booking.Segments[0].DepartureTime, nil
+	}
+	departure, err := time.Parse("2006-01-02", booking.Date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid booking date %q: %w", booking.Date, err)
+	}
+	return departure, nil
+}
+
+// CancelBooking cancels seats of a booking, charging the fee from its fare's cancellation policy.
+// A seats count below the booked total releases only those seats and keeps the booking
+// confirmed with the reduced seat count and amount; zero cancels the whole booking.
+func (bs *BookingServiceV2) CancelBooking(ctx context.Context, bookingID, seats int) (*models.CancellationResponse, error) {
 	// Get booking first
 	booking, err := bs.GetBooking(ctx, bookingID)
 	if err != nil {
-		return fmt.Errorf("failed to get booking: %w", err)
+		return nil, fmt.Errorf("failed to get booking: %w", err)
 	}
 
-	if !booking.CanCancel() {
-		return fmt.Errorf("booking cannot be cancelled in current status: %s", booking.Status)
+	departure, err := bs.departureTime(ctx, booking)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if !booking.CanCancelAt(departure, now) {
+		if err := models.CheckTransition(booking.Status, models.BookingStatusCancelled); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("booking cannot be cancelled after departure")
 	}
 
-	// Update booking status
-	query := `UPDATE bookings SET status = $1 WHERE id = $2`
-	_, err = bs.db.ExecContext(ctx, query, models.BookingStatusCancelled, bookingID)
+	fareCode := booking.FareCode
+	if fareCode == "" {
+		fareCode = models.DefaultFareCode
+	}
+	policy, err := bs.policies.GetPolicy(ctx, fareCode)
 	if err != nil {
-		return fmt.Errorf("failed to update booking status: %w", err)
+		return nil, fmt.Errorf("failed to load cancellation policy: %w", err)
+	}
+
+	if seats > booking.Seats {
+		return nil, fmt.Errorf("cannot cancel %d seats, booking has %d", seats, booking.Seats)
+	}
+	partial := seats > 0 && seats < booking.Seats
+	if !partial {
+		seats = booking.Seats
+	}
+
+	// Refund only the cancelled seats' share of the amount
+	amount := booking.TotalAmount
+	if partial {
+		amount = booking.TotalAmount.Split(seats, booking.Seats)
+	}
+	quote := policy.Evaluate(amount, departure.Sub(now).Hours())
+
+	if partial {
+		// Guard on the current seat count so concurrent cancellations can't both apply
+		query := `
+			UPDATE bookings
+			SET seats = seats - $1, total_amount = total_amount - $2,
+			    refund_amount = COALESCE(refund_amount, 0) + $3, cancellation_fee = COALESCE(cancellation_fee, 0) + $4
+			WHERE id = $5 AND seats = $6 AND status = $7
+		`
+		result, err := bs.db.ExecContext(ctx, query, seats, amount, quote.RefundAmount, quote.CancellationFee,
+			bookingID, booking.Seats, booking.Status)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update booking seats: %w", err)
+		}
+		if updated, err := result.RowsAffected(); err == nil && updated == 0 {
+			return nil, fmt.Errorf("booking was modified concurrently, please retry")
+		}
+		remaining := *booking
+		remaining.Seats -= seats
+		bs.publishBookingEvent(ctx, models.EventBookingUpdated, &remaining, booking.Status, "seats_cancelled")
+	} else {
+		err := bs.transitionBooking(ctx, booking, models.BookingStatusCancelled, "cancelled",
+			", refund_amount = COALESCE(refund_amount, 0) + $4, cancellation_fee = COALESCE(cancellation_fee, 0) + $5",
+			quote.RefundAmount, quote.CancellationFee)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// Increment seats back in Flight Service using the actual flight date
-	if err := bs.incrementSeatsViaHTTP(ctx, booking.FlightID, booking.Seats, booking.Date); err != nil {
+	// Agency bookings are refunded to the agency's credit
+	if booking.AgencyID > 0 {
+		if err := bs.agencies.Credit(ctx, booking.AgencyID, booking.PaymentID, quote.RefundAmount, "cancellation"); err != nil {
+			log.Printf("Failed to credit agency refund for booking %d: %v", bookingID, err)
+		}
+	}
+
+	// Increment seats back in Flight Service using the actual flight date, to the booking's cabin
+	if err := bs.releaseBookingSeats(ctx, booking, seats, models.SeatReasonBookingCancelled); err != nil {
 		log.Printf("Failed to increment seats on cancellation: %v", err)
 		// Don't return error here as the booking is already cancelled in database
 	}
 
+	// Publish correction event for load-factor tracking. Partial cancellations are keyed by
+	// the remaining seat count, which is unique per booking because it only decreases.
+	if partial {
+		eventID := fmt.Sprintf("remaining-%d", booking.Seats-seats)
+		bs.publishOccupancyEvent(ctx, bookingID, booking.FlightID, -seats, booking.Date, models.OccupancyReasonSeatsCancelled, eventID)
+	} else {
+		bs.publishOccupancyEvent(ctx, bookingID, booking.FlightID, -seats, booking.Date, models.OccupancyReasonBookingCancelled, "")
+	}
+
 	// Remove from cache
 	cacheKey := database.GenerateBookingCacheKey(bookingID)
 	bs.cache.Delete(ctx, cacheKey)
 
-	return nil
+	response := &models.CancellationResponse{
+		Message:           "Booking cancelled successfully",
+		BookingID:         bookingID,
+		Status:            models.BookingStatusCancelled,
+		SeatsCancelled:    seats,
+		RemainingSeats:    booking.Seats - seats,
+		CancelledAt:       now,
+		CancellationQuote: quote,
+	}
+	if partial {
+		response.Message = "Seats cancelled successfully"
+		response.Status = booking.Status
+	}
+
+	// Travelling companions on linked bookings are not cancelled, only pointed out
+	linked, err := bs.activeLinkedBookings(ctx, bookingID)
+	if err != nil {
+		log.Printf("Failed to load bookings linked to %d: %v", bookingID, err)
+	}
+	response.LinkedBookings = linked
+
+	log.Printf("Booking %d: %d of %d seats cancelled under fare %s: fee=%s refund=%s",
+		bookingID, seats, booking.Seats, fareCode, quote.CancellationFee, quote.RefundAmount)
+	return response, nil
+}
+
+// publishOccupancyEvent posts a seat occupancy change to the Flight Service.
+// Failures are logged only; the booking outcome does not depend on load-factor tracking.
+func (bs *BookingServiceV2) publishOccupancyEvent(ctx context.Context, bookingID, flightID, delta int, date, reason, eventID string) {
+	event := models.SeatOccupancyEvent{
+		EventID:    eventID,
+		FlightID:   flightID,
+		Date:       date,
+		BookingID:  bookingID,
+		Delta:      delta,
+		Reason:     reason,
+		OccurredAt: time.Now(),
+	}
+
+	if err := bs.flights.RecordOccupancyEvent(ctx, &event); err != nil {
+		log.Printf("Failed to publish occupancy event for booking %d: %v", bookingID, err)
+	}
+}
+
+// GetConfirmedSeats returns the total seats held by confirmed (or already flown) bookings for a flight date
+func (bs *BookingServiceV2) GetConfirmedSeats(ctx context.Context, flightID int, date string) (int, error) {
+	query := `
+		SELECT COALESCE(SUM(seats), 0)
+		FROM bookings
+		WHERE flight_id = $1 AND date = $2 AND status IN ($3, $4)
+	`
+
+	var seats int
+	err := bs.db.QueryRowContext(ctx, query, flightID, date, models.BookingStatusConfirmed, models.BookingStatusCompleted).Scan(&seats)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query confirmed seats: %w", err)
+	}
+
+	return seats, nil
 }
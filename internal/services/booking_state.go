@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/internal/events"
+	"cred_flights_booking/pkg/models"
+)
+
+// transitionBooking moves a stored booking to a new status through the booking state machine.
+// The update is guarded on the booking's current status so concurrent changes cannot both
+// apply. set appends assignments to the UPDATE; its placeholders start at $4 and bind args.
+// On success the cached booking is dropped and a status change event is published.
+func (bs *BookingServiceV2) transitionBooking(ctx context.Context, booking *models.Booking, to, reason, set string, args ...interface{}) error {
+	if err := models.CheckTransition(booking.Status, to); err != nil {
+		return err
+	}
+
+	query := "UPDATE bookings SET status = $1" + set + " WHERE id = $2 AND status = $3"
+	result, err := bs.db.ExecContext(ctx, query, append([]interface{}{to, booking.ID, booking.Status}, args...)...)
+	if err != nil {
+		return fmt.Errorf("failed to update booking status: %w", err)
+	}
+	if updated, err := result.RowsAffected(); err == nil && updated == 0 {
+		// The status changed since the booking was read (or the cached copy was stale)
+		var current string
+		err := bs.db.QueryRowContext(ctx, "SELECT status FROM bookings WHERE id = $1", booking.ID).Scan(&current)
+		if err != nil || models.CanTransition(current, to) {
+			return fmt.Errorf("booking was modified concurrently, please retry")
+		}
+		return &models.TransitionError{From: current, To: to}
+	}
+
+	from := booking.Status
+	booking.Status = to
+	bs.cache.Delete(ctx, database.GenerateBookingCacheKey(booking.ID))
+	bs.publishStatusChange(ctx, booking, from, reason)
+
+	log.Printf("Booking %d: %s -> %s (%s)", booking.ID, from, to, reason)
+	return nil
+}
+
+// publishStatusChange publishes a booking status change event. Failures are logged, not returned.
+func (bs *BookingServiceV2) publishStatusChange(ctx context.Context, booking *models.Booking, from, reason string) {
+	bs.publishBookingEvent(ctx, models.EventBookingStatusChanged, booking, from, reason)
+}
+
+// publishBookingEvent publishes a booking event and records it for the read model's
+// staleness check. Failures are logged, not returned.
+func (bs *BookingServiceV2) publishBookingEvent(ctx context.Context, eventType string, booking *models.Booking, from, reason string) {
+	if bs.events == nil {
+		return
+	}
+
+	payload := models.BookingStatusEvent{
+		BookingID: booking.ID,
+		UserID:    booking.UserID,
+		FlightID:  booking.FlightID,
+		Date:      booking.Date,
+		Seats:     booking.Seats,
+		From:      from,
+		To:        booking.Status,
+		Reason:    reason,
+	}
+	publishedAt := time.Now()
+	if err := bs.events.Publish(ctx, events.StreamBookings, eventType, payload); err != nil {
+		log.Printf("Failed to publish %s for booking %d: %v", eventType, booking.ID, err)
+		// No event will reach the projector, so refresh the read model directly
+		if err := bs.readModel.Refresh(ctx, booking.ID); err != nil {
+			log.Printf("Failed to refresh read model for booking %d: %v", booking.ID, err)
+		}
+		return
+	}
+	bs.readModel.MarkPublished(ctx, publishedAt)
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/pkg/models"
+)
+
+// insertTaxLines stores the tax lines charged with a booking's payment as part of a transaction
+func insertTaxLines(ctx context.Context, tx *sql.Tx, bookingID int, paymentID string, taxes []models.TaxLine) error {
+	query := `
+		INSERT INTO booking_tax_lines (booking_id, line_index, payment_id, jurisdiction, code, rate, taxable_amount, amount)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8)
+	`
+
+	for i, line := range taxes {
+		_, err := tx.ExecContext(ctx, query, bookingID, i, paymentID, line.Jurisdiction, line.Code, line.Rate,
+			line.Taxable, line.Amount)
+		if err != nil {
+			return fmt.Errorf("failed to store booking tax line: %w", err)
+		}
+	}
+	return nil
+}
+
+// appendTaxLines stores further tax lines on a booking, e.g. for an upgrade, after its
+// existing ones as part of a transaction
+func appendTaxLines(ctx context.Context, tx *sql.Tx, bookingID int, paymentID string, taxes []models.TaxLine) error {
+	if len(taxes) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO booking_tax_lines (booking_id, line_index, payment_id, jurisdiction, code, rate, taxable_amount, amount)
+		SELECT $1, COALESCE(MAX(line_index), -1) + 1, NULLIF($2, ''), $3, $4, $5, $6, $7
+		FROM booking_tax_lines
+		WHERE booking_id = $1
+	`
+
+	for _, line := range taxes {
+		_, err := tx.ExecContext(ctx, query, bookingID, paymentID, line.Jurisdiction, line.Code, line.Rate,
+			line.Taxable, line.Amount)
+		if err != nil {
+			return fmt.Errorf("failed to store booking tax line: %w", err)
+		}
+	}
+	return nil
+}
+
+// getTaxLines loads the tax lines of a booking in receipt order
+func (bs *BookingServiceV2) getTaxLines(ctx context.Context, bookingID int) ([]models.TaxLine, error) {
+	query := `
+		SELECT jurisdiction, code, rate, taxable_amount, amount
+		FROM booking_tax_lines
+		WHERE booking_id = $1
+		ORDER BY line_index
+	`
+
+	rows, err := bs.db.QueryContext(ctx, query, bookingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query booking tax lines: %w", err)
+	}
+	defer rows.Close()
+
+	var taxes []models.TaxLine
+	for rows.Next() {
+		var line models.TaxLine
+		if err := rows.Scan(&line.Jurisdiction, &line.Code, &line.Rate, &line.Taxable, &line.Amount); err != nil {
+			return nil, fmt.Errorf("failed to scan booking tax line: %w", err)
+		}
+		taxes = append(taxes, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read booking tax lines: %w", err)
+	}
+
+	return taxes, nil
+}
+
+// TaxReportService totals the taxes charged on bookings for filing and settlement
+type TaxReportService struct {
+	db *database.DB
+}
+
+// NewTaxReportService creates a new tax report service
+func NewTaxReportService(db *database.DB) *TaxReportService {
+	return &TaxReportService{db: db}
+}
+
+// Report totals tax lines by jurisdiction, code, and rate for bookings created on UTC days
+// in [from, to]. Taxes are reported as charged; refunds of cancelled bookings are not netted.
+func (ts *TaxReportService) Report(ctx context.Context, from, to string) (*models.TaxReportResponse, error) {
+	query := `
+		SELECT jurisdiction, code, rate, COUNT(DISTINCT booking_id), SUM(taxable_amount), SUM(amount)
+		FROM booking_tax_lines
+		WHERE created_at >= $1::date AND created_at < $2::date + 1
+		GROUP BY jurisdiction, code, rate
+		ORDER BY jurisdiction, code, rate
+	`
+
+	rows, err := ts.db.QueryContext(ctx, query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tax lines: %w", err)
+	}
+	defer rows.Close()
+
+	response := &models.TaxReportResponse{
+		From:  from,
+		To:    to,
+		Lines: []models.TaxReportLine{},
+		Total: models.NewMoney(0),
+	}
+	for rows.Next() {
+		var line models.TaxReportLine
+		err := rows.Scan(&line.Jurisdiction, &line.Code, &line.Rate, &line.Bookings, &line.Taxable, &line.Amount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tax report line: %w", err)
+		}
+		response.Lines = append(response.Lines, line)
+		response.Total = response.Total.Add(line.Amount)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tax lines: %w", err)
+	}
+
+	return response, nil
+}
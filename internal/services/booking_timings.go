@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"cred_flights_booking/internal/tracing"
+	"cred_flights_booking/pkg/models"
+)
+
+// Booking flow step names
+const (
+	stepPolicy    = "policy"
+	stepValidate  = "validate"
+	stepHold      = "hold"
+	stepDecrement = "decrement"
+	stepPayment   = "payment"
+	stepPersist   = "persist"
+	stepPublish   = "publish"
+	stepRevert    = "revert"
+)
+
+// stepTimer records how long each step of a booking takes. Steps may overlap.
+type stepTimer struct {
+	mu    sync.Mutex
+	start time.Time
+	steps []models.StepTiming
+}
+
+// newStepTimer starts timing a booking request
+func newStepTimer() *stepTimer {
+	return &stepTimer{start: time.Now()}
+}
+
+// step starts timing a step and returns a function that ends it
+func (st *stepTimer) step(name string) func() {
+	begin := time.Now()
+	return func() {
+		elapsed := time.Since(begin)
+		st.mu.Lock()
+		defer st.mu.Unlock()
+		st.steps = append(st.steps, models.StepTiming{
+			Step:       name,
+			StartMs:    milliseconds(begin.Sub(st.start)),
+			DurationMs: milliseconds(elapsed),
+		})
+	}
+}
+
+// finish logs the breakdown and attaches it to the response
+func (st *stepTimer) finish(ctx context.Context, response *models.BookingResponse) {
+	st.mu.Lock()
+	steps := append([]models.StepTiming(nil), st.steps...)
+	st.mu.Unlock()
+
+	parts := make([]string, 0, len(steps))
+	for _, s := range steps {
+		parts = append(parts, fmt.Sprintf("%s=%.1f", s.Step, s.DurationMs))
+	}
+
+	bookingID, status := 0, "error"
+	if response != nil {
+		bookingID, status = response.BookingID, response.Status
+		response.DebugTimings = steps
+	}
+	log.Printf("BOOKING_TIMINGS booking_id=%d status=%s total_ms=%.1f steps_ms=%q trace_id=%s",
+		bookingID, status, milliseconds(time.Since(st.start)), strings.Join(parts, ","), tracing.TraceID(ctx))
+}
+
+// milliseconds converts a duration to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/pkg/client"
+	"cred_flights_booking/pkg/models"
+	"github.com/google/uuid"
+)
+
+// Cabin upgrade errors
+var (
+	// ErrUpgradeNotAllowed is returned for bookings that are not confirmed or have departed
+	ErrUpgradeNotAllowed = errors.New("only confirmed bookings can be upgraded before departure")
+	// ErrOfferNotFound is returned when an offer does not exist, has expired or been used,
+	// or belongs to another booking
+	ErrOfferNotFound = errors.New("upgrade offer not found")
+	// ErrOfferStale is returned when the booking changed since the offer was made
+	ErrOfferStale = errors.New("booking changed since the offer was made, request new offers")
+	// ErrUpgradeUnavailable is returned when the cabin sold out before the offer was accepted
+	ErrUpgradeUnavailable = errors.New("upgrade cabin no longer has enough seats")
+	// ErrUpgradePaymentFailed is returned when the upgrade's payment did not succeed
+	ErrUpgradePaymentFailed = errors.New("upgrade payment failed")
+)
+
+// upgradeEligible returns ErrUpgradeNotAllowed unless a booking is confirmed and its flight
+// has not departed
+func (bs *BookingServiceV2) upgradeEligible(ctx context.Context, booking *models.Booking) error {
+	if booking.Status != models.BookingStatusConfirmed {
+		return ErrUpgradeNotAllowed
+	}
+	departure, err := bs.departureTime(ctx, booking)
+	if err != nil {
+		return err
+	}
+	if !time.Now().Before(departure) {
+		return ErrUpgradeNotAllowed
+	}
+	return nil
+}
+
+// ListUpgradeOffers prices moving a confirmed booking's seats to each higher cabin with room
+// for them. Each offer is cached until UPGRADE_OFFER_TTL or its fare quote expires.
+func (bs *BookingServiceV2) ListUpgradeOffers(ctx context.Context, bookingID int) (*models.UpgradeOffersResponse, error) {
+	booking, err := bs.GetBooking(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if err := bs.upgradeEligible(ctx, booking); err != nil {
+		return nil, err
+	}
+
+	cabin := booking.Cabin
+	if cabin == "" {
+		cabin = models.CabinEconomy
+	}
+	quotes, err := bs.flights.QuoteUpgrades(ctx, &models.UpgradeQuoteRequest{
+		FlightID:  booking.FlightID,
+		Date:      booking.Date,
+		Seats:     booking.Seats,
+		UserID:    booking.UserID,
+		FromCabin: cabin,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to quote upgrades: %w", err)
+	}
+
+	response := &models.UpgradeOffersResponse{
+		BookingID: bookingID,
+		Cabin:     cabin,
+		Offers:    []models.UpgradeOffer{},
+	}
+	now := time.Now()
+	for _, quote := range quotes.Quotes {
+		offer := models.UpgradeOffer{
+			OfferID:        uuid.New().String(),
+			BookingID:      bookingID,
+			UserID:         booking.UserID,
+			FlightID:       booking.FlightID,
+			Date:           booking.Date,
+			FromCabin:      cabin,
+			ToCabin:        quote.Cabin,
+			Seats:          booking.Seats,
+			AvailableSeats: quote.AvailableSeats,
+			Price:          quote.Price,
+			Taxes:          quote.Taxes,
+			Quote:          quote.Quote,
+			ExpiresAt:      now.Add(bs.upgradeOfferTTL),
+		}
+		if quote.Quote != nil && quote.Quote.ExpiresAt.Before(offer.ExpiresAt) {
+			offer.ExpiresAt = quote.Quote.ExpiresAt
+		}
+
+		key := database.GenerateUpgradeOfferCacheKey(offer.OfferID)
+		if err := bs.cache.SetJSON(ctx, key, &offer, time.Until(offer.ExpiresAt)); err != nil {
+			return nil, fmt.Errorf("failed to store upgrade offer: %w", err)
+		}
+		response.Offers = append(response.Offers, offer)
+	}
+
+	return response, nil
+}
+
+// AcceptUpgradeOffer charges an offer's price and moves the booking's seats to the offered
+// cabin. The booking row stays locked while the Flight Service moves the seats, so the
+// booking and both cabins' inventories change together or not at all; the payment is
+// reversed when they don't. An offer can be accepted once.
+func (bs *BookingServiceV2) AcceptUpgradeOffer(ctx context.Context, bookingID int, offerID string) (*models.UpgradeResponse, error) {
+	key := database.GenerateUpgradeOfferCacheKey(offerID)
+	var offer models.UpgradeOffer
+	if err := bs.cache.GetJSON(ctx, key, &offer); err != nil {
+		if exists, existsErr := bs.cache.KeyExists(ctx, key); existsErr == nil && !exists {
+			return nil, ErrOfferNotFound
+		}
+		return nil, fmt.Errorf("failed to load upgrade offer: %w", err)
+	}
+	if offer.BookingID != bookingID {
+		return nil, ErrOfferNotFound
+	}
+
+	// Claim the offer so concurrent acceptances can't both charge
+	claimed, err := bs.cache.Del(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim upgrade offer: %w", err)
+	}
+	if claimed == 0 {
+		return nil, ErrOfferNotFound
+	}
+
+	booking, err := bs.GetBooking(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if err := bs.upgradeEligible(ctx, booking); err != nil {
+		return nil, err
+	}
+	cabin := booking.Cabin
+	if cabin == "" {
+		cabin = models.CabinEconomy
+	}
+	if cabin != offer.FromCabin || booking.Seats != offer.Seats {
+		return nil, ErrOfferStale
+	}
+
+	// Charge the fare difference the same way the booking was paid
+	var paymentResp *models.PaymentResponse
+	if booking.AgencyID > 0 {
+		paymentResp, err = bs.chargeAgency(ctx, booking.AgencyID, offer.Price)
+	} else {
+		paymentResp, err = bs.processPayment(ctx, &models.PaymentRequest{
+			BookingID:   bookingID,
+			Amount:      offer.Price,
+			UserID:      booking.UserID,
+			PaymentType: "credit_card",
+			Quote:       offer.Quote,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUpgradePaymentFailed, err)
+	}
+	if paymentResp.Status != models.PaymentStatusSuccess {
+		return nil, fmt.Errorf("%w: %s", ErrUpgradePaymentFailed, paymentResp.Message)
+	}
+	if paymentResp.Amount.Minor != offer.Price.Minor {
+		mismatch := &models.AmountMismatchError{Expected: offer.Price, Actual: paymentResp.Amount}
+		bs.reverseUpgradePayment(ctx, booking, paymentResp, "amount_mismatch", mismatch)
+		return nil, mismatch
+	}
+
+	update := `
+		UPDATE bookings
+		SET cabin = $1, total_amount = total_amount + $2
+		WHERE id = $3 AND status = $4 AND cabin = $5 AND seats = $6
+		RETURNING total_amount
+	`
+	record := `
+		INSERT INTO booking_upgrades (booking_id, offer_id, from_cabin, to_cabin, seats, amount, payment_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	var total models.Money
+	err = bs.db.Transaction(func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, update, offer.ToCabin, offer.Price, bookingID, models.BookingStatusConfirmed,
+			offer.FromCabin, offer.Seats).Scan(&total)
+		if err == sql.ErrNoRows {
+			return ErrOfferStale
+		}
+		if err != nil {
+			return fmt.Errorf("failed to upgrade booking: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, record, bookingID, offerID, offer.FromCabin, offer.ToCabin, offer.Seats,
+			offer.Price, paymentResp.PaymentID); err != nil {
+			return fmt.Errorf("failed to record upgrade: %w", err)
+		}
+		if err := appendTaxLines(ctx, tx, bookingID, paymentResp.PaymentID, offer.Taxes); err != nil {
+			return err
+		}
+
+		// Seats move last, so any earlier failure leaves the inventories untouched
+		err = bs.flights.MoveCabinSeats(ctx, &models.CabinMoveRequest{
+			FlightID:  booking.FlightID,
+			Date:      booking.Date,
+			Seats:     offer.Seats,
+			FromCabin: offer.FromCabin,
+			ToCabin:   offer.ToCabin,
+		})
+		if errors.Is(err, client.ErrConflict) {
+			return ErrUpgradeUnavailable
+		}
+		if err != nil {
+			return fmt.Errorf("failed to move seats: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		bs.reverseUpgradePayment(ctx, booking, paymentResp, "upgrade_failed", err)
+		return nil, err
+	}
+
+	if booking.AgencyID == 0 {
+		bs.capturePayment(ctx, paymentResp.PaymentID, bookingID)
+	}
+
+	// Cached and projected copies still show the old cabin
+	if err := bs.cache.Delete(ctx, database.GenerateBookingCacheKey(bookingID)); err != nil {
+		log.Printf("Failed to invalidate booking %d after upgrade: %v", bookingID, err)
+	}
+	upgraded := *booking
+	upgraded.Cabin = offer.ToCabin
+	upgraded.TotalAmount = total
+	bs.publishBookingEvent(ctx, models.EventBookingUpdated, &upgraded, booking.Status, "cabin_upgraded")
+
+	log.Printf("Booking %d upgraded from %s to %s for %s (payment %s)",
+		bookingID, offer.FromCabin, offer.ToCabin, offer.Price, paymentResp.PaymentID)
+
+	return &models.UpgradeResponse{
+		BookingID:   bookingID,
+		OfferID:     offerID,
+		FromCabin:   offer.FromCabin,
+		ToCabin:     offer.ToCabin,
+		AmountPaid:  offer.Price,
+		TotalAmount: total,
+		PaymentID:   paymentResp.PaymentID,
+		UpgradedAt:  time.Now(),
+	}, nil
+}
+
+// reverseUpgradePayment returns an upgrade charge whose upgrade did not go through: to the
+// agency's credit, or left uncaptured so the card payment's authorization is voided
+func (bs *BookingServiceV2) reverseUpgradePayment(ctx context.Context, booking *models.Booking, payment *models.PaymentResponse, reason string, cause error) {
+	if booking.AgencyID > 0 {
+		if err := bs.agencies.Credit(ctx, booking.AgencyID, payment.PaymentID, payment.Amount, reason); err != nil {
+			log.Printf("Failed to reverse agency upgrade charge %s: %v", payment.PaymentID, err)
+		}
+		return
+	}
+	log.Printf("Upgrade payment %s for booking %d rejected (%v) and left uncaptured to be voided", payment.PaymentID, booking.ID, cause)
+}
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"cred_flights_booking/pkg/models"
+)
+
+// Cabin inventory errors
+var (
+	// ErrUnknownCabin is returned for a cabin name outside models.CabinRank
+	ErrUnknownCabin = errors.New("unknown cabin")
+	// ErrCabinNotFound is returned when a flight date does not sell the cabin
+	ErrCabinNotFound = errors.New("cabin not sold on this flight date")
+	// ErrCabinSoldOut is returned when a cabin has fewer free seats than requested
+	ErrCabinSoldOut = errors.New("not enough seats available in cabin")
+	// ErrInvalidCabinMove is returned for a move that is not to a higher cabin
+	ErrInvalidCabinMove = errors.New("seats can only move to a higher cabin")
+)
+
+// ListCabins returns a flight date's cabins: economy from the seat counter at the flight's
+// fare, then each premium cabin in cabin_inventory
+func (fs *FlightService) ListCabins(ctx context.Context, flightID int, date string) (*models.CabinsResponse, error) {
+	flight, err := fs.GetFlight(ctx, flightID)
+	if err != nil {
+		return nil, err
+	}
+	available, err := fs.getAvailableSeats(ctx, flightID, date)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get available seats: %w", err)
+	}
+
+	response := &models.CabinsResponse{
+		FlightID: flightID,
+		Date:     date,
+		Cabins: []models.CabinInventory{{
+			Cabin:          models.CabinEconomy,
+			TotalSeats:     flight.TotalSeats,
+			BookedSeats:    max(flight.TotalSeats-available, 0),
+			AvailableSeats: available,
+			Price:          flight.Price,
+		}},
+	}
+
+	query := `
+		SELECT cabin, total_seats, booked_seats, price, updated_at
+		FROM cabin_inventory
+		WHERE flight_id = $1 AND date = $2
+	`
+
+	rows, err := fs.db.QueryContext(ctx, query, flightID, date)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cabin inventory: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cabin models.CabinInventory
+		if err := rows.Scan(&cabin.Cabin, &cabin.TotalSeats, &cabin.BookedSeats, &cabin.Price, &cabin.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan cabin inventory: %w", err)
+		}
+		cabin.AvailableSeats = cabin.TotalSeats - cabin.BookedSeats
+		response.Cabins = append(response.Cabins, cabin)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cabin inventory: %w", err)
+	}
+
+	// Economy first, then by rank
+	sort.SliceStable(response.Cabins, func(i, j int) bool {
+		a, _ := models.CabinRank(response.Cabins[i].Cabin)
+		b, _ := models.CabinRank(response.Cabins[j].Cabin)
+		return a < b
+	})
+	return response, nil
+}
+
+// SetCabin creates or resizes a premium cabin on a flight date. Capacity cannot drop below
+// the seats already booked in it.
+func (fs *FlightService) SetCabin(ctx context.Context, flightID int, date, cabin string, req *models.CabinInventoryRequest, updatedBy string) (*models.CabinInventory, error) {
+	if rank, ok := models.CabinRank(cabin); !ok || rank == 0 {
+		return nil, ErrUnknownCabin
+	}
+
+	query := `
+		INSERT INTO cabin_inventory (flight_id, date, cabin, total_seats, price)
+		SELECT id, $2, $3, $4, $5
+		FROM flights
+		WHERE id = $1 AND DATE(departure_time) = $2
+		ON CONFLICT (flight_id, date, cabin) DO UPDATE
+		SET total_seats = EXCLUDED.total_seats, price = EXCLUDED.price, updated_at = CURRENT_TIMESTAMP
+		WHERE cabin_inventory.booked_seats <= EXCLUDED.total_seats
+		RETURNING cabin, total_seats, booked_seats, price, updated_at
+	`
+
+	var inventory models.CabinInventory
+	err := fs.db.QueryRowContext(ctx, query, flightID, date, cabin, req.TotalSeats, req.Price).Scan(
+		&inventory.Cabin, &inventory.TotalSeats, &inventory.BookedSeats, &inventory.Price, &inventory.UpdatedAt)
+	if err == sql.ErrNoRows {
+		// Either the flight does not depart that day, or the cabin is booked past the new capacity
+		if _, err := fs.GetFlight(ctx, flightID); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: capacity below booked seats or flight not on %s", ErrCabinSoldOut, date)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update cabin inventory: %w", err)
+	}
+	inventory.AvailableSeats = inventory.TotalSeats - inventory.BookedSeats
+
+	log.Printf("AUDIT: cabin %s on flight %d (%s) set to %d seats at %s by %s",
+		cabin, flightID, date, inventory.TotalSeats, inventory.Price, updatedBy)
+	return &inventory, nil
+}
+
+// QuoteUpgrades prices moving seats from a cabin to each higher cabin with enough free seats.
+// Each quote is the fare difference at current fares plus the route's taxes on it, signed
+// for the user's payment.
+func (fs *FlightService) QuoteUpgrades(ctx context.Context, req *models.UpgradeQuoteRequest) (*models.UpgradeQuotesResponse, error) {
+	fromRank, ok := models.CabinRank(req.FromCabin)
+	if !ok {
+		return nil, ErrUnknownCabin
+	}
+	if req.FromCabin == "" {
+		req.FromCabin = models.CabinEconomy
+	}
+
+	flight, err := fs.GetFlight(ctx, req.FlightID)
+	if err != nil {
+		return nil, err
+	}
+	cabins, err := fs.ListCabins(ctx, req.FlightID, req.Date)
+	if err != nil {
+		return nil, err
+	}
+
+	var fromPrice *models.Money
+	for _, cabin := range cabins.Cabins {
+		if cabin.Cabin == req.FromCabin {
+			fromPrice = &cabin.Price
+			break
+		}
+	}
+	if fromPrice == nil {
+		return nil, ErrCabinNotFound
+	}
+
+	response := &models.UpgradeQuotesResponse{
+		FlightID:  req.FlightID,
+		Date:      req.Date,
+		FromCabin: req.FromCabin,
+		Quotes:    []models.UpgradeQuote{},
+	}
+	jurisdiction := fs.routeJurisdiction(ctx, flight.Source, flight.Destination)
+	for _, cabin := range cabins.Cabins {
+		rank, _ := models.CabinRank(cabin.Cabin)
+		if rank <= fromRank || cabin.AvailableSeats < req.Seats || cabin.Price.Minor <= fromPrice.Minor {
+			continue
+		}
+
+		baseFare := cabin.Price.Sub(*fromPrice).Mul(req.Seats)
+		taxes, total := fs.taxes.Apply(baseFare, jurisdiction)
+		response.Quotes = append(response.Quotes, models.UpgradeQuote{
+			Cabin:          cabin.Cabin,
+			AvailableSeats: cabin.AvailableSeats,
+			Price:          total,
+			BaseFare:       baseFare,
+			Taxes:          taxes,
+			Quote:          fs.quotes.Sign(req.FlightID, req.Date, req.Seats, req.UserID, total),
+		})
+	}
+
+	return response, nil
+}
+
+// MoveCabinSeats moves booked seats to a higher cabin. The premium seats are claimed and
+// premium seats moved out of released in one transaction; economy seats are returned to
+// the seat counter after it commits, and the claim is undone if that fails.
+func (fs *FlightService) MoveCabinSeats(ctx context.Context, req *models.CabinMoveRequest) error {
+	if req.FromCabin == "" {
+		req.FromCabin = models.CabinEconomy
+	}
+	fromRank, ok := models.CabinRank(req.FromCabin)
+	toRank, toOK := models.CabinRank(req.ToCabin)
+	if !ok || !toOK {
+		return ErrUnknownCabin
+	}
+	if toRank <= fromRank {
+		return ErrInvalidCabinMove
+	}
+
+	claim := `
+		UPDATE cabin_inventory
+		SET booked_seats = booked_seats + $4, updated_at = CURRENT_TIMESTAMP
+		WHERE flight_id = $1 AND date = $2 AND cabin = $3 AND booked_seats + $4 <= total_seats
+	`
+	release := `
+		UPDATE cabin_inventory
+		SET booked_seats = booked_seats - $4, updated_at = CURRENT_TIMESTAMP
+		WHERE flight_id = $1 AND date = $2 AND cabin = $3 AND booked_seats >= $4
+	`
+
+	err := fs.db.Transaction(func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, claim, req.FlightID, req.Date, req.ToCabin, req.Seats)
+		if err != nil {
+			return fmt.Errorf("failed to claim cabin seats: %w", err)
+		}
+		if claimed, _ := result.RowsAffected(); claimed == 0 {
+			return ErrCabinSoldOut
+		}
+
+		if req.FromCabin != models.CabinEconomy {
+			result, err := tx.ExecContext(ctx, release, req.FlightID, req.Date, req.FromCabin, req.Seats)
+			if err != nil {
+				return fmt.Errorf("failed to release cabin seats: %w", err)
+			}
+			if released, _ := result.RowsAffected(); released == 0 {
+				return fmt.Errorf("cabin %s has fewer than %d booked seats", req.FromCabin, req.Seats)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Economy seats go back to the seat counter only once the claim has committed; if that
+	// fails, the claimed premium seats are given back so the move leaves both unchanged
+	if req.FromCabin == models.CabinEconomy {
+		if err := fs.IncrementSeats(ctx, req.FlightID, req.Seats, req.Date, models.SeatReasonCabinUpgrade); err != nil {
+			if _, undoErr := fs.db.ExecContext(ctx, release, req.FlightID, req.Date, req.ToCabin, req.Seats); undoErr != nil {
+				log.Printf("Failed to give back %d %s seats on flight %d (%s) after a failed move: %v",
+					req.Seats, req.ToCabin, req.FlightID, req.Date, undoErr)
+			}
+			return fmt.Errorf("failed to release economy seats: %w", err)
+		}
+	}
+
+	log.Printf("Moved %d seats on flight %d (%s) from %s to %s", req.Seats, req.FlightID, req.Date, req.FromCabin, req.ToCabin)
+	return nil
+}
+
+// ReleaseCabinSeats returns cancelled seats to a premium cabin
+func (fs *FlightService) ReleaseCabinSeats(ctx context.Context, flightID, seats int, date, cabin string) error {
+	query := `
+		UPDATE cabin_inventory
+		SET booked_seats = GREATEST(booked_seats - $4, 0), updated_at = CURRENT_TIMESTAMP
+		WHERE flight_id = $1 AND date = $2 AND cabin = $3
+	`
+
+	result, err := fs.db.ExecContext(ctx, query, flightID, date, cabin, seats)
+	if err != nil {
+		return fmt.Errorf("failed to release cabin seats: %w", err)
+	}
+	if released, _ := result.RowsAffected(); released == 0 {
+		return ErrCabinNotFound
+	}
+
+	log.Printf("Released %d %s seats for flight %d on %s", seats, cabin, flightID, date)
+	return nil
+}
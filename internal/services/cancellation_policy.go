@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/pkg/models"
+)
+
+// ErrPolicyNotFound is returned when no cancellation policy exists for a fare code
+var ErrPolicyNotFound = errors.New("cancellation policy not found")
+
+// ErrPolicyInUse is returned when deleting a policy that bookings still reference
+var ErrPolicyInUse = errors.New("cancellation policy is used by existing bookings")
+
+// CancellationPolicyService manages the per-fare cancellation rule sets
+type CancellationPolicyService struct {
+	db *database.DB
+}
+
+// NewCancellationPolicyService creates a new cancellation policy service
+func NewCancellationPolicyService(db *database.DB) *CancellationPolicyService {
+	return &CancellationPolicyService{
+		db: db,
+	}
+}
+
+// scanPolicy scans a cancellation_policies row
+func scanPolicy(row rowScanner) (*models.CancellationPolicy, error) {
+	var policy models.CancellationPolicy
+	var tiers []byte
+	if err := row.Scan(&policy.FareCode, &policy.Name, &policy.Refundable, &tiers, &policy.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(tiers, &policy.Tiers); err != nil {
+		return nil, fmt.Errorf("failed to decode fee tiers for %s: %w", policy.FareCode, err)
+	}
+	return &policy, nil
+}
+
+// GetPolicy returns the cancellation policy for a fare code
+func (ps *CancellationPolicyService) GetPolicy(ctx context.Context, fareCode string) (*models.CancellationPolicy, error) {
+	query := `
+		SELECT fare_code, name, refundable, tiers, updated_at
+		FROM cancellation_policies
+		WHERE fare_code = $1
+	`
+
+	policy, err := scanPolicy(ps.db.QueryRowContext(ctx, query, fareCode))
+	if err == sql.ErrNoRows {
+		return nil, ErrPolicyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cancellation policy: %w", err)
+	}
+	return policy, nil
+}
+
+// ListPolicies returns every cancellation policy
+func (ps *CancellationPolicyService) ListPolicies(ctx context.Context) ([]models.CancellationPolicy, error) {
+	query := `
+		SELECT fare_code, name, refundable, tiers, updated_at
+		FROM cancellation_policies
+		ORDER BY fare_code
+	`
+
+	rows, err := ps.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cancellation policies: %w", err)
+	}
+	defer rows.Close()
+
+	policies := []models.CancellationPolicy{}
+	for rows.Next() {
+		policy, err := scanPolicy(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan cancellation policy: %w", err)
+		}
+		policies = append(policies, *policy)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cancellation policies: %w", err)
+	}
+
+	return policies, nil
+}
+
+// UpsertPolicy creates or replaces the cancellation policy for a fare code
+func (ps *CancellationPolicyService) UpsertPolicy(ctx context.Context, policy *models.CancellationPolicy) (*models.CancellationPolicy, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	if policy.Tiers == nil {
+		policy.Tiers = []models.FeeTier{}
+	}
+
+	tiers, err := json.Marshal(policy.Tiers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode fee tiers: %w", err)
+	}
+
+	query := `
+		INSERT INTO cancellation_policies (fare_code, name, refundable, tiers)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (fare_code) DO UPDATE
+		SET name = EXCLUDED.name, refundable = EXCLUDED.refundable, tiers = EXCLUDED.tiers,
+		    updated_at = CURRENT_TIMESTAMP
+		RETURNING fare_code, name, refundable, tiers, updated_at
+	`
+
+	saved, err := scanPolicy(ps.db.QueryRowContext(ctx, query, policy.FareCode, policy.Name, policy.Refundable, tiers))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save cancellation policy: %w", err)
+	}
+	return saved, nil
+}
+
+// DeletePolicy removes a cancellation policy that no booking references
+func (ps *CancellationPolicyService) DeletePolicy(ctx context.Context, fareCode string) error {
+	if fareCode == models.DefaultFareCode {
+		return ErrPolicyInUse
+	}
+
+	var inUse bool
+	err := ps.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM bookings WHERE fare_code = $1)`, fareCode).Scan(&inUse)
+	if err != nil {
+		return fmt.Errorf("failed to check policy usage: %w", err)
+	}
+	if inUse {
+		return ErrPolicyInUse
+	}
+
+	result, err := ps.db.ExecContext(ctx, `DELETE FROM cancellation_policies WHERE fare_code = $1`, fareCode)
+	if err != nil {
+		return fmt.Errorf("failed to delete cancellation policy: %w", err)
+	}
+	if deleted, err := result.RowsAffected(); err == nil && deleted == 0 {
+		return ErrPolicyNotFound
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+
+	"cred_flights_booking/internal/experiments"
+	"cred_flights_booking/pkg/models"
+)
+
+// AssignExperiments returns a context carrying the user's experiment variants, so fares and
+// published events downstream match what the user was shown in search
+func (fs *FlightService) AssignExperiments(ctx context.Context, userID int) context.Context {
+	return experiments.WithAssignments(ctx, fs.experiments.Assign(userID))
+}
+
+// ExperimentStats returns exposure counts per experiment variant
+func (fs *FlightService) ExperimentStats(ctx context.Context) ([]experiments.ExperimentStats, error) {
+	return fs.experiments.Stats(ctx)
+}
+
+// rankingWeightsFor returns the recommended-sort weights, with any overrides from the
+// user's ranking variant
+func (fs *FlightService) rankingWeightsFor(ctx context.Context) RankingWeights {
+	weights := fs.rankingWeights
+	assignments := experiments.FromContext(ctx)
+	if value, ok := assignments.Param(experiments.Ranking, "weight_price"); ok {
+		weights.Price = value
+	}
+	if value, ok := assignments.Param(experiments.Ranking, "weight_duration"); ok {
+		weights.Duration = value
+	}
+	if value, ok := assignments.Param(experiments.Ranking, "weight_stops"); ok {
+		weights.Stops = value
+	}
+	return weights
+}
+
+// priceMultiplier returns the fare multiplier of the user's pricing variant (1 when none)
+func (fs *FlightService) priceMultiplier(ctx context.Context) float64 {
+	multiplier, ok := experiments.FromContext(ctx).Param(experiments.Pricing, "price_multiplier")
+	if !ok || multiplier <= 0 {
+		return 1
+	}
+	return multiplier
+}
+
+// applyPricing scales path fares by the user's pricing variant. Flights are copied first
+// because search results may be shared with concurrent callers.
+func (fs *FlightService) applyPricing(ctx context.Context, paths []models.FlightPath) {
+	multiplier := fs.priceMultiplier(ctx)
+	if multiplier == 1 {
+		return
+	}
+
+	for i := range paths {
+		flights := make([]models.Flight, len(paths[i].Flights))
+		copy(flights, paths[i].Flights)
+
+		for j := range flights {
+			flights[j].Price = experimentPrice(flights[j].Price, multiplier)
+		}
+		paths[i].Flights = flights
+		paths[i].CalculateTotalPrice()
+	}
+}
+
+// experimentPrice scales a fare, rounded to the nearest minor unit
+func experimentPrice(price models.Money, multiplier float64) models.Money {
+	if multiplier == 1 {
+		return price
+	}
+	return price.Scale(multiplier)
+}
@@ -0,0 +1,278 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/internal/feeds"
+	"cred_flights_booking/pkg/models"
+)
+
+// ErrFeedNotConfigured is returned when a sync is requested without a flight feed
+var ErrFeedNotConfigured = errors.New("flight feed is not configured")
+
+// FeedSyncService imports an external schedule/availability feed into the flights table
+type FeedSyncService struct {
+	db      *database.DB
+	flights *FlightService
+	fetcher feeds.Fetcher
+	cfg     feeds.Config
+}
+
+// NewFeedSyncService creates a new feed sync service; a nil fetcher disables syncing
+func NewFeedSyncService(db *database.DB, flights *FlightService, fetcher feeds.Fetcher, cfg feeds.Config) *FeedSyncService {
+	return &FeedSyncService{
+		db:      db,
+		flights: flights,
+		fetcher: fetcher,
+		cfg:     cfg,
+	}
+}
+
+// feedRow scans a row selected with flightColumns followed by feed_key
+type feedRow struct {
+	rows *sql.Rows
+	key  *string
+}
+
+// Scan scans the flight columns and the feed key
+func (r feedRow) Scan(dest ...interface{}) error {
+	return r.rows.Scan(append(dest, r.key)...)
+}
+
+// Sync fetches the feed and applies it to the flights it owns: new records are created,
+// changed times, seats, and fares are updated, and records marked cancelled (or, with
+// CancelMissing, dropped from the snapshot's date range) are cancelled. Flights created by
+// admins or schedules are never touched. Every change goes through the same paths as admin
+// edits, so caches are invalidated and flight events published.
+func (s *FeedSyncService) Sync(ctx context.Context, triggeredBy string) (*models.FeedSyncResponse, error) {
+	if s.fetcher == nil {
+		return nil, ErrFeedNotConfigured
+	}
+
+	records, err := s.fetcher.Fetch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch flight feed: %w", err)
+	}
+
+	response := &models.FeedSyncResponse{
+		Source:      s.cfg.Source,
+		Records:     len(records),
+		Failed:      []models.FeedSyncError{},
+		TriggeredBy: triggeredBy,
+		SyncedAt:    time.Now(),
+	}
+	fail := func(key string, err error) {
+		response.Failed = append(response.Failed, models.FeedSyncError{Key: key, Error: err.Error()})
+	}
+
+	// Index the valid records, keeping the feed's order
+	var feed []*models.FeedFlight
+	seen := make(map[string]bool)
+	var from, to string
+	for i := range records {
+		record := &records[i]
+		if err := record.Validate(); err != nil {
+			fail(record.Key(), err)
+			continue
+		}
+		if seen[record.Key()] {
+			fail(record.Key(), fmt.Errorf("duplicate record in feed"))
+			continue
+		}
+		seen[record.Key()] = true
+		record.DepartureTime = wallClock(record.DepartureTime)
+		record.ArrivalTime = wallClock(record.ArrivalTime)
+		feed = append(feed, record)
+
+		if from == "" || record.Date < from {
+			from = record.Date
+		}
+		if record.Date > to {
+			to = record.Date
+		}
+	}
+
+	local, err := s.loadFeedFlights(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	actor := "feed:" + s.cfg.Source
+	for _, record := range feed {
+		flight, exists := local[record.Key()]
+		cancelled := record.Status == models.FlightStatusCancelled
+
+		switch {
+		case !exists && cancelled:
+			response.Unchanged++
+		case !exists:
+			created, err := s.createFlight(ctx, record)
+			if err != nil {
+				fail(record.Key(), err)
+			} else if created {
+				response.Created++
+			} else {
+				response.Unchanged++
+			}
+		case flight.Status == models.FlightStatusCancelled:
+			if !cancelled {
+				fail(record.Key(), fmt.Errorf("flight %d is cancelled and cannot be reinstated", flight.ID))
+				continue
+			}
+			response.Unchanged++
+		case cancelled:
+			if _, err := s.flights.CancelFlight(ctx, flight.ID, "cancelled in feed", actor); err != nil {
+				fail(record.Key(), err)
+				continue
+			}
+			response.Cancelled++
+		default:
+			updated, err := s.updateFlight(ctx, flight, record, actor)
+			if err != nil {
+				fail(record.Key(), err)
+			} else if updated {
+				response.Updated++
+			} else {
+				response.Unchanged++
+			}
+		}
+	}
+
+	// Flights the snapshot covers but no longer lists have been withdrawn from the feed
+	if s.cfg.CancelMissing && from != "" {
+		now := time.Now()
+		for key, flight := range local {
+			_, date, _ := strings.Cut(key, "/")
+			if seen[key] || date < from || date > to ||
+				flight.Status != models.FlightStatusScheduled || flight.DepartureTime.Before(now) {
+				continue
+			}
+			if _, err := s.flights.CancelFlight(ctx, flight.ID, "no longer in feed", actor); err != nil {
+				fail(key, err)
+				continue
+			}
+			response.Cancelled++
+		}
+	}
+
+	log.Printf("Synced flight feed %s: %d records, %d created, %d updated, %d cancelled, %d failed",
+		s.cfg.Source, response.Records, response.Created, response.Updated, response.Cancelled, len(response.Failed))
+	return response, nil
+}
+
+// wallClock returns a feed time's local date and time without its offset, as flight times
+// are stored in airport-local time and scanned back in UTC
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// loadFeedFlights returns the flights imported from the feed, by feed key
+func (s *FeedSyncService) loadFeedFlights(ctx context.Context) (map[string]*models.Flight, error) {
+	query := `SELECT ` + flightColumns + `, feed_key FROM flights WHERE feed_source = $1`
+
+	rows, err := s.db.QueryContext(ctx, query, s.cfg.Source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query feed flights: %w", err)
+	}
+	defer rows.Close()
+
+	flights := make(map[string]*models.Flight)
+	for rows.Next() {
+		var key string
+		flight, _, err := scanFlightRow(feedRow{rows: rows, key: &key})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan feed flight: %w", err)
+		}
+		flights[key] = flight
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read feed flights: %w", err)
+	}
+
+	return flights, nil
+}
+
+// createFlight inserts a feed record as a new flight with its date-scoped inventory. It
+// returns false if a concurrent sync created the flight first.
+func (s *FeedSyncService) createFlight(ctx context.Context, record *models.FeedFlight) (bool, error) {
+	query := `
+		INSERT INTO flights (flight_number, source, destination, departure_time, arrival_time,
+		                     total_seats, booked_seats, price, feed_source, feed_key)
+		VALUES ($1, $2, $3, $4, $5, $6, 0, $7, $8, $9)
+		ON CONFLICT (feed_source, feed_key) WHERE feed_source IS NOT NULL DO NOTHING
+		RETURNING ` + flightColumns
+
+	inventoryQuery := `
+		INSERT INTO flight_inventory (flight_id, date, total_seats, booked_seats)
+		VALUES ($1, DATE($2), $3, 0)
+		ON CONFLICT (flight_id, date) DO NOTHING
+	`
+
+	var flight *models.Flight
+	err := s.db.Transaction(func(tx *sql.Tx) error {
+		var err error
+		flight, _, err = scanFlightRow(tx.QueryRowContext(ctx, query,
+			record.FlightNumber, record.Source, record.Destination, record.DepartureTime, record.ArrivalTime,
+			record.TotalSeats, record.Price, s.cfg.Source, record.Key()))
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, inventoryQuery, flight.ID, flight.DepartureTime, flight.TotalSeats); err != nil {
+			return fmt.Errorf("failed to create inventory: %w", err)
+		}
+		return nil
+	})
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to create flight: %w", err)
+	}
+
+	s.flights.invalidateFlightCaches(ctx, flight)
+	publishFlightEvent(ctx, s.flights.events, models.EventFlightCreated, flight, nil, "")
+	return true, nil
+}
+
+// updateFlight applies a feed record's changed times, seats, and fare to its flight. It
+// returns false if nothing changed.
+func (s *FeedSyncService) updateFlight(ctx context.Context, flight *models.Flight, record *models.FeedFlight, actor string) (bool, error) {
+	if flight.Source != record.Source || flight.Destination != record.Destination {
+		return false, fmt.Errorf("route %s-%s does not match flight %d (%s-%s)",
+			record.Source, record.Destination, flight.ID, flight.Source, flight.Destination)
+	}
+
+	var req models.FlightUpdateRequest
+	changed := false
+	if !flight.DepartureTime.Equal(record.DepartureTime) {
+		req.DepartureTime = &record.DepartureTime
+		changed = true
+	}
+	if !flight.ArrivalTime.Equal(record.ArrivalTime) {
+		req.ArrivalTime = &record.ArrivalTime
+		changed = true
+	}
+	if flight.TotalSeats != record.TotalSeats {
+		req.TotalSeats = &record.TotalSeats
+		changed = true
+	}
+	if flight.Price != record.Price {
+		req.Price = &record.Price
+		changed = true
+	}
+	if !changed {
+		return false, nil
+	}
+
+	if _, err := s.flights.UpdateFlight(ctx, flight.ID, &req, actor); err != nil {
+		return false, err
+	}
+	return true, nil
+}
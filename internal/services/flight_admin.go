@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"cred_flights_booking/internal/cdn"
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/internal/events"
+	"cred_flights_booking/internal/funnel"
+	"cred_flights_booking/internal/tracing"
+	"cred_flights_booking/pkg/models"
+)
+
+// ErrFlightNotFound is returned when a flight does not exist or can no longer be changed
+var ErrFlightNotFound = errors.New("flight not found")
+
+// flightColumns lists the flight columns scanned by scanFlightRow
+const flightColumns = `id, flight_number, source, destination, departure_time, arrival_time,
+	total_seats, booked_seats, price, created_at, status, schedule_id`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanFlightRow scans a row selected with flightColumns
+func scanFlightRow(row rowScanner) (*models.Flight, *int, error) {
+	var flight models.Flight
+	var scheduleID sql.NullInt64
+	err := row.Scan(
+		&flight.ID, &flight.FlightNumber, &flight.Source, &flight.Destination,
+		&flight.DepartureTime, &flight.ArrivalTime, &flight.TotalSeats,
+		&flight.BookedSeats, &flight.Price, &flight.CreatedAt, &flight.Status, &scheduleID,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if scheduleID.Valid {
+		id := int(scheduleID.Int64)
+		return &flight, &id, nil
+	}
+	return &flight, nil, nil
+}
+
+// GetFlight returns a flight with its airline and local display times
+func (fs *FlightService) GetFlight(ctx context.Context, flightID int) (*models.Flight, error) {
+	flight, _, err := scanFlightRow(fs.db.QueryRowContext(ctx,
+		`SELECT `+flightColumns+` FROM flights WHERE id = $1`, flightID))
+	if err == sql.ErrNoRows {
+		return nil, ErrFlightNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query flight: %w", err)
+	}
+
+	flights := []models.Flight{*flight}
+	fs.enrichFlights(ctx, flights)
+	return &flights[0], nil
+}
+
+// TrackSelection records in the booking funnel that the session opened a flight's details.
+// Only client sessions count: services also fetch flights (booking details, admin views),
+// and those lookups are not selections.
+func (fs *FlightService) TrackSelection(ctx context.Context, flight *models.Flight) {
+	if info := tracing.FromContext(ctx); info == nil || info.SessionID == "" {
+		return
+	}
+	fs.funnel.Track(ctx, funnel.StageSelection, funnel.Route(flight.Source, flight.Destination))
+}
+
+// UpdateFlight applies an admin change to a scheduled flight and publishes FlightUpdated
+func (fs *FlightService) UpdateFlight(ctx context.Context, flightID int, req *models.FlightUpdateRequest, updatedBy string) (*models.Flight, error) {
+	previous, _, err := scanFlightRow(fs.db.QueryRowContext(ctx,
+		`SELECT `+flightColumns+` FROM flights WHERE id = $1 AND status = $2`, flightID, models.FlightStatusScheduled))
+	if err == sql.ErrNoRows {
+		return nil, ErrFlightNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query flight: %w", err)
+	}
+
+	departure, arrival := previous.DepartureTime, previous.ArrivalTime
+	if req.DepartureTime != nil {
+		departure = *req.DepartureTime
+	}
+	if req.ArrivalTime != nil {
+		arrival = *req.ArrivalTime
+	}
+	if !arrival.After(departure) {
+		return nil, fmt.Errorf("arrival time must be after departure time")
+	}
+	if req.TotalSeats != nil && *req.TotalSeats < previous.BookedSeats {
+		return nil, fmt.Errorf("total seats cannot be below the %d seats already booked", previous.BookedSeats)
+	}
+	if req.Price != nil && !req.Price.IsPositive() {
+		return nil, fmt.Errorf("price must be positive")
+	}
+
+	query := `
+		UPDATE flights
+		SET departure_time = $2, arrival_time = $3,
+		    total_seats = COALESCE($4, total_seats), price = COALESCE($5, price)
+		WHERE id = $1 AND status = $6
+		RETURNING ` + flightColumns
+
+	flight, scheduleID, err := scanFlightRow(fs.db.QueryRowContext(ctx, query,
+		flightID, departure, arrival, req.TotalSeats, req.Price, models.FlightStatusScheduled))
+	if err == sql.ErrNoRows {
+		return nil, ErrFlightNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update flight: %w", err)
+	}
+
+	// Keep the date-scoped inventory aligned with the flight
+	inventoryQuery := `
+		UPDATE flight_inventory
+		SET date = DATE($2), total_seats = COALESCE($3, total_seats), updated_at = CURRENT_TIMESTAMP
+		WHERE flight_id = $1
+	`
+	if _, err := fs.db.ExecContext(ctx, inventoryQuery, flightID, flight.DepartureTime, req.TotalSeats); err != nil {
+		return nil, fmt.Errorf("failed to update inventory: %w", err)
+	}
+
+	fs.invalidateFlightCaches(ctx, previous)
+	fs.invalidateFlightCaches(ctx, flight)
+
+	log.Printf("AUDIT: flight %d (%s) updated by %s", flight.ID, flight.FlightNumber, updatedBy)
+	publishFlightEvent(ctx, fs.events, models.EventFlightUpdated, flight, scheduleID, "")
+	return flight, nil
+}
+
+// CancelFlight marks a flight as cancelled, removes it from search, and publishes FlightCancelled
+func (fs *FlightService) CancelFlight(ctx context.Context, flightID int, reason, cancelledBy string) (*models.Flight, error) {
+	query := `
+		UPDATE flights
+		SET status = $2
+		WHERE id = $1 AND status <> $2
+		RETURNING ` + flightColumns
+
+	flight, scheduleID, err := scanFlightRow(fs.db.QueryRowContext(ctx, query, flightID, models.FlightStatusCancelled))
+	if err == sql.ErrNoRows {
+		return nil, ErrFlightNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel flight: %w", err)
+	}
+
+	fs.invalidateFlightCaches(ctx, flight)
+
+	log.Printf("AUDIT: flight %d (%s) cancelled by %s: %s", flight.ID, flight.FlightNumber, cancelledBy, reason)
+	publishFlightEvent(ctx, fs.events, models.EventFlightCancelled, flight, scheduleID, reason)
+	return flight, nil
+}
+
+// StartCDNPurges sends queued CDN purges of search responses until ctx is cancelled
+func (fs *FlightService) StartCDNPurges(ctx context.Context) {
+	fs.cdn.Start(ctx)
+}
+
+// PendingCDNPurges returns how many surrogate keys are waiting to be purged
+func (fs *FlightService) PendingCDNPurges(ctx context.Context) (int64, error) {
+	return int64(fs.cdn.Pending()), nil
+}
+
+// invalidateFlightCaches drops the cached search results, seat counter, and flight details
+// covering a flight, and purges the CDN's copies of searches that may show it
+func (fs *FlightService) invalidateFlightCaches(ctx context.Context, flight *models.Flight) {
+	date := flight.DepartureTime.Format("2006-01-02")
+	keys := []string{
+		database.GenerateSeatCacheKey(flight.ID, date),
+		database.GenerateFlightCacheKey(flight.ID),
+	}
+	for stops := 0; stops <= maxSearchStops; stops++ {
+		keys = append(keys, database.GenerateSearchCacheKey(flight.Source, flight.Destination, date, stops))
+	}
+	if err := fs.cache.Del(ctx, keys...).Err(); err != nil {
+		log.Printf("Failed to invalidate caches for flight %d: %v", flight.ID, err)
+	}
+	fs.cdn.Purge(cdn.RouteKey(flight.Source, flight.Destination, date), cdn.FlightKey(flight.ID, date))
+}
+
+// publishFlightEvent publishes a flight lifecycle event. Failures are logged, not returned.
+func publishFlightEvent(ctx context.Context, bus *events.Bus, eventType string, flight *models.Flight, scheduleID *int, reason string) {
+	if bus == nil {
+		return
+	}
+
+	payload := models.FlightEvent{
+		FlightID:      flight.ID,
+		FlightNumber:  flight.FlightNumber,
+		Source:        flight.Source,
+		Destination:   flight.Destination,
+		DepartureTime: flight.DepartureTime,
+		ArrivalTime:   flight.ArrivalTime,
+		TotalSeats:    flight.TotalSeats,
+		Price:         flight.Price,
+		Status:        flight.Status,
+		ScheduleID:    scheduleID,
+		Reason:        reason,
+	}
+	if err := bus.Publish(ctx, events.StreamFlights, eventType, payload); err != nil {
+		log.Printf("Failed to publish %s for flight %d: %v", eventType, flight.ID, err)
+	}
+}
+
+// publishSeatsEvent publishes a seat reservation or release event. Failures are logged, not returned.
+func (fs *FlightService) publishSeatsEvent(ctx context.Context, eventType string, flightID, seats int, date string, available int) {
+	if fs.events == nil {
+		return
+	}
+
+	payload := models.SeatsEvent{
+		FlightID:  flightID,
+		Date:      date,
+		Seats:     seats,
+		Available: available,
+	}
+	if err := fs.events.Publish(ctx, events.StreamFlights, eventType, payload); err != nil {
+		log.Printf("Failed to publish %s for flight %d: %v", eventType, flightID, err)
+	}
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/events"
+	"cred_flights_booking/pkg/models"
+)
+
+// ForecastConfig controls how booking velocity is estimated from seat events
+type ForecastConfig struct {
+	Lookback  time.Duration // How much event history is replayed
+	HalfLife  time.Duration // Age at which an event counts half as much as a new one
+	MinEvents int           // Events needed before a forecast is considered reliable
+}
+
+// LoadForecastConfig loads forecast settings from the environment
+func LoadForecastConfig() ForecastConfig {
+	return ForecastConfig{
+		Lookback:  config.GetDuration("FORECAST_LOOKBACK", 14*24*time.Hour),
+		HalfLife:  config.GetDuration("FORECAST_HALF_LIFE", 48*time.Hour),
+		MinEvents: config.GetInt("FORECAST_MIN_EVENTS", 5),
+	}
+}
+
+// ForecastFlight models a flight date's booking velocity from the seats.reserved and
+// seats.released events on the flights stream, and projects when it sells out and its
+// load factor at departure. An empty date means the flight's own departure date.
+func (fs *FlightService) ForecastFlight(ctx context.Context, flightID int, date string) (*models.FlightForecast, error) {
+	flight, err := fs.GetFlight(ctx, flightID)
+	if err != nil {
+		return nil, err
+	}
+	if date == "" {
+		date = flight.DepartureTime.Format("2006-01-02")
+	}
+
+	availability, err := fs.GetSeatAvailability(ctx, flightID, date)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	cfg := fs.forecastConfig
+	windowStart := now.Add(-cfg.Lookback)
+	if flight.CreatedAt.After(windowStart) {
+		windowStart = flight.CreatedAt
+	}
+
+	// Weight each event's net seats by its age, halving every half-life
+	var weightedSeats float64
+	var analyzed, last24h int
+	replay := func(event *events.Event) error {
+		var sign int
+		switch event.Type {
+		case models.EventSeatsReserved:
+			sign = 1
+		case models.EventSeatsReleased:
+			sign = -1
+		default:
+			return nil
+		}
+
+		var payload models.SeatsEvent
+		if err := event.Decode(&payload); err != nil || payload.FlightID != flightID || payload.Date != date {
+			return nil
+		}
+
+		seats := sign * payload.Seats
+		age := now.Sub(event.OccurredAt)
+		weightedSeats += float64(seats) * math.Pow(0.5, age.Hours()/cfg.HalfLife.Hours())
+		analyzed++
+		if age <= 24*time.Hour {
+			last24h += seats
+		}
+		return nil
+	}
+	if fs.events != nil {
+		if err := fs.events.Range(ctx, events.StreamFlights, windowStart, replay); err != nil {
+			return nil, err
+		}
+	}
+
+	forecast := &models.FlightForecast{
+		FlightID:        flightID,
+		Date:            date,
+		DepartureTime:   flight.DepartureTime,
+		TotalSeats:      availability.TotalSeats,
+		AvailableSeats:  max(availability.Available, 0),
+		NetSeatsLast24h: last24h,
+		EventsAnalyzed:  analyzed,
+		WindowHours:     roundTo(now.Sub(windowStart).Hours(), 2),
+		SoldOut:         availability.Available <= 0,
+		LowConfidence:   analyzed < cfg.MinEvents,
+		GeneratedAt:     now,
+	}
+	forecast.BookedSeats = forecast.TotalSeats - forecast.AvailableSeats
+	forecast.HoursToDeparture = roundTo(math.Max(flight.DepartureTime.Sub(now).Hours(), 0), 2)
+	if forecast.TotalSeats > 0 {
+		forecast.LoadFactor = roundTo(float64(forecast.BookedSeats)/float64(forecast.TotalSeats), 4)
+	}
+
+	// Velocity is the weighted seats over the weighted time they were observed:
+	// the integral of 0.5^(t/halfLife) across the window
+	halfLife := cfg.HalfLife.Hours()
+	exposure := halfLife / math.Ln2 * (1 - math.Pow(0.5, forecast.WindowHours/halfLife))
+	velocity := 0.0
+	if exposure > 0 {
+		velocity = weightedSeats / exposure
+	}
+	forecast.VelocitySeatsPerHour = roundTo(velocity, 4)
+
+	// Project forward; net releases are treated as no further sales
+	projected := 0.0
+	if velocity > 0 {
+		projected = math.Min(velocity*forecast.HoursToDeparture, float64(forecast.AvailableSeats))
+		if hours := float64(forecast.AvailableSeats) / velocity; !forecast.SoldOut && hours <= forecast.HoursToDeparture {
+			sellOut := now.Add(time.Duration(hours * float64(time.Hour))).Truncate(time.Minute)
+			forecast.PredictedSellOutAt = &sellOut
+		}
+	}
+	forecast.PredictedUnsoldSeats = forecast.AvailableSeats - int(math.Round(projected))
+	if forecast.TotalSeats > 0 {
+		final := float64(forecast.TotalSeats-forecast.PredictedUnsoldSeats) / float64(forecast.TotalSeats)
+		forecast.PredictedFinalLoadFactor = roundTo(final, 4)
+	}
+
+	return forecast, nil
+}
+
+// roundTo rounds value to the given number of decimal places
+func roundTo(value float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(value*scale) / scale
+}
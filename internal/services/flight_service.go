@@ -3,81 +3,248 @@ package services
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"sort"
-	"strings"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"cred_flights_booking/internal/cdn"
+	"cred_flights_booking/internal/config"
 	"cred_flights_booking/internal/database"
-	"cred_flights_booking/internal/models"
+	"cred_flights_booking/internal/events"
+	"cred_flights_booking/internal/experiments"
+	"cred_flights_booking/internal/farequote"
+	"cred_flights_booking/internal/funnel"
+	"cred_flights_booking/internal/httpclient"
+	"cred_flights_booking/internal/seatnonce"
+	"cred_flights_booking/internal/tax"
+	"cred_flights_booking/pkg/client"
+	"cred_flights_booking/pkg/models"
+	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
 	"golang.org/x/sync/singleflight"
 )
 
+// ErrInvalidOccupancyDelta is returned for occupancy events changing no seats, or more seats
+// than the flight has
+var ErrInvalidOccupancyDelta = errors.New("invalid occupancy delta")
+
 // FlightService handles flight-related operations
 type FlightService struct {
-	db    *database.DB
-	cache *database.RedisClient
+	db                *database.DB
+	cache             *database.RedisClient
+	events            *events.Bus
+	bookings          *client.BookingClient
+	searchCacheConfig SearchCacheConfig
+	searchDepth       SearchDepthConfig
+	forecastConfig    ForecastConfig
+	rankingWeights    RankingWeights
+	experiments       *experiments.Registry
+	funnel            *funnel.Tracker
+	quotes            *farequote.Signer
+	seatNonces        *seatnonce.Signer
+	taxes             *tax.Engine
+	nearbyRadiusKm    float64
+	seatEvents        bool // Seat changes are appended to seat_events and counters replayed from them
+	cdn               *cdn.Purger
+	popularRoutes     PopularRoutesConfig
+	popularSet        popularRouteSet // Popular routes, for search cache TTLs
+	reference         referenceData
 	// Singleflight group to prevent cache stampede
 	searchGroup singleflight.Group
+	// How search loads are shared between replicas
+	searchCoalescing SearchCoalescingConfig
+	// Search keys with a background refresh in progress
+	refreshing sync.Map
+	// Fewest legs between airports, for skipping multi-stop queries that cannot succeed
+	routes atomic.Pointer[routeGraph]
 }
 
 // NewFlightService creates a new flight service
-func NewFlightService(db *database.DB, cache *database.RedisClient) *FlightService {
+func NewFlightService(db *database.DB, cache *database.RedisClient, bus *events.Bus, registry *experiments.Registry, bookingServiceURL string) *FlightService {
 	return &FlightService{
-		db:          db,
-		cache:       cache,
-		searchGroup: singleflight.Group{},
+		db:                db,
+		cache:             cache,
+		events:            bus,
+		bookings:          client.NewBookingClient(httpclient.ServiceConfig(bookingServiceURL, 30*time.Second)),
+		searchCacheConfig: LoadSearchCacheConfig(),
+		searchDepth:       LoadSearchDepthConfig(),
+		forecastConfig:    LoadForecastConfig(),
+		rankingWeights:    LoadRankingWeights(),
+		experiments:       registry,
+		funnel:            funnel.NewTracker(cache),
+		quotes:            farequote.NewSigner(farequote.LoadConfig()),
+		seatNonces:        seatnonce.NewSigner(seatnonce.LoadConfig(), cache),
+		taxes:             tax.NewEngine(tax.LoadConfig()),
+		nearbyRadiusKm:    config.GetFloat("NEARBY_AIRPORT_RADIUS_KM", 100),
+		seatEvents:        config.GetBool("SEAT_EVENT_SOURCING", false),
+		cdn:               cdn.NewPurger(cdn.LoadConfig(), &http.Client{Timeout: 10 * time.Second}),
+		popularRoutes:     LoadPopularRoutesConfig(),
+		searchGroup:       singleflight.Group{},
+		searchCoalescing:  LoadSearchCoalescingConfig(),
 	}
 }
 
-// SearchFlights searches for flights with improved caching strategy
+// SearchFlights searches for flights with improved caching strategy. Signed-in users are
+// bucketed into experiments, which may change ranking and fares; the response is tagged
+// with their variants.
 func (fs *FlightService) SearchFlights(ctx context.Context, req *models.SearchRequest) (*models.SearchResponse, error) {
-	// Generate cache key for search results (src, dest, date only)
-	cacheKey := database.GenerateSearchCacheKey(req.Source, req.Destination, req.Date)
+	assignments := fs.experiments.Assign(req.UserID)
+	ctx = experiments.WithAssignments(ctx, assignments)
+
+	response, err := fs.search(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	fs.applyPricing(ctx, response.Paths)
+	response.Experiments = assignments.Tags()
+	fs.experiments.RecordExposures(ctx, assignments)
+	fs.funnel.Track(ctx, funnel.StageSearch, funnel.Route(req.Source, req.Destination))
+	return response, nil
+}
+
+// search runs a search for one airport pair, or for every nearby pair when requested
+func (fs *FlightService) search(ctx context.Context, req *models.SearchRequest) (*models.SearchResponse, error) {
+	if req.IncludeNearby {
+		return fs.searchNearbyAirports(ctx, req)
+	}
+
+	paths, err := fs.searchRoute(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.SearchResponse{
+		Paths: paths,
+		Count: len(paths),
+	}
+
+	return response, nil
+}
+
+// searchRoute searches a single source/destination pair
+func (fs *FlightService) searchRoute(ctx context.Context, req *models.SearchRequest) ([]models.FlightPath, error) {
+	// Generate cache key for search results (src, dest, date, and search depth only)
+	maxStops := fs.searchDepthFor(req)
+	cacheKey := database.GenerateSearchCacheKey(req.Source, req.Destination, req.Date, maxStops)
 
-	// Try to get cached search results
-	var cachedFlights []models.Flight
-	if err := fs.cache.GetJSON(ctx, cacheKey, &cachedFlights); err == nil {
-		log.Printf("Cache hit for search key: %s", cacheKey)
+	// Reuse the sorted paths of a recent search in the same seat bucket and order
+	if paths, ok := fs.getSearchProjection(ctx, req); ok {
+		return paths, nil
+	}
+
+	// Try to get cached search results, serving stale entries while they refresh
+	if cachedFlights, fresh, err := fs.getCachedSearch(ctx, cacheKey); err == nil {
+		if fresh {
+			log.Printf("Cache hit for search key: %s", cacheKey)
+			searchCacheStats.Add("hits", 1)
+		} else {
+			log.Printf("Serving stale search results for key: %s", cacheKey)
+			searchCacheStats.Add("stale_serves", 1)
+			fs.refreshSearchInBackground(req.Source, req.Destination, req.Date, maxStops)
+		}
 		// Filter flights based on available seats and sort
-		paths := fs.filterAndSortFlights(cachedFlights, req.Seats, req.SortBy)
-		return &models.SearchResponse{
-			Paths: paths,
-			Count: len(paths),
-		}, nil
+		return fs.filterAndSortFlights(ctx, cachedFlights, req), nil
 	}
+	searchCacheStats.Add("misses", 1)
 
 	// Cache miss - use singleflight to prevent stampede
-	searchKey := fmt.Sprintf("%s:%s:%s", req.Source, req.Destination, req.Date)
-	flights, err, _ := fs.searchGroup.Do(searchKey, func() (interface{}, error) {
-		return fs.searchFlightsFromDB(ctx, req.Source, req.Destination, req.Date)
-	})
-
+	flightList, err := fs.loadSearchResultsShared(ctx, req.Source, req.Destination, req.Date, maxStops)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search flights: %w", err)
 	}
 
-	flightList := flights.([]models.Flight)
+	// Filter flights based on available seats and sort
+	return fs.filterAndSortFlights(ctx, flightList, req), nil
+}
 
-	// Cache the search results for 2 hours
-	if err := fs.cache.SetJSON(ctx, cacheKey, flightList, 2*time.Hour); err != nil {
-		log.Printf("Failed to cache search results: %v", err)
+// searchNearbyAirports expands source and destination to nearby airports, searches every
+// airport pair, and merges the labeled results
+func (fs *FlightService) searchNearbyAirports(ctx context.Context, req *models.SearchRequest) (*models.SearchResponse, error) {
+	radius := req.NearbyRadiusKm
+	if radius <= 0 {
+		radius = fs.nearbyRadiusKm
 	}
 
-	// Filter flights based on available seats and sort
-	paths := fs.filterAndSortFlights(flightList, req.Seats, req.SortBy)
+	sources, err := fs.nearbyAirports(ctx, req.Source, radius)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand source airport: %w", err)
+	}
+	destinations, err := fs.nearbyAirports(ctx, req.Destination, radius)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand destination airport: %w", err)
+	}
 
-	response := &models.SearchResponse{
-		Paths: paths,
-		Count: len(paths),
+	var allPaths []models.FlightPath
+	var pairs []string
+	for _, source := range sources {
+		for _, destination := range destinations {
+			if source == destination {
+				continue
+			}
+
+			pairReq := *req
+			pairReq.Source = source
+			pairReq.Destination = destination
+
+			paths, err := fs.searchRoute(ctx, &pairReq)
+			if err != nil {
+				log.Printf("Nearby search failed for %s-%s: %v", source, destination, err)
+				continue
+			}
+
+			pair := fmt.Sprintf("%s-%s", source, destination)
+			pairs = append(pairs, pair)
+			for i := range paths {
+				paths[i].AirportPair = pair
+				paths[i].Nearby = source != req.Source || destination != req.Destination
+			}
+			allPaths = append(allPaths, paths...)
+		}
 	}
 
-	return response, nil
+	// Re-rank the merged results and apply the usual truncation
+	fs.sortFlightPaths(ctx, allPaths, req.SortBy)
+	paths := applyDiversity(allPaths, req.Diversity, 20)
+
+	return &models.SearchResponse{
+		Paths:        paths,
+		Count:        len(paths),
+		AirportPairs: pairs,
+	}, nil
+}
+
+// loadSearchResultsShared loads search results through singleflight, and across replicas as
+// SEARCH_COALESCING says. The shared load keeps the caller's context values (trace spans) but
+// not its cancellation, so one caller giving up does not fail every waiter; each caller still
+// stops waiting when its own context is done.
+func (fs *FlightService) loadSearchResultsShared(ctx context.Context, source, destination, date string, maxStops int) ([]models.Flight, error) {
+	searchKey := fmt.Sprintf("%s:%s:%s:%d", source, destination, date, maxStops)
+	resultCh := fs.searchGroup.DoChan(searchKey, func() (interface{}, error) {
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+		defer cancel()
+		return fs.loadSearchResultsCoalesced(loadCtx, source, destination, date, maxStops)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-resultCh:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return result.Val.([]models.Flight), nil
+	}
 }
 
 // searchFlightsFromDB searches flights from database (called by singleflight)
-func (fs *FlightService) searchFlightsFromDB(ctx context.Context, source, destination, date string) ([]models.Flight, error) {
+func (fs *FlightService) searchFlightsFromDB(ctx context.Context, source, destination, date string, maxStops int) ([]models.Flight, error) {
 	// Parse date
 	searchDate, err := time.Parse("2006-01-02", date)
 	if err != nil {
@@ -85,7 +252,7 @@ func (fs *FlightService) searchFlightsFromDB(ctx context.Context, source, destin
 	}
 
 	// Search for flights
-	paths, err := fs.findFlightPaths(ctx, source, destination, searchDate, 1) // Use 1 seat for search
+	paths, err := fs.findFlightPaths(ctx, source, destination, searchDate, 1, maxStops) // Use 1 seat for search
 	if err != nil {
 		return nil, fmt.Errorf("failed to find flight paths: %w", err)
 	}
@@ -107,19 +274,49 @@ func (fs *FlightService) searchFlightsFromDB(ctx context.Context, source, destin
 	return flights, nil
 }
 
-// filterAndSortFlights filters flights based on available seats and sorts them
-func (fs *FlightService) filterAndSortFlights(flights []models.Flight, requestedSeats int, sortBy string) []models.FlightPath {
+// filterAndSortFlights filters flights based on available seats and sorts them. The sorted
+// paths for the request's seat bucket are cached as a projection for similar searches.
+func (fs *FlightService) filterAndSortFlights(ctx context.Context, flights []models.Flight, req *models.SearchRequest) []models.FlightPath {
+	projection, err := fs.projectFlights(ctx, flights, req, seatBucket(req.Seats))
+	if err != nil {
+		log.Printf("Failed to get available seats: %v", err)
+		return nil
+	}
+	fs.cacheSearchProjection(ctx, req, projection)
+
+	return projection.selectPaths(req)
+}
+
+// projectFlights turns flights with at least minSeats available into paths, sorted by the
+// request's order
+func (fs *FlightService) projectFlights(ctx context.Context, flights []models.Flight, req *models.SearchRequest, minSeats int) (*searchProjection, error) {
+	// Check seat availability for all flights in one batch
+	seatCounts, err := fs.getAvailableSeatsBatch(ctx, flights)
+	if err != nil {
+		return nil, err
+	}
+
 	var validPaths []models.FlightPath
+	available := make(map[int]int)
+
+	// Restrict to requested airlines
+	airlines := make(map[string]bool, len(req.Airlines))
+	for _, code := range req.Airlines {
+		airlines[code] = true
+	}
 
-	// Check seat availability for each flight
 	for _, flight := range flights {
-		availableSeats, err := fs.getAvailableSeats(context.Background(), flight.ID, flight.DepartureTime.Format("2006-01-02"))
-		if err != nil {
-			log.Printf("Failed to get available seats for flight %d: %v", flight.ID, err)
+		if len(airlines) > 0 && !airlines[flight.AirlineCode()] {
+			continue
+		}
+
+		availableSeats, ok := seatCounts[flight.ID]
+		if !ok {
+			log.Printf("No seat count found for flight %d", flight.ID)
 			continue
 		}
 
-		if availableSeats >= requestedSeats {
+		if availableSeats >= minSeats {
 			path := models.FlightPath{
 				Flights: []models.Flight{flight},
 			}
@@ -127,18 +324,18 @@ func (fs *FlightService) filterAndSortFlights(flights []models.Flight, requested
 			path.CalculateTotalTime()
 			path.CalculateStops()
 			validPaths = append(validPaths, path)
+			available[flight.ID] = availableSeats
 		}
 	}
 
 	// Sort paths
-	fs.sortFlightPaths(validPaths, sortBy)
+	fs.sortFlightPaths(ctx, validPaths, req.SortBy)
 
-	// Limit to top 20
-	if len(validPaths) > 20 {
-		validPaths = validPaths[:20]
+	projection := &searchProjection{Paths: validPaths, Available: make([]int, len(validPaths))}
+	for i, path := range validPaths {
+		projection.Available[i] = available[path.Flights[0].ID]
 	}
-
-	return validPaths
+	return projection, nil
 }
 
 // getAvailableSeats gets available seats from cache or database
@@ -150,33 +347,211 @@ func (fs *FlightService) getAvailableSeats(ctx context.Context, flightID int, da
 		return seats, nil
 	}
 
-	// Cache miss - get from database
+	// Cache miss - replay seat events, or get from the date-scoped inventory, falling back
+	// to the flight row
+	var availableSeats int
+	if fs.seatEvents {
+		seats, err := fs.loadSeatsFromEvents(ctx, flightID, date)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get available seats: %w", err)
+		}
+		availableSeats = seats
+	} else {
+		query := `
+			SELECT COALESCE(i.total_seats - i.booked_seats, f.total_seats - f.booked_seats)
+			FROM flights f
+			LEFT JOIN flight_inventory i ON i.flight_id = f.id AND i.date = $2
+			WHERE f.id = $1 AND DATE(f.departure_time) = $2
+		`
+
+		err := fs.db.QueryRowContext(ctx, query, flightID, date).Scan(&availableSeats)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get available seats: %w", err)
+		}
+	}
+
+	// Counters never expire; the archive job removes them after departure
+	pipe := fs.cache.Pipeline()
+	fs.storeSeatCounter(ctx, pipe, flightID, date, availableSeats)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to cache seat count: %v", err)
+	}
+
+	return availableSeats, nil
+}
+
+// getAvailableSeatsBatch gets available seats for many flights with a single MGET,
+// falling back to one database query for all cache misses
+func (fs *FlightService) getAvailableSeatsBatch(ctx context.Context, flights []models.Flight) (map[int]int, error) {
+	seatCounts := make(map[int]int, len(flights))
+	if len(flights) == 0 {
+		return seatCounts, nil
+	}
+
+	cacheKeys := make([]string, len(flights))
+	for i, flight := range flights {
+		cacheKeys[i] = database.GenerateSeatCacheKey(flight.ID, flight.DepartureTime.Format("2006-01-02"))
+	}
+
+	// Try cache first
+	var missing []models.Flight
+	values, err := fs.cache.MGet(ctx, cacheKeys...).Result()
+	if err != nil {
+		log.Printf("Failed to batch read seat counts from cache: %v", err)
+		missing = flights
+	} else {
+		for i, value := range values {
+			str, ok := value.(string)
+			if !ok {
+				missing = append(missing, flights[i])
+				continue
+			}
+			seats, err := strconv.Atoi(str)
+			if err != nil {
+				missing = append(missing, flights[i])
+				continue
+			}
+			seatCounts[flights[i].ID] = seats
+		}
+	}
+
+	if len(missing) == 0 {
+		return seatCounts, nil
+	}
+
+	// Counters replayed from seat events are loaded one flight date at a time
+	if fs.seatEvents {
+		for _, flight := range missing {
+			seats, err := fs.getAvailableSeats(ctx, flight.ID, flight.DepartureTime.Format("2006-01-02"))
+			if err != nil {
+				return nil, err
+			}
+			seatCounts[flight.ID] = seats
+		}
+		return seatCounts, nil
+	}
+
+	// Cache miss - get all missing counts from database in one query
+	ids := make([]int64, len(missing))
+	dates := make(map[int]string, len(missing))
+	for i, flight := range missing {
+		ids[i] = int64(flight.ID)
+		dates[flight.ID] = flight.DepartureTime.Format("2006-01-02")
+	}
+
 	query := `
-		SELECT total_seats - booked_seats
-		FROM flights 
-		WHERE id = $1 AND DATE(departure_time) = $2
+		SELECT f.id, COALESCE(i.total_seats - i.booked_seats, f.total_seats - f.booked_seats)
+		FROM flights f
+		LEFT JOIN flight_inventory i ON i.flight_id = f.id AND i.date = DATE(f.departure_time)
+		WHERE f.id = ANY($1)
 	`
 
-	var availableSeats int
-	err := fs.db.QueryRowContext(ctx, query, flightID, date).Scan(&availableSeats)
+	rows, err := fs.db.QueryContext(ctx, query, pq.Array(ids))
 	if err != nil {
-		return 0, fmt.Errorf("failed to get available seats: %w", err)
+		return nil, fmt.Errorf("failed to get available seats: %w", err)
 	}
+	defer rows.Close()
 
-	// Cache the result for 1 hour
-	if err := fs.cache.Set(ctx, cacheKey, availableSeats, time.Hour).Err(); err != nil {
-		log.Printf("Failed to cache seat count: %v", err)
+	pipe := fs.cache.Pipeline()
+	for rows.Next() {
+		var flightID, availableSeats int
+		if err := rows.Scan(&flightID, &availableSeats); err != nil {
+			return nil, fmt.Errorf("failed to scan available seats: %w", err)
+		}
+		seatCounts[flightID] = availableSeats
+		fs.storeSeatCounter(ctx, pipe, flightID, dates[flightID], availableSeats)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read available seats: %w", err)
 	}
 
-	return availableSeats, nil
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to cache seat counts: %v", err)
+	}
+
+	return seatCounts, nil
+}
+
+// markSeatsReconciled records that a seat counter was just loaded from the database
+func (fs *FlightService) markSeatsReconciled(ctx context.Context, pipe redis.Pipeliner, flightID int, date string) {
+	pipe.Set(ctx, database.GenerateSeatReconciledCacheKey(flightID, date), time.Now().UTC().Format(time.RFC3339), 0)
+}
+
+// GetSeatAvailability reports the live seat counter for a flight date along with where it came
+// from, so cache and database values can be compared when investigating inventory discrepancies
+func (fs *FlightService) GetSeatAvailability(ctx context.Context, flightID int, date string) (*models.SeatAvailabilityResponse, error) {
+	query := `
+		SELECT COALESCE(i.total_seats, f.total_seats),
+		       COALESCE(i.total_seats - i.booked_seats, f.total_seats - f.booked_seats),
+		       i.updated_at
+		FROM flights f
+		LEFT JOIN flight_inventory i ON i.flight_id = f.id AND i.date = $2
+		WHERE f.id = $1 AND DATE(f.departure_time) = $2
+	`
+
+	response := &models.SeatAvailabilityResponse{
+		FlightID: flightID,
+		Date:     date,
+	}
+
+	var inventoryUpdatedAt sql.NullTime
+	err := fs.db.QueryRowContext(ctx, query, flightID, date).Scan(
+		&response.TotalSeats, &response.DatabaseAvailable, &inventoryUpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrFlightNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query seat inventory: %w", err)
+	}
+	if inventoryUpdatedAt.Valid {
+		response.InventoryUpdatedAt = &inventoryUpdatedAt.Time
+	}
+	if fs.seatEvents {
+		_, projected, _, err := fs.projectSeats(ctx, flightID, date)
+		if err != nil {
+			return nil, err
+		}
+		response.DatabaseAvailable = projected
+	}
+
+	cacheKey := database.GenerateSeatCacheKey(flightID, date)
+	pipe := fs.cache.Pipeline()
+	counterCmd := pipe.Get(ctx, cacheKey)
+	ttlCmd := pipe.TTL(ctx, cacheKey)
+	reconciledCmd := pipe.Get(ctx, database.GenerateSeatReconciledCacheKey(flightID, date))
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to read seat counter: %w", err)
+	}
+
+	counter, err := counterCmd.Int()
+	if err != nil {
+		// No live counter; the next read will load it from the database
+		response.Available = response.DatabaseAvailable
+		response.Source = models.SeatSourceDatabase
+		return response, nil
+	}
+
+	response.Available = counter
+	response.Source = models.SeatSourceCache
+	response.Drift = counter - response.DatabaseAvailable
+	if ttl := ttlCmd.Val(); ttl > 0 {
+		seconds := int64(ttl.Seconds())
+		response.TTLSeconds = &seconds
+	}
+	if reconciledAt, err := time.Parse(time.RFC3339, reconciledCmd.Val()); err == nil {
+		response.LastReconciledAt = &reconciledAt
+	}
+
+	return response, nil
 }
 
-// ValidateFlight validates if a flight can be booked
-func (fs *FlightService) ValidateFlight(ctx context.Context, flightID, seats int, date string) (*models.FlightValidationResponse, error) {
+// ValidateFlight validates if a flight can be booked, with a signed quote of the total for the user
+func (fs *FlightService) ValidateFlight(ctx context.Context, flightID, seats int, date string, userID int) (*models.FlightValidationResponse, error) {
 	// Get flight details
 	query := `
 		SELECT id, flight_number, source, destination, departure_time, arrival_time,
-		       total_seats, booked_seats, price, created_at
+		       total_seats, booked_seats, price, created_at, status
 		FROM flights 
 		WHERE id = $1
 	`
@@ -185,7 +560,7 @@ func (fs *FlightService) ValidateFlight(ctx context.Context, flightID, seats int
 	err := fs.db.QueryRowContext(ctx, query, flightID).Scan(
 		&flight.ID, &flight.FlightNumber, &flight.Source, &flight.Destination,
 		&flight.DepartureTime, &flight.ArrivalTime, &flight.TotalSeats,
-		&flight.BookedSeats, &flight.Price, &flight.CreatedAt,
+		&flight.BookedSeats, &flight.Price, &flight.CreatedAt, &flight.Status,
 	)
 
 	if err != nil {
@@ -198,6 +573,13 @@ func (fs *FlightService) ValidateFlight(ctx context.Context, flightID, seats int
 		return nil, fmt.Errorf("failed to query flight: %w", err)
 	}
 
+	if flight.Status == models.FlightStatusCancelled {
+		return &models.FlightValidationResponse{
+			Valid:   false,
+			Message: "Flight has been cancelled",
+		}, nil
+	}
+
 	// Get available seats from cache
 	availableSeats, err := fs.getAvailableSeats(ctx, flightID, date)
 	if err != nil {
@@ -206,21 +588,95 @@ func (fs *FlightService) ValidateFlight(ctx context.Context, flightID, seats int
 
 	canBook := availableSeats >= seats
 
+	// Price in the user's pricing variant, so the booking charges what search showed
+	flight.Price = experimentPrice(flight.Price, fs.priceMultiplier(ctx))
+
+	flights := []models.Flight{flight}
+	fs.enrichFlights(ctx, flights)
+
+	// Taxes by the route's jurisdiction are charged on top of the fare
+	baseFare := flight.Price.Mul(seats)
+	taxes, total := fs.taxes.Apply(baseFare, fs.routeJurisdiction(ctx, flight.Source, flight.Destination))
+
 	response := &models.FlightValidationResponse{
-		Valid:     canBook,
-		Price:     flight.Price * float64(seats),
-		Available: availableSeats,
+		Valid:       canBook,
+		Price:       total,
+		Available:   availableSeats,
+		Flight:      &flights[0],
+		Experiments: experiments.FromContext(ctx).Tags(),
+		Taxes:       taxes,
+	}
+	if len(taxes) > 0 {
+		response.BaseFare = &baseFare
 	}
 
-	if !canBook {
+	if canBook {
+		response.Quote = fs.quotes.Sign(flightID, date, seats, userID, response.Price)
+		response.SeatNonce = fs.seatNonces.Issue(models.SeatEventReserve, flightID, date, models.CabinEconomy, seats)
+	} else {
 		response.Message = fmt.Sprintf("Not enough seats available. Requested: %d, Available: %d", seats, availableSeats)
 	}
 
 	return response, nil
 }
 
-// DecrementSeats decrements available seats in cache (atomic operation)
-func (fs *FlightService) DecrementSeats(ctx context.Context, flightID int, seats int, date string) error {
+// SpendSeatNonce checks and spends the nonce of a seat decrement (models.SeatEventReserve)
+// or increment (models.SeatEventRelease), so the same request cannot be replayed
+func (fs *FlightService) SpendSeatNonce(ctx context.Context, operation string, req *models.SeatUpdateRequest) error {
+	return fs.seatNonces.Spend(ctx, operation, req)
+}
+
+// IssueReleaseNonce returns a nonce for giving up to seats seats back to a flight date's
+// cabin (empty for economy), or nil when seat nonces are disabled
+func (fs *FlightService) IssueReleaseNonce(flightID int, date, cabin string, seats int) *models.SeatNonce {
+	return fs.seatNonces.Issue(models.SeatEventRelease, flightID, date, cabin, seats)
+}
+
+// LookupFlights finds flights by flight number on a date, including the operating airline
+func (fs *FlightService) LookupFlights(ctx context.Context, flightNumber, date string) ([]models.Flight, error) {
+	query := `
+		SELECT f.id, f.flight_number, f.source, f.destination, f.departure_time, f.arrival_time,
+		       f.total_seats, f.booked_seats, f.price, f.created_at, a.code, a.name
+		FROM flights f
+		LEFT JOIN airlines a ON a.code = f.airline_code
+		WHERE f.flight_number = $1 AND DATE(f.departure_time) = $2
+		ORDER BY f.departure_time
+	`
+
+	rows, err := fs.db.QueryContext(ctx, query, flightNumber, date)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query flights: %w", err)
+	}
+	defer rows.Close()
+
+	var flights []models.Flight
+	for rows.Next() {
+		var flight models.Flight
+		var airlineCode, airlineName sql.NullString
+		err := rows.Scan(
+			&flight.ID, &flight.FlightNumber, &flight.Source, &flight.Destination,
+			&flight.DepartureTime, &flight.ArrivalTime, &flight.TotalSeats,
+			&flight.BookedSeats, &flight.Price, &flight.CreatedAt, &airlineCode, &airlineName,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan flight: %w", err)
+		}
+		if airlineCode.Valid {
+			flight.Airline = &models.Airline{Code: airlineCode.String, Name: airlineName.String}
+		}
+		flights = append(flights, flight)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read flights: %w", err)
+	}
+
+	fs.enrichFlights(ctx, flights)
+	return flights, nil
+}
+
+// DecrementSeats decrements available seats in cache (atomic operation). With seat event
+// sourcing the reservation is also recorded, and undone if it cannot be.
+func (fs *FlightService) DecrementSeats(ctx context.Context, flightID int, seats int, date, reason string) error {
 	cacheKey := database.GenerateSeatCacheKey(flightID, date)
 
 	// Use Lua script for atomic decrement with validation
@@ -248,70 +704,206 @@ func (fs *FlightService) DecrementSeats(ctx context.Context, flightID int, seats
 			return fmt.Errorf("seat decrement failed: %v", resultMap[1])
 		}
 	}
+	// The script's {ok = n} reply arrives as a status string
+	available, _ := strconv.Atoi(fmt.Sprint(result))
+
+	if fs.seatEvents {
+		event := seatEvent{flightID: flightID, date: date, kind: models.SeatEventReserve, seats: -seats, reason: reason}
+		if err := fs.recordSeatEvents(ctx, event); err != nil {
+			if undoErr := fs.cache.IncrBy(ctx, cacheKey, int64(seats)).Err(); undoErr != nil {
+				log.Printf("Failed to undo unrecorded seat decrement for flight %d: %v", flightID, undoErr)
+			}
+			return err
+		}
+	}
 
 	log.Printf("Decremented %d seats for flight %d on %s", seats, flightID, date)
+	fs.cdn.Purge(cdn.FlightKey(flightID, date))
+	fs.publishSeatsEvent(ctx, models.EventSeatsReserved, flightID, seats, date, available)
 	return nil
 }
 
-// IncrementSeats increments available seats in cache (atomic operation)
-func (fs *FlightService) IncrementSeats(ctx context.Context, flightID int, seats int, date string) error {
+// reserveBatchScript decrements every seat counter only if all of them have enough seats.
+// KEYS are seat counter keys and ARGV the seats requested for each key, in the same order.
+const reserveBatchScript = `
+	local available = {}
+	for i, key in ipairs(KEYS) do
+		local current = redis.call('GET', key)
+		if not current then
+			return redis.error_reply('Seat count not found in cache for ' .. key)
+		end
+		current = tonumber(current)
+		if current < tonumber(ARGV[i]) then
+			return redis.error_reply('Not enough seats available for ' .. key)
+		end
+		available[i] = current - tonumber(ARGV[i])
+	end
+	for i, key in ipairs(KEYS) do
+		redis.call('DECRBY', key, ARGV[i])
+	end
+	return available
+`
+
+// ReserveSeatsBatch atomically reserves seats across several flights: either every
+// reservation succeeds or none of the counters change
+func (fs *FlightService) ReserveSeatsBatch(ctx context.Context, reservations []models.SeatUpdateRequest) ([]models.SeatReservationResult, error) {
+	// Merge repeated flight/date pairs so each counter is checked once
+	var keys, reasons []string
+	var merged []models.SeatReservationResult
+	index := make(map[string]int)
+	for _, r := range reservations {
+		key := database.GenerateSeatCacheKey(r.FlightID, r.Date)
+		if i, ok := index[key]; ok {
+			merged[i].Seats += r.Seats
+			continue
+		}
+		index[key] = len(keys)
+		keys = append(keys, key)
+		reasons = append(reasons, r.Reason)
+		merged = append(merged, models.SeatReservationResult{FlightID: r.FlightID, Date: r.Date, Seats: r.Seats})
+	}
+
+	// Make sure every counter is loaded before the script runs
+	args := make([]interface{}, len(merged))
+	for i, r := range merged {
+		if _, err := fs.getAvailableSeats(ctx, r.FlightID, r.Date); err != nil {
+			return nil, fmt.Errorf("failed to load seats for flight %d: %w", r.FlightID, err)
+		}
+		args[i] = r.Seats
+	}
+
+	result, err := fs.cache.Eval(ctx, reserveBatchScript, keys, args...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("batch seat reservation failed: %w", err)
+	}
+
+	remaining, ok := result.([]interface{})
+	if !ok || len(remaining) != len(merged) {
+		return nil, fmt.Errorf("unexpected batch reservation result: %v", result)
+	}
+
+	if fs.seatEvents {
+		seatEvents := make([]seatEvent, len(merged))
+		for i, r := range merged {
+			seatEvents[i] = seatEvent{flightID: r.FlightID, date: r.Date, kind: models.SeatEventReserve, seats: -r.Seats, reason: reasons[i]}
+		}
+		if err := fs.recordSeatEvents(ctx, seatEvents...); err != nil {
+			pipe := fs.cache.Pipeline()
+			for i, key := range keys {
+				pipe.IncrBy(ctx, key, int64(merged[i].Seats))
+			}
+			if _, undoErr := pipe.Exec(ctx); undoErr != nil {
+				log.Printf("Failed to undo unrecorded batch seat reservation: %v", undoErr)
+			}
+			return nil, err
+		}
+	}
+	for i := range merged {
+		available, _ := remaining[i].(int64)
+		merged[i].Available = int(available)
+		fs.cdn.Purge(cdn.FlightKey(merged[i].FlightID, merged[i].Date))
+		fs.publishSeatsEvent(ctx, models.EventSeatsReserved, merged[i].FlightID, merged[i].Seats, merged[i].Date, merged[i].Available)
+	}
+
+	log.Printf("Reserved seats on %d flights in one batch", len(merged))
+	return merged, nil
+}
+
+// IncrementSeats increments available seats in cache (atomic operation). With seat event
+// sourcing the release is recorded first; a counter that then fails to update is dropped so
+// it is replayed from the events on the next read.
+func (fs *FlightService) IncrementSeats(ctx context.Context, flightID int, seats int, date, reason string) error {
 	cacheKey := database.GenerateSeatCacheKey(flightID, date)
 
+	if fs.seatEvents {
+		event := seatEvent{flightID: flightID, date: date, kind: models.SeatEventRelease, seats: seats, reason: reason}
+		if err := fs.recordSeatEvents(ctx, event); err != nil {
+			return err
+		}
+	}
+
 	// Use atomic increment
-	if err := fs.cache.IncrBy(ctx, cacheKey, int64(seats)).Err(); err != nil {
+	available, err := fs.cache.IncrBy(ctx, cacheKey, int64(seats)).Result()
+	if err != nil {
+		if fs.seatEvents {
+			fs.cache.Delete(ctx, cacheKey)
+		}
 		return fmt.Errorf("failed to increment seats: %w", err)
 	}
 
 	log.Printf("Incremented %d seats for flight %d on %s", seats, flightID, date)
+	fs.cdn.Purge(cdn.FlightKey(flightID, date))
+	fs.publishSeatsEvent(ctx, models.EventSeatsReleased, flightID, seats, date, int(available))
 	return nil
 }
 
-// findFlightPaths finds all possible flight paths (direct and multi-stop)
-func (fs *FlightService) findFlightPaths(ctx context.Context, source, destination string, date time.Time, seats int) ([]models.FlightPath, error) {
+// findFlightPaths finds flight paths with up to maxStops connections, one number of stops
+// at a time, direct flights first. Deeper levels are skipped once enough of the paths found
+// are cheaper than any path with more stops could be.
+func (fs *FlightService) findFlightPaths(ctx context.Context, source, destination string, date time.Time, seats, maxStops int) ([]models.FlightPath, error) {
 	var paths []models.FlightPath
+	exit := &earlyExit{fs: fs, source: source, destination: destination, date: date, seats: seats}
 
-	// Find direct flights
-	directFlights, err := fs.findDirectFlights(ctx, source, destination, date, seats)
-	if err != nil {
-		return nil, err
-	}
+	for stops := 0; stops <= maxStops; stops++ {
+		levelPaths, err := fs.findPathsWithStops(ctx, source, destination, date, seats, stops)
+		if err != nil {
+			if stops == 0 {
+				return nil, err
+			}
+			log.Printf("Error finding %d-stop flights: %v", stops, err)
+			continue
+		}
+		searchDepthStats.Add("levels_searched", 1)
+		paths = append(paths, levelPaths...)
 
-	for _, flight := range directFlights {
-		path := models.FlightPath{
-			Flights: []models.Flight{flight},
+		if stops < maxStops && exit.canStopAfter(ctx, paths, stops) {
+			break
 		}
-		path.CalculateTotalPrice()
-		path.CalculateTotalTime()
-		path.CalculateStops()
-		paths = append(paths, path)
 	}
 
-	// Find multi-stop flights (up to 3 stops)
-	for stops := 1; stops <= 3; stops++ {
-		multiStopPaths, err := fs.findMultiStopFlights(ctx, source, destination, date, seats, stops)
+	return paths, nil
+}
+
+// findPathsWithStops finds the paths with exactly the given number of stops (0 for direct flights)
+func (fs *FlightService) findPathsWithStops(ctx context.Context, source, destination string, date time.Time, seats, stops int) ([]models.FlightPath, error) {
+	var paths []models.FlightPath
+	if stops == 0 {
+		directFlights, err := fs.findDirectFlights(ctx, source, destination, date, seats)
 		if err != nil {
-			log.Printf("Error finding %d-stop flights: %v", stops, err)
-			continue
+			return nil, err
+		}
+		for _, flight := range directFlights {
+			path := models.FlightPath{Flights: []models.Flight{flight}}
+			path.CalculateTotalPrice()
+			path.CalculateTotalTime()
+			path.CalculateStops()
+			paths = append(paths, path)
 		}
-		paths = append(paths, multiStopPaths...)
+		return paths, nil
 	}
 
+	// The query returns paths of up to this many legs; keep the longest ones
+	legs := stops + 1
+	if !fs.canReachWithin(source, destination, legs) {
+		return nil, nil
+	}
+	multiStopPaths, err := fs.findMultiStopFlights(ctx, source, destination, date, seats, legs)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range multiStopPaths {
+		if len(path.Flights) == legs {
+			paths = append(paths, path)
+		}
+	}
 	return paths, nil
 }
 
 // findDirectFlights finds direct flights between source and destination
 func (fs *FlightService) findDirectFlights(ctx context.Context, source, destination string, date time.Time, seats int) ([]models.Flight, error) {
-	query := `
-		SELECT id, flight_number, source, destination, departure_time, arrival_time, 
-		       total_seats, booked_seats, price, created_at
-		FROM flights 
-		WHERE source = $1 AND destination = $2 
-		  AND DATE(departure_time) = $3 
-		  AND (total_seats - booked_seats) >= $4
-		ORDER BY departure_time
-	`
+	fs.explainSearchQuery(ctx, "direct_flights", DirectFlightsQuery, source, destination, date, seats)
 
-	rows, err := fs.db.QueryContext(ctx, query, source, destination, date, seats)
+	rows, err := fs.db.QueryContext(ctx, DirectFlightsQuery, source, destination, date, seats)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query direct flights: %w", err)
 	}
@@ -331,13 +923,15 @@ func (fs *FlightService) findDirectFlights(ctx context.Context, source, destinat
 		flights = append(flights, flight)
 	}
 
+	fs.enrichFlights(ctx, flights)
 	return flights, nil
 }
 
 // findMultiStopFlights finds multi-stop flights using recursive CTE
 func (fs *FlightService) findMultiStopFlights(ctx context.Context, source, destination string, date time.Time, seats int, maxStops int) ([]models.FlightPath, error) {
 	// Build the recursive CTE query
-	query := fs.buildMultiStopQuery(maxStops)
+	query := MultiStopFlightsQuery(maxStops)
+	fs.explainSearchQuery(ctx, fmt.Sprintf("multi_stop_flights_%d", maxStops), query, source, destination, date, seats)
 
 	rows, err := fs.db.QueryContext(ctx, query, source, destination, date, seats)
 	if err != nil {
@@ -345,60 +939,38 @@ func (fs *FlightService) findMultiStopFlights(ctx context.Context, source, desti
 	}
 	defer rows.Close()
 
-	var paths []models.FlightPath
-	pathMap := make(map[string]models.FlightPath)
+	builder := getPathBuilder(maxStops)
+	defer builder.release()
 
+	// Each path is built once, from the first row it appears in
+	pathMap := make(map[string]models.FlightPath)
 	for rows.Next() {
-		var flightIDs []int
-		var flightNumbers []string
-		var sources []string
-		var destinations []string
-		var departureTimes []time.Time
-		var arrivalTimes []time.Time
-		var totalSeats []int
-		var bookedSeats []int
-		var prices []float64
-		var createdAt []time.Time
-
-		err := rows.Scan(
-			&flightIDs, &flightNumbers, &sources, &destinations,
-			&departureTimes, &arrivalTimes, &totalSeats, &bookedSeats,
-			&prices, &createdAt,
-		)
+		pathKey, err := builder.scan(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan multi-stop flight: %w", err)
 		}
-
-		// Build flight path
-		var flights []models.Flight
-		for i := range flightIDs {
-			flight := models.Flight{
-				ID:            flightIDs[i],
-				FlightNumber:  flightNumbers[i],
-				Source:        sources[i],
-				Destination:   destinations[i],
-				DepartureTime: departureTimes[i],
-				ArrivalTime:   arrivalTimes[i],
-				TotalSeats:    totalSeats[i],
-				BookedSeats:   bookedSeats[i],
-				Price:         prices[i],
-				CreatedAt:     createdAt[i],
-			}
-			flights = append(flights, flight)
+		if _, exists := pathMap[string(pathKey)]; exists {
+			continue
 		}
 
-		// Create unique key for this path
-		pathKey := fs.generatePathKey(flights)
-		if _, exists := pathMap[pathKey]; !exists {
-			path := models.FlightPath{Flights: flights}
-			path.CalculateTotalPrice()
-			path.CalculateTotalTime()
-			path.CalculateStops()
-			pathMap[pathKey] = path
+		flights, err := builder.flights()
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan multi-stop flight: %w", err)
 		}
+		fs.enrichFlights(ctx, flights)
+
+		path := models.FlightPath{Flights: flights}
+		path.CalculateTotalPrice()
+		path.CalculateTotalTime()
+		path.CalculateStops()
+		pathMap[string(pathKey)] = path
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read multi-stop flights: %w", err)
 	}
 
 	// Convert map to slice
+	paths := make([]models.FlightPath, 0, len(pathMap))
 	for _, path := range pathMap {
 		paths = append(paths, path)
 	}
@@ -406,89 +978,250 @@ func (fs *FlightService) findMultiStopFlights(ctx context.Context, source, desti
 	return paths, nil
 }
 
-// buildMultiStopQuery builds the recursive CTE query for multi-stop flights
-func (fs *FlightService) buildMultiStopQuery(maxStops int) string {
-	return fmt.Sprintf(`
-		WITH RECURSIVE flight_paths AS (
-			-- Base case: direct flights
-			SELECT 
-				id, flight_number, source, destination, departure_time, arrival_time,
-				total_seats, booked_seats, price, created_at,
-				1 as stops,
-				ARRAY[id] as flight_ids,
-				ARRAY[flight_number] as flight_numbers,
-				ARRAY[source] as sources,
-				ARRAY[destination] as destinations,
-				ARRAY[departure_time] as departure_times,
-				ARRAY[arrival_time] as arrival_times,
-				ARRAY[total_seats] as total_seats_array,
-				ARRAY[booked_seats] as booked_seats_array,
-				ARRAY[price] as prices,
-				ARRAY[created_at] as created_ats
-			FROM flights 
-			WHERE source = $1 AND DATE(departure_time) = $3
-			  AND (total_seats - booked_seats) >= $4
-			
-			UNION ALL
-			
-			-- Recursive case: add connecting flights
-			SELECT 
-				f.id, f.flight_number, f.source, f.destination, f.departure_time, f.arrival_time,
-				f.total_seats, f.booked_seats, f.price, f.created_at,
-				fp.stops + 1,
-				fp.flight_ids || f.id,
-				fp.flight_numbers || f.flight_number,
-				fp.sources || f.source,
-				fp.destinations || f.destination,
-				fp.departure_times || f.departure_time,
-				fp.arrival_times || f.arrival_time,
-				fp.total_seats_array || f.total_seats,
-				fp.booked_seats_array || f.booked_seats,
-				fp.prices || f.price,
-				fp.created_ats || f.created_at
-			FROM flight_paths fp
-			JOIN flights f ON fp.destinations[array_length(fp.destinations, 1)] = f.source
-			WHERE fp.stops < %d
-			  AND f.destination = $2
-			  AND DATE(f.departure_time) = $3
-			  AND (f.total_seats - f.booked_seats) >= $4
-			  AND f.departure_time > fp.arrival_times[array_length(fp.arrival_times, 1)]
-			  AND f.departure_time <= fp.arrival_times[array_length(fp.arrival_times, 1)] + INTERVAL '4 hours'
-		)
-		SELECT 
-			flight_ids, flight_numbers, sources, destinations,
-			departure_times, arrival_times, total_seats_array, booked_seats_array,
-			prices, created_ats
-		FROM flight_paths
-		WHERE destinations[array_length(destinations, 1)] = $2
-		ORDER BY stops, prices[1]
-	`, maxStops)
-}
-
-// generatePathKey generates a unique key for a flight path
-func (fs *FlightService) generatePathKey(flights []models.Flight) string {
-	var keys []string
-	for _, flight := range flights {
-		keys = append(keys, fmt.Sprintf("%d", flight.ID))
-	}
-	return strings.Join(keys, "-")
-}
-
 // sortFlightPaths sorts flight paths by the specified criteria
-func (fs *FlightService) sortFlightPaths(paths []models.FlightPath, sortBy string) {
+func (fs *FlightService) sortFlightPaths(ctx context.Context, paths []models.FlightPath, sortBy string) {
 	switch sortBy {
 	case "cheapest":
 		sort.Slice(paths, func(i, j int) bool {
-			return paths[i].TotalPrice < paths[j].TotalPrice
+			return paths[i].TotalPrice.Minor < paths[j].TotalPrice.Minor
 		})
 	case "fastest":
 		sort.Slice(paths, func(i, j int) bool {
 			return paths[i].TotalTime < paths[j].TotalTime
 		})
+	case "recommended":
+		sortByScore(paths, fs.rankingWeightsFor(ctx))
 	default:
 		// Default to cheapest
 		sort.Slice(paths, func(i, j int) bool {
-			return paths[i].TotalPrice < paths[j].TotalPrice
+			return paths[i].TotalPrice.Minor < paths[j].TotalPrice.Minor
 		})
 	}
 }
+
+// flightDateCapacity returns a flight date's seat capacity, from its inventory when it has one
+func (fs *FlightService) flightDateCapacity(ctx context.Context, flightID int, date string) (int, error) {
+	query := `
+		SELECT COALESCE(i.total_seats, f.total_seats)
+		FROM flights f
+		LEFT JOIN flight_inventory i ON i.flight_id = f.id AND i.date = $2
+		WHERE f.id = $1
+	`
+
+	var totalSeats int
+	err := fs.db.QueryRowContext(ctx, query, flightID, date).Scan(&totalSeats)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrFlightNotFound
+		}
+		return 0, fmt.Errorf("failed to query flight: %w", err)
+	}
+	return totalSeats, nil
+}
+
+// ApplyOccupancyEvent updates the booked seat counter for a flight from a booking event.
+// A zero delta, or one larger than the flight's capacity, is rejected with
+// ErrInvalidOccupancyDelta.
+func (fs *FlightService) ApplyOccupancyEvent(ctx context.Context, event *models.SeatOccupancyEvent) (*models.LoadFactorResponse, error) {
+	if event.Delta == 0 {
+		return nil, ErrInvalidOccupancyDelta
+	}
+	capacity, err := fs.flightDateCapacity(ctx, event.FlightID, event.Date)
+	if err != nil {
+		return nil, err
+	}
+	if event.Delta > capacity || -event.Delta > capacity {
+		return nil, fmt.Errorf("%w: %+d seats on a %d-seat flight", ErrInvalidOccupancyDelta, event.Delta, capacity)
+	}
+
+	// Deduplicate retried deliveries of the same event
+	dedupeID := event.Reason
+	if event.EventID != "" {
+		dedupeID = event.Reason + ":" + event.EventID
+	}
+	eventKey := database.GenerateOccupancyEventCacheKey(event.BookingID, dedupeID)
+	firstDelivery, err := fs.cache.SetNX(ctx, eventKey, event.Delta, 7*24*time.Hour).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to record occupancy event: %w", err)
+	}
+
+	loadKey := database.GenerateLoadFactorCacheKey(event.FlightID, event.Date)
+	if firstDelivery {
+		if err := fs.updateInventoryBooked(ctx, event.FlightID, event.Date, event.Delta); err != nil {
+			// Allow the event to be redelivered
+			fs.cache.Delete(ctx, eventKey)
+			return nil, err
+		}
+		if err := fs.cache.IncrBy(ctx, loadKey, int64(event.Delta)).Err(); err != nil {
+			log.Printf("Failed to update load factor counter: %v", err)
+		}
+		log.Printf("Applied occupancy event for flight %d on %s: %+d seats (%s)", event.FlightID, event.Date, event.Delta, event.Reason)
+	} else {
+		log.Printf("Ignoring duplicate occupancy event for booking %d (%s)", event.BookingID, event.Reason)
+	}
+
+	return fs.GetLoadFactor(ctx, event.FlightID, event.Date)
+}
+
+// GetLoadFactor returns the booked seats and load factor tracked for a flight on a date
+func (fs *FlightService) GetLoadFactor(ctx context.Context, flightID int, date string) (*models.LoadFactorResponse, error) {
+	totalSeats, err := fs.flightDateCapacity(ctx, flightID, date)
+	if err != nil {
+		return nil, err
+	}
+
+	bookedSeats, err := fs.cache.Get(ctx, database.GenerateLoadFactorCacheKey(flightID, date)).Int()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get booked seats: %w", err)
+	}
+
+	response := &models.LoadFactorResponse{
+		FlightID:    flightID,
+		Date:        date,
+		BookedSeats: bookedSeats,
+		TotalSeats:  totalSeats,
+	}
+	if totalSeats > 0 {
+		response.LoadFactor = float64(bookedSeats) / float64(totalSeats)
+	}
+
+	return response, nil
+}
+
+// RecalculateSeats recomputes the cached seat counter from confirmed bookings,
+// releasing any seats held by stuck or leaked reservations
+func (fs *FlightService) RecalculateSeats(ctx context.Context, flightID int, date, triggeredBy string) (*models.SeatRecalculationResponse, error) {
+	// Base capacity excludes seats sold outside this system (flights.booked_seats)
+	query := `
+		SELECT COALESCE(i.total_seats, f.total_seats) - f.booked_seats, f.booked_seats
+		FROM flights f
+		LEFT JOIN flight_inventory i ON i.flight_id = f.id AND i.date = $2
+		WHERE f.id = $1 AND DATE(f.departure_time) = $2
+	`
+
+	var baseSeats, externalBooked int
+	err := fs.db.QueryRowContext(ctx, query, flightID, date).Scan(&baseSeats, &externalBooked)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("flight not found")
+		}
+		return nil, fmt.Errorf("failed to query flight: %w", err)
+	}
+
+	confirmedSeats, err := fs.getConfirmedSeatsViaHTTP(ctx, flightID, date)
+	if err != nil {
+		return nil, err
+	}
+
+	// Persist the corrected booked count to the date-scoped inventory
+	if err := fs.setInventoryBooked(ctx, flightID, date, externalBooked+confirmedSeats); err != nil {
+		return nil, err
+	}
+
+	response := &models.SeatRecalculationResponse{
+		FlightID:       flightID,
+		Date:           date,
+		Available:      baseSeats - confirmedSeats,
+		ConfirmedSeats: confirmedSeats,
+		TriggeredBy:    triggeredBy,
+	}
+
+	// Record the correction so replaying seat events reaches the recalculated counter
+	if fs.seatEvents {
+		if err := ensureSeatOpeningBalance(ctx, fs.db, flightID, date); err != nil {
+			return nil, err
+		}
+		_, projected, _, err := fs.projectSeats(ctx, flightID, date)
+		if err != nil {
+			return nil, err
+		}
+		if adjustment := response.Available - projected; adjustment != 0 {
+			event := seatEvent{
+				flightID: flightID,
+				date:     date,
+				kind:     models.SeatEventAdjust,
+				seats:    adjustment,
+				reason:   models.SeatReasonRecalculation,
+				actor:    triggeredBy,
+			}
+			if err := fs.recordSeatEvents(ctx, event); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	previous, err := fs.replaceSeatCounter(ctx, flightID, date, response.Available)
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil {
+		response.PreviousAvailable = previous
+		response.Delta = response.Available - *previous
+	}
+
+	log.Printf("AUDIT: seat counter for flight %d on %s recalculated by %s: available=%d delta=%d",
+		flightID, date, triggeredBy, response.Available, response.Delta)
+	return response, nil
+}
+
+// getConfirmedSeatsViaHTTP gets the confirmed seat total via HTTP call to Booking Service
+func (fs *FlightService) getConfirmedSeatsViaHTTP(ctx context.Context, flightID int, date string) (int, error) {
+	confirmed, err := fs.bookings.GetConfirmedSeats(ctx, flightID, date)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get confirmed seats: %w", err)
+	}
+
+	return confirmed.ConfirmedSeats, nil
+}
+
+// ensureInventory creates the date-scoped inventory row for a flight from its flight row
+func (fs *FlightService) ensureInventory(ctx context.Context, flightID int, date string) error {
+	query := `
+		INSERT INTO flight_inventory (flight_id, date, total_seats, booked_seats)
+		SELECT id, DATE(departure_time), total_seats, booked_seats
+		FROM flights
+		WHERE id = $1 AND DATE(departure_time) = $2
+		ON CONFLICT (flight_id, date) DO NOTHING
+	`
+
+	if _, err := fs.db.ExecContext(ctx, query, flightID, date); err != nil {
+		return fmt.Errorf("failed to create inventory: %w", err)
+	}
+	return nil
+}
+
+// updateInventoryBooked adjusts the persisted booked seat count for a flight date
+func (fs *FlightService) updateInventoryBooked(ctx context.Context, flightID int, date string, delta int) error {
+	if err := fs.ensureInventory(ctx, flightID, date); err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE flight_inventory
+		SET booked_seats = GREATEST(booked_seats + $3, 0), updated_at = CURRENT_TIMESTAMP
+		WHERE flight_id = $1 AND date = $2
+	`
+
+	if _, err := fs.db.ExecContext(ctx, query, flightID, date, delta); err != nil {
+		return fmt.Errorf("failed to update inventory: %w", err)
+	}
+	return nil
+}
+
+// setInventoryBooked overwrites the persisted booked seat count for a flight date
+func (fs *FlightService) setInventoryBooked(ctx context.Context, flightID int, date string, booked int) error {
+	if err := fs.ensureInventory(ctx, flightID, date); err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE flight_inventory
+		SET booked_seats = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE flight_id = $1 AND date = $2
+	`
+
+	if _, err := fs.db.ExecContext(ctx, query, flightID, date, booked); err != nil {
+		return fmt.Errorf("failed to update inventory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/internal/funnel"
+	"cred_flights_booking/pkg/models"
+)
+
+// FunnelService aggregates funnel events into conversion reports
+type FunnelService struct {
+	db      *database.DB
+	tracker *funnel.Tracker
+}
+
+// NewFunnelService creates a new funnel service
+func NewFunnelService(db *database.DB, cache *database.RedisClient) *FunnelService {
+	return &FunnelService{
+		db:      db,
+		tracker: funnel.NewTracker(cache),
+	}
+}
+
+// Aggregate snapshots the funnel counts of a UTC day into funnel_reports, replacing any
+// earlier snapshot of the same day. It returns the number of routes reported.
+func (fs *FunnelService) Aggregate(ctx context.Context, day string) (int, error) {
+	counts, err := fs.tracker.Counts(ctx, day)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		INSERT INTO funnel_reports (day, route, searches, selections, booking_attempts, payments, confirmations, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		ON CONFLICT (day, route) DO UPDATE SET
+			searches = EXCLUDED.searches,
+			selections = EXCLUDED.selections,
+			booking_attempts = EXCLUDED.booking_attempts,
+			payments = EXCLUDED.payments,
+			confirmations = EXCLUDED.confirmations,
+			updated_at = EXCLUDED.updated_at
+	`
+	for route, stages := range counts {
+		_, err := fs.db.ExecContext(ctx, query, day, route,
+			stages[funnel.StageSearch], stages[funnel.StageSelection], stages[funnel.StageBookingAttempt],
+			stages[funnel.StagePayment], stages[funnel.StageConfirmation])
+		if err != nil {
+			return 0, fmt.Errorf("failed to save funnel report for %s on %s: %w", route, day, err)
+		}
+	}
+
+	return len(counts), nil
+}
+
+// AggregateRecent reports today and yesterday, so a day's final counts are captured by the
+// first run after midnight UTC
+func (fs *FunnelService) AggregateRecent(ctx context.Context) error {
+	now := time.Now().UTC()
+	for _, day := range []time.Time{now.AddDate(0, 0, -1), now} {
+		routes, err := fs.Aggregate(ctx, day.Format("2006-01-02"))
+		if err != nil {
+			return err
+		}
+		log.Printf("Funnel report for %s: %d routes", day.Format("2006-01-02"), routes)
+	}
+	return nil
+}
+
+// Report returns funnel reports for days in [from, to], optionally for one route
+func (fs *FunnelService) Report(ctx context.Context, from, to, route string) (*models.FunnelReportResponse, error) {
+	query := `
+		SELECT to_char(day, 'YYYY-MM-DD'), route, searches, selections, booking_attempts, payments, confirmations, updated_at
+		FROM funnel_reports
+		WHERE day BETWEEN $1 AND $2 AND ($3 = '' OR route = $3)
+		ORDER BY day, route
+	`
+
+	rows, err := fs.db.QueryContext(ctx, query, from, to, route)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query funnel reports: %w", err)
+	}
+	defer rows.Close()
+
+	response := &models.FunnelReportResponse{
+		From:    from,
+		To:      to,
+		Route:   route,
+		Reports: []models.FunnelReport{},
+		Daily:   []models.FunnelReport{},
+	}
+	for rows.Next() {
+		var report models.FunnelReport
+		err := rows.Scan(&report.Day, &report.Route, &report.Searches, &report.Selections,
+			&report.BookingAttempts, &report.Payments, &report.Confirmations, &report.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan funnel report: %w", err)
+		}
+		report.SetConversionRate()
+		response.Reports = append(response.Reports, report)
+
+		// Rows are ordered by day, so each day's total is the last daily entry
+		if n := len(response.Daily); n == 0 || response.Daily[n-1].Day != report.Day {
+			response.Daily = append(response.Daily, models.FunnelReport{Day: report.Day})
+		}
+		daily := &response.Daily[len(response.Daily)-1]
+		daily.Searches += report.Searches
+		daily.Selections += report.Selections
+		daily.BookingAttempts += report.BookingAttempts
+		daily.Payments += report.Payments
+		daily.Confirmations += report.Confirmations
+		if report.UpdatedAt.After(daily.UpdatedAt) {
+			daily.UpdatedAt = report.UpdatedAt
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read funnel reports: %w", err)
+	}
+
+	for i := range response.Daily {
+		response.Daily[i].SetConversionRate()
+	}
+	return response, nil
+}
@@ -0,0 +1,312 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/pkg/models"
+	"github.com/go-redis/redis/v8"
+)
+
+var (
+	// ErrInvalidAPIKey is returned when an API key is unknown or its partner is inactive
+	ErrInvalidAPIKey = errors.New("invalid API key")
+	// ErrPartnerNotFound is returned when a partner does not exist
+	ErrPartnerNotFound = errors.New("partner not found")
+	// ErrRateLimited is returned when a partner exceeds its per-minute request limit
+	ErrRateLimited = errors.New("rate limit exceeded")
+	// ErrQuotaExceeded is returned when a partner has used its daily quota
+	ErrQuotaExceeded = errors.New("daily quota exceeded")
+)
+
+// partnerUsageTTL is how long daily usage hashes are kept for reporting
+const partnerUsageTTL = 90 * 24 * time.Hour
+
+// maxUsageReportDays bounds the date range of a usage report
+const maxUsageReportDays = 31
+
+// admitPartnerScript counts a request against a partner's per-minute limit and daily quota.
+// KEYS: rate counter, daily usage hash. ARGV: per-minute limit, daily quota, endpoint, usage TTL seconds.
+// Returns {status, requests this minute, requests today}: 1 admitted, -1 rate limited, -2 quota exceeded.
+const admitPartnerScript = `
+local rate = redis.call('INCR', KEYS[1])
+if rate == 1 then
+	redis.call('EXPIRE', KEYS[1], 60)
+end
+
+local status = 1
+local used = tonumber(redis.call('HGET', KEYS[2], 'total') or '0')
+if rate > tonumber(ARGV[1]) then
+	status = -1
+elseif used >= tonumber(ARGV[2]) then
+	status = -2
+end
+
+if status == 1 then
+	used = redis.call('HINCRBY', KEYS[2], 'total', 1)
+	redis.call('HINCRBY', KEYS[2], 'endpoint:' .. ARGV[3], 1)
+else
+	redis.call('HINCRBY', KEYS[2], 'rejected', 1)
+end
+redis.call('EXPIRE', KEYS[2], ARGV[4])
+
+return {status, rate, used}
+`
+
+// PartnerAdmission reports a partner's remaining allowance after a request
+type PartnerAdmission struct {
+	MinuteRemaining int
+	DailyRemaining  int
+	RetryAfter      time.Duration // Set when the request was refused
+}
+
+// PartnerService manages partner API keys, quotas, and usage metering
+type PartnerService struct {
+	db    *database.DB
+	cache *database.RedisClient
+}
+
+// NewPartnerService creates a new partner service
+func NewPartnerService(db *database.DB, cache *database.RedisClient) *PartnerService {
+	return &PartnerService{
+		db:    db,
+		cache: cache,
+	}
+}
+
+// hashAPIKey returns the stored form of an API key
+func hashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreatePartner registers a partner and returns its newly generated API key
+func (ps *PartnerService) CreatePartner(ctx context.Context, req *models.PartnerRequest) (*models.PartnerCreatedResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	apiKey := "pk_" + hex.EncodeToString(secret)
+
+	partner := models.Partner{
+		Name:              req.Name,
+		KeyPrefix:         apiKey[:10],
+		Scopes:            req.Scopes,
+		RequestsPerMinute: req.RequestsPerMinute,
+		DailyQuota:        req.DailyQuota,
+	}
+
+	query := `
+		INSERT INTO partners (name, key_hash, key_prefix, scopes, requests_per_minute, daily_quota)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, active, created_at
+	`
+	err := ps.db.QueryRowContext(ctx, query, partner.Name, hashAPIKey(apiKey), partner.KeyPrefix,
+		strings.Join(partner.Scopes, ","), partner.RequestsPerMinute, partner.DailyQuota,
+	).Scan(&partner.ID, &partner.Active, &partner.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create partner: %w", err)
+	}
+
+	return &models.PartnerCreatedResponse{Partner: partner, APIKey: apiKey}, nil
+}
+
+// partnerColumns lists the partner columns scanned by scanPartner
+const partnerColumns = `id, name, key_prefix, scopes, requests_per_minute, daily_quota, active, created_at`
+
+// scanPartner scans a row selected with partnerColumns
+func scanPartner(row rowScanner) (*models.Partner, error) {
+	var partner models.Partner
+	var scopes string
+	err := row.Scan(&partner.ID, &partner.Name, &partner.KeyPrefix, &scopes,
+		&partner.RequestsPerMinute, &partner.DailyQuota, &partner.Active, &partner.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	partner.Scopes = strings.Split(scopes, ",")
+	return &partner, nil
+}
+
+// ListPartners returns all registered partners
+func (ps *PartnerService) ListPartners(ctx context.Context) ([]models.Partner, error) {
+	rows, err := ps.db.QueryContext(ctx, `SELECT `+partnerColumns+` FROM partners ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query partners: %w", err)
+	}
+	defer rows.Close()
+
+	partners := []models.Partner{}
+	for rows.Next() {
+		partner, err := scanPartner(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan partner: %w", err)
+		}
+		partners = append(partners, *partner)
+	}
+	return partners, rows.Err()
+}
+
+// GetPartner returns a partner by ID
+func (ps *PartnerService) GetPartner(ctx context.Context, partnerID int) (*models.Partner, error) {
+	partner, err := scanPartner(ps.db.QueryRowContext(ctx, `SELECT `+partnerColumns+` FROM partners WHERE id = $1`, partnerID))
+	if err == sql.ErrNoRows {
+		return nil, ErrPartnerNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query partner: %w", err)
+	}
+	return partner, nil
+}
+
+// Authenticate resolves an API key to its active partner
+func (ps *PartnerService) Authenticate(ctx context.Context, apiKey string) (*models.Partner, error) {
+	if apiKey == "" {
+		return nil, ErrInvalidAPIKey
+	}
+
+	keyHash := hashAPIKey(apiKey)
+	cacheKey := database.GeneratePartnerKeyCacheKey(keyHash)
+
+	var partner *models.Partner
+	var cached models.Partner
+	if err := ps.cache.GetJSON(ctx, cacheKey, &cached); err == nil {
+		partner = &cached
+	} else {
+		found, err := scanPartner(ps.db.QueryRowContext(ctx,
+			`SELECT `+partnerColumns+` FROM partners WHERE key_hash = $1`, keyHash))
+		if err == sql.ErrNoRows {
+			return nil, ErrInvalidAPIKey
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to query partner: %w", err)
+		}
+		partner = found
+
+		if err := ps.cache.SetJSON(ctx, cacheKey, partner, 5*time.Minute); err != nil {
+			log.Printf("Failed to cache partner %d: %v", partner.ID, err)
+		}
+	}
+
+	if !partner.Active {
+		return nil, ErrInvalidAPIKey
+	}
+	return partner, nil
+}
+
+// Admit meters a partner request to an endpoint against its per-minute limit and daily quota
+func (ps *PartnerService) Admit(ctx context.Context, partner *models.Partner, endpoint string) (*PartnerAdmission, error) {
+	now := time.Now().UTC()
+	keys := []string{
+		database.GeneratePartnerRateKey(partner.ID, now.Unix()/60),
+		database.GeneratePartnerUsageKey(partner.ID, now.Format("2006-01-02")),
+	}
+
+	result, err := ps.cache.Eval(ctx, admitPartnerScript, keys,
+		partner.RequestsPerMinute, partner.DailyQuota, endpoint, int(partnerUsageTTL.Seconds())).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to meter partner request: %w", err)
+	}
+
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 3 {
+		return nil, fmt.Errorf("unexpected metering result: %v", result)
+	}
+	status, _ := values[0].(int64)
+	rate, _ := values[1].(int64)
+	used, _ := values[2].(int64)
+
+	admission := &PartnerAdmission{
+		MinuteRemaining: max(partner.RequestsPerMinute-int(rate), 0),
+		DailyRemaining:  max(partner.DailyQuota-int(used), 0),
+	}
+
+	switch status {
+	case -1:
+		admission.RetryAfter = time.Duration(60-now.Second()) * time.Second
+		return admission, ErrRateLimited
+	case -2:
+		admission.RetryAfter = now.Truncate(24 * time.Hour).Add(24 * time.Hour).Sub(now)
+		return admission, ErrQuotaExceeded
+	}
+	return admission, nil
+}
+
+// GetUsage reports a partner's metered usage per day between from and to (inclusive, YYYY-MM-DD UTC)
+func (ps *PartnerService) GetUsage(ctx context.Context, partnerID int, from, to string) (*models.PartnerUsageReport, error) {
+	partner, err := ps.GetPartner(ctx, partnerID)
+	if err != nil {
+		return nil, err
+	}
+
+	start, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from date: %w", err)
+	}
+	end, err := time.Parse("2006-01-02", to)
+	if err != nil {
+		return nil, fmt.Errorf("invalid to date: %w", err)
+	}
+	if end.Before(start) || end.Sub(start) >= maxUsageReportDays*24*time.Hour {
+		return nil, fmt.Errorf("date range must be between 1 and %d days", maxUsageReportDays)
+	}
+
+	// Read every day's usage hash in one round trip
+	var dates []string
+	pipe := ps.cache.Pipeline()
+	var cmds []*redis.StringStringMapCmd
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		dates = append(dates, date)
+		cmds = append(cmds, pipe.HGetAll(ctx, database.GeneratePartnerUsageKey(partnerID, date)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to read partner usage: %w", err)
+	}
+
+	report := &models.PartnerUsageReport{
+		PartnerID:  partner.ID,
+		Name:       partner.Name,
+		DailyQuota: partner.DailyQuota,
+		From:       from,
+		To:         to,
+		Days:       make([]models.PartnerUsageDay, 0, len(dates)),
+	}
+	for i, cmd := range cmds {
+		day := models.PartnerUsageDay{
+			Date:       dates[i],
+			ByEndpoint: make(map[string]int64),
+		}
+		for field, value := range cmd.Val() {
+			count, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			switch {
+			case field == "total":
+				day.Requests = count
+			case field == "rejected":
+				day.Rejected = count
+			case strings.HasPrefix(field, "endpoint:"):
+				day.ByEndpoint[strings.TrimPrefix(field, "endpoint:")] = count
+			}
+		}
+		report.Total += day.Requests
+		report.Days = append(report.Days, day)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"cred_flights_booking/pkg/models"
+	"github.com/lib/pq"
+)
+
+// Columns of a multi-stop search row, each an array with one element per leg
+const (
+	colFlightIDs = iota
+	colFlightNumbers
+	colSources
+	colDestinations
+	colDepartureTimes
+	colArrivalTimes
+	colTotalSeats
+	colBookedSeats
+	colPrices
+	colCreatedAt
+	multiStopColumns
+)
+
+// maxInternedCodes bounds the airport codes a path builder shares across rows
+const maxInternedCodes = 4096
+
+// pathBuilder turns multi-stop search rows into flight paths. Rows are scanned as raw
+// array text into reused buffers and split in place, so the only allocations per row are
+// the path's flights and their strings. Builders are pooled across searches.
+type pathBuilder struct {
+	raw   [multiStopColumns]sql.RawBytes
+	dest  [multiStopColumns]interface{}
+	elems [multiStopColumns][][]byte // Elements of each column, pointing into raw
+	key   []byte                     // Path key of the current row
+
+	codes map[string]string // Interned airport codes, shared by every path of a search
+}
+
+// pathBuilders holds idle path builders
+var pathBuilders = sync.Pool{
+	New: func() interface{} {
+		b := &pathBuilder{codes: make(map[string]string)}
+		for i := range b.raw {
+			b.dest[i] = &b.raw[i]
+		}
+		return b
+	},
+}
+
+// getPathBuilder takes a builder with element buffers sized for paths of up to maxLegs legs
+func getPathBuilder(maxLegs int) *pathBuilder {
+	b := pathBuilders.Get().(*pathBuilder)
+	for i := range b.elems {
+		if cap(b.elems[i]) < maxLegs {
+			b.elems[i] = make([][]byte, 0, maxLegs)
+		}
+	}
+	return b
+}
+
+// release returns the builder to the pool, dropping its references into the driver's buffers
+func (b *pathBuilder) release() {
+	for i := range b.raw {
+		b.raw[i] = nil
+	}
+	for i := range b.elems {
+		clear(b.elems[i][:cap(b.elems[i])])
+		b.elems[i] = b.elems[i][:0]
+	}
+	if len(b.codes) > maxInternedCodes {
+		clear(b.codes)
+	}
+	pathBuilders.Put(b)
+}
+
+// scan reads the current row and returns its path key ("id-id-..."), valid until the next scan
+func (b *pathBuilder) scan(rows *sql.Rows) ([]byte, error) {
+	if err := rows.Scan(b.dest[:]...); err != nil {
+		return nil, err
+	}
+
+	for i := range b.raw {
+		elems, err := splitArray(b.elems[i][:0], b.raw[i])
+		if err != nil {
+			return nil, fmt.Errorf("column %d: %w", i, err)
+		}
+		b.elems[i] = elems
+	}
+
+	legs := len(b.elems[colFlightIDs])
+	for i := range b.elems {
+		if len(b.elems[i]) != legs {
+			return nil, fmt.Errorf("column %d has %d elements, expected %d", i, len(b.elems[i]), legs)
+		}
+	}
+
+	b.key = b.key[:0]
+	for i, id := range b.elems[colFlightIDs] {
+		if i > 0 {
+			b.key = append(b.key, '-')
+		}
+		b.key = append(b.key, id...)
+	}
+	return b.key, nil
+}
+
+// flights builds the legs of the scanned row
+func (b *pathBuilder) flights() ([]models.Flight, error) {
+	legs := len(b.elems[colFlightIDs])
+	flights := make([]models.Flight, legs)
+	for i := range flights {
+		f := &flights[i]
+
+		var err error
+		if f.ID, err = parseArrayInt(b.elems[colFlightIDs][i]); err != nil {
+			return nil, fmt.Errorf("invalid flight ID: %w", err)
+		}
+		if f.TotalSeats, err = parseArrayInt(b.elems[colTotalSeats][i]); err != nil {
+			return nil, fmt.Errorf("invalid total seats: %w", err)
+		}
+		if f.BookedSeats, err = parseArrayInt(b.elems[colBookedSeats][i]); err != nil {
+			return nil, fmt.Errorf("invalid booked seats: %w", err)
+		}
+		if f.DepartureTime, err = parseArrayTime(b.elems[colDepartureTimes][i]); err != nil {
+			return nil, fmt.Errorf("invalid departure time: %w", err)
+		}
+		if f.ArrivalTime, err = parseArrayTime(b.elems[colArrivalTimes][i]); err != nil {
+			return nil, fmt.Errorf("invalid arrival time: %w", err)
+		}
+		if f.CreatedAt, err = parseArrayTime(b.elems[colCreatedAt][i]); err != nil {
+			return nil, fmt.Errorf("invalid created at: %w", err)
+		}
+		if f.Price, err = models.ParseMoney(string(b.elems[colPrices][i])); err != nil {
+			return nil, err
+		}
+
+		f.FlightNumber = string(b.elems[colFlightNumbers][i])
+		f.Source = b.intern(b.elems[colSources][i])
+		f.Destination = b.intern(b.elems[colDestinations][i])
+	}
+	return flights, nil
+}
+
+// intern returns the shared string of an airport code
+func (b *pathBuilder) intern(code []byte) string {
+	if s, ok := b.codes[string(code)]; ok {
+		return s
+	}
+	s := string(code)
+	b.codes[s] = s
+	return s
+}
+
+// splitArray appends the elements of a one-dimensional PostgreSQL array in text form
+// ({a,"b c",d}) to dst. Quoted elements are unescaped in place, so src is modified and the
+// elements point into it.
+func splitArray(dst [][]byte, src []byte) ([][]byte, error) {
+	if len(src) < 2 || src[0] != '{' || src[len(src)-1] != '}' {
+		return nil, fmt.Errorf("malformed array %q", src)
+	}
+	body := src[1 : len(src)-1]
+	if len(body) == 0 {
+		return dst, nil
+	}
+
+	for i := 0; i <= len(body); {
+		if i < len(body) && body[i] == '"' {
+			// Quoted: copy down over backslashes until the closing quote
+			start, w := i+1, i+1
+			i++
+			for ; i < len(body) && body[i] != '"'; i++ {
+				if body[i] == '\\' && i+1 < len(body) {
+					i++
+				}
+				body[w] = body[i]
+				w++
+			}
+			if i == len(body) {
+				return nil, fmt.Errorf("unterminated quote in array %q", src)
+			}
+			dst = append(dst, body[start:w])
+			i++ // Closing quote
+		} else {
+			start := i
+			for i < len(body) && body[i] != ',' {
+				i++
+			}
+			elem := body[start:i]
+			if string(elem) == "NULL" {
+				return nil, fmt.Errorf("unexpected NULL in array %q", src)
+			}
+			dst = append(dst, elem)
+		}
+
+		if i < len(body) && body[i] != ',' {
+			return nil, fmt.Errorf("malformed array %q", src)
+		}
+		i++ // Separator, or past the end
+	}
+	return dst, nil
+}
+
+// parseArrayInt parses a decimal integer array element
+func parseArrayInt(elem []byte) (int, error) {
+	digits := elem
+	if len(digits) > 0 && digits[0] == '-' {
+		digits = digits[1:]
+	}
+	if len(digits) == 0 || len(digits) > 18 {
+		return strconv.Atoi(string(elem))
+	}
+
+	n := 0
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid integer %q", elem)
+		}
+		n = n*10 + int(c-'0')
+	}
+	if len(digits) < len(elem) {
+		n = -n
+	}
+	return n, nil
+}
+
+// parseArrayTime parses a timestamp array element as the driver parses a timestamp column
+func parseArrayTime(elem []byte) (time.Time, error) {
+	return pq.ParseTimestamp(nil, string(elem))
+}
@@ -0,0 +1,364 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/internal/events"
+	"cred_flights_booking/pkg/models"
+	"github.com/go-redis/redis/v8"
+)
+
+// Authorization errors
+var (
+	ErrAuthorizationNotFound = errors.New("payment authorization not found")
+	ErrAuthorizationVoided   = errors.New("payment authorization was voided or refunded")
+)
+
+// Payment audit log events of authorizations
+const (
+	AuditEventPaymentCaptured = "payment.captured"
+	AuditEventPaymentVoided   = "payment.voided"
+	AuditEventPaymentRefunded = "payment.refunded"
+)
+
+// authorizationStats exposes payment authorization counters (authorized, captured, voided,
+// refunded)
+var authorizationStats = expvar.NewMap("payment_authorizations")
+
+// authorizationVoidBatch is the most expired authorizations read at once by the void job
+const authorizationVoidBatch = 100
+
+// authorizationRetention is how long a captured or voided authorization can still be looked up
+const authorizationRetention = 7 * 24 * time.Hour
+
+// PaymentCaptureDeadline returns how long a successful payment's authorization waits to be
+// captured before it is voided
+func PaymentCaptureDeadline() time.Duration {
+	return config.GetDuration("PAYMENT_CAPTURE_DEADLINE", 15*time.Minute)
+}
+
+// authorize records a successful payment's authorization and its capture deadline, and
+// sets the deadline on the response
+func (ps *PaymentService) authorize(ctx context.Context, req *models.PaymentRequest, response *models.PaymentResponse) error {
+	auth := &models.PaymentAuthorization{
+		PaymentID:    response.PaymentID,
+		BookingID:    req.BookingID,
+		UserID:       req.UserID,
+		Amount:       response.Amount,
+		Status:       models.AuthorizationStatusAuthorized,
+		AuthorizedAt: response.ProcessedAt,
+		CaptureBy:    response.ProcessedAt.Add(ps.captureDeadline),
+	}
+	if err := ps.saveAuthorization(ctx, auth); err != nil {
+		return err
+	}
+
+	err := ps.cache.ZAdd(ctx, database.GeneratePaymentAuthorizationDeadlinesKey(), &redis.Z{
+		Score:  float64(auth.CaptureBy.Unix()),
+		Member: auth.PaymentID,
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to schedule authorization deadline: %w", err)
+	}
+
+	authorizationStats.Add("authorized", 1)
+	response.CaptureBy = &auth.CaptureBy
+	return nil
+}
+
+// saveAuthorization stores an authorization, kept for authorizationRetention past its deadline
+func (ps *PaymentService) saveAuthorization(ctx context.Context, auth *models.PaymentAuthorization) error {
+	ttl := time.Until(auth.CaptureBy) + authorizationRetention
+	if err := ps.cache.SetJSON(ctx, database.GeneratePaymentAuthorizationKey(auth.PaymentID), auth, ttl); err != nil {
+		return fmt.Errorf("failed to store authorization %s: %w", auth.PaymentID, err)
+	}
+	return nil
+}
+
+// getAuthorization loads a payment's authorization
+func (ps *PaymentService) getAuthorization(ctx context.Context, paymentID string) (*models.PaymentAuthorization, error) {
+	payload, err := ps.cache.GetPayload(ctx, database.GeneratePaymentAuthorizationKey(paymentID))
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrAuthorizationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load authorization %s: %w", paymentID, err)
+	}
+
+	var auth models.PaymentAuthorization
+	if err := json.Unmarshal(payload, &auth); err != nil {
+		return nil, fmt.Errorf("failed to decode authorization %s: %w", paymentID, err)
+	}
+	return &auth, nil
+}
+
+// claimAuthorization removes an authorization from the deadlines set. Capture and void
+// both claim it first, so only one of them can settle it.
+func (ps *PaymentService) claimAuthorization(ctx context.Context, paymentID string) (bool, error) {
+	removed, err := ps.cache.ZRem(ctx, database.GeneratePaymentAuthorizationDeadlinesKey(), paymentID).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim authorization %s: %w", paymentID, err)
+	}
+	return removed > 0, nil
+}
+
+// CapturePayment captures a payment's authorization once its booking is stored. Capturing
+// again returns the captured authorization; a voided or refunded one can't be captured.
+func (ps *PaymentService) CapturePayment(ctx context.Context, paymentID string) (*models.PaymentAuthorization, error) {
+	auth, err := ps.getAuthorization(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	switch auth.Status {
+	case models.AuthorizationStatusCaptured:
+		return auth, nil
+	case models.AuthorizationStatusVoided, models.AuthorizationStatusRefunded:
+		return nil, ErrAuthorizationVoided
+	}
+
+	claimed, err := ps.claimAuthorization(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	// Settled since it was read: report how
+	if auth, err = ps.getAuthorization(ctx, paymentID); err != nil {
+		return nil, err
+	}
+	if auth.Status == models.AuthorizationStatusVoided || auth.Status == models.AuthorizationStatusRefunded {
+		if claimed && auth.Status == models.AuthorizationStatusVoided {
+			// The void job put it back to retry its event; leave it for the next run
+			ps.rescheduleVoid(ctx, auth)
+		}
+		return nil, ErrAuthorizationVoided
+	}
+	if !claimed {
+		if auth.Status == models.AuthorizationStatusCaptured {
+			return auth, nil
+		}
+		return nil, fmt.Errorf("authorization %s is not awaiting capture", paymentID)
+	}
+
+	now := time.Now()
+	auth.Status = models.AuthorizationStatusCaptured
+	auth.CapturedAt = &now
+	if err := ps.saveAuthorization(ctx, auth); err != nil {
+		return nil, err
+	}
+
+	authorizationStats.Add("captured", 1)
+	ps.recordAudit(AuditEventPaymentCaptured, &paymentAuditRecord{
+		BookingID: auth.BookingID,
+		UserID:    auth.UserID,
+		Amount:    auth.Amount,
+		PaymentID: auth.PaymentID,
+		Status:    auth.Status,
+	})
+	log.Printf("Captured payment %s for booking %d", auth.PaymentID, auth.BookingID)
+	return auth, nil
+}
+
+// ReversePayment returns a payment's funds in full for a booking that was rolled back: an
+// authorization not yet captured is voided, a captured one refunded. Reversing again returns
+// the voided or refunded authorization. Unlike voids at the capture deadline, no
+// payment.voided event is published, since the caller has already undone the booking.
+func (ps *PaymentService) ReversePayment(ctx context.Context, paymentID, reason string) (*models.PaymentAuthorization, error) {
+	auth, err := ps.getAuthorization(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	switch auth.Status {
+	case models.AuthorizationStatusVoided, models.AuthorizationStatusRefunded:
+		return auth, nil
+	case models.AuthorizationStatusCaptured:
+		return ps.refundPayment(ctx, auth, reason)
+	}
+
+	claimed, err := ps.claimAuthorization(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	// Settled since it was read: reverse what it became
+	if auth, err = ps.getAuthorization(ctx, paymentID); err != nil {
+		return nil, err
+	}
+	switch auth.Status {
+	case models.AuthorizationStatusVoided:
+		if claimed {
+			// The void job put it back to retry its event; leave it for the next run
+			ps.rescheduleVoid(ctx, auth)
+		}
+		return auth, nil
+	case models.AuthorizationStatusRefunded:
+		return auth, nil
+	case models.AuthorizationStatusCaptured:
+		return ps.refundPayment(ctx, auth, reason)
+	}
+	if !claimed {
+		return nil, fmt.Errorf("authorization %s is being settled", paymentID)
+	}
+
+	now := time.Now()
+	auth.Status = models.AuthorizationStatusVoided
+	auth.VoidedAt = &now
+	if err := ps.saveAuthorization(ctx, auth); err != nil {
+		ps.rescheduleVoid(ctx, auth)
+		return nil, err
+	}
+
+	authorizationStats.Add("voided", 1)
+	ps.recordAudit(AuditEventPaymentVoided, &paymentAuditRecord{
+		BookingID: auth.BookingID,
+		UserID:    auth.UserID,
+		Amount:    auth.Amount,
+		PaymentID: auth.PaymentID,
+		Status:    auth.Status,
+		Reason:    reason,
+	})
+	log.Printf("Voided payment %s for booking %d (%s)", auth.PaymentID, auth.BookingID, reason)
+	return auth, nil
+}
+
+// refundPayment refunds a captured payment in full. A refund key claimed first makes sure
+// concurrent reversals refund it only once.
+func (ps *PaymentService) refundPayment(ctx context.Context, auth *models.PaymentAuthorization, reason string) (*models.PaymentAuthorization, error) {
+	refundKey := database.GeneratePaymentRefundKey(auth.PaymentID)
+	claimed, err := ps.cache.SetNX(ctx, refundKey, 1, authorizationRetention).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim refund of %s: %w", auth.PaymentID, err)
+	}
+	if !claimed {
+		return ps.getAuthorization(ctx, auth.PaymentID)
+	}
+
+	now := time.Now()
+	auth.Status = models.AuthorizationStatusRefunded
+	auth.RefundedAt = &now
+	if err := ps.saveAuthorization(ctx, auth); err != nil {
+		// Let a retry refund it
+		ps.cache.Delete(ctx, refundKey)
+		return nil, err
+	}
+
+	authorizationStats.Add("refunded", 1)
+	ps.recordAudit(AuditEventPaymentRefunded, &paymentAuditRecord{
+		BookingID: auth.BookingID,
+		UserID:    auth.UserID,
+		Amount:    auth.Amount,
+		PaymentID: auth.PaymentID,
+		Status:    auth.Status,
+		Reason:    reason,
+	})
+	log.Printf("Refunded payment %s for booking %d (%s)", auth.PaymentID, auth.BookingID, reason)
+	return auth, nil
+}
+
+// VoidExpiredAuthorizations voids every authorization whose capture deadline has passed,
+// releasing the held funds, and publishes a payment.voided event for each so the booking
+// side can reconcile. Returns how many were voided.
+func (ps *PaymentService) VoidExpiredAuthorizations(ctx context.Context) (int, error) {
+	key := database.GeneratePaymentAuthorizationDeadlinesKey()
+	cutoff := strconv.FormatInt(time.Now().Unix(), 10)
+
+	voided := 0
+	for {
+		paymentIDs, err := ps.cache.ZRangeByScore(ctx, key, &redis.ZRangeBy{
+			Min:   "-inf",
+			Max:   cutoff,
+			Count: authorizationVoidBatch,
+		}).Result()
+		if err != nil {
+			return voided, fmt.Errorf("failed to read authorization deadlines: %w", err)
+		}
+		if len(paymentIDs) == 0 {
+			break
+		}
+
+		for _, paymentID := range paymentIDs {
+			ok, err := ps.voidAuthorization(ctx, paymentID)
+			if err != nil {
+				return voided, err
+			}
+			if ok {
+				voided++
+			}
+		}
+	}
+
+	if voided > 0 {
+		log.Printf("Voided %d uncaptured payment authorizations", voided)
+	}
+	return voided, nil
+}
+
+// voidAuthorization voids one expired authorization and publishes its event, reporting
+// whether it was voided now. When the event can't be published the authorization goes
+// back on the deadlines set, already voided, so the next run publishes it again.
+func (ps *PaymentService) voidAuthorization(ctx context.Context, paymentID string) (bool, error) {
+	claimed, err := ps.claimAuthorization(ctx, paymentID)
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	auth, err := ps.getAuthorization(ctx, paymentID)
+	if errors.Is(err, ErrAuthorizationNotFound) {
+		log.Printf("Dropping deadline of unknown authorization %s", paymentID)
+		return false, nil
+	}
+	if err != nil {
+		// Keep it due so a later run retries
+		ps.cache.ZAdd(ctx, database.GeneratePaymentAuthorizationDeadlinesKey(), &redis.Z{Score: float64(time.Now().Unix()), Member: paymentID})
+		return false, err
+	}
+	if auth.Status == models.AuthorizationStatusCaptured {
+		return false, nil
+	}
+
+	voided := auth.Status != models.AuthorizationStatusVoided
+	if voided {
+		now := time.Now()
+		auth.Status = models.AuthorizationStatusVoided
+		auth.VoidedAt = &now
+		if err := ps.saveAuthorization(ctx, auth); err != nil {
+			ps.rescheduleVoid(ctx, auth)
+			return false, err
+		}
+
+		authorizationStats.Add("voided", 1)
+		ps.recordAudit(AuditEventPaymentVoided, &paymentAuditRecord{
+			BookingID: auth.BookingID,
+			UserID:    auth.UserID,
+			Amount:    auth.Amount,
+			PaymentID: auth.PaymentID,
+			Status:    auth.Status,
+			Reason:    "capture_deadline",
+		})
+		log.Printf("Voided payment %s for booking %d: not captured by %s", auth.PaymentID, auth.BookingID, auth.CaptureBy.Format(time.RFC3339))
+	}
+
+	if err := ps.events.Publish(ctx, events.StreamPayments, models.EventPaymentVoided, auth); err != nil {
+		ps.rescheduleVoid(ctx, auth)
+		return voided, fmt.Errorf("failed to publish void of payment %s: %w", auth.PaymentID, err)
+	}
+	return voided, nil
+}
+
+// rescheduleVoid puts a voided authorization back on the deadlines set so the void job
+// publishes its event again
+func (ps *PaymentService) rescheduleVoid(ctx context.Context, auth *models.PaymentAuthorization) {
+	err := ps.cache.ZAdd(ctx, database.GeneratePaymentAuthorizationDeadlinesKey(), &redis.Z{
+		Score:  float64(auth.CaptureBy.Unix()),
+		Member: auth.PaymentID,
+	}).Err()
+	if err != nil {
+		log.Printf("Failed to reschedule void of payment %s: %v", auth.PaymentID, err)
+	}
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"fmt"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/pkg/models"
+)
+
+// Test persona users of the mock payment gateway
+const (
+	PersonaUserTimeout  = 999
+	PersonaUserDeclined = 998
+	PersonaUserApproved = 997
+)
+
+// paymentPersona pairs a documented persona with the payments it applies to
+type paymentPersona struct {
+	models.PaymentPersona
+	matches func(req *models.PaymentRequest) bool
+}
+
+// paymentPersonas are checked in order before the random outcome; the first match decides
+// the payment. User personas come first so they win over amount personas.
+var paymentPersonas = []paymentPersona{
+	userPersona("timeout_user", PersonaUserTimeout, models.PaymentStatusTimeout, "Payment gateway timeout"),
+	userPersona("declined_user", PersonaUserDeclined, models.PaymentStatusFailed, "Card declined"),
+	userPersona("approved_user", PersonaUserApproved, models.PaymentStatusSuccess, "Payment processed successfully"),
+	paisePersona("insufficient_funds", 13, models.PaymentStatusFailed, "Insufficient funds"),
+	paisePersona("bank_declined", 51, models.PaymentStatusFailed, "Bank declined transaction"),
+	paisePersona("gateway_timeout", 99, models.PaymentStatusTimeout, "Payment gateway timeout"),
+}
+
+// userPersona applies to every payment by one user
+func userPersona(name string, userID int, status, message string) paymentPersona {
+	return paymentPersona{
+		PaymentPersona: models.PaymentPersona{
+			Name:    name,
+			Match:   fmt.Sprintf("user_id %d", userID),
+			Status:  status,
+			Message: message,
+		},
+		matches: func(req *models.PaymentRequest) bool { return req.UserID == userID },
+	}
+}
+
+// paisePersona applies to every amount whose paise are a given value, e.g. 13 for 1500.13
+func paisePersona(name string, paise int64, status, message string) paymentPersona {
+	return paymentPersona{
+		PaymentPersona: models.PaymentPersona{
+			Name:    name,
+			Match:   fmt.Sprintf("amount ending in .%02d", paise),
+			Status:  status,
+			Message: message,
+		},
+		matches: func(req *models.PaymentRequest) bool { return req.Amount.Minor%100 == paise },
+	}
+}
+
+// PaymentPersonasEnabled reports whether the mock gateway applies its test personas
+func PaymentPersonasEnabled() bool {
+	return config.GetBool("PAYMENT_PERSONAS_ENABLED", true)
+}
+
+// personaOutcome returns the outcome of the first persona a payment matches
+func (ps *PaymentService) personaOutcome(req *models.PaymentRequest) (*paymentPersona, bool) {
+	if !ps.personasEnabled {
+		return nil, false
+	}
+	for i := range paymentPersonas {
+		if paymentPersonas[i].matches(req) {
+			return &paymentPersonas[i], true
+		}
+	}
+	return nil, false
+}
+
+// Personas lists the mock gateway's personas in the order they are matched
+func (ps *PaymentService) Personas() *models.PaymentPersonasResponse {
+	response := &models.PaymentPersonasResponse{Enabled: ps.personasEnabled}
+	for _, persona := range paymentPersonas {
+		response.Personas = append(response.Personas, persona.PaymentPersona)
+	}
+	return response
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/pkg/models"
+)
+
+// LoadPaymentRateSchedule loads the mock gateway's rate schedule from the environment.
+// PAYMENT_RATE_SCHEDULE lists "period:offset:duration:failure[:timeout]" windows separated
+// by commas, e.g. "15m:0s:2m:0.8" for 80% failures during the first 2 minutes of every
+// quarter hour. Invalid entries are logged and skipped.
+func LoadPaymentRateSchedule() models.PaymentRateSchedule {
+	var schedule models.PaymentRateSchedule
+	for _, entry := range config.GetList("PAYMENT_RATE_SCHEDULE", nil) {
+		window, err := parseRateWindow(entry)
+		if err != nil {
+			log.Printf("Ignoring payment rate window %q: %v", entry, err)
+			continue
+		}
+		schedule.Windows = append(schedule.Windows, window)
+	}
+	return schedule
+}
+
+// parseRateWindow parses one PAYMENT_RATE_SCHEDULE entry
+func parseRateWindow(entry string) (models.PaymentRateWindow, error) {
+	parts := strings.Split(entry, ":")
+	if len(parts) < 4 || len(parts) > 5 {
+		return models.PaymentRateWindow{}, fmt.Errorf("expected period:offset:duration:failure[:timeout]")
+	}
+
+	var durations [3]time.Duration
+	for i, name := range []string{"period", "offset", "duration"} {
+		d, err := time.ParseDuration(strings.TrimSpace(parts[i]))
+		if err != nil {
+			return models.PaymentRateWindow{}, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		durations[i] = d
+	}
+
+	var rates [2]float64
+	for i, part := range parts[3:] {
+		rate, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return models.PaymentRateWindow{}, fmt.Errorf("invalid rate %q", part)
+		}
+		rates[i] = rate
+	}
+
+	window := models.PaymentRateWindow{
+		PeriodSeconds:   int64(durations[0].Seconds()),
+		OffsetSeconds:   int64(durations[1].Seconds()),
+		DurationSeconds: int64(durations[2].Seconds()),
+		FailureRate:     rates[0],
+		TimeoutRate:     rates[1],
+	}
+	return window, window.Validate()
+}
+
+// currentRates returns the failure and timeout rates in effect at now, and the index of
+// the schedule window setting them (-1 for the base rates)
+func (ps *PaymentService) currentRates(now time.Time) (failure, timeout float64, window int) {
+	if schedule := ps.schedule.Load(); schedule != nil {
+		for i := range schedule.Windows {
+			if schedule.Windows[i].Active(now) {
+				return schedule.Windows[i].FailureRate, schedule.Windows[i].TimeoutRate, i
+			}
+		}
+	}
+	return ps.failureRate, ps.timeoutRate, -1
+}
+
+// SetRateSchedule replaces the rate schedule; an empty one restores the base rates
+func (ps *PaymentService) SetRateSchedule(schedule models.PaymentRateSchedule) error {
+	for i := range schedule.Windows {
+		if err := schedule.Windows[i].Validate(); err != nil {
+			return fmt.Errorf("window %d: %w", i, err)
+		}
+	}
+	ps.schedule.Store(&schedule)
+	return nil
+}
+
+// Rates describes the rates in effect now and the schedule
+func (ps *PaymentService) Rates(now time.Time) *models.PaymentRatesResponse {
+	failure, timeout, window := ps.currentRates(now)
+	response := &models.PaymentRatesResponse{
+		BaseFailureRate: ps.failureRate,
+		BaseTimeoutRate: ps.timeoutRate,
+		FailureRate:     failure,
+		TimeoutRate:     timeout,
+		Schedule:        models.PaymentRateSchedule{Windows: []models.PaymentRateWindow{}},
+	}
+	if window >= 0 {
+		response.ActiveWindow = &window
+	}
+	if schedule := ps.schedule.Load(); schedule != nil && schedule.Windows != nil {
+		response.Schedule = *schedule
+	}
+	return response
+}
@@ -2,11 +2,18 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"math/rand"
+	"sync/atomic"
 	"time"
 
-	"cred_flights_booking/internal/models"
+	"cred_flights_booking/internal/auditlog"
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/internal/events"
+	"cred_flights_booking/internal/farequote"
+	"cred_flights_booking/pkg/models"
 
 	"github.com/google/uuid"
 )
@@ -17,20 +24,155 @@ type PaymentService struct {
 	failureRate    float64       // Percentage of payments that should fail
 	timeoutRate    float64       // Percentage of payments that should timeout
 	processingTime time.Duration // Average processing time
+	// Verifies that amounts match flight-service's signed booking totals
+	quotes *farequote.Signer
+	// Tamper-evident record of every payment, kept out of the application logs
+	audit *auditlog.Log
+	// Successful payments are authorizations until captured, voided after captureDeadline
+	cache           *database.RedisClient
+	events          *events.Bus
+	captureDeadline time.Duration
+	// Test personas decide matching payments instead of the random rates
+	personasEnabled bool
+	// Windows of time-varying rates that replace the ones above while active
+	schedule atomic.Pointer[models.PaymentRateSchedule]
 }
 
-// NewPaymentService creates a new payment service
-func NewPaymentService() *PaymentService {
-	return &PaymentService{
-		failureRate:    0.15,            // 15% failure rate
-		timeoutRate:    0.05,            // 5% timeout rate
-		processingTime: 2 * time.Second, // 2 seconds average processing time
+// Payment audit log events
+const (
+	AuditEventPaymentRejected = "payment.rejected"
+	AuditEventPaymentResult   = "payment.result"
+)
+
+// paymentAuditRecord is the detail of one payment audit log entry
+type paymentAuditRecord struct {
+	BookingID   int          `json:"booking_id"`
+	UserID      int          `json:"user_id"`
+	Amount      models.Money `json:"amount"`
+	PaymentType string       `json:"payment_type"`
+	PaymentID   string       `json:"payment_id,omitempty"`
+	Status      string       `json:"status,omitempty"`
+	Reason      string       `json:"reason,omitempty"`
+	Message     string       `json:"message,omitempty"`
+}
+
+// NewPaymentService creates a new payment service recording payments in the audit log.
+// Authorizations are tracked in Redis and their voids published on bus.
+func NewPaymentService(audit *auditlog.Log, cache *database.RedisClient, bus *events.Bus) *PaymentService {
+	ps := &PaymentService{
+		failureRate:     0.15,            // 15% failure rate
+		timeoutRate:     0.05,            // 5% timeout rate
+		processingTime:  2 * time.Second, // 2 seconds average processing time
+		quotes:          farequote.NewSigner(farequote.LoadConfig()),
+		audit:           audit,
+		cache:           cache,
+		events:          bus,
+		captureDeadline: PaymentCaptureDeadline(),
+		personasEnabled: PaymentPersonasEnabled(),
+	}
+	ps.schedule.Store(&models.PaymentRateSchedule{})
+	if schedule := LoadPaymentRateSchedule(); len(schedule.Windows) > 0 {
+		ps.SetRateSchedule(schedule)
+		log.Printf("Payment rate schedule has %d windows", len(schedule.Windows))
+	}
+	return ps
+}
+
+// rejectionReason names why a payment was refused, without the amounts involved
+func rejectionReason(err error) string {
+	switch {
+	case errors.Is(err, models.ErrAmountMismatch):
+		return "amount_mismatch"
+	case errors.Is(err, farequote.ErrMissingQuote):
+		return "missing_quote"
+	case errors.Is(err, farequote.ErrQuoteExpired):
+		return "quote_expired"
+	case errors.Is(err, farequote.ErrInvalidQuote):
+		return "invalid_quote"
+	case errors.Is(err, farequote.ErrNoSecrets):
+		return "quotes_not_configured"
+	}
+	return "rejected"
+}
+
+// recordAudit appends a payment to the audit log. A failed append is logged rather than
+// failing the payment, which the gateway has already decided.
+func (ps *PaymentService) recordAudit(event string, record *paymentAuditRecord) {
+	if err := ps.audit.Append(event, record); err != nil {
+		log.Printf("Failed to record payment audit entry for booking %d: %v", record.BookingID, err)
+	}
+}
+
+// VerifyAuditLog checks the audit log's hash chain
+func (ps *PaymentService) VerifyAuditLog() (*models.AuditLogReport, error) {
+	return ps.audit.Verify()
+}
+
+// AuditLogEntries returns up to limit audit log entries from sequence from on
+func (ps *PaymentService) AuditLogEntries(from int64, limit int) ([]models.AuditLogEntry, error) {
+	return ps.audit.Entries(from, limit)
+}
+
+// verifyAmount checks a payment against its fare quote: the quote must be signed by
+// flight-service, unexpired, for the paying user, and for exactly the amount charged.
+// Without FARE_QUOTE_SECRETS every payment is refused, unless FARE_QUOTE_INSECURE_DEV
+// accepts every amount.
+func (ps *PaymentService) verifyAmount(req *models.PaymentRequest) error {
+	if ps.quotes.Optional() {
+		return nil
+	}
+	if err := ps.quotes.Verify(req.Quote); err != nil {
+		return err
 	}
+	if req.Quote.UserID != req.UserID {
+		return fmt.Errorf("%w: quoted for user %d", farequote.ErrInvalidQuote, req.Quote.UserID)
+	}
+	if req.Quote.Amount.Minor != req.Amount.Minor || req.Quote.Amount.Currency != req.Amount.Currency {
+		return &models.AmountMismatchError{Expected: req.Quote.Amount, Actual: req.Amount}
+	}
+	return nil
 }
 
-// ProcessPayment processes a payment request with mock scenarios
+// ProcessPayment processes a payment request with mock scenarios. Amounts not backed by
+// the booking's fare quote are rejected with an error before anything is charged.
 func (ps *PaymentService) ProcessPayment(ctx context.Context, req *models.PaymentRequest) (*models.PaymentResponse, error) {
-	log.Printf("Processing payment for booking %d, amount: %.2f", req.BookingID, req.Amount)
+	log.Printf("Processing payment for booking %d, amount: %s", req.BookingID, req.Amount)
+
+	record := &paymentAuditRecord{
+		BookingID:   req.BookingID,
+		UserID:      req.UserID,
+		Amount:      req.Amount,
+		PaymentType: req.PaymentType,
+	}
+
+	if err := ps.verifyAmount(req); err != nil {
+		record.Reason, record.Message = rejectionReason(err), err.Error()
+		ps.recordAudit(AuditEventPaymentRejected, record)
+		log.Printf("Payment rejected for booking %d: %s", req.BookingID, record.Reason)
+		return nil, err
+	}
+
+	response, err := ps.process(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// The funds are only held until the booking side captures them
+	if response.Status == models.PaymentStatusSuccess {
+		if err := ps.authorize(ctx, req, response); err != nil {
+			return nil, err
+		}
+	}
+
+	record.PaymentID, record.Status, record.Message = response.PaymentID, response.Status, response.Message
+	ps.recordAudit(AuditEventPaymentResult, record)
+	return response, nil
+}
+
+// process runs a payment through the mock gateway. Simulations call it directly, since
+// they charge nothing and so need no quote, and not live: personas and the rate schedule
+// would override the outcome they force.
+func (ps *PaymentService) process(ctx context.Context, req *models.PaymentRequest, live bool) (*models.PaymentResponse, error) {
 
 	// Validate payment type
 	if !models.IsValidPaymentType(req.PaymentType) {
@@ -62,29 +204,39 @@ func (ps *PaymentService) ProcessPayment(ctx context.Context, req *models.Paymen
 		// Continue processing
 	}
 
-	// Simulate random scenarios
-	rand.Seed(time.Now().UnixNano())
-	randomValue := rand.Float64()
-
 	// Determine payment outcome
 	var status string
 	var message string
 
-	switch {
-	case randomValue < ps.timeoutRate:
-		// Timeout scenario
-		status = models.PaymentStatusTimeout
-		message = "Payment gateway timeout"
-
-	case randomValue < ps.timeoutRate+ps.failureRate:
-		// Failure scenario
-		status = models.PaymentStatusFailed
-		message = ps.getRandomFailureMessage()
-
-	default:
-		// Success scenario
-		status = models.PaymentStatusSuccess
-		message = "Payment processed successfully"
+	if persona, ok := ps.personaOutcome(req); ok && live {
+		// Test personas always end the same way
+		status, message = persona.Status, persona.Message
+		log.Printf("Payment for booking %d matched persona %s", req.BookingID, persona.Name)
+	} else {
+		// Simulate random scenarios, at the scheduled rates when a window is active
+		failureRate, timeoutRate := ps.failureRate, ps.timeoutRate
+		if live {
+			failureRate, timeoutRate, _ = ps.currentRates(time.Now())
+		}
+		rand.Seed(time.Now().UnixNano())
+		randomValue := rand.Float64()
+
+		switch {
+		case randomValue < timeoutRate:
+			// Timeout scenario
+			status = models.PaymentStatusTimeout
+			message = "Payment gateway timeout"
+
+		case randomValue < timeoutRate+failureRate:
+			// Failure scenario
+			status = models.PaymentStatusFailed
+			message = ps.getRandomFailureMessage()
+
+		default:
+			// Success scenario
+			status = models.PaymentStatusSuccess
+			message = "Payment processed successfully"
+		}
 	}
 
 	// Generate payment ID
@@ -156,7 +308,7 @@ func (ps *PaymentService) SimulatePaymentFailure(ctx context.Context, req *model
 		ps.timeoutRate = originalTimeoutRate
 	}()
 
-	return ps.ProcessPayment(ctx, req)
+	return ps.process(ctx, req, false)
 }
 
 // SimulatePaymentTimeout simulates a payment timeout for testing
@@ -165,7 +317,7 @@ func (ps *PaymentService) SimulatePaymentTimeout(ctx context.Context, req *model
 	ps.timeoutRate = 1.0 // 100% timeout rate
 	defer func() { ps.timeoutRate = originalTimeoutRate }()
 
-	return ps.ProcessPayment(ctx, req)
+	return ps.process(ctx, req, false)
 }
 
 // SimulatePaymentSuccess simulates a successful payment for testing
@@ -181,5 +333,5 @@ func (ps *PaymentService) SimulatePaymentSuccess(ctx context.Context, req *model
 		ps.timeoutRate = originalTimeoutRate
 	}()
 
-	return ps.ProcessPayment(ctx, req)
+	return ps.process(ctx, req, false)
 }
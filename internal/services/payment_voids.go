@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+
+	"cred_flights_booking/internal/events"
+	"cred_flights_booking/pkg/models"
+)
+
+// paymentVoidsGroup is the consumer group reconciling bookings with voided payments
+const paymentVoidsGroup = "booking-payment-voids"
+
+// paymentVoidStats exposes payment void reconciliation counters (bookings_cancelled,
+// upgrades_unpaid, no_booking)
+var paymentVoidStats = expvar.NewMap("payment_void_reconciliation")
+
+// PaymentVoidReconciler cancels bookings whose payment authorization was voided because it
+// was never captured, so no booking keeps seats it did not pay for
+type PaymentVoidReconciler struct {
+	bookings *BookingServiceV2
+}
+
+// NewPaymentVoidReconciler creates a payment void reconciler
+func NewPaymentVoidReconciler(bookings *BookingServiceV2) *PaymentVoidReconciler {
+	return &PaymentVoidReconciler{bookings: bookings}
+}
+
+// Start reconciles voided payments in the background until ctx is cancelled
+func (pr *PaymentVoidReconciler) Start(ctx context.Context, bus *events.Bus, consumer string) {
+	go func() {
+		if err := bus.Subscribe(ctx, events.StreamPayments, paymentVoidsGroup, consumer, pr.Reconcile); err != nil {
+			log.Printf("Payment void reconciler stopped: %v", err)
+		}
+	}()
+}
+
+// Backlog returns how many payment events the reconciler has yet to handle
+func (pr *PaymentVoidReconciler) Backlog(ctx context.Context, bus *events.Bus) (int64, error) {
+	return bus.Backlog(ctx, events.StreamPayments, paymentVoidsGroup)
+}
+
+// Reconcile cancels the confirmed booking paid by a voided payment and releases its seats.
+// Voids of payments without a booking (already compensated) need nothing; a voided
+// upgrade payment is logged for review, since the upgrade itself was stored.
+func (pr *PaymentVoidReconciler) Reconcile(ctx context.Context, event *events.Event) error {
+	if event.Type != models.EventPaymentVoided {
+		return nil
+	}
+	var auth models.PaymentAuthorization
+	if err := event.Decode(&auth); err != nil {
+		return err
+	}
+
+	bs := pr.bookings
+	var bookingID int
+	err := bs.db.QueryRowContext(ctx, "SELECT id FROM bookings WHERE payment_id = $1", auth.PaymentID).Scan(&bookingID)
+	if err == sql.ErrNoRows {
+		var upgradedID int
+		err := bs.db.QueryRowContext(ctx, "SELECT booking_id FROM booking_upgrades WHERE payment_id = $1", auth.PaymentID).Scan(&upgradedID)
+		if err == nil {
+			paymentVoidStats.Add("upgrades_unpaid", 1)
+			log.Printf("Upgrade of booking %d was never paid: payment %s voided, needs review", upgradedID, auth.PaymentID)
+			return nil
+		}
+		if err != sql.ErrNoRows {
+			return fmt.Errorf("failed to query upgrade by payment: %w", err)
+		}
+		paymentVoidStats.Add("no_booking", 1)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query booking by payment: %w", err)
+	}
+
+	booking, err := bs.GetBooking(ctx, bookingID)
+	if err != nil {
+		return err
+	}
+	if booking.Status != models.BookingStatusConfirmed {
+		log.Printf("Payment %s of booking %d voided while the booking is %s, nothing to cancel", auth.PaymentID, bookingID, booking.Status)
+		return nil
+	}
+
+	err = bs.transitionBooking(ctx, booking, models.BookingStatusCancelled, "payment_voided", "")
+	var transitionErr *models.TransitionError
+	if errors.As(err, &transitionErr) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := bs.releaseBookingSeats(ctx, booking, booking.Seats, models.SeatReasonBookingCancelled); err != nil {
+		log.Printf("Failed to release seats of unpaid booking %d: %v", bookingID, err)
+	}
+	bs.publishOccupancyEvent(ctx, bookingID, booking.FlightID, -booking.Seats, booking.Date, models.OccupancyReasonBookingCancelled, "")
+
+	paymentVoidStats.Add("bookings_cancelled", 1)
+	log.Printf("Cancelled booking %d: payment %s was voided without being captured", bookingID, auth.PaymentID)
+	return nil
+}
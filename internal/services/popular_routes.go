@@ -0,0 +1,214 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/internal/funnel"
+	"cred_flights_booking/pkg/models"
+	"github.com/andybalholm/brotli"
+	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
+)
+
+// ErrPopularRoutesUnavailable is returned before the popular routes job has run
+var ErrPopularRoutesUnavailable = errors.New("popular routes are not available yet")
+
+// Popular routes encodings, as stored in Redis and negotiated with clients
+const (
+	PopularRoutesJSON   = "json"
+	PopularRoutesBrotli = "br"
+)
+
+// PopularRoutesConfig controls the precomputed popular routes list
+type PopularRoutesConfig struct {
+	Limit      int           // Most routes listed
+	Days       int           // How many days ahead the cheapest fare is looked for
+	SeedRoutes []string      // "SRC-DST" routes listed after the most searched ones, e.g. before any traffic
+	TTL        time.Duration // How long a built list is kept if the job stops refreshing it
+	MaxAge     time.Duration // Cache-Control max-age of GET /api/flights/popular
+}
+
+// LoadPopularRoutesConfig loads the popular routes settings from the environment
+func LoadPopularRoutesConfig() PopularRoutesConfig {
+	return PopularRoutesConfig{
+		Limit:      max(config.GetInt("POPULAR_ROUTES_LIMIT", 10), 1),
+		Days:       max(config.GetInt("POPULAR_ROUTES_DAYS", 30), 1),
+		SeedRoutes: config.GetList("POPULAR_ROUTES_SEED", nil),
+		TTL:        config.GetDuration("POPULAR_ROUTES_TTL", 2*time.Hour),
+		MaxAge:     config.GetDuration("POPULAR_ROUTES_MAX_AGE", 5*time.Minute),
+	}
+}
+
+// PopularRoutesMaxAge returns how long clients and CDNs may cache the popular routes
+func (fs *FlightService) PopularRoutesMaxAge() time.Duration {
+	return fs.popularRoutes.MaxAge
+}
+
+// topSearchedRoutes returns the routes with the most search sessions today and yesterday
+// (UTC), followed by the seed routes, up to the configured limit
+func (fs *FlightService) topSearchedRoutes(ctx context.Context) ([]string, map[string]int64, error) {
+	searches := make(map[string]int64)
+	now := time.Now().UTC()
+	for _, day := range []time.Time{now, now.AddDate(0, 0, -1)} {
+		counts, err := fs.funnel.Counts(ctx, day.Format("2006-01-02"))
+		if err != nil {
+			return nil, nil, err
+		}
+		for route, stages := range counts {
+			searches[route] += stages[funnel.StageSearch]
+		}
+	}
+
+	routes := make([]string, 0, len(searches))
+	for route, count := range searches {
+		if count > 0 {
+			routes = append(routes, route)
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if searches[routes[i]] != searches[routes[j]] {
+			return searches[routes[i]] > searches[routes[j]]
+		}
+		return routes[i] < routes[j]
+	})
+
+	for _, seed := range fs.popularRoutes.SeedRoutes {
+		source, destination, _ := strings.Cut(strings.ToUpper(seed), "-")
+		if route := funnel.Route(source, destination); route != "" && searches[route] == 0 {
+			routes = append(routes, route)
+			searches[route] = 0
+		}
+	}
+
+	if len(routes) > fs.popularRoutes.Limit {
+		routes = routes[:fs.popularRoutes.Limit]
+	}
+	return routes, searches, nil
+}
+
+// BuildPopularRoutes precomputes the cheapest upcoming direct fare with seats left on each of
+// the most searched routes, and stores the list as JSON and Brotli-compressed JSON so
+// GET /api/flights/popular never runs a search or compresses per request
+func (fs *FlightService) BuildPopularRoutes(ctx context.Context) (*models.PopularRoutesResponse, error) {
+	routes, searches, err := fs.topSearchedRoutes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := make([]string, len(routes))
+	destinations := make([]string, len(routes))
+	for i, route := range routes {
+		sources[i], destinations[i], _ = strings.Cut(route, "-")
+	}
+
+	query := `
+		SELECT DISTINCT ON (f.source, f.destination)
+		       f.source, f.destination, f.id, f.flight_number, f.departure_time, f.price
+		FROM flights f
+		JOIN unnest($1::text[], $2::text[]) AS r(source, destination)
+		  ON f.source = r.source AND f.destination = r.destination
+		LEFT JOIN flight_inventory i ON i.flight_id = f.id AND i.date = DATE(f.departure_time)
+		WHERE f.status <> 'cancelled'
+		  AND f.departure_time >= $3 AND f.departure_time < $4
+		  AND COALESCE(i.total_seats, f.total_seats) > COALESCE(i.booked_seats, f.booked_seats)
+		ORDER BY f.source, f.destination, f.price, f.departure_time
+	`
+
+	now := time.Now()
+	rows, err := fs.db.QueryContext(ctx, query, pq.Array(sources), pq.Array(destinations),
+		now, now.AddDate(0, 0, fs.popularRoutes.Days))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query popular route fares: %w", err)
+	}
+	defer rows.Close()
+
+	fares := make(map[string]models.PopularRoute)
+	for rows.Next() {
+		var route models.PopularRoute
+		err := rows.Scan(&route.Source, &route.Destination, &route.FlightID, &route.FlightNumber,
+			&route.DepartureTime, &route.Price)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan popular route fare: %w", err)
+		}
+		fares[funnel.Route(route.Source, route.Destination)] = route
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read popular route fares: %w", err)
+	}
+
+	airports, err := fs.listAirports(ctx)
+	if err != nil {
+		log.Printf("Failed to load airports for popular routes: %v", err)
+	}
+
+	// Keep the search ranking; routes without bookable flights are left out
+	response := &models.PopularRoutesResponse{
+		Routes:      []models.PopularRoute{},
+		GeneratedAt: now,
+	}
+	for _, key := range routes {
+		route, ok := fares[key]
+		if !ok {
+			continue
+		}
+		route.Searches = searches[key]
+		route.SourceCity = airports[route.Source].City
+		route.DestinationCity = airports[route.Destination].City
+		response.Routes = append(response.Routes, route)
+	}
+
+	if err := fs.storePopularRoutes(ctx, response); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Built popular routes: %d of %d top routes have fares", len(response.Routes), len(routes))
+	return response, nil
+}
+
+// storePopularRoutes stores both encodings of the list in one transaction
+func (fs *FlightService) storePopularRoutes(ctx context.Context, response *models.PopularRoutesResponse) error {
+	body, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("failed to marshal popular routes: %w", err)
+	}
+
+	var compressed bytes.Buffer
+	bw := brotli.NewWriterLevel(&compressed, brotli.BestCompression)
+	if _, err := bw.Write(body); err != nil {
+		return fmt.Errorf("failed to compress popular routes: %w", err)
+	}
+	if err := bw.Close(); err != nil {
+		return fmt.Errorf("failed to compress popular routes: %w", err)
+	}
+
+	pipe := fs.cache.TxPipeline()
+	pipe.Set(ctx, database.GeneratePopularRoutesCacheKey(PopularRoutesJSON), body, fs.popularRoutes.TTL)
+	pipe.Set(ctx, database.GeneratePopularRoutesCacheKey(PopularRoutesBrotli), compressed.Bytes(), fs.popularRoutes.TTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to store popular routes: %w", err)
+	}
+	return nil
+}
+
+// PopularRoutes returns the precomputed popular routes body in the given encoding
+// (PopularRoutesJSON or PopularRoutesBrotli)
+func (fs *FlightService) PopularRoutes(ctx context.Context, encoding string) ([]byte, error) {
+	body, err := fs.cache.Get(ctx, database.GeneratePopularRoutesCacheKey(encoding)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrPopularRoutesUnavailable
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load popular routes: %w", err)
+	}
+	return body, nil
+}
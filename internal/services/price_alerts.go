@@ -0,0 +1,281 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/internal/notifications"
+	"cred_flights_booking/pkg/models"
+)
+
+var (
+	// ErrPriceAlertNotFound is returned when an alert does not exist or belongs to another user
+	ErrPriceAlertNotFound = errors.New("price alert not found")
+	// ErrTooManyAlerts is returned when a user already has the maximum number of active alerts
+	ErrTooManyAlerts = errors.New("too many active price alerts")
+)
+
+// PriceAlertService manages fare drop subscriptions and checks them against live search results
+type PriceAlertService struct {
+	db         *database.DB
+	flights    *FlightService
+	notifier   *notifications.Notifier
+	maxPerUser int
+}
+
+// NewPriceAlertService creates a new price alert service
+func NewPriceAlertService(db *database.DB, flights *FlightService, notifier *notifications.Notifier) *PriceAlertService {
+	return &PriceAlertService{
+		db:         db,
+		flights:    flights,
+		notifier:   notifier,
+		maxPerUser: config.GetInt("PRICE_ALERT_MAX_PER_USER", 20),
+	}
+}
+
+// priceAlertColumns is the column list scanned by scanPriceAlert
+const priceAlertColumns = `id, user_id, source, destination, to_char(date, 'YYYY-MM-DD'), seats, target_price,
+	COALESCE(email, ''), COALESCE(phone, ''), status, last_price, last_checked_at, triggered_at, created_at`
+
+// scanPriceAlert scans one price alert row
+func scanPriceAlert(row rowScanner) (*models.PriceAlert, error) {
+	var alert models.PriceAlert
+	var lastPrice sql.NullFloat64
+	var lastCheckedAt, triggeredAt sql.NullTime
+	err := row.Scan(&alert.ID, &alert.UserID, &alert.Source, &alert.Destination, &alert.Date, &alert.Seats,
+		&alert.TargetPrice, &alert.Email, &alert.Phone, &alert.Status, &lastPrice, &lastCheckedAt, &triggeredAt,
+		&alert.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if lastPrice.Valid {
+		alert.LastPrice = &lastPrice.Float64
+	}
+	if lastCheckedAt.Valid {
+		alert.LastCheckedAt = &lastCheckedAt.Time
+	}
+	if triggeredAt.Valid {
+		alert.TriggeredAt = &triggeredAt.Time
+	}
+	return &alert, nil
+}
+
+// CreateAlert subscribes a user to fare drops on a route and date
+func (ps *PriceAlertService) CreateAlert(ctx context.Context, req *models.PriceAlertRequest) (*models.PriceAlert, error) {
+	var active int
+	err := ps.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM price_alerts WHERE user_id = $1 AND status = $2",
+		req.UserID, models.PriceAlertStatusActive,
+	).Scan(&active)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count price alerts: %w", err)
+	}
+	if active >= ps.maxPerUser {
+		return nil, ErrTooManyAlerts
+	}
+
+	query := `
+		INSERT INTO price_alerts (user_id, source, destination, date, seats, target_price, email, phone)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''))
+		RETURNING ` + priceAlertColumns
+
+	alert, err := scanPriceAlert(ps.db.QueryRowContext(ctx, query,
+		req.UserID, req.Source, req.Destination, req.Date, req.Seats, req.TargetPrice, req.Email, req.Phone))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create price alert: %w", err)
+	}
+
+	return alert, nil
+}
+
+// ListAlerts returns a user's price alerts, newest first
+func (ps *PriceAlertService) ListAlerts(ctx context.Context, userID int) ([]models.PriceAlert, error) {
+	query := "SELECT " + priceAlertColumns + " FROM price_alerts WHERE user_id = $1 ORDER BY created_at DESC, id DESC"
+
+	rows, err := ps.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query price alerts: %w", err)
+	}
+	defer rows.Close()
+
+	alerts := []models.PriceAlert{}
+	for rows.Next() {
+		alert, err := scanPriceAlert(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan price alert: %w", err)
+		}
+		alerts = append(alerts, *alert)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read price alerts: %w", err)
+	}
+
+	return alerts, nil
+}
+
+// CancelAlert stops an active alert owned by the user
+func (ps *PriceAlertService) CancelAlert(ctx context.Context, alertID, userID int) (*models.PriceAlert, error) {
+	query := `
+		UPDATE price_alerts SET status = $3
+		WHERE id = $1 AND user_id = $2 AND status = $4
+		RETURNING ` + priceAlertColumns
+
+	alert, err := scanPriceAlert(ps.db.QueryRowContext(ctx, query,
+		alertID, userID, models.PriceAlertStatusCancelled, models.PriceAlertStatusActive))
+	if err == sql.ErrNoRows {
+		return nil, ErrPriceAlertNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel price alert: %w", err)
+	}
+
+	return alert, nil
+}
+
+// priceAlertRoute groups alerts that share one search
+type priceAlertRoute struct {
+	source      string
+	destination string
+	date        string
+	seats       int
+}
+
+// CheckAlerts expires past alerts, re-runs one search per distinct route, date, and seat
+// count (served from the search cache when warm), and notifies subscribers whose target
+// fare was reached. It returns the number of alerts triggered.
+func (ps *PriceAlertService) CheckAlerts(ctx context.Context) (int, error) {
+	today := time.Now().UTC().Format("2006-01-02")
+	result, err := ps.db.ExecContext(ctx,
+		"UPDATE price_alerts SET status = $1 WHERE status = $2 AND date < $3",
+		models.PriceAlertStatusExpired, models.PriceAlertStatusActive, today)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire price alerts: %w", err)
+	}
+	if expired, _ := result.RowsAffected(); expired > 0 {
+		log.Printf("Expired %d price alerts", expired)
+	}
+
+	rows, err := ps.db.QueryContext(ctx,
+		"SELECT "+priceAlertColumns+" FROM price_alerts WHERE status = $1 ORDER BY id",
+		models.PriceAlertStatusActive)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query active price alerts: %w", err)
+	}
+	routes := make(map[priceAlertRoute][]*models.PriceAlert)
+	var order []priceAlertRoute
+	for rows.Next() {
+		alert, err := scanPriceAlert(rows)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan price alert: %w", err)
+		}
+		route := priceAlertRoute{alert.Source, alert.Destination, alert.Date, alert.Seats}
+		if _, ok := routes[route]; !ok {
+			order = append(order, route)
+		}
+		routes[route] = append(routes[route], alert)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read active price alerts: %w", err)
+	}
+
+	triggered := 0
+	for _, route := range order {
+		if err := ctx.Err(); err != nil {
+			return triggered, err
+		}
+
+		fare, found, err := ps.lowestFare(ctx, route)
+		if err != nil {
+			log.Printf("Price alert search failed for %s-%s on %s: %v", route.source, route.destination, route.date, err)
+			continue
+		}
+
+		for _, alert := range routes[route] {
+			if ps.checkAlert(ctx, alert, fare, found) {
+				triggered++
+			}
+		}
+	}
+
+	log.Printf("Checked price alerts: %d routes, %d triggered", len(order), triggered)
+	return triggered, nil
+}
+
+// lowestFare returns the cheapest per-seat fare for a route, and whether any path was found
+func (ps *PriceAlertService) lowestFare(ctx context.Context, route priceAlertRoute) (float64, bool, error) {
+	response, err := ps.flights.SearchFlights(ctx, &models.SearchRequest{
+		Source:      route.source,
+		Destination: route.destination,
+		Date:        route.date,
+		Seats:       route.seats,
+		SortBy:      "cheapest",
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	if len(response.Paths) == 0 {
+		return 0, false, nil
+	}
+
+	fare := response.Paths[0].TotalPrice
+	for _, path := range response.Paths[1:] {
+		if path.TotalPrice.Minor < fare.Minor {
+			fare = path.TotalPrice
+		}
+	}
+	return fare.Float64(), true, nil
+}
+
+// checkAlert records the latest fare on an alert and, if it reached the target, claims and
+// notifies it. Claiming before sending keeps concurrent checkers from notifying twice.
+func (ps *PriceAlertService) checkAlert(ctx context.Context, alert *models.PriceAlert, fare float64, found bool) bool {
+	if !found || fare > alert.TargetPrice {
+		var lastPrice sql.NullFloat64
+		if found {
+			lastPrice = sql.NullFloat64{Float64: fare, Valid: true}
+		}
+		_, err := ps.db.ExecContext(ctx,
+			"UPDATE price_alerts SET last_price = $2, last_checked_at = NOW() WHERE id = $1",
+			alert.ID, lastPrice)
+		if err != nil {
+			log.Printf("Failed to record price for alert %d: %v", alert.ID, err)
+		}
+		return false
+	}
+
+	result, err := ps.db.ExecContext(ctx, `
+		UPDATE price_alerts SET status = $2, last_price = $3, last_checked_at = NOW(), triggered_at = NOW()
+		WHERE id = $1 AND status = $4`,
+		alert.ID, models.PriceAlertStatusTriggered, fare, models.PriceAlertStatusActive)
+	if err != nil {
+		log.Printf("Failed to trigger price alert %d: %v", alert.ID, err)
+		return false
+	}
+	if claimed, _ := result.RowsAffected(); claimed == 0 {
+		return false // Cancelled or triggered since it was loaded
+	}
+
+	if _, err := ps.notifier.SendPriceAlert(ctx, alert, fare); err != nil {
+		log.Printf("Failed to notify price alert %d, re-arming: %v", alert.ID, err)
+		_, err := ps.db.ExecContext(ctx,
+			"UPDATE price_alerts SET status = $2, triggered_at = NULL WHERE id = $1",
+			alert.ID, models.PriceAlertStatusActive)
+		if err != nil {
+			log.Printf("Failed to re-arm price alert %d: %v", alert.ID, err)
+		}
+		return false
+	}
+
+	log.Printf("Price alert %d triggered: %s-%s on %s at %.2f (target %.2f)",
+		alert.ID, alert.Source, alert.Destination, alert.Date, fare, alert.TargetPrice)
+	return true
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/pkg/models"
+)
+
+// ExportUserData returns every booking, payment record, and contact stored for a user
+func (bs *BookingServiceV2) ExportUserData(ctx context.Context, userID int) (*models.UserDataExport, error) {
+	query := `
+		SELECT id, user_id, flight_id, seats, total_amount, status, COALESCE(payment_id, ''), date, fare_code,
+		       COALESCE(email, ''), COALESCE(phone, ''), refund_amount, cancellation_fee, anonymized_at, created_at
+		FROM bookings
+		WHERE user_id = $1
+		ORDER BY id
+	`
+
+	rows, err := bs.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bookings: %w", err)
+	}
+	defer rows.Close()
+
+	export := &models.UserDataExport{
+		UserID:     userID,
+		ExportedAt: time.Now(),
+		Bookings:   []models.Booking{},
+		Payments:   []models.PaymentRecord{},
+		Passengers: []models.PassengerData{},
+		Erasures:   []models.ErasureRecord{},
+	}
+	for rows.Next() {
+		var booking models.Booking
+		var refund, fee *models.Money
+		var anonymizedAt sql.NullTime
+		err := rows.Scan(
+			&booking.ID, &booking.UserID, &booking.FlightID, &booking.Seats, &booking.TotalAmount,
+			&booking.Status, &booking.PaymentID, &booking.Date, &booking.FareCode,
+			&booking.Email, &booking.Phone, &refund, &fee, &anonymizedAt, &booking.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan booking: %w", err)
+		}
+
+		payment := models.PaymentRecord{
+			BookingID:       booking.ID,
+			PaymentID:       booking.PaymentID,
+			Amount:          booking.TotalAmount,
+			Status:          booking.Status,
+			RefundAmount:    refund,
+			CancellationFee: fee,
+		}
+
+		passenger := models.PassengerData{
+			BookingID: booking.ID,
+			Email:     booking.Email,
+			Phone:     booking.Phone,
+		}
+		if anonymizedAt.Valid {
+			passenger.AnonymizedAt = &anonymizedAt.Time
+		}
+
+		export.Bookings = append(export.Bookings, booking)
+		export.Payments = append(export.Payments, payment)
+		export.Passengers = append(export.Passengers, passenger)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read bookings: %w", err)
+	}
+
+	for i := range export.Bookings {
+		segments, err := bs.getSegments(ctx, export.Bookings[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		export.Bookings[i].Segments = segments
+
+		travellers, err := bs.getPassengers(ctx, export.Bookings[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		export.Passengers[i].Travellers = travellers
+	}
+
+	erasures, err := bs.db.QueryContext(ctx, `
+		SELECT id, user_id, requested_by, bookings_anonymized, erased_at
+		FROM data_erasures
+		WHERE user_id = $1
+		ORDER BY id
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query erasures: %w", err)
+	}
+	defer erasures.Close()
+
+	for erasures.Next() {
+		var record models.ErasureRecord
+		if err := erasures.Scan(&record.ID, &record.UserID, &record.RequestedBy, &record.BookingsAnonymized, &record.ErasedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan erasure: %w", err)
+		}
+		export.Erasures = append(export.Erasures, record)
+	}
+	if err := erasures.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read erasures: %w", err)
+	}
+
+	return export, nil
+}
+
+// EraseUserData anonymizes a user's personal fields on every booking while keeping amounts,
+// payment IDs, and statuses for financial records, and records the erasure for audit
+func (bs *BookingServiceV2) EraseUserData(ctx context.Context, userID int, requestedBy string) (*models.ErasureRecord, error) {
+	record := &models.ErasureRecord{
+		UserID:      userID,
+		RequestedBy: requestedBy,
+	}
+	var bookingIDs []int
+
+	err := bs.db.Transaction(func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `
+			UPDATE bookings
+			SET email = NULL, phone = NULL, anonymized_at = CURRENT_TIMESTAMP
+			WHERE user_id = $1
+			RETURNING id
+		`, userID)
+		if err != nil {
+			return fmt.Errorf("failed to anonymize bookings: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				return fmt.Errorf("failed to scan booking ID: %w", err)
+			}
+			bookingIDs = append(bookingIDs, id)
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to read anonymized bookings: %w", err)
+		}
+
+		// The read model must not keep the erased contacts either
+		_, err = tx.ExecContext(ctx,
+			"UPDATE booking_read_model SET email = NULL, phone = NULL WHERE user_id = $1", userID)
+		if err != nil {
+			return fmt.Errorf("failed to anonymize booking read model: %w", err)
+		}
+
+		// Travellers are erased with the booker; the flight and date stay for capacity reports
+		_, err = tx.ExecContext(ctx, `
+			UPDATE passengers SET name = NULL, document_number = NULL
+			WHERE booking_id IN (SELECT id FROM bookings WHERE user_id = $1)
+		`, userID)
+		if err != nil {
+			return fmt.Errorf("failed to anonymize passengers: %w", err)
+		}
+
+		record.BookingsAnonymized = len(bookingIDs)
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO data_erasures (user_id, requested_by, bookings_anonymized)
+			VALUES ($1, $2, $3)
+			RETURNING id, erased_at
+		`, userID, requestedBy, record.BookingsAnonymized).Scan(&record.ID, &record.ErasedAt)
+		if err != nil {
+			return fmt.Errorf("failed to record erasure: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Cached bookings still hold the erased contacts
+	for _, id := range bookingIDs {
+		if err := bs.cache.Delete(ctx, database.GenerateBookingCacheKey(id)); err != nil {
+			log.Printf("Failed to invalidate booking %d after erasure: %v", id, err)
+		}
+	}
+
+	return record, nil
+}
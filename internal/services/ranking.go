@@ -0,0 +1,121 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/pkg/models"
+)
+
+// RankingWeights controls how price, duration, and stops are blended by the "recommended" sort
+type RankingWeights struct {
+	Price    float64
+	Duration float64
+	Stops    float64
+}
+
+// LoadRankingWeights loads recommended-sort weights from the environment
+func LoadRankingWeights() RankingWeights {
+	return RankingWeights{
+		Price:    config.GetFloat("RECOMMENDED_WEIGHT_PRICE", 0.5),
+		Duration: config.GetFloat("RECOMMENDED_WEIGHT_DURATION", 0.35),
+		Stops:    config.GetFloat("RECOMMENDED_WEIGHT_STOPS", 0.15),
+	}
+}
+
+// maxScoredStops is the stop count that receives the full stops penalty
+const maxScoredStops = 3
+
+// scoreFlightPaths assigns each path a score from 0 to 100 (higher is better). Price and
+// duration are min-max normalized across the candidate set so weights stay comparable.
+func scoreFlightPaths(paths []models.FlightPath, weights RankingWeights) {
+	if len(paths) == 0 {
+		return
+	}
+
+	totalWeight := weights.Price + weights.Duration + weights.Stops
+	if totalWeight <= 0 {
+		return
+	}
+
+	minPrice, maxPrice := paths[0].TotalPrice.Float64(), paths[0].TotalPrice.Float64()
+	minTime, maxTime := paths[0].TotalTime, paths[0].TotalTime
+	for _, path := range paths[1:] {
+		minPrice = math.Min(minPrice, path.TotalPrice.Float64())
+		maxPrice = math.Max(maxPrice, path.TotalPrice.Float64())
+		if path.TotalTime < minTime {
+			minTime = path.TotalTime
+		}
+		if path.TotalTime > maxTime {
+			maxTime = path.TotalTime
+		}
+	}
+
+	for i := range paths {
+		cost := weights.Price*normalize(paths[i].TotalPrice.Float64(), minPrice, maxPrice) +
+			weights.Duration*normalize(float64(paths[i].TotalTime), float64(minTime), float64(maxTime)) +
+			weights.Stops*math.Min(float64(paths[i].Stops)/maxScoredStops, 1)
+		paths[i].Score = math.Round((1-cost/totalWeight)*10000) / 100
+	}
+}
+
+// normalize maps value into [0, 1] relative to the given range
+func normalize(value, min, max float64) float64 {
+	if max <= min {
+		return 0
+	}
+	return (value - min) / (max - min)
+}
+
+// sortByScore scores paths and orders them best first, breaking ties by price
+func sortByScore(paths []models.FlightPath, weights RankingWeights) {
+	scoreFlightPaths(paths, weights)
+	sort.SliceStable(paths, func(i, j int) bool {
+		if paths[i].Score != paths[j].Score {
+			return paths[i].Score > paths[j].Score
+		}
+		return paths[i].TotalPrice.Minor < paths[j].TotalPrice.Minor
+	})
+}
+
+// applyDiversity walks sorted paths and keeps at most limit of them, skipping any path that
+// would exceed a per-first-leg, per-airline, or per-departure-hour cap
+func applyDiversity(paths []models.FlightPath, opts models.DiversityOptions, limit int) []models.FlightPath {
+	firstLegCounts := make(map[int]int)
+	airlineCounts := make(map[string]int)
+	hourCounts := make(map[string]int)
+
+	result := make([]models.FlightPath, 0, min(len(paths), limit))
+	for _, path := range paths {
+		if len(result) >= limit {
+			break
+		}
+		if len(path.Flights) == 0 {
+			continue
+		}
+
+		firstLeg := path.Flights[0]
+		airline := firstLeg.AirlineCode()
+		hour := fmt.Sprintf("%s-%02d", firstLeg.DepartureTime.Format("2006-01-02"), firstLeg.DepartureTime.Hour())
+
+		if exceeds(firstLegCounts[firstLeg.ID], opts.MaxPerFirstLeg) ||
+			exceeds(airlineCounts[airline], opts.MaxPerAirline) ||
+			exceeds(hourCounts[hour], opts.MaxPerDepartureHour) {
+			continue
+		}
+
+		firstLegCounts[firstLeg.ID]++
+		airlineCounts[airline]++
+		hourCounts[hour]++
+		result = append(result, path)
+	}
+
+	return result
+}
+
+// exceeds reports whether adding one more item would pass a cap (0 means unlimited)
+func exceeds(count, max int) bool {
+	return max > 0 && count >= max
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"expvar"
+	"fmt"
+	"log"
+
+	"cred_flights_booking/internal/config"
+)
+
+// routeGraphStats exposes route graph counters (refreshes, pairs, pruned_queries)
+var routeGraphStats = expvar.NewMap("route_graph")
+
+// refreshRouteGraphQuery rebuilds route_graph: the fewest legs between every pair of
+// airports over routes with upcoming flights, ignoring dates and connection windows, so
+// it is a lower bound for any dated search
+const refreshRouteGraphQuery = `
+	INSERT INTO route_graph (source, destination, min_legs, refreshed_at)
+	WITH RECURSIVE routes AS (
+		SELECT DISTINCT source, destination
+		FROM flights
+		WHERE status <> 'cancelled' AND departure_time >= CURRENT_DATE
+	),
+	reach(source, destination, legs) AS (
+		SELECT source, destination, 1 FROM routes
+		UNION
+		SELECT r.source, routes.destination, r.legs + 1
+		FROM reach r
+		JOIN routes ON routes.source = r.destination
+		WHERE r.legs < $1 AND routes.destination <> r.source
+	)
+	SELECT source, destination, MIN(legs), NOW()
+	FROM reach
+	GROUP BY source, destination
+`
+
+// routeGraph is an in-memory copy of route_graph
+type routeGraph struct {
+	minLegs map[string]map[string]int // By source, then destination
+	maxLegs int                       // Deepest level computed; pairs further apart are absent
+}
+
+// RefreshRouteGraph recomputes the route graph table and reloads it into memory
+func (fs *FlightService) RefreshRouteGraph(ctx context.Context) error {
+	if !config.GetBool("ROUTE_GRAPH_ENABLED", true) {
+		fs.routes.Store(nil)
+		return nil
+	}
+	maxLegs := config.GetInt("ROUTE_GRAPH_MAX_LEGS", 5)
+
+	err := fs.db.RetryTransaction(ctx, nil, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM route_graph"); err != nil {
+			return fmt.Errorf("failed to clear route graph: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, refreshRouteGraphQuery, maxLegs); err != nil {
+			return fmt.Errorf("failed to compute route graph: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	graph, err := fs.loadRouteGraph(ctx, maxLegs)
+	if err != nil {
+		return err
+	}
+	fs.routes.Store(graph)
+
+	pairs := 0
+	for _, destinations := range graph.minLegs {
+		pairs += len(destinations)
+	}
+	routeGraphStats.Add("refreshes", 1)
+	pairCount := new(expvar.Int)
+	pairCount.Set(int64(pairs))
+	routeGraphStats.Set("pairs", pairCount)
+	log.Printf("Route graph refreshed: %d airport pairs within %d legs", pairs, maxLegs)
+	return nil
+}
+
+// loadRouteGraph reads the route graph table
+func (fs *FlightService) loadRouteGraph(ctx context.Context, maxLegs int) (*routeGraph, error) {
+	rows, err := fs.db.QueryContext(ctx, "SELECT source, destination, min_legs FROM route_graph")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load route graph: %w", err)
+	}
+	defer rows.Close()
+
+	graph := &routeGraph{minLegs: make(map[string]map[string]int), maxLegs: maxLegs}
+	for rows.Next() {
+		var source, destination string
+		var legs int
+		if err := rows.Scan(&source, &destination, &legs); err != nil {
+			return nil, fmt.Errorf("failed to scan route graph: %w", err)
+		}
+		if graph.minLegs[source] == nil {
+			graph.minLegs[source] = make(map[string]int)
+		}
+		graph.minLegs[source][destination] = legs
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read route graph: %w", err)
+	}
+	return graph, nil
+}
+
+// canReachWithin reports whether a path of at most legs flights may exist between two
+// airports. It answers true when the graph cannot tell: before it is loaded, or for a
+// source with no routes in it (e.g. one added since the last refresh).
+func (fs *FlightService) canReachWithin(source, destination string, legs int) bool {
+	graph := fs.routes.Load()
+	if graph == nil || legs > graph.maxLegs {
+		return true
+	}
+	destinations, ok := graph.minLegs[source]
+	if !ok {
+		return true
+	}
+
+	minLegs, ok := destinations[destination]
+	if ok && minLegs <= legs {
+		return true
+	}
+	routeGraphStats.Add("pruned_queries", 1)
+	return false
+}
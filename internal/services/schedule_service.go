@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/internal/events"
+	"cred_flights_booking/pkg/models"
+)
+
+// ScheduleService manages recurring flight schedules and materializes them into flights
+type ScheduleService struct {
+	db          *database.DB
+	events      *events.Bus
+	horizonDays int
+}
+
+// NewScheduleService creates a new schedule service
+func NewScheduleService(db *database.DB, bus *events.Bus, horizonDays int) *ScheduleService {
+	return &ScheduleService{
+		db:          db,
+		events:      bus,
+		horizonDays: horizonDays,
+	}
+}
+
+// CreateSchedule stores a new schedule template
+func (ss *ScheduleService) CreateSchedule(ctx context.Context, schedule *models.FlightSchedule) (*models.FlightSchedule, error) {
+	if err := schedule.Validate(); err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO flight_schedules (flight_number, source, destination, departure_time, arrival_time,
+		                              arrival_day_offset, days_of_week, valid_from, valid_to, total_seats, price)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, active, created_at
+	`
+
+	err := ss.db.QueryRowContext(ctx, query,
+		schedule.FlightNumber, schedule.Source, schedule.Destination, schedule.DepartureTime, schedule.ArrivalTime,
+		schedule.ArrivalDayOffset, schedule.DaysOfWeek, schedule.ValidFrom, schedule.ValidTo, schedule.TotalSeats, schedule.Price,
+	).Scan(&schedule.ID, &schedule.Active, &schedule.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schedule: %w", err)
+	}
+
+	return schedule, nil
+}
+
+// ListSchedules returns all schedule templates
+func (ss *ScheduleService) ListSchedules(ctx context.Context) ([]models.FlightSchedule, error) {
+	query := `
+		SELECT id, flight_number, source, destination,
+		       to_char(departure_time, 'HH24:MI'), to_char(arrival_time, 'HH24:MI'), arrival_day_offset,
+		       days_of_week, to_char(valid_from, 'YYYY-MM-DD'), to_char(valid_to, 'YYYY-MM-DD'),
+		       total_seats, price, active, created_at
+		FROM flight_schedules
+		ORDER BY flight_number, valid_from
+	`
+
+	rows, err := ss.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var schedules []models.FlightSchedule
+	for rows.Next() {
+		var s models.FlightSchedule
+		err := rows.Scan(
+			&s.ID, &s.FlightNumber, &s.Source, &s.Destination,
+			&s.DepartureTime, &s.ArrivalTime, &s.ArrivalDayOffset,
+			&s.DaysOfWeek, &s.ValidFrom, &s.ValidTo,
+			&s.TotalSeats, &s.Price, &s.Active, &s.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan schedule: %w", err)
+		}
+		schedules = append(schedules, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schedules: %w", err)
+	}
+
+	return schedules, nil
+}
+
+// Materialize generates concrete flight rows for every active schedule date in the
+// horizon that does not have one yet. It is idempotent and safe to run repeatedly.
+func (ss *ScheduleService) Materialize(ctx context.Context) (*models.MaterializeResponse, error) {
+	from := time.Now().Format("2006-01-02")
+	to := time.Now().AddDate(0, 0, ss.horizonDays).Format("2006-01-02")
+
+	query := `
+		INSERT INTO flights (flight_number, source, destination, departure_time, arrival_time,
+		                     total_seats, booked_seats, price, schedule_id)
+		SELECT s.flight_number, s.source, s.destination,
+		       d::date + s.departure_time,
+		       d::date + s.arrival_day_offset * INTERVAL '1 day' + s.arrival_time,
+		       s.total_seats, 0, s.price, s.id
+		FROM flight_schedules s,
+		     generate_series(GREATEST(s.valid_from, $1::date), LEAST(s.valid_to, $2::date), INTERVAL '1 day') AS d
+		WHERE s.active
+		  AND POSITION(EXTRACT(ISODOW FROM d)::text IN s.days_of_week) > 0
+		  AND NOT EXISTS (
+			SELECT 1 FROM flights f
+			WHERE f.schedule_id = s.id AND DATE(f.departure_time) = d::date
+		  )
+		RETURNING ` + flightColumns
+
+	rows, err := ss.db.QueryContext(ctx, query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to materialize schedules: %w", err)
+	}
+	defer rows.Close()
+
+	type createdFlight struct {
+		flight     *models.Flight
+		scheduleID *int
+	}
+	var createdFlights []createdFlight
+	for rows.Next() {
+		flight, scheduleID, err := scanFlightRow(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan materialized flight: %w", err)
+		}
+		createdFlights = append(createdFlights, createdFlight{flight, scheduleID})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read materialized rows: %w", err)
+	}
+	created := int64(len(createdFlights))
+
+	// Give every materialized flight its own date-scoped inventory
+	inventoryQuery := `
+		INSERT INTO flight_inventory (flight_id, date, total_seats, booked_seats)
+		SELECT id, DATE(departure_time), total_seats, booked_seats
+		FROM flights
+		WHERE schedule_id IS NOT NULL AND DATE(departure_time) BETWEEN $1::date AND $2::date
+		ON CONFLICT (flight_id, date) DO NOTHING
+	`
+	if _, err := ss.db.ExecContext(ctx, inventoryQuery, from, to); err != nil {
+		return nil, fmt.Errorf("failed to create inventory for materialized flights: %w", err)
+	}
+
+	for _, c := range createdFlights {
+		publishFlightEvent(ctx, ss.events, models.EventFlightCreated, c.flight, c.scheduleID, "")
+	}
+
+	log.Printf("Materialized %d flights from schedules between %s and %s", created, from, to)
+	return &models.MaterializeResponse{From: from, To: to, Created: created}, nil
+}
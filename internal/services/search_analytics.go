@@ -0,0 +1,318 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/pkg/models"
+	"github.com/go-redis/redis/v8"
+)
+
+var (
+	// ErrSearchThrottled is returned when a flagged subject exceeds its throttled search rate
+	ErrSearchThrottled = errors.New("search rate throttled")
+	// ErrSearchAnomalyNotFound is returned when a subject has no anomaly or search activity
+	ErrSearchAnomalyNotFound = errors.New("search anomaly not found")
+)
+
+// searchAbuseStats exposes search abuse counters (anomalies, throttled_searches)
+var searchAbuseStats = expvar.NewMap("search_abuse")
+
+// recordSearchScript adds a search to a subject's sliding window, refusing it first when
+// the subject is throttled and over its per-minute rate.
+// KEYS: activity set, throttle marker, throttle rate counter.
+// ARGV: now (ms), window (ms), member, entries kept, throttled searches per minute.
+// Returns {-1} when refused, else {1, members in the window}.
+const recordSearchScript = `
+if redis.call('EXISTS', KEYS[2]) == 1 then
+	local rate = redis.call('INCR', KEYS[3])
+	if rate == 1 then
+		redis.call('EXPIRE', KEYS[3], 60)
+	end
+	if rate > tonumber(ARGV[5]) then
+		return {-1}
+	end
+end
+
+redis.call('ZADD', KEYS[1], ARGV[1], ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', tonumber(ARGV[1]) - tonumber(ARGV[2]))
+redis.call('ZREMRANGEBYRANK', KEYS[1], 0, -tonumber(ARGV[4]) - 1)
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+
+return {1, redis.call('ZRANGE', KEYS[1], 0, -1)}
+`
+
+// SearchAbuseConfig controls search activity tracking and abuse heuristics
+type SearchAbuseConfig struct {
+	Window           time.Duration // Sliding window searches are counted over
+	MaxSearches      int           // Searches in the window above which a subject is flagged
+	MaxDatesPerRoute int           // Distinct dates of one route above which a subject is flagged
+	MaxRoutes        int           // Distinct routes above which a subject is flagged
+	AnomalyTTL       time.Duration // How long a flagged subject is reported
+	Throttle         bool          // Whether flagged subjects are throttled
+	ThrottleDuration time.Duration // How long a flagged subject stays throttled
+	ThrottleRate     int           // Searches per minute a throttled subject is allowed
+}
+
+// LoadSearchAbuseConfig loads search abuse settings from the environment
+func LoadSearchAbuseConfig() SearchAbuseConfig {
+	return SearchAbuseConfig{
+		Window:           config.GetDuration("SEARCH_ABUSE_WINDOW", 10*time.Minute),
+		MaxSearches:      config.GetInt("SEARCH_ABUSE_MAX_SEARCHES", 200),
+		MaxDatesPerRoute: config.GetInt("SEARCH_ABUSE_MAX_DATES_PER_ROUTE", 14),
+		MaxRoutes:        config.GetInt("SEARCH_ABUSE_MAX_ROUTES", 40),
+		AnomalyTTL:       config.GetDuration("SEARCH_ABUSE_ANOMALY_TTL", 24*time.Hour),
+		Throttle:         config.GetBool("SEARCH_ABUSE_THROTTLE", false),
+		ThrottleDuration: config.GetDuration("SEARCH_ABUSE_THROTTLE_DURATION", 30*time.Minute),
+		ThrottleRate:     config.GetInt("SEARCH_ABUSE_THROTTLE_RATE", 10),
+	}
+}
+
+// reasons returns the heuristics a subject's activity trips
+func (c SearchAbuseConfig) reasons(activity *models.SearchActivity) []string {
+	var reasons []string
+	if activity.Searches > c.MaxSearches {
+		reasons = append(reasons, models.SearchAnomalyVolume)
+	}
+	if activity.MaxDatesPerRoute > c.MaxDatesPerRoute {
+		reasons = append(reasons, models.SearchAnomalyDateSweep)
+	}
+	if activity.Routes > c.MaxRoutes {
+		reasons = append(reasons, models.SearchAnomalyRouteSweep)
+	}
+	return reasons
+}
+
+// SearchAnalyticsService tracks search volume per client and flags scraping patterns
+type SearchAnalyticsService struct {
+	cache *database.RedisClient
+	cfg   SearchAbuseConfig
+}
+
+// NewSearchAnalyticsService creates a new search analytics service
+func NewSearchAnalyticsService(cache *database.RedisClient) *SearchAnalyticsService {
+	return &SearchAnalyticsService{
+		cache: cache,
+		cfg:   LoadSearchAbuseConfig(),
+	}
+}
+
+// RecordSearch counts a search of route on date against each subject's window, flagging
+// subjects that trip a heuristic. It returns ErrSearchThrottled, with the time until the
+// subject's rate resets, when a throttled subject is over its rate.
+func (sa *SearchAnalyticsService) RecordSearch(ctx context.Context, subjects []string, route, date string) (time.Duration, error) {
+	now := time.Now()
+	member := fmt.Sprintf("%s|%s|%d", route, date, now.UnixNano())
+
+	for _, subject := range subjects {
+		keys := []string{
+			database.GenerateSearchActivityKey(subject),
+			database.GenerateSearchThrottleKey(subject),
+			database.GenerateSearchThrottleRateKey(subject, now.Unix()/60),
+		}
+		result, err := sa.cache.Eval(ctx, recordSearchScript, keys,
+			now.UnixMilli(), sa.cfg.Window.Milliseconds(), member, sa.cfg.MaxSearches+1, sa.cfg.ThrottleRate).Result()
+		if err != nil {
+			return 0, fmt.Errorf("failed to record search: %w", err)
+		}
+
+		values, ok := result.([]interface{})
+		if !ok || len(values) == 0 {
+			return 0, fmt.Errorf("unexpected search record result: %v", result)
+		}
+		if status, _ := values[0].(int64); status == -1 {
+			searchAbuseStats.Add("throttled_searches", 1)
+			return time.Duration(60-now.Second()) * time.Second, ErrSearchThrottled
+		}
+
+		var members []string
+		if len(values) > 1 {
+			raw, _ := values[1].([]interface{})
+			for _, value := range raw {
+				if s, ok := value.(string); ok {
+					members = append(members, s)
+				}
+			}
+		}
+
+		activity := summarizeSearchActivity(subject, members)
+		if reasons := sa.cfg.reasons(activity); len(reasons) > 0 {
+			if err := sa.flag(ctx, activity, reasons, now); err != nil {
+				log.Printf("Failed to flag search anomaly for %s: %v", subject, err)
+			}
+		}
+	}
+
+	volumeKey := database.GenerateSearchVolumeKey(now.Unix() / 60)
+	pipe := sa.cache.TxPipeline()
+	pipe.Incr(ctx, volumeKey)
+	pipe.Expire(ctx, volumeKey, sa.cfg.Window+time.Minute)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to count search volume: %w", err)
+	}
+	return 0, nil
+}
+
+// summarizeSearchActivity counts searches, routes, and the most dates of one route among
+// window members ("route|date|nanos")
+func summarizeSearchActivity(subject string, members []string) *models.SearchActivity {
+	activity := &models.SearchActivity{Subject: subject, Searches: len(members)}
+
+	dates := make(map[string]map[string]bool)
+	for _, member := range members {
+		parts := strings.SplitN(member, "|", 3)
+		if len(parts) < 2 {
+			continue
+		}
+		route, date := parts[0], parts[1]
+		if dates[route] == nil {
+			dates[route] = make(map[string]bool)
+		}
+		dates[route][date] = true
+	}
+
+	activity.Routes = len(dates)
+	for route, routeDates := range dates {
+		if len(routeDates) > activity.MaxDatesPerRoute || (len(routeDates) == activity.MaxDatesPerRoute && route < activity.TopRoute) {
+			activity.MaxDatesPerRoute = len(routeDates)
+			activity.TopRoute = route
+		}
+	}
+	return activity
+}
+
+// flag records a subject's anomaly and, when enabled, starts throttling it
+func (sa *SearchAnalyticsService) flag(ctx context.Context, activity *models.SearchActivity, reasons []string, now time.Time) error {
+	anomalyKey := database.GenerateSearchAnomalyKey(activity.Subject)
+	known, err := sa.cache.KeyExists(ctx, anomalyKey)
+	if err != nil {
+		return err
+	}
+
+	anomaly := models.SearchAnomaly{
+		SearchActivity: *activity,
+		Reasons:        reasons,
+		DetectedAt:     now.UTC(),
+	}
+
+	if sa.cfg.Throttle {
+		throttleKey := database.GenerateSearchThrottleKey(activity.Subject)
+		if _, err := sa.cache.SetNX(ctx, throttleKey, now.Unix(), sa.cfg.ThrottleDuration).Result(); err != nil {
+			return fmt.Errorf("failed to throttle subject: %w", err)
+		}
+		ttl, err := sa.cache.PTTL(ctx, throttleKey).Result()
+		if err != nil {
+			return fmt.Errorf("failed to read throttle expiry: %w", err)
+		}
+		if ttl > 0 {
+			until := now.Add(ttl).UTC()
+			anomaly.ThrottledUntil = &until
+		}
+	}
+
+	if err := sa.cache.SetJSON(ctx, anomalyKey, anomaly, sa.cfg.AnomalyTTL); err != nil {
+		return err
+	}
+	if err := sa.cache.ZAdd(ctx, database.GenerateSearchAnomaliesKey(), &redis.Z{Score: float64(now.Unix()), Member: activity.Subject}).Err(); err != nil {
+		return fmt.Errorf("failed to index search anomaly: %w", err)
+	}
+
+	if !known {
+		searchAbuseStats.Add("anomalies", 1)
+		log.Printf("SEARCH_ANOMALY subject=%s reasons=%s searches=%d routes=%d max_dates_per_route=%d top_route=%s throttled=%t",
+			activity.Subject, strings.Join(reasons, ","), activity.Searches, activity.Routes,
+			activity.MaxDatesPerRoute, activity.TopRoute, anomaly.ThrottledUntil != nil)
+	}
+	return nil
+}
+
+// Activity summarizes a subject's searches in the current window
+func (sa *SearchAnalyticsService) Activity(ctx context.Context, subject string) (*models.SearchActivity, error) {
+	since := time.Now().Add(-sa.cfg.Window).UnixMilli()
+	members, err := sa.cache.ZRangeByScore(ctx, database.GenerateSearchActivityKey(subject), &redis.ZRangeBy{
+		Min: strconv.FormatInt(since, 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read search activity: %w", err)
+	}
+	return summarizeSearchActivity(subject, members), nil
+}
+
+// Anomalies reports the most recently flagged subjects (up to limit) and the number of
+// searches across all clients in the window
+func (sa *SearchAnalyticsService) Anomalies(ctx context.Context, limit int) (*models.SearchAnomalyReport, error) {
+	now := time.Now()
+	indexKey := database.GenerateSearchAnomaliesKey()
+
+	expired := strconv.FormatInt(now.Add(-sa.cfg.AnomalyTTL).Unix(), 10)
+	if err := sa.cache.ZRemRangeByScore(ctx, indexKey, "-inf", "("+expired).Err(); err != nil {
+		return nil, fmt.Errorf("failed to trim search anomalies: %w", err)
+	}
+	subjects, err := sa.cache.ZRevRange(ctx, indexKey, 0, int64(limit)-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list search anomalies: %w", err)
+	}
+
+	report := &models.SearchAnomalyReport{
+		Window:     sa.cfg.Window.String(),
+		Throttling: sa.cfg.Throttle,
+		Anomalies:  []models.SearchAnomaly{},
+	}
+	for _, subject := range subjects {
+		var anomaly models.SearchAnomaly
+		if err := sa.cache.GetJSON(ctx, database.GenerateSearchAnomalyKey(subject), &anomaly); err != nil {
+			continue
+		}
+		if anomaly.ThrottledUntil != nil && anomaly.ThrottledUntil.Before(now) {
+			anomaly.ThrottledUntil = nil
+		}
+		report.Anomalies = append(report.Anomalies, anomaly)
+	}
+	report.Count = len(report.Anomalies)
+
+	var volumeKeys []string
+	for minute := now.Add(-sa.cfg.Window).Unix() / 60; minute <= now.Unix()/60; minute++ {
+		volumeKeys = append(volumeKeys, database.GenerateSearchVolumeKey(minute))
+	}
+	counts, err := sa.cache.MGet(ctx, volumeKeys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read search volume: %w", err)
+	}
+	for _, count := range counts {
+		if s, ok := count.(string); ok {
+			n, _ := strconv.Atoi(s)
+			report.GlobalSearches += n
+		}
+	}
+
+	return report, nil
+}
+
+// ClearSubject forgives a flagged subject: its anomaly, throttle, and window are removed
+func (sa *SearchAnalyticsService) ClearSubject(ctx context.Context, subject string) error {
+	removed, err := sa.cache.Del(ctx,
+		database.GenerateSearchAnomalyKey(subject),
+		database.GenerateSearchThrottleKey(subject),
+		database.GenerateSearchActivityKey(subject),
+	).Result()
+	if err != nil {
+		return fmt.Errorf("failed to clear search anomaly: %w", err)
+	}
+	unindexed, err := sa.cache.ZRem(ctx, database.GenerateSearchAnomaliesKey(), subject).Result()
+	if err != nil {
+		return fmt.Errorf("failed to unindex search anomaly: %w", err)
+	}
+	if removed == 0 && unindexed == 0 {
+		return ErrSearchAnomalyNotFound
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/experiments"
+	"cred_flights_booking/internal/funnel"
+	"cred_flights_booking/pkg/models"
+	"golang.org/x/sync/errgroup"
+)
+
+// MaxBatchSearches returns the most route and date searches accepted in one batch search
+func MaxBatchSearches() int {
+	return config.GetInt("SEARCH_BATCH_MAX_SEARCHES", 14)
+}
+
+// batchSearchConcurrency returns how many searches of one batch run in parallel
+func batchSearchConcurrency() int {
+	return max(config.GetInt("SEARCH_BATCH_CONCURRENCY", 4), 1)
+}
+
+// SearchFlightsBatch runs the searches of a batch with bounded parallelism. Every search
+// shares the user's experiment variants; a search that fails is reported on its result.
+func (fs *FlightService) SearchFlightsBatch(ctx context.Context, req *models.BatchSearchRequest) (*models.BatchSearchResponse, error) {
+	assignments := fs.experiments.Assign(req.UserID)
+	ctx = experiments.WithAssignments(ctx, assignments)
+
+	// One route on several dates is keyed by date, routes by route and date
+	results := make(map[string]*models.BatchSearchResult)
+	if len(req.Dates) > 0 {
+		source := strings.ToUpper(strings.TrimSpace(req.Source))
+		destination := strings.ToUpper(strings.TrimSpace(req.Destination))
+		for _, date := range req.Dates {
+			date = strings.TrimSpace(date)
+			results[date] = &models.BatchSearchResult{RouteDate: models.RouteDate{Source: source, Destination: destination, Date: date}}
+		}
+	} else {
+		for _, route := range req.Routes {
+			route = models.RouteDate{
+				Source:      strings.ToUpper(strings.TrimSpace(route.Source)),
+				Destination: strings.ToUpper(strings.TrimSpace(route.Destination)),
+				Date:        strings.TrimSpace(route.Date),
+			}
+			results[route.Source+"-"+route.Destination+":"+route.Date] = &models.BatchSearchResult{RouteDate: route}
+		}
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(batchSearchConcurrency())
+	for _, result := range results {
+		result := result
+		g.Go(func() error {
+			fs.batchSearch(gctx, req, result)
+			return nil
+		})
+	}
+	g.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	fs.experiments.RecordExposures(ctx, assignments)
+	return &models.BatchSearchResponse{Results: results, Experiments: assignments.Tags()}, nil
+}
+
+// batchSearch runs one search of a batch. Errors are reported on the result.
+func (fs *FlightService) batchSearch(ctx context.Context, batch *models.BatchSearchRequest, result *models.BatchSearchResult) {
+	result.Paths = []models.FlightPath{}
+	if len(result.Source) != 3 || len(result.Destination) != 3 || result.Source == result.Destination {
+		result.Error = "invalid source or destination"
+		return
+	}
+	if _, err := time.Parse("2006-01-02", result.Date); err != nil {
+		result.Error = "invalid date, expected YYYY-MM-DD"
+		return
+	}
+
+	response, err := fs.search(ctx, &models.SearchRequest{
+		Source:      result.Source,
+		Destination: result.Destination,
+		Date:        result.Date,
+		Seats:       batch.Seats,
+		SortBy:      batch.SortBy,
+		Airlines:    batch.Airlines,
+		UserID:      batch.UserID,
+		MaxStops:    batch.MaxStops,
+	})
+	if err != nil {
+		log.Printf("Batch search failed for %s-%s on %s: %v", result.Source, result.Destination, result.Date, err)
+		result.Error = "search failed"
+		return
+	}
+
+	fs.applyPricing(ctx, response.Paths)
+	if response.Paths != nil {
+		result.Paths = response.Paths
+	}
+	result.Count = response.Count
+	fs.funnel.Track(ctx, funnel.StageSearch, funnel.Route(result.Source, result.Destination))
+}
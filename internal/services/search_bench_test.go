@@ -0,0 +1,329 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/internal/events"
+	"cred_flights_booking/internal/experiments"
+	"cred_flights_booking/pkg/models"
+)
+
+// profSchema holds the seeded flights and route graph; the service reads it through
+// DB_SEARCH_PATH, so the real tables are never touched
+const profSchema = "searchprof"
+
+// seedFlightsQuery generates $1 flights prefixed $5 between $2 airports over $4 days from $3.
+// Hub airports get most of the traffic, about 15% of flights are full and 3% cancelled.
+const seedFlightsQuery = `
+	INSERT INTO flights (id, flight_number, source, destination, departure_time, arrival_time,
+	                     total_seats, booked_seats, price, created_at, status)
+	SELECT g, $5::text || g,
+	       chr(65 + s / 26) || chr(65 + s % 26) || 'X',
+	       chr(65 + d / 26) || chr(65 + d % 26) || 'X',
+	       dep, dep + make_interval(mins => 60 + floor(random() * 240)::int),
+	       180,
+	       CASE WHEN random() < 0.15 THEN 180 ELSE floor(random() * 180)::int END,
+	       2000 + floor(random() * 8000),
+	       NOW(),
+	       CASE WHEN random() < 0.03 THEN 'cancelled' ELSE 'scheduled' END
+	FROM (
+		SELECT g, s,
+		       (s + 1 + floor(power(random(), 2) * ($2::int - 1))::int) % $2::int AS d,
+		       $3::date + floor(random() * $4::int)::int + make_interval(mins => floor(random() * 1440)::int) AS dep
+		FROM (
+			SELECT g, floor(power(random(), 2) * $2::int)::int AS s
+			FROM generate_series(1, $1::int) g
+		) sources
+	) generated
+`
+
+// searchFixture is the seeded flights and the service searching them, shared by the
+// search benchmarks of one run
+type searchFixture struct {
+	db        *database.DB
+	cache     *database.RedisClient
+	service   *FlightService
+	scenarios []searchScenario
+}
+
+// searchScenario is the sampled searches, each limited to the same number of stops
+type searchScenario struct {
+	stops    int
+	searches []models.SearchRequest
+}
+
+// searchFixtureOnce seeds the fixture for the first search benchmark that needs it
+var (
+	searchFixtureOnce sync.Once
+	sharedFixture     *searchFixture
+	searchFixtureErr  error
+	searchFixtureSkip string
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if sharedFixture != nil {
+		if !config.GetBool("SEARCH_BENCH_KEEP", false) {
+			sharedFixture.cleanup(context.Background())
+		}
+		sharedFixture.db.Close()
+		sharedFixture.cache.Close()
+	}
+	os.Exit(code)
+}
+
+// BenchmarkSearchFlights benchmarks SearchFlights end to end for each search depth, first
+// with the search cache warm (the projection of each search already cached) and then with
+// it evicted before every search
+func BenchmarkSearchFlights(b *testing.B) {
+	f := loadSearchFixture(b)
+	ctx := context.Background()
+
+	for _, s := range f.scenarios {
+		for _, cold := range []bool{false, true} {
+			name := fmt.Sprintf("stops_%d/cache_hit", s.stops)
+			if cold {
+				name = fmt.Sprintf("stops_%d/cache_miss", s.stops)
+			}
+
+			b.Run(name, func(b *testing.B) {
+				// Warm the cache, so the hit variant never goes to the database
+				paths := 0
+				for i := range s.searches {
+					response, err := f.service.SearchFlights(ctx, &s.searches[i])
+					if err != nil {
+						b.Fatalf("Failed to warm search: %v", err)
+					}
+					paths += response.Count
+				}
+
+				defer quietServiceLogs()()
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					req := s.searches[i%len(s.searches)]
+					if cold {
+						b.StopTimer()
+						if err := evictSearch(ctx, f.cache, &req); err != nil {
+							b.Fatal(err)
+						}
+						b.StartTimer()
+					}
+					if _, err := f.service.SearchFlights(ctx, &req); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(paths)/float64(len(s.searches)), "paths/search")
+			})
+		}
+	}
+}
+
+// loadSearchFixture returns the shared search fixture, seeding it on first use; TestMain
+// drops it after the run. Benchmarks are skipped unless the environment points the service
+// at the profiling schema and cache namespace, and PostgreSQL and Redis are reachable.
+func loadSearchFixture(b *testing.B) *searchFixture {
+	b.Helper()
+	searchFixtureOnce.Do(func() {
+		// Refuse to run against the real tables and cache keys
+		if !strings.HasPrefix(config.GetEnv("DB_SEARCH_PATH", ""), profSchema) {
+			searchFixtureSkip = fmt.Sprintf("DB_SEARCH_PATH must start with %s (e.g. %s,public)", profSchema, profSchema)
+			return
+		}
+		if database.KeyPrefix() != profSchema+":" {
+			searchFixtureSkip = "CACHE_KEY_PREFIX must be " + profSchema
+			return
+		}
+		db, err := database.NewPostgresDB()
+		if err != nil {
+			searchFixtureSkip = fmt.Sprintf("PostgreSQL unavailable: %v", err)
+			return
+		}
+		cache, err := database.NewRedisClient()
+		if err != nil {
+			db.Close()
+			searchFixtureSkip = fmt.Sprintf("Redis unavailable: %v", err)
+			return
+		}
+		sharedFixture = &searchFixture{db: db, cache: cache}
+		searchFixtureErr = sharedFixture.setup(context.Background())
+	})
+	if searchFixtureSkip != "" {
+		b.Skip(searchFixtureSkip)
+	}
+	if searchFixtureErr != nil {
+		b.Fatal(searchFixtureErr)
+	}
+	return sharedFixture
+}
+
+// setup seeds the profiling schema, builds the route graph, and draws the searches
+func (f *searchFixture) setup(ctx context.Context) error {
+	airports := config.GetInt("SEARCH_BENCH_AIRPORTS", 60)
+	days := config.GetInt("SEARCH_BENCH_DAYS", 14)
+	if airports < 2 || airports > 26*26 {
+		return fmt.Errorf("SEARCH_BENCH_AIRPORTS must be between 2 and %d", 26*26)
+	}
+
+	if err := f.seed(ctx, config.GetInt("SEARCH_BENCH_FLIGHTS", 100000), airports, days); err != nil {
+		return fmt.Errorf("failed to seed flights: %w", err)
+	}
+
+	registry, err := experiments.LoadRegistry(f.cache)
+	if err != nil {
+		return fmt.Errorf("failed to load experiments: %w", err)
+	}
+	bus := events.NewBus(f.cache, profSchema, int64(config.GetInt("EVENT_STREAM_MAX_LEN", 100000)))
+	f.service = NewFlightService(f.db, f.cache, bus, registry, config.GetEnv("BOOKING_SERVICE_URL", "http://localhost:8081"))
+	if err := f.service.RefreshRouteGraph(ctx); err != nil {
+		return fmt.Errorf("failed to build route graph: %w", err)
+	}
+
+	rng := rand.New(rand.NewSource(int64(config.GetInt("SEARCH_BENCH_SEED", 1))))
+	if err := f.drawScenarios(ctx, rng, airports, days, config.GetInt("SEARCH_BENCH_SAMPLES", 20)); err != nil {
+		return fmt.Errorf("failed to draw searches: %w", err)
+	}
+	return nil
+}
+
+// seed recreates the profiling schema with a copy of the flights table, indexes included,
+// fills it, and adds an empty route graph for the service to compute. The pool's
+// search_path resolves the unqualified fill to the new table.
+func (f *searchFixture) seed(ctx context.Context, flights, airports, days int) error {
+	setup := []string{
+		"DROP SCHEMA IF EXISTS " + profSchema + " CASCADE",
+		"CREATE SCHEMA " + profSchema,
+		"CREATE TABLE " + profSchema + ".flights (LIKE public.flights INCLUDING ALL)",
+		"CREATE TABLE " + profSchema + ".route_graph (LIKE public.route_graph INCLUDING ALL)",
+	}
+	for _, statement := range setup {
+		if _, err := f.db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("%q: %w", statement, err)
+		}
+	}
+
+	log.Printf("Seeding %d flights between %d airports over %d days", flights, airports, days)
+	start := time.Now()
+	if _, err := f.db.ExecContext(ctx, seedFlightsQuery, flights, airports, seedStartDate().Format("2006-01-02"), days, "SP"); err != nil {
+		return fmt.Errorf("failed to insert flights: %w", err)
+	}
+	if _, err := f.db.ExecContext(ctx, "ANALYZE flights"); err != nil {
+		return fmt.Errorf("failed to analyze flights: %w", err)
+	}
+	log.Printf("Seeded in %v", time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// drawScenarios draws routes that have paths at the service's full search depth, and adds
+// a scenario for each depth from direct only up to it, searching those routes. Routes are
+// drawn from SEARCH_BENCH_SEED, so runs compare the same searches.
+func (f *searchFixture) drawScenarios(ctx context.Context, rng *rand.Rand, airports, days, samples int) error {
+	defer quietServiceLogs()()
+
+	var searches []models.SearchRequest
+	for attempt := 0; attempt < samples*50 && len(searches) < samples; attempt++ {
+		source := rng.Intn(airports)
+		destination := (source + 1 + rng.Intn(airports-1)) % airports
+		req := models.SearchRequest{
+			Source:      seedAirportCode(source),
+			Destination: seedAirportCode(destination),
+			Date:        seedStartDate().AddDate(0, 0, rng.Intn(days)).Format("2006-01-02"),
+			Seats:       1,
+			SortBy:      "cheapest",
+		}
+
+		response, err := f.service.SearchFlights(ctx, &req)
+		if err != nil {
+			return err
+		}
+		if response.Count > 0 {
+			searches = append(searches, req)
+		}
+	}
+	if len(searches) == 0 {
+		return fmt.Errorf("no seeded route has flights")
+	}
+
+	f.scenarios = make([]searchScenario, f.service.MaxSearchStops()+1)
+	for stops := range f.scenarios {
+		f.scenarios[stops].stops = stops
+		f.scenarios[stops].searches = make([]models.SearchRequest, len(searches))
+		for i, req := range searches {
+			req.MaxStops = &f.scenarios[stops].stops
+			f.scenarios[stops].searches[i] = req
+		}
+	}
+	return nil
+}
+
+// cleanup drops the profiling schema and the run's cache keys
+func (f *searchFixture) cleanup(ctx context.Context) {
+	if _, err := f.db.ExecContext(ctx, "DROP SCHEMA IF EXISTS "+profSchema+" CASCADE"); err != nil {
+		log.Printf("Failed to drop %s schema: %v", profSchema, err)
+	}
+
+	iter := f.cache.Scan(ctx, 0, database.KeyPrefix()+"*", 1000).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		log.Printf("Failed to find %s cache keys: %v", profSchema, err)
+		return
+	}
+	for start := 0; start < len(keys); start += 1000 {
+		if err := f.cache.Del(ctx, keys[start:min(start+1000, len(keys))]...).Err(); err != nil {
+			log.Printf("Failed to delete %s cache keys: %v", profSchema, err)
+			return
+		}
+	}
+}
+
+// evictSearch removes a search's cached results and sorted projections, so the next search
+// of its route reads the database. Seat counters stay cached, as they would in production.
+func evictSearch(ctx context.Context, cache *database.RedisClient, req *models.SearchRequest) error {
+	keys := []string{database.GenerateSearchCacheKey(req.Source, req.Destination, req.Date, *req.MaxStops)}
+	// Projections are keyed by route, date, and depth, then seat bucket, order, and variant
+	projections := database.GenerateSearchProjectionKey(req.Source, req.Destination, req.Date, *req.MaxStops, 0, "", "")
+	projections = strings.TrimSuffix(projections, "0::") + "*"
+
+	iter := cache.Scan(ctx, 0, projections, 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to find search projections: %w", err)
+	}
+	return cache.Del(ctx, keys...).Err()
+}
+
+// quietServiceLogs discards the service's per-search logging unless SEARCH_BENCH_SERVICE_LOGS
+// is set, and returns a func restoring it
+func quietServiceLogs() func() {
+	output := log.Writer()
+	if !config.GetBool("SEARCH_BENCH_SERVICE_LOGS", false) {
+		log.SetOutput(io.Discard)
+	}
+	return func() { log.SetOutput(output) }
+}
+
+// seedStartDate is the first day of seeded departures
+func seedStartDate() time.Time {
+	return time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 1, 0)
+}
+
+// seedAirportCode is the code the seed query gives the i-th airport
+func seedAirportCode(i int) string {
+	return string(rune('A'+i/26)) + string(rune('A'+i%26)) + "X"
+}
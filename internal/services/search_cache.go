@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"expvar"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/pkg/models"
+)
+
+// searchCacheStats exposes search cache counters (hits, misses, stale_serves, refreshes, refresh_errors)
+var searchCacheStats = expvar.NewMap("flight_search_cache")
+
+// searchProjectionStats exposes projection cache counters (hits, misses)
+var searchProjectionStats = expvar.NewMap("flight_search_projection_cache")
+
+func init() {
+	// Hit rate of each cache layer: projections first, then the route's base results
+	expvar.Publish("flight_search_hit_rate", expvar.Func(func() interface{} {
+		baseHits := counterValue(searchCacheStats, "hits") + counterValue(searchCacheStats, "stale_serves")
+		return map[string]float64{
+			"projection": hitRate(counterValue(searchProjectionStats, "hits"), counterValue(searchProjectionStats, "misses")),
+			"base":       hitRate(baseHits, counterValue(searchCacheStats, "misses")),
+		}
+	}))
+}
+
+// counterValue reads an integer counter from an expvar map (0 when unset)
+func counterValue(m *expvar.Map, key string) int64 {
+	if v, ok := m.Get(key).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+// hitRate returns hits as a fraction of lookups (0 before any lookup)
+func hitRate(hits, misses int64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
+
+// SearchCacheConfig controls freshness of cached search results
+type SearchCacheConfig struct {
+	TTL         time.Duration   // How long an entry is considered fresh, unless the policy says otherwise
+	Policy      SearchTTLPolicy // Per-route TTLs by days to departure and popularity
+	Jitter      float64         // Fraction of TTL randomly added or removed to spread expiries
+	StaleWindow time.Duration   // How long an expired entry may still be served while refreshing
+	// How long sorted paths per seat bucket and order are reused (0 disables)
+	ProjectionTTL time.Duration
+}
+
+// LoadSearchCacheConfig loads search cache settings from the environment
+func LoadSearchCacheConfig() SearchCacheConfig {
+	return SearchCacheConfig{
+		TTL: config.GetDuration("SEARCH_CACHE_TTL", 2*time.Hour),
+		Policy: SearchTTLPolicy{
+			NearDays:      config.GetInt("SEARCH_CACHE_NEAR_DAYS", 2),
+			NearTTL:       config.GetDuration("SEARCH_CACHE_NEAR_TTL", 15*time.Minute),
+			FarDays:       config.GetInt("SEARCH_CACHE_FAR_DAYS", 30),
+			FarTTL:        config.GetDuration("SEARCH_CACHE_FAR_TTL", 12*time.Hour),
+			PopularFactor: config.GetFloat("SEARCH_CACHE_POPULAR_TTL_FACTOR", 0.5),
+		},
+		Jitter:        config.GetFloat("SEARCH_CACHE_TTL_JITTER", 0.1),
+		StaleWindow:   config.GetDuration("SEARCH_CACHE_STALE_WINDOW", 10*time.Minute),
+		ProjectionTTL: config.GetDuration("SEARCH_PROJECTION_TTL", 30*time.Second),
+	}
+}
+
+// cachedSearchResult is the envelope stored under a search cache key
+type cachedSearchResult struct {
+	Flights    []models.Flight `json:"flights"`
+	FreshUntil time.Time       `json:"fresh_until"`
+}
+
+// jitter returns a fresh TTL with random jitter applied
+func (c SearchCacheConfig) jitter(ttl time.Duration) time.Duration {
+	if c.Jitter <= 0 {
+		return ttl
+	}
+	spread := float64(ttl) * c.Jitter
+	return ttl + time.Duration((rand.Float64()*2-1)*spread)
+}
+
+// getCachedSearch reads a search entry and reports whether it is still fresh
+func (fs *FlightService) getCachedSearch(ctx context.Context, cacheKey string) ([]models.Flight, bool, error) {
+	var cached cachedSearchResult
+	if err := fs.cache.GetJSON(ctx, cacheKey, &cached); err != nil {
+		return nil, false, err
+	}
+	return cached.Flights, time.Now().Before(cached.FreshUntil), nil
+}
+
+// cacheSearchResults stores a route's search results with its policy TTL, jittered, plus
+// the stale window
+func (fs *FlightService) cacheSearchResults(ctx context.Context, source, destination, date, cacheKey string, flights []models.Flight) {
+	ttl := fs.searchTTL(ctx, source, destination, date)
+	entry := cachedSearchResult{
+		Flights:    flights,
+		FreshUntil: time.Now().Add(ttl),
+	}
+
+	if err := fs.cache.SetJSON(ctx, cacheKey, entry, ttl+fs.searchCacheConfig.StaleWindow); err != nil {
+		log.Printf("Failed to cache search results: %v", err)
+	}
+}
+
+// loadSearchResults searches the database and repopulates the cache (called by singleflight)
+func (fs *FlightService) loadSearchResults(ctx context.Context, source, destination, date string, maxStops int) ([]models.Flight, error) {
+	flights, err := fs.searchFlightsFromDB(ctx, source, destination, date, maxStops)
+	if err != nil {
+		return nil, err
+	}
+
+	fs.cacheSearchResults(ctx, source, destination, date, database.GenerateSearchCacheKey(source, destination, date, maxStops), flights)
+	return flights, nil
+}
+
+// refreshSearchInBackground repopulates a stale search entry without blocking the caller
+func (fs *FlightService) refreshSearchInBackground(source, destination, date string, maxStops int) {
+	searchKey := fmt.Sprintf("%s:%s:%s:%d", source, destination, date, maxStops)
+	if _, refreshing := fs.refreshing.LoadOrStore(searchKey, struct{}{}); refreshing {
+		return
+	}
+
+	go func() {
+		defer fs.refreshing.Delete(searchKey)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		_, err := fs.loadSearchResultsShared(ctx, source, destination, date, maxStops)
+		if err != nil {
+			searchCacheStats.Add("refresh_errors", 1)
+			log.Printf("Background refresh failed for %s: %v", searchKey, err)
+			return
+		}
+		searchCacheStats.Add("refreshes", 1)
+	}()
+}
+
+// searchProjection is a route's paths with at least a seat bucket's seats, sorted for one
+// order, before the diversity caps and truncation. Available holds each path's free seats
+// when it was projected.
+type searchProjection struct {
+	Paths     []models.FlightPath `json:"paths"`
+	Available []int               `json:"available"`
+}
+
+// selectPaths narrows a projection to the paths with the requested seats and applies the
+// diversity caps and top-20 truncation
+func (p *searchProjection) selectPaths(req *models.SearchRequest) []models.FlightPath {
+	var paths []models.FlightPath
+	for i, path := range p.Paths {
+		if p.Available[i] >= req.Seats {
+			paths = append(paths, path)
+		}
+	}
+	return applyDiversity(paths, req.Diversity, 20)
+}
+
+// seatBucket returns the smallest seat count of the bucket a request falls in: 1, 2, 3-4,
+// 5-8, or 9 and up. Searches in one bucket share a projection.
+func seatBucket(seats int) int {
+	switch {
+	case seats <= 2:
+		return max(seats, 1)
+	case seats <= 4:
+		return 3
+	case seats <= 8:
+		return 5
+	default:
+		return 9
+	}
+}
+
+// searchProjectionKey identifies the projection a search can be served from. Airline
+// filters and, for the recommended order, the user's ranking weights are part of the key.
+func (fs *FlightService) searchProjectionKey(ctx context.Context, req *models.SearchRequest) string {
+	airlines := append([]string(nil), req.Airlines...)
+	sort.Strings(airlines)
+	variant := strings.Join(airlines, ",")
+	if req.SortBy == "recommended" {
+		weights := fs.rankingWeightsFor(ctx)
+		variant += fmt.Sprintf("|%g,%g,%g", weights.Price, weights.Duration, weights.Stops)
+	}
+	if variant == "" {
+		variant = "default"
+	} else {
+		sum := sha256.Sum256([]byte(variant))
+		variant = hex.EncodeToString(sum[:8])
+	}
+
+	sortBy := req.SortBy
+	if sortBy == "" {
+		sortBy = "cheapest"
+	}
+	return database.GenerateSearchProjectionKey(req.Source, req.Destination, req.Date, fs.searchDepthFor(req), seatBucket(req.Seats), sortBy, variant)
+}
+
+// getSearchProjection serves a search from a cached projection
+func (fs *FlightService) getSearchProjection(ctx context.Context, req *models.SearchRequest) ([]models.FlightPath, bool) {
+	if fs.searchCacheConfig.ProjectionTTL <= 0 {
+		return nil, false
+	}
+
+	var projection searchProjection
+	if err := fs.cache.GetJSON(ctx, fs.searchProjectionKey(ctx, req), &projection); err != nil || len(projection.Available) != len(projection.Paths) {
+		searchProjectionStats.Add("misses", 1)
+		return nil, false
+	}
+	searchProjectionStats.Add("hits", 1)
+	return projection.selectPaths(req), true
+}
+
+// cacheSearchProjection stores a search's projection for the projection TTL
+func (fs *FlightService) cacheSearchProjection(ctx context.Context, req *models.SearchRequest, projection *searchProjection) {
+	if fs.searchCacheConfig.ProjectionTTL <= 0 {
+		return
+	}
+	if err := fs.cache.SetJSON(ctx, fs.searchProjectionKey(ctx, req), projection, fs.searchCacheConfig.ProjectionTTL); err != nil {
+		log.Printf("Failed to cache search projection: %v", err)
+	}
+}
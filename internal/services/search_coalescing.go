@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+// Search coalescing modes. Singleflight always dedupes concurrent loads within a replica;
+// the modes decide what happens across replicas.
+const (
+	CoalesceLocal = "local" // Each replica loads on its own
+	CoalesceAudit = "audit" // Each replica loads on its own, counting loads another replica was already running
+	CoalesceRedis = "redis" // One replica loads while the others wait for its results
+)
+
+// searchCoalescingStats exposes cross-replica coalescing counters, so the modes can be
+// compared: db_loads, duplicate_loads (audit), leader_loads, follower_hits,
+// wait_timeouts, and lock_errors (redis)
+var searchCoalescingStats = expvar.NewMap("flight_search_coalescing")
+
+// releaseSearchLockScript deletes a search load lock only while the caller still holds it.
+// KEYS: lock. ARGV: holder token.
+const releaseSearchLockScript = `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`
+
+// SearchCoalescingConfig controls how search loads are shared between replicas
+type SearchCoalescingConfig struct {
+	Mode        string
+	LockTTL     time.Duration // How long a loading replica holds the lock; bounds the wait when it dies
+	WaitTimeout time.Duration // How long a replica waits for another's results before loading itself
+}
+
+// LoadSearchCoalescingConfig loads search coalescing settings from the environment
+func LoadSearchCoalescingConfig() SearchCoalescingConfig {
+	cfg := SearchCoalescingConfig{
+		Mode:        config.GetEnv("SEARCH_COALESCING", CoalesceLocal),
+		LockTTL:     config.GetDuration("SEARCH_COALESCING_LOCK_TTL", 10*time.Second),
+		WaitTimeout: config.GetDuration("SEARCH_COALESCING_WAIT", 5*time.Second),
+	}
+	switch cfg.Mode {
+	case CoalesceLocal, CoalesceAudit, CoalesceRedis:
+	default:
+		log.Printf("Unknown SEARCH_COALESCING %q, using %s", cfg.Mode, CoalesceLocal)
+		cfg.Mode = CoalesceLocal
+	}
+	searchCoalescingStats.Set("mode", expvarString(cfg.Mode))
+	return cfg
+}
+
+// expvarString wraps a string for an expvar map
+func expvarString(s string) *expvar.String {
+	v := new(expvar.String)
+	v.Set(s)
+	return v
+}
+
+// loadSearchResultsCoalesced loads a route's search results for this replica's singleflight
+// leader, sharing the load with other replicas as the coalescing mode says
+func (fs *FlightService) loadSearchResultsCoalesced(ctx context.Context, source, destination, date string, maxStops int) ([]models.Flight, error) {
+	cfg := fs.searchCoalescing
+	if cfg.Mode == CoalesceLocal {
+		return fs.loadSearchResultsCounted(ctx, source, destination, date, maxStops)
+	}
+
+	lockKey := database.GenerateSearchLoadLockKey(source, destination, date, maxStops)
+	token := uuid.New().String()
+	acquired, err := fs.cache.SetNX(ctx, lockKey, token, cfg.LockTTL).Result()
+	if err != nil {
+		// Coalescing is an optimization; a Redis failure must not fail the search
+		searchCoalescingStats.Add("lock_errors", 1)
+		log.Printf("Failed to acquire search load lock %s: %v", lockKey, err)
+		return fs.loadSearchResultsCounted(ctx, source, destination, date, maxStops)
+	}
+	if acquired {
+		return fs.leadSearchLoad(ctx, source, destination, date, maxStops, lockKey, token)
+	}
+
+	if cfg.Mode == CoalesceAudit {
+		searchCoalescingStats.Add("duplicate_loads", 1)
+		return fs.loadSearchResultsCounted(ctx, source, destination, date, maxStops)
+	}
+	return fs.followSearchLoad(ctx, source, destination, date, maxStops)
+}
+
+// leadSearchLoad loads search results while holding the lock, then announces them to
+// waiting replicas and releases the lock
+func (fs *FlightService) leadSearchLoad(ctx context.Context, source, destination, date string, maxStops int, lockKey, token string) ([]models.Flight, error) {
+	searchCoalescingStats.Add("leader_loads", 1)
+	defer func() {
+		if err := fs.cache.Eval(ctx, releaseSearchLockScript, []string{lockKey}, token).Err(); err != nil {
+			log.Printf("Failed to release search load lock %s: %v", lockKey, err)
+		}
+	}()
+
+	flights, err := fs.loadSearchResultsCounted(ctx, source, destination, date, maxStops)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only in redis mode are other replicas waiting for the results
+	if fs.searchCoalescing.Mode == CoalesceRedis {
+		channel := database.GenerateSearchLoadedChannel(source, destination, date, maxStops)
+		if err := fs.cache.Publish(ctx, channel, "loaded").Err(); err != nil {
+			log.Printf("Failed to announce search results on %s: %v", channel, err)
+		}
+	}
+	return flights, nil
+}
+
+// followSearchLoad waits for the replica holding the lock to cache the results and reads
+// them, loading them itself when they don't arrive within the wait timeout
+func (fs *FlightService) followSearchLoad(ctx context.Context, source, destination, date string, maxStops int) ([]models.Flight, error) {
+	cacheKey := database.GenerateSearchCacheKey(source, destination, date, maxStops)
+	channel := database.GenerateSearchLoadedChannel(source, destination, date, maxStops)
+
+	waitCtx, cancel := context.WithTimeout(ctx, fs.searchCoalescing.WaitTimeout)
+	defer cancel()
+
+	pubsub := fs.cache.Subscribe(waitCtx, channel)
+	defer pubsub.Close()
+
+	// Check the cache only once subscribed, so results cached in between aren't missed
+	_, err := pubsub.Receive(waitCtx)
+	if err != nil && waitCtx.Err() == nil {
+		searchCoalescingStats.Add("lock_errors", 1)
+		log.Printf("Failed to subscribe to %s: %v", channel, err)
+		return fs.loadSearchResultsCounted(ctx, source, destination, date, maxStops)
+	}
+	if err == nil {
+		if flights, _, err := fs.getCachedSearch(ctx, cacheKey); err == nil {
+			searchCoalescingStats.Add("follower_hits", 1)
+			return flights, nil
+		}
+
+		select {
+		case <-pubsub.Channel():
+			if flights, _, err := fs.getCachedSearch(ctx, cacheKey); err == nil {
+				searchCoalescingStats.Add("follower_hits", 1)
+				return flights, nil
+			}
+		case <-waitCtx.Done():
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("failed waiting for search results: %w", err)
+	}
+	searchCoalescingStats.Add("wait_timeouts", 1)
+	log.Printf("Search results for %s not loaded by another replica within %v, loading them", cacheKey, fs.searchCoalescing.WaitTimeout)
+	return fs.loadSearchResultsCounted(ctx, source, destination, date, maxStops)
+}
+
+// loadSearchResultsCounted loads search results from the database, counting the load
+func (fs *FlightService) loadSearchResultsCounted(ctx context.Context, source, destination, date string, maxStops int) ([]models.Flight, error) {
+	searchCoalescingStats.Add("db_loads", 1)
+	return fs.loadSearchResults(ctx, source, destination, date, maxStops)
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"expvar"
+	"fmt"
+	"log"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/pkg/models"
+)
+
+// maxSearchStops is the deepest a search may be configured or asked to go
+const maxSearchStops = 3
+
+// searchDepthStats exposes search depth counters (levels_searched, early_exits)
+var searchDepthStats = expvar.NewMap("flight_search_depth")
+
+// SearchDepthConfig controls how many connections searches look for
+type SearchDepthConfig struct {
+	MaxStops int // Connections per path when the request does not ask for fewer
+	// Paths cheaper than any path with more stops could be that end the search early
+	// (0 disables early exit)
+	EarlyExitPaths int
+}
+
+// LoadSearchDepthConfig loads search depth settings from the environment
+func LoadSearchDepthConfig() SearchDepthConfig {
+	return SearchDepthConfig{
+		MaxStops:       min(max(config.GetInt("SEARCH_MAX_STOPS", maxSearchStops), 0), maxSearchStops),
+		EarlyExitPaths: max(config.GetInt("SEARCH_EARLY_EXIT_PATHS", 5), 0),
+	}
+}
+
+// MaxSearchStops returns the most connections a search may ask for
+func (fs *FlightService) MaxSearchStops() int {
+	return fs.searchDepth.MaxStops
+}
+
+// searchDepthFor returns the connections a search looks for: the request's max_stops,
+// capped at the configured depth
+func (fs *FlightService) searchDepthFor(req *models.SearchRequest) int {
+	if req.MaxStops != nil {
+		return min(max(*req.MaxStops, 0), fs.searchDepth.MaxStops)
+	}
+	return fs.searchDepth.MaxStops
+}
+
+// fareFloor holds the lowest fares of a search date's bookable flights, from which the
+// cheapest possible path with a given number of stops is bounded
+type fareFloor struct {
+	fromSource    models.Money // Cheapest first leg
+	toDestination models.Money // Cheapest last leg
+	anyLeg        models.Money // Cheapest leg anywhere
+}
+
+// fareFloorQuery finds the lowest fares of flights departing the source, arriving at the
+// destination, and anywhere ($1 source, $2 destination, $3 date, $4 seats)
+const fareFloorQuery = `
+	/* query: search_fare_floor */
+	SELECT MIN(price) FILTER (WHERE source = $1),
+	       MIN(price) FILTER (WHERE destination = $2),
+	       MIN(price)
+	FROM flights
+	WHERE departure_time >= $3::date AND departure_time < $3::date + 1
+	  AND booked_seats < total_seats
+	  AND (total_seats - booked_seats) >= $4
+	  AND status <> 'cancelled'
+`
+
+// loadFareFloor reads the lowest fares of a search date. ok is false when no path with a
+// connection can exist (no flight leaves the source or reaches the destination).
+func (fs *FlightService) loadFareFloor(ctx context.Context, source, destination string, date time.Time, seats int) (floor fareFloor, ok bool, err error) {
+	var fromSource, toDestination, anyLeg sql.NullString
+	err = fs.db.QueryRowContext(ctx, fareFloorQuery, source, destination, date, seats).Scan(&fromSource, &toDestination, &anyLeg)
+	if err != nil {
+		return floor, false, fmt.Errorf("failed to load fare floor: %w", err)
+	}
+	if !fromSource.Valid || !toDestination.Valid || !anyLeg.Valid {
+		return floor, false, nil
+	}
+
+	for _, fare := range []struct {
+		value sql.NullString
+		dest  *models.Money
+	}{{fromSource, &floor.fromSource}, {toDestination, &floor.toDestination}, {anyLeg, &floor.anyLeg}} {
+		if *fare.dest, err = models.ParseMoney(fare.value.String); err != nil {
+			return floor, false, fmt.Errorf("failed to parse fare floor: %w", err)
+		}
+	}
+	return floor, true, nil
+}
+
+// lowerBound returns the least a path with the given number of stops (at least one) can
+// cost: the cheapest first leg, the cheapest last leg, and the cheapest leg for each
+// connection between them
+func (f fareFloor) lowerBound(stops int) models.Money {
+	bound := f.fromSource.Add(f.toDestination)
+	for i := 1; i < stops; i++ {
+		bound = bound.Add(f.anyLeg)
+	}
+	return bound
+}
+
+// earlyExit decides when a search has found enough cheap paths to skip deeper levels
+type earlyExit struct {
+	fs          *FlightService
+	source      string
+	destination string
+	date        time.Time
+	seats       int
+
+	floor  *fareFloor // Loaded on first use
+	noPath bool       // No deeper path can exist
+}
+
+// canStopAfter reports whether a search that has found paths up to stops connections can
+// skip deeper levels: at least EarlyExitPaths of them are cheaper than the cheapest path
+// with one more stop could be
+func (e *earlyExit) canStopAfter(ctx context.Context, paths []models.FlightPath, stops int) bool {
+	need := e.fs.searchDepth.EarlyExitPaths
+	if need <= 0 || len(paths) < need {
+		return false
+	}
+
+	if e.floor == nil && !e.noPath {
+		floor, ok, err := e.fs.loadFareFloor(ctx, e.source, e.destination, e.date, e.seats)
+		if err != nil {
+			log.Printf("Early exit check failed for %s-%s: %v", e.source, e.destination, err)
+			return false
+		}
+		// When nothing leaves the source or reaches the destination, no deeper path exists
+		e.floor, e.noPath = &floor, !ok
+	}
+
+	if !e.noPath {
+		bound := e.floor.lowerBound(stops + 1)
+		cheaper := 0
+		for _, path := range paths {
+			if path.TotalPrice.Minor < bound.Minor {
+				cheaper++
+			}
+		}
+		if cheaper < need {
+			return false
+		}
+	}
+	searchDepthStats.Add("early_exits", 1)
+	return true
+}
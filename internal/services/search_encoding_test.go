@@ -0,0 +1,63 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+
+	"cred_flights_booking/pkg/models"
+)
+
+// BenchmarkEncodeSearchResponse compares encoding each depth's responses with encoding/json
+// against the pooled encoder the search handler uses, after checking both write the same bytes
+func BenchmarkEncodeSearchResponse(b *testing.B) {
+	f := loadSearchFixture(b)
+	ctx := context.Background()
+
+	encoders := []struct {
+		name   string
+		encode func(*models.SearchResponse) error
+	}{
+		{name: "encoding_json", encode: func(r *models.SearchResponse) error { return json.NewEncoder(io.Discard).Encode(r) }},
+		{name: "pooled", encode: func(r *models.SearchResponse) error { return r.WriteJSON(io.Discard) }},
+	}
+
+	for _, s := range f.scenarios {
+		responses := make([]*models.SearchResponse, len(s.searches))
+		paths := 0
+		for i := range s.searches {
+			response, err := f.service.SearchFlights(ctx, &s.searches[i])
+			if err != nil {
+				b.Fatalf("stops_%d: %v", s.stops, err)
+			}
+			var want, got bytes.Buffer
+			if err := json.NewEncoder(&want).Encode(response); err != nil {
+				b.Fatalf("stops_%d: %v", s.stops, err)
+			}
+			if err := response.WriteJSON(&got); err != nil {
+				b.Fatalf("stops_%d: %v", s.stops, err)
+			}
+			if !bytes.Equal(want.Bytes(), got.Bytes()) {
+				b.Fatalf("stops_%d: pooled encoder output differs from encoding/json for %s-%s on %s",
+					s.stops, s.searches[i].Source, s.searches[i].Destination, s.searches[i].Date)
+			}
+			responses[i] = response
+			paths += response.Count
+		}
+
+		for _, encoder := range encoders {
+			b.Run(fmt.Sprintf("stops_%d/%s", s.stops, encoder.name), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if err := encoder.encode(responses[i%len(responses)]); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(paths)/float64(len(s.searches)), "paths/search")
+			})
+		}
+	}
+}
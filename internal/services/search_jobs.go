@@ -0,0 +1,272 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/pkg/models"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrSearchJobNotFound is returned when a search job does not exist or has expired
+	ErrSearchJobNotFound = errors.New("search job not found")
+	// ErrSearchQueueFull is returned when too many search jobs are waiting
+	ErrSearchQueueFull = errors.New("too many search jobs queued, please try again later")
+)
+
+// SearchJobConfig controls async search jobs
+type SearchJobConfig struct {
+	Workers    int           // Jobs run at the same time per instance
+	QueueSize  int           // Jobs waiting per instance before new ones are refused
+	Timeout    time.Duration // Longest a job may run
+	MaxStops   int           // Most connections a job may ask for
+	MaxResults int           // Most paths kept per job, best first
+	JobTTL     time.Duration // How long a job and its results can be fetched
+	ResultTTL  time.Duration // How long results are reused for identical jobs
+}
+
+// LoadSearchJobConfig loads search job settings from the environment
+func LoadSearchJobConfig() SearchJobConfig {
+	return SearchJobConfig{
+		Workers:    config.GetInt("SEARCH_JOB_WORKERS", 2),
+		QueueSize:  config.GetInt("SEARCH_JOB_QUEUE_SIZE", 100),
+		Timeout:    config.GetDuration("SEARCH_JOB_TIMEOUT", 5*time.Minute),
+		MaxStops:   config.GetInt("SEARCH_JOB_MAX_STOPS", 4),
+		MaxResults: config.GetInt("SEARCH_JOB_MAX_RESULTS", 500),
+		JobTTL:     config.GetDuration("SEARCH_JOB_TTL", time.Hour),
+		ResultTTL:  config.GetDuration("SEARCH_JOB_RESULT_TTL", 15*time.Minute),
+	}
+}
+
+// SearchJobService runs exhaustive multi-stop searches in the background, for queries too
+// deep to answer within a request. Jobs are stored in Redis, so any instance can report
+// on them; each runs on the instance that accepted it.
+type SearchJobService struct {
+	flights *FlightService
+	cache   *database.RedisClient
+	cfg     SearchJobConfig
+	queue   chan string
+}
+
+// NewSearchJobService creates the search job service
+func NewSearchJobService(flights *FlightService, cache *database.RedisClient) *SearchJobService {
+	cfg := LoadSearchJobConfig()
+	return &SearchJobService{
+		flights: flights,
+		cache:   cache,
+		cfg:     cfg,
+		queue:   make(chan string, cfg.QueueSize),
+	}
+}
+
+// QueueDepth returns how many jobs are waiting for a worker on this instance
+func (ss *SearchJobService) QueueDepth(ctx context.Context) (int64, error) {
+	return int64(len(ss.queue)), nil
+}
+
+// MaxStops returns the most connections a job may ask for
+func (ss *SearchJobService) MaxStops() int {
+	return ss.cfg.MaxStops
+}
+
+// Start runs the job workers until ctx is cancelled
+func (ss *SearchJobService) Start(ctx context.Context) {
+	for i := 0; i < ss.cfg.Workers; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case jobID := <-ss.queue:
+					ss.run(ctx, jobID)
+				}
+			}
+		}()
+	}
+}
+
+// searchJobHash identifies a normalized job request for the result cache
+func searchJobHash(req *models.SearchJobRequest) string {
+	airlines := append([]string(nil), req.Airlines...)
+	sort.Strings(airlines)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%s|%s|%d|%s|%d|%s", req.Source, req.Destination, req.Date, req.Seats,
+		req.SortBy, req.MaxStops, strings.Join(airlines, ","))
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// CreateJob queues a search job. When an identical job completed recently, the new job
+// is returned already completed with its results.
+func (ss *SearchJobService) CreateJob(ctx context.Context, req *models.SearchJobRequest) (*models.SearchJob, error) {
+	now := time.Now()
+	job := &models.SearchJob{
+		ID:        uuid.NewString(),
+		Status:    models.SearchJobQueued,
+		Request:   *req,
+		Progress:  models.SearchJobProgress{StopsTotal: req.MaxStops + 1},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	var cached models.SearchJob
+	if err := ss.cache.GetJSON(ctx, database.GenerateSearchJobResultKey(searchJobHash(req)), &cached); err == nil {
+		job.Status = models.SearchJobCompleted
+		job.Progress = cached.Progress
+		job.Paths = cached.Paths
+		job.Count = cached.Count
+		job.Truncated = cached.Truncated
+		job.Cached = true
+		job.CompletedAt = &now
+		if err := ss.save(ctx, job); err != nil {
+			return nil, err
+		}
+		return job, nil
+	}
+
+	if err := ss.save(ctx, job); err != nil {
+		return nil, err
+	}
+
+	select {
+	case ss.queue <- job.ID:
+	default:
+		ss.cache.Delete(ctx, database.GenerateSearchJobKey(job.ID))
+		return nil, ErrSearchQueueFull
+	}
+
+	log.Printf("Search job %s queued for %s-%s on %s (max %d stops)", job.ID, req.Source, req.Destination, req.Date, req.MaxStops)
+	return job, nil
+}
+
+// GetJob returns a search job with its progress, and its results once completed
+func (ss *SearchJobService) GetJob(ctx context.Context, jobID string) (*models.SearchJob, error) {
+	data, err := ss.cache.GetPayload(ctx, database.GenerateSearchJobKey(jobID))
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrSearchJobNotFound
+		}
+		return nil, fmt.Errorf("failed to load search job: %w", err)
+	}
+
+	var job models.SearchJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("failed to decode search job: %w", err)
+	}
+	return &job, nil
+}
+
+// save stores a job's current state
+func (ss *SearchJobService) save(ctx context.Context, job *models.SearchJob) error {
+	job.UpdatedAt = time.Now()
+	if err := ss.cache.SetJSON(ctx, database.GenerateSearchJobKey(job.ID), job, ss.cfg.JobTTL); err != nil {
+		return fmt.Errorf("failed to save search job: %w", err)
+	}
+	return nil
+}
+
+// run computes a job's paths one stop count at a time, recording progress after each
+func (ss *SearchJobService) run(ctx context.Context, jobID string) {
+	ctx, cancel := context.WithTimeout(ctx, ss.cfg.Timeout)
+	defer cancel()
+
+	job, err := ss.GetJob(ctx, jobID)
+	if err != nil {
+		log.Printf("Failed to start search job %s: %v", jobID, err)
+		return
+	}
+	job.Status = models.SearchJobRunning
+	ss.save(ctx, job)
+
+	paths, err := ss.search(ctx, job)
+	if err != nil {
+		log.Printf("Search job %s failed: %v", jobID, err)
+		job.Status = models.SearchJobFailed
+		job.Error = err.Error()
+		// Record the failure even if the job ran out of time
+		saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		ss.save(saveCtx, job)
+		return
+	}
+
+	ss.flights.sortFlightPaths(ctx, paths, job.Request.SortBy)
+	if len(paths) > ss.cfg.MaxResults {
+		paths = paths[:ss.cfg.MaxResults]
+		job.Truncated = true
+	}
+
+	completedAt := time.Now()
+	job.Status = models.SearchJobCompleted
+	job.Paths = paths
+	job.Count = len(paths)
+	job.CompletedAt = &completedAt
+	if err := ss.save(ctx, job); err != nil {
+		log.Printf("Failed to save search job %s results: %v", jobID, err)
+		return
+	}
+
+	resultKey := database.GenerateSearchJobResultKey(searchJobHash(&job.Request))
+	if err := ss.cache.SetJSON(ctx, resultKey, job, ss.cfg.ResultTTL); err != nil {
+		log.Printf("Failed to cache search job %s results: %v", jobID, err)
+	}
+	log.Printf("Search job %s completed: %d paths in %v", jobID, job.Count, completedAt.Sub(job.CreatedAt))
+}
+
+// search finds every path of a job's route with up to its maximum stops, direct first
+func (ss *SearchJobService) search(ctx context.Context, job *models.SearchJob) ([]models.FlightPath, error) {
+	req := &job.Request
+	date, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date format: %w", err)
+	}
+
+	airlines := make(map[string]bool, len(req.Airlines))
+	for _, code := range req.Airlines {
+		airlines[code] = true
+	}
+
+	var paths []models.FlightPath
+	for stops := 0; stops <= req.MaxStops; stops++ {
+		found, err := ss.flights.findPathsWithStops(ctx, req.Source, req.Destination, date, req.Seats, stops)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range found {
+			if pathOperatedBy(path, airlines) {
+				paths = append(paths, path)
+			}
+		}
+
+		job.Progress.StopsSearched = stops + 1
+		job.Progress.PathsFound = len(paths)
+		ss.save(ctx, job)
+	}
+	return paths, nil
+}
+
+// pathOperatedBy reports whether every leg of a path is operated by one of the airlines
+// (any airline when none are given)
+func pathOperatedBy(path models.FlightPath, airlines map[string]bool) bool {
+	if len(airlines) == 0 {
+		return true
+	}
+	for _, flight := range path.Flights {
+		if !airlines[flight.AirlineCode()] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"cred_flights_booking/internal/config"
+)
+
+// DirectFlightsQuery finds direct flights on a route and date ($1 source, $2 destination,
+// $3 date, $4 seats). The date is matched as a departure_time range and full flights are
+// excluded so the composite and partial search indexes apply.
+const DirectFlightsQuery = `
+	/* query: direct_flights */
+	SELECT id, flight_number, source, destination, departure_time, arrival_time,
+	       total_seats, booked_seats, price, created_at
+	FROM flights
+	WHERE source = $1 AND destination = $2
+	  AND departure_time >= $3::date AND departure_time < $3::date + 1
+	  AND booked_seats < total_seats
+	  AND (total_seats - booked_seats) >= $4
+	  AND status <> 'cancelled'
+	ORDER BY departure_time
+`
+
+// MultiStopFlightsQuery builds the recursive CTE query for paths of up to maxLegs flights,
+// taking the same arguments as DirectFlightsQuery
+func MultiStopFlightsQuery(maxLegs int) string {
+	return fmt.Sprintf(`
+		/* query: multi_stop_flights_%d */
+		WITH RECURSIVE flight_paths AS (
+			-- Base case: direct flights
+			SELECT
+				id, flight_number, source, destination, departure_time, arrival_time,
+				total_seats, booked_seats, price, created_at,
+				1 as stops,
+				ARRAY[id] as flight_ids,
+				ARRAY[flight_number] as flight_numbers,
+				ARRAY[source] as sources,
+				ARRAY[destination] as destinations,
+				ARRAY[departure_time] as departure_times,
+				ARRAY[arrival_time] as arrival_times,
+				ARRAY[total_seats] as total_seats_array,
+				ARRAY[booked_seats] as booked_seats_array,
+				ARRAY[price] as prices,
+				ARRAY[created_at] as created_ats
+			FROM flights
+			WHERE source = $1
+			  AND departure_time >= $3::date AND departure_time < $3::date + 1
+			  AND booked_seats < total_seats
+			  AND (total_seats - booked_seats) >= $4
+			  AND status <> 'cancelled'
+
+			UNION ALL
+
+			-- Recursive case: add connecting flights
+			SELECT
+				f.id, f.flight_number, f.source, f.destination, f.departure_time, f.arrival_time,
+				f.total_seats, f.booked_seats, f.price, f.created_at,
+				fp.stops + 1,
+				fp.flight_ids || f.id,
+				fp.flight_numbers || f.flight_number,
+				fp.sources || f.source,
+				fp.destinations || f.destination,
+				fp.departure_times || f.departure_time,
+				fp.arrival_times || f.arrival_time,
+				fp.total_seats_array || f.total_seats,
+				fp.booked_seats_array || f.booked_seats,
+				fp.prices || f.price,
+				fp.created_ats || f.created_at
+			FROM flight_paths fp
+			JOIN flights f ON fp.destinations[array_length(fp.destinations, 1)] = f.source
+			WHERE fp.stops < %d
+			  AND f.destination = $2
+			  AND f.departure_time >= $3::date AND f.departure_time < $3::date + 1
+			  AND f.booked_seats < f.total_seats
+			  AND (f.total_seats - f.booked_seats) >= $4
+			  AND f.status <> 'cancelled'
+			  AND f.departure_time > fp.arrival_times[array_length(fp.arrival_times, 1)]
+			  AND f.departure_time <= fp.arrival_times[array_length(fp.arrival_times, 1)] + INTERVAL '4 hours'
+		)
+		SELECT
+			flight_ids, flight_numbers, sources, destinations,
+			departure_times, arrival_times, total_seats_array, booked_seats_array,
+			prices, created_ats
+		FROM flight_paths
+		WHERE destinations[array_length(destinations, 1)] = $2
+		ORDER BY stops, prices[1]
+	`, maxLegs, maxLegs)
+}
+
+// explainSearchQuery logs the plan of a search query when SEARCH_EXPLAIN is set. With
+// SEARCH_EXPLAIN_ANALYZE the query is also executed to report actual row counts and timings.
+func (fs *FlightService) explainSearchQuery(ctx context.Context, name, query string, args ...interface{}) {
+	if !config.GetBool("SEARCH_EXPLAIN", false) {
+		return
+	}
+
+	explain := "EXPLAIN "
+	if config.GetBool("SEARCH_EXPLAIN_ANALYZE", false) {
+		explain = "EXPLAIN (ANALYZE, BUFFERS) "
+	}
+
+	rows, err := fs.db.QueryContext(ctx, explain+query, args...)
+	if err != nil {
+		log.Printf("Failed to explain %s query: %v", name, err)
+		return
+	}
+	defer rows.Close()
+
+	var plan []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			log.Printf("Failed to scan %s query plan: %v", name, err)
+			return
+		}
+		plan = append(plan, line)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Failed to read %s query plan: %v", name, err)
+		return
+	}
+
+	log.Printf("SEARCH_PLAN query=%s args=%v\n%s", name, args, strings.Join(plan, "\n"))
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/database"
+)
+
+// benchSchema holds the synthetic flights table of the query benchmarks, so the real one is
+// never touched
+const benchSchema = "searchbench"
+
+// baselineIndexes are the flights search indexes as they were before the search index migration
+var baselineIndexes = []string{
+	"ALTER TABLE flights ADD PRIMARY KEY (id)",
+	"CREATE INDEX idx_flights_source_dest_date ON flights(source, destination, departure_time)",
+	"CREATE INDEX idx_flights_source ON flights(source)",
+	"ANALYZE flights",
+}
+
+// benchSearch is one benchmarked search
+type benchSearch struct {
+	source      string
+	destination string
+	date        time.Time
+}
+
+// BenchmarkSearchQueries benchmarks the direct and multi-stop search queries on seeded
+// flights with the pre-migration indexes, then again after applying the search index
+// migration (SEARCH_QUERY_BENCH_MIGRATION), and fails if the two runs return different rows:
+// indexes change plans, never results. Skipped when PostgreSQL is unavailable.
+func BenchmarkSearchQueries(b *testing.B) {
+	migration := config.GetEnv("SEARCH_QUERY_BENCH_MIGRATION", "../../scripts/migrations/flights/001_search_indexes.sql")
+	statements, err := readMigration(migration)
+	if err != nil {
+		b.Fatalf("Failed to read migration: %v", err)
+	}
+
+	db, err := database.NewPostgresDB()
+	if err != nil {
+		b.Skipf("PostgreSQL unavailable: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	// One connection, so the schema's search_path applies to every statement
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		b.Fatalf("Failed to get database connection: %v", err)
+	}
+	defer conn.Close()
+
+	if err := seedQueryBench(ctx, conn); err != nil {
+		b.Fatalf("Failed to seed flights: %v", err)
+	}
+	if !config.GetBool("SEARCH_BENCH_KEEP", false) {
+		defer func() {
+			if _, err := conn.ExecContext(ctx, "DROP SCHEMA IF EXISTS "+benchSchema+" CASCADE"); err != nil {
+				log.Printf("Failed to drop %s schema: %v", benchSchema, err)
+			}
+		}()
+	}
+
+	searches, err := sampleSearches(ctx, conn, config.GetInt("SEARCH_QUERY_BENCH_SAMPLES", 100))
+	if err != nil {
+		b.Fatalf("Failed to sample searches: %v", err)
+	}
+
+	queries := []struct {
+		name  string
+		query string
+	}{
+		{name: "direct", query: DirectFlightsQuery},
+		{name: "multi_stop_2", query: MultiStopFlightsQuery(2)},
+		{name: "multi_stop_3", query: MultiStopFlightsQuery(3)},
+	}
+
+	phases := []struct {
+		name       string
+		statements []string
+	}{
+		{name: "baseline", statements: baselineIndexes},
+		{name: "migrated", statements: statements},
+	}
+
+	rows := make(map[string]int)
+	for _, phase := range phases {
+		if err := execAll(ctx, conn, phase.statements); err != nil {
+			b.Fatalf("Failed to create %s indexes: %v", phase.name, err)
+		}
+
+		for _, q := range queries {
+			total := 0
+			for _, s := range searches {
+				n, err := runSearch(ctx, conn, q.query, s)
+				if err != nil {
+					b.Fatalf("%s: %v", q.name, err)
+				}
+				total += n
+			}
+			if want, ok := rows[q.name]; ok && want != total {
+				b.Errorf("%s: %d rows after migration, %d before", q.name, total, want)
+			}
+			rows[q.name] = total
+
+			b.Run(phase.name+"/"+q.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := runSearch(ctx, conn, q.query, searches[i%len(searches)]); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(total)/float64(len(searches)), "rows/search")
+			})
+		}
+	}
+}
+
+// readMigration splits a migration file into its statements
+func readMigration(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines = append(lines, line)
+		}
+	}
+
+	var statements []string
+	for _, statement := range strings.Split(strings.Join(lines, "\n"), ";") {
+		if statement = strings.TrimSpace(statement); statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+	return statements, nil
+}
+
+// seedQueryBench recreates the bench schema with an unindexed copy of the flights table and
+// fills it
+func seedQueryBench(ctx context.Context, conn *sql.Conn) error {
+	flights := config.GetInt("SEARCH_QUERY_BENCH_FLIGHTS", 500000)
+	airports := config.GetInt("SEARCH_QUERY_BENCH_AIRPORTS", 120)
+	days := config.GetInt("SEARCH_QUERY_BENCH_DAYS", 60)
+	if airports < 2 || airports > 26*26 {
+		return fmt.Errorf("SEARCH_QUERY_BENCH_AIRPORTS must be between 2 and %d", 26*26)
+	}
+
+	setup := []string{
+		"DROP SCHEMA IF EXISTS " + benchSchema + " CASCADE",
+		"CREATE SCHEMA " + benchSchema,
+		"SET search_path TO " + benchSchema,
+		"CREATE TABLE flights (LIKE public.flights INCLUDING GENERATED INCLUDING CONSTRAINTS)",
+	}
+	if err := execAll(ctx, conn, setup); err != nil {
+		return err
+	}
+
+	log.Printf("Seeding %d flights between %d airports over %d days", flights, airports, days)
+	start := time.Now()
+	if _, err := conn.ExecContext(ctx, seedFlightsQuery, flights, airports, seedStartDate().Format("2006-01-02"), days, "SB"); err != nil {
+		return fmt.Errorf("failed to insert flights: %w", err)
+	}
+	log.Printf("Seeded in %v", time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// sampleSearches picks routes and dates that have flights
+func sampleSearches(ctx context.Context, conn *sql.Conn, samples int) ([]benchSearch, error) {
+	rows, err := conn.QueryContext(ctx, `
+		SELECT source, destination, departure_time::date
+		FROM flights
+		WHERE status <> 'cancelled'
+		ORDER BY random()
+		LIMIT $1
+	`, samples)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var searches []benchSearch
+	for rows.Next() {
+		var s benchSearch
+		if err := rows.Scan(&s.source, &s.destination, &s.date); err != nil {
+			return nil, err
+		}
+		searches = append(searches, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(searches) == 0 {
+		return nil, fmt.Errorf("no flights seeded")
+	}
+	return searches, nil
+}
+
+// execAll runs statements in order
+func execAll(ctx context.Context, conn *sql.Conn, statements []string) error {
+	for _, statement := range statements {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("%q: %w", statement, err)
+		}
+	}
+	return nil
+}
+
+// runSearch runs a search query for one seat and returns the number of rows
+func runSearch(ctx context.Context, conn *sql.Conn, query string, s benchSearch) (int, error) {
+	rows, err := conn.QueryContext(ctx, query, s.source, s.destination, s.date, 1)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	return n, rows.Err()
+}
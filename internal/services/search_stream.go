@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/experiments"
+	"cred_flights_booking/internal/funnel"
+	"cred_flights_booking/pkg/models"
+)
+
+// MaxStreamedPaths returns the most paths a streamed search sends
+func MaxStreamedPaths() int {
+	return config.GetInt("SEARCH_STREAM_MAX_PATHS", 50)
+}
+
+// StreamSearch searches a route one number of stops at a time, direct flights first, and
+// calls emit for each path with enough live seats as soon as its level is done. Paths
+// within a level are sorted; at most MaxStreamedPaths are sent. An error from emit (the
+// client went away) stops the search.
+func (fs *FlightService) StreamSearch(ctx context.Context, req *models.SearchRequest, emit func(path *models.FlightPath) error) (int, error) {
+	assignments := fs.experiments.Assign(req.UserID)
+	ctx = experiments.WithAssignments(ctx, assignments)
+
+	date, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		return 0, fmt.Errorf("invalid date format: %w", err)
+	}
+
+	airlines := make(map[string]bool, len(req.Airlines))
+	for _, code := range req.Airlines {
+		airlines[code] = true
+	}
+
+	limit := MaxStreamedPaths()
+	maxStops := fs.searchDepthFor(req)
+	sent := 0
+	for stops := 0; stops <= maxStops && sent < limit; stops++ {
+		paths, err := fs.findPathsWithStops(ctx, req.Source, req.Destination, date, req.Seats, stops)
+		if err != nil {
+			return sent, fmt.Errorf("failed to find %d-stop paths: %w", stops, err)
+		}
+
+		paths, err = fs.withLiveSeats(ctx, paths, req.Seats)
+		if err != nil {
+			return sent, err
+		}
+
+		var matching []models.FlightPath
+		for _, path := range paths {
+			if pathOperatedBy(path, airlines) {
+				matching = append(matching, path)
+			}
+		}
+		fs.applyPricing(ctx, matching)
+		fs.sortFlightPaths(ctx, matching, req.SortBy)
+
+		for i := range matching {
+			if sent >= limit {
+				break
+			}
+			if err := emit(&matching[i]); err != nil {
+				return sent, err
+			}
+			sent++
+		}
+	}
+
+	fs.experiments.RecordExposures(ctx, assignments)
+	fs.funnel.Track(ctx, funnel.StageSearch, funnel.Route(req.Source, req.Destination))
+	return sent, nil
+}
+
+// withLiveSeats keeps the paths whose every leg has enough seats by the live counters
+func (fs *FlightService) withLiveSeats(ctx context.Context, paths []models.FlightPath, seats int) ([]models.FlightPath, error) {
+	var flights []models.Flight
+	seen := make(map[int]bool)
+	for _, path := range paths {
+		for _, flight := range path.Flights {
+			if !seen[flight.ID] {
+				seen[flight.ID] = true
+				flights = append(flights, flight)
+			}
+		}
+	}
+
+	seatCounts, err := fs.getAvailableSeatsBatch(ctx, flights)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get available seats: %w", err)
+	}
+
+	var available []models.FlightPath
+	for _, path := range paths {
+		ok := true
+		for _, flight := range path.Flights {
+			if seatCounts[flight.ID] < seats {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			available = append(available, path)
+		}
+	}
+	return available, nil
+}
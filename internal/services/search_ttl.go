@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/internal/funnel"
+	"cred_flights_booking/pkg/models"
+	"github.com/go-redis/redis/v8"
+)
+
+// popularRoutesRefresh is how often the set of popular routes used for cache TTLs is reloaded
+const popularRoutesRefresh = time.Minute
+
+// SearchTTLPolicy picks how long a route's search results stay fresh. Inventory close to
+// departure and on popular routes sells faster, so it is cached for less time; far-future
+// inventory rarely changes and is cached for longer.
+type SearchTTLPolicy struct {
+	NearDays      int           // Departures at most this many days away use NearTTL
+	NearTTL       time.Duration // Fresh TTL of near-departure searches
+	FarDays       int           // Departures at least this many days away use FarTTL
+	FarTTL        time.Duration // Fresh TTL of far-future searches
+	PopularFactor float64       // Multiplies the TTL of routes in the popular routes list
+}
+
+// ttl returns the fresh TTL, before jitter, of a search departing on date (YYYY-MM-DD).
+// Searches between the near and far windows, and unparseable dates, get the base TTL.
+func (p SearchTTLPolicy) ttl(base time.Duration, date string, popular bool, now time.Time) time.Duration {
+	ttl := base
+	if departure, err := time.Parse("2006-01-02", date); err == nil {
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		days := int(departure.Sub(today).Hours() / 24)
+		switch {
+		case days <= p.NearDays:
+			ttl = p.NearTTL
+		case p.FarDays > 0 && days >= p.FarDays:
+			ttl = p.FarTTL
+		}
+	}
+
+	if popular && p.PopularFactor > 0 {
+		ttl = time.Duration(float64(ttl) * p.PopularFactor)
+	}
+	return ttl
+}
+
+// searchTTL returns the jittered fresh TTL of a route's search results
+func (fs *FlightService) searchTTL(ctx context.Context, source, destination, date string) time.Duration {
+	cfg := fs.searchCacheConfig
+	popular := fs.isPopularRoute(ctx, source, destination)
+	ttl := cfg.Policy.ttl(cfg.TTL, date, popular, time.Now().UTC())
+	return cfg.jitter(ttl)
+}
+
+// popularRouteSet is the routes of the last popular routes list, reloaded periodically
+type popularRouteSet struct {
+	mu       sync.Mutex
+	routes   map[string]bool
+	loadedAt time.Time
+}
+
+// isPopularRoute reports whether a route is in the popular routes list built by the
+// popular-routes job. Before the list exists, or when it can't be read, no route is popular.
+func (fs *FlightService) isPopularRoute(ctx context.Context, source, destination string) bool {
+	set := &fs.popularSet
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	if set.routes == nil || time.Since(set.loadedAt) >= popularRoutesRefresh {
+		set.routes = fs.loadPopularRouteSet(ctx)
+		set.loadedAt = time.Now()
+	}
+	return set.routes[funnel.Route(source, destination)]
+}
+
+// loadPopularRouteSet reads the routes of the stored popular routes list
+func (fs *FlightService) loadPopularRouteSet(ctx context.Context) map[string]bool {
+	routes := make(map[string]bool)
+
+	body, err := fs.cache.Get(ctx, database.GeneratePopularRoutesCacheKey(PopularRoutesJSON)).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			log.Printf("Failed to load popular routes for search TTLs: %v", err)
+		}
+		return routes
+	}
+
+	var list models.PopularRoutesResponse
+	if err := json.Unmarshal(body, &list); err != nil {
+		log.Printf("Failed to decode popular routes for search TTLs: %v", err)
+		return routes
+	}
+	for _, route := range list.Routes {
+		routes[funnel.Route(route.Source, route.Destination)] = true
+	}
+	return routes
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/database"
+	"github.com/go-redis/redis/v8"
+)
+
+// seatCounterStats exposes seat counter lifecycle counters (opened, archived)
+var seatCounterStats = expvar.NewMap("seat_counters")
+
+// seatCounterArchiveBatch is the most counters one archive pass moves to the database
+const seatCounterArchiveBatch = 500
+
+// openSeatCountersQuery lists the seat counters of bookable flights departing between two
+// dates ($1 from, $2 to, inclusive), as the database would load them
+const openSeatCountersQuery = `
+	SELECT f.id, to_char(f.departure_time, 'YYYY-MM-DD'),
+	       COALESCE(i.total_seats - i.booked_seats, f.total_seats - f.booked_seats)
+	FROM flights f
+	LEFT JOIN flight_inventory i ON i.flight_id = f.id AND i.date = DATE(f.departure_time)
+	WHERE f.departure_time >= $1::date AND f.departure_time < $2::date + 1
+	  AND f.status <> 'cancelled'
+`
+
+// archiveSeatCounterQuery stores the final value of a departed flight date's seat counter
+const archiveSeatCounterQuery = `
+	INSERT INTO seat_counter_archive (flight_id, date, available)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (flight_id, date) DO UPDATE SET available = EXCLUDED.available, archived_at = CURRENT_TIMESTAMP
+`
+
+// SeatCounterArchiveAfter returns how long after the end of its departure day a seat
+// counter is archived
+func SeatCounterArchiveAfter() time.Duration {
+	return config.GetDuration("SEAT_COUNTER_ARCHIVE_AFTER", 24*time.Hour)
+}
+
+// storeSeatCounter queues writing a flight date's seat counter, with no expiry, on pipe
+func (fs *FlightService) storeSeatCounter(ctx context.Context, pipe redis.Pipeliner, flightID int, date string, available int) {
+	pipe.Set(ctx, database.GenerateSeatCacheKey(flightID, date), available, 0)
+	fs.markSeatsReconciled(ctx, pipe, flightID, date)
+	trackSeatCounter(ctx, pipe, flightID, date)
+}
+
+// trackSeatCounter queues adding a flight date's counter to the index the archive job reads,
+// scored by the end of its departure day
+func trackSeatCounter(ctx context.Context, pipe redis.Pipeliner, flightID int, date string) {
+	departure, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return
+	}
+	pipe.ZAdd(ctx, database.GenerateSeatCounterIndexKey(), &redis.Z{
+		Score:  float64(departure.AddDate(0, 0, 1).Unix()),
+		Member: seatCounterMember(flightID, date),
+	})
+}
+
+// seatCounterMember is a flight date's member in the seat counter index
+func seatCounterMember(flightID int, date string) string {
+	return fmt.Sprintf("%d:%s", flightID, date)
+}
+
+// parseSeatCounterMember splits a seat counter index member into its flight and date
+func parseSeatCounterMember(member string) (int, string, bool) {
+	id, date, ok := strings.Cut(member, ":")
+	if !ok {
+		return 0, "", false
+	}
+	flightID, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, "", false
+	}
+	return flightID, date, true
+}
+
+// OpenSeatCounters creates the seat counters of every bookable flight departing between
+// from and to (YYYY-MM-DD, inclusive) that does not have one yet. Counters never expire;
+// ArchiveSeatCounters removes them after departure. Returns how many were created.
+func (fs *FlightService) OpenSeatCounters(ctx context.Context, from, to string) (int, error) {
+	rows, err := fs.db.QueryContext(ctx, openSeatCountersQuery, from, to)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query flights for seat counters: %w", err)
+	}
+	defer rows.Close()
+
+	type counter struct {
+		flightID  int
+		date      string
+		available int
+	}
+	var counters []counter
+	for rows.Next() {
+		var c counter
+		if err := rows.Scan(&c.flightID, &c.date, &c.available); err != nil {
+			return 0, fmt.Errorf("failed to scan seat counter: %w", err)
+		}
+		counters = append(counters, c)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read seat counters: %w", err)
+	}
+
+	// Counters replayed from seat events are loaded one flight date at a time
+	opened := 0
+	if fs.seatEvents {
+		for _, c := range counters {
+			exists, err := fs.cache.KeyExists(ctx, database.GenerateSeatCacheKey(c.flightID, c.date))
+			if err != nil {
+				return opened, err
+			}
+			if exists {
+				continue
+			}
+			if _, err := fs.getAvailableSeats(ctx, c.flightID, c.date); err != nil {
+				return opened, err
+			}
+			opened++
+		}
+		seatCounterStats.Add("opened", int64(opened))
+		log.Printf("Opened %d seat counters for flights between %s and %s", opened, from, to)
+		return opened, nil
+	}
+
+	// Existing counters keep their value but lose any expiry left from before counters
+	// were persistent
+	pipe := fs.cache.Pipeline()
+	created := make([]*redis.BoolCmd, len(counters))
+	for i, c := range counters {
+		key := database.GenerateSeatCacheKey(c.flightID, c.date)
+		created[i] = pipe.SetNX(ctx, key, c.available, 0)
+		pipe.Persist(ctx, key)
+		trackSeatCounter(ctx, pipe, c.flightID, c.date)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to open seat counters: %w", err)
+	}
+
+	reconciled := fs.cache.Pipeline()
+	for i, cmd := range created {
+		if cmd.Val() {
+			fs.markSeatsReconciled(ctx, reconciled, counters[i].flightID, counters[i].date)
+			opened++
+		}
+	}
+	if opened > 0 {
+		if _, err := reconciled.Exec(ctx); err != nil {
+			log.Printf("Failed to mark opened seat counters reconciled: %v", err)
+		}
+	}
+
+	seatCounterStats.Add("opened", int64(opened))
+	log.Printf("Opened %d seat counters for flights between %s and %s", opened, from, to)
+	return opened, nil
+}
+
+// ArchiveSeatCounters moves the seat counters of flight dates that departed more than
+// SeatCounterArchiveAfter ago to seat_counter_archive and deletes them from Redis.
+// Returns how many were archived.
+func (fs *FlightService) ArchiveSeatCounters(ctx context.Context) (int, error) {
+	indexKey := database.GenerateSeatCounterIndexKey()
+	cutoff := time.Now().Add(-SeatCounterArchiveAfter()).Unix()
+
+	archived := 0
+	for {
+		members, err := fs.cache.ZRangeByScore(ctx, indexKey, &redis.ZRangeBy{
+			Min:   "-inf",
+			Max:   strconv.FormatInt(cutoff, 10),
+			Count: seatCounterArchiveBatch,
+		}).Result()
+		if err != nil {
+			return archived, fmt.Errorf("failed to read seat counter index: %w", err)
+		}
+		if len(members) == 0 {
+			break
+		}
+
+		for _, member := range members {
+			if err := fs.archiveSeatCounter(ctx, member); err != nil {
+				return archived, err
+			}
+			archived++
+		}
+	}
+
+	seatCounterStats.Add("archived", int64(archived))
+	if archived > 0 {
+		log.Printf("Archived %d seat counters of departed flights", archived)
+	}
+	return archived, nil
+}
+
+// archiveSeatCounter stores one departed flight date's counter in the database, then drops
+// it and its index entry. A counter that is already gone only loses its index entry.
+func (fs *FlightService) archiveSeatCounter(ctx context.Context, member string) error {
+	indexKey := database.GenerateSeatCounterIndexKey()
+
+	flightID, date, ok := parseSeatCounterMember(member)
+	if !ok {
+		log.Printf("Dropping malformed seat counter index entry %q", member)
+		return fs.cache.ZRem(ctx, indexKey, member).Err()
+	}
+
+	cacheKey := database.GenerateSeatCacheKey(flightID, date)
+	available, err := fs.cache.Get(ctx, cacheKey).Int()
+	if err != nil && err != redis.Nil {
+		return fmt.Errorf("failed to read seat counter of flight %d: %w", flightID, err)
+	}
+	if err == nil {
+		if _, err := fs.db.ExecContext(ctx, archiveSeatCounterQuery, flightID, date, available); err != nil {
+			return fmt.Errorf("failed to archive seat counter of flight %d: %w", flightID, err)
+		}
+	}
+
+	pipe := fs.cache.TxPipeline()
+	pipe.Del(ctx, cacheKey, database.GenerateSeatReconciledCacheKey(flightID, date))
+	pipe.ZRem(ctx, indexKey, member)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to drop seat counter of flight %d: %w", flightID, err)
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"cred_flights_booking/internal/cdn"
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/pkg/models"
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrSeatEventsDisabled is returned by seat event operations when SEAT_EVENT_SOURCING is off
+var ErrSeatEventsDisabled = errors.New("seat event sourcing is not enabled")
+
+// MaxSeatReasonLength is the longest reason a seat event may carry
+const MaxSeatReasonLength = 50
+
+// seatEvent is a seat change to append to a flight date's event stream
+type seatEvent struct {
+	flightID int
+	date     string
+	kind     string
+	seats    int // Signed change to available seats
+	reason   string
+	actor    string
+}
+
+// seatProjectionQuery returns a flight date's base capacity, excluding seats sold outside
+// this system, and the net seats and count of its events
+const seatProjectionQuery = `
+	SELECT COALESCE(i.total_seats, f.total_seats) - f.booked_seats, e.seats, e.events
+	FROM flights f
+	LEFT JOIN flight_inventory i ON i.flight_id = f.id AND i.date = $2
+	CROSS JOIN LATERAL (
+		SELECT COALESCE(SUM(seats), 0) AS seats, COUNT(*) AS events
+		FROM seat_events
+		WHERE flight_id = f.id AND date = $2
+	) e
+	WHERE f.id = $1 AND DATE(f.departure_time) = $2
+`
+
+// seatOpeningBalanceQuery records the seats a flight date's inventory had already booked
+// through this system before its first event, so replaying events starts from the same
+// counter the database would have loaded. A flight date only ever gets one.
+const seatOpeningBalanceQuery = `
+	INSERT INTO seat_events (flight_id, date, kind, seats, reason, actor)
+	SELECT f.id, $2, $3, f.booked_seats - COALESCE(i.booked_seats, f.booked_seats), $4, 'system'
+	FROM flights f
+	LEFT JOIN flight_inventory i ON i.flight_id = f.id AND i.date = $2
+	WHERE f.id = $1 AND DATE(f.departure_time) = $2
+	ON CONFLICT (flight_id, date) WHERE reason = 'opening_balance' DO NOTHING
+`
+
+// execer is satisfied by *database.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// ensureSeatOpeningBalance records a flight date's opening balance if it has none yet
+func ensureSeatOpeningBalance(ctx context.Context, exec execer, flightID int, date string) error {
+	_, err := exec.ExecContext(ctx, seatOpeningBalanceQuery, flightID, date,
+		models.SeatEventAdjust, models.SeatReasonOpeningBalance)
+	if err != nil {
+		return fmt.Errorf("failed to record seat opening balance: %w", err)
+	}
+	return nil
+}
+
+// recordSeatEvents appends seat events in one transaction, opening each flight date's
+// stream first if needed
+func (fs *FlightService) recordSeatEvents(ctx context.Context, events ...seatEvent) error {
+	query := `
+		INSERT INTO seat_events (flight_id, date, kind, seats, reason, actor)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))
+	`
+
+	return fs.db.RetryTransaction(ctx, nil, func(tx *sql.Tx) error {
+		for _, event := range events {
+			if err := ensureSeatOpeningBalance(ctx, tx, event.flightID, event.date); err != nil {
+				return err
+			}
+			reason := event.reason
+			if reason == "" {
+				reason = models.SeatReasonUnspecified
+			}
+			_, err := tx.ExecContext(ctx, query, event.flightID, event.date, event.kind, event.seats, reason, event.actor)
+			if err != nil {
+				return fmt.Errorf("failed to record seat event: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// projectSeats returns a flight date's base capacity, the available seats its events
+// project, and the number of events
+func (fs *FlightService) projectSeats(ctx context.Context, flightID int, date string) (base, available, events int, err error) {
+	var net int
+	err = fs.db.QueryRowContext(ctx, seatProjectionQuery, flightID, date).Scan(&base, &net, &events)
+	if err == sql.ErrNoRows {
+		return 0, 0, 0, ErrFlightNotFound
+	}
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to project seat events: %w", err)
+	}
+	return base, base + net, events, nil
+}
+
+// loadSeatsFromEvents returns a flight date's available seats replayed from its events,
+// opening the stream from the database's booked count on first use
+func (fs *FlightService) loadSeatsFromEvents(ctx context.Context, flightID int, date string) (int, error) {
+	if err := ensureSeatOpeningBalance(ctx, fs.db, flightID, date); err != nil {
+		return 0, err
+	}
+	_, available, _, err := fs.projectSeats(ctx, flightID, date)
+	if err != nil {
+		return 0, err
+	}
+	return available, nil
+}
+
+// replaceSeatCounter overwrites a flight date's cached seat counter and returns the value
+// it replaced, or nil when none was cached
+func (fs *FlightService) replaceSeatCounter(ctx context.Context, flightID int, date string, available int) (*int, error) {
+	cacheKey := database.GenerateSeatCacheKey(flightID, date)
+
+	var previous *int
+	value, err := fs.cache.GetSet(ctx, cacheKey, available).Int()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to update seat count: %w", err)
+	}
+	if err == nil {
+		previous = &value
+	}
+
+	fs.cdn.Purge(cdn.FlightKey(flightID, date))
+
+	// Clear any expiry left from before counters were persistent
+	pipe := fs.cache.Pipeline()
+	pipe.Persist(ctx, cacheKey)
+	fs.markSeatsReconciled(ctx, pipe, flightID, date)
+	trackSeatCounter(ctx, pipe, flightID, date)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to track seat counter: %v", err)
+	}
+
+	return previous, nil
+}
+
+// ListSeatEvents returns a flight date's seat events in order, with the counter each projects
+func (fs *FlightService) ListSeatEvents(ctx context.Context, flightID int, date string) (*models.SeatEventsResponse, error) {
+	if !fs.seatEvents {
+		return nil, ErrSeatEventsDisabled
+	}
+
+	base, _, _, err := fs.projectSeats(ctx, flightID, date)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id, kind, seats, reason, COALESCE(actor, ''), created_at
+		FROM seat_events
+		WHERE flight_id = $1 AND date = $2
+		ORDER BY id
+	`
+
+	rows, err := fs.db.QueryContext(ctx, query, flightID, date)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query seat events: %w", err)
+	}
+	defer rows.Close()
+
+	response := &models.SeatEventsResponse{
+		FlightID:  flightID,
+		Date:      date,
+		BaseSeats: base,
+		Events:    []models.SeatEvent{},
+		Available: base,
+	}
+	for rows.Next() {
+		var event models.SeatEvent
+		if err := rows.Scan(&event.ID, &event.Kind, &event.Seats, &event.Reason, &event.Actor, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan seat event: %w", err)
+		}
+		response.Available += event.Seats
+		event.Available = response.Available
+		response.Events = append(response.Events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read seat events: %w", err)
+	}
+
+	return response, nil
+}
+
+// RebuildSeats replays seat events into the cached seat counters, e.g. after the cache was
+// lost or flushed. A zero flightID or empty date matches every flight or date with events.
+// Reservations made while a counter is rebuilt may be overwritten, so run it while the
+// affected flights are quiet or straight after a cache loss.
+func (fs *FlightService) RebuildSeats(ctx context.Context, flightID int, date, triggeredBy string) (*models.SeatRebuildResponse, error) {
+	if !fs.seatEvents {
+		return nil, ErrSeatEventsDisabled
+	}
+
+	query := `
+		SELECT DISTINCT flight_id, date
+		FROM seat_events
+		WHERE flight_id = COALESCE(NULLIF($1, 0), flight_id)
+		  AND date = COALESCE(NULLIF($2, '')::date, date)
+		ORDER BY flight_id, date
+	`
+
+	rows, err := fs.db.QueryContext(ctx, query, flightID, date)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query seat event streams: %w", err)
+	}
+	var counters []models.SeatRebuildResult
+	for rows.Next() {
+		var counter models.SeatRebuildResult
+		var counterDate time.Time
+		if err := rows.Scan(&counter.FlightID, &counterDate); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan seat event stream: %w", err)
+		}
+		counter.Date = counterDate.Format("2006-01-02")
+		counters = append(counters, counter)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read seat event streams: %w", err)
+	}
+
+	response := &models.SeatRebuildResponse{
+		Counters:    []models.SeatRebuildResult{},
+		TriggeredBy: triggeredBy,
+		RebuiltAt:   time.Now(),
+	}
+	for _, counter := range counters {
+		_, available, events, err := fs.projectSeats(ctx, counter.FlightID, counter.Date)
+		if err != nil {
+			return nil, fmt.Errorf("failed to project seats for flight %d on %s: %w", counter.FlightID, counter.Date, err)
+		}
+		previous, err := fs.replaceSeatCounter(ctx, counter.FlightID, counter.Date, available)
+		if err != nil {
+			return nil, err
+		}
+
+		counter.Events = events
+		counter.Available = available
+		counter.PreviousAvailable = previous
+		if previous != nil {
+			counter.Delta = available - *previous
+		}
+		response.Counters = append(response.Counters, counter)
+	}
+
+	log.Printf("AUDIT: %d seat counters rebuilt from seat events by %s", len(response.Counters), triggeredBy)
+	return response, nil
+}
@@ -0,0 +1,264 @@
+package status
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cred_flights_booking/internal/database"
+)
+
+// Dependency states
+const (
+	StateUp   = "up"
+	StateDown = "down"
+)
+
+// Overall service states
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+)
+
+// Check probes one dependency; an error marks it down
+type Check struct {
+	Name  string
+	Probe func(ctx context.Context) error
+}
+
+// Queue reports the depth of a work queue or consumer group backlog
+type Queue struct {
+	Name  string
+	Depth func(ctx context.Context) (int64, error)
+}
+
+// Cache reports the hit and miss counts of a cache
+type Cache struct {
+	Name   string
+	Counts func(ctx context.Context) (hits, misses int64, err error)
+}
+
+// Config lists what a service reports at GET /internal/status
+type Config struct {
+	Service  string
+	Checks   []Check
+	Queues   []Queue
+	Caches   []Cache
+	Breakers []string      // expvar maps holding the state of overload protections
+	Timeout  time.Duration // Deadline for each probe
+}
+
+// DependencyStatus is the result of one dependency probe
+type DependencyStatus struct {
+	Name      string  `json:"name"`
+	State     string  `json:"state"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// QueueStatus is the depth of one queue
+type QueueStatus struct {
+	Name  string `json:"name"`
+	Depth int64  `json:"depth"`
+	Error string `json:"error,omitempty"`
+}
+
+// CacheStatus is the hit rate of one cache since its counters started
+type CacheStatus struct {
+	Name    string  `json:"name"`
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hit_rate"` // 0-1; 0 before any lookups
+	Error   string  `json:"error,omitempty"`
+}
+
+// Report is the response of GET /internal/status
+type Report struct {
+	Service      string                     `json:"service"`
+	Status       string                     `json:"status"` // StatusDegraded when any dependency is down
+	CheckedAt    time.Time                  `json:"checked_at"`
+	Dependencies []DependencyStatus         `json:"dependencies"`
+	Breakers     map[string]json.RawMessage `json:"breakers"`
+	Queues       []QueueStatus              `json:"queues"`
+	Caches       []CacheStatus              `json:"caches"`
+}
+
+// Collect probes every dependency concurrently and gathers queue depths, cache hit rates,
+// and protection states
+func Collect(ctx context.Context, cfg Config) *Report {
+	report := &Report{
+		Service:      cfg.Service,
+		Status:       StatusOK,
+		CheckedAt:    time.Now(),
+		Dependencies: make([]DependencyStatus, len(cfg.Checks)),
+		Breakers:     make(map[string]json.RawMessage),
+		Queues:       make([]QueueStatus, 0, len(cfg.Queues)),
+		Caches:       make([]CacheStatus, 0, len(cfg.Caches)),
+	}
+
+	var wg sync.WaitGroup
+	for i, check := range cfg.Checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			report.Dependencies[i] = probe(ctx, check, cfg.Timeout)
+		}(i, check)
+	}
+	wg.Wait()
+
+	for _, dependency := range report.Dependencies {
+		if dependency.State == StateDown {
+			report.Status = StatusDegraded
+		}
+	}
+
+	for _, queue := range cfg.Queues {
+		status := QueueStatus{Name: queue.Name}
+		depth, err := queue.Depth(ctx)
+		if err != nil {
+			status.Error = err.Error()
+		}
+		status.Depth = depth
+		report.Queues = append(report.Queues, status)
+	}
+
+	for _, cache := range cfg.Caches {
+		status := CacheStatus{Name: cache.Name}
+		hits, misses, err := cache.Counts(ctx)
+		if err != nil {
+			status.Error = err.Error()
+		}
+		status.Hits, status.Misses = hits, misses
+		if total := hits + misses; total > 0 {
+			status.HitRate = float64(hits) / float64(total)
+		}
+		report.Caches = append(report.Caches, status)
+	}
+
+	for _, name := range cfg.Breakers {
+		if v := expvar.Get(name); v != nil {
+			report.Breakers[name] = json.RawMessage(v.String())
+		}
+	}
+
+	return report
+}
+
+// probe runs one check with its deadline and times it
+func probe(ctx context.Context, check Check, timeout time.Duration) DependencyStatus {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := check.Probe(ctx)
+	status := DependencyStatus{
+		Name:      check.Name,
+		State:     StateUp,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		status.State = StateDown
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// NewHandler serves the status report as JSON
+func NewHandler(cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := Collect(r.Context(), cfg)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			log.Printf("Failed to encode response: %v", err)
+		}
+	}
+}
+
+// PostgresCheck pings a database
+func PostgresCheck(name string, db *database.DB) Check {
+	return Check{Name: name, Probe: db.PingContext}
+}
+
+// RedisCheck pings Redis
+func RedisCheck(name string, cache *database.RedisClient) Check {
+	return Check{Name: name, Probe: func(ctx context.Context) error {
+		return cache.Ping(ctx).Err()
+	}}
+}
+
+// ServiceCheck calls another service's /health endpoint
+func ServiceCheck(name, baseURL string, client *http.Client) Check {
+	return Check{Name: name, Probe: func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(baseURL, "/")+"/health", nil)
+		if err != nil {
+			return fmt.Errorf("failed to create health request: %w", err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("health check returned status %d", resp.StatusCode)
+		}
+		return nil
+	}}
+}
+
+// ExpvarCache reads hit rates from an expvar map with "hits" and "misses" counters
+func ExpvarCache(name, expvarName string) Cache {
+	return Cache{Name: name, Counts: func(ctx context.Context) (int64, int64, error) {
+		m, ok := expvar.Get(expvarName).(*expvar.Map)
+		if !ok {
+			return 0, 0, nil
+		}
+		counter := func(key string) int64 {
+			if v, ok := m.Get(key).(*expvar.Int); ok {
+				return v.Value()
+			}
+			return 0
+		}
+		return counter("hits"), counter("misses"), nil
+	}}
+}
+
+// RedisKeyspaceCache reads the keyspace hit and miss counts of the whole Redis server
+func RedisKeyspaceCache(name string, cache *database.RedisClient) Cache {
+	return Cache{Name: name, Counts: func(ctx context.Context) (int64, int64, error) {
+		info, err := cache.Info(ctx, "stats").Result()
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to read Redis stats: %w", err)
+		}
+
+		var hits, misses int64
+		scanner := bufio.NewScanner(strings.NewReader(info))
+		for scanner.Scan() {
+			key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+			if !ok {
+				continue
+			}
+			switch key {
+			case "keyspace_hits":
+				hits, _ = strconv.ParseInt(value, 10, 64)
+			case "keyspace_misses":
+				misses, _ = strconv.ParseInt(value, 10, 64)
+			}
+		}
+		return hits, misses, nil
+	}}
+}
@@ -0,0 +1,111 @@
+package tax
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/pkg/models"
+)
+
+// Rule is one tax levied as a percentage of the base fare
+type Rule struct {
+	Code string
+	Rate float64 // Percent, e.g. 2.5
+}
+
+// Config holds the tax rules for each route domesticity
+type Config struct {
+	HomeCountry   string // ISO 3166 code; routes between two of its airports are domestic
+	Domestic      []Rule
+	International []Rule
+}
+
+// LoadConfig loads tax rules from the environment. Rules are CODE:RATE lists, e.g.
+// TAX_DOMESTIC_RULES=CGST:2.5,SGST:2.5; without rules no tax is charged.
+func LoadConfig() Config {
+	return Config{
+		HomeCountry:   strings.ToUpper(config.GetEnv("TAX_HOME_COUNTRY", "IN")),
+		Domestic:      loadRules("TAX_DOMESTIC_RULES"),
+		International: loadRules("TAX_INTERNATIONAL_RULES"),
+	}
+}
+
+// loadRules parses a rule list from the environment, skipping invalid entries
+func loadRules(key string) []Rule {
+	var rules []Rule
+	for _, spec := range config.GetList(key, nil) {
+		rule, err := ParseRule(spec)
+		if err != nil {
+			log.Printf("Ignoring %s entry: %v", key, err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// ParseRule parses a CODE:RATE rule such as "IGST:5"
+func ParseRule(spec string) (Rule, error) {
+	code, rateStr, ok := strings.Cut(strings.TrimSpace(spec), ":")
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if !ok || code == "" {
+		return Rule{}, fmt.Errorf("invalid tax rule %q, expected CODE:RATE", spec)
+	}
+	rate, err := strconv.ParseFloat(strings.TrimSpace(rateStr), 64)
+	if err != nil || rate < 0 || rate > 100 {
+		return Rule{}, fmt.Errorf("invalid rate in tax rule %q, expected a percent between 0 and 100", spec)
+	}
+	return Rule{Code: code, Rate: rate}, nil
+}
+
+// Engine computes the taxes on a fare by the route's jurisdiction
+type Engine struct {
+	cfg Config
+}
+
+// NewEngine creates a tax engine
+func NewEngine(cfg Config) *Engine {
+	return &Engine{cfg: cfg}
+}
+
+// Jurisdiction returns the jurisdiction of a route between airports in the given countries.
+// An unknown country is assumed to be the home country.
+func (e *Engine) Jurisdiction(sourceCountry, destinationCountry string) string {
+	isHome := func(country string) bool {
+		return country == "" || strings.EqualFold(country, e.cfg.HomeCountry)
+	}
+	if isHome(sourceCountry) && isHome(destinationCountry) {
+		return models.TaxJurisdictionDomestic
+	}
+	return models.TaxJurisdictionInternational
+}
+
+// rules returns the rules of a jurisdiction
+func (e *Engine) rules(jurisdiction string) []Rule {
+	if jurisdiction == models.TaxJurisdictionDomestic {
+		return e.cfg.Domestic
+	}
+	return e.cfg.International
+}
+
+// Apply returns the tax lines on a base fare and the total including them. Each line is
+// rounded to the nearest minor unit on its own, as it is printed on the receipt.
+func (e *Engine) Apply(base models.Money, jurisdiction string) ([]models.TaxLine, models.Money) {
+	var lines []models.TaxLine
+	total := base
+	for _, rule := range e.rules(jurisdiction) {
+		line := models.TaxLine{
+			Jurisdiction: jurisdiction,
+			Code:         rule.Code,
+			Rate:         rule.Rate,
+			Taxable:      base,
+			Amount:       base.Scale(rule.Rate / 100),
+		}
+		lines = append(lines, line)
+		total = total.Add(line.Amount)
+	}
+	return lines, total
+}
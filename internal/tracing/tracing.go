@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+)
+
+// HeaderRequestID carries the trace ID between services and back to clients
+const HeaderRequestID = "X-Request-ID"
+
+// HeaderSessionID carries a client's browsing session across requests and services
+const HeaderSessionID = "X-Session-ID"
+
+// RequestInfo describes the request being served, for logs
+type RequestInfo struct {
+	TraceID   string
+	SessionID string // Client-supplied; empty when the client sent none
+	Route     string // Matched route pattern, e.g. "GET /api/flights/{id}"
+}
+
+// contextKey is the context key for RequestInfo
+type contextKey struct{}
+
+// NewContext returns a context carrying the request info
+func NewContext(ctx context.Context, info *RequestInfo) context.Context {
+	return context.WithValue(ctx, contextKey{}, info)
+}
+
+// FromContext returns the request info, or nil outside a traced request
+func FromContext(ctx context.Context) *RequestInfo {
+	info, _ := ctx.Value(contextKey{}).(*RequestInfo)
+	return info
+}
+
+// TraceID returns the trace ID of the request, or "" outside a traced request
+func TraceID(ctx context.Context) string {
+	if info := FromContext(ctx); info != nil {
+		return info.TraceID
+	}
+	return ""
+}
+
+// SessionID returns the client's session ID, falling back to the trace ID so a single
+// request still correlates across services. It is "" outside a traced request.
+func SessionID(ctx context.Context) string {
+	info := FromContext(ctx)
+	if info == nil {
+		return ""
+	}
+	if info.SessionID != "" {
+		return info.SessionID
+	}
+	return info.TraceID
+}
+
+// SetRoute records the matched route pattern on the request info
+func SetRoute(ctx context.Context, route string) {
+	if info := FromContext(ctx); info != nil {
+		info.Route = route
+	}
+}
+
+// Transport propagates the trace and session IDs to downstream services
+type Transport struct {
+	Base http.RoundTripper // Defaults to http.DefaultTransport
+}
+
+// RoundTrip sets the request and session ID headers from the request context
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	info := FromContext(req.Context())
+	if info == nil {
+		return base.RoundTrip(req)
+	}
+
+	setTrace := info.TraceID != "" && req.Header.Get(HeaderRequestID) == ""
+	setSession := info.SessionID != "" && req.Header.Get(HeaderSessionID) == ""
+	if setTrace || setSession {
+		req = req.Clone(req.Context())
+		if setTrace {
+			req.Header.Set(HeaderRequestID, info.TraceID)
+		}
+		if setSession {
+			req.Header.Set(HeaderSessionID, info.SessionID)
+		}
+	}
+	return base.RoundTrip(req)
+}
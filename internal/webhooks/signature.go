@@ -0,0 +1,226 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cred_flights_booking/internal/config"
+	"cred_flights_booking/internal/database"
+	"cred_flights_booking/internal/middleware"
+	"github.com/google/uuid"
+)
+
+// Webhook delivery headers
+const (
+	HeaderID        = "X-Webhook-ID"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// signatureScheme prefixes each signature in the signature header
+const signatureScheme = "v1="
+
+// maxBodyBytes bounds the webhook bodies read for verification
+const maxBodyBytes = 1 << 20
+
+var (
+	// ErrMissingSignature is returned when a delivery has no signature headers
+	ErrMissingSignature = errors.New("missing webhook signature")
+	// ErrInvalidSignature is returned when no signature matches a known secret
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrTimestampOutOfRange is returned when a delivery is older or newer than the tolerance allows
+	ErrTimestampOutOfRange = errors.New("webhook timestamp outside tolerance")
+	// ErrReplayed is returned when a delivery was already received
+	ErrReplayed = errors.New("webhook delivery already received")
+)
+
+// Config holds webhook signing secrets and the accepted clock skew
+type Config struct {
+	Secrets   []string      // All active secrets; deliveries are signed with each so secrets can rotate
+	Tolerance time.Duration // Maximum age (or clock skew) of a delivery timestamp
+}
+
+// LoadConfig loads webhook settings from the environment
+func LoadConfig() Config {
+	return Config{
+		Secrets:   config.GetList("WEBHOOK_SECRETS", nil),
+		Tolerance: config.GetDuration("WEBHOOK_TOLERANCE", 5*time.Minute),
+	}
+}
+
+// Enabled reports whether any signing secret is configured
+func (c Config) Enabled() bool {
+	return len(c.Secrets) > 0
+}
+
+// computeSignature returns the hex HMAC-SHA256 of "id.timestamp.body"
+func computeSignature(secret, id, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(id))
+	mac.Write([]byte("."))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Signer signs outgoing webhook deliveries
+type Signer struct {
+	secrets []string
+}
+
+// NewSigner creates a signer; without secrets, Sign leaves requests unsigned
+func NewSigner(cfg Config) *Signer {
+	return &Signer{
+		secrets: cfg.Secrets,
+	}
+}
+
+// Sign sets the delivery ID, timestamp, and signature headers for a request body.
+// Retries must be signed again so they carry a fresh timestamp.
+func (s *Signer) Sign(req *http.Request, body []byte) {
+	if len(s.secrets) == 0 {
+		return
+	}
+
+	id := uuid.New().String()
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	signatures := make([]string, len(s.secrets))
+	for i, secret := range s.secrets {
+		signatures[i] = signatureScheme + computeSignature(secret, id, timestamp, body)
+	}
+
+	req.Header.Set(HeaderID, id)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, strings.Join(signatures, ","))
+}
+
+// Verifier checks signatures, timestamps, and replays of incoming webhook deliveries
+type Verifier struct {
+	secrets   []string
+	tolerance time.Duration
+	cache     *database.RedisClient
+}
+
+// NewVerifier creates a verifier that records received deliveries in Redis
+func NewVerifier(cfg Config, cache *database.RedisClient) *Verifier {
+	return &Verifier{
+		secrets:   cfg.Secrets,
+		tolerance: cfg.Tolerance,
+		cache:     cache,
+	}
+}
+
+// Verify checks a delivery's signature against every active secret and its timestamp against
+// the tolerance, then records it so the same delivery is rejected if sent again
+func (v *Verifier) Verify(ctx context.Context, header http.Header, body []byte) error {
+	id := header.Get(HeaderID)
+	timestamp := header.Get(HeaderTimestamp)
+	signatureHeader := header.Get(HeaderSignature)
+	if id == "" || timestamp == "" || signatureHeader == "" {
+		return ErrMissingSignature
+	}
+
+	sentAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrTimestampOutOfRange
+	}
+	age := time.Since(time.Unix(sentAt, 0))
+	if age > v.tolerance || age < -v.tolerance {
+		return ErrTimestampOutOfRange
+	}
+
+	if !v.matches(id, timestamp, body, signatureHeader) {
+		return ErrInvalidSignature
+	}
+
+	// Deliveries are only accepted within the tolerance, so remembering them that long suffices
+	replayKey := database.GenerateWebhookReplayKey(id, timestamp)
+	fresh, err := v.cache.SetNX(ctx, replayKey, 1, 2*v.tolerance).Result()
+	if err != nil {
+		return fmt.Errorf("failed to check webhook replay: %w", err)
+	}
+	if !fresh {
+		return ErrReplayed
+	}
+	return nil
+}
+
+// matches reports whether any provided signature matches any active secret
+func (v *Verifier) matches(id, timestamp string, body []byte, signatureHeader string) bool {
+	for _, secret := range v.secrets {
+		expected := []byte(computeSignature(secret, id, timestamp, body))
+		for _, provided := range strings.Split(signatureHeader, ",") {
+			provided, ok := strings.CutPrefix(strings.TrimSpace(provided), signatureScheme)
+			if ok && hmac.Equal([]byte(provided), expected) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Middleware rejects deliveries that fail verification. Without secrets it lets every request through.
+func (v *Verifier) Middleware() middleware.Middleware {
+	return v.middleware(false)
+}
+
+// Require rejects deliveries that fail verification, and every request when no secrets are
+// configured, for routes that must never be open (e.g. issuing seat release nonces)
+func (v *Verifier) Require() middleware.Middleware {
+	return v.middleware(true)
+}
+
+// middleware verifies signed requests; without secrets it lets them through or, when
+// required, refuses them
+func (v *Verifier) middleware(required bool) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		if len(v.secrets) == 0 && required {
+			log.Printf("WARNING: WEBHOOK_SECRETS not set, refusing requests to service-only routes")
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "Service credentials required", http.StatusUnauthorized)
+			})
+		}
+		if len(v.secrets) == 0 {
+			log.Printf("WEBHOOK_SECRETS not set, webhook signatures are not verified")
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes))
+			if err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+
+			if err := v.Verify(r.Context(), r.Header, body); err != nil {
+				status := http.StatusUnauthorized
+				switch {
+				case errors.Is(err, ErrReplayed):
+					status = http.StatusConflict
+				case !errors.Is(err, ErrMissingSignature) && !errors.Is(err, ErrInvalidSignature) &&
+					!errors.Is(err, ErrTimestampOutOfRange):
+					log.Printf("Webhook verification error: %v", err)
+					status = http.StatusInternalServerError
+				}
+				http.Error(w, err.Error(), status)
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,322 @@
+// Package websocket implements the WebSocket protocol (RFC 6455) for text messages, which
+// is all the services push: JSON in both directions. Upgrade serves the services'
+// endpoints; Dial connects to them as a client, e.g. from the stress tester.
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// acceptGUID is appended to the client's key to compute Sec-WebSocket-Accept
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// MaxMessageBytes bounds a message read from the peer, across fragments
+const MaxMessageBytes = 64 * 1024
+
+// writeWait bounds how long a single frame write may block on a slow client
+const writeWait = 10 * time.Second
+
+// Frame opcodes
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// Close status codes
+const (
+	CloseNormal        = 1000
+	CloseGoingAway     = 1001
+	CloseProtocolError = 1002
+	CloseUnsupported   = 1003
+	CloseTooLarge      = 1009
+	CloseTryAgainLater = 1013
+)
+
+// ErrNotWebSocket is returned by Upgrade when the request is not a valid WebSocket handshake
+var ErrNotWebSocket = errors.New("not a websocket handshake")
+
+// CloseError is returned by ReadMessage when the client closes the connection
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+// Error implements error
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket closed with code %d: %s", e.Code, e.Reason)
+}
+
+// Conn is a WebSocket connection. One goroutine may read while others write.
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	client bool // Dialed: frames sent are masked, frames received are not
+
+	writeMu sync.Mutex
+	closed  bool
+
+	// OnPong is called for every pong received, e.g. to extend the read deadline
+	OnPong func()
+}
+
+// Upgrade completes the WebSocket handshake and takes over the request's connection. On
+// failure it has already written an error response.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "Expected a WebSocket upgrade", http.StatusBadRequest)
+		return nil, ErrNotWebSocket
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, ErrNotWebSocket
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "Missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, ErrNotWebSocket
+	}
+
+	netConn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "WebSocket upgrade not supported", http.StatusInternalServerError)
+		return nil, fmt.Errorf("failed to hijack connection: %w", err)
+	}
+
+	// The server's read and write timeouts were set for the HTTP request
+	netConn.SetDeadline(time.Time{})
+
+	hash := sha1.Sum([]byte(key + acceptGUID))
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(hash[:]) + "\r\n\r\n"
+	if _, err := rw.WriteString(response); err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("failed to write handshake: %w", err)
+	}
+	if err := rw.Flush(); err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("failed to write handshake: %w", err)
+	}
+
+	return &Conn{conn: netConn, reader: rw.Reader}, nil
+}
+
+// headerContains reports whether a comma-separated header has a token, case-insensitively
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SetReadDeadline sets the deadline for the next ReadMessage
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// ReadMessage returns the next text or binary message from the peer. Pings are answered
+// and pongs reported via OnPong while waiting. A close frame is echoed and returned as a
+// *CloseError.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	var message []byte
+	inMessage := false
+
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+		case opPong:
+			if c.OnPong != nil {
+				c.OnPong()
+			}
+		case opClose:
+			closeErr := &CloseError{Code: CloseNormal}
+			if len(payload) >= 2 {
+				closeErr.Code = int(binary.BigEndian.Uint16(payload))
+				closeErr.Reason = string(payload[2:])
+			}
+			c.Close(closeErr.Code, "")
+			return nil, closeErr
+		case opText, opBinary, opContinuation:
+			if (opcode == opContinuation) != inMessage {
+				c.Close(CloseProtocolError, "unexpected frame")
+				return nil, fmt.Errorf("unexpected frame with opcode %d", opcode)
+			}
+			if len(message)+len(payload) > MaxMessageBytes {
+				c.Close(CloseTooLarge, "message too large")
+				return nil, fmt.Errorf("message exceeds %d bytes", MaxMessageBytes)
+			}
+			message = append(message, payload...)
+			inMessage = !fin
+			if fin {
+				return message, nil
+			}
+		default:
+			c.Close(CloseProtocolError, "unknown opcode")
+			return nil, fmt.Errorf("unknown opcode %d", opcode)
+		}
+	}
+}
+
+// readFrame reads and unmasks one frame
+func (c *Conn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	// Clients must mask every frame, and servers none
+	if masked == c.client {
+		c.Close(CloseProtocolError, "unexpected frame masking")
+		return false, 0, nil, fmt.Errorf("received a frame with mask bit %t", masked)
+	}
+	if length > MaxMessageBytes {
+		c.Close(CloseTooLarge, "message too large")
+		return false, 0, nil, fmt.Errorf("frame exceeds %d bytes", MaxMessageBytes)
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		maskPayload(payload, mask)
+	}
+	return fin, opcode, payload, nil
+}
+
+// WriteMessage sends a text message
+func (c *Conn) WriteMessage(data []byte) error {
+	return c.writeFrame(opText, data)
+}
+
+// WriteJSON sends a value as a JSON text message
+func (c *Conn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	return c.WriteMessage(data)
+}
+
+// Ping sends a ping; the client's pong is reported via OnPong
+func (c *Conn) Ping() error {
+	return c.writeFrame(opPing, nil)
+}
+
+// Close sends a close frame with a status code and closes the connection. It is safe to
+// call more than once.
+func (c *Conn) Close(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	c.writeFrame(opClose, payload)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.closed = true
+	return c.conn.Close()
+}
+
+// writeFrame sends one unfragmented frame, masked when dialed
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closed {
+		return net.ErrClosed
+	}
+
+	header := make([]byte, 2, 14+len(payload))
+	header[0] = 0x80 | opcode
+	switch {
+	case len(payload) < 126:
+		header[1] = byte(len(payload))
+	case len(payload) <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(len(payload)))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(len(payload)))
+	}
+	if c.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return fmt.Errorf("failed to generate frame mask: %w", err)
+		}
+		header[1] |= 0x80
+		header = append(header, mask[:]...)
+		payload = append([]byte(nil), payload...)
+		maskPayload(payload, mask)
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		return fmt.Errorf("failed to write frame: %w", err)
+	}
+	return nil
+}
+
+// maskPayload applies (or removes) a frame's masking key in place
+func maskPayload(payload []byte, mask [4]byte) {
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+}
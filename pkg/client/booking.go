@@ -0,0 +1,178 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"cred_flights_booking/pkg/models"
+)
+
+// BookingClient calls the Booking Service API
+type BookingClient struct {
+	client
+}
+
+// NewBookingClient creates a Booking Service client
+func NewBookingClient(cfg Config) *BookingClient {
+	return &BookingClient{client: newClient(cfg)}
+}
+
+// CreateBooking books seats on a flight date and charges for them. A booking that failed
+// (e.g. a declined payment) is not an error: check the response's Status. Requests with an
+// IdempotencyKey are retried, since a repeat returns the original booking. A booking queued
+// behind others for a busy flight is resubmitted with its ticket until it is admitted or
+// ctx ends.
+func (bc *BookingClient) CreateBooking(ctx context.Context, req *models.BookingRequest) (*models.BookingResponse, error) {
+	body := *req
+	for {
+		call := request{
+			method:         "POST",
+			path:           "/api/bookings",
+			body:           &body,
+			idempotent:     body.IdempotencyKey != "",
+			resultStatuses: []int{http.StatusBadRequest},
+		}
+
+		var response models.BookingResponse
+		if err := bc.do(ctx, call, &response); err != nil {
+			return nil, err
+		}
+		if response.Status != models.BookingStatusQueued {
+			return &response, nil
+		}
+
+		body.QueueTicket = response.QueueTicket
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("booking still queued at position %d: %w", response.QueuePosition, ctx.Err())
+		case <-time.After(max(time.Duration(response.RetryAfterSeconds)*time.Second, time.Second)):
+		}
+	}
+}
+
+// GetBooking returns a booking by ID
+func (bc *BookingClient) GetBooking(ctx context.Context, bookingID int) (*models.Booking, error) {
+	// The handler reads the ID from the query string
+	path := fmt.Sprintf("/api/bookings/%d?id=%d", bookingID, bookingID)
+
+	var booking models.Booking
+	if err := bc.do(ctx, request{method: "GET", path: path}, &booking); err != nil {
+		return nil, err
+	}
+	return &booking, nil
+}
+
+// CancelBooking cancels a booking, or only some of its seats when seats is positive
+func (bc *BookingClient) CancelBooking(ctx context.Context, bookingID, seats int) (*models.CancellationResponse, error) {
+	path := fmt.Sprintf("/api/bookings/%d/cancel?id=%d", bookingID, bookingID)
+
+	var response models.CancellationResponse
+	if err := bc.do(ctx, request{method: "PUT", path: path, body: &models.CancelBookingRequest{Seats: seats}}, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ExtendHold gives a user more time to pay for a pending booking's seat hold. Extensions
+// are limited, so it is not retried.
+func (bc *BookingClient) ExtendHold(ctx context.Context, holdID string, userID int) (*models.HoldExtensionResponse, error) {
+	path := "/api/bookings/holds/" + url.PathEscape(holdID) + "/extend"
+
+	var response models.HoldExtensionResponse
+	if err := bc.do(ctx, request{method: "POST", path: path, body: &models.HoldExtensionRequest{UserID: userID}}, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// UpgradeOffers returns the cabin upgrades a confirmed booking can buy
+func (bc *BookingClient) UpgradeOffers(ctx context.Context, bookingID int) (*models.UpgradeOffersResponse, error) {
+	var response models.UpgradeOffersResponse
+	if err := bc.do(ctx, request{method: "GET", path: fmt.Sprintf("/api/bookings/%d/offers", bookingID)}, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// AcceptUpgradeOffer buys an upgrade offer. It charges the offer's price, so it is not retried.
+func (bc *BookingClient) AcceptUpgradeOffer(ctx context.Context, bookingID int, offerID string) (*models.UpgradeResponse, error) {
+	path := fmt.Sprintf("/api/bookings/%d/offers/%s/accept", bookingID, url.PathEscape(offerID))
+
+	var response models.UpgradeResponse
+	if err := bc.do(ctx, request{method: "POST", path: path}, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// LinkBookings links a booking to a companion's booking on the same flight date and returns
+// their shared itinerary
+func (bc *BookingClient) LinkBookings(ctx context.Context, bookingID, otherID int) (*models.ItineraryResponse, error) {
+	path := fmt.Sprintf("/api/bookings/%d/links", bookingID)
+
+	var response models.ItineraryResponse
+	if err := bc.do(ctx, request{method: "POST", path: path, body: &models.BookingLinkRequest{BookingID: otherID}, idempotent: true}, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// UnlinkBooking removes a booking from its linked group
+func (bc *BookingClient) UnlinkBooking(ctx context.Context, bookingID int) error {
+	return bc.do(ctx, request{method: "DELETE", path: fmt.Sprintf("/api/bookings/%d/links", bookingID)}, nil)
+}
+
+// GetItinerary returns the shared itinerary of a booking and the bookings linked to it
+func (bc *BookingClient) GetItinerary(ctx context.Context, bookingID int) (*models.ItineraryResponse, error) {
+	var response models.ItineraryResponse
+	if err := bc.do(ctx, request{method: "GET", path: fmt.Sprintf("/api/bookings/%d/itinerary", bookingID)}, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ListUserBookings returns a user's bookings, newest first, optionally filtered by status
+func (bc *BookingClient) ListUserBookings(ctx context.Context, userID int, status string, limit, offset int) (*models.UserBookingsResponse, error) {
+	query := url.Values{}
+	if status != "" {
+		query.Set("status", status)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	if offset > 0 {
+		query.Set("offset", strconv.Itoa(offset))
+	}
+
+	var response models.UserBookingsResponse
+	path := fmt.Sprintf("/api/users/%d/bookings?%s", userID, query.Encode())
+	if err := bc.do(ctx, request{method: "GET", path: path}, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GetConfirmedSeats returns the seats held by confirmed and flown bookings for a flight date
+func (bc *BookingClient) GetConfirmedSeats(ctx context.Context, flightID int, date string) (*models.ConfirmedSeatsResponse, error) {
+	path := fmt.Sprintf("/api/bookings/seats?flight_id=%d&date=%s", flightID, url.QueryEscape(date))
+
+	var response models.ConfirmedSeatsResponse
+	if err := bc.do(ctx, request{method: "GET", path: path}, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// CreateAgency opens an agency account and returns its API key, which is only shown once.
+// It requires AdminToken and is not retried, since a repeat would open a second account.
+func (bc *BookingClient) CreateAgency(ctx context.Context, req *models.AgencyRequest) (*models.AgencyCreatedResponse, error) {
+	var response models.AgencyCreatedResponse
+	if err := bc.do(ctx, request{method: "POST", path: "/api/admin/agencies", body: req}, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
@@ -0,0 +1,265 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"cred_flights_booking/pkg/models"
+)
+
+// userAgent identifies requests made through this package
+const userAgent = "cred-flights-client/" + models.Version
+
+// maxErrorBodyBytes bounds the error bodies kept in an APIError
+const maxErrorBodyBytes = 4096
+
+// maxRetryDelay caps the wait between attempts, including a server's Retry-After
+const maxRetryDelay = 10 * time.Second
+
+// Auth headers understood by the services
+const (
+	HeaderAdminUser  = "X-Admin-User"
+	HeaderAdminToken = "X-Admin-Token"
+	HeaderAgencyKey  = "X-Agency-Key"
+	HeaderAPIKey     = "X-API-Key"
+)
+
+// Errors matched by errors.Is against an *APIError, by status code
+var (
+	ErrBadRequest   = errors.New("bad request")         // 400, 422
+	ErrUnauthorized = errors.New("unauthorized")        // 401, 403
+	ErrNotFound     = errors.New("not found")           // 404
+	ErrConflict     = errors.New("conflict")            // 409
+	ErrRateLimited  = errors.New("rate limited")        // 429
+	ErrUnavailable  = errors.New("service unavailable") // 502, 503, 504
+)
+
+// RequestSigner signs a request before it is sent (e.g. webhooks.Signer for calls the
+// receiving service verifies)
+type RequestSigner interface {
+	Sign(req *http.Request, body []byte)
+}
+
+// Config holds the connection, retry, and auth settings of a service client
+type Config struct {
+	BaseURL    string        // Service root, e.g. http://localhost:8081
+	HTTPClient *http.Client  // Defaults to a client with Timeout
+	Timeout    time.Duration // Per-request timeout of the default HTTP client (default 30s)
+
+	// Attempts per idempotent request (default 3; 1 disables retries). Requests that could
+	// apply twice, such as payments or seat updates, are never retried.
+	MaxAttempts  int
+	RetryBackoff time.Duration // Delay before the first retry, doubling per attempt (default 100ms)
+
+	AdminUser  string        // Sent as X-Admin-User on every request; must name AdminToken's operator
+	AdminToken string        // The operator's own token, sent as X-Admin-Token
+	AgencyKey  string        // Sent as X-Agency-Key (Booking Service agency routes)
+	APIKey     string        // Sent as X-API-Key (Flight Service partner routes)
+	Signer     RequestSigner // Signs requests to webhook routes, when set
+}
+
+// APIError is returned when a service answers with a non-2xx status
+type APIError struct {
+	StatusCode int
+	Code       string // Machine-readable code, when the service sent a JSON error envelope
+	Message    string
+	RetryAfter time.Duration // From the Retry-After header, when present
+}
+
+// Error implements error
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("request failed with status %d (%s): %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("request failed with status %d: %s", e.StatusCode, e.Message)
+}
+
+// Is matches the sentinel error for the status code
+func (e *APIError) Is(target error) bool {
+	switch e.StatusCode {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return target == ErrBadRequest
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return target == ErrUnauthorized
+	case http.StatusNotFound:
+		return target == ErrNotFound
+	case http.StatusConflict:
+		return target == ErrConflict
+	case http.StatusTooManyRequests:
+		return target == ErrRateLimited
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return target == ErrUnavailable
+	}
+	return false
+}
+
+// retryable reports whether a failed attempt may succeed if repeated
+func (e *APIError) retryable() bool {
+	return errors.Is(e, ErrRateLimited) || errors.Is(e, ErrUnavailable)
+}
+
+// client performs JSON requests against one service
+type client struct {
+	cfg        Config
+	baseURL    string
+	httpClient *http.Client
+}
+
+// newClient creates a client from a config, applying defaults
+func newClient(cfg Config) client {
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		timeout := cfg.Timeout
+		if timeout <= 0 {
+			timeout = 30 * time.Second
+		}
+		httpClient = &http.Client{Timeout: timeout}
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 3
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = 100 * time.Millisecond
+	}
+	return client{cfg: cfg, baseURL: strings.TrimRight(cfg.BaseURL, "/"), httpClient: httpClient}
+}
+
+// request describes one API call
+type request struct {
+	method string
+	path   string
+	body   interface{}
+	// Safe to send more than once (always true for GET)
+	idempotent bool
+	// Non-2xx statuses whose JSON body is a result (e.g. a declined payment), not an error
+	resultStatuses []int
+	// Signed with the configured signer
+	signed bool
+}
+
+// do sends a request with an optional JSON body and decodes a JSON response into out
+// (skipped when out is nil). Non-2xx responses are returned as *APIError. Idempotent
+// requests are retried on connection errors, 429, and 502-504, with exponential backoff.
+func (c *client) do(ctx context.Context, req request, out interface{}) error {
+	var jsonData []byte
+	if req.body != nil {
+		var err error
+		if jsonData, err = json.Marshal(req.body); err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+	}
+
+	attempts := 1
+	if req.idempotent || req.method == http.MethodGet {
+		attempts = c.cfg.MaxAttempts
+	}
+
+	delay := c.cfg.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := c.send(ctx, req, jsonData, out)
+		if err == nil || attempt >= attempts || ctx.Err() != nil {
+			return err
+		}
+
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			if !apiErr.retryable() {
+				return err
+			}
+			if apiErr.RetryAfter > delay {
+				delay = min(apiErr.RetryAfter, maxRetryDelay)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+// send makes one attempt of a request
+func (c *client) send(ctx context.Context, req request, jsonData []byte, out interface{}) error {
+	var reader io.Reader
+	if jsonData != nil {
+		reader = bytes.NewReader(jsonData)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, c.baseURL+req.path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	if jsonData != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set("User-Agent", userAgent)
+	c.setAuthHeaders(httpReq)
+	if req.signed && c.cfg.Signer != nil {
+		c.cfg.Signer.Sign(httpReq, jsonData)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to make %s %s request: %w", req.method, req.path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		isResult := slices.Contains(req.resultStatuses, resp.StatusCode) &&
+			strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json")
+		if !isResult {
+			return decodeError(resp)
+		}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s %s response: %w", req.method, req.path, err)
+	}
+	return nil
+}
+
+// setAuthHeaders adds the configured credentials to a request
+func (c *client) setAuthHeaders(httpReq *http.Request) {
+	headers := map[string]string{
+		HeaderAdminUser:  c.cfg.AdminUser,
+		HeaderAdminToken: c.cfg.AdminToken,
+		HeaderAgencyKey:  c.cfg.AgencyKey,
+		HeaderAPIKey:     c.cfg.APIKey,
+	}
+	for name, value := range headers {
+		if value != "" {
+			httpReq.Header.Set(name, value)
+		}
+	}
+}
+
+// decodeError builds an APIError from an error response, which is either the shared JSON
+// error envelope or plain text
+func decodeError(resp *http.Response) *APIError {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+	apiErr := &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+
+	var envelope models.ErrorResponse
+	if json.Unmarshal(data, &envelope) == nil && envelope.Error != "" {
+		apiErr.Code = envelope.Code
+		apiErr.Message = envelope.Error
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr
+}
@@ -0,0 +1,37 @@
+// Package client provides typed Go clients for the flight, booking, and payment services'
+// HTTP APIs, using the request and response types of package models.
+//
+// Each client is safe for concurrent use. Every call takes a context; reads (and writes
+// that are safe to repeat, such as bookings with an idempotency key) are retried on
+// connection errors, 429, and 502-504 with exponential backoff, honouring Retry-After.
+// Requests that come back with an error status return an *APIError, which matches
+// ErrNotFound, ErrConflict, and the other sentinels with errors.Is. Credentials in Config
+// are sent as the services' auth headers on every request:
+//
+//	flights := client.NewFlightClient(client.Config{BaseURL: "http://localhost:8080"})
+//	bookings := client.NewBookingClient(client.Config{
+//		BaseURL:   "http://localhost:8081",
+//		AgencyKey: os.Getenv("AGENCY_KEY"), // Book on the agency's credit
+//	})
+//
+//	results, err := flights.Search(ctx, &models.SearchRequest{
+//		Source: "DEL", Destination: "BOM", Date: "2024-02-15", Seats: 1, SortBy: "cheapest",
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	if len(results.Paths) == 0 {
+//		return errors.New("no flights")
+//	}
+//
+//	booking, err := bookings.CreateBooking(ctx, &models.BookingRequest{
+//		UserID: 42, FlightID: results.Paths[0].Flights[0].ID, Seats: 1, Date: "2024-02-15",
+//		IdempotencyKey: orderID, // Makes the call safe to retry
+//	})
+//	if errors.Is(err, client.ErrBadRequest) {
+//		// The request was rejected; err.(*client.APIError).Message says why
+//	}
+//
+// The API follows models.Version: within a major version, methods and Config fields are
+// only added.
+package client
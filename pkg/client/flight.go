@@ -0,0 +1,245 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"cred_flights_booking/pkg/models"
+)
+
+// FlightClient calls the Flight Service API
+type FlightClient struct {
+	client
+}
+
+// NewFlightClient creates a Flight Service client
+func NewFlightClient(cfg Config) *FlightClient {
+	return &FlightClient{client: newClient(cfg)}
+}
+
+// Search finds direct and connecting flight paths
+func (fc *FlightClient) Search(ctx context.Context, req *models.SearchRequest) (*models.SearchResponse, error) {
+	var response models.SearchResponse
+	if err := fc.do(ctx, request{method: "GET", path: "/api/flights/search?" + searchQuery(req).Encode()}, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// PartnerSearch searches through the partner API, metered and rate limited per key. It
+// requires APIKey with the search scope.
+func (fc *FlightClient) PartnerSearch(ctx context.Context, req *models.SearchRequest) (*models.SearchResponse, error) {
+	var response models.SearchResponse
+	if err := fc.do(ctx, request{method: "GET", path: "/api/partner/v1/flights/search?" + searchQuery(req).Encode()}, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// searchQuery encodes a search request as query parameters
+func searchQuery(req *models.SearchRequest) url.Values {
+	query := url.Values{}
+	query.Set("source", req.Source)
+	query.Set("destination", req.Destination)
+	query.Set("date", req.Date)
+	query.Set("seats", strconv.Itoa(req.Seats))
+	if req.SortBy != "" {
+		query.Set("sort_by", req.SortBy)
+	}
+	if len(req.Airlines) > 0 {
+		query.Set("airline", strings.Join(req.Airlines, ","))
+	}
+	if req.IncludeNearby {
+		query.Set("include_nearby", "true")
+	}
+	if req.NearbyRadiusKm > 0 {
+		query.Set("nearby_radius_km", strconv.FormatFloat(req.NearbyRadiusKm, 'f', -1, 64))
+	}
+	if req.UserID > 0 {
+		query.Set("user_id", strconv.Itoa(req.UserID))
+	}
+	if req.MaxStops != nil {
+		query.Set("max_stops", strconv.Itoa(*req.MaxStops))
+	}
+	for param, value := range map[string]int{
+		"max_per_first_leg":      req.Diversity.MaxPerFirstLeg,
+		"max_per_airline":        req.Diversity.MaxPerAirline,
+		"max_per_departure_hour": req.Diversity.MaxPerDepartureHour,
+	} {
+		if value > 0 {
+			query.Set(param, strconv.Itoa(value))
+		}
+	}
+	return query
+}
+
+// SearchBatch searches one route on several dates, or several routes, in one call. It is a
+// read, so it is retried like a GET.
+func (fc *FlightClient) SearchBatch(ctx context.Context, req *models.BatchSearchRequest) (*models.BatchSearchResponse, error) {
+	var response models.BatchSearchResponse
+	if err := fc.do(ctx, request{method: "POST", path: "/api/flights/search/batch", body: req, idempotent: true}, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GetFlight returns a flight by ID
+func (fc *FlightClient) GetFlight(ctx context.Context, flightID int) (*models.Flight, error) {
+	var flight models.Flight
+	if err := fc.do(ctx, request{method: "GET", path: fmt.Sprintf("/api/flights/%d", flightID)}, &flight); err != nil {
+		return nil, err
+	}
+	return &flight, nil
+}
+
+// Validate checks that a flight date has enough seats and returns the price. It is a read,
+// so it is retried like a GET.
+func (fc *FlightClient) Validate(ctx context.Context, req *models.FlightValidationRequest) (*models.FlightValidationResponse, error) {
+	var validation models.FlightValidationResponse
+	if err := fc.do(ctx, request{method: "POST", path: "/api/flights/validate", body: req, idempotent: true}, &validation); err != nil {
+		return nil, err
+	}
+	return &validation, nil
+}
+
+// DecrementSeats takes seats from a flight date's availability. The response carries the
+// nonce for giving them back.
+func (fc *FlightClient) DecrementSeats(ctx context.Context, req *models.SeatUpdateRequest) (*models.SeatUpdateResponse, error) {
+	var response models.SeatUpdateResponse
+	if err := fc.do(ctx, request{method: "POST", path: "/api/flights/seats/decrement", body: req}, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// IncrementSeats gives seats back to a flight date's availability
+func (fc *FlightClient) IncrementSeats(ctx context.Context, req *models.SeatUpdateRequest) error {
+	return fc.do(ctx, request{method: "POST", path: "/api/flights/seats/increment", body: req}, nil)
+}
+
+// ReleaseNonce returns a nonce for giving seats back to a flight date's cabin (empty for
+// economy). It is a read, so it is retried like a GET. The request is signed with the configured signer; only services
+// may make it.
+func (fc *FlightClient) ReleaseNonce(ctx context.Context, flightID int, date, cabin string, seats int) (*models.SeatNonce, error) {
+	req := &models.SeatUpdateRequest{FlightID: flightID, Date: date, Cabin: cabin, Seats: seats}
+
+	var response models.SeatNonceResponse
+	if err := fc.do(ctx, request{method: "POST", path: "/api/flights/seats/release-nonce", body: req, idempotent: true, signed: true}, &response); err != nil {
+		return nil, err
+	}
+	return response.Nonce, nil
+}
+
+// GetAvailability returns a flight date's live seat counter
+func (fc *FlightClient) GetAvailability(ctx context.Context, flightID int, date string) (*models.SeatAvailabilityResponse, error) {
+	path := fmt.Sprintf("/api/flights/%d/availability?date=%s", flightID, url.QueryEscape(date))
+
+	var availability models.SeatAvailabilityResponse
+	if err := fc.do(ctx, request{method: "GET", path: path}, &availability); err != nil {
+		return nil, err
+	}
+	return &availability, nil
+}
+
+// PopularRoutes returns the precomputed most searched routes with their cheapest fares
+func (fc *FlightClient) PopularRoutes(ctx context.Context) (*models.PopularRoutesResponse, error) {
+	var response models.PopularRoutesResponse
+	if err := fc.do(ctx, request{method: "GET", path: "/api/flights/popular"}, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Cabins returns a flight date's cabins, economy first, with their free seats and fares
+func (fc *FlightClient) Cabins(ctx context.Context, flightID int, date string) (*models.CabinsResponse, error) {
+	path := fmt.Sprintf("/api/flights/%d/cabins?date=%s", flightID, url.QueryEscape(date))
+
+	var response models.CabinsResponse
+	if err := fc.do(ctx, request{method: "GET", path: path}, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// QuoteUpgrades prices moving seats to each higher cabin. It is a read, so it is retried
+// like a GET.
+func (fc *FlightClient) QuoteUpgrades(ctx context.Context, req *models.UpgradeQuoteRequest) (*models.UpgradeQuotesResponse, error) {
+	var response models.UpgradeQuotesResponse
+	if err := fc.do(ctx, request{method: "POST", path: "/api/flights/cabins/quote", body: req, idempotent: true}, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// MoveCabinSeats moves booked seats to a higher cabin. The request is signed with the
+// configured signer, as flight-service only accepts moves from other services. A repeated
+// move would claim the seats twice, so it is not retried.
+func (fc *FlightClient) MoveCabinSeats(ctx context.Context, req *models.CabinMoveRequest) error {
+	return fc.do(ctx, request{method: "POST", path: "/api/flights/cabins/move", body: req, signed: true}, nil)
+}
+
+// RecordOccupancyEvent reports a booked or released seat change for load-factor tracking.
+// The request is signed with the configured signer; events are deduplicated by EventID,
+// so it is retried.
+func (fc *FlightClient) RecordOccupancyEvent(ctx context.Context, event *models.SeatOccupancyEvent) error {
+	return fc.do(ctx, request{method: "POST", path: "/api/flights/occupancy/events", body: event, idempotent: true, signed: true}, nil)
+}
+
+// RecalculateSeats rebuilds a flight date's cached seat counter from the database.
+// It requires AdminToken.
+func (fc *FlightClient) RecalculateSeats(ctx context.Context, flightID int, date string) (*models.SeatRecalculationResponse, error) {
+	path := fmt.Sprintf("/api/admin/flights/%d/seats/recalculate?date=%s", flightID, url.QueryEscape(date))
+
+	var response models.SeatRecalculationResponse
+	if err := fc.do(ctx, request{method: "POST", path: path, idempotent: true}, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// RebuildSeats replays seat events into the cached seat counters of a flight date, every date
+// of a flight, or every flight (zero flightID, empty date). It requires AdminToken and seat
+// event sourcing on the service.
+func (fc *FlightClient) RebuildSeats(ctx context.Context, flightID int, date string) (*models.SeatRebuildResponse, error) {
+	query := url.Values{}
+	if flightID > 0 {
+		query.Set("flight_id", strconv.Itoa(flightID))
+	}
+	if date != "" {
+		query.Set("date", date)
+	}
+	path := "/api/admin/seats/rebuild"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var response models.SeatRebuildResponse
+	if err := fc.do(ctx, request{method: "POST", path: path, idempotent: true}, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// SeatEvents lists a flight date's seat events. It requires AdminToken.
+func (fc *FlightClient) SeatEvents(ctx context.Context, flightID int, date string) (*models.SeatEventsResponse, error) {
+	path := fmt.Sprintf("/api/admin/flights/%d/seats/events?date=%s", flightID, url.QueryEscape(date))
+
+	var response models.SeatEventsResponse
+	if err := fc.do(ctx, request{method: "GET", path: path}, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// CreatePartner registers a partner and returns its API key, which is only shown once. It
+// requires AdminUser and is not retried, since a repeat would create a second partner.
+func (fc *FlightClient) CreatePartner(ctx context.Context, req *models.PartnerRequest) (*models.PartnerCreatedResponse, error) {
+	var response models.PartnerCreatedResponse
+	if err := fc.do(ctx, request{method: "POST", path: "/api/admin/partners", body: req}, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
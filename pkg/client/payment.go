@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"cred_flights_booking/pkg/models"
+)
+
+// Simulated payment outcomes, for testing how callers handle each
+const (
+	SimulateSuccess = "success"
+	SimulateFailure = "failure"
+	SimulateTimeout = "timeout"
+)
+
+// PaymentClient calls the Payment Service API
+type PaymentClient struct {
+	client
+}
+
+// NewPaymentClient creates a Payment Service client
+func NewPaymentClient(cfg Config) *PaymentClient {
+	return &PaymentClient{client: newClient(cfg)}
+}
+
+// ProcessPayment charges a booking's amount. A declined or timed-out payment is not an
+// error: check the response's Status. Payments are never retried.
+func (pc *PaymentClient) ProcessPayment(ctx context.Context, req *models.PaymentRequest) (*models.PaymentResponse, error) {
+	return pc.payment(ctx, "/api/payments/process", req)
+}
+
+// Capture captures a successful payment's authorization once its booking is stored, before
+// its capture_by deadline voids it. Capturing twice is safe. A voided authorization returns
+// ErrConflict.
+func (pc *PaymentClient) Capture(ctx context.Context, paymentID string) (*models.PaymentAuthorization, error) {
+	call := request{
+		method:     "POST",
+		path:       "/api/payments/" + url.PathEscape(paymentID) + "/capture",
+		idempotent: true,
+	}
+
+	var auth models.PaymentAuthorization
+	if err := pc.do(ctx, call, &auth); err != nil {
+		return nil, err
+	}
+	return &auth, nil
+}
+
+// Reverse returns a payment in full for a booking that was rolled back: it is voided while
+// still authorized and refunded once captured. Reversing twice is safe.
+func (pc *PaymentClient) Reverse(ctx context.Context, paymentID, reason string) (*models.PaymentAuthorization, error) {
+	call := request{
+		method:     "POST",
+		path:       "/api/payments/" + url.PathEscape(paymentID) + "/reverse?reason=" + url.QueryEscape(reason),
+		idempotent: true,
+	}
+
+	var auth models.PaymentAuthorization
+	if err := pc.do(ctx, call, &auth); err != nil {
+		return nil, err
+	}
+	return &auth, nil
+}
+
+// Simulate returns a payment with a forced outcome (SimulateSuccess, SimulateFailure, or
+// SimulateTimeout) without charging anything
+func (pc *PaymentClient) Simulate(ctx context.Context, outcome string, req *models.PaymentRequest) (*models.PaymentResponse, error) {
+	switch outcome {
+	case SimulateSuccess, SimulateFailure, SimulateTimeout:
+	default:
+		return nil, fmt.Errorf("unknown payment outcome %q", outcome)
+	}
+	return pc.payment(ctx, "/api/payments/simulate/"+outcome, req)
+}
+
+// payment posts a payment request and decodes the payment, including declined ones
+func (pc *PaymentClient) payment(ctx context.Context, path string, req *models.PaymentRequest) (*models.PaymentResponse, error) {
+	call := request{
+		method:         "POST",
+		path:           path,
+		body:           req,
+		resultStatuses: []int{http.StatusBadRequest, http.StatusRequestTimeout},
+	}
+
+	var response models.PaymentResponse
+	if err := pc.do(ctx, call, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// AdminFlightView consolidates what an ops dashboard shows for one flight date. Sections the
+// caller's roles do not allow are omitted; sections that failed to load are listed in Errors.
+type AdminFlightView struct {
+	FlightID       int                       `json:"flight_id"`
+	Date           string                    `json:"date"`
+	Roles          []string                  `json:"roles"`
+	Flight         *Flight                   `json:"flight,omitempty"`
+	Availability   *SeatAvailabilityResponse `json:"availability,omitempty"`
+	RecentBookings []Booking                 `json:"recent_bookings,omitempty"`
+	PaymentStats   *FlightPaymentStats       `json:"payment_stats,omitempty"`
+	Errors         map[string]string         `json:"errors,omitempty"` // Section name to error
+	GeneratedAt    time.Time                 `json:"generated_at"`
+}
+
+// FlightPaymentStats summarizes payments and refunds on a flight date's bookings
+type FlightPaymentStats struct {
+	ConfirmedBookings int     `json:"confirmed_bookings"` // Including flown (completed) bookings
+	CancelledBookings int     `json:"cancelled_bookings"`
+	SeatsSold         int     `json:"seats_sold"`    // Seats on confirmed and flown bookings
+	GrossRevenue      float64 `json:"gross_revenue"` // Amount collected on every booking
+	Refunded          float64 `json:"refunded"`
+	CancellationFees  float64 `json:"cancellation_fees"`
+	NetRevenue        float64 `json:"net_revenue"`    // Gross revenue minus refunds
+	AgencyRevenue     float64 `json:"agency_revenue"` // Net revenue charged to agency credit
+}
+
+// AdminSession tells a dashboard who is signed in and which sections they may see
+type AdminSession struct {
+	User     string   `json:"user"`
+	Roles    []string `json:"roles"`
+	Sections []string `json:"sections"`
+}
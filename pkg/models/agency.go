@@ -0,0 +1,96 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Agency is a travel agent or corporate account that books on behalf of travellers
+// against a credit limit and is invoiced periodically
+type Agency struct {
+	ID          int       `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	KeyPrefix   string    `json:"key_prefix" db:"key_prefix"` // First characters of the key, for identification
+	CreditLimit float64   `json:"credit_limit" db:"credit_limit"`
+	Active      bool      `json:"active" db:"active"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// AgencyRequest represents a request to register an agency
+type AgencyRequest struct {
+	Name        string  `json:"name"`
+	CreditLimit float64 `json:"credit_limit"`
+}
+
+// Validate checks the agency request
+func (r *AgencyRequest) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if r.CreditLimit <= 0 {
+		return fmt.Errorf("credit_limit must be positive")
+	}
+	return nil
+}
+
+// AgencyCreatedResponse returns a new agency with its API key, which is only shown once
+type AgencyCreatedResponse struct {
+	Agency
+	APIKey string `json:"api_key"`
+}
+
+// AgencyAccount is an agency's credit position
+type AgencyAccount struct {
+	Agency
+	Unbilled    float64 `json:"unbilled"`    // Net charges not yet invoiced
+	Invoiced    float64 `json:"invoiced"`    // Open invoices awaiting payment
+	Outstanding float64 `json:"outstanding"` // Unbilled plus invoiced
+	Available   float64 `json:"available_credit"`
+}
+
+// Agency ledger entry types
+const (
+	LedgerEntryCharge = "charge" // Booking charged to credit
+	LedgerEntryCredit = "credit" // Refund or reversal returned to credit
+)
+
+// LedgerEntry is one movement on an agency's credit ledger. Charges are positive and
+// credits negative, so the sum is what the agency owes.
+type LedgerEntry struct {
+	ID        int       `json:"id" db:"id"`
+	AgencyID  int       `json:"agency_id" db:"agency_id"`
+	PaymentID string    `json:"payment_id" db:"payment_id"` // Matches bookings.payment_id
+	EntryType string    `json:"entry_type" db:"entry_type"`
+	Amount    float64   `json:"amount" db:"amount"`
+	Note      string    `json:"note,omitempty" db:"note"`
+	InvoiceID *int      `json:"invoice_id,omitempty" db:"invoice_id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// Agency invoice statuses
+const (
+	InvoiceStatusOpen = "open"
+	InvoiceStatusPaid = "paid"
+)
+
+// Invoice bills an agency for the ledger entries of a period
+type Invoice struct {
+	ID          int        `json:"id" db:"id"`
+	AgencyID    int        `json:"agency_id" db:"agency_id"`
+	PeriodStart time.Time  `json:"period_start" db:"period_start"`
+	PeriodEnd   time.Time  `json:"period_end" db:"period_end"`
+	Amount      float64    `json:"amount" db:"amount"`
+	Entries     int        `json:"entries" db:"entries"`
+	Status      string     `json:"status" db:"status"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	PaidAt      *time.Time `json:"paid_at,omitempty" db:"paid_at"`
+}
+
+// AgencyBookingsResponse lists bookings made by an agency
+type AgencyBookingsResponse struct {
+	AgencyID int       `json:"agency_id"`
+	Bookings []Booking `json:"bookings"`
+	Count    int       `json:"count"`
+	Limit    int       `json:"limit"`
+	Offset   int       `json:"offset"`
+}
@@ -0,0 +1,18 @@
+package models
+
+// Airport represents an airport with its location
+type Airport struct {
+	Code      string  `json:"code" db:"code"`
+	Name      string  `json:"name" db:"name"`
+	City      string  `json:"city" db:"city"`
+	Latitude  float64 `json:"latitude" db:"latitude"`
+	Longitude float64 `json:"longitude" db:"longitude"`
+	TimeZone  string  `json:"timezone" db:"timezone"` // IANA zone, e.g. "Asia/Kolkata"
+	Country   string  `json:"country" db:"country"`   // ISO 3166 code, e.g. "IN"; decides tax jurisdiction
+}
+
+// Airline represents an operating airline
+type Airline struct {
+	Code string `json:"code" db:"code"`
+	Name string `json:"name" db:"name"`
+}
@@ -0,0 +1,375 @@
+package models
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Booking represents a flight booking
+type Booking struct {
+	ID          int              `json:"id" db:"id"`
+	UserID      int              `json:"user_id" db:"user_id"`
+	FlightID    int              `json:"flight_id" db:"flight_id"`
+	Seats       int              `json:"seats" db:"seats"`
+	TotalAmount Money            `json:"total_amount" db:"total_amount"`
+	Status      string           `json:"status" db:"status"`
+	PaymentID   string           `json:"payment_id,omitempty" db:"payment_id"`
+	Date        string           `json:"date" db:"date"` // Flight date
+	FareCode    string           `json:"fare_code" db:"fare_code"`
+	Cabin       string           `json:"cabin,omitempty" db:"cabin"`         // Economy unless upgraded
+	Email       string           `json:"email,omitempty" db:"email"`         // Confirmation contact
+	Phone       string           `json:"phone,omitempty" db:"phone"`         // Confirmation contact
+	AgencyID    int              `json:"agency_id,omitempty" db:"agency_id"` // Set when booked on an agency's credit
+	CreatedAt   time.Time        `json:"created_at" db:"created_at"`
+	Flight      *Flight          `json:"flight,omitempty" db:"-"`
+	Segments    []BookingSegment `json:"segments,omitempty" db:"-"`
+	Taxes       []TaxLine        `json:"taxes,omitempty" db:"-"` // Included in TotalAmount
+}
+
+// BookingSegment is a snapshot of a booked flight's terms taken at confirmation, so the
+// booking still renders as sold after the flight is edited
+type BookingSegment struct {
+	BookingID     int       `json:"booking_id" db:"booking_id"`
+	SegmentIndex  int       `json:"segment_index" db:"segment_index"`
+	FlightID      int       `json:"flight_id" db:"flight_id"`
+	FlightNumber  string    `json:"flight_number" db:"flight_number"`
+	Source        string    `json:"source" db:"source"`
+	Destination   string    `json:"destination" db:"destination"`
+	DepartureTime time.Time `json:"departure_time" db:"departure_time"`
+	ArrivalTime   time.Time `json:"arrival_time" db:"arrival_time"`
+	Price         Money     `json:"price" db:"price"` // Per-seat fare at booking time
+}
+
+// NewBookingSegment snapshots a flight as a booking segment
+func NewBookingSegment(index int, flight *Flight) BookingSegment {
+	return BookingSegment{
+		SegmentIndex:  index,
+		FlightID:      flight.ID,
+		FlightNumber:  flight.FlightNumber,
+		Source:        flight.Source,
+		Destination:   flight.Destination,
+		DepartureTime: flight.DepartureTime,
+		ArrivalTime:   flight.ArrivalTime,
+		Price:         flight.Price,
+	}
+}
+
+// BookingRequest represents a booking request
+type BookingRequest struct {
+	UserID   int    `json:"user_id"`
+	FlightID int    `json:"flight_id"`
+	Seats    int    `json:"seats"`
+	Date     string `json:"date"`
+	FareCode string `json:"fare_code,omitempty"` // Cancellation rule set; defaults to "standard"
+	Email    string `json:"email,omitempty"`     // Where the confirmation is emailed
+	Phone    string `json:"phone,omitempty"`     // Where the confirmation is texted, in E.164 format
+	AgencyID int    `json:"-"`                   // Set from the agency API key; charged to credit instead of card
+	// Travellers, one per seat; required for duplicate passenger checks
+	Passengers []Passenger `json:"passengers,omitempty"`
+	// Client-chosen key that makes retries of the same booking return the original
+	// (also accepted as the Idempotency-Key header)
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+	// Ticket from a queued response, sent back to keep the booking's place in the flight's
+	// admission queue (also accepted as the X-Queue-Ticket header)
+	QueueTicket string `json:"queue_ticket,omitempty"`
+}
+
+// Offline payment methods of manual bookings
+const (
+	OfflinePaymentCash         = "cash"
+	OfflinePaymentBankTransfer = "bank_transfer"
+	OfflinePaymentCheque       = "cheque"
+)
+
+// MaxPaymentReferenceLength is the longest accepted offline payment reference
+const MaxPaymentReferenceLength = 36
+
+// ManualBookingRequest is an operator's booking of a sale paid outside the payment service,
+// e.g. over the phone or at a counter
+type ManualBookingRequest struct {
+	BookingRequest
+	PaymentMethod    string `json:"payment_method"`    // OfflinePaymentCash, OfflinePaymentBankTransfer, or OfflinePaymentCheque
+	PaymentReference string `json:"payment_reference"` // Receipt, transfer, or cheque number
+}
+
+// Validate checks the booking fields and the offline payment
+func (r *ManualBookingRequest) Validate() error {
+	if err := r.BookingRequest.Validate(); err != nil {
+		return err
+	}
+	switch r.PaymentMethod {
+	case OfflinePaymentCash, OfflinePaymentBankTransfer, OfflinePaymentCheque:
+	default:
+		return fmt.Errorf("payment_method must be %q, %q, or %q", OfflinePaymentCash, OfflinePaymentBankTransfer, OfflinePaymentCheque)
+	}
+	if r.PaymentReference == "" || len(r.PaymentReference) > MaxPaymentReferenceLength || strings.ContainsAny(r.PaymentReference, " \t\n:") {
+		return fmt.Errorf("payment_reference is required: up to %d characters without spaces or colons", MaxPaymentReferenceLength)
+	}
+	return nil
+}
+
+// PaymentID returns the payment ID a manual booking is stored with, "{method}:{reference}"
+func (r *ManualBookingRequest) PaymentID() string {
+	return r.PaymentMethod + ":" + r.PaymentReference
+}
+
+// MaxIdempotencyKeyLength is the longest accepted idempotency key
+const MaxIdempotencyKeyLength = 64
+
+// phonePattern matches E.164 phone numbers
+var phonePattern = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+// Validate checks the required booking fields and the optional contact fields
+func (r *BookingRequest) Validate() error {
+	if r.UserID <= 0 || r.FlightID <= 0 || r.Seats <= 0 || r.Date == "" {
+		return fmt.Errorf("invalid user ID, flight ID, seats, or date")
+	}
+	if err := r.ValidateIdempotencyKey(); err != nil {
+		return err
+	}
+	if err := r.ValidatePassengers(); err != nil {
+		return err
+	}
+	return r.ValidateContact()
+}
+
+// ValidatePassengers checks the optional passengers and normalizes their document numbers
+func (r *BookingRequest) ValidatePassengers() error {
+	return validatePassengers(r.Passengers, r.Seats)
+}
+
+// ValidateIdempotencyKey checks the optional idempotency key's length
+func (r *BookingRequest) ValidateIdempotencyKey() error {
+	if len(r.IdempotencyKey) > MaxIdempotencyKeyLength {
+		return fmt.Errorf("idempotency key must be at most %d characters", MaxIdempotencyKeyLength)
+	}
+	return nil
+}
+
+// ValidateContact checks the optional email and phone contact fields
+func (r *BookingRequest) ValidateContact() error {
+	return validateContact(r.Email, r.Phone)
+}
+
+// validateContact checks an optional email address and E.164 phone number
+func validateContact(email, phone string) error {
+	if email != "" {
+		addr, err := mail.ParseAddress(email)
+		if err != nil || addr.Address != email {
+			return fmt.Errorf("invalid email address")
+		}
+	}
+	if phone != "" && !phonePattern.MatchString(phone) {
+		return fmt.Errorf("invalid phone number, expected E.164 format such as +919876543210")
+	}
+	return nil
+}
+
+// TempBooking represents a temporary booking in cache
+type TempBooking struct {
+	HoldID      string    `json:"hold_id"`
+	UserID      int       `json:"user_id"`
+	FlightID    int       `json:"flight_id"`
+	Seats       int       `json:"seats"`
+	TotalAmount Money     `json:"total_amount"`
+	Date        string    `json:"date"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Extensions  int       `json:"extensions"`
+}
+
+// HoldExtensionRequest asks for more time to pay for a held booking
+type HoldExtensionRequest struct {
+	UserID int `json:"user_id"` // Must own the hold
+}
+
+// HoldExtensionResponse reports a hold's new expiry and how much longer it may be extended
+type HoldExtensionResponse struct {
+	HoldID              string    `json:"hold_id"`
+	PreviousExpiresAt   time.Time `json:"previous_expires_at"`
+	ExpiresAt           time.Time `json:"expires_at"`
+	Extensions          int       `json:"extensions"`
+	ExtensionsRemaining int       `json:"extensions_remaining"`
+	MaxExpiresAt        time.Time `json:"max_expires_at"` // Latest expiry any extension can reach
+}
+
+// BookingResponse represents the response for booking
+type BookingResponse struct {
+	BookingID   int    `json:"booking_id"`
+	Status      string `json:"status"`
+	TotalAmount Money  `json:"total_amount"`
+	PaymentID   string `json:"payment_id,omitempty"`
+	Message     string `json:"message,omitempty"`
+	Duplicate   bool   `json:"duplicate,omitempty"` // The booking already existed for this idempotency key
+	// Seat hold kept while a pending payment completes; extend it with POST /api/bookings/holds/{id}/extend
+	HoldID        string     `json:"hold_id,omitempty"`
+	HoldExpiresAt *time.Time `json:"hold_expires_at,omitempty"`
+	// Non-blocking problems, e.g. a passenger already booked on the flight in warn mode
+	Warnings []string `json:"warnings,omitempty"`
+	// Per-step durations, only returned to internal callers that ask for them
+	DebugTimings []StepTiming `json:"debug_timings,omitempty"`
+	// Set when the booking is queued behind others for the same flight date: resubmit the
+	// request with the ticket after RetryAfterSeconds
+	QueueTicket       string `json:"queue_ticket,omitempty"`
+	QueuePosition     int    `json:"queue_position,omitempty"`
+	RetryAfterSeconds int    `json:"retry_after_seconds,omitempty"`
+}
+
+// BatchBookingRequest creates several bookings in one call (e.g. an agent booking a group)
+type BatchBookingRequest struct {
+	Bookings []BookingRequest `json:"bookings"`
+	Atomic   bool             `json:"atomic"` // Roll back every booking unless all are confirmed
+}
+
+// BatchBookingResult is the outcome of one booking in a batch
+type BatchBookingResult struct {
+	Index       int    `json:"index"` // Position in the request
+	Status      string `json:"status"`
+	BookingID   int    `json:"booking_id,omitempty"`
+	TotalAmount *Money `json:"total_amount,omitempty"`
+	PaymentID   string `json:"payment_id,omitempty"`
+	ErrorCode   string `json:"error_code,omitempty"`
+	Message     string `json:"message,omitempty"`
+}
+
+// BatchBookingResponse reports per-booking results in request order
+type BatchBookingResponse struct {
+	Atomic     bool                 `json:"atomic"`
+	RolledBack bool                 `json:"rolled_back"`
+	Succeeded  int                  `json:"succeeded"` // Confirmed bookings
+	Pending    int                  `json:"pending"`   // Bookings awaiting payment
+	Failed     int                  `json:"failed"`    // Failed, skipped, or rolled back items
+	Results    []BatchBookingResult `json:"results"`
+}
+
+// Batch booking error codes
+const (
+	BatchErrorInvalidRequest     = "invalid_request"     // The item failed validation
+	BatchErrorDuplicate          = "duplicate"           // Same user and flight as an earlier item
+	BatchErrorDuplicatePassenger = "duplicate_passenger" // A passenger is already booked on the flight
+	BatchErrorBookingFailed      = "booking_failed"      // Validation, seats, or payment declined
+	BatchErrorPending            = "payment_pending"     // Payment did not settle (fails atomic batches)
+	BatchErrorInternal           = "internal_error"
+	BatchErrorAborted            = "aborted" // Not attempted because an atomic batch already failed
+)
+
+// StepTiming is how long one step of the booking flow took, relative to the request start
+type StepTiming struct {
+	Step       string  `json:"step"`
+	StartMs    float64 `json:"start_ms"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// ResendConfirmationResponse reports where a booking confirmation was resent
+type ResendConfirmationResponse struct {
+	BookingID int       `json:"booking_id"`
+	Channels  []string  `json:"channels"`
+	SentAt    time.Time `json:"sent_at"`
+}
+
+// ConfirmedSeatsResponse represents the confirmed seat total for a flight date
+type ConfirmedSeatsResponse struct {
+	FlightID       int    `json:"flight_id"`
+	Date           string `json:"date"`
+	ConfirmedSeats int    `json:"confirmed_seats"`
+}
+
+// BookingReceipt is the financial summary of a confirmed booking sent to accounting
+type BookingReceipt struct {
+	InvoiceNumber string    `json:"invoice_number"` // Stable per booking, so redeliveries can be deduplicated
+	BookingID     int       `json:"booking_id"`
+	UserID        int       `json:"user_id"`
+	AgencyID      int       `json:"agency_id,omitempty"` // Set when paid on an agency's credit
+	FlightID      int       `json:"flight_id"`
+	Date          string    `json:"date"`
+	Seats         int       `json:"seats"`
+	FareCode      string    `json:"fare_code"`
+	Currency      string    `json:"currency"`
+	BaseFare      Money     `json:"base_fare"`
+	Taxes         []TaxLine `json:"taxes"`
+	TaxTotal      Money     `json:"tax_total"`
+	TotalAmount   Money     `json:"total_amount"`
+	PaymentID     string    `json:"payment_id,omitempty"`
+	BookedAt      time.Time `json:"booked_at"`
+	ConfirmedAt   time.Time `json:"confirmed_at"`
+}
+
+// BookingStatus constants
+const (
+	BookingStatusPending   = "pending"
+	BookingStatusConfirmed = "confirmed"
+	BookingStatusFailed    = "failed"
+	BookingStatusCancelled = "cancelled"
+	BookingStatusCompleted = "completed" // The flight has arrived
+	// Batch-only result statuses
+	BookingStatusRolledBack = "rolled_back"
+	BookingStatusSkipped    = "skipped"
+	// Response-only status of a booking waiting in its flight's admission queue; never stored
+	BookingStatusQueued = "queued"
+)
+
+// IsValidStatus checks if the booking status is valid
+func (b *Booking) IsValidStatus() bool {
+	validStatuses := []string{
+		BookingStatusPending,
+		BookingStatusConfirmed,
+		BookingStatusFailed,
+		BookingStatusCancelled,
+		BookingStatusCompleted,
+	}
+
+	for _, status := range validStatuses {
+		if b.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// CanCancel checks if the booking can be cancelled
+func (b *Booking) CanCancel() bool {
+	return CanTransition(b.Status, BookingStatusCancelled)
+}
+
+// CanCancelAt checks if the booking can be cancelled at now, given its flight's departure
+func (b *Booking) CanCancelAt(departure, now time.Time) bool {
+	return b.CanCancel() && now.Before(departure)
+}
+
+// UserBookingsResponse lists a user's bookings
+type UserBookingsResponse struct {
+	UserID   int       `json:"user_id"`
+	Bookings []Booking `json:"bookings"`
+	Count    int       `json:"count"`
+	Limit    int       `json:"limit"`
+	Offset   int       `json:"offset"`
+}
+
+// BookingLinkRequest links a booking to another on the same flight date, e.g. a family
+// travelling together but paying separately
+type BookingLinkRequest struct {
+	BookingID int `json:"booking_id"`
+}
+
+// LinkedBooking summarizes one booking of a linked group
+type LinkedBooking struct {
+	BookingID int       `json:"booking_id"`
+	UserID    int       `json:"user_id"`
+	Seats     int       `json:"seats"`
+	Status    string    `json:"status"`
+	Cabin     string    `json:"cabin"`
+	LinkedAt  time.Time `json:"linked_at"`
+}
+
+// ItineraryResponse is the shared view of a booking and the bookings linked to it
+type ItineraryResponse struct {
+	GroupID     string           `json:"group_id,omitempty"` // Empty when the booking is not linked
+	FlightID    int              `json:"flight_id"`
+	Date        string           `json:"date"`
+	Segments    []BookingSegment `json:"segments,omitempty"`
+	Bookings    []LinkedBooking  `json:"bookings"`
+	ActiveSeats int              `json:"active_seats"` // Seats of pending and confirmed bookings
+}
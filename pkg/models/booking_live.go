@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// LiveRequest is a message sent by a client on the booking WebSocket
+type LiveRequest struct {
+	Action     string `json:"action"` // subscribe or unsubscribe
+	BookingIDs []int  `json:"booking_ids"`
+}
+
+// LiveRequest action constants
+const (
+	LiveActionSubscribe   = "subscribe"
+	LiveActionUnsubscribe = "unsubscribe"
+)
+
+// BookingLiveUpdate is a message pushed to clients on the booking WebSocket. A snapshot
+// carries the booking's current status when it is subscribed to; status and updated
+// messages follow each booking event.
+type BookingLiveUpdate struct {
+	Type       string    `json:"type"`
+	BookingID  int       `json:"booking_id,omitempty"`
+	Status     string    `json:"status,omitempty"`
+	From       string    `json:"from,omitempty"`
+	Seats      int       `json:"seats,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	OccurredAt time.Time `json:"occurred_at,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// BookingLiveUpdate type constants
+const (
+	LiveUpdateSnapshot = "snapshot"
+	LiveUpdateStatus   = "status"  // The booking moved from From to Status
+	LiveUpdateUpdated  = "updated" // Seats changed without a status change
+	LiveUpdateError    = "error"
+)
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidTransition is wrapped by every TransitionError
+var ErrInvalidTransition = errors.New("invalid booking status transition")
+
+// TransitionError reports a booking status change the state machine does not allow
+type TransitionError struct {
+	From string
+	To   string
+}
+
+// Error describes the rejected transition
+func (e *TransitionError) Error() string {
+	if e.From == "" {
+		return fmt.Sprintf("%v: bookings cannot be created as %s", ErrInvalidTransition, e.To)
+	}
+	return fmt.Sprintf("%v: %s -> %s", ErrInvalidTransition, e.From, e.To)
+}
+
+// Unwrap lets errors.Is match ErrInvalidTransition
+func (e *TransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
+
+// bookingTransitions lists the statuses each status may move to. The empty status is a
+// booking not yet stored. Failed, cancelled, and completed bookings are final.
+// Keep in sync with the bookings_status_transition trigger in init_bookings_db.sql.
+var bookingTransitions = map[string][]string{
+	"":                     {BookingStatusPending, BookingStatusConfirmed, BookingStatusFailed},
+	BookingStatusPending:   {BookingStatusConfirmed, BookingStatusFailed, BookingStatusCancelled},
+	BookingStatusConfirmed: {BookingStatusCancelled, BookingStatusCompleted},
+}
+
+// CanTransition reports whether a booking may move from one status to another
+func CanTransition(from, to string) bool {
+	for _, next := range bookingTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckTransition returns a TransitionError unless a booking may move from one status to another
+func CheckTransition(from, to string) error {
+	if !CanTransition(from, to) {
+		return &TransitionError{From: from, To: to}
+	}
+	return nil
+}
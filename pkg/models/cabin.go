@@ -0,0 +1,124 @@
+package models
+
+import "time"
+
+// Cabin constants, in ascending order of service. Economy is a flight's main seat counter;
+// the others are sold from cabin_inventory.
+const (
+	CabinEconomy        = "economy"
+	CabinPremiumEconomy = "premium_economy"
+	CabinBusiness       = "business"
+	CabinFirst          = "first"
+)
+
+// cabinRanks orders the known cabins; an upgrade must move to a higher rank
+var cabinRanks = map[string]int{
+	CabinEconomy:        0,
+	CabinPremiumEconomy: 1,
+	CabinBusiness:       2,
+	CabinFirst:          3,
+}
+
+// CabinRank returns a cabin's rank, and false for an unknown cabin. An empty cabin is economy.
+func CabinRank(cabin string) (int, bool) {
+	if cabin == "" {
+		cabin = CabinEconomy
+	}
+	rank, ok := cabinRanks[cabin]
+	return rank, ok
+}
+
+// CabinInventory is a cabin's capacity and per-seat fare on a flight date
+type CabinInventory struct {
+	Cabin          string    `json:"cabin"`
+	TotalSeats     int       `json:"total_seats"`
+	BookedSeats    int       `json:"booked_seats"`
+	AvailableSeats int       `json:"available_seats"`
+	Price          Money     `json:"price"` // Per seat, before taxes
+	UpdatedAt      time.Time `json:"updated_at,omitempty"`
+}
+
+// CabinInventoryRequest sets a premium cabin's capacity and fare on a flight date
+type CabinInventoryRequest struct {
+	TotalSeats int   `json:"total_seats"`
+	Price      Money `json:"price"`
+}
+
+// CabinsResponse lists the cabins sold on a flight date, economy first
+type CabinsResponse struct {
+	FlightID int              `json:"flight_id"`
+	Date     string           `json:"date"`
+	Cabins   []CabinInventory `json:"cabins"`
+}
+
+// UpgradeQuoteRequest asks for the price of moving a booking's seats to each higher cabin
+type UpgradeQuoteRequest struct {
+	FlightID  int    `json:"flight_id"`
+	Date      string `json:"date"`
+	Seats     int    `json:"seats"`
+	UserID    int    `json:"user_id"`
+	FromCabin string `json:"from_cabin"`
+}
+
+// UpgradeQuote prices an upgrade to one cabin
+type UpgradeQuote struct {
+	Cabin          string     `json:"cabin"`
+	AvailableSeats int        `json:"available_seats"`
+	Price          Money      `json:"price"`           // Fare difference for all seats, including Taxes
+	BaseFare       Money      `json:"base_fare"`       // Fare difference before taxes
+	Taxes          []TaxLine  `json:"taxes,omitempty"` // Taxes on the fare difference
+	Quote          *FareQuote `json:"quote,omitempty"` // Signed quote of Price for the payment
+}
+
+// UpgradeQuotesResponse lists the upgrades available from a cabin
+type UpgradeQuotesResponse struct {
+	FlightID  int            `json:"flight_id"`
+	Date      string         `json:"date"`
+	FromCabin string         `json:"from_cabin"`
+	Quotes    []UpgradeQuote `json:"quotes"`
+}
+
+// CabinMoveRequest moves seats between two cabins of a flight date in one step
+type CabinMoveRequest struct {
+	FlightID  int    `json:"flight_id"`
+	Date      string `json:"date"`
+	Seats     int    `json:"seats"`
+	FromCabin string `json:"from_cabin"`
+	ToCabin   string `json:"to_cabin"`
+}
+
+// UpgradeOffer is an upgrade a confirmed booking can accept until it expires
+type UpgradeOffer struct {
+	OfferID        string     `json:"offer_id"`
+	BookingID      int        `json:"booking_id"`
+	UserID         int        `json:"-"`
+	FlightID       int        `json:"-"`
+	Date           string     `json:"-"`
+	FromCabin      string     `json:"from_cabin"`
+	ToCabin        string     `json:"to_cabin"`
+	Seats          int        `json:"seats"`
+	AvailableSeats int        `json:"available_seats"`
+	Price          Money      `json:"price"` // Charged on acceptance, including Taxes
+	Taxes          []TaxLine  `json:"taxes,omitempty"`
+	Quote          *FareQuote `json:"-"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+}
+
+// UpgradeOffersResponse lists a booking's current upgrade offers
+type UpgradeOffersResponse struct {
+	BookingID int            `json:"booking_id"`
+	Cabin     string         `json:"cabin"`
+	Offers    []UpgradeOffer `json:"offers"`
+}
+
+// UpgradeResponse reports an accepted upgrade offer
+type UpgradeResponse struct {
+	BookingID   int       `json:"booking_id"`
+	OfferID     string    `json:"offer_id"`
+	FromCabin   string    `json:"from_cabin"`
+	ToCabin     string    `json:"to_cabin"`
+	AmountPaid  Money     `json:"amount_paid"`
+	TotalAmount Money     `json:"total_amount"` // Booking total after the upgrade
+	PaymentID   string    `json:"payment_id"`
+	UpgradedAt  time.Time `json:"upgraded_at"`
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// DefaultFareCode is the fare rule set applied when a booking does not name one
+const DefaultFareCode = "standard"
+
+// CancellationPolicy is the cancellation rule set for a fare
+type CancellationPolicy struct {
+	FareCode   string    `json:"fare_code"`
+	Name       string    `json:"name"`
+	Refundable bool      `json:"refundable"`
+	Tiers      []FeeTier `json:"tiers"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// FeeTier is the cancellation fee charged when cancelling at least MinHoursBeforeDeparture
+// hours before departure. The fee is FeePercent of the booking amount plus FlatFee.
+type FeeTier struct {
+	MinHoursBeforeDeparture float64 `json:"min_hours_before_departure"`
+	FeePercent              float64 `json:"fee_percent"`
+	FlatFee                 float64 `json:"flat_fee"`
+}
+
+// CancellationQuote is the outcome of evaluating a policy for a booking
+type CancellationQuote struct {
+	FareCode         string  `json:"fare_code"`
+	HoursToDeparture float64 `json:"hours_to_departure"`
+	CancellationFee  Money   `json:"cancellation_fee"`
+	RefundAmount     Money   `json:"refund_amount"`
+}
+
+// CancellationResponse represents the response for a booking cancellation
+type CancellationResponse struct {
+	Message        string    `json:"message"`
+	BookingID      int       `json:"booking_id"`
+	Status         string    `json:"status"` // Remains confirmed after a partial cancellation
+	SeatsCancelled int       `json:"seats_cancelled"`
+	RemainingSeats int       `json:"remaining_seats"`
+	CancelledAt    time.Time `json:"cancelled_at"`
+	// Still-active bookings linked to this one, so the client can offer to cancel them too
+	LinkedBookings []int `json:"linked_bookings,omitempty"`
+	CancellationQuote
+}
+
+// CancelBookingRequest optionally limits a cancellation to some of the booked seats
+type CancelBookingRequest struct {
+	Seats int `json:"seats,omitempty"` // 0 cancels the whole booking
+}
+
+// Validate checks that a policy is well formed
+func (p *CancellationPolicy) Validate() error {
+	if p.FareCode == "" || p.Name == "" {
+		return fmt.Errorf("fare_code and name are required")
+	}
+	if p.Refundable && len(p.Tiers) == 0 {
+		return fmt.Errorf("refundable policies need at least one fee tier")
+	}
+	for _, tier := range p.Tiers {
+		if tier.MinHoursBeforeDeparture < 0 || tier.FeePercent < 0 || tier.FeePercent > 100 || tier.FlatFee < 0 {
+			return fmt.Errorf("invalid fee tier: hours and fees must be non-negative and fee_percent at most 100")
+		}
+	}
+	return nil
+}
+
+// Evaluate computes the fee and refund for cancelling a booking of the given amount with
+// hoursToDeparture left. Non-refundable fares, and cancellations closer to departure than
+// every tier allows, forfeit the whole amount.
+func (p *CancellationPolicy) Evaluate(amount Money, hoursToDeparture float64) CancellationQuote {
+	quote := CancellationQuote{
+		FareCode:         p.FareCode,
+		HoursToDeparture: math.Round(hoursToDeparture*10) / 10,
+		CancellationFee:  amount,
+	}
+	if !p.Refundable {
+		return quote
+	}
+
+	// Use the tier with the largest threshold the cancellation still meets
+	tiers := append([]FeeTier(nil), p.Tiers...)
+	sort.Slice(tiers, func(i, j int) bool {
+		return tiers[i].MinHoursBeforeDeparture > tiers[j].MinHoursBeforeDeparture
+	})
+	for _, tier := range tiers {
+		if hoursToDeparture >= tier.MinHoursBeforeDeparture {
+			fee := amount.Scale(tier.FeePercent / 100).Add(MoneyFromFloat(tier.FlatFee))
+			if fee.Minor > amount.Minor {
+				fee = amount
+			}
+			quote.CancellationFee = fee
+			break
+		}
+	}
+
+	quote.RefundAmount = amount.Sub(quote.CancellationFee)
+	return quote
+}
@@ -0,0 +1,21 @@
+// Package models holds the request, response, and domain types shared by the flight,
+// booking, and payment services. They are the wire format of the services' JSON APIs, so
+// Go programs can build requests and decode responses without redeclaring them.
+//
+// Within API version 2 (see Version), fields and types are only added: existing JSON field
+// names, constants, and helper signatures do not change. Fields marked internal in their
+// comments (such as debug timings) may be empty for external callers. Version 2 changed
+// prices and amounts from float64 to Money; their JSON is unchanged.
+//
+// Searching for flights and booking the cheapest result:
+//
+//	search := models.SearchRequest{Source: "DEL", Destination: "BOM", Date: "2024-02-15", Seats: 2}
+//	// GET /api/flights/search with the request as query parameters → models.SearchResponse
+//	booking := models.BookingRequest{UserID: 1, FlightID: resp.Paths[0].Flights[0].ID, Seats: 2, Date: search.Date}
+//	if err := booking.Validate(); err != nil {
+//		return err
+//	}
+package models
+
+// Version is the version of the exported API surface of pkg/models and pkg/client
+const Version = "2.0.0"
@@ -0,0 +1,18 @@
+package models
+
+// ErrorResponse is the standard JSON error envelope returned by shared middleware
+type ErrorResponse struct {
+	Error  string `json:"error"`
+	Code   string `json:"code"`
+	Status int    `json:"status"`
+}
+
+// Error code constants
+const (
+	ErrorCodeTimeout    = "timeout"
+	ErrorCodeOverloaded = "overloaded"
+	// A booking's passenger already holds a booking on the flight date (409)
+	ErrorCodeDuplicatePassenger = "duplicate_passenger"
+	// A failure injected by the chaos middleware in a test environment
+	ErrorCodeInjectedFault = "injected_fault"
+)
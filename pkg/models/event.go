@@ -0,0 +1,88 @@
+package models
+
+import "time"
+
+// Flight domain event types
+const (
+	EventFlightCreated   = "flight.created"
+	EventFlightUpdated   = "flight.updated"
+	EventFlightCancelled = "flight.cancelled"
+	EventSeatsReserved   = "seats.reserved"
+	EventSeatsReleased   = "seats.released"
+)
+
+// Booking domain event types
+const (
+	EventBookingStatusChanged = "booking.status_changed"
+	EventBookingUpdated       = "booking.updated" // Seats or amounts changed without a status change
+)
+
+// Payment domain event types
+const (
+	EventPaymentVoided = "payment.voided" // Payload is the voided PaymentAuthorization
+)
+
+// FlightEvent is the payload of flight lifecycle events
+type FlightEvent struct {
+	FlightID      int       `json:"flight_id"`
+	FlightNumber  string    `json:"flight_number"`
+	Source        string    `json:"source"`
+	Destination   string    `json:"destination"`
+	DepartureTime time.Time `json:"departure_time"`
+	ArrivalTime   time.Time `json:"arrival_time"`
+	TotalSeats    int       `json:"total_seats"`
+	Price         Money     `json:"price"`
+	Status        string    `json:"status"`
+	ScheduleID    *int      `json:"schedule_id,omitempty"`
+	Reason        string    `json:"reason,omitempty"`
+}
+
+// SeatsEvent is the payload of seat reservation and release events
+type SeatsEvent struct {
+	FlightID  int    `json:"flight_id"`
+	Date      string `json:"date"`
+	Seats     int    `json:"seats"`
+	Available int    `json:"available"`
+}
+
+// BookingStatusEvent is the payload of booking events. From is empty when the booking was
+// created, and equals To on updates that keep the status.
+type BookingStatusEvent struct {
+	BookingID int    `json:"booking_id"`
+	UserID    int    `json:"user_id"`
+	FlightID  int    `json:"flight_id"`
+	Date      string `json:"date"`
+	Seats     int    `json:"seats"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// DeadLetter is an event a consumer group gave up on, kept for inspection and replay
+type DeadLetter struct {
+	ID        string    `json:"id"`
+	Stream    string    `json:"stream"`
+	Group     string    `json:"group"`
+	MessageID string    `json:"message_id"`           // Stream entry the event was read from
+	EventID   string    `json:"event_id,omitempty"`   // Empty when the entry could not be decoded
+	EventType string    `json:"event_type,omitempty"` // Empty when the entry could not be decoded
+	Event     string    `json:"event"`                // The entry as published, replayed verbatim
+	Error     string    `json:"error"`                // Last handler error
+	Attempts  int       `json:"attempts"`
+	FailedAt  time.Time `json:"failed_at"`
+}
+
+// DeadLettersResponse lists dead-lettered events, newest first
+type DeadLettersResponse struct {
+	DeadLetters []DeadLetter `json:"dead_letters"`
+	Count       int          `json:"count"`
+	Total       int64        `json:"total"` // Dead letters stored, across all streams
+}
+
+// DeadLetterReplayResponse reports a dead letter re-published to its stream
+type DeadLetterReplayResponse struct {
+	ID        string `json:"id"`
+	Stream    string `json:"stream"`
+	Group     string `json:"group"`      // Only this consumer group receives the replay
+	MessageID string `json:"message_id"` // New stream entry of the replayed event
+}
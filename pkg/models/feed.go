@@ -0,0 +1,66 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// FeedFlight is one flight date published by an external schedule/availability feed (GDS)
+type FeedFlight struct {
+	FlightNumber  string    `json:"flight_number"`
+	Date          string    `json:"date"` // Operating date, "2006-01-02"; with the flight number it identifies the record
+	Source        string    `json:"source"`
+	Destination   string    `json:"destination"`
+	DepartureTime time.Time `json:"departure_time"` // Airport-local; any UTC offset is ignored
+	ArrivalTime   time.Time `json:"arrival_time"`   // Airport-local; any UTC offset is ignored
+	TotalSeats    int       `json:"total_seats"`
+	Price         Money     `json:"price"`
+	Status        string    `json:"status,omitempty"` // FlightStatusScheduled (default) or FlightStatusCancelled
+}
+
+// Key returns the record's identity within its feed
+func (f *FeedFlight) Key() string {
+	return f.FlightNumber + "/" + f.Date
+}
+
+// Validate checks that the feed record is well formed
+func (f *FeedFlight) Validate() error {
+	if f.FlightNumber == "" || f.Source == "" || f.Destination == "" {
+		return fmt.Errorf("flight_number, source, and destination are required")
+	}
+	if f.Source == f.Destination {
+		return fmt.Errorf("source and destination must differ")
+	}
+	if _, err := time.Parse("2006-01-02", f.Date); err != nil {
+		return fmt.Errorf("invalid date: %w", err)
+	}
+	if !f.ArrivalTime.After(f.DepartureTime) {
+		return fmt.Errorf("arrival_time must be after departure_time")
+	}
+	if f.TotalSeats <= 0 || !f.Price.IsPositive() {
+		return fmt.Errorf("total_seats and price must be positive")
+	}
+	if f.Status != "" && f.Status != FlightStatusScheduled && f.Status != FlightStatusCancelled {
+		return fmt.Errorf("status must be %s or %s", FlightStatusScheduled, FlightStatusCancelled)
+	}
+	return nil
+}
+
+// FeedSyncError reports a feed record that could not be applied
+type FeedSyncError struct {
+	Key   string `json:"key"`
+	Error string `json:"error"`
+}
+
+// FeedSyncResponse summarizes one feed sync run
+type FeedSyncResponse struct {
+	Source      string          `json:"source"`
+	Records     int             `json:"records"`
+	Created     int             `json:"created"`
+	Updated     int             `json:"updated"`
+	Cancelled   int             `json:"cancelled"`
+	Unchanged   int             `json:"unchanged"`
+	Failed      []FeedSyncError `json:"failed"`
+	TriggeredBy string          `json:"triggered_by"`
+	SyncedAt    time.Time       `json:"synced_at"`
+}
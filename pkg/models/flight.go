@@ -0,0 +1,440 @@
+package models
+
+import (
+	"time"
+)
+
+// Flight represents a single flight
+type Flight struct {
+	ID            int       `json:"id" db:"id"`
+	FlightNumber  string    `json:"flight_number" db:"flight_number"`
+	Source        string    `json:"source" db:"source"`
+	Destination   string    `json:"destination" db:"destination"`
+	DepartureTime time.Time `json:"departure_time" db:"departure_time"`
+	ArrivalTime   time.Time `json:"arrival_time" db:"arrival_time"`
+	TotalSeats    int       `json:"total_seats" db:"total_seats"`
+	BookedSeats   int       `json:"booked_seats" db:"booked_seats"`
+	Price         Money     `json:"price" db:"price"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	Status        string    `json:"status,omitempty" db:"status"`
+	Airline       *Airline  `json:"airline,omitempty" db:"-"`
+	// Display fields in each airport's local time, set by Localize
+	DepartureLocal  string `json:"departure_local,omitempty" db:"-"`
+	ArrivalLocal    string `json:"arrival_local,omitempty" db:"-"`
+	DurationMinutes int64  `json:"duration_minutes,omitempty" db:"-"`
+}
+
+// FlightPath represents a complete flight path (can be direct or multi-stop)
+type FlightPath struct {
+	Flights     []Flight `json:"flights"`
+	TotalPrice  Money    `json:"total_price"`
+	TotalTime   int64    `json:"total_time_minutes"` // in minutes
+	Stops       int      `json:"stops"`
+	Score       float64  `json:"score,omitempty"`        // 0-100, set by the "recommended" sort
+	AirportPair string   `json:"airport_pair,omitempty"` // e.g. "DEL-BOM"
+	Nearby      bool     `json:"nearby,omitempty"`       // true when the pair differs from the requested one
+}
+
+// SearchRequest represents a flight search request
+type SearchRequest struct {
+	Source         string           `json:"source"`
+	Destination    string           `json:"destination"`
+	Date           string           `json:"date"`
+	Seats          int              `json:"seats"`
+	SortBy         string           `json:"sort_by"` // "cheapest", "fastest", or "recommended"
+	Diversity      DiversityOptions `json:"diversity"`
+	Airlines       []string         `json:"airlines,omitempty"` // Only return flights operated by these airline codes
+	IncludeNearby  bool             `json:"include_nearby"`
+	NearbyRadiusKm float64          `json:"nearby_radius_km"`
+	UserID         int              `json:"user_id,omitempty"`   // Buckets the search into experiments
+	MaxStops       *int             `json:"max_stops,omitempty"` // Most connections per path; the service's configured depth when unset
+}
+
+// DiversityOptions limits how many returned paths may share a trait (0 means unlimited)
+type DiversityOptions struct {
+	MaxPerFirstLeg      int `json:"max_per_first_leg"`
+	MaxPerAirline       int `json:"max_per_airline"`
+	MaxPerDepartureHour int `json:"max_per_departure_hour"`
+}
+
+// SearchResponse represents the response for flight search
+type SearchResponse struct {
+	Paths        []FlightPath `json:"paths"`
+	Count        int          `json:"count"`
+	AirportPairs []string     `json:"airport_pairs,omitempty"` // Pairs searched when nearby expansion is on
+	// Experiment variants applied to this search, by experiment key
+	Experiments map[string]string `json:"experiments,omitempty"`
+}
+
+// SearchStreamLine is one line of a streamed (NDJSON) search: a line per path, direct
+// flights first, then a done line with the count, or an error line if the search failed
+type SearchStreamLine struct {
+	Type  string      `json:"type"`
+	Path  *FlightPath `json:"path,omitempty"`
+	Count int         `json:"count,omitempty"`
+	Error string      `json:"error,omitempty"`
+}
+
+// SearchStreamLine type constants
+const (
+	SearchStreamPath  = "path"
+	SearchStreamDone  = "done"
+	SearchStreamError = "error"
+)
+
+// FlightValidationRequest represents a flight validation request
+type FlightValidationRequest struct {
+	FlightID int    `json:"flight_id"`
+	Seats    int    `json:"seats"`
+	Date     string `json:"date"`
+	UserID   int    `json:"user_id,omitempty"` // Prices the booking in the user's experiment variant
+}
+
+// FlightValidationResponse represents the response for flight validation
+type FlightValidationResponse struct {
+	Valid     bool    `json:"valid"`
+	Message   string  `json:"message,omitempty"`
+	Price     Money   `json:"price"` // Total for all seats, including Taxes
+	Available int     `json:"available_seats,omitempty"`
+	Flight    *Flight `json:"flight,omitempty"` // Flight details at validation time, used for booking snapshots
+	// Experiment variants applied to the price, by experiment key
+	Experiments map[string]string `json:"experiments,omitempty"`
+	// Signed quote of Price, passed on to the payment
+	Quote *FareQuote `json:"quote,omitempty"`
+	// Single-use nonce authorizing the seat decrement for this booking
+	SeatNonce *SeatNonce `json:"seat_nonce,omitempty"`
+	// Price before taxes and the taxes on it, when tax rules apply to the route
+	BaseFare *Money    `json:"base_fare,omitempty"`
+	Taxes    []TaxLine `json:"taxes,omitempty"`
+}
+
+// SeatUpdateRequest represents a seat update request
+type SeatUpdateRequest struct {
+	FlightID int    `json:"flight_id"`
+	Seats    int    `json:"seats"`
+	Date     string `json:"date"`
+	UserID   int    `json:"user_id,omitempty"` // Tags the resulting event with the user's experiment variants
+	Reason   string `json:"reason,omitempty"`  // Recorded with the seat event, e.g. "booking_hold"
+	Cabin    string `json:"cabin,omitempty"`   // Releases a premium cabin's seats instead of economy's
+	// Single-use nonce issued by flight-service, required on every seat update
+	Nonce *SeatNonce `json:"nonce,omitempty"`
+}
+
+// SeatUpdateResponse confirms a seat decrement or increment
+type SeatUpdateResponse struct {
+	Message   string    `json:"message"`
+	FlightID  int       `json:"flight_id"`
+	Seats     int       `json:"seats"`
+	Date      string    `json:"date"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Nonce for giving the decremented seats back, e.g. when the booking then fails
+	ReleaseNonce *SeatNonce `json:"release_nonce,omitempty"`
+}
+
+// SeatNonce is flight-service's signed, single-use authorization of one seat decrement
+// (SeatEventReserve) or increment (SeatEventRelease) of at most Seats seats of one cabin on a
+// flight date, so a captured seat update cannot be replayed
+type SeatNonce struct {
+	ID        string    `json:"id"`
+	Operation string    `json:"operation"`
+	FlightID  int       `json:"flight_id"`
+	Date      string    `json:"date"`
+	Cabin     string    `json:"cabin"`
+	Seats     int       `json:"seats"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Signature string    `json:"signature"`
+}
+
+// SeatNonceResponse carries a release nonce
+type SeatNonceResponse struct {
+	Nonce *SeatNonce `json:"nonce"`
+}
+
+// BatchSeatRequest reserves seats on several flights at once (e.g. every leg of an itinerary).
+// Each reservation carries its own seat nonce.
+type BatchSeatRequest struct {
+	Reservations []SeatUpdateRequest `json:"reservations"`
+}
+
+// BatchSeatResponse reports the remaining seats for each reserved flight
+type BatchSeatResponse struct {
+	Reservations []SeatReservationResult `json:"reservations"`
+	ReservedAt   time.Time               `json:"reserved_at"`
+}
+
+// SeatReservationResult is the outcome of one reservation in a batch
+type SeatReservationResult struct {
+	FlightID     int        `json:"flight_id"`
+	Date         string     `json:"date"`
+	Seats        int        `json:"seats"`
+	Available    int        `json:"available_seats"`
+	ReleaseNonce *SeatNonce `json:"release_nonce,omitempty"` // Spent to give the seats back
+}
+
+// SeatAvailabilityResponse describes a flight's live seat counter and its provenance
+type SeatAvailabilityResponse struct {
+	FlightID           int        `json:"flight_id"`
+	Date               string     `json:"date"`
+	Available          int        `json:"available_seats"`
+	Source             string     `json:"source"`                // "cache" or "db"
+	TTLSeconds         *int64     `json:"ttl_seconds,omitempty"` // remaining lifetime of the cached counter
+	LastReconciledAt   *time.Time `json:"last_reconciled_at,omitempty"`
+	TotalSeats         int        `json:"total_seats"`
+	DatabaseAvailable  int        `json:"db_available_seats"`
+	Drift              int        `json:"drift"` // cache counter minus database value
+	InventoryUpdatedAt *time.Time `json:"inventory_updated_at,omitempty"`
+}
+
+// RouteDate is one route and date in a batch availability request
+type RouteDate struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Date        string `json:"date"`
+}
+
+// BatchAvailabilityRequest asks for availability on several routes and dates at once
+type BatchAvailabilityRequest struct {
+	Routes []RouteDate `json:"routes"`
+	Seats  int         `json:"seats,omitempty"` // Seats needed per itinerary; defaults to 1
+}
+
+// RouteAvailability summarizes bookable flights on one route and date
+type RouteAvailability struct {
+	RouteDate
+	Available      bool   `json:"available"`
+	FlightCount    int    `json:"flight_count"`
+	MaxSeats       int    `json:"max_available_seats"` // Most seats bookable on a single itinerary
+	LowestFare     *Money `json:"lowest_fare,omitempty"`
+	LowestFareTrip []int  `json:"lowest_fare_flight_ids,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// BatchAvailabilityResponse returns availability for each requested route in request order
+type BatchAvailabilityResponse struct {
+	Routes      []RouteAvailability `json:"routes"`
+	Cached      bool                `json:"cached"` // true when served from the request-level cache
+	GeneratedAt time.Time           `json:"generated_at"`
+}
+
+// BatchSearchRequest searches one route on several dates, or several route and date pairs,
+// in one call. Exactly one of Dates (with Source and Destination) or Routes is set.
+type BatchSearchRequest struct {
+	Source      string      `json:"source,omitempty"`
+	Destination string      `json:"destination,omitempty"`
+	Dates       []string    `json:"dates,omitempty"`
+	Routes      []RouteDate `json:"routes,omitempty"`
+	Seats       int         `json:"seats"`
+	SortBy      string      `json:"sort_by,omitempty"` // "cheapest", "fastest", or "recommended"
+	Airlines    []string    `json:"airlines,omitempty"`
+	UserID      int         `json:"user_id,omitempty"`
+	MaxStops    *int        `json:"max_stops,omitempty"`
+}
+
+// BatchSearchResult is the search of one route and date in a batch
+type BatchSearchResult struct {
+	RouteDate
+	Paths []FlightPath `json:"paths"`
+	Count int          `json:"count"`
+	Error string       `json:"error,omitempty"`
+}
+
+// BatchSearchResponse returns each search of a batch, keyed by date when one route was
+// searched on several dates, or by "{source}-{destination}:{date}" for routes
+type BatchSearchResponse struct {
+	Results map[string]*BatchSearchResult `json:"results"`
+	// Experiment variants applied to every search, by experiment key
+	Experiments map[string]string `json:"experiments,omitempty"`
+}
+
+// Seat counter source constants
+const (
+	SeatSourceCache    = "cache"
+	SeatSourceDatabase = "db"
+)
+
+// SeatOccupancyEvent represents a change in booked seats published by the booking service
+type SeatOccupancyEvent struct {
+	EventID    string    `json:"event_id,omitempty"` // Distinguishes repeated events of one reason for a booking
+	FlightID   int       `json:"flight_id"`
+	Date       string    `json:"date"`
+	BookingID  int       `json:"booking_id"`
+	Delta      int       `json:"delta"`  // positive when seats are booked, negative for corrections
+	Reason     string    `json:"reason"` // "booking_confirmed", "booking_cancelled"
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// LoadFactorResponse represents the occupancy of a flight on a given date
+type LoadFactorResponse struct {
+	FlightID    int     `json:"flight_id"`
+	Date        string  `json:"date"`
+	BookedSeats int     `json:"booked_seats"`
+	TotalSeats  int     `json:"total_seats"`
+	LoadFactor  float64 `json:"load_factor"`
+}
+
+// SeatRecalculationResponse represents the result of recomputing a seat counter
+type SeatRecalculationResponse struct {
+	FlightID          int    `json:"flight_id"`
+	Date              string `json:"date"`
+	PreviousAvailable *int   `json:"previous_available"` // nil when no counter was cached
+	Available         int    `json:"available_seats"`
+	ConfirmedSeats    int    `json:"confirmed_seats"`
+	Delta             int    `json:"delta"`
+	TriggeredBy       string `json:"triggered_by"`
+}
+
+// SeatEvent kind constants
+const (
+	SeatEventReserve = "reserve"
+	SeatEventRelease = "release"
+	SeatEventAdjust  = "adjust"
+)
+
+// SeatEvent reason constants
+const (
+	SeatReasonUnspecified      = "unspecified"
+	SeatReasonBookingHold      = "booking_hold"
+	SeatReasonHoldReverted     = "hold_reverted"
+	SeatReasonBookingCancelled = "booking_cancelled"
+	SeatReasonBatchRollback    = "batch_rollback"
+	SeatReasonOpeningBalance   = "opening_balance" // Counter a flight date had before events were recorded
+	SeatReasonRecalculation    = "recalculation"
+	SeatReasonCabinUpgrade     = "cabin_upgrade" // Economy seats moved to a premium cabin
+)
+
+// SeatEvent is one append-only change to a flight date's available seats
+type SeatEvent struct {
+	ID        int64     `json:"id"`
+	Kind      string    `json:"kind"`  // "reserve", "release", "adjust"
+	Seats     int       `json:"seats"` // Signed change to available seats
+	Reason    string    `json:"reason"`
+	Actor     string    `json:"actor,omitempty"`
+	Available int       `json:"available_after"` // Projected available seats after this event
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SeatEventsResponse lists a flight date's seat events with the counter they project
+type SeatEventsResponse struct {
+	FlightID  int         `json:"flight_id"`
+	Date      string      `json:"date"`
+	BaseSeats int         `json:"base_seats"` // Capacity the events apply to
+	Events    []SeatEvent `json:"events"`
+	Available int         `json:"available_seats"`
+}
+
+// SeatRebuildResult is one seat counter replayed from its events
+type SeatRebuildResult struct {
+	FlightID          int    `json:"flight_id"`
+	Date              string `json:"date"`
+	Events            int    `json:"events"`
+	PreviousAvailable *int   `json:"previous_available"` // nil when no counter was cached
+	Available         int    `json:"available_seats"`
+	Delta             int    `json:"delta"`
+}
+
+// SeatRebuildResponse reports the seat counters rebuilt from seat events
+type SeatRebuildResponse struct {
+	Counters    []SeatRebuildResult `json:"counters"`
+	TriggeredBy string              `json:"triggered_by"`
+	RebuiltAt   time.Time           `json:"rebuilt_at"`
+}
+
+// SeatOccupancyEvent reason constants
+const (
+	OccupancyReasonBookingConfirmed = "booking_confirmed"
+	OccupancyReasonBookingCancelled = "booking_cancelled"
+	OccupancyReasonSeatsCancelled   = "seats_cancelled"
+)
+
+// AvailableSeats returns the number of available seats
+func (f *Flight) AvailableSeats() int {
+	return f.TotalSeats - f.BookedSeats
+}
+
+// localTimeLayout is the display format for local departure/arrival times
+const localTimeLayout = "2006-01-02 15:04 MST"
+
+// Localize anchors the stored wall-clock times (recorded in each airport's local time) to the
+// source and destination time zones, so durations are computed between real instants
+func (f *Flight) Localize(departureZone, arrivalZone *time.Location) {
+	f.DepartureTime = inZone(f.DepartureTime, departureZone)
+	f.ArrivalTime = inZone(f.ArrivalTime, arrivalZone)
+	f.DepartureLocal = f.DepartureTime.Format(localTimeLayout)
+	f.ArrivalLocal = f.ArrivalTime.Format(localTimeLayout)
+	f.DurationMinutes = int64(f.ArrivalTime.UTC().Sub(f.DepartureTime.UTC()).Minutes())
+}
+
+// inZone reinterprets a wall-clock time as being in the given location
+func inZone(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
+
+// AirlineCode returns the operating airline's code, falling back to the designator
+// prefix of the flight number (e.g. "AI" for "AI101")
+func (f *Flight) AirlineCode() string {
+	if f.Airline != nil {
+		return f.Airline.Code
+	}
+	for i, r := range f.FlightNumber {
+		if r >= '0' && r <= '9' && i >= 2 {
+			return f.FlightNumber[:i]
+		}
+	}
+	return f.FlightNumber
+}
+
+// CanBook checks if the flight can be booked for the given number of seats
+func (f *Flight) CanBook(seats int) bool {
+	return f.AvailableSeats() >= seats
+}
+
+// CalculateTotalTime calculates total travel time in minutes
+func (fp *FlightPath) CalculateTotalTime() {
+	if len(fp.Flights) == 0 {
+		fp.TotalTime = 0
+		return
+	}
+
+	firstFlight := fp.Flights[0]
+	lastFlight := fp.Flights[len(fp.Flights)-1]
+
+	// Compare in UTC so legs across time zones yield the real elapsed time
+	duration := lastFlight.ArrivalTime.UTC().Sub(firstFlight.DepartureTime.UTC())
+	fp.TotalTime = int64(duration.Minutes())
+}
+
+// CalculateTotalPrice calculates total price for all flights
+func (fp *FlightPath) CalculateTotalPrice() {
+	fp.TotalPrice = NewMoney(0)
+	for _, flight := range fp.Flights {
+		fp.TotalPrice = fp.TotalPrice.Add(flight.Price)
+	}
+}
+
+// CalculateStops calculates number of stops
+func (fp *FlightPath) CalculateStops() {
+	if len(fp.Flights) <= 1 {
+		fp.Stops = 0
+	} else {
+		fp.Stops = len(fp.Flights) - 1
+	}
+}
+
+// Flight status constants
+const (
+	FlightStatusScheduled = "scheduled"
+	FlightStatusCancelled = "cancelled"
+)
+
+// FlightUpdateRequest represents an admin change to a flight; nil fields are left unchanged
+type FlightUpdateRequest struct {
+	DepartureTime *time.Time `json:"departure_time,omitempty"`
+	ArrivalTime   *time.Time `json:"arrival_time,omitempty"`
+	TotalSeats    *int       `json:"total_seats,omitempty"`
+	Price         *Money     `json:"price,omitempty"`
+}
+
+// FlightCancelRequest represents an admin flight cancellation
+type FlightCancelRequest struct {
+	Reason string `json:"reason"`
+}
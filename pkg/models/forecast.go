@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// FlightForecast projects a flight date's sales from its recent booking velocity
+type FlightForecast struct {
+	FlightID         int       `json:"flight_id"`
+	Date             string    `json:"date"`
+	DepartureTime    time.Time `json:"departure_time"`
+	HoursToDeparture float64   `json:"hours_to_departure"`
+	TotalSeats       int       `json:"total_seats"`
+	BookedSeats      int       `json:"booked_seats"` // Includes seats held by in-flight bookings
+	AvailableSeats   int       `json:"available_seats"`
+	LoadFactor       float64   `json:"load_factor"`
+	// Net seats sold per hour, weighting recent events more (negative when releases dominate)
+	VelocitySeatsPerHour float64 `json:"velocity_seats_per_hour"`
+	NetSeatsLast24h      int     `json:"net_seats_last_24h"`
+	EventsAnalyzed       int     `json:"events_analyzed"`
+	WindowHours          float64 `json:"window_hours"` // Event history the velocity is based on
+	// Projections, assuming the current velocity holds until departure
+	SoldOut                  bool       `json:"sold_out"`
+	PredictedSellOutAt       *time.Time `json:"predicted_sell_out_at,omitempty"` // Omitted when not expected before departure
+	PredictedFinalLoadFactor float64    `json:"predicted_final_load_factor"`
+	PredictedUnsoldSeats     int        `json:"predicted_unsold_seats"`
+	LowConfidence            bool       `json:"low_confidence"` // Too few events for a reliable velocity
+	GeneratedAt              time.Time  `json:"generated_at"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// FunnelReport is the booking funnel for one route on one UTC day. Counts are distinct
+// sessions reaching each stage (approximate, from HyperLogLogs).
+type FunnelReport struct {
+	Day             string    `json:"day" db:"day"`
+	Route           string    `json:"route" db:"route"` // e.g. "DEL-BOM"
+	Searches        int64     `json:"searches" db:"searches"`
+	Selections      int64     `json:"selections" db:"selections"`
+	BookingAttempts int64     `json:"booking_attempts" db:"booking_attempts"`
+	Payments        int64     `json:"payments" db:"payments"`
+	Confirmations   int64     `json:"confirmations" db:"confirmations"`
+	ConversionRate  float64   `json:"conversion_rate"` // Confirmations per search session
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// SetConversionRate computes the search-to-confirmation rate
+func (r *FunnelReport) SetConversionRate() {
+	r.ConversionRate = 0
+	if r.Searches > 0 {
+		r.ConversionRate = float64(r.Confirmations) / float64(r.Searches)
+	}
+}
+
+// FunnelReportResponse lists funnel reports per route and day, with per-day totals
+type FunnelReportResponse struct {
+	From    string         `json:"from"`
+	To      string         `json:"to"`
+	Route   string         `json:"route,omitempty"`
+	Reports []FunnelReport `json:"reports"`
+	Daily   []FunnelReport `json:"daily"` // Summed across routes; Route is empty
+}
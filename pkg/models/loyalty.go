@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// LoyaltyAccrual is the points earned by one flown booking
+type LoyaltyAccrual struct {
+	BookingID int       `json:"booking_id"`
+	Points    int       `json:"points"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LoyaltyBalance is a user's points total with their most recent accruals
+type LoyaltyBalance struct {
+	UserID   int              `json:"user_id"`
+	Points   int              `json:"points"`
+	Accruals []LoyaltyAccrual `json:"accruals"`
+}
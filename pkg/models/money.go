@@ -0,0 +1,250 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the ISO 4217 currency every fare and payment is in
+const DefaultCurrency = "INR"
+
+// minorPerMajor is the number of minor units (paise) in one major unit (rupee)
+const minorPerMajor = 100
+
+// Money is an amount in integer minor units of a currency, so fare sums, refunds, and
+// ledger entries never accumulate floating point error. Amounts are exchanged as decimal
+// JSON numbers and NUMERIC columns, so clients and tables that predate it still work.
+type Money struct {
+	Minor    int64  // Amount in minor units, e.g. paise
+	Currency string // ISO 4217 code, DefaultCurrency when empty
+}
+
+// NewMoney creates an amount from minor units in the default currency
+func NewMoney(minor int64) Money {
+	return Money{Minor: minor, Currency: DefaultCurrency}
+}
+
+// MoneyFromFloat converts a decimal amount to money, rounded to the nearest minor unit.
+// The amount must already be known to fit; use ParseMoneyFloat for input.
+func MoneyFromFloat(amount float64) Money {
+	return NewMoney(int64(math.Round(amount * minorPerMajor)))
+}
+
+// ParseMoneyFloat converts a decimal amount from input to money, rounded to the nearest
+// minor unit. Negative amounts and amounts beyond the int64 range of minor units are
+// rejected rather than wrapped around.
+func ParseMoneyFloat(amount float64) (Money, error) {
+	minor := math.Round(amount * minorPerMajor)
+	if math.IsNaN(minor) || minor < 0 || minor >= math.MaxInt64 {
+		return Money{}, fmt.Errorf("invalid amount %v", amount)
+	}
+	return NewMoney(int64(minor)), nil
+}
+
+// ParseMoney parses a decimal amount such as "8500", "8500.5", or "-12.75" exactly
+func ParseMoney(s string) (Money, error) {
+	value := strings.TrimSpace(s)
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "-"), "+")
+
+	whole, fraction, _ := strings.Cut(value, ".")
+	fraction = strings.TrimRight(fraction, "0")
+	if whole == "" || len(fraction) > 2 || !isDigits(whole) || !isDigits(fraction) {
+		return Money{}, fmt.Errorf("invalid amount %q", s)
+	}
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > math.MaxInt64/minorPerMajor-1 {
+		return Money{}, fmt.Errorf("invalid amount %q", s)
+	}
+	cents, _ := strconv.ParseInt((fraction + "00")[:2], 10, 64)
+
+	minor := units*minorPerMajor + cents
+	if negative {
+		minor = -minor
+	}
+	return NewMoney(minor), nil
+}
+
+// isDigits reports whether s holds only ASCII digits
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// currency returns m's currency, or other's when m has none (e.g. a zero value sum)
+func (m Money) currency(other Money) string {
+	switch {
+	case m.Currency != "":
+		return m.Currency
+	case other.Currency != "":
+		return other.Currency
+	default:
+		return DefaultCurrency
+	}
+}
+
+// Add returns m plus other; both must be in the same currency
+func (m Money) Add(other Money) Money {
+	return Money{Minor: m.Minor + other.Minor, Currency: m.currency(other)}
+}
+
+// Sub returns m minus other; both must be in the same currency
+func (m Money) Sub(other Money) Money {
+	return Money{Minor: m.Minor - other.Minor, Currency: m.currency(other)}
+}
+
+// Mul returns m times a count, e.g. a per-seat fare times seats
+func (m Money) Mul(n int) Money {
+	return Money{Minor: m.Minor * int64(n), Currency: m.currency(Money{})}
+}
+
+// Scale returns m times a factor, rounded to the nearest minor unit
+func (m Money) Scale(factor float64) Money {
+	return Money{Minor: int64(math.Round(float64(m.Minor) * factor)), Currency: m.currency(Money{})}
+}
+
+// Split returns the share of m for part out of whole (e.g. seats of a booking), rounded
+// to the nearest minor unit
+func (m Money) Split(part, whole int) Money {
+	if whole <= 0 {
+		return Money{Currency: m.currency(Money{})}
+	}
+	return m.Scale(float64(part) / float64(whole))
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m.Minor == 0
+}
+
+// IsPositive reports whether the amount is above zero
+func (m Money) IsPositive() bool {
+	return m.Minor > 0
+}
+
+// Float64 returns the amount in major units, for scoring and display only
+func (m Money) Float64() float64 {
+	return float64(m.Minor) / minorPerMajor
+}
+
+// String formats the amount with two decimals, e.g. "8500.00"
+func (m Money) String() string {
+	minor := m.Minor
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, minor/minorPerMajor, minor%minorPerMajor)
+}
+
+// MarshalJSON encodes the amount as a decimal number, as float amounts were
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// moneyObject is the explicit JSON form of an amount
+type moneyObject struct {
+	Minor    *int64 `json:"minor"`
+	Currency string `json:"currency"`
+}
+
+// UnmarshalJSON accepts a decimal number (8500.5), a decimal string ("8500.50"), or
+// {"minor": 850050, "currency": "INR"}. Numbers with float noise beyond two decimals
+// (e.g. 0.30000000000000004) are rounded to the nearest minor unit. Negative amounts, and
+// amounts too large for int64 minor units, are rejected.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		return nil
+	case bytes.HasPrefix(data, []byte("{")):
+		var obj moneyObject
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+		if obj.Minor == nil {
+			return fmt.Errorf("amount object is missing minor")
+		}
+		if *obj.Minor < 0 {
+			return fmt.Errorf("invalid amount %d: must not be negative", *obj.Minor)
+		}
+		*m = Money{Minor: *obj.Minor, Currency: strings.ToUpper(obj.Currency)}
+		if m.Currency == "" {
+			m.Currency = DefaultCurrency
+		}
+		return nil
+	case bytes.HasPrefix(data, []byte(`"`)):
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		parsed, err := ParseMoney(s)
+		if err != nil {
+			return err
+		}
+		if parsed.Minor < 0 {
+			return fmt.Errorf("invalid amount %q: must not be negative", s)
+		}
+		*m = parsed
+		return nil
+	}
+
+	if parsed, err := ParseMoney(string(data)); err == nil {
+		if parsed.Minor < 0 {
+			return fmt.Errorf("invalid amount %s: must not be negative", data)
+		}
+		*m = parsed
+		return nil
+	}
+	amount, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return fmt.Errorf("invalid amount %s", data)
+	}
+	parsed, err := ParseMoneyFloat(amount)
+	if err != nil {
+		return fmt.Errorf("invalid amount %s", data)
+	}
+	*m = parsed
+	return nil
+}
+
+// Scan reads a NUMERIC column exactly, through its decimal text
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		parsed, err := ParseMoney(string(v))
+		if err != nil {
+			return err
+		}
+		*m = parsed
+	case string:
+		parsed, err := ParseMoney(v)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+	case int64:
+		*m = NewMoney(v * minorPerMajor)
+	case float64:
+		*m = MoneyFromFloat(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+	return nil
+}
+
+// Value writes the amount as decimal text, so NUMERIC columns store it exactly
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Partner API scopes
+const (
+	PartnerScopeSearch       = "search"
+	PartnerScopeAvailability = "availability"
+)
+
+// PartnerScopes lists every scope a partner key can be granted
+var PartnerScopes = []string{PartnerScopeSearch, PartnerScopeAvailability}
+
+// Partner is an external aggregator with read-only API access
+type Partner struct {
+	ID                int       `json:"id" db:"id"`
+	Name              string    `json:"name" db:"name"`
+	KeyPrefix         string    `json:"key_prefix" db:"key_prefix"` // First characters of the key, for identification
+	Scopes            []string  `json:"scopes" db:"scopes"`
+	RequestsPerMinute int       `json:"requests_per_minute" db:"requests_per_minute"`
+	DailyQuota        int       `json:"daily_quota" db:"daily_quota"`
+	Active            bool      `json:"active" db:"active"`
+	CreatedAt         time.Time `json:"created_at" db:"created_at"`
+}
+
+// HasScope checks if the partner was granted a scope
+func (p *Partner) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// PartnerRequest represents a request to register a partner
+type PartnerRequest struct {
+	Name              string   `json:"name"`
+	Scopes            []string `json:"scopes"` // Defaults to all scopes
+	RequestsPerMinute int      `json:"requests_per_minute"`
+	DailyQuota        int      `json:"daily_quota"`
+}
+
+// Validate checks the partner request, filling in default scopes
+func (r *PartnerRequest) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if r.RequestsPerMinute <= 0 || r.DailyQuota <= 0 {
+		return fmt.Errorf("requests_per_minute and daily_quota must be positive")
+	}
+	if len(r.Scopes) == 0 {
+		r.Scopes = PartnerScopes
+	}
+	for _, scope := range r.Scopes {
+		if scope != PartnerScopeSearch && scope != PartnerScopeAvailability {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	return nil
+}
+
+// PartnerCreatedResponse returns a new partner with its API key, which is only shown once
+type PartnerCreatedResponse struct {
+	Partner
+	APIKey string `json:"api_key"`
+}
+
+// PartnerUsageDay is a partner's metered usage for one UTC day
+type PartnerUsageDay struct {
+	Date       string           `json:"date"`
+	Requests   int64            `json:"requests"`
+	Rejected   int64            `json:"rejected"` // Requests refused by rate limit or quota
+	ByEndpoint map[string]int64 `json:"by_endpoint"`
+}
+
+// PartnerUsageReport summarizes a partner's usage over a date range
+type PartnerUsageReport struct {
+	PartnerID  int               `json:"partner_id"`
+	Name       string            `json:"name"`
+	DailyQuota int               `json:"daily_quota"`
+	From       string            `json:"from"`
+	To         string            `json:"to"`
+	Total      int64             `json:"total"`
+	Days       []PartnerUsageDay `json:"days"`
+}
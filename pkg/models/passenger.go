@@ -0,0 +1,89 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaxPassengerNameLength is the longest accepted passenger name
+const MaxPassengerNameLength = 100
+
+// documentNumberPattern matches a normalized passport or national ID number
+var documentNumberPattern = regexp.MustCompile(`^[A-Z0-9]{5,20}$`)
+
+// Passenger is a traveller on a booking, identified by a travel document
+type Passenger struct {
+	Name           string `json:"name"`
+	DocumentNumber string `json:"document_number"` // Passport or national ID number
+}
+
+// NormalizeDocumentNumber uppercases a document number and drops spaces and hyphens, so
+// "z 123-4567" and "Z1234567" are the same document
+func NormalizeDocumentNumber(number string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(number)))
+}
+
+// MaskDocumentNumber hides all but the last four characters of a document number
+func MaskDocumentNumber(number string) string {
+	if len(number) <= 4 {
+		return strings.Repeat("*", len(number))
+	}
+	return strings.Repeat("*", len(number)-4) + number[len(number)-4:]
+}
+
+// validatePassengers checks optional passengers: one per seat, named, with distinct valid
+// document numbers. Document numbers are normalized in place.
+func validatePassengers(passengers []Passenger, seats int) error {
+	if len(passengers) == 0 {
+		return nil
+	}
+	if len(passengers) != seats {
+		return fmt.Errorf("expected one passenger per seat, got %d passengers for %d seats", len(passengers), seats)
+	}
+
+	seen := make(map[string]bool, len(passengers))
+	for i := range passengers {
+		p := &passengers[i]
+		p.Name = strings.TrimSpace(p.Name)
+		p.DocumentNumber = NormalizeDocumentNumber(p.DocumentNumber)
+		if p.Name == "" || len(p.Name) > MaxPassengerNameLength {
+			return fmt.Errorf("passenger %d needs a name of at most %d characters", i+1, MaxPassengerNameLength)
+		}
+		if !documentNumberPattern.MatchString(p.DocumentNumber) {
+			return fmt.Errorf("passenger %d has an invalid document number, expected 5-20 letters and digits", i+1)
+		}
+		if seen[p.DocumentNumber] {
+			return fmt.Errorf("passenger %d repeats another passenger's document number", i+1)
+		}
+		seen[p.DocumentNumber] = true
+	}
+	return nil
+}
+
+// ErrDuplicatePassenger is wrapped by every DuplicatePassengerError
+var ErrDuplicatePassenger = errors.New("passenger is already booked on this flight")
+
+// DuplicatePassengerError reports passengers who already hold an active booking on the flight date
+type DuplicatePassengerError struct {
+	FlightID        int
+	Date            string
+	DocumentNumbers []string // Masked to their last four characters
+}
+
+// Error describes the duplicates
+func (e *DuplicatePassengerError) Error() string {
+	return fmt.Sprintf("%v: flight %d on %s already has documents %s", ErrDuplicatePassenger, e.FlightID, e.Date,
+		strings.Join(e.DocumentNumbers, ", "))
+}
+
+// Unwrap lets errors.Is match ErrDuplicatePassenger
+func (e *DuplicatePassengerError) Unwrap() error {
+	return ErrDuplicatePassenger
+}
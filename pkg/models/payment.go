@@ -0,0 +1,229 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrAmountMismatch is wrapped by every AmountMismatchError
+var ErrAmountMismatch = errors.New("payment amount does not match the booking total")
+
+// AmountMismatchError reports a payment whose amount differs from the booking's quoted total
+type AmountMismatchError struct {
+	Expected Money // Booking total from the fare quote
+	Actual   Money // Amount the payment was for
+}
+
+// Error describes the mismatch
+func (e *AmountMismatchError) Error() string {
+	return fmt.Sprintf("%v: expected %s, got %s", ErrAmountMismatch, e.Expected, e.Actual)
+}
+
+// Unwrap lets errors.Is match ErrAmountMismatch
+func (e *AmountMismatchError) Unwrap() error {
+	return ErrAmountMismatch
+}
+
+// PaymentRequest represents a payment request
+type PaymentRequest struct {
+	BookingID   int    `json:"booking_id"`
+	Amount      Money  `json:"amount"`
+	UserID      int    `json:"user_id"`
+	PaymentType string `json:"payment_type"` // "credit_card", "debit_card", "upi", etc.
+	// Flight-service's signed booking total; required unless FARE_QUOTE_INSECURE_DEV is on
+	Quote *FareQuote `json:"quote,omitempty"`
+}
+
+// FareQuote is flight-service's signed statement of a booking's total. The payment
+// service only charges amounts backed by a valid, unexpired quote for the same user.
+type FareQuote struct {
+	FlightID  int       `json:"flight_id"`
+	Date      string    `json:"date"`
+	Seats     int       `json:"seats"`
+	UserID    int       `json:"user_id"`
+	Amount    Money     `json:"amount"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Signature string    `json:"signature"`
+}
+
+// PaymentResponse represents the response for payment processing
+type PaymentResponse struct {
+	PaymentID   string    `json:"payment_id"`
+	Status      string    `json:"status"`
+	Message     string    `json:"message,omitempty"`
+	BookingID   int       `json:"booking_id"`
+	Amount      Money     `json:"amount"`
+	ProcessedAt time.Time `json:"processed_at"`
+	// Deadline to capture a successful payment's authorization before it is voided
+	CaptureBy *time.Time `json:"capture_by,omitempty"`
+}
+
+// Authorization status constants
+const (
+	AuthorizationStatusAuthorized = "authorized"
+	AuthorizationStatusCaptured   = "captured"
+	AuthorizationStatusVoided     = "voided"
+	AuthorizationStatusRefunded   = "refunded" // Captured, then returned in full
+)
+
+// PaymentAuthorization is the hold a successful payment places on the customer's funds.
+// It must be captured once the booking is stored, or it is voided at CaptureBy.
+type PaymentAuthorization struct {
+	PaymentID    string     `json:"payment_id"`
+	BookingID    int        `json:"booking_id"`
+	UserID       int        `json:"user_id"`
+	Amount       Money      `json:"amount"`
+	Status       string     `json:"status"`
+	AuthorizedAt time.Time  `json:"authorized_at"`
+	CaptureBy    time.Time  `json:"capture_by"`
+	CapturedAt   *time.Time `json:"captured_at,omitempty"`
+	VoidedAt     *time.Time `json:"voided_at,omitempty"`
+	RefundedAt   *time.Time `json:"refunded_at,omitempty"`
+}
+
+// AuditLogEntry is one line of the payment audit log. Hash covers the entry's fields and
+// the previous entry's hash, so editing, removing, or reordering entries breaks the chain.
+type AuditLogEntry struct {
+	Seq      int64           `json:"seq"`
+	Time     time.Time       `json:"time"`
+	Event    string          `json:"event"`
+	Data     json.RawMessage `json:"data,omitempty"`   // Plaintext details (decrypted when read back with the key)
+	Sealed   string          `json:"sealed,omitempty"` // Base64 AES-GCM nonce and ciphertext of the details, with a key
+	PrevHash string          `json:"prev_hash"`
+	Hash     string          `json:"hash"`
+}
+
+// AuditLogEntriesResponse lists payment audit log entries
+type AuditLogEntriesResponse struct {
+	Entries []AuditLogEntry `json:"entries"`
+}
+
+// AuditLogReport is the result of verifying the payment audit log's hash chain
+type AuditLogReport struct {
+	Entries  int64  `json:"entries"`
+	Head     string `json:"head"` // Hash of the last entry; record it elsewhere to detect truncation
+	Valid    bool   `json:"valid"`
+	BrokenAt int64  `json:"broken_at,omitempty"` // Sequence of the first entry that fails verification
+	Error    string `json:"error,omitempty"`
+}
+
+// PaymentPersona is a deterministic outcome of the mock payment gateway: payments matching
+// it always end the same way instead of by the random failure and timeout rates
+type PaymentPersona struct {
+	Name    string `json:"name"`
+	Match   string `json:"match"` // Which payments the persona applies to
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// PaymentPersonasResponse lists the mock gateway's personas, in the order they are matched
+type PaymentPersonasResponse struct {
+	Personas []PaymentPersona `json:"personas"`
+	Enabled  bool             `json:"enabled"`
+}
+
+// PaymentRateWindow replaces the mock gateway's failure and timeout rates for part of every
+// period, e.g. 80% failures for 2 minutes every 15 minutes. Periods are aligned to the Unix
+// epoch, so a 15-minute period starts on the quarter hour.
+type PaymentRateWindow struct {
+	PeriodSeconds   int64   `json:"period_seconds"`
+	OffsetSeconds   int64   `json:"offset_seconds"` // Start of the window within each period
+	DurationSeconds int64   `json:"duration_seconds"`
+	FailureRate     float64 `json:"failure_rate"` // 0-1
+	TimeoutRate     float64 `json:"timeout_rate"` // 0-1
+}
+
+// Validate checks that the window fits in its period and its rates are probabilities
+func (w *PaymentRateWindow) Validate() error {
+	if w.PeriodSeconds <= 0 {
+		return fmt.Errorf("period_seconds must be positive")
+	}
+	if w.OffsetSeconds < 0 || w.OffsetSeconds >= w.PeriodSeconds {
+		return fmt.Errorf("offset_seconds must be within the period")
+	}
+	if w.DurationSeconds <= 0 || w.DurationSeconds > w.PeriodSeconds {
+		return fmt.Errorf("duration_seconds must be positive and at most the period")
+	}
+	if w.FailureRate < 0 || w.TimeoutRate < 0 || w.FailureRate+w.TimeoutRate > 1 {
+		return fmt.Errorf("failure_rate and timeout_rate must be non-negative and add up to at most 1")
+	}
+	return nil
+}
+
+// Active reports whether the window is in effect at t. A window running past the end of
+// its period carries over into the next one.
+func (w *PaymentRateWindow) Active(t time.Time) bool {
+	elapsed := (t.Unix() - w.OffsetSeconds) % w.PeriodSeconds
+	if elapsed < 0 {
+		elapsed += w.PeriodSeconds
+	}
+	return elapsed < w.DurationSeconds
+}
+
+// PaymentRateSchedule lists rate windows; the first active one sets the rates, and the
+// base rates apply outside all of them
+type PaymentRateSchedule struct {
+	Windows []PaymentRateWindow `json:"windows"`
+}
+
+// PaymentRatesResponse describes the mock gateway's rates now and its schedule
+type PaymentRatesResponse struct {
+	BaseFailureRate float64             `json:"base_failure_rate"`
+	BaseTimeoutRate float64             `json:"base_timeout_rate"`
+	FailureRate     float64             `json:"failure_rate"` // In effect now
+	TimeoutRate     float64             `json:"timeout_rate"` // In effect now
+	ActiveWindow    *int                `json:"active_window,omitempty"`
+	Schedule        PaymentRateSchedule `json:"schedule"`
+}
+
+// PaymentStatus constants
+const (
+	PaymentStatusSuccess = "success"
+	PaymentStatusFailed  = "failed"
+	PaymentStatusTimeout = "timeout"
+	PaymentStatusPending = "pending"
+)
+
+// PaymentType constants
+const (
+	PaymentTypeCreditCard = "credit_card"
+	PaymentTypeDebitCard  = "debit_card"
+	PaymentTypeUPI        = "upi"
+	PaymentTypeNetBanking = "net_banking"
+)
+
+// IsValidPaymentType checks if the payment type is valid
+func IsValidPaymentType(paymentType string) bool {
+	validTypes := []string{
+		PaymentTypeCreditCard,
+		PaymentTypeDebitCard,
+		PaymentTypeUPI,
+		PaymentTypeNetBanking,
+	}
+
+	for _, t := range validTypes {
+		if paymentType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidPaymentStatus checks if the payment status is valid
+func IsValidPaymentStatus(status string) bool {
+	validStatuses := []string{
+		PaymentStatusSuccess,
+		PaymentStatusFailed,
+		PaymentStatusTimeout,
+		PaymentStatusPending,
+	}
+
+	for _, s := range validStatuses {
+		if status == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// PopularRoute is one of the most searched routes with its cheapest upcoming direct fare
+type PopularRoute struct {
+	Source          string    `json:"source"`
+	Destination     string    `json:"destination"`
+	SourceCity      string    `json:"source_city,omitempty"`
+	DestinationCity string    `json:"destination_city,omitempty"`
+	Searches        int64     `json:"searches"` // Distinct search sessions over the last two UTC days
+	FlightID        int       `json:"flight_id"`
+	FlightNumber    string    `json:"flight_number"`
+	DepartureTime   time.Time `json:"departure_time"`
+	Price           Money     `json:"price"` // Per seat, before taxes
+}
+
+// PopularRoutesResponse is the precomputed list served by GET /api/flights/popular
+type PopularRoutesResponse struct {
+	Routes      []PopularRoute `json:"routes"`
+	GeneratedAt time.Time      `json:"generated_at"`
+}
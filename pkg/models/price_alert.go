@@ -0,0 +1,84 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Price alert statuses
+const (
+	PriceAlertStatusActive    = "active"
+	PriceAlertStatusTriggered = "triggered" // Notified once; alerts are one-shot
+	PriceAlertStatusExpired   = "expired"   // The travel date passed without reaching the target
+	PriceAlertStatusCancelled = "cancelled"
+)
+
+// PriceAlert is a subscription to be notified when a route's fare drops to a target
+type PriceAlert struct {
+	ID            int        `json:"id" db:"id"`
+	UserID        int        `json:"user_id" db:"user_id"`
+	Source        string     `json:"source" db:"source"`
+	Destination   string     `json:"destination" db:"destination"`
+	Date          string     `json:"date" db:"date"`
+	Seats         int        `json:"seats" db:"seats"`
+	TargetPrice   float64    `json:"target_price" db:"target_price"` // Per-seat fare
+	Email         string     `json:"email,omitempty" db:"email"`
+	Phone         string     `json:"phone,omitempty" db:"phone"`
+	Status        string     `json:"status" db:"status"`
+	LastPrice     *float64   `json:"last_price,omitempty" db:"last_price"` // Lowest fare at the last check
+	LastCheckedAt *time.Time `json:"last_checked_at,omitempty" db:"last_checked_at"`
+	TriggeredAt   *time.Time `json:"triggered_at,omitempty" db:"triggered_at"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+}
+
+// PriceAlertRequest represents a request to subscribe to a route's fares
+type PriceAlertRequest struct {
+	UserID      int     `json:"user_id"`
+	Source      string  `json:"source"`
+	Destination string  `json:"destination"`
+	Date        string  `json:"date"`
+	Seats       int     `json:"seats,omitempty"` // Defaults to 1
+	TargetPrice float64 `json:"target_price"`
+	Email       string  `json:"email,omitempty"`
+	Phone       string  `json:"phone,omitempty"`
+}
+
+// Validate checks the price alert request, normalizing airport codes and filling in defaults
+func (r *PriceAlertRequest) Validate(now time.Time) error {
+	r.Source = strings.ToUpper(strings.TrimSpace(r.Source))
+	r.Destination = strings.ToUpper(strings.TrimSpace(r.Destination))
+	if r.UserID <= 0 {
+		return fmt.Errorf("user_id is required")
+	}
+	if len(r.Source) != 3 || len(r.Destination) != 3 || r.Source == r.Destination {
+		return fmt.Errorf("source and destination must be different 3-letter airport codes")
+	}
+	date, err := time.Parse("2006-01-02", r.Date)
+	if err != nil {
+		return fmt.Errorf("invalid date, expected YYYY-MM-DD")
+	}
+	if date.Before(now.Truncate(24 * time.Hour)) {
+		return fmt.Errorf("date is in the past")
+	}
+	if r.Seats == 0 {
+		r.Seats = 1
+	}
+	if r.Seats < 0 {
+		return fmt.Errorf("invalid seats")
+	}
+	if r.TargetPrice <= 0 {
+		return fmt.Errorf("target_price must be positive")
+	}
+	if r.Email == "" && r.Phone == "" {
+		return fmt.Errorf("an email or phone is required for notifications")
+	}
+	return validateContact(r.Email, r.Phone)
+}
+
+// PriceAlertsResponse lists a user's price alerts
+type PriceAlertsResponse struct {
+	UserID int          `json:"user_id"`
+	Alerts []PriceAlert `json:"alerts"`
+	Count  int          `json:"count"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// UserDataExport bundles everything stored about a user for a data subject access request
+type UserDataExport struct {
+	UserID     int             `json:"user_id"`
+	ExportedAt time.Time       `json:"exported_at"`
+	Bookings   []Booking       `json:"bookings"`
+	Payments   []PaymentRecord `json:"payments"`
+	Passengers []PassengerData `json:"passengers"`
+	Erasures   []ErasureRecord `json:"erasures"`
+}
+
+// PaymentRecord is the financial record of a booking
+type PaymentRecord struct {
+	BookingID       int    `json:"booking_id"`
+	PaymentID       string `json:"payment_id,omitempty"`
+	Amount          Money  `json:"amount"`
+	RefundAmount    *Money `json:"refund_amount,omitempty"`
+	CancellationFee *Money `json:"cancellation_fee,omitempty"`
+	Status          string `json:"status"`
+}
+
+// PassengerData is the personal contact data captured with a booking
+type PassengerData struct {
+	BookingID    int         `json:"booking_id"`
+	Email        string      `json:"email,omitempty"`
+	Phone        string      `json:"phone,omitempty"`
+	Travellers   []Passenger `json:"travellers,omitempty"` // Names and documents; erased with the contacts
+	AnonymizedAt *time.Time  `json:"anonymized_at,omitempty"`
+}
+
+// ErasureRecord is the audit entry for an erasure of a user's personal data
+type ErasureRecord struct {
+	ID                 int       `json:"id" db:"id"`
+	UserID             int       `json:"user_id" db:"user_id"`
+	RequestedBy        string    `json:"requested_by" db:"requested_by"`
+	BookingsAnonymized int       `json:"bookings_anonymized" db:"bookings_anonymized"`
+	ErasedAt           time.Time `json:"erased_at" db:"erased_at"`
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FlightSchedule represents a recurring flight template that is materialized into per-date flights
+type FlightSchedule struct {
+	ID               int       `json:"id" db:"id"`
+	FlightNumber     string    `json:"flight_number" db:"flight_number"`
+	Source           string    `json:"source" db:"source"`
+	Destination      string    `json:"destination" db:"destination"`
+	DepartureTime    string    `json:"departure_time" db:"departure_time"`         // Local time, "15:04"
+	ArrivalTime      string    `json:"arrival_time" db:"arrival_time"`             // Local time, "15:04"
+	ArrivalDayOffset int       `json:"arrival_day_offset" db:"arrival_day_offset"` // 1 for overnight flights
+	DaysOfWeek       string    `json:"days_of_week" db:"days_of_week"`             // ISO weekdays, e.g. "135" = Mon, Wed, Fri
+	ValidFrom        string    `json:"valid_from" db:"valid_from"`                 // "2006-01-02"
+	ValidTo          string    `json:"valid_to" db:"valid_to"`                     // "2006-01-02"
+	TotalSeats       int       `json:"total_seats" db:"total_seats"`
+	Price            Money     `json:"price" db:"price"`
+	Active           bool      `json:"active" db:"active"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+}
+
+// MaterializeResponse represents the result of generating flights from schedules
+type MaterializeResponse struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Created int64  `json:"created"`
+}
+
+// Validate checks that the schedule fields are well formed
+func (s *FlightSchedule) Validate() error {
+	if s.FlightNumber == "" || s.Source == "" || s.Destination == "" {
+		return fmt.Errorf("flight_number, source, and destination are required")
+	}
+	if s.Source == s.Destination {
+		return fmt.Errorf("source and destination must differ")
+	}
+	if _, err := time.Parse("15:04", s.DepartureTime); err != nil {
+		return fmt.Errorf("invalid departure_time: %w", err)
+	}
+	if _, err := time.Parse("15:04", s.ArrivalTime); err != nil {
+		return fmt.Errorf("invalid arrival_time: %w", err)
+	}
+	if s.ArrivalDayOffset < 0 || s.ArrivalDayOffset > 2 {
+		return fmt.Errorf("arrival_day_offset must be between 0 and 2")
+	}
+	if s.DaysOfWeek == "" || strings.Trim(s.DaysOfWeek, "1234567") != "" {
+		return fmt.Errorf("days_of_week must contain ISO weekday digits 1-7")
+	}
+	from, err := time.Parse("2006-01-02", s.ValidFrom)
+	if err != nil {
+		return fmt.Errorf("invalid valid_from: %w", err)
+	}
+	to, err := time.Parse("2006-01-02", s.ValidTo)
+	if err != nil {
+		return fmt.Errorf("invalid valid_to: %w", err)
+	}
+	if to.Before(from) {
+		return fmt.Errorf("valid_to must not be before valid_from")
+	}
+	if s.TotalSeats <= 0 || !s.Price.IsPositive() {
+		return fmt.Errorf("total_seats and price must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Search abuse heuristics a subject can trip
+const (
+	SearchAnomalyVolume     = "volume"      // More searches than a person makes in the window
+	SearchAnomalyDateSweep  = "date_sweep"  // One route searched across many dates
+	SearchAnomalyRouteSweep = "route_sweep" // Many distinct routes searched
+)
+
+// SearchActivity summarizes a subject's searches in the sliding window. A subject is a
+// client IP ("ip:203.0.113.7") or a user ("user:42").
+type SearchActivity struct {
+	Subject          string `json:"subject"`
+	Searches         int    `json:"searches"`
+	Routes           int    `json:"routes"`
+	MaxDatesPerRoute int    `json:"max_dates_per_route"`
+	TopRoute         string `json:"top_route,omitempty"` // Route with the most distinct dates
+}
+
+// SearchAnomaly is a subject whose searches tripped an abuse heuristic
+type SearchAnomaly struct {
+	SearchActivity
+	Reasons        []string   `json:"reasons"`
+	DetectedAt     time.Time  `json:"detected_at"`
+	ThrottledUntil *time.Time `json:"throttled_until,omitempty"`
+}
+
+// SearchAnomalyReport lists recently flagged subjects alongside global search volume
+type SearchAnomalyReport struct {
+	Window         string          `json:"window"`
+	GlobalSearches int             `json:"global_searches"` // All tracked searches in the window
+	Throttling     bool            `json:"throttling"`      // Whether flagged subjects are throttled
+	Anomalies      []SearchAnomaly `json:"anomalies"`
+	Count          int             `json:"count"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// SearchJobRequest starts an exhaustive search that runs in the background
+type SearchJobRequest struct {
+	Source      string   `json:"source"`
+	Destination string   `json:"destination"`
+	Date        string   `json:"date"`
+	Seats       int      `json:"seats"`
+	SortBy      string   `json:"sort_by"`            // "cheapest", "fastest", or "recommended"
+	Airlines    []string `json:"airlines,omitempty"` // Only return paths whose every leg is operated by these airline codes
+	MaxStops    int      `json:"max_stops"`          // Connections allowed per path
+}
+
+// SearchJob is an async search and, once completed, its results
+type SearchJob struct {
+	ID          string            `json:"id"`
+	Status      string            `json:"status"`
+	Request     SearchJobRequest  `json:"request"`
+	Progress    SearchJobProgress `json:"progress"`
+	Paths       []FlightPath      `json:"paths,omitempty"`
+	Count       int               `json:"count"`
+	Truncated   bool              `json:"truncated,omitempty"` // More paths were found than are returned
+	Cached      bool              `json:"cached,omitempty"`    // Results were served from an earlier identical job
+	Error       string            `json:"error,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	CompletedAt *time.Time        `json:"completed_at,omitempty"`
+}
+
+// SearchJobProgress reports how far a search job is. Paths are searched by number of
+// stops, direct flights first.
+type SearchJobProgress struct {
+	StopsSearched int `json:"stops_searched"` // Stop counts finished so far
+	StopsTotal    int `json:"stops_total"`    // max_stops + 1
+	PathsFound    int `json:"paths_found"`
+}
+
+// SearchJob status constants
+const (
+	SearchJobQueued    = "queued"
+	SearchJobRunning   = "running"
+	SearchJobCompleted = "completed"
+	SearchJobFailed    = "failed"
+)
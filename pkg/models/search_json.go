@@ -0,0 +1,238 @@
+package models
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxPooledBuffer is the largest encoding buffer returned to the pool, so one huge
+// response does not pin its memory for the life of the process
+const maxPooledBuffer = 1 << 20
+
+// encodeBuffers holds reusable buffers for encoding search responses
+var encodeBuffers = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 16<<10)
+		return &buf
+	},
+}
+
+// WriteJSON writes the response as JSON followed by a newline, byte for byte what
+// json.NewEncoder(w).Encode(r) writes, encoded into a pooled buffer without reflection
+func (r *SearchResponse) WriteJSON(w io.Writer) error {
+	bufp := encodeBuffers.Get().(*[]byte)
+	buf := append(r.AppendJSON((*bufp)[:0]), '\n')
+
+	_, err := w.Write(buf)
+
+	if cap(buf) <= maxPooledBuffer {
+		*bufp = buf[:0]
+		encodeBuffers.Put(bufp)
+	}
+	return err
+}
+
+// AppendJSON appends the response's JSON encoding to buf
+func (r *SearchResponse) AppendJSON(buf []byte) []byte {
+	buf = append(buf, `{"paths":`...)
+	if r.Paths == nil {
+		buf = append(buf, "null"...)
+	} else {
+		buf = append(buf, '[')
+		for i := range r.Paths {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = r.Paths[i].AppendJSON(buf)
+		}
+		buf = append(buf, ']')
+	}
+
+	buf = append(buf, `,"count":`...)
+	buf = strconv.AppendInt(buf, int64(r.Count), 10)
+
+	if len(r.AirportPairs) > 0 {
+		buf = append(buf, `,"airport_pairs":[`...)
+		for i, pair := range r.AirportPairs {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = appendJSONString(buf, pair)
+		}
+		buf = append(buf, ']')
+	}
+
+	if len(r.Experiments) > 0 {
+		// Map keys are sorted, as encoding/json sorts them
+		keys := make([]string, 0, len(r.Experiments))
+		for key := range r.Experiments {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		buf = append(buf, `,"experiments":{`...)
+		for i, key := range keys {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = appendJSONString(buf, key)
+			buf = append(buf, ':')
+			buf = appendJSONString(buf, r.Experiments[key])
+		}
+		buf = append(buf, '}')
+	}
+
+	return append(buf, '}')
+}
+
+// AppendJSON appends the path's JSON encoding to buf
+func (p *FlightPath) AppendJSON(buf []byte) []byte {
+	buf = append(buf, `{"flights":`...)
+	if p.Flights == nil {
+		buf = append(buf, "null"...)
+	} else {
+		buf = append(buf, '[')
+		for i := range p.Flights {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = p.Flights[i].AppendJSON(buf)
+		}
+		buf = append(buf, ']')
+	}
+
+	buf = append(buf, `,"total_price":`...)
+	buf = p.TotalPrice.AppendJSON(buf)
+	buf = append(buf, `,"total_time_minutes":`...)
+	buf = strconv.AppendInt(buf, p.TotalTime, 10)
+	buf = append(buf, `,"stops":`...)
+	buf = strconv.AppendInt(buf, int64(p.Stops), 10)
+	if p.Score != 0 {
+		buf = append(buf, `,"score":`...)
+		buf = appendJSONFloat(buf, p.Score)
+	}
+	if p.AirportPair != "" {
+		buf = append(buf, `,"airport_pair":`...)
+		buf = appendJSONString(buf, p.AirportPair)
+	}
+	if p.Nearby {
+		buf = append(buf, `,"nearby":true`...)
+	}
+	return append(buf, '}')
+}
+
+// AppendJSON appends the flight's JSON encoding to buf
+func (f *Flight) AppendJSON(buf []byte) []byte {
+	buf = append(buf, `{"id":`...)
+	buf = strconv.AppendInt(buf, int64(f.ID), 10)
+	buf = append(buf, `,"flight_number":`...)
+	buf = appendJSONString(buf, f.FlightNumber)
+	buf = append(buf, `,"source":`...)
+	buf = appendJSONString(buf, f.Source)
+	buf = append(buf, `,"destination":`...)
+	buf = appendJSONString(buf, f.Destination)
+	buf = append(buf, `,"departure_time":`...)
+	buf = appendJSONTime(buf, f.DepartureTime)
+	buf = append(buf, `,"arrival_time":`...)
+	buf = appendJSONTime(buf, f.ArrivalTime)
+	buf = append(buf, `,"total_seats":`...)
+	buf = strconv.AppendInt(buf, int64(f.TotalSeats), 10)
+	buf = append(buf, `,"booked_seats":`...)
+	buf = strconv.AppendInt(buf, int64(f.BookedSeats), 10)
+	buf = append(buf, `,"price":`...)
+	buf = f.Price.AppendJSON(buf)
+	buf = append(buf, `,"created_at":`...)
+	buf = appendJSONTime(buf, f.CreatedAt)
+	if f.Status != "" {
+		buf = append(buf, `,"status":`...)
+		buf = appendJSONString(buf, f.Status)
+	}
+	if f.Airline != nil {
+		buf = append(buf, `,"airline":{"code":`...)
+		buf = appendJSONString(buf, f.Airline.Code)
+		buf = append(buf, `,"name":`...)
+		buf = appendJSONString(buf, f.Airline.Name)
+		buf = append(buf, '}')
+	}
+	if f.DepartureLocal != "" {
+		buf = append(buf, `,"departure_local":`...)
+		buf = appendJSONString(buf, f.DepartureLocal)
+	}
+	if f.ArrivalLocal != "" {
+		buf = append(buf, `,"arrival_local":`...)
+		buf = appendJSONString(buf, f.ArrivalLocal)
+	}
+	if f.DurationMinutes != 0 {
+		buf = append(buf, `,"duration_minutes":`...)
+		buf = strconv.AppendInt(buf, f.DurationMinutes, 10)
+	}
+	return append(buf, '}')
+}
+
+// AppendJSON appends the amount's JSON encoding, the same decimal as MarshalJSON, to buf
+func (m Money) AppendJSON(buf []byte) []byte {
+	minor := m.Minor
+	if minor == math.MinInt64 {
+		return append(buf, m.String()...)
+	}
+	if minor < 0 {
+		buf = append(buf, '-')
+		minor = -minor
+	}
+	buf = strconv.AppendInt(buf, minor/minorPerMajor, 10)
+	cents := minor % minorPerMajor
+	return append(buf, '.', byte('0'+cents/10), byte('0'+cents%10))
+}
+
+// appendJSONString appends s as a JSON string. Plain ASCII is copied directly; anything
+// encoding/json would escape (quotes, control and HTML characters, non-ASCII) is encoded
+// by it, so the output always matches.
+func appendJSONString(buf []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c >= 0x7f || c == '"' || c == '\\' || c == '<' || c == '>' || c == '&' {
+			encoded, _ := json.Marshal(s)
+			return append(buf, encoded...)
+		}
+	}
+	buf = append(buf, '"')
+	buf = append(buf, s...)
+	return append(buf, '"')
+}
+
+// appendJSONTime appends t as a JSON string in RFC 3339 with nanoseconds, as time.Time
+// marshals itself
+func appendJSONTime(buf []byte, t time.Time) []byte {
+	buf = append(buf, '"')
+	buf = t.AppendFormat(buf, time.RFC3339Nano)
+	return append(buf, '"')
+}
+
+// appendJSONFloat appends f the way encoding/json formats a float64: the shortest
+// representation, in exponent form only for very large or small magnitudes
+func appendJSONFloat(buf []byte, f float64) []byte {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		// Not representable in JSON; encoding/json fails on these too
+		return append(buf, "null"...)
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	start := len(buf)
+	buf = strconv.AppendFloat(buf, f, format, -1, 64)
+	if format == 'e' {
+		// Clean up e-09 to e-9
+		n := len(buf) - start
+		if n >= 4 && buf[len(buf)-4] == 'e' && buf[len(buf)-3] == '-' && buf[len(buf)-2] == '0' {
+			buf[len(buf)-2] = buf[len(buf)-1]
+			buf = buf[:len(buf)-1]
+		}
+	}
+	return buf
+}
@@ -0,0 +1,34 @@
+package models
+
+// Tax jurisdiction constants, by route domesticity
+const (
+	TaxJurisdictionDomestic      = "domestic"
+	TaxJurisdictionInternational = "international"
+)
+
+// TaxLine is one tax levied on a booking's base fare, e.g. CGST on a domestic flight
+type TaxLine struct {
+	Jurisdiction string  `json:"jurisdiction"`
+	Code         string  `json:"code"`           // e.g. "CGST", "IGST", "VAT"
+	Rate         float64 `json:"rate"`           // Percent of the taxable amount
+	Taxable      Money   `json:"taxable_amount"` // Base fare the rate applies to
+	Amount       Money   `json:"amount"`
+}
+
+// TaxReportLine totals one tax code and rate in a jurisdiction
+type TaxReportLine struct {
+	Jurisdiction string  `json:"jurisdiction"`
+	Code         string  `json:"code"`
+	Rate         float64 `json:"rate"`
+	Bookings     int     `json:"bookings"`
+	Taxable      Money   `json:"taxable_amount"`
+	Amount       Money   `json:"amount"`
+}
+
+// TaxReportResponse totals the taxes charged on bookings created in a date range
+type TaxReportResponse struct {
+	From  string          `json:"from"`
+	To    string          `json:"to"` // Inclusive
+	Lines []TaxReportLine `json:"lines"`
+	Total Money           `json:"total"`
+}
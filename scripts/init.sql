@@ -8,7 +8,7 @@ CREATE TABLE IF NOT EXISTS flights (
     arrival_time TIMESTAMP NOT NULL,
     total_seats INTEGER NOT NULL,
     booked_seats INTEGER DEFAULT 0,
-    price DECIMAL(10,2) NOT NULL,
+    price DECIMAL(12,2) NOT NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
@@ -18,7 +18,7 @@ CREATE TABLE IF NOT EXISTS bookings (
     user_id INTEGER NOT NULL,
     flight_id INTEGER NOT NULL,
     seats INTEGER NOT NULL,
-    total_amount DECIMAL(10,2) NOT NULL,
+    total_amount DECIMAL(12,2) NOT NULL,
     status VARCHAR(20) DEFAULT 'pending',
     payment_id VARCHAR(50),
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
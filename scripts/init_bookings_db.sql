@@ -4,13 +4,247 @@ CREATE TABLE IF NOT EXISTS bookings (
     user_id INTEGER NOT NULL,
     flight_id INTEGER NOT NULL,
     seats INTEGER NOT NULL,
-    total_amount DECIMAL(10,2) NOT NULL,
-    status VARCHAR(20) DEFAULT 'pending',
+    total_amount DECIMAL(12,2) NOT NULL,
+    status VARCHAR(20) DEFAULT 'pending' CHECK (status IN ('pending', 'confirmed', 'failed', 'cancelled', 'completed')),
     payment_id VARCHAR(50),
     date VARCHAR(10) NOT NULL, -- Flight date (YYYY-MM-DD)
+    fare_code VARCHAR(20) NOT NULL DEFAULT 'standard', -- Cancellation rule set (cancellation_policies)
+    cabin VARCHAR(20) NOT NULL DEFAULT 'economy', -- Changed by paid upgrades (booking_upgrades)
+    refund_amount DECIMAL(12,2), -- Set on cancellation
+    cancellation_fee DECIMAL(12,2), -- Set on cancellation
+    email VARCHAR(255), -- Confirmation contact
+    phone VARCHAR(20), -- Confirmation contact (E.164)
+    anonymized_at TIMESTAMP, -- Set when personal fields were erased
+    agency_id INTEGER, -- Agency that booked on its credit (agencies)
+    idempotency_key VARCHAR(100) NOT NULL, -- Client Idempotency-Key, or "payment:{payment_id}"
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, flight_id, date, idempotency_key),
+    CONSTRAINT bookings_amounts_non_negative
+        CHECK (total_amount >= 0 AND COALESCE(refund_amount, 0) >= 0 AND COALESCE(cancellation_fee, 0) >= 0)
+);
+
+-- Reject status changes outside the booking state machine (models.bookingTransitions):
+-- pending -> confirmed/failed/cancelled, confirmed -> cancelled/completed; the rest are final
+CREATE OR REPLACE FUNCTION bookings_status_transition() RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.status IS DISTINCT FROM OLD.status AND NOT (
+        (OLD.status = 'pending' AND NEW.status IN ('confirmed', 'failed', 'cancelled')) OR
+        (OLD.status = 'confirmed' AND NEW.status IN ('cancelled', 'completed'))
+    ) THEN
+        RAISE EXCEPTION 'invalid booking status transition: % -> %', OLD.status, NEW.status
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS bookings_status_transition ON bookings;
+CREATE TRIGGER bookings_status_transition
+    BEFORE UPDATE OF status ON bookings
+    FOR EACH ROW EXECUTE FUNCTION bookings_status_transition();
+
+-- Create booking read model table (denormalized copy of bookings and their segments,
+-- projected from booking events and serving booking reads)
+CREATE TABLE IF NOT EXISTS booking_read_model (
+    id INTEGER PRIMARY KEY, -- bookings.id
+    user_id INTEGER NOT NULL,
+    flight_id INTEGER NOT NULL,
+    seats INTEGER NOT NULL,
+    total_amount DECIMAL(12,2) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    payment_id VARCHAR(50),
+    date VARCHAR(10) NOT NULL,
+    fare_code VARCHAR(20) NOT NULL,
+    cabin VARCHAR(20) NOT NULL DEFAULT 'economy',
+    email VARCHAR(255),
+    phone VARCHAR(20),
+    agency_id INTEGER,
+    refund_amount DECIMAL(12,2),
+    cancellation_fee DECIMAL(12,2),
+    segments JSONB NOT NULL DEFAULT '[]', -- booking_segments rows in itinerary order
+    taxes JSONB NOT NULL DEFAULT '[]', -- booking_tax_lines rows in receipt order
+    created_at TIMESTAMP NOT NULL,
+    projected_at TIMESTAMP NOT NULL
+);
+
+-- Create cancellation policies table (fee schedule by hours before departure, per fare)
+CREATE TABLE IF NOT EXISTS cancellation_policies (
+    fare_code VARCHAR(20) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    refundable BOOLEAN NOT NULL DEFAULT TRUE,
+    tiers JSONB NOT NULL DEFAULT '[]', -- [{"min_hours_before_departure", "fee_percent", "flat_fee"}]
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO cancellation_policies (fare_code, name, refundable, tiers) VALUES
+('standard', 'Standard', TRUE, '[{"min_hours_before_departure": 72, "fee_percent": 10, "flat_fee": 0}, {"min_hours_before_departure": 24, "fee_percent": 25, "flat_fee": 0}, {"min_hours_before_departure": 2, "fee_percent": 50, "flat_fee": 0}]'),
+('flexi', 'Flexi', TRUE, '[{"min_hours_before_departure": 2, "fee_percent": 0, "flat_fee": 0}]'),
+('saver', 'Saver (non-refundable)', FALSE, '[]')
+ON CONFLICT (fare_code) DO NOTHING;
+
+-- Create booking segments table (flight terms snapshotted at confirmation)
+CREATE TABLE IF NOT EXISTS booking_segments (
+    booking_id INTEGER NOT NULL REFERENCES bookings(id),
+    segment_index INTEGER NOT NULL,
+    flight_id INTEGER NOT NULL,
+    flight_number VARCHAR(20) NOT NULL,
+    source VARCHAR(3) NOT NULL,
+    destination VARCHAR(3) NOT NULL,
+    departure_time TIMESTAMP NOT NULL,
+    arrival_time TIMESTAMP NOT NULL,
+    price DECIMAL(12,2) NOT NULL CONSTRAINT booking_segments_price_non_negative CHECK (price >= 0), -- Per-seat fare at booking time
+    PRIMARY KEY (booking_id, segment_index)
+);
+
+-- Create booking tax lines table (taxes included in a booking's total, as charged)
+CREATE TABLE IF NOT EXISTS booking_tax_lines (
+    booking_id INTEGER NOT NULL REFERENCES bookings(id),
+    line_index INTEGER NOT NULL,
+    payment_id VARCHAR(50), -- Matches bookings.payment_id
+    jurisdiction VARCHAR(20) NOT NULL, -- domestic, international
+    code VARCHAR(20) NOT NULL, -- e.g. CGST, SGST, IGST
+    rate DECIMAL(5,2) NOT NULL, -- Percent of taxable_amount
+    taxable_amount DECIMAL(12,2) NOT NULL,
+    amount DECIMAL(12,2) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (booking_id, line_index)
+);
+
+-- Create booking hold extensions table (each extension of a seat hold's expiry)
+CREATE TABLE IF NOT EXISTS booking_hold_extensions (
+    hold_id VARCHAR(36) NOT NULL,
+    extension INTEGER NOT NULL, -- 1 for a hold's first extension
+    user_id INTEGER NOT NULL,
+    flight_id INTEGER NOT NULL,
+    date DATE NOT NULL,
+    previous_expires_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (hold_id, extension)
+);
+
+-- Create booking upgrades table (accepted cabin upgrade offers and what they charged)
+CREATE TABLE IF NOT EXISTS booking_upgrades (
+    id SERIAL PRIMARY KEY,
+    booking_id INTEGER NOT NULL REFERENCES bookings(id),
+    offer_id VARCHAR(36) NOT NULL UNIQUE,
+    from_cabin VARCHAR(20) NOT NULL,
+    to_cabin VARCHAR(20) NOT NULL,
+    seats INTEGER NOT NULL,
+    amount DECIMAL(12,2) NOT NULL, -- Fare difference including taxes, added to the booking total
+    payment_id VARCHAR(50),
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Create booking links table (companions travelling together on separately paid bookings)
+CREATE TABLE IF NOT EXISTS booking_links (
+    booking_id INTEGER PRIMARY KEY REFERENCES bookings(id), -- A booking is in at most one group
+    group_id VARCHAR(36) NOT NULL,
+    linked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create passengers table (travellers named on a booking, checked for duplicates per flight date)
+CREATE TABLE IF NOT EXISTS passengers (
+    id SERIAL PRIMARY KEY,
+    booking_id INTEGER NOT NULL REFERENCES bookings(id),
+    flight_id INTEGER NOT NULL,
+    date DATE NOT NULL,
+    name VARCHAR(100), -- NULL once erased
+    document_number VARCHAR(20), -- Normalized passport or national ID number; NULL once erased
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create data erasures table (audit trail of personal data erasure requests)
+CREATE TABLE IF NOT EXISTS data_erasures (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    requested_by VARCHAR(100) NOT NULL,
+    bookings_anonymized INTEGER NOT NULL,
+    erased_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create agencies table (travel agents and corporate accounts booking on credit)
+CREATE TABLE IF NOT EXISTS agencies (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL UNIQUE, -- SHA-256 of the API key; the key itself is never stored
+    key_prefix VARCHAR(10) NOT NULL,
+    credit_limit DECIMAL(12,2) NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create agency invoices table
+CREATE TABLE IF NOT EXISTS agency_invoices (
+    id SERIAL PRIMARY KEY,
+    agency_id INTEGER NOT NULL REFERENCES agencies(id),
+    period_start TIMESTAMP NOT NULL,
+    period_end TIMESTAMP NOT NULL,
+    amount DECIMAL(12,2) NOT NULL,
+    entries INTEGER NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'open', -- open, paid
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    paid_at TIMESTAMP
+);
+
+-- Create agency ledger table (charges positive, credits negative)
+CREATE TABLE IF NOT EXISTS agency_ledger (
+    id SERIAL PRIMARY KEY,
+    agency_id INTEGER NOT NULL REFERENCES agencies(id),
+    payment_id VARCHAR(50) NOT NULL, -- Matches bookings.payment_id
+    entry_type VARCHAR(10) NOT NULL, -- charge, credit
+    amount DECIMAL(12,2) NOT NULL,
+    note VARCHAR(100),
+    invoice_id INTEGER REFERENCES agency_invoices(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create funnel reports table (distinct sessions per funnel stage, by route and UTC day)
+CREATE TABLE IF NOT EXISTS funnel_reports (
+    day DATE NOT NULL,
+    route VARCHAR(7) NOT NULL, -- e.g. DEL-BOM
+    searches BIGINT NOT NULL DEFAULT 0,
+    selections BIGINT NOT NULL DEFAULT 0,
+    booking_attempts BIGINT NOT NULL DEFAULT 0,
+    payments BIGINT NOT NULL DEFAULT 0,
+    confirmations BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (day, route)
+);
+
+-- Create loyalty ledger table (points accrued once per flown booking)
+CREATE TABLE IF NOT EXISTS loyalty_ledger (
+    booking_id INTEGER PRIMARY KEY REFERENCES bookings(id),
+    user_id INTEGER NOT NULL,
+    points INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Plans of slow queries captured with EXPLAIN ANALYZE, newest QUERY_EXPLAIN_KEEP per query
+CREATE TABLE IF NOT EXISTS query_explain_samples (
+    id BIGSERIAL PRIMARY KEY,
+    query_name VARCHAR(100) NOT NULL, -- Metrics label of the query
+    query TEXT NOT NULL,
+    duration_ms INTEGER NOT NULL, -- Duration of the slow run that was sampled
+    plan JSONB NOT NULL,
+    captured_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
 -- Create indexes for better performance
 CREATE INDEX IF NOT EXISTS idx_bookings_user_id ON bookings(user_id);
-CREATE INDEX IF NOT EXISTS idx_bookings_status ON bookings(status); 
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_bookings_status ON bookings(status);
+CREATE INDEX IF NOT EXISTS idx_bookings_agency_id ON bookings(agency_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_bookings_payment_id ON bookings(payment_id);
+CREATE INDEX IF NOT EXISTS idx_booking_read_model_user ON booking_read_model(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_booking_read_model_agency ON booking_read_model(agency_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_booking_read_model_flight ON booking_read_model(flight_id, date, created_at);
+CREATE INDEX IF NOT EXISTS idx_booking_tax_lines_created_at ON booking_tax_lines(created_at);
+CREATE INDEX IF NOT EXISTS idx_booking_hold_extensions_user ON booking_hold_extensions(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_passengers_flight_document ON passengers(flight_id, date, document_number);
+CREATE INDEX IF NOT EXISTS idx_passengers_booking_id ON passengers(booking_id);
+CREATE INDEX IF NOT EXISTS idx_booking_upgrades_booking_id ON booking_upgrades(booking_id);
+CREATE INDEX IF NOT EXISTS idx_booking_upgrades_payment_id ON booking_upgrades(payment_id);
+CREATE INDEX IF NOT EXISTS idx_booking_links_group_id ON booking_links(group_id);
+CREATE INDEX IF NOT EXISTS idx_loyalty_ledger_user_id ON loyalty_ledger(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_agency_ledger_unbilled ON agency_ledger(agency_id) WHERE invoice_id IS NULL;
+CREATE INDEX IF NOT EXISTS idx_query_explain_samples_name ON query_explain_samples(query_name, id); 
\ No newline at end of file
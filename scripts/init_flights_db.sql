@@ -8,13 +8,198 @@ CREATE TABLE IF NOT EXISTS flights (
     arrival_time TIMESTAMP NOT NULL,
     total_seats INTEGER NOT NULL,
     booked_seats INTEGER DEFAULT 0,
-    price DECIMAL(10,2) NOT NULL,
+    price DECIMAL(12,2) NOT NULL CONSTRAINT flights_price_positive CHECK (price > 0),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    airline_code VARCHAR(2) GENERATED ALWAYS AS (SUBSTRING(flight_number FROM 1 FOR 2)) STORED,
+    schedule_id INTEGER, -- Set for flights materialized from flight_schedules
+    feed_source VARCHAR(50), -- Set for flights imported from an external schedule feed
+    feed_key VARCHAR(64), -- The feed record's identity, "<flight_number>/<date>"
+    status VARCHAR(20) NOT NULL DEFAULT 'scheduled' CHECK (status IN ('scheduled', 'cancelled'))
+);
+
+-- Create flight schedules table (recurring templates materialized into flights)
+CREATE TABLE IF NOT EXISTS flight_schedules (
+    id SERIAL PRIMARY KEY,
+    flight_number VARCHAR(20) NOT NULL,
+    source VARCHAR(3) NOT NULL,
+    destination VARCHAR(3) NOT NULL,
+    departure_time TIME NOT NULL, -- Local time at source
+    arrival_time TIME NOT NULL, -- Local time at destination
+    arrival_day_offset INTEGER NOT NULL DEFAULT 0,
+    days_of_week VARCHAR(7) NOT NULL, -- ISO weekdays, e.g. '12345' for weekdays
+    valid_from DATE NOT NULL,
+    valid_to DATE NOT NULL,
+    total_seats INTEGER NOT NULL,
+    price DECIMAL(12,2) NOT NULL CONSTRAINT flight_schedules_price_positive CHECK (price > 0),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK (valid_to >= valid_from)
+);
+
+-- Create flight inventory table (seat inventory per flight and date, aligned with
+-- the flight_seats:{flight_id}:{date} Redis counters). booked_seats includes seats sold
+-- outside this system (flights.booked_seats) plus confirmed bookings.
+CREATE TABLE IF NOT EXISTS flight_inventory (
+    flight_id INTEGER NOT NULL REFERENCES flights(id),
+    date DATE NOT NULL,
+    total_seats INTEGER NOT NULL,
+    booked_seats INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (flight_id, date),
+    CHECK (booked_seats >= 0 AND booked_seats <= total_seats)
+);
+
+-- Append-only seat inventory events, the source of truth when SEAT_EVENT_SOURCING is on.
+-- A flight date's counter is its base capacity plus the sum of its events' seats; the
+-- Redis counter is a projection that `flightsctl seats rebuild` replays from here.
+CREATE TABLE IF NOT EXISTS seat_events (
+    id BIGSERIAL PRIMARY KEY,
+    flight_id INTEGER NOT NULL REFERENCES flights(id),
+    date DATE NOT NULL,
+    kind VARCHAR(10) NOT NULL CHECK (kind IN ('reserve', 'release', 'adjust')),
+    seats INTEGER NOT NULL, -- Signed change to available seats: negative for reserve
+    reason VARCHAR(50) NOT NULL,
+    actor VARCHAR(100),
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Premium cabin inventory per flight date; economy is the flight's main seat counter
+CREATE TABLE IF NOT EXISTS cabin_inventory (
+    flight_id INTEGER NOT NULL REFERENCES flights(id),
+    date DATE NOT NULL,
+    cabin VARCHAR(20) NOT NULL CHECK (cabin IN ('premium_economy', 'business', 'first')),
+    total_seats INTEGER NOT NULL,
+    booked_seats INTEGER NOT NULL DEFAULT 0,
+    price DECIMAL(12,2) NOT NULL CHECK (price > 0), -- Per seat, before taxes
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (flight_id, date, cabin),
+    CHECK (booked_seats >= 0 AND booked_seats <= total_seats)
+);
+
+-- Create airlines table
+CREATE TABLE IF NOT EXISTS airlines (
+    code VARCHAR(2) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL
+);
+
+-- Create partners table (read-only partner API keys; only the key hash is stored)
+CREATE TABLE IF NOT EXISTS partners (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE, -- SHA-256 of the API key
+    key_prefix VARCHAR(10) NOT NULL,
+    scopes VARCHAR(100) NOT NULL DEFAULT 'search,availability',
+    requests_per_minute INTEGER NOT NULL,
+    daily_quota INTEGER NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create price alerts table (one-shot fare drop subscriptions checked by a background job)
+CREATE TABLE IF NOT EXISTS price_alerts (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    source VARCHAR(3) NOT NULL,
+    destination VARCHAR(3) NOT NULL,
+    date DATE NOT NULL,
+    seats INTEGER NOT NULL DEFAULT 1,
+    target_price DECIMAL(12,2) NOT NULL, -- Per-seat fare
+    email VARCHAR(255),
+    phone VARCHAR(20),
+    status VARCHAR(10) NOT NULL DEFAULT 'active', -- active, triggered, expired, cancelled
+    last_price DECIMAL(12,2),
+    last_checked_at TIMESTAMP,
+    triggered_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Fewest legs between airport pairs over routes with upcoming flights, rebuilt by the
+-- route-graph job; multi-stop searches skip depths that cannot reach the destination
+CREATE TABLE IF NOT EXISTS route_graph (
+    source VARCHAR(3) NOT NULL,
+    destination VARCHAR(3) NOT NULL,
+    min_legs INTEGER NOT NULL,
+    refreshed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (source, destination)
+);
+
+-- Plans of slow queries captured with EXPLAIN ANALYZE, newest QUERY_EXPLAIN_KEEP per query
+CREATE TABLE IF NOT EXISTS query_explain_samples (
+    id BIGSERIAL PRIMARY KEY,
+    query_name VARCHAR(100) NOT NULL, -- Metrics label of the query
+    query TEXT NOT NULL,
+    duration_ms INTEGER NOT NULL, -- Duration of the slow run that was sampled
+    plan JSONB NOT NULL,
+    captured_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Final seat counters of departed flight dates, moved out of Redis by the seat-counter-archive job
+CREATE TABLE IF NOT EXISTS seat_counter_archive (
+    flight_id INTEGER NOT NULL REFERENCES flights(id),
+    date DATE NOT NULL,
+    available INTEGER NOT NULL, -- Seats left unsold when the counter was archived
+    archived_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (flight_id, date)
+);
+
 -- Create indexes for better performance
 CREATE INDEX IF NOT EXISTS idx_flights_source_dest_date ON flights(source, destination, departure_time);
-CREATE INDEX IF NOT EXISTS idx_flights_source ON flights(source);
+CREATE INDEX IF NOT EXISTS idx_flights_source_departure ON flights(source, departure_time);
+CREATE INDEX IF NOT EXISTS idx_flights_available_route ON flights(source, destination, departure_time)
+    WHERE status <> 'cancelled' AND booked_seats < total_seats;
+CREATE INDEX IF NOT EXISTS idx_flights_flight_number ON flights(flight_number, departure_time);
+CREATE INDEX IF NOT EXISTS idx_flights_schedule_date ON flights(schedule_id, (DATE(departure_time))) WHERE schedule_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_flights_feed_key ON flights(feed_source, feed_key) WHERE feed_source IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_seat_events_flight_date ON seat_events(flight_id, date, id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_seat_events_opening ON seat_events(flight_id, date) WHERE reason = 'opening_balance';
+CREATE INDEX IF NOT EXISTS idx_query_explain_samples_name ON query_explain_samples(query_name, id);
+CREATE INDEX IF NOT EXISTS idx_price_alerts_user_id ON price_alerts(user_id);
+CREATE INDEX IF NOT EXISTS idx_price_alerts_active ON price_alerts(source, destination, date) WHERE status = 'active';
+
+-- Create airports table (used for nearby-airport search expansion)
+CREATE TABLE IF NOT EXISTS airports (
+    code VARCHAR(3) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    city VARCHAR(100) NOT NULL,
+    latitude DOUBLE PRECISION NOT NULL,
+    longitude DOUBLE PRECISION NOT NULL,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC', -- IANA zone; flight times are stored in local wall-clock time
+    country CHAR(2) NOT NULL DEFAULT 'IN' -- ISO 3166; routes within TAX_HOME_COUNTRY are taxed as domestic
+);
+
+-- Create airport groups table (metro areas served by several airports)
+CREATE TABLE IF NOT EXISTS airport_groups (
+    group_code VARCHAR(10) NOT NULL,
+    airport_code VARCHAR(3) NOT NULL REFERENCES airports(code),
+    PRIMARY KEY (group_code, airport_code)
+);
+
+-- Insert sample airline data
+INSERT INTO airlines (code, name) VALUES
+('AI', 'Air India'),
+('6E', 'IndiGo'),
+('UK', 'Vistara'),
+('SG', 'SpiceJet')
+ON CONFLICT (code) DO NOTHING;
+
+-- Insert sample airport data
+INSERT INTO airports (code, name, city, latitude, longitude, timezone, country) VALUES
+('DEL', 'Indira Gandhi International Airport', 'Delhi', 28.5562, 77.1000, 'Asia/Kolkata', 'IN'),
+('DXN', 'Noida International Airport', 'Noida', 28.1767, 77.6089, 'Asia/Kolkata', 'IN'),
+('BOM', 'Chhatrapati Shivaji Maharaj International Airport', 'Mumbai', 19.0896, 72.8656, 'Asia/Kolkata', 'IN'),
+('NMI', 'Navi Mumbai International Airport', 'Navi Mumbai', 18.9936, 73.0700, 'Asia/Kolkata', 'IN'),
+('BLR', 'Kempegowda International Airport', 'Bengaluru', 13.1986, 77.7066, 'Asia/Kolkata', 'IN'),
+('HYD', 'Rajiv Gandhi International Airport', 'Hyderabad', 17.2403, 78.4294, 'Asia/Kolkata', 'IN'),
+('CCU', 'Netaji Subhas Chandra Bose International Airport', 'Kolkata', 22.6547, 88.4467, 'Asia/Kolkata', 'IN'),
+('DXB', 'Dubai International Airport', 'Dubai', 25.2532, 55.3657, 'Asia/Dubai', 'AE')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO airport_groups (group_code, airport_code) VALUES
+('NCR', 'DEL'),
+('NCR', 'DXN'),
+('MMR', 'BOM'),
+('MMR', 'NMI')
+ON CONFLICT DO NOTHING;
 
 -- Insert sample flight data
 INSERT INTO flights (flight_number, source, destination, departure_time, arrival_time, total_seats, booked_seats, price) VALUES
@@ -47,4 +232,9 @@ INSERT INTO flights (flight_number, source, destination, departure_time, arrival
 -- Return flights
 ('AI501', 'BOM', 'DEL', '2024-02-15 11:00:00', '2024-02-15 13:30:00', 180, 40, 8500.00),
 ('AI502', 'BLR', 'DEL', '2024-02-15 13:00:00', '2024-02-15 16:00:00', 180, 35, 12000.00),
-('AI503', 'BLR', 'BOM', '2024-02-15 12:00:00', '2024-02-15 13:30:00', 180, 30, 6500.00); 
\ No newline at end of file
+('AI503', 'BLR', 'BOM', '2024-02-15 12:00:00', '2024-02-15 13:30:00', 180, 30, 6500.00);
+
+-- Seed inventory for the sample flights
+INSERT INTO flight_inventory (flight_id, date, total_seats, booked_seats)
+SELECT id, DATE(departure_time), total_seats, booked_seats FROM flights
+ON CONFLICT (flight_id, date) DO NOTHING;
@@ -0,0 +1,22 @@
+-- Money columns: booking amounts are exact DECIMAL amounts with two decimals, read into
+-- integer minor units (models.Money) without a float conversion. Widened to DECIMAL(12,2)
+-- so every booking total fits the agency ledger. Changing the type rewrites the tables,
+-- so run this in a maintenance window, e.g. with `make migrate-bookings`.
+
+ALTER TABLE bookings
+    ALTER COLUMN total_amount TYPE DECIMAL(12,2),
+    ALTER COLUMN refund_amount TYPE DECIMAL(12,2),
+    ALTER COLUMN cancellation_fee TYPE DECIMAL(12,2),
+    DROP CONSTRAINT IF EXISTS bookings_amounts_non_negative,
+    ADD CONSTRAINT bookings_amounts_non_negative
+        CHECK (total_amount >= 0 AND COALESCE(refund_amount, 0) >= 0 AND COALESCE(cancellation_fee, 0) >= 0);
+
+ALTER TABLE booking_read_model
+    ALTER COLUMN total_amount TYPE DECIMAL(12,2),
+    ALTER COLUMN refund_amount TYPE DECIMAL(12,2),
+    ALTER COLUMN cancellation_fee TYPE DECIMAL(12,2);
+
+ALTER TABLE booking_segments
+    ALTER COLUMN price TYPE DECIMAL(12,2),
+    DROP CONSTRAINT IF EXISTS booking_segments_price_non_negative,
+    ADD CONSTRAINT booking_segments_price_non_negative CHECK (price >= 0);
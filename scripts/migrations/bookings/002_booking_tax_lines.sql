@@ -0,0 +1,19 @@
+-- Tax lines included in each booking's total, stored as charged for tax reports, and
+-- projected into the booking read model. Apply with `make migrate-bookings`.
+
+CREATE TABLE IF NOT EXISTS booking_tax_lines (
+    booking_id INTEGER NOT NULL REFERENCES bookings(id),
+    line_index INTEGER NOT NULL,
+    payment_id VARCHAR(50), -- Matches bookings.payment_id
+    jurisdiction VARCHAR(20) NOT NULL, -- domestic, international
+    code VARCHAR(20) NOT NULL, -- e.g. CGST, SGST, IGST
+    rate DECIMAL(5,2) NOT NULL, -- Percent of taxable_amount
+    taxable_amount DECIMAL(12,2) NOT NULL,
+    amount DECIMAL(12,2) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (booking_id, line_index)
+);
+
+CREATE INDEX IF NOT EXISTS idx_booking_tax_lines_created_at ON booking_tax_lines(created_at);
+
+ALTER TABLE booking_read_model ADD COLUMN IF NOT EXISTS taxes JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,16 @@
+-- Extensions of seat holds (temporary bookings) past their initial expiry, recorded by
+-- POST /api/bookings/holds/{id}/extend. Apply with `make migrate-bookings`.
+
+CREATE TABLE IF NOT EXISTS booking_hold_extensions (
+    hold_id VARCHAR(36) NOT NULL,
+    extension INTEGER NOT NULL, -- 1 for a hold's first extension
+    user_id INTEGER NOT NULL,
+    flight_id INTEGER NOT NULL,
+    date DATE NOT NULL,
+    previous_expires_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (hold_id, extension)
+);
+
+CREATE INDEX IF NOT EXISTS idx_booking_hold_extensions_user ON booking_hold_extensions(user_id, created_at);
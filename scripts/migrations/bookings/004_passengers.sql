@@ -0,0 +1,15 @@
+-- Travellers named on a booking, one per seat, used to detect the same passenger booked
+-- twice on a flight date (DUPLICATE_PASSENGER_CHECK). Apply with `make migrate-bookings`.
+
+CREATE TABLE IF NOT EXISTS passengers (
+    id SERIAL PRIMARY KEY,
+    booking_id INTEGER NOT NULL REFERENCES bookings(id),
+    flight_id INTEGER NOT NULL,
+    date DATE NOT NULL,
+    name VARCHAR(100), -- NULL once erased
+    document_number VARCHAR(20), -- Normalized passport or national ID number; NULL once erased
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_passengers_flight_document ON passengers(flight_id, date, document_number);
+CREATE INDEX IF NOT EXISTS idx_passengers_booking_id ON passengers(booking_id);
@@ -0,0 +1,19 @@
+-- Cabin upgrades: bookings record their cabin, and each accepted upgrade offer is kept with
+-- the amount it added to the booking. Apply with `make migrate-bookings`.
+
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS cabin VARCHAR(20) NOT NULL DEFAULT 'economy';
+ALTER TABLE booking_read_model ADD COLUMN IF NOT EXISTS cabin VARCHAR(20) NOT NULL DEFAULT 'economy';
+
+CREATE TABLE IF NOT EXISTS booking_upgrades (
+    id SERIAL PRIMARY KEY,
+    booking_id INTEGER NOT NULL REFERENCES bookings(id),
+    offer_id VARCHAR(36) NOT NULL UNIQUE,
+    from_cabin VARCHAR(20) NOT NULL,
+    to_cabin VARCHAR(20) NOT NULL,
+    seats INTEGER NOT NULL,
+    amount DECIMAL(12,2) NOT NULL, -- Fare difference including taxes, added to the booking total
+    payment_id VARCHAR(50),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_booking_upgrades_booking_id ON booking_upgrades(booking_id);
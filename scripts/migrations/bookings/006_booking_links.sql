@@ -0,0 +1,10 @@
+-- Linked bookings: companions on the same flight date who paid separately share a group,
+-- used for the shared itinerary and cancellation prompts. Apply with `make migrate-bookings`.
+
+CREATE TABLE IF NOT EXISTS booking_links (
+    booking_id INTEGER PRIMARY KEY REFERENCES bookings(id), -- A booking is in at most one group
+    group_id VARCHAR(36) NOT NULL,
+    linked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_booking_links_group_id ON booking_links(group_id);
@@ -0,0 +1,13 @@
+-- Plans of slow queries captured with EXPLAIN ANALYZE (QUERY_EXPLAIN_SAMPLE_RATE), newest
+-- QUERY_EXPLAIN_KEEP per query. Apply with `make migrate-bookings`.
+
+CREATE TABLE IF NOT EXISTS query_explain_samples (
+    id BIGSERIAL PRIMARY KEY,
+    query_name VARCHAR(100) NOT NULL, -- Metrics label of the query
+    query TEXT NOT NULL,
+    duration_ms INTEGER NOT NULL, -- Duration of the slow run that was sampled
+    plan JSONB NOT NULL,
+    captured_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_query_explain_samples_name ON query_explain_samples(query_name, id);
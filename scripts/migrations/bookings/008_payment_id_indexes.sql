@@ -0,0 +1,5 @@
+-- Bookings and upgrades are looked up by payment when payment-service voids an uncaptured
+-- authorization. Apply with `make migrate-bookings`.
+
+CREATE INDEX IF NOT EXISTS idx_bookings_payment_id ON bookings(payment_id);
+CREATE INDEX IF NOT EXISTS idx_booking_upgrades_payment_id ON booking_upgrades(payment_id);
@@ -0,0 +1,28 @@
+-- Booking status state machine (models.bookingTransitions) for databases created before
+-- init_bookings_db.sql enforced it: statuses are limited to the known set and changes
+-- outside pending -> confirmed/failed/cancelled, confirmed -> cancelled/completed are
+-- rejected. Fails if a booking already has an unknown status; fix those rows first.
+-- Apply with `make migrate-bookings`.
+
+ALTER TABLE bookings
+    DROP CONSTRAINT IF EXISTS bookings_status_check,
+    ADD CONSTRAINT bookings_status_check
+        CHECK (status IN ('pending', 'confirmed', 'failed', 'cancelled', 'completed'));
+
+CREATE OR REPLACE FUNCTION bookings_status_transition() RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.status IS DISTINCT FROM OLD.status AND NOT (
+        (OLD.status = 'pending' AND NEW.status IN ('confirmed', 'failed', 'cancelled')) OR
+        (OLD.status = 'confirmed' AND NEW.status IN ('cancelled', 'completed'))
+    ) THEN
+        RAISE EXCEPTION 'invalid booking status transition: % -> %', OLD.status, NEW.status
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS bookings_status_transition ON bookings;
+CREATE TRIGGER bookings_status_transition
+    BEFORE UPDATE OF status ON bookings
+    FOR EACH ROW EXECUTE FUNCTION bookings_status_transition();
@@ -0,0 +1,19 @@
+-- Booking idempotency keys for databases created before init_bookings_db.sql had them:
+-- permanent bookings are inserted with ON CONFLICT on (user_id, flight_id, date,
+-- idempotency_key), so both the column and the unique constraint must exist. Existing
+-- bookings get "payment:{payment_id}", as bookings created without an Idempotency-Key
+-- header do, or "booking:{id}" when they have no payment. Fails if two bookings of one
+-- user, flight, and date share a payment ID; fix those rows first.
+-- Apply with `make migrate-bookings`.
+
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS idempotency_key VARCHAR(100);
+
+UPDATE bookings
+SET idempotency_key = COALESCE('payment:' || payment_id, 'booking:' || id)
+WHERE idempotency_key IS NULL;
+
+ALTER TABLE bookings
+    ALTER COLUMN idempotency_key SET NOT NULL,
+    DROP CONSTRAINT IF EXISTS bookings_user_id_flight_id_date_idempotency_key_key,
+    ADD CONSTRAINT bookings_user_id_flight_id_date_idempotency_key_key
+        UNIQUE (user_id, flight_id, date, idempotency_key);
@@ -0,0 +1,20 @@
+-- Search indexes for flight search on existing databases (new ones get them from
+-- init_flights_db.sql). Built CONCURRENTLY so flights stays writable; run outside a
+-- transaction, e.g. with `make migrate-flights`. cmd/searchbench applies this file to a
+-- synthetic schema to measure its effect.
+
+-- Direct searches and the recursive CTE's connecting legs: route then departure range
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_flights_source_dest_date ON flights(source, destination, departure_time);
+
+-- First leg of the recursive CTE: every departure from the source on the date
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_flights_source_departure ON flights(source, departure_time);
+
+-- Bookable flights only; searches filter on the same predicate so full and cancelled
+-- flights are never visited
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_flights_available_route ON flights(source, destination, departure_time)
+    WHERE status <> 'cancelled' AND booked_seats < total_seats;
+
+-- Covered by idx_flights_source_departure
+DROP INDEX CONCURRENTLY IF EXISTS idx_flights_source;
+
+ANALYZE flights;
@@ -0,0 +1,18 @@
+-- Money columns: fares are exact DECIMAL amounts with two decimals, read into integer
+-- minor units (models.Money) without a float conversion. Widened to DECIMAL(12,2) to
+-- match the agency ledger. Changing the type rewrites the table, so run this in a
+-- maintenance window, e.g. with `make migrate-flights`.
+
+ALTER TABLE flights
+    ALTER COLUMN price TYPE DECIMAL(12,2),
+    DROP CONSTRAINT IF EXISTS flights_price_positive,
+    ADD CONSTRAINT flights_price_positive CHECK (price > 0);
+
+ALTER TABLE flight_schedules
+    ALTER COLUMN price TYPE DECIMAL(12,2),
+    DROP CONSTRAINT IF EXISTS flight_schedules_price_positive,
+    ADD CONSTRAINT flight_schedules_price_positive CHECK (price > 0);
+
+ALTER TABLE price_alerts
+    ALTER COLUMN target_price TYPE DECIMAL(12,2),
+    ALTER COLUMN last_price TYPE DECIMAL(12,2);
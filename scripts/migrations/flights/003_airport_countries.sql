@@ -0,0 +1,5 @@
+-- Airport countries decide a route's tax jurisdiction: routes between two airports in
+-- TAX_HOME_COUNTRY are domestic, every other route international. Existing airports
+-- default to 'IN'; correct foreign ones after applying, e.g. with `make migrate-flights`.
+
+ALTER TABLE airports ADD COLUMN IF NOT EXISTS country CHAR(2) NOT NULL DEFAULT 'IN';
@@ -0,0 +1,16 @@
+-- Append-only seat inventory events for SEAT_EVENT_SOURCING. A flight date's first event is
+-- an opening_balance adjustment carrying the counter it had before events were recorded.
+
+CREATE TABLE IF NOT EXISTS seat_events (
+    id BIGSERIAL PRIMARY KEY,
+    flight_id INTEGER NOT NULL REFERENCES flights(id),
+    date DATE NOT NULL,
+    kind VARCHAR(10) NOT NULL CHECK (kind IN ('reserve', 'release', 'adjust')),
+    seats INTEGER NOT NULL, -- Signed change to available seats: negative for reserve
+    reason VARCHAR(50) NOT NULL,
+    actor VARCHAR(100),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_seat_events_flight_date ON seat_events(flight_id, date, id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_seat_events_opening ON seat_events(flight_id, date) WHERE reason = 'opening_balance';
@@ -0,0 +1,15 @@
+-- Premium cabin inventory: seats a flight date sells above economy, which stays the flight's
+-- main seat counter. Seats reach these cabins through paid upgrades
+-- (POST /api/flights/cabins/move). Apply with `make migrate-flights`.
+
+CREATE TABLE IF NOT EXISTS cabin_inventory (
+    flight_id INTEGER NOT NULL REFERENCES flights(id),
+    date DATE NOT NULL,
+    cabin VARCHAR(20) NOT NULL CHECK (cabin IN ('premium_economy', 'business', 'first')),
+    total_seats INTEGER NOT NULL,
+    booked_seats INTEGER NOT NULL DEFAULT 0,
+    price DECIMAL(12,2) NOT NULL CHECK (price > 0), -- Per seat, before taxes
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (flight_id, date, cabin),
+    CHECK (booked_seats >= 0 AND booked_seats <= total_seats)
+);
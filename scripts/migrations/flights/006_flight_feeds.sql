@@ -0,0 +1,8 @@
+-- Flights imported from an external schedule/availability feed (FLIGHT_FEED_FILE) record the
+-- feed and the record they came from, so each sync can diff the feed against them.
+-- Apply with `make migrate-flights`.
+
+ALTER TABLE flights ADD COLUMN IF NOT EXISTS feed_source VARCHAR(50);
+ALTER TABLE flights ADD COLUMN IF NOT EXISTS feed_key VARCHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_flights_feed_key ON flights(feed_source, feed_key) WHERE feed_source IS NOT NULL;
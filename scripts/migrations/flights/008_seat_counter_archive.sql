@@ -0,0 +1,10 @@
+-- Final seat counters of departed flight dates, moved out of Redis by the seat-counter-archive
+-- job SEAT_COUNTER_ARCHIVE_AFTER after their departure day. Apply with `make migrate-flights`.
+
+CREATE TABLE IF NOT EXISTS seat_counter_archive (
+    flight_id INTEGER NOT NULL REFERENCES flights(id),
+    date DATE NOT NULL,
+    available INTEGER NOT NULL, -- Seats left unsold when the counter was archived
+    archived_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (flight_id, date)
+);